@@ -0,0 +1,157 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements checking, as a file is loaded, for calls that can never have the right
+// number of arguments.
+
+package golisp
+
+import (
+	"errors"
+)
+
+// CheckArity returns an ArityError for each call in sexpr whose argument count can't match
+// what the primitive or function it calls, as bound in env, accepts.  Like the optimizer it
+// leaves alone quoted data, the arguments of macros, and calls to names that aren't defined
+// yet or that are rebound locally.
+func CheckArity(sexpr *Data, env *SymbolTableFrame) (problems []*LispError) {
+	checkArity(sexpr, env, nil, &problems)
+	return
+}
+
+// reportArityProblems warns about the problems CheckArity finds in sexpr, or returns the
+// first of them if Config.StrictArityChecking is set
+func reportArityProblems(sexpr *Data, env *SymbolTableFrame) error {
+	for _, problem := range CheckArity(sexpr, env) {
+		if strictArityChecking() {
+			return problem
+		}
+		LogWarnf("Warning: %s\n", problem)
+	}
+	return nil
+}
+
+// noteArityProblem records a problem if err is one
+func noteArityProblem(err error, call *Data, problems *[]*LispError) {
+	var lispError *LispError
+	if !errors.As(err, &lispError) {
+		return
+	}
+	lispError.Form = call
+	lispError.Source = SourcePositionOf(call)
+	*problems = append(*problems, lispError)
+}
+
+// definedNames returns the names defined by the define forms directly in body, which are
+// local to the body
+func definedNames(body *Data, env *SymbolTableFrame, shadowed map[string]bool) (names []*Data) {
+	for c := body; nonEmptyPairP(c); c = Cdr(c) {
+		sexpr := Car(c)
+		if !nonEmptyPairP(sexpr) || specialFormNamed(Car(sexpr), env, shadowed) != "define" {
+			continue
+		}
+		if nonEmptyPairP(Cadr(sexpr)) {
+			names = append(names, Car(Cadr(sexpr)))
+		} else {
+			names = append(names, Cadr(sexpr))
+		}
+	}
+	return
+}
+
+func checkArityOfEach(sexprs *Data, env *SymbolTableFrame, shadowed map[string]bool, problems *[]*LispError) {
+	for c := sexprs; nonEmptyPairP(c); c = Cdr(c) {
+		checkArity(Car(c), env, shadowed, problems)
+	}
+}
+
+func checkArityOfBody(body *Data, env *SymbolTableFrame, shadowed map[string]bool, problems *[]*LispError) {
+	checkArityOfEach(body, env, shadowedBy(shadowed, definedNames(body, env, shadowed)...), problems)
+}
+
+func checkArityOfSpecialForm(form string, sexpr *Data, env *SymbolTableFrame, shadowed map[string]bool, problems *[]*LispError) {
+	args := Cdr(sexpr)
+
+	switch form {
+	case "if", "when", "unless", "begin", "and", "or":
+		checkArityOfEach(args, env, shadowed, problems)
+	case "set!":
+		checkArity(Cadr(args), env, shadowed, problems)
+	case "cond", "case":
+		clauses := args
+		if form == "case" {
+			checkArity(Car(args), env, shadowed, problems)
+			clauses = Cdr(args)
+		}
+		for c := clauses; nonEmptyPairP(c); c = Cdr(c) {
+			clause := Car(c)
+			if !nonEmptyPairP(clause) {
+				continue
+			}
+			if form == "cond" {
+				checkArity(Car(clause), env, shadowed, problems)
+			}
+			checkArityOfEach(Cdr(clause), env, shadowed, problems)
+		}
+	case "lambda", "named-lambda":
+		checkArityOfBody(Cdr(args), env, shadowedBy(shadowed, Car(args)), problems)
+	case "define":
+		target := Car(args)
+		if nonEmptyPairP(target) {
+			checkArityOfBody(Cdr(args), env, shadowedBy(shadowed, target), problems)
+		} else {
+			checkArity(Cadr(args), env, shadowed, problems)
+		}
+	case "let", "let*", "letrec":
+		name := []*Data{}
+		if SymbolP(Car(args)) {
+			name = append(name, Car(args))
+			args = Cdr(args)
+		}
+		bindings := Car(args)
+		inner := shadowedBy(shadowed, append(letBoundNames(bindings), name...)...)
+		for c := bindings; nonEmptyPairP(c); c = Cdr(c) {
+			if nonEmptyPairP(Car(c)) {
+				checkArity(Cadar(c), env, inner, problems)
+			}
+		}
+		checkArityOfBody(Cdr(args), env, inner, problems)
+	}
+}
+
+func checkArity(sexpr *Data, env *SymbolTableFrame, shadowed map[string]bool, problems *[]*LispError) {
+	if !nonEmptyPairP(sexpr) {
+		return
+	}
+
+	head := Car(sexpr)
+	argCount := Length(Cdr(sexpr))
+	if form := specialFormNamed(head, env, shadowed); form != "" {
+		if err := PrimitiveValue(globalValueOf(head, env, shadowed)).arityError(argCount); err != nil {
+			noteArityProblem(err, sexpr, problems)
+			return
+		}
+		checkArityOfSpecialForm(form, sexpr, env, shadowed, problems)
+		return
+	}
+
+	if nonEmptyPairP(head) {
+		checkArity(head, env, shadowed, problems)
+		checkArityOfEach(Cdr(sexpr), env, shadowed, problems)
+		return
+	}
+
+	// the arguments of macros and of names that aren't defined yet may not be expressions
+	function := globalValueOf(head, env, shadowed)
+	switch {
+	case PrimitiveP(function):
+		noteArityProblem(PrimitiveValue(function).arityError(argCount), sexpr, problems)
+	case FunctionP(function):
+		noteArityProblem(FunctionValue(function).arityError(argCount), sexpr, problems)
+	default:
+		return
+	}
+	checkArityOfEach(Cdr(sexpr), env, shadowed, problems)
+}
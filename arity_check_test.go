@@ -0,0 +1,88 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests checking calls for the wrong number of arguments as files are loaded.
+
+package golisp
+
+import (
+	"bytes"
+	. "gopkg.in/check.v1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+type ArityCheckSuite struct {
+	warnings *bytes.Buffer
+}
+
+var _ = Suite(&ArityCheckSuite{})
+
+func (s *ArityCheckSuite) SetUpTest(c *C) {
+	s.warnings = new(bytes.Buffer)
+	SetErrorWriter(s.warnings)
+}
+
+func (s *ArityCheckSuite) TearDownTest(c *C) {
+	SetErrorWriter(os.Stderr)
+	Config.StrictArityChecking = false
+}
+
+func (s *ArityCheckSuite) problemsIn(c *C, src string) []*LispError {
+	sexpr, err := Parse(src)
+	c.Assert(err, IsNil)
+	return CheckArity(sexpr, Global)
+}
+
+func (s *ArityCheckSuite) TestFindsBadCallsToPrimitivesAndFunctions(c *C) {
+	_, err := ParseAndEval("(define (arity-check-two a b) (+ a b))")
+	c.Assert(err, IsNil)
+
+	problems := s.problemsIn(c, "(if (car '(1) '(2)) (arity-check-two 1) (arity-check-two 1 2))")
+	c.Assert(problems, HasLen, 2)
+	c.Assert(String(problems[0].Form), Equals, "(car '(1) '(2))")
+	c.Assert(problems[0].Category, Equals, ArityError)
+	c.Assert(String(problems[1].Form), Equals, "(arity-check-two 1)")
+}
+
+func (s *ArityCheckSuite) TestChecksSpecialForms(c *C) {
+	c.Assert(s.problemsIn(c, "(if)"), HasLen, 1)
+	c.Assert(s.problemsIn(c, "(let ((x (cdr))) (cons x))"), HasLen, 2)
+	c.Assert(s.problemsIn(c, "(cond ((car) 1) (else (cdr 1 2)))"), HasLen, 2)
+}
+
+func (s *ArityCheckSuite) TestIgnoresLocallyRebound(c *C) {
+	c.Assert(s.problemsIn(c, "(lambda (car) (car 1 2))"), HasLen, 0)
+	c.Assert(s.problemsIn(c, "(let ((car list)) (car 1 2))"), HasLen, 0)
+	c.Assert(s.problemsIn(c, "(define (f) (define (car a b) a) (car 1 2))"), HasLen, 0)
+}
+
+func (s *ArityCheckSuite) TestIgnoresQuotedDataAndMacros(c *C) {
+	c.Assert(s.problemsIn(c, "'(car 1 2)"), HasLen, 0)
+	c.Assert(s.problemsIn(c, "(assert-error (car 1 2))"), HasLen, 0)
+	c.Assert(s.problemsIn(c, "(arity-check-undefined (car 1 2))"), HasLen, 0)
+}
+
+func (s *ArityCheckSuite) loadFile(c *C, src string) (*Data, error) {
+	filename := filepath.Join(c.MkDir(), "arity.lsp")
+	c.Assert(ioutil.WriteFile(filename, []byte(src), 0644), IsNil)
+	return ProcessFile(filename)
+}
+
+func (s *ArityCheckSuite) TestLoadingWarns(c *C) {
+	result, err := s.loadFile(c, "(define (arity-check-bad) (car 1 2))\n42")
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(42))
+	c.Assert(s.warnings.String(), Matches, "(?s)Warning: Wrong number of args to car.*Called as \\(car 1 2\\) at .*arity.lsp:1\n")
+}
+
+func (s *ArityCheckSuite) TestLoadingStrictlyFails(c *C) {
+	Config.StrictArityChecking = true
+	_, err := s.loadFile(c, "(define arity-check-loaded 1)\n(define (arity-check-bad) (car 1 2))\n(define arity-check-loaded 2)")
+	c.Assert(err, NotNil)
+	c.Assert(err, ErrorMatches, "(?s).*arity.lsp:2")
+	c.Assert(IntegerValue(Global.ValueOf(Intern("arity-check-loaded"))), Equals, int64(1))
+}
@@ -0,0 +1,154 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements arbitrary precision integers.
+
+package golisp
+
+import (
+	"math"
+	"math/big"
+	"unsafe"
+)
+
+// Integers that don't fit in an int64 are boxed big.Ints.  Arithmetic on integers promotes
+// to them when it overflows, and results that fit in an int64 again are demoted, so a
+// BigInteger is never equal to an Integer.
+
+func registerBigIntegerEquality() {
+	RegisterObjectEquality("BigInteger",
+		func(a unsafe.Pointer, b unsafe.Pointer) bool {
+			return (*big.Int)(a).Cmp((*big.Int)(b)) == 0
+		},
+		func(o unsafe.Pointer) uint64 {
+			return hashBigInteger((*big.Int)(o))
+		})
+}
+
+// hashBigInteger hashes n, which is also how integral floats out of the int64 range hash
+func hashBigInteger(n *big.Int) uint64 {
+	return hashBytes('B', n.Bytes())
+}
+
+// BigIntegerWithValue makes an integer from n, which is a plain Integer if it fits in an int64
+func BigIntegerWithValue(n *big.Int) *Data {
+	if n.IsInt64() {
+		return IntegerWithValue(n.Int64())
+	}
+	return ObjectWithTypeAndValue("BigInteger", unsafe.Pointer(n))
+}
+
+func BigIntegerP(d *Data) bool {
+	return ObjectP(d) && ObjectType(d) == "BigInteger"
+}
+
+func BigIntegerValue(d *Data) *big.Int {
+	if !BigIntegerP(d) {
+		return nil
+	}
+	return (*big.Int)(ObjectValue(d))
+}
+
+// ExactIntegerP is true of both Integers and BigIntegers
+func ExactIntegerP(d *Data) bool {
+	return IntegerP(d) || BigIntegerP(d)
+}
+
+// bigIntegerOf widens an Integer or BigInteger to a big.Int that is safe to modify
+func bigIntegerOf(d *Data) *big.Int {
+	if BigIntegerP(d) {
+		return new(big.Int).Set(BigIntegerValue(d))
+	}
+	return big.NewInt(IntegerValue(d))
+}
+
+// bigFloatOf widens a number to a big.Float without rounding, so integers and floats can be
+// compared exactly.  d mustn't be NaN.
+func bigFloatOf(d *Data) *big.Float {
+	if FloatP(d) {
+		return new(big.Float).SetFloat64(float64(FloatValue(d)))
+	}
+	return new(big.Float).SetInt(bigIntegerOf(d))
+}
+
+// bigIntegerFloat is the nearest float64 to n, or an infinity if it's out of range
+func bigIntegerFloat(n *big.Int) float64 {
+	f, _ := new(big.Float).SetInt(n).Float64()
+	return f
+}
+
+// bigIntegerInt64 clamps the BigInteger n, which never fits in an int64, to the int64 range
+// for callers that can only handle an int64
+func bigIntegerInt64(n *big.Int) int64 {
+	if n.Sign() < 0 {
+		return math.MinInt64
+	}
+	return math.MaxInt64
+}
+
+// parseBigInteger reads an integer literal in the given base that is too large for an int64
+func parseBigInteger(str string, base int) (n *Data, ok bool) {
+	i, ok := new(big.Int).SetString(str, base)
+	if !ok {
+		return
+	}
+	return BigIntegerWithValue(i), true
+}
+
+// addInt64 adds a and b, with ok false when the sum overflows
+func addInt64(a int64, b int64) (sum int64, ok bool) {
+	sum = a + b
+	return sum, (sum > a) == (b > 0)
+}
+
+// subtractInt64 subtracts b from a, with ok false when the difference overflows
+func subtractInt64(a int64, b int64) (difference int64, ok bool) {
+	difference = a - b
+	return difference, (difference < a) == (b > 0)
+}
+
+// multiplyInt64 multiplies a and b, with ok false when the product overflows
+func multiplyInt64(a int64, b int64) (product int64, ok bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	product = a * b
+	if product/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return product, false
+	}
+	return product, true
+}
+
+// Unsigned 64 bit values, such as device serial numbers and masks, are exact integers too:
+// those above math.MaxInt64 are BigIntegers, so they print and compare correctly instead of
+// wrapping around to negative numbers.
+
+// Uint64WithValue makes an exact integer from u
+func Uint64WithValue(u uint64) *Data {
+	if u <= math.MaxInt64 {
+		return IntegerWithValue(int64(u))
+	}
+	return ObjectWithTypeAndValue("BigInteger", unsafe.Pointer(new(big.Int).SetUint64(u)))
+}
+
+// Uint64P is true of exact integers in the range of a uint64
+func Uint64P(d *Data) bool {
+	if IntegerP(d) {
+		return IntegerValue(d) >= 0
+	}
+	return BigIntegerP(d) && BigIntegerValue(d).IsUint64()
+}
+
+// Uint64Value is the value of d as a uint64.  Negative Integers are taken as their two's
+// complement bits.
+func Uint64Value(d *Data) uint64 {
+	if BigIntegerP(d) {
+		if n := BigIntegerValue(d); n.IsUint64() {
+			return n.Uint64()
+		}
+		return 0
+	}
+	return uint64(IntegerValue(d))
+}
@@ -9,16 +9,52 @@ package golisp
 
 import (
 	"fmt"
+	"sync"
 )
 
+// Binding associates a symbol with a value.  Bindings can be shared by goroutines, so use
+// Value and SetValue rather than accessing Val directly.
 type Binding struct {
 	Sym       *Data
 	Val       *Data
 	Protected bool
+	Mutex     sync.RWMutex
 }
 
 func (self *Binding) Dump() {
-	fmt.Printf("   %s => %s\n", StringValue(self.Sym), String(self.Val))
+	fmt.Fprintf(OutputWriter(), "   %s => %s\n", StringValue(self.Sym), String(self.Value()))
+}
+
+func (self *Binding) Value() *Data {
+	self.Mutex.RLock()
+	defer self.Mutex.RUnlock()
+	return self.Val
+}
+
+func (self *Binding) SetValue(value *Data) {
+	self.Mutex.Lock()
+	self.Val = value
+	self.Mutex.Unlock()
+}
+
+// Assign sets the value unless the binding is protected
+func (self *Binding) Assign(value *Data) error {
+	self.Mutex.Lock()
+	if self.Protected {
+		self.Mutex.Unlock()
+		return fmt.Errorf("%s is a protected binding", StringValue(self.Sym))
+	}
+	self.Val = value
+	self.Mutex.Unlock()
+	noteBindingChange(self.Sym, value)
+	return nil
+}
+
+func (self *Binding) protect(value *Data) {
+	self.Mutex.Lock()
+	self.Val = value
+	self.Protected = true
+	self.Mutex.Unlock()
 }
 
 func BindingWithSymbolAndValue(sym *Data, val *Data) *Binding {
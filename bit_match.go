@@ -0,0 +1,259 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements bit-match, which destructures a bytearray like Erlang's bit syntax.
+
+package golisp
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+// (bit-match bytes (segment...) body...) splits bytes into fields of any number of bits,
+// binds them, and evaluates body.  Each segment is (target size modifier...):
+//
+//   target    a symbol to bind the field to, _ to skip it, or an integer the field must equal
+//   size      the field's width in bits; an expression that can use earlier fields
+//   modifiers big (the default) or little endian, unsigned (the default) or signed, and
+//             integer (the default) or binary, which makes the field a bytearray
+//
+// The last segment can be (target binary), which takes whatever bytes are left.  If the
+// fields don't add up to exactly the length of bytes, or a literal field doesn't match,
+// bit-match is #f and body isn't evaluated.
+//
+// For example (bit-match packet ((0x7e 8) (len 16) (payload (* len 8) binary)) payload)
+// checks the start marker and takes the payload from a packet with a 16 bit length.
+
+type bitSegment struct {
+	target    *Data
+	fixedSize int
+	size      *Data
+	rest      bool
+	little    bool
+	signed    bool
+	binary    bool
+}
+
+// Patterns are parsed once, the first time their bit-match is evaluated.  Patterns made at
+// runtime would fill the cache forever, so it is emptied when it reaches maxBitPatterns.
+const maxBitPatterns = 1024
+
+var bitPatterns = struct {
+	sync.RWMutex
+	compiled map[*Data][]*bitSegment
+}{compiled: make(map[*Data][]*bitSegment)}
+
+func compileBitSegment(spec *Data, last bool, env *SymbolTableFrame) (segment *bitSegment, err error) {
+	if !ListP(spec) || Length(spec) < 2 {
+		err = ProcessError(fmt.Sprintf("bit-match expected a (target size modifier...) segment, received %s", String(spec)), env)
+		return
+	}
+
+	segment = &bitSegment{target: Car(spec), fixedSize: -1}
+	if !SymbolP(segment.target) && !ExactIntegerP(segment.target) {
+		err = ProcessError(fmt.Sprintf("bit-match expected a symbol or integer to match a segment against, received %s", String(segment.target)), env)
+		return
+	}
+
+	size := Cadr(spec)
+	modifiers := Cddr(spec)
+	if SymbolP(size) && StringValue(size) == "binary" && NilP(modifiers) {
+		if !last {
+			err = ProcessError(fmt.Sprintf("bit-match segment %s takes the rest of the bytes so it has to be the last one", String(spec)), env)
+			return
+		}
+		segment.rest = true
+		segment.binary = true
+	} else if IntegerP(size) {
+		segment.fixedSize = int(IntegerValue(size))
+	} else {
+		segment.size = size
+	}
+
+	for c := modifiers; NotNilP(c); c = Cdr(c) {
+		modifier := StringValue(Car(c))
+		switch {
+		case !SymbolP(Car(c)):
+			err = ProcessError(fmt.Sprintf("bit-match expected a symbol as a modifier, received %s", String(Car(c))), env)
+			return
+		case modifier == "big":
+			segment.little = false
+		case modifier == "little":
+			segment.little = true
+		case modifier == "signed":
+			segment.signed = true
+		case modifier == "unsigned":
+			segment.signed = false
+		case modifier == "binary":
+			segment.binary = true
+		case modifier == "integer":
+			segment.binary = false
+		default:
+			err = ProcessError(fmt.Sprintf("bit-match doesn't know the modifier %s", modifier), env)
+			return
+		}
+	}
+
+	if segment.binary && ExactIntegerP(segment.target) {
+		err = ProcessError(fmt.Sprintf("bit-match can only match integer segments against a literal, not %s", String(spec)), env)
+		return
+	}
+	return
+}
+
+func compileBitPattern(pattern *Data, env *SymbolTableFrame) (segments []*bitSegment, err error) {
+	bitPatterns.RLock()
+	segments, found := bitPatterns.compiled[pattern]
+	bitPatterns.RUnlock()
+	if found {
+		return
+	}
+
+	if !ListP(pattern) {
+		err = ProcessError(fmt.Sprintf("bit-match expected a list of segments, received %s", String(pattern)), env)
+		return
+	}
+	for c := pattern; NotNilP(c); c = Cdr(c) {
+		var segment *bitSegment
+		if segment, err = compileBitSegment(Car(c), NilP(Cdr(c)), env); err != nil {
+			return nil, err
+		}
+		segments = append(segments, segment)
+	}
+
+	bitPatterns.Lock()
+	if len(bitPatterns.compiled) >= maxBitPatterns {
+		bitPatterns.compiled = make(map[*Data][]*bitSegment)
+	}
+	bitPatterns.compiled[pattern] = segments
+	bitPatterns.Unlock()
+	return
+}
+
+// extractBits reads size bits, most significant first, starting offset bits into bytes
+func extractBits(bytes []byte, offset int, size int) (bits uint64) {
+	for size > 0 {
+		bit := offset % 8
+		take := 8 - bit
+		if take > size {
+			take = size
+		}
+		chunk := uint64(bytes[offset/8]>>uint(8-bit-take)) & (1<<uint(take) - 1)
+		bits = bits<<uint(take) | chunk
+		offset += take
+		size -= take
+	}
+	return
+}
+
+// value extracts the segment, which is size bits at offset into bytes
+func (self *bitSegment) value(bytes []byte, offset int, size int, env *SymbolTableFrame) (value *Data, err error) {
+	if self.binary {
+		if size%8 != 0 {
+			err = ProcessError(fmt.Sprintf("bit-match binary segment %s has to be a whole number of bytes, not %d bits", String(self.target), size), env)
+			return
+		}
+		field := make([]byte, size/8)
+		for i := range field {
+			field[i] = byte(extractBits(bytes, offset+i*8, 8))
+		}
+		return ObjectWithTypeAndValue("[]byte", unsafe.Pointer(&field)), nil
+	}
+
+	if size > 64 {
+		err = ProcessError(fmt.Sprintf("bit-match integer segment %s can be at most 64 bits, not %d", String(self.target), size), env)
+		return
+	}
+	var bits uint64
+	if self.little {
+		if size%8 != 0 {
+			err = ProcessError(fmt.Sprintf("bit-match little endian segment %s has to be a whole number of bytes, not %d bits", String(self.target), size), env)
+			return
+		}
+		for i := 0; i < size/8; i++ {
+			bits |= extractBits(bytes, offset+i*8, 8) << uint(i*8)
+		}
+	} else {
+		bits = extractBits(bytes, offset, size)
+	}
+
+	if self.signed {
+		if size > 0 && size < 64 && bits&(1<<uint(size-1)) != 0 {
+			bits |= ^uint64(0) << uint(size)
+		}
+		return IntegerWithValue(int64(bits)), nil
+	}
+	return Uint64WithValue(bits), nil
+}
+
+// BitMatchImpl handles (bit-match bytes (segment...) body...)
+func BitMatchImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	segments, err := compileBitPattern(Cadr(args), env)
+	if err != nil {
+		return
+	}
+
+	bytesObj, err := Eval(Car(args), env)
+	if err != nil {
+		return
+	}
+	bytes, ok := byteSlice(bytesObj)
+	if !ok {
+		err = ProcessTypeError(fmt.Sprintf("bit-match expected a bytearray or bytevector, received %s", String(bytesObj)), env)
+		return
+	}
+	available := len(bytes) * 8
+
+	localEnv := NewSymbolTableFrameBelow(env, "bit-match")
+	if err = localEnv.callFrom(env); err != nil {
+		return
+	}
+
+	offset := 0
+	for _, segment := range segments {
+		size := segment.fixedSize
+		switch {
+		case segment.rest:
+			size = available - offset
+		case segment.size != nil:
+			var sizeObj *Data
+			if sizeObj, err = Eval(segment.size, localEnv); err != nil {
+				return
+			}
+			if !IntegerP(sizeObj) {
+				err = ProcessTypeError(fmt.Sprintf("bit-match expected an integer size for segment %s, received %s", String(segment.target), String(sizeObj)), env)
+				return
+			}
+			size = int(IntegerValue(sizeObj))
+		}
+		if size < 0 || size > available-offset {
+			return LispFalse, nil
+		}
+
+		var value *Data
+		if value, err = segment.value(bytes, offset, size, env); err != nil {
+			return
+		}
+		offset += size
+
+		switch {
+		case ExactIntegerP(segment.target):
+			if !IsEqual(value, segment.target) {
+				return LispFalse, nil
+			}
+		case StringValue(segment.target) != "_":
+			if _, err = localEnv.BindLocallyTo(segment.target, value); err != nil {
+				return
+			}
+		}
+	}
+
+	if offset != available {
+		return LispFalse, nil
+	}
+	return evaluateBody(Cddr(args), localEnv)
+}
@@ -0,0 +1,62 @@
+// Copyright 2014 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements the character data type.
+
+package golisp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var characterNames = map[string]rune{
+	"alarm":     '\a',
+	"backspace": '\b',
+	"delete":    0x7f,
+	"escape":    0x1b,
+	"newline":   '\n',
+	"null":      0,
+	"return":    '\r',
+	"space":     ' ',
+	"tab":       '\t',
+}
+
+// CharacterName returns the text that follows #\ when writing ch
+func CharacterName(ch rune) string {
+	for name, c := range characterNames {
+		if c == ch {
+			return name
+		}
+	}
+	if unicode.IsGraphic(ch) && !unicode.IsSpace(ch) {
+		return string(ch)
+	}
+	return fmt.Sprintf("x%x", ch)
+}
+
+// CharacterFromName converts the text that follows #\ into a character
+func CharacterFromName(name string) (ch rune, err error) {
+	runes := []rune(name)
+	if len(runes) == 1 {
+		return runes[0], nil
+	}
+
+	if c, found := characterNames[strings.ToLower(name)]; found {
+		return c, nil
+	}
+
+	if runes[0] == 'x' || runes[0] == 'U' {
+		n, parseErr := strconv.ParseInt(strings.TrimPrefix(string(runes[1:]), "+"), 16, 32)
+		if parseErr == nil {
+			return rune(n), nil
+		}
+	}
+
+	err = fmt.Errorf("Unknown character name: #\\%s", name)
+	return
+}
@@ -0,0 +1,213 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements the clock that time, timer, and scheduler primitives consult.
+
+package golisp
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock is where the interpreter gets the time and its timers from.  It is the real clock
+// unless a host (or deterministic mode) sets another one with SetClock, e.g. a FakeClock
+// that tests move forward to run scheduled jobs without waiting for them.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) ClockTimer
+	Sleep(d time.Duration)
+}
+
+// ClockTimer is a timer made by a Clock, which behaves like a time.Timer
+type ClockTimer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+type realClock struct{}
+
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (self realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (self realClock) NewTimer(d time.Duration) ClockTimer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (self realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+func (self realTimer) C() <-chan time.Time {
+	return self.timer.C
+}
+
+func (self realTimer) Stop() bool {
+	return self.timer.Stop()
+}
+
+func (self realTimer) Reset(d time.Duration) bool {
+	return self.timer.Reset(d)
+}
+
+// RealClock is the clock that tells the actual time
+var RealClock Clock = realClock{}
+
+type clockHolder struct {
+	clock Clock
+}
+
+var currentClock atomic.Value
+
+// SetClock makes the interpreter use clock, or the real clock if clock is nil.  Timers that
+// are already running stay on the clock that made them.
+func SetClock(clock Clock) {
+	currentClock.Store(clockHolder{clock})
+}
+
+// CurrentClock returns the clock the interpreter is using
+func CurrentClock() Clock {
+	if holder, _ := currentClock.Load().(clockHolder); holder.clock != nil {
+		return holder.clock
+	}
+	return RealClock
+}
+
+// CurrentTime is the time as seen by lisp code
+func CurrentTime() time.Time {
+	return CurrentClock().Now()
+}
+
+// FakeClock is a Clock whose time only moves when Advance is called, which fires the timers
+// that come due, in order
+type FakeClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+	added  *sync.Cond
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	deadline time.Time
+	c        chan time.Time
+	active   bool
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	clock := &FakeClock{now: now}
+	clock.added = sync.NewCond(&clock.mutex)
+	return clock
+}
+
+func (self *FakeClock) Now() time.Time {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.now
+}
+
+func (self *FakeClock) NewTimer(d time.Duration) ClockTimer {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	timer := &fakeTimer{clock: self, c: make(chan time.Time, 1)}
+	self.start(timer, d)
+	return timer
+}
+
+// Sleep blocks until the clock has been advanced by d
+func (self *FakeClock) Sleep(d time.Duration) {
+	<-self.NewTimer(d).C()
+}
+
+// start schedules timer to fire d from now.  The caller has to hold the clock's lock.
+func (self *FakeClock) start(timer *fakeTimer, d time.Duration) {
+	timer.deadline = self.now.Add(d)
+	timer.active = true
+	if d <= 0 {
+		self.fire(timer)
+		return
+	}
+	self.timers = append(self.timers, timer)
+	self.added.Broadcast()
+}
+
+// fire sends the current time on timer's channel, like a time.Timer.  The caller has to hold
+// the clock's lock.
+func (self *FakeClock) fire(timer *fakeTimer) {
+	timer.active = false
+	select {
+	case timer.c <- self.now:
+	default:
+	}
+}
+
+func (self *FakeClock) remove(timer *fakeTimer) bool {
+	for i, t := range self.timers {
+		if t == timer {
+			self.timers = append(self.timers[:i], self.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Advance moves the clock forward by d, firing the timers that come due along the way in
+// deadline order, with the clock showing each one's deadline as it fires
+func (self *FakeClock) Advance(d time.Duration) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	end := self.now.Add(d)
+	for {
+		sort.SliceStable(self.timers, func(i, j int) bool { return self.timers[i].deadline.Before(self.timers[j].deadline) })
+		if len(self.timers) == 0 || self.timers[0].deadline.After(end) {
+			break
+		}
+		timer := self.timers[0]
+		self.timers = self.timers[1:]
+		self.now = timer.deadline
+		self.fire(timer)
+	}
+	self.now = end
+}
+
+// WaitForTimers blocks until at least n timers are waiting, e.g. so a test knows a scheduled
+// job is waiting for its next run before advancing the clock
+func (self *FakeClock) WaitForTimers(n int) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	for len(self.timers) < n {
+		self.added.Wait()
+	}
+}
+
+func (self *fakeTimer) C() <-chan time.Time {
+	return self.c
+}
+
+func (self *fakeTimer) Stop() bool {
+	self.clock.mutex.Lock()
+	defer self.clock.mutex.Unlock()
+	wasActive := self.active
+	self.active = false
+	self.clock.remove(self)
+	return wasActive
+}
+
+func (self *fakeTimer) Reset(d time.Duration) bool {
+	self.clock.mutex.Lock()
+	defer self.clock.mutex.Unlock()
+	wasActive := self.active
+	self.clock.remove(self)
+	self.clock.start(self, d)
+	return wasActive
+}
@@ -0,0 +1,95 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests the interpreter's clock.
+
+package golisp
+
+import (
+	. "gopkg.in/check.v1"
+	"time"
+)
+
+type ClockSuite struct {
+	clock *FakeClock
+}
+
+var _ = Suite(&ClockSuite{})
+
+func (s *ClockSuite) SetUpTest(c *C) {
+	s.clock = NewFakeClock(time.Date(2015, time.June, 1, 12, 0, 0, 0, time.UTC))
+	SetClock(s.clock)
+}
+
+func (s *ClockSuite) TearDownTest(c *C) {
+	SetClock(nil)
+}
+
+func (s *ClockSuite) TestFakeTimersFireInOrder(c *C) {
+	late := s.clock.NewTimer(2 * time.Second)
+	early := s.clock.NewTimer(time.Second)
+	stopped := s.clock.NewTimer(time.Second)
+	c.Assert(stopped.Stop(), Equals, true)
+
+	s.clock.Advance(500 * time.Millisecond)
+	select {
+	case <-early.C():
+		c.Fatal("the timer fired early")
+	default:
+	}
+
+	s.clock.Advance(2 * time.Second)
+	c.Assert((<-early.C()).Equal(time.Date(2015, time.June, 1, 12, 0, 1, 0, time.UTC)), Equals, true)
+	c.Assert((<-late.C()).Equal(time.Date(2015, time.June, 1, 12, 0, 2, 0, time.UTC)), Equals, true)
+	c.Assert(s.clock.Now().Equal(time.Date(2015, time.June, 1, 12, 0, 2, 500000000, time.UTC)), Equals, true)
+	select {
+	case <-stopped.C():
+		c.Fatal("a stopped timer fired")
+	default:
+	}
+}
+
+func (s *ClockSuite) TestTimePrimitivesUseTheClock(c *C) {
+	result, err := ParseAndEval(`(time->milliseconds (now))`)
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, s.clock.Now().UnixNano()/1e6)
+}
+
+func (s *ClockSuite) TestFastForwardingScheduledJobs(c *C) {
+	_, err := ParseAndEvalAll(`(define clock-test-runs (atomic))
+(define clock-test-job (schedule every: "1m" (lambda (job) (atomic-add! clock-test-runs 1))))`)
+	c.Assert(err, IsNil)
+	defer ParseAndEval(`(cancel-job! clock-test-job)`)
+
+	for i := 0; i < 3; i++ {
+		s.clock.WaitForTimers(1)
+		s.clock.Advance(time.Minute)
+	}
+	s.clock.WaitForTimers(1)
+	result, err := ParseAndEval(`(atomic-load clock-test-runs)`)
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(3))
+}
+
+func (s *ClockSuite) TestLockingScheduledJobs(c *C) {
+	_, err := ParseAndEvalAll(`(define clock-lock-runs (atomic))
+(define clock-lock-job (schedule every: "1m" (lambda (job) (atomic-add! clock-lock-runs 1))))`)
+	c.Assert(err, IsNil)
+	defer ParseAndEval(`(cancel-job! clock-lock-job)`)
+
+	LockScheduledJobs()
+	s.clock.WaitForTimers(1)
+	s.clock.Advance(time.Minute)
+	time.Sleep(20 * time.Millisecond)
+	result, err := ParseAndEval(`(atomic-load clock-lock-runs)`)
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(0))
+
+	UnlockScheduledJobs()
+	s.clock.WaitForTimers(1)
+	result, err = ParseAndEval(`(atomic-load clock-lock-runs)`)
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(1))
+}
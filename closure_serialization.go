@@ -0,0 +1,359 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements serializing closures as text, so jobs can be saved and run later.
+
+package golisp
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A closure is serialized as the code that would make it again:
+//
+//   (let ((count 3) (hosts '("a" "b")))
+//     (named-lambda (job attempt) ...))
+//
+// The let binds the closure's free variables that come from local scopes, like the let or
+// function call it was made in.  Globals aren't captured; they're looked up in the
+// environment the closure is deserialized into, when it runs.  Captured values have to be
+// data that can be written out: numbers, strings, characters, booleans, symbols, bytearrays,
+// and lists, vectors, frames and closures of them.
+//
+// Deserializing doesn't evaluate the text.  It only accepts the shape above, with values
+// that are literals or built with quote, list, cons, vector, make-frame, or a nested let and
+// lambda, so a saved job can't run anything until it is called.
+
+func RegisterClosureSerializationPrimitives() {
+	MakePrimitiveFunction("serialize-closure", "1", SerializeClosureImpl)
+	MakePrimitiveFunction("deserialize-closure", "1|2", DeserializeClosureImpl)
+}
+
+type closureSerializer struct {
+	inProgress map[*Function]bool
+}
+
+// freeSymbols adds the symbols code uses that aren't bound by params, skipping quoted data
+func freeSymbols(code *Data, bound map[string]bool, free map[string]*Data) {
+	switch {
+	case SymbolP(code):
+		if !NakedP(code) && !bound[StringValue(code)] {
+			free[StringValue(code)] = code
+		}
+	case PairP(code):
+		if SymbolP(Car(code)) && StringValue(Car(code)) == "quote" {
+			return
+		}
+		for c := code; NotNilP(c); c = Cdr(c) {
+			if !PairP(c) {
+				freeSymbols(c, bound, free)
+				break
+			}
+			freeSymbols(Car(c), bound, free)
+		}
+	case VectorP(code):
+		for _, element := range VectorValue(code) {
+			freeSymbols(element, bound, free)
+		}
+	}
+}
+
+func paramNames(params *Data) map[string]bool {
+	names := make(map[string]bool)
+	for p := params; NotNilP(p); p = Cdr(p) {
+		if SymbolP(p) {
+			names[StringValue(p)] = true
+			break
+		}
+		names[StringValue(Car(p))] = true
+	}
+	return names
+}
+
+// capturedBinding finds symbol in the local scopes between env and the global environment
+func capturedBinding(symbol *Data, env *SymbolTableFrame) (value *Data, found bool) {
+	for e := env; e != nil && e != Global && e.Parent != nil; e = e.Parent {
+		if binding, present := e.BindingNamed(StringValue(symbol)); present {
+			return binding.Value(), true
+		}
+	}
+	return
+}
+
+// containsFrame is whether d has to be rebuilt rather than quoted
+func containsFrame(d *Data) bool {
+	switch {
+	case NilP(d):
+		return false
+	case FrameP(d), FunctionP(d):
+		return true
+	case PairP(d):
+		return containsFrame(Car(d)) || containsFrame(Cdr(d))
+	case VectorP(d):
+		for _, element := range VectorValue(d) {
+			if containsFrame(element) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// value is the expression that makes d again
+func (self *closureSerializer) value(name string, d *Data) (expr *Data, err error) {
+	switch {
+	case NilP(d), NumberP(d), StringP(d), BooleanP(d), CharacterP(d):
+		return d, nil
+	case ObjectP(d) && ObjectType(d) == "[]byte", BytevectorP(d):
+		return d, nil
+	case SymbolP(d):
+		return InternalMakeList(Intern("quote"), d), nil
+	case FunctionP(d):
+		return self.closure(FunctionValue(d))
+	case !containsFrame(d) && (PairP(d) || VectorP(d)):
+		if VectorP(d) {
+			return d, nil
+		}
+		return InternalMakeList(Intern("quote"), d), nil
+	case PairP(d):
+		var elements []*Data
+		c := d
+		for ; NotNilP(c) && PairP(c); c = Cdr(c) {
+			var element *Data
+			if element, err = self.value(name, Car(c)); err != nil {
+				return
+			}
+			elements = append(elements, element)
+		}
+		if NilP(c) {
+			return Cons(Intern("list"), ArrayToList(elements)), nil
+		}
+		if expr, err = self.value(name, c); err != nil {
+			return
+		}
+		for i := len(elements) - 1; i >= 0; i-- {
+			expr = InternalMakeList(Intern("cons"), elements[i], expr)
+		}
+		return
+	case VectorP(d):
+		elements := []*Data{Intern("vector")}
+		for _, element := range VectorValue(d) {
+			if element, err = self.value(name, element); err != nil {
+				return
+			}
+			elements = append(elements, element)
+		}
+		return ArrayToList(elements), nil
+	case FrameP(d):
+		frame := FrameValue(d)
+		elements := []*Data{Intern("make-frame")}
+		var slots []string
+		for _, key := range frame.Keys() {
+			slots = append(slots, StringValue(key))
+		}
+		sort.Strings(slots)
+		for _, slot := range slots {
+			var value *Data
+			if value, err = self.value(name, frame.Get(slot)); err != nil {
+				return
+			}
+			elements = append(elements, Intern(slot), value)
+		}
+		return ArrayToList(elements), nil
+	}
+	err = fmt.Errorf("can't capture %s, which is %s", name, String(d))
+	return
+}
+
+// closure is the let and lambda that make function again
+func (self *closureSerializer) closure(function *Function) (expr *Data, err error) {
+	if self.inProgress[function] {
+		return nil, fmt.Errorf("can't serialize %s, which refers to itself", function.Name)
+	}
+	self.inProgress[function] = true
+	defer delete(self.inProgress, function)
+
+	free := make(map[string]*Data)
+	freeSymbols(function.Body, paramNames(function.Params), free)
+	var bindings []*Data
+	for _, name := range sortedNames(free) {
+		captured, found := capturedBinding(free[name], function.Env)
+		if !found {
+			continue
+		}
+		var value *Data
+		if value, err = self.value(name, captured); err != nil {
+			return
+		}
+		bindings = append(bindings, InternalMakeList(free[name], value))
+	}
+
+	var lambda *Data
+	if function.Name == "unnamed" {
+		lambda = Cons(Intern("lambda"), Cons(function.Params, function.Body))
+	} else {
+		lambda = Cons(Intern("named-lambda"), Cons(Cons(Intern(function.Name), function.Params), function.Body))
+	}
+	return InternalMakeList(Intern("let"), ArrayToList(bindings), lambda), nil
+}
+
+func sortedNames(symbols map[string]*Data) []string {
+	names := make([]string, 0, len(symbols))
+	for name := range symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validParams is whether params is a lambda list: symbols, possibly with a rest symbol
+func validParams(params *Data) bool {
+	for p := params; NotNilP(p); p = Cdr(p) {
+		if SymbolP(p) {
+			return true
+		}
+		if !PairP(p) || !SymbolP(Car(p)) {
+			return false
+		}
+	}
+	return true
+}
+
+// SerializeClosure writes function out as text that DeserializeClosure can read back
+func SerializeClosure(function *Function) (text string, err error) {
+	expr, err := (&closureSerializer{inProgress: make(map[*Function]bool)}).closure(function)
+	if err != nil {
+		return
+	}
+	return String(expr), nil
+}
+
+// deserializedValue builds the value expr makes, without evaluating it
+func deserializedValue(expr *Data, env *SymbolTableFrame) (value *Data, err error) {
+	if SymbolP(expr) && !NakedP(expr) {
+		return nil, fmt.Errorf("unexpected variable %s", String(expr))
+	}
+	if NilP(expr) || !PairP(expr) {
+		return expr, nil
+	}
+
+	operator := StringValue(Car(expr))
+	if operator == "quote" && Length(expr) == 2 {
+		return Cadr(expr), nil
+	}
+	if operator == "let" {
+		return deserializedClosure(expr, env)
+	}
+
+	var args []*Data
+	for _, arg := range ToArray(Cdr(expr)) {
+		var element *Data
+		if element, err = deserializedValue(arg, env); err != nil {
+			return
+		}
+		args = append(args, element)
+	}
+	switch {
+	case operator == "list":
+		return ArrayToList(args), nil
+	case operator == "cons" && len(args) == 2:
+		return Cons(args[0], args[1]), nil
+	case operator == "vector":
+		return VectorWithValue(args), nil
+	case operator == "make-frame" && len(args)%2 == 0:
+		frame := &FrameMap{Data: make(FrameMapData, len(args)/2)}
+		for i := 0; i < len(args); i += 2 {
+			if !NakedP(args[i]) {
+				return nil, fmt.Errorf("expected a slot name but found %s", String(args[i]))
+			}
+			frame.Data[StringValue(args[i])] = args[i+1]
+		}
+		return FrameWithValue(frame), nil
+	}
+	return nil, fmt.Errorf("unexpected %s", String(expr))
+}
+
+// deserializedClosure makes the closure a (let bindings lambda) expression describes, below env
+func deserializedClosure(expr *Data, env *SymbolTableFrame) (closure *Data, err error) {
+	if !PairP(expr) || StringValue(Car(expr)) != "let" || Length(expr) != 3 || !ListP(Cadr(expr)) || !PairP(Caddr(expr)) {
+		return nil, fmt.Errorf("expected (let (bindings...) (lambda ...)) but found %s", String(expr))
+	}
+
+	closureEnv := NewSymbolTableFrameBelow(env, "deserialized closure")
+	for _, binding := range ToArray(Cadr(expr)) {
+		if !PairP(binding) || Length(binding) != 2 || !SymbolP(Car(binding)) {
+			return nil, fmt.Errorf("expected (name value) but found %s", String(binding))
+		}
+		var value *Data
+		if value, err = deserializedValue(Cadr(binding), env); err != nil {
+			return
+		}
+		if _, err = closureEnv.BindLocallyTo(Car(binding), value); err != nil {
+			return
+		}
+	}
+
+	lambda := Caddr(expr)
+	kind := StringValue(Car(lambda))
+	switch {
+	case kind == "lambda" && Length(lambda) >= 2 && validParams(Cadr(lambda)):
+		return FunctionWithNameParamsBodyAndParent("unnamed", Cadr(lambda), Cddr(lambda), closureEnv), nil
+	case kind == "named-lambda" && Length(lambda) >= 2 && PairP(Cadr(lambda)) && validParams(Cadr(lambda)):
+		return FunctionWithNameParamsBodyAndParent(StringValue(Caadr(lambda)), Cdadr(lambda), Cddr(lambda), closureEnv), nil
+	}
+	return nil, fmt.Errorf("expected a lambda but found %s", String(lambda))
+}
+
+// DeserializeClosure reads text written by SerializeClosure into a closure whose globals come
+// from env
+func DeserializeClosure(text string, env *SymbolTableFrame) (closure *Data, err error) {
+	expr, err := Parse(text)
+	if err != nil {
+		return
+	}
+	return deserializedClosure(expr, env)
+}
+
+// SerializeClosureImpl handles (serialize-closure f), which is f as a string
+func SerializeClosureImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if !FunctionP(Car(args)) {
+		err = ProcessTypeError(fmt.Sprintf("serialize-closure expects a function, but received %s.", String(Car(args))), env)
+		return
+	}
+	text, err := SerializeClosure(FunctionValue(Car(args)))
+	if err != nil {
+		err = ProcessError(fmt.Sprintf("serialize-closure %s.", err), env)
+		return
+	}
+	return StringWithValue(text), nil
+}
+
+// DeserializeClosureImpl handles (deserialize-closure text [environment]), where the closure's
+// globals come from environment, or the system global environment.  A closure deserialized
+// in a restricted environment is restricted too.
+func DeserializeClosureImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if !StringP(Car(args)) {
+		err = ProcessTypeError(fmt.Sprintf("deserialize-closure expects a string, but received %s.", String(Car(args))), env)
+		return
+	}
+	globals := Global
+	if NotNilP(Cdr(args)) {
+		if !EnvironmentP(Cadr(args)) {
+			err = ProcessTypeError(fmt.Sprintf("deserialize-closure expects an environment, but received %s.", String(Cadr(args))), env)
+			return
+		}
+		globals = EnvironmentValue(Cadr(args))
+	}
+	if env.IsRestricted && !globals.IsRestricted {
+		// the closure mustn't be able to do more than the code that made it
+		globals = NewSymbolTableFrameBelow(globals, "restricted deserialized closure")
+		globals.IsRestricted = true
+	}
+	if result, err = DeserializeClosure(StringValue(Car(args)), globals); err != nil {
+		err = ProcessError(fmt.Sprintf("deserialize-closure %s.", err), env)
+	}
+	return
+}
@@ -0,0 +1,44 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests closure serialization from restricted environments.
+
+package golisp
+
+import (
+	. "gopkg.in/check.v1"
+	"os"
+)
+
+type ClosureSerializationSuite struct {
+}
+
+var _ = Suite(&ClosureSerializationSuite{})
+
+func (s *ClosureSerializationSuite) TestDeserializingWhenRestricted(c *C) {
+	env := NewSymbolTableFrameBelow(Global, "restricted closure test")
+	env.IsRestricted = true
+	for _, code := range []string{
+		`((deserialize-closure "(let () (lambda () (exec \"echo\" \"x\")))"))`,
+		`((deserialize-closure "(let () (lambda () (load \"nothing.lsp\")))"))`,
+	} {
+		sexpr, err := Parse(code)
+		c.Assert(err, IsNil)
+		_, err = Eval(sexpr, env)
+		c.Assert(err, ErrorMatches, "(?s).*restricted.*", Commentf("%s", code))
+	}
+
+	os.Setenv("CLOSURE_TEST_SECRET", "secret")
+	defer os.Unsetenv("CLOSURE_TEST_SECRET")
+	sexpr, err := Parse(`((deserialize-closure "(let () (lambda () (expand-vars \"$CLOSURE_TEST_SECRET\")))"))`)
+	c.Assert(err, IsNil)
+	result, err := Eval(sexpr, env)
+	c.Assert(err, IsNil)
+	c.Assert(StringValue(result), Not(Equals), "secret")
+
+	result, err = ParseAndEval(`((deserialize-closure "(let ((x 1)) (lambda () (+ x 1)))"))`)
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(2))
+}
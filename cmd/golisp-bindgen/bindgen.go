@@ -0,0 +1,398 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file scans Go source for annotated functions and types and generates primitive bindings.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	primitiveAnnotation = "golisp:primitive"
+	objectAnnotation    = "golisp:object"
+)
+
+// goType describes how a parameter or result is converted between Go and lisp
+type goType struct {
+	Kind   string // integer, float, string, boolean, bytes, data, object or error
+	GoName string // the Go spelling of the type, e.g. int32 or *Widget
+	Object string // the object type name, for kind object
+}
+
+type binding struct {
+	LispName string
+	GoName   string
+	Params   []goType
+	Results  []goType
+}
+
+type scanResult struct {
+	Package  string
+	Bindings []binding
+	Objects  []string
+}
+
+var integerTypes = map[string]bool{
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true, "byte": true,
+}
+
+// annotation returns the text following tag in a //golisp:... comment, if there is one
+func annotation(doc *ast.CommentGroup, tag string) (value string, found bool) {
+	if doc == nil {
+		return "", false
+	}
+	for _, comment := range doc.List {
+		text := strings.TrimSpace(strings.TrimPrefix(comment.Text, "//"))
+		if text == tag || strings.HasPrefix(text, tag+" ") {
+			return strings.TrimSpace(strings.TrimPrefix(text, tag)), true
+		}
+	}
+	return "", false
+}
+
+// lispName turns a Go name such as ParseHTTPHeader into parse-http-header
+func lispName(goName string) string {
+	runes := []rune(goName)
+	var buf bytes.Buffer
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			previous := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextIsLower) {
+				buf.WriteRune('-')
+			}
+		}
+		buf.WriteRune(unicode.ToLower(r))
+	}
+	return buf.String()
+}
+
+func isGolispData(expr ast.Expr, pkg string) bool {
+	star, ok := expr.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	switch x := star.X.(type) {
+	case *ast.SelectorExpr:
+		ident, ok := x.X.(*ast.Ident)
+		return ok && ident.Name == "golisp" && x.Sel.Name == "Data"
+	case *ast.Ident:
+		return pkg == "golisp" && x.Name == "Data"
+	}
+	return false
+}
+
+func classify(expr ast.Expr, pkg string, objects map[string]bool) (t goType, err error) {
+	if isGolispData(expr, pkg) {
+		return goType{Kind: "data"}, nil
+	}
+
+	switch x := expr.(type) {
+	case *ast.Ident:
+		switch {
+		case integerTypes[x.Name]:
+			return goType{Kind: "integer", GoName: x.Name}, nil
+		case x.Name == "float32" || x.Name == "float64":
+			return goType{Kind: "float", GoName: x.Name}, nil
+		case x.Name == "string":
+			return goType{Kind: "string", GoName: x.Name}, nil
+		case x.Name == "bool":
+			return goType{Kind: "boolean", GoName: x.Name}, nil
+		case x.Name == "error":
+			return goType{Kind: "error", GoName: x.Name}, nil
+		}
+	case *ast.ArrayType:
+		if ident, ok := x.Elt.(*ast.Ident); ok && x.Len == nil && ident.Name == "byte" {
+			return goType{Kind: "bytes", GoName: "[]byte"}, nil
+		}
+	case *ast.StarExpr:
+		if ident, ok := x.X.(*ast.Ident); ok && objects[ident.Name] {
+			return goType{Kind: "object", GoName: "*" + ident.Name, Object: ident.Name}, nil
+		}
+	}
+
+	var buf bytes.Buffer
+	format.Node(&buf, token.NewFileSet(), expr)
+	return goType{}, errors.New(fmt.Sprintf("unsupported type %s", buf.String()))
+}
+
+func fieldTypes(fields *ast.FieldList, pkg string, objects map[string]bool) (types []goType, err error) {
+	if fields == nil {
+		return
+	}
+	for _, field := range fields.List {
+		if _, variadic := field.Type.(*ast.Ellipsis); variadic {
+			return nil, errors.New("variadic functions are not supported")
+		}
+		t, err := classify(field.Type, pkg, objects)
+		if err != nil {
+			return nil, err
+		}
+		count := len(field.Names)
+		if count == 0 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			types = append(types, t)
+		}
+	}
+	return
+}
+
+// scan collects the annotated functions and types of one package
+func scan(fset *token.FileSet, files []*ast.File) (result *scanResult, err error) {
+	result = &scanResult{}
+	objects := make(map[string]bool)
+
+	for _, file := range files {
+		result.Package = file.Name.Name
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				doc := typeSpec.Doc
+				if doc == nil && len(gen.Specs) == 1 {
+					doc = gen.Doc
+				}
+				if _, found := annotation(doc, objectAnnotation); found {
+					objects[typeSpec.Name.Name] = true
+					result.Objects = append(result.Objects, typeSpec.Name.Name)
+				}
+			}
+		}
+	}
+	sort.Strings(result.Objects)
+
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil {
+				continue
+			}
+			name, found := annotation(fn.Doc, primitiveAnnotation)
+			if !found {
+				continue
+			}
+			if name == "" {
+				name = lispName(fn.Name.Name)
+			}
+
+			b := binding{LispName: name, GoName: fn.Name.Name}
+			b.Params, err = fieldTypes(fn.Type.Params, result.Package, objects)
+			if err == nil {
+				b.Results, err = fieldTypes(fn.Type.Results, result.Package, objects)
+			}
+			if err == nil {
+				err = checkResults(b.Results)
+			}
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("%s: %s: %s", fset.Position(fn.Pos()), fn.Name.Name, err))
+			}
+			for _, p := range b.Params {
+				if p.Kind == "error" {
+					return nil, errors.New(fmt.Sprintf("%s: %s: error parameters are not supported", fset.Position(fn.Pos()), fn.Name.Name))
+				}
+			}
+			result.Bindings = append(result.Bindings, b)
+		}
+	}
+
+	sort.Sort(byLispName(result.Bindings))
+	return
+}
+
+func checkResults(results []goType) error {
+	for i, r := range results {
+		if r.Kind == "error" && i != len(results)-1 {
+			return errors.New("an error result must be the last one")
+		}
+	}
+	return nil
+}
+
+type byLispName []binding
+
+func (b byLispName) Len() int           { return len(b) }
+func (b byLispName) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byLispName) Less(i, j int) bool { return b[i].LispName < b[j].LispName }
+
+// generator writes the bindings, qualifying golisp names unless generating into golisp itself
+type generator struct {
+	buf bytes.Buffer
+	q   string
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+var kindDescriptions = map[string]string{
+	"integer": "an integer",
+	"float":   "a number",
+	"string":  "a string",
+	"bytes":   "a bytearray",
+}
+
+func (g *generator) convertParam(b binding, i int, p goType) {
+	arg := fmt.Sprintf("argv[%d]", i)
+	fail := func(check string, description string) {
+		g.printf("\tif !(%s) {\n", check)
+		g.printf("\t\terr = %sProcessError(fmt.Sprintf(\"%s expected %s as argument %d, but received %%s.\", %sString(%s)), env)\n", g.q, b.LispName, description, i+1, g.q, arg)
+		g.printf("\t\treturn\n\t}\n")
+	}
+
+	switch p.Kind {
+	case "integer":
+		fail(fmt.Sprintf("%sIntegerP(%s)", g.q, arg), kindDescriptions[p.Kind])
+		g.printf("\tp%d := %s(%sIntegerValue(%s))\n", i, p.GoName, g.q, arg)
+	case "float":
+		fail(fmt.Sprintf("%sNumberP(%s)", g.q, arg), kindDescriptions[p.Kind])
+		g.printf("\tp%d := %s(%sFloatValue(%s))\n", i, p.GoName, g.q, arg)
+	case "string":
+		fail(fmt.Sprintf("%sStringP(%s)", g.q, arg), kindDescriptions[p.Kind])
+		g.printf("\tp%d := %sStringValue(%s)\n", i, g.q, arg)
+	case "boolean":
+		g.printf("\tp%d := %sBooleanValue(%s)\n", i, g.q, arg)
+	case "bytes":
+		fail(fmt.Sprintf("%sObjectP(%s) && %sObjectType(%s) == \"[]byte\"", g.q, arg, g.q, arg), kindDescriptions[p.Kind])
+		g.printf("\tp%d := *(*[]byte)(%sObjectValue(%s))\n", i, g.q, arg)
+	case "data":
+		g.printf("\tp%d := %s\n", i, arg)
+	case "object":
+		fail(fmt.Sprintf("%sObjectP(%s) && %sObjectType(%s) == %q", g.q, arg, g.q, arg, p.Object), "a "+p.Object)
+		g.printf("\tp%d := (%s)(%sObjectValue(%s))\n", i, p.GoName, g.q, arg)
+	}
+}
+
+func (g *generator) convertResult(value string, r goType) string {
+	switch r.Kind {
+	case "integer":
+		return fmt.Sprintf("%sIntegerWithValue(int64(%s))", g.q, value)
+	case "float":
+		return fmt.Sprintf("%sFloatWithValue(float32(%s))", g.q, value)
+	case "string":
+		return fmt.Sprintf("%sStringWithValue(%s)", g.q, value)
+	case "boolean":
+		return fmt.Sprintf("%sBooleanWithValue(%s)", g.q, value)
+	case "bytes":
+		return fmt.Sprintf("%sObjectWithTypeAndValue(\"[]byte\", unsafe.Pointer(&%s))", g.q, value)
+	case "object":
+		return fmt.Sprintf("%sObjectWithTypeAndValue(%q, unsafe.Pointer(%s))", g.q, r.Object, value)
+	}
+	return value
+}
+
+func (g *generator) binding(b binding) {
+	g.printf("func golispBinding%s(args *%sData, env *%sSymbolTableFrame) (result *%sData, err error) {\n", b.GoName, g.q, g.q, g.q)
+	if len(b.Params) > 0 {
+		g.printf("\targv := %sToArray(args)\n", g.q)
+	}
+	params := make([]string, len(b.Params))
+	for i, p := range b.Params {
+		g.convertParam(b, i, p)
+		params[i] = fmt.Sprintf("p%d", i)
+	}
+
+	results := make([]string, len(b.Results))
+	for i := range b.Results {
+		results[i] = fmt.Sprintf("r%d", i)
+	}
+	call := fmt.Sprintf("%s(%s)", b.GoName, strings.Join(params, ", "))
+	if len(results) == 0 {
+		g.printf("\t%s\n", call)
+	} else {
+		g.printf("\t%s := %s\n", strings.Join(results, ", "), call)
+	}
+
+	values := b.Results
+	if len(values) > 0 && values[len(values)-1].Kind == "error" {
+		last := results[len(results)-1]
+		g.printf("\tif %s != nil {\n", last)
+		g.printf("\t\terr = %sProcessError(fmt.Sprintf(\"%s: %%s\", %s), env)\n", g.q, b.LispName, last)
+		g.printf("\t\treturn\n\t}\n")
+		values = values[:len(values)-1]
+	}
+
+	switch len(values) {
+	case 0:
+		g.printf("\treturn\n")
+	case 1:
+		g.printf("\treturn %s, nil\n", g.convertResult(results[0], values[0]))
+	default:
+		converted := make([]string, len(values))
+		for i, v := range values {
+			converted[i] = g.convertResult(results[i], v)
+		}
+		g.printf("\treturn %sInternalMakeList(%s), nil\n", g.q, strings.Join(converted, ", "))
+	}
+	g.printf("}\n\n")
+}
+
+func (g *generator) objectPredicate(object string) {
+	g.printf("func golispBinding%sP(args *%sData, env *%sSymbolTableFrame) (result *%sData, err error) {\n", object, g.q, g.q, g.q)
+	g.printf("\treturn %sBooleanWithValue(%sObjectP(%sCar(args)) && %sObjectType(%sCar(args)) == %q), nil\n", g.q, g.q, g.q, g.q, g.q, object)
+	g.printf("}\n\n")
+}
+
+// generate produces the formatted source of the bindings, registered by a function named registerFunction
+func generate(scanned *scanResult, registerFunction string) ([]byte, error) {
+	g := &generator{q: "golisp."}
+	if scanned.Package == "golisp" {
+		g.q = ""
+	}
+
+	var body generator
+	body.q = g.q
+	body.printf("// %s registers the annotated functions of this package as lisp primitives.\n", registerFunction)
+	body.printf("func %s() {\n", registerFunction)
+	for _, b := range scanned.Bindings {
+		body.printf("\t%sMakePrimitiveFunction(%q, \"%d\", golispBinding%s)\n", g.q, b.LispName, len(b.Params), b.GoName)
+	}
+	for _, object := range scanned.Objects {
+		body.printf("\t%sMakePrimitiveFunction(%q, \"1\", golispBinding%sP)\n", g.q, lispName(object)+"?", object)
+	}
+	body.printf("}\n\n")
+	for _, b := range scanned.Bindings {
+		body.binding(b)
+	}
+	for _, object := range scanned.Objects {
+		body.objectPredicate(object)
+	}
+
+	code := body.buf.String()
+	imports := make([]string, 0, 3)
+	if strings.Contains(code, "fmt.") {
+		imports = append(imports, `"fmt"`)
+	}
+	if g.q != "" {
+		imports = append(imports, `"github.com/steelseries/golisp"`)
+	}
+	if strings.Contains(code, "unsafe.") {
+		imports = append(imports, `"unsafe"`)
+	}
+
+	g.printf("// Code generated by golisp-bindgen. DO NOT EDIT.\n\n")
+	g.printf("package %s\n\n", scanned.Package)
+	if len(imports) > 0 {
+		g.printf("import (\n\t%s\n)\n\n", strings.Join(imports, "\n\t"))
+	}
+	g.buf.WriteString(code)
+
+	return format.Source(g.buf.Bytes())
+}
@@ -0,0 +1,134 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests the binding generator.
+
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	. "gopkg.in/check.v1"
+	"strings"
+	"testing"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type BindgenSuite struct {
+}
+
+var _ = Suite(&BindgenSuite{})
+
+const widgetSource = `package widgets
+
+import "github.com/steelseries/golisp"
+
+//golisp:object
+type Widget struct {
+	Name string
+}
+
+//golisp:primitive
+func NewWidget(name string) *Widget {
+	return &Widget{Name: name}
+}
+
+//golisp:primitive widget-name
+func Name(w *Widget) string {
+	return w.Name
+}
+
+//golisp:primitive
+func ScaleHTTPValue(x float64, factor int32, round bool) (int, error) {
+	return 0, nil
+}
+
+//golisp:primitive
+func Describe(d *golisp.Data) (string, []byte) {
+	return "", nil
+}
+
+//golisp:primitive
+func Reset() {
+}
+
+func NotExported(x int) int {
+	return x
+}
+`
+
+func scanSource(c *C, src string) (*scanResult, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "widgets.go", src, parser.ParseComments)
+	c.Assert(err, IsNil)
+	return scan(fset, []*ast.File{file})
+}
+
+func (s *BindgenSuite) TestLispNames(c *C) {
+	c.Assert(lispName("NewWidget"), Equals, "new-widget")
+	c.Assert(lispName("ScaleHTTPValue"), Equals, "scale-http-value")
+	c.Assert(lispName("reset"), Equals, "reset")
+}
+
+func (s *BindgenSuite) TestScanning(c *C) {
+	scanned, err := scanSource(c, widgetSource)
+	c.Assert(err, IsNil)
+	c.Assert(scanned.Package, Equals, "widgets")
+	c.Assert(scanned.Objects, DeepEquals, []string{"Widget"})
+
+	names := make([]string, 0)
+	for _, b := range scanned.Bindings {
+		names = append(names, b.LispName)
+	}
+	c.Assert(names, DeepEquals, []string{"describe", "new-widget", "reset", "scale-http-value", "widget-name"})
+}
+
+func (s *BindgenSuite) TestGenerating(c *C) {
+	scanned, err := scanSource(c, widgetSource)
+	c.Assert(err, IsNil)
+	src, err := generate(scanned, "RegisterWidgets")
+	c.Assert(err, IsNil)
+
+	code := string(src)
+	for _, expected := range []string{
+		"// Code generated by golisp-bindgen. DO NOT EDIT.",
+		`golisp.MakePrimitiveFunction("new-widget", "1", golispBindingNewWidget)`,
+		`golisp.MakePrimitiveFunction("scale-http-value", "3", golispBindingScaleHTTPValue)`,
+		`golisp.MakePrimitiveFunction("widget?", "1", golispBindingWidgetP)`,
+		`return golisp.ObjectWithTypeAndValue("Widget", unsafe.Pointer(r0)), nil`,
+		`p0 := (*Widget)(golisp.ObjectValue(argv[0]))`,
+		`p1 := int32(golisp.IntegerValue(argv[1]))`,
+		`p2 := golisp.BooleanValue(argv[2])`,
+		`err = golisp.ProcessError(fmt.Sprintf("scale-http-value: %s", r1), env)`,
+		`return golisp.InternalMakeList(golisp.StringWithValue(r0), golisp.ObjectWithTypeAndValue("[]byte", unsafe.Pointer(&r1))), nil`,
+	} {
+		c.Assert(strings.Contains(code, expected), Equals, true, Commentf("missing %s in\n%s", expected, code))
+	}
+	c.Assert(strings.Contains(code, "NotExported"), Equals, false)
+}
+
+func (s *BindgenSuite) TestGeneratingIntoGolisp(c *C) {
+	scanned, err := scanSource(c, "package golisp\n\n//golisp:primitive\nfunc First(d *Data) *Data {\n\treturn Car(d)\n}\n")
+	c.Assert(err, IsNil)
+	src, err := generate(scanned, "RegisterFirst")
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(src), `MakePrimitiveFunction("first", "1", golispBindingFirst)`), Equals, true)
+	c.Assert(strings.Contains(string(src), "github.com/steelseries/golisp"), Equals, false)
+}
+
+func (s *BindgenSuite) TestUnsupportedSignatures(c *C) {
+	for _, src := range []string{
+		"package p\n\n//golisp:primitive\nfunc F(m map[string]int) {}\n",
+		"package p\n\n//golisp:primitive\nfunc F(xs ...int) {}\n",
+		"package p\n\n//golisp:primitive\nfunc F(e error) {}\n",
+		"package p\n\n//golisp:primitive\nfunc F() (error, int) { return nil, 0 }\n",
+		"package p\n\ntype T struct{}\n\n//golisp:primitive\nfunc F(t *T) {}\n",
+	} {
+		_, err := scanSource(c, src)
+		c.Assert(err, NotNil, Commentf("scanning %s", src))
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file provides golisp-bindgen, which generates the glue that registers annotated Go
+// functions as lisp primitives.  Use it from the host package with
+//
+//	//go:generate golisp-bindgen
+//
+// and annotate functions and types:
+//
+//	//golisp:primitive widget-count
+//	func CountWidgets(w *Widget, min int) (int, error) { ... }
+//
+//	//golisp:object
+//	type Widget struct { ... }
+//
+// Functions take and return integers, floats, strings, bools, []byte, *golisp.Data and
+// pointers to annotated types, and may return an error last.  Without a name the primitive
+// is named after the function, e.g. CountWidgets becomes count-widgets.  Each annotated type
+// also gets a predicate, e.g. widget?.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	dir              = flag.String("dir", ".", "directory of the package to scan")
+	output           = flag.String("output", "golisp_bindings.go", "file to write the bindings to, relative to dir")
+	registerFunction = flag.String("register", "RegisterGolispBindings", "name of the generated registration function")
+)
+
+func main() {
+	flag.Parse()
+	if err := run(*dir, *output, *registerFunction); err != nil {
+		fmt.Fprintf(os.Stderr, "golisp-bindgen: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(dir string, output string, registerFunction string) error {
+	fset := token.NewFileSet()
+	filter := func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go") && info.Name() != filepath.Base(output)
+	}
+	packages, err := parser.ParseDir(fset, dir, filter, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	if len(packages) != 1 {
+		return fmt.Errorf("expected one package in %s but found %d", dir, len(packages))
+	}
+
+	var files []*ast.File
+	for _, pkg := range packages {
+		for _, file := range pkg.Files {
+			files = append(files, file)
+		}
+	}
+
+	scanned, err := scan(fset, files)
+	if err != nil {
+		return err
+	}
+	src, err := generate(scanned, registerFunction)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, output), src, 0644)
+}
@@ -0,0 +1,579 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file translates define-compiled functions to Go.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/steelseries/golisp"
+)
+
+// arity is the range of argument counts a primitive accepts, where a max of -1 means there
+// is no upper bound
+type arity struct {
+	min int
+	max int
+}
+
+// The primitives compiled functions can call: arithmetic, comparisons and vector operations
+var supportedPrimitives = map[string]arity{
+	"+": {0, -1}, "-": {0, -1}, "*": {0, -1}, "/": {0, -1}, "quotient": {2, 2},
+	"%": {2, 2}, "remainder": {2, 2}, "modulo": {2, 2},
+	"succ": {1, 1}, "pred": {1, 1}, "abs": {1, 1}, "sign": {1, 1},
+	"min": {1, -1}, "max": {1, -1}, "floor": {1, 1}, "ceiling": {1, 1},
+	"pow": {2, 2}, "expt": {2, 2}, "integer": {1, 1}, "float": {1, 1},
+	"sqrt": {1, 1}, "exp": {1, 1}, "log": {1, 1}, "sin": {1, 1}, "cos": {1, 1}, "tan": {1, 1},
+	"atan":  {1, 1},
+	"zero?": {1, 1}, "positive?": {1, 1}, "negative?": {1, 1}, "even?": {1, 1}, "odd?": {1, 1},
+	"<": {2, -1}, ">": {2, -1}, "<=": {2, -1}, ">=": {2, -1}, "=": {2, -1},
+	"==": {2, 2}, "!=": {2, 2},
+	"make-vector": {1, 2}, "vector": {0, -1}, "vector-length": {1, 1},
+	"vector-ref": {2, 2}, "vector-set!": {3, 3},
+}
+
+// compiledFunction is a define-compiled function found in the lisp source
+type compiledFunction struct {
+	LispName string
+	GoName   string
+	Params   []string
+	Body     []*golisp.Data
+}
+
+// goIdentifier turns a lisp name such as vector-norm! into VectorNormBang
+func goIdentifier(lispName string) string {
+	var buf bytes.Buffer
+	upper := true
+	for _, r := range lispName {
+		switch {
+		case r == '?':
+			buf.WriteString("P")
+		case r == '!':
+			buf.WriteString("Bang")
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if upper {
+				r = unicode.ToUpper(r)
+			}
+			buf.WriteRune(r)
+			upper = false
+			continue
+		}
+		upper = true
+	}
+	return buf.String()
+}
+
+// scan finds the define-compiled forms among forms
+func scan(forms []*golisp.Data) (functions []compiledFunction, err error) {
+	goNames := make(map[string]string)
+	for _, form := range forms {
+		if !golisp.PairP(form) || !golisp.SymbolP(golisp.Car(form)) || golisp.StringValue(golisp.Car(form)) != "define-compiled" {
+			continue
+		}
+		signature := golisp.Cadr(form)
+		if !golisp.PairP(signature) || !golisp.SymbolP(golisp.Car(signature)) {
+			return nil, errors.New(fmt.Sprintf("define-compiled expected a (name param...) list, received %s", golisp.String(signature)))
+		}
+
+		f := compiledFunction{LispName: golisp.StringValue(golisp.Car(signature)), Body: golisp.ToArray(golisp.Cddr(form))}
+		f.GoName = goIdentifier(f.LispName)
+		if other, found := goNames[f.GoName]; found {
+			return nil, errors.New(fmt.Sprintf("%s and %s would have the same Go name", other, f.LispName))
+		}
+		goNames[f.GoName] = f.LispName
+
+		for c := golisp.Cdr(signature); golisp.NotNilP(c); c = golisp.Cdr(c) {
+			if !golisp.PairP(c) || !golisp.SymbolP(golisp.Car(c)) {
+				return nil, errors.New(fmt.Sprintf("%s: compiled functions need a fixed list of parameter names", f.LispName))
+			}
+			f.Params = append(f.Params, golisp.StringValue(golisp.Car(c)))
+		}
+		if len(f.Body) == 0 {
+			return nil, errors.New(fmt.Sprintf("%s: compiled functions need a body", f.LispName))
+		}
+		functions = append(functions, f)
+	}
+	sort.Sort(byLispName(functions))
+	return
+}
+
+type byLispName []compiledFunction
+
+func (f byLispName) Len() int           { return len(f) }
+func (f byLispName) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+func (f byLispName) Less(i, j int) bool { return f[i].LispName < f[j].LispName }
+
+// scope maps the lisp names of parameters and locals to Go variables
+type scope struct {
+	names  map[string]string
+	parent *scope
+}
+
+func (s *scope) lookup(name string) (goName string, found bool) {
+	for ; s != nil; s = s.parent {
+		if goName, found = s.names[name]; found {
+			return
+		}
+	}
+	return
+}
+
+// compiler writes Go for the functions, qualifying golisp names unless generating into
+// golisp itself
+type compiler struct {
+	q          string
+	functions  map[string]compiledFunction
+	primitives []string
+	primitive  map[string]int
+	constants  []string
+	constant   map[string]string
+	buf        bytes.Buffer
+	indent     int
+	temps      int
+	current    string
+}
+
+func (c *compiler) printf(format string, args ...interface{}) {
+	c.buf.WriteString(strings.Repeat("\t", c.indent))
+	fmt.Fprintf(&c.buf, format, args...)
+}
+
+func (c *compiler) errorf(format string, args ...interface{}) error {
+	return errors.New(fmt.Sprintf("%s: %s", c.current, fmt.Sprintf(format, args...)))
+}
+
+func (c *compiler) temp() string {
+	c.temps++
+	return fmt.Sprintf("t%d", c.temps)
+}
+
+// local names the Go variable for a lisp parameter or local, e.g. x-pos becomes xPos3
+func (c *compiler) local(name string) string {
+	c.temps++
+	identifier := goIdentifier(name)
+	if identifier == "" {
+		identifier = "v"
+	}
+	return fmt.Sprintf("%s%s%d", strings.ToLower(identifier[:1]), identifier[1:], c.temps)
+}
+
+// constantFor hoists the Go expression for a literal into a package level variable
+func (c *compiler) constantFor(expr string) string {
+	if name, found := c.constant[expr]; found {
+		return name
+	}
+	name := fmt.Sprintf("golispConstant%d", len(c.constants))
+	c.constants = append(c.constants, fmt.Sprintf("%s = %s", name, expr))
+	c.constant[expr] = name
+	return name
+}
+
+func (c *compiler) primitiveIndex(name string) int {
+	if i, found := c.primitive[name]; found {
+		return i
+	}
+	c.primitive[name] = len(c.primitives)
+	c.primitives = append(c.primitives, name)
+	return len(c.primitives) - 1
+}
+
+func (c *compiler) checkError() {
+	c.printf("if err != nil {\n")
+	c.printf("\treturn\n")
+	c.printf("}\n")
+}
+
+func (c *compiler) literal(form *golisp.Data) (expr string, err error) {
+	switch {
+	case golisp.NilP(form):
+		return "nil", nil
+	case golisp.IntegerP(form):
+		return c.constantFor(fmt.Sprintf("%sIntegerWithValue(%d)", c.q, golisp.IntegerValue(form))), nil
+	case golisp.FloatP(form):
+		return c.constantFor(fmt.Sprintf("%sFloatWithValue(%s)", c.q, strconv.FormatFloat(float64(golisp.FloatValue(form)), 'g', -1, 32))), nil
+	case golisp.BooleanP(form):
+		if golisp.BooleanValue(form) {
+			return c.q + "LispTrue", nil
+		}
+		return c.q + "LispFalse", nil
+	case golisp.StringP(form):
+		return c.constantFor(fmt.Sprintf("%sStringWithValue(%q)", c.q, golisp.StringValue(form))), nil
+	case golisp.SymbolP(form):
+		return c.constantFor(fmt.Sprintf("%sIntern(%q)", c.q, golisp.StringValue(form))), nil
+	}
+	return "", c.errorf("%s can't be compiled", golisp.String(form))
+}
+
+// expression writes the statements that evaluate form, and returns the Go expression for
+// its value
+func (c *compiler) expression(form *golisp.Data, s *scope) (expr string, err error) {
+	if golisp.SymbolP(form) && !golisp.NakedP(form) {
+		if goName, found := s.lookup(golisp.StringValue(form)); found {
+			return goName, nil
+		}
+		return "", c.errorf("%s is not a parameter or local variable", golisp.StringValue(form))
+	}
+	if !golisp.PairP(form) || golisp.NilP(form) {
+		return c.literal(form)
+	}
+
+	head := golisp.Car(form)
+	args := golisp.ToArray(golisp.Cdr(form))
+	if !golisp.SymbolP(head) {
+		return "", c.errorf("only named functions can be called, not %s", golisp.String(head))
+	}
+	name := golisp.StringValue(head)
+	if _, shadowed := s.lookup(name); shadowed {
+		return "", c.errorf("%s can't be called since it's a variable", name)
+	}
+
+	switch name {
+	case "quote":
+		if len(args) != 1 || golisp.PairP(args[0]) {
+			return "", c.errorf("only quoted symbols and literals can be compiled, not %s", golisp.String(form))
+		}
+		return c.literal(args[0])
+	case "if":
+		if len(args) < 2 || len(args) > 3 {
+			return "", c.errorf("if expects 2 or 3 arguments")
+		}
+		return c.conditional(args[0], args[1:2], args[2:], s)
+	case "when", "unless":
+		if len(args) < 2 {
+			return "", c.errorf("%s expects a condition and a body", name)
+		}
+		if name == "when" {
+			return c.conditional(args[0], args[1:], nil, s)
+		}
+		return c.conditional(args[0], nil, args[1:], s)
+	case "cond":
+		return c.cond(args, s)
+	case "and", "or":
+		return c.logical(name == "and", args, s)
+	case "not":
+		if len(args) != 1 {
+			return "", c.errorf("not expects 1 argument")
+		}
+		value, err := c.expression(args[0], s)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%sBooleanWithValue(!%sBooleanValue(%s))", c.q, c.q, value), nil
+	case "begin":
+		return c.body(args, s)
+	case "let", "let*":
+		return c.let(name == "let*", args, s)
+	case "set!":
+		return c.assignment(args, s)
+	}
+
+	values := make([]string, len(args))
+	for i, arg := range args {
+		if values[i], err = c.expression(arg, s); err != nil {
+			return
+		}
+	}
+
+	var call string
+	if f, found := c.functions[name]; found {
+		if len(args) != len(f.Params) {
+			return "", c.errorf("%s expects %d arguments but is given %d", name, len(f.Params), len(args))
+		}
+		call = fmt.Sprintf("golispCompiled%s(%s)", f.GoName, strings.Join(append([]string{"env"}, values...), ", "))
+	} else if expected, found := supportedPrimitives[name]; found {
+		if len(args) < expected.min || (expected.max >= 0 && len(args) > expected.max) {
+			return "", c.errorf("%s can't be called with %d arguments", name, len(args))
+		}
+		call = fmt.Sprintf("golispPrimitives[%d](%sInternalMakeList(%s), env)", c.primitiveIndex(name), c.q, strings.Join(values, ", "))
+	} else {
+		return "", c.errorf("%s is not a compiled function or a supported primitive", name)
+	}
+
+	// declared first so that err isn't shadowed in nested blocks
+	result := c.temp()
+	c.printf("var %s *%sData\n", result, c.q)
+	c.printf("%s, err = %s\n", result, call)
+	c.checkError()
+	return result, nil
+}
+
+// body writes the forms in sequence, resulting in the value of the last one
+func (c *compiler) body(forms []*golisp.Data, s *scope) (expr string, err error) {
+	expr = "nil"
+	for i, form := range forms {
+		if expr, err = c.expression(form, s); err != nil {
+			return
+		}
+		if i < len(forms)-1 && isVariable(expr) {
+			c.printf("_ = %s\n", expr)
+		}
+	}
+	return
+}
+
+// isVariable is true of the Go expressions for temporaries and locals, which Go requires to
+// be used
+func isVariable(expr string) bool {
+	return !strings.ContainsAny(expr, "(.") && expr != "nil" && !strings.HasPrefix(expr, "golispConstant")
+}
+
+// block writes forms in a Go block that assigns their value to result
+func (c *compiler) block(result string, forms []*golisp.Data, s *scope) (err error) {
+	c.indent++
+	defer func() { c.indent-- }()
+	value, err := c.body(forms, &scope{names: map[string]string{}, parent: s})
+	if err == nil {
+		c.printf("%s = %s\n", result, value)
+	}
+	return
+}
+
+func (c *compiler) conditional(test *golisp.Data, then []*golisp.Data, otherwise []*golisp.Data, s *scope) (expr string, err error) {
+	condition, err := c.expression(test, s)
+	if err != nil {
+		return
+	}
+	result := c.temp()
+	c.printf("var %s *%sData\n", result, c.q)
+	c.printf("if %sBooleanValue(%s) {\n", c.q, condition)
+	if err = c.block(result, then, s); err != nil {
+		return
+	}
+	if len(otherwise) > 0 {
+		c.printf("} else {\n")
+		if err = c.block(result, otherwise, s); err != nil {
+			return
+		}
+	}
+	c.printf("}\n")
+	return result, nil
+}
+
+// cond writes the clauses as nested ifs, so each test is only evaluated when the ones
+// before it fail
+func (c *compiler) cond(clauses []*golisp.Data, s *scope) (expr string, err error) {
+	result := c.temp()
+	c.printf("var %s *%sData\n", result, c.q)
+	closing := 0
+	for i, clause := range clauses {
+		if !golisp.PairP(clause) || golisp.NilP(clause) {
+			return "", c.errorf("cond clauses have to be lists, not %s", golisp.String(clause))
+		}
+		test := golisp.Car(clause)
+		body := golisp.ToArray(golisp.Cdr(clause))
+		if golisp.SymbolP(test) && golisp.StringValue(test) == "else" {
+			if err = c.block(result, body, s); err != nil {
+				return
+			}
+			break
+		}
+		for _, form := range body {
+			if golisp.SymbolP(form) && golisp.StringValue(form) == "=>" {
+				return "", c.errorf("cond clauses with => can't be compiled")
+			}
+		}
+
+		var condition string
+		if condition, err = c.expression(test, s); err != nil {
+			return
+		}
+		c.printf("if %sBooleanValue(%s) {\n", c.q, condition)
+		if len(body) == 0 {
+			c.printf("\t%s = %s\n", result, condition)
+		} else if err = c.block(result, body, s); err != nil {
+			return
+		}
+		if i == len(clauses)-1 {
+			c.printf("}\n")
+			break
+		}
+		c.printf("} else {\n")
+		c.indent++
+		closing++
+	}
+	for ; closing > 0; closing-- {
+		c.indent--
+		c.printf("}\n")
+	}
+	return result, nil
+}
+
+// logical writes and or or, which stop at the first false or true value respectively
+func (c *compiler) logical(isAnd bool, forms []*golisp.Data, s *scope) (expr string, err error) {
+	result := c.temp()
+	if isAnd {
+		c.printf("%s := %sLispTrue\n", result, c.q)
+	} else {
+		c.printf("%s := %sLispFalse\n", result, c.q)
+	}
+	test := "%sBooleanValue(%s)"
+	if !isAnd {
+		test = "!%sBooleanValue(%s)"
+	}
+	closing := 0
+	for i, form := range forms {
+		if i > 0 {
+			c.printf("if "+test+" {\n", c.q, result)
+			c.indent++
+			closing++
+		}
+		var value string
+		if value, err = c.expression(form, s); err != nil {
+			return
+		}
+		c.printf("%s = %s\n", result, value)
+	}
+	for ; closing > 0; closing-- {
+		c.indent--
+		c.printf("}\n")
+	}
+	return result, nil
+}
+
+func (c *compiler) let(sequential bool, args []*golisp.Data, s *scope) (expr string, err error) {
+	if len(args) < 2 || !golisp.ListP(args[0]) {
+		return "", c.errorf("let expects a list of bindings and a body")
+	}
+	result := c.temp()
+	c.printf("var %s *%sData\n", result, c.q)
+	c.printf("{\n")
+	c.indent++
+
+	local := &scope{names: map[string]string{}, parent: s}
+	initScope := s
+	if sequential {
+		initScope = local
+	}
+	for _, binding := range golisp.ToArray(args[0]) {
+		if !golisp.PairP(binding) || !golisp.SymbolP(golisp.Car(binding)) || golisp.Length(binding) > 2 {
+			return "", c.errorf("let bindings have to be (name value) lists, not %s", golisp.String(binding))
+		}
+		var value string
+		if value, err = c.expression(golisp.Cadr(binding), initScope); err != nil {
+			return
+		}
+		name := c.local(golisp.StringValue(golisp.Car(binding)))
+		c.printf("var %s *%sData = %s\n", name, c.q, value)
+		c.printf("_ = %s\n", name)
+		local.names[golisp.StringValue(golisp.Car(binding))] = name
+	}
+
+	value, err := c.body(args[1:], local)
+	if err != nil {
+		return
+	}
+	c.printf("%s = %s\n", result, value)
+	c.indent--
+	c.printf("}\n")
+	return result, nil
+}
+
+func (c *compiler) assignment(args []*golisp.Data, s *scope) (expr string, err error) {
+	if len(args) != 2 || !golisp.SymbolP(args[0]) {
+		return "", c.errorf("set! expects a name and a value")
+	}
+	goName, found := s.lookup(golisp.StringValue(args[0]))
+	if !found {
+		return "", c.errorf("only parameters and local variables can be set!, not %s", golisp.StringValue(args[0]))
+	}
+	value, err := c.expression(args[1], s)
+	if err != nil {
+		return
+	}
+	c.printf("%s = %s\n", goName, value)
+	return goName, nil
+}
+
+func (c *compiler) compileFunction(f compiledFunction) (err error) {
+	c.current = f.LispName
+	c.temps = 0
+	params := make([]string, len(f.Params))
+	s := &scope{names: map[string]string{}}
+	for i, param := range f.Params {
+		params[i] = c.local(param)
+		s.names[param] = params[i]
+	}
+
+	c.printf("// golispCompiled%s is the compiled version of %s\n", f.GoName, f.LispName)
+	signature := append([]string{fmt.Sprintf("env *%sSymbolTableFrame", c.q)}, params...)
+	if len(params) > 0 {
+		signature[len(signature)-1] += fmt.Sprintf(" *%sData", c.q)
+	}
+	c.printf("func golispCompiled%s(%s) (result *%sData, err error) {\n", f.GoName, strings.Join(signature, ", "), c.q)
+	c.indent++
+	value, err := c.body(f.Body, s)
+	if err != nil {
+		return
+	}
+	c.printf("return %s, nil\n", value)
+	c.indent--
+	c.printf("}\n\n")
+
+	c.printf("func golispCompiled%sPrimitive(args *%sData, env *%sSymbolTableFrame) (*%sData, error) {\n", f.GoName, c.q, c.q, c.q)
+	arguments := []string{"env"}
+	if len(params) > 0 {
+		c.printf("\targv := %sToArray(args)\n", c.q)
+		for i := range params {
+			arguments = append(arguments, fmt.Sprintf("argv[%d]", i))
+		}
+	}
+	c.printf("\treturn golispCompiled%s(%s)\n", f.GoName, strings.Join(arguments, ", "))
+	c.printf("}\n\n")
+	return
+}
+
+// generate produces the formatted Go source for the functions in package pkg, registered by
+// a function named registerFunction
+func generate(functions []compiledFunction, pkg string, registerFunction string) ([]byte, error) {
+	c := &compiler{q: "golisp.", functions: make(map[string]compiledFunction), primitive: make(map[string]int), constant: make(map[string]string)}
+	if pkg == "golisp" {
+		c.q = ""
+	}
+	for _, f := range functions {
+		c.functions[f.LispName] = f
+	}
+	for _, f := range functions {
+		if err := c.compileFunction(f); err != nil {
+			return nil, err
+		}
+	}
+	code := c.buf.String()
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by golisp-compile. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\n", pkg)
+	if c.q != "" {
+		out.WriteString("import \"github.com/steelseries/golisp\"\n\n")
+	}
+	fmt.Fprintf(&out, "var golispPrimitives []func(*%sData, *%sSymbolTableFrame) (*%sData, error)\n\n", c.q, c.q, c.q)
+	if len(c.constants) > 0 {
+		fmt.Fprintf(&out, "var (\n\t%s\n)\n\n", strings.Join(c.constants, "\n\t"))
+	}
+
+	fmt.Fprintf(&out, "// %s registers the compiled functions, which define-compiled then\n", registerFunction)
+	out.WriteString("// leaves in place of their lisp definitions.\n")
+	fmt.Fprintf(&out, "func %s() {\n", registerFunction)
+	quoted := make([]string, len(c.primitives))
+	for i, name := range c.primitives {
+		quoted[i] = fmt.Sprintf("%q", name)
+	}
+	fmt.Fprintf(&out, "\tgolispPrimitives = %sPrimitiveBodies(%s)\n", c.q, strings.Join(quoted, ", "))
+	for _, f := range functions {
+		fmt.Fprintf(&out, "\t%sRegisterCompiledFunction(%q, \"%d\", golispCompiled%sPrimitive)\n", c.q, f.LispName, len(f.Params), f.GoName)
+	}
+	out.WriteString("}\n\n")
+	out.WriteString(code)
+
+	return format.Source(out.Bytes())
+}
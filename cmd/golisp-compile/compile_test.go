@@ -0,0 +1,192 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests the compiler.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/steelseries/golisp"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type CompileSuite struct {
+}
+
+var _ = Suite(&CompileSuite{})
+
+const samplesSource = `
+(define (not-compiled x) x)
+
+(define-compiled (scale-sample sample gain)
+  (if (> sample 0) (* sample gain) 0))
+
+(define-compiled (fact n)
+  (if (<= n 1) 1 (* n (fact (- n 1)))))
+
+(define-compiled (in-range? x)
+  (let* ((lo 0)
+         (hi (+ lo 10)))
+    (and (>= x lo) (< x hi))))
+`
+
+func compileSource(c *C, src string) ([]compiledFunction, error) {
+	forms, err := golisp.ParseAll(src)
+	c.Assert(err, IsNil)
+	return scan(forms)
+}
+
+func (s *CompileSuite) TestGoIdentifiers(c *C) {
+	c.Assert(goIdentifier("scale-sample"), Equals, "ScaleSample")
+	c.Assert(goIdentifier("in-range?"), Equals, "InRangeP")
+	c.Assert(goIdentifier("reset!"), Equals, "ResetBang")
+	c.Assert(goIdentifier("x->y"), Equals, "XY")
+}
+
+func (s *CompileSuite) TestScanning(c *C) {
+	functions, err := compileSource(c, samplesSource)
+	c.Assert(err, IsNil)
+	names := make([]string, 0)
+	for _, f := range functions {
+		names = append(names, f.LispName)
+	}
+	c.Assert(names, DeepEquals, []string{"fact", "in-range?", "scale-sample"})
+	c.Assert(functions[2].Params, DeepEquals, []string{"sample", "gain"})
+}
+
+func (s *CompileSuite) TestGenerating(c *C) {
+	functions, err := compileSource(c, samplesSource)
+	c.Assert(err, IsNil)
+	src, err := generate(functions, "devices", "RegisterSamples")
+	c.Assert(err, IsNil)
+
+	code := string(src)
+	for _, expected := range []string{
+		"// Code generated by golisp-compile. DO NOT EDIT.",
+		"package devices",
+		`golisp.RegisterCompiledFunction("scale-sample", "2", golispCompiledScaleSamplePrimitive)`,
+		`golisp.RegisterCompiledFunction("in-range?", "1", golispCompiledInRangePPrimitive)`,
+		"func golispCompiledScaleSample(env *golisp.SymbolTableFrame, sample1, gain2 *golisp.Data) (result *golisp.Data, err error) {",
+		"golispConstant1 = golisp.IntegerWithValue(0)",
+		"= golispCompiledFact(env, ",
+		"if golisp.BooleanValue(",
+	} {
+		c.Assert(strings.Contains(code, expected), Equals, true, Commentf("missing %s in\n%s", expected, code))
+	}
+	c.Assert(strings.Contains(code, "not-compiled"), Equals, false)
+}
+
+// comparisonMain evaluates each of its arguments with the compiled functions registered,
+// printing one result per line
+const comparisonMain = `package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/steelseries/golisp"
+)
+
+func main() {
+	RegisterSamples()
+	for _, expr := range os.Args[1:] {
+		result, err := golisp.ParseAndEval(expr)
+		if err != nil {
+			fmt.Println("error")
+			continue
+		}
+		fmt.Println(golisp.String(result))
+	}
+}
+`
+
+func (s *CompileSuite) TestCompiledFunctionsMatchTheInterpreter(c *C) {
+	goTool, err := exec.LookPath("go")
+	if testing.Short() || err != nil {
+		c.Skip("needs the go tool to build the generated code")
+	}
+
+	functions, err := compileSource(c, samplesSource)
+	c.Assert(err, IsNil)
+	src, err := generate(functions, "main", "RegisterSamples")
+	c.Assert(err, IsNil)
+
+	// build inside this package's directory so the generated code imports this golisp
+	dir, err := ioutil.TempDir(".", "generated")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+	generated := filepath.Join(dir, "samples.go")
+	program := filepath.Join(dir, "main.go")
+	c.Assert(ioutil.WriteFile(generated, src, 0644), IsNil)
+	c.Assert(ioutil.WriteFile(program, []byte(comparisonMain), 0644), IsNil)
+
+	exprs := []string{"(fact 0)", "(fact 1)", "(fact 10)", "(fact 20)", "(fact 2.5)", "(fact 'a)",
+		"(scale-sample 3 4)", "(scale-sample -3 4)", "(scale-sample 0 4)", "(scale-sample 1.5 2)", "(scale-sample 2 0.5)",
+		"(scale-sample 'a 1)", "(in-range? 5)", "(in-range? 10)"}
+	output, err := exec.Command(goTool, append([]string{"run", program, generated}, exprs...)...).CombinedOutput()
+	c.Assert(err, IsNil, Commentf("%s", output))
+	compiled := strings.Split(strings.TrimSpace(string(output)), "\n")
+
+	_, err = golisp.ParseAndEvalAll(samplesSource)
+	c.Assert(err, IsNil)
+	c.Assert(compiled, HasLen, len(exprs))
+	for i, expr := range exprs {
+		interpreted := "error"
+		if result, err := golisp.ParseAndEval(expr); err == nil {
+			interpreted = golisp.String(result)
+		}
+		c.Assert(compiled[i], Equals, interpreted, Commentf("%s", expr))
+	}
+}
+
+func (s *CompileSuite) TestGeneratingIntoGolisp(c *C) {
+	functions, err := compileSource(c, "(define-compiled (twice x) (* x 2))")
+	c.Assert(err, IsNil)
+	src, err := generate(functions, "golisp", "RegisterTwice")
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(src), `RegisterCompiledFunction("twice", "1", golispCompiledTwicePrimitive)`), Equals, true)
+	c.Assert(strings.Contains(string(src), "github.com/steelseries/golisp"), Equals, false)
+}
+
+func (s *CompileSuite) TestUnsupportedFunctions(c *C) {
+	for _, src := range []string{
+		"(define-compiled (f x) (display x))",
+		"(define-compiled (f x) (undefined-variable))",
+		"(define-compiled (f x) y)",
+		"(define-compiled (f x) (x 1))",
+		"(define-compiled (f x) ((lambda (y) y) x))",
+		"(define-compiled (f x) '(1 2))",
+		"(define-compiled (f x) (vector-ref x))",
+		"(define-compiled (f x) (f))",
+		"(define-compiled (f x) (set! y 1))",
+		"(define-compiled (f x) (cond ((= x 1) => succ)))",
+	} {
+		functions, err := compileSource(c, src)
+		c.Assert(err, IsNil, Commentf("scanning %s", src))
+		_, err = generate(functions, "p", "Register")
+		c.Assert(err, NotNil, Commentf("compiling %s", src))
+	}
+}
+
+func (s *CompileSuite) TestInvalidDefinitions(c *C) {
+	for _, src := range []string{
+		"(define-compiled f 1)",
+		"(define-compiled (f . args) 1)",
+		"(define-compiled (f x))",
+		"(define-compiled (f-p x) 1) (define-compiled (f-p? x) 1) (define-compiled (fP x) 1)",
+	} {
+		_, err := compileSource(c, src)
+		c.Assert(err, NotNil, Commentf("scanning %s", src))
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file provides golisp-compile, which translates lisp functions defined with
+// define-compiled into Go for hosts to compile in.  Use it from the host package with
+//
+//	//go:generate golisp-compile -package devices scripts/samples.lsp
+//
+// and mark the hot functions in the lisp source:
+//
+//	(define-compiled (scale-sample sample gain)
+//	  (if (> sample 0) (* sample gain) 0))
+//
+// Calling the generated registration function makes define-compiled use the compiled
+// versions.  Compiled functions can use their parameters, let, let*, set!, if, when,
+// unless, cond, and, or, not and begin, call each other, and call the arithmetic,
+// comparison and vector primitives; anything else is reported when generating.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/steelseries/golisp"
+)
+
+var (
+	output           = flag.String("output", "golisp_compiled.go", "file to write the compiled functions to")
+	pkg              = flag.String("package", "main", "package of the generated file")
+	registerFunction = flag.String("register", "RegisterCompiledFunctions", "name of the generated registration function")
+)
+
+func main() {
+	flag.Parse()
+	if err := run(flag.Args(), *output, *pkg, *registerFunction); err != nil {
+		fmt.Fprintf(os.Stderr, "golisp-compile: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(files []string, output string, pkg string, registerFunction string) error {
+	if len(files) == 0 {
+		return fmt.Errorf("no lisp files given")
+	}
+
+	var forms []*golisp.Data
+	for _, filename := range files {
+		src, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		fileForms, err := golisp.ParseAll(string(src))
+		if err != nil {
+			return fmt.Errorf("%s: %s", filename, err)
+		}
+		forms = append(forms, fileForms...)
+	}
+
+	functions, err := scan(forms)
+	if err != nil {
+		return err
+	}
+	src, err := generate(functions, pkg, registerFunction)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(output, src, 0644)
+}
@@ -0,0 +1,262 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements parser combinators that parse lists of tokens.
+
+package golisp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Combinator is a parser for a sequence of tokens, as made by lex.  Combinators are ordered
+// choice (PEG) parsers: they don't backtrack into a choice that has succeeded.
+type Combinator interface {
+	// parse tries to parse the input at pos, returning the result and the position after
+	// what it consumed, or ok false if it doesn't match there
+	parse(state *parseState, pos int) (result *Data, next int, ok bool, err error)
+}
+
+// parseState is the input being parsed along with the furthest point any parser got to
+// and what was expected there, which is where a failed parse is reported.  It also tracks
+// which lazy parsers are running at which positions, to catch left recursion.
+type parseState struct {
+	input    []*Data
+	env      *SymbolTableFrame
+	furthest int
+	expected map[string]bool
+	active   map[lazyParse]bool
+	depth    int
+}
+
+type lazyParse struct {
+	parser *lazyParser
+	pos    int
+}
+
+// Rules can only nest this deeply, so a runaway grammar fails rather than overflowing the
+// stack
+const maxParseDepth = 10000
+
+func (self *parseState) fail(pos int, expected string) {
+	if pos > self.furthest {
+		self.furthest = pos
+		self.expected = make(map[string]bool)
+	}
+	if pos == self.furthest {
+		self.expected[expected] = true
+	}
+}
+
+// ParseTokens parses all of tokens with parser, returning its result
+func ParseTokens(parser Combinator, tokens []*Data, env *SymbolTableFrame) (result *Data, err error) {
+	state := &parseState{input: tokens, env: env, expected: make(map[string]bool), active: make(map[lazyParse]bool)}
+	result, next, ok, err := parser.parse(state, 0)
+	if err != nil {
+		return
+	}
+	if ok && next == len(tokens) {
+		return result, nil
+	}
+	if ok {
+		state.fail(next, "end of input")
+	}
+	return nil, state.error()
+}
+
+func (self *parseState) error() error {
+	expected := make([]string, 0, len(self.expected))
+	for description := range self.expected {
+		expected = append(expected, description)
+	}
+	sort.Strings(expected)
+	found := "end of input"
+	where := ""
+	if self.furthest < len(self.input) {
+		token := self.input[self.furthest]
+		found = String(tokenText(token))
+		if FrameP(token) && IntegerP(FrameValue(token).Get("line:")) {
+			where = fmt.Sprintf(" at line %d, column %d", IntegerValue(FrameValue(token).Get("line:")), IntegerValue(FrameValue(token).Get("column:")))
+		}
+	}
+	return NewLispError(ParseError, fmt.Sprintf("Parse failed%s: expected %s but found %s", where, strings.Join(expected, " or "), found))
+}
+
+// tokenType is the type: of a token frame, or the token itself for input that isn't made
+// of frames, e.g. a list of symbols
+func tokenType(token *Data) *Data {
+	if FrameP(token) {
+		return FrameValue(token).Get("type:")
+	}
+	return token
+}
+
+func tokenText(token *Data) *Data {
+	if FrameP(token) {
+		return FrameValue(token).Get("text:")
+	}
+	return token
+}
+
+func tokenValue(token *Data) *Data {
+	if FrameP(token) {
+		return FrameValue(token).Get("value:")
+	}
+	return token
+}
+
+// tokenParser matches one token: by type if match is a symbol, or by text if it's a string
+type tokenParser struct {
+	match *Data
+}
+
+func (self *tokenParser) parse(state *parseState, pos int) (result *Data, next int, ok bool, err error) {
+	if pos < len(state.input) {
+		token := state.input[pos]
+		if (SymbolP(self.match) && IsEqv(tokenType(token), self.match)) || (StringP(self.match) && IsEqual(tokenText(token), self.match)) {
+			return tokenValue(token), pos + 1, true, nil
+		}
+	}
+	state.fail(pos, String(self.match))
+	return nil, pos, false, nil
+}
+
+type seqParser struct {
+	parsers []Combinator
+}
+
+func (self *seqParser) parse(state *parseState, pos int) (result *Data, next int, ok bool, err error) {
+	results := make([]*Data, 0, len(self.parsers))
+	next = pos
+	for _, parser := range self.parsers {
+		var item *Data
+		item, next, ok, err = parser.parse(state, next)
+		if !ok || err != nil {
+			return nil, pos, false, err
+		}
+		results = append(results, item)
+	}
+	return ArrayToList(results), next, true, nil
+}
+
+type altParser struct {
+	parsers []Combinator
+}
+
+func (self *altParser) parse(state *parseState, pos int) (result *Data, next int, ok bool, err error) {
+	for _, parser := range self.parsers {
+		result, next, ok, err = parser.parse(state, pos)
+		if ok || err != nil {
+			return
+		}
+	}
+	return nil, pos, false, nil
+}
+
+// manyParser matches its parser as many times as it can, and at least min times
+type manyParser struct {
+	parser Combinator
+	min    int
+}
+
+func (self *manyParser) parse(state *parseState, pos int) (result *Data, next int, ok bool, err error) {
+	results := make([]*Data, 0)
+	next = pos
+	for {
+		item, after, matched, err := self.parser.parse(state, next)
+		if err != nil {
+			return nil, pos, false, err
+		}
+		if !matched || after == next {
+			break
+		}
+		results = append(results, item)
+		next = after
+	}
+	if len(results) < self.min {
+		return nil, pos, false, nil
+	}
+	return ArrayToList(results), next, true, nil
+}
+
+// optionalParser matches its parser or nothing, which results in otherwise
+type optionalParser struct {
+	parser    Combinator
+	otherwise *Data
+}
+
+func (self *optionalParser) parse(state *parseState, pos int) (result *Data, next int, ok bool, err error) {
+	result, next, ok, err = self.parser.parse(state, pos)
+	if err != nil || ok {
+		return
+	}
+	return self.otherwise, pos, true, nil
+}
+
+// mapParser transforms the result of its parser with a lisp function
+type mapParser struct {
+	parser   Combinator
+	function *Data
+}
+
+func (self *mapParser) parse(state *parseState, pos int) (result *Data, next int, ok bool, err error) {
+	result, next, ok, err = self.parser.parse(state, pos)
+	if err != nil || !ok {
+		return
+	}
+	result, err = ApplyWithoutEval(self.function, InternalMakeList(result), state.env)
+	return
+}
+
+// lazyParser gets its parser by calling a lisp function the first time it's used, so that
+// grammars can refer to rules defined after them, including themselves
+type lazyParser struct {
+	thunk  *Data
+	parser Combinator
+	mutex  sync.Mutex
+}
+
+func (self *lazyParser) force(env *SymbolTableFrame) (err error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.parser != nil {
+		return
+	}
+	parser, err := ApplyWithoutEval(self.thunk, nil, env)
+	if err != nil {
+		return
+	}
+	if !CombinatorP(parser) {
+		return NewLispError(TypeError, fmt.Sprintf("p-lazy requires its function to return a parser but it returned %s.", String(parser)))
+	}
+	self.parser = CombinatorValue(parser)
+	return
+}
+
+func (self *lazyParser) parse(state *parseState, pos int) (result *Data, next int, ok bool, err error) {
+	if err = self.force(state.env); err != nil {
+		return
+	}
+
+	key := lazyParse{parser: self, pos: pos}
+	if state.active[key] {
+		err = NewLispError(ParseError, fmt.Sprintf("Parse failed: a rule is left recursive, using itself at token %d without consuming anything", pos))
+		return
+	}
+	if state.depth >= maxParseDepth {
+		err = NewLispError(ParseError, fmt.Sprintf("Parse failed: rules nested more than %d deep", maxParseDepth))
+		return
+	}
+	state.active[key] = true
+	state.depth++
+	defer func() {
+		delete(state.active, key)
+		state.depth--
+	}()
+	return self.parser.parse(state, pos)
+}
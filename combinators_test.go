@@ -0,0 +1,36 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests parser combinators.
+
+package golisp
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type CombinatorsSuite struct {
+}
+
+var _ = Suite(&CombinatorsSuite{})
+
+func (s *CombinatorsSuite) TestFailuresReportTheFurthestPoint(c *C) {
+	_, err := ParseAndEval(`(p-parse (p-seq (p-token 'word) (p-alt (p-token "=") (p-token ":")) (p-token 'number))
+                                     (lex (make-lexer '(("[a-z]+" word) ("[0-9]+" number) ("[=:]" punct) ("\\s+" #f)))
+                                          "port\n  = x"))`)
+	c.Assert(ErrorCategoryOf(err), Equals, ParseError)
+	c.Assert(err, ErrorMatches, `(?s).*Parse failed at line 2, column 5: expected number but found "x".*`)
+
+	_, err = ParseAndEval(`(p-parse (p-alt (p-token 'a) (p-token 'b)) '())`)
+	c.Assert(err, ErrorMatches, `(?s).*expected a or b but found end of input.*`)
+}
+
+func (s *CombinatorsSuite) TestParseTokens(c *C) {
+	parser, err := ParseAndEval(`(p-many (p-token 'a))`)
+	c.Assert(err, IsNil)
+	result, err := ParseTokens(CombinatorValue(parser), []*Data{Intern("a"), Intern("a")}, Global)
+	c.Assert(err, IsNil)
+	c.Assert(String(result), Equals, "(a a)")
+}
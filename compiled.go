@@ -0,0 +1,76 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements support for functions compiled to Go by golisp-compile.
+
+package golisp
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Functions defined with define-compiled can be translated to Go by golisp-compile.  The
+// host registers the generated functions as primitives with RegisterCompiledFunction, after
+// which define-compiled leaves them in place rather than defining the lisp version.  Without
+// a compiled version, define-compiled is the same as define.
+
+var compiledFunctions = struct {
+	sync.RWMutex
+	names map[string]bool
+}{names: make(map[string]bool)}
+
+// RegisterCompiledFunction registers the generated Go version of a define-compiled function
+func RegisterCompiledFunction(name string, argCount string, function func(*Data, *SymbolTableFrame) (*Data, error)) {
+	MakePrimitiveFunction(name, argCount, function)
+	compiledFunctions.Lock()
+	defer compiledFunctions.Unlock()
+	compiledFunctions.names[name] = true
+}
+
+func CompiledFunctionP(name string) bool {
+	compiledFunctions.RLock()
+	defer compiledFunctions.RUnlock()
+	return compiledFunctions.names[name]
+}
+
+// PrimitiveBodies looks up the Go functions of the named primitives, for generated code to
+// call directly.  It panics if one isn't a primitive, since the generated code can't run
+// without it.
+func PrimitiveBodies(names ...string) (bodies []func(*Data, *SymbolTableFrame) (*Data, error)) {
+	bodies = make([]func(*Data, *SymbolTableFrame) (*Data, error), len(names))
+	for i, name := range names {
+		primitive := PrimitiveValue(Global.ValueOf(Intern(name)))
+		if primitive == nil || primitive.Special {
+			panic(fmt.Sprintf("%s is not a primitive function", name))
+		}
+		bodies[i] = primitive.Body
+	}
+	return
+}
+
+// DefineCompiledImpl handles (define-compiled (name param...) body...), which defines the
+// function like define unless its compiled version has been registered
+func DefineCompiledImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if !PairP(Car(args)) || !SymbolP(Caar(args)) {
+		err = ProcessError(fmt.Sprintf("define-compiled expected a (name param...) list, received %s", String(Car(args))), env)
+		return
+	}
+	name := Caar(args)
+	if CompiledFunctionP(StringValue(name)) {
+		return Global.ValueOf(name), nil
+	}
+	return DefineImpl(args, env)
+}
+
+// IsCompiledImpl handles (compiled? 'name), which is true when the compiled version of a
+// define-compiled function is in use
+func IsCompiledImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if !SymbolP(Car(args)) {
+		err = ProcessTypeError(fmt.Sprintf("compiled? expected a function name, received %s", String(Car(args))), env)
+		return
+	}
+	return BooleanWithValue(CompiledFunctionP(StringValue(Car(args)))), nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests support for compiled functions.
+
+package golisp
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type CompiledSuite struct {
+}
+
+var _ = Suite(&CompiledSuite{})
+
+func (s *CompiledSuite) TestDefineCompiledWithoutCompiledVersion(c *C) {
+	result, err := ParseAndEvalAll("(define-compiled (compiled-suite-double x) (* x 2)) (compiled-suite-double 21)")
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(42))
+	c.Assert(CompiledFunctionP("compiled-suite-double"), Equals, false)
+}
+
+func (s *CompiledSuite) TestDefineCompiledKeepsCompiledVersion(c *C) {
+	RegisterCompiledFunction("compiled-suite-triple", "1", func(args *Data, env *SymbolTableFrame) (*Data, error) {
+		return IntegerWithValue(IntegerValue(Car(args)) * 3), nil
+	})
+	result, err := ParseAndEvalAll("(define-compiled (compiled-suite-triple x) 0) (compiled-suite-triple 2)")
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(6))
+
+	result, err = ParseAndEval("(compiled? 'compiled-suite-triple)")
+	c.Assert(err, IsNil)
+	c.Assert(BooleanValue(result), Equals, true)
+}
+
+func (s *CompiledSuite) TestPrimitiveBodies(c *C) {
+	bodies := PrimitiveBodies("+")
+	result, err := bodies[0](InternalMakeList(IntegerWithValue(1), IntegerWithValue(2)), Global)
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(3))
+
+	c.Assert(func() { PrimitiveBodies("if") }, PanicMatches, "if is not a primitive function")
+}
+
+func (s *CompiledSuite) TestInvalidDefinitions(c *C) {
+	_, err := ParseAndEval("(define-compiled x 1)")
+	c.Assert(err, NotNil)
+	_, err = ParseAndEval("(compiled? \"x\")")
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,187 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements the interpreter's configuration.
+
+package golisp
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+// InterpreterConfig holds the settings a host chooses for the interpreter, passed to
+// InitLispWithConfig or ApplyConfig.  Scripts can change some of them as they run, e.g.
+// with (lisp-trace #t) or (max-call-depth 1000).
+type InterpreterConfig struct {
+	// MaxCallDepth limits how deeply function calls and local scopes may nest before
+	// evaluation fails with a StackOverflowError, protecting the host process from running
+	// out of Go stack.  Zero disables the check.  Use SetMaxCallDepth to change it while
+	// code is running.
+	MaxCallDepth int32
+
+	// ParallelWorkers is how many goroutines pmap and pvector-map split their work across;
+	// zero means one per processor Go will use
+	ParallelWorkers int
+
+	// LoadPath lists the directories that load and require look in for relative file names
+	// that aren't found as given
+	LoadPath []string
+
+	// Extensions are the registered extensions to use when the configuration is applied
+	Extensions []string
+
+	// Output, ErrorOutput, and TraceOutput are where printing, errors that can't be returned,
+	// and tracing go when the configuration is applied; nil means stdout, stderr, and stdout
+	Output      io.Writer
+	ErrorOutput io.Writer
+	TraceOutput io.Writer
+
+	// DebugTrace is set with (debug-trace #t)
+	DebugTrace bool
+
+	// LispTrace traces every evaluation to TraceWriter
+	LispTrace bool
+
+	// DebugOnError enters the debugger when an error occurs in an interactive session
+	DebugOnError bool
+
+	// DumpLimits keeps environment dumps from flooding the terminal
+	DumpLimits DumpLimits
+
+	// DebugCommandPrefix starts debugger commands, as opposed to code to evaluate
+	DebugCommandPrefix string
+
+	// OptimizeDefinitions controls whether define runs the optimizer over the value or
+	// function body being defined
+	OptimizeDefinitions bool
+
+	// StrictArityChecking makes loading a file fail, rather than just warn, when it contains
+	// a call whose argument count the function being called can never accept
+	StrictArityChecking bool
+}
+
+// DefaultInterpreterConfig returns the configuration the interpreter starts with
+func DefaultInterpreterConfig() InterpreterConfig {
+	return InterpreterConfig{
+		MaxCallDepth:       100000,
+		DumpLimits:         DumpLimits{Width: 200},
+		DebugCommandPrefix: ":",
+	}
+}
+
+// Config is the configuration in effect
+var Config = DefaultInterpreterConfig()
+
+const (
+	defaultMaxCallDepth       = 100000
+	defaultDebugCommandPrefix = ":"
+)
+
+// The package-level settings that InterpreterConfig replaced are still honoured alongside
+// Config until the next release.  A boolean is on if either it or the Config field is on,
+// and a changed DebugCommandPrefix or MaxCallDepth takes precedence over Config.  Changing
+// a setting from Lisp, or with ApplyConfig, resets the deprecated variable.
+var (
+	// Deprecated: use Config.DebugTrace
+	DebugTrace = false
+
+	// Deprecated: use Config.LispTrace
+	LispTrace = false
+
+	// Deprecated: use Config.DebugOnError
+	DebugOnError = false
+
+	// Deprecated: use Config.DebugCommandPrefix
+	DebugCommandPrefix = defaultDebugCommandPrefix
+
+	// Deprecated: use Config.OptimizeDefinitions
+	OptimizeDefinitions = false
+
+	// Deprecated: use Config.StrictArityChecking
+	StrictArityChecking = false
+
+	// Deprecated: use Config.MaxCallDepth or SetMaxCallDepth
+	MaxCallDepth int32 = defaultMaxCallDepth
+)
+
+func debugTraceEnabled() bool {
+	return Config.DebugTrace || DebugTrace
+}
+
+func lispTraceEnabled() bool {
+	return Config.LispTrace || LispTrace
+}
+
+func debugOnErrorEnabled() bool {
+	return Config.DebugOnError || DebugOnError
+}
+
+func optimizingDefinitions() bool {
+	return Config.OptimizeDefinitions || OptimizeDefinitions
+}
+
+func strictArityChecking() bool {
+	return Config.StrictArityChecking || StrictArityChecking
+}
+
+func debugCommandPrefix() string {
+	if DebugCommandPrefix != defaultDebugCommandPrefix {
+		return DebugCommandPrefix
+	}
+	return Config.DebugCommandPrefix
+}
+
+func maxCallDepth() int32 {
+	if depth := atomic.LoadInt32(&MaxCallDepth); depth != defaultMaxCallDepth {
+		return depth
+	}
+	return atomic.LoadInt32(&Config.MaxCallDepth)
+}
+
+func resetDeprecatedSettings() {
+	DebugTrace = false
+	LispTrace = false
+	DebugOnError = false
+	DebugCommandPrefix = defaultDebugCommandPrefix
+	OptimizeDefinitions = false
+	StrictArityChecking = false
+	atomic.StoreInt32(&MaxCallDepth, defaultMaxCallDepth)
+}
+
+// InitLispWithConfig sets up the interpreter afresh, replacing the global environment, and
+// applies config to it
+func InitLispWithConfig(config InterpreterConfig) error {
+	InitLisp()
+	return ApplyConfig(config)
+}
+
+// ApplyConfig makes config the configuration in effect, redirecting output and using the
+// extensions it lists
+func ApplyConfig(config InterpreterConfig) error {
+	config.LoadPath = append([]string{}, config.LoadPath...)
+	config.Extensions = append([]string{}, config.Extensions...)
+	Config = config
+	resetDeprecatedSettings()
+
+	SetOutputWriter(writerOrDefault(config.Output, os.Stdout))
+	SetErrorWriter(writerOrDefault(config.ErrorOutput, os.Stderr))
+	SetTraceWriter(writerOrDefault(config.TraceOutput, os.Stdout))
+
+	for _, name := range config.Extensions {
+		if err := UseExtension(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writerOrDefault(w io.Writer, standard io.Writer) io.Writer {
+	if w == nil {
+		return standard
+	}
+	return w
+}
@@ -0,0 +1,192 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests the interpreter configuration.
+
+package golisp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type ConfigSuite struct {
+}
+
+var _ = Suite(&ConfigSuite{})
+
+func init() {
+	RegisterExtension(NewExtension("config-test", `(define config-test-loaded #t)`))
+}
+
+func (s *ConfigSuite) TearDownTest(c *C) {
+	ApplyConfig(DefaultInterpreterConfig())
+}
+
+func (s *ConfigSuite) TestDefaults(c *C) {
+	config := DefaultInterpreterConfig()
+	c.Assert(config.MaxCallDepth, Equals, int32(100000))
+	c.Assert(config.DebugCommandPrefix, Equals, ":")
+	c.Assert(config.LispTrace, Equals, false)
+	c.Assert(config.StrictArityChecking, Equals, false)
+}
+
+func (s *ConfigSuite) TestOutputWriters(c *C) {
+	var output, errors bytes.Buffer
+	config := DefaultInterpreterConfig()
+	config.Output = &output
+	config.ErrorOutput = &errors
+	c.Assert(ApplyConfig(config), IsNil)
+
+	_, err := ParseAndEval(`(display "configured")`)
+	c.Assert(err, IsNil)
+	c.Assert(output.String(), Equals, "configured")
+	c.Assert(OutputWriter(), Equals, &output)
+	c.Assert(ErrorWriter(), Equals, &errors)
+}
+
+func (s *ConfigSuite) TestFlagsAreVisibleFromLisp(c *C) {
+	config := DefaultInterpreterConfig()
+	config.StrictArityChecking = true
+	config.OptimizeDefinitions = true
+	c.Assert(ApplyConfig(config), IsNil)
+
+	result, err := ParseAndEval("(strict-arity-checking)")
+	c.Assert(err, IsNil)
+	c.Assert(BooleanValue(result), Equals, true)
+	result, err = ParseAndEval("(optimize)")
+	c.Assert(err, IsNil)
+	c.Assert(BooleanValue(result), Equals, true)
+
+	_, err = ParseAndEval("(optimize #f)")
+	c.Assert(err, IsNil)
+	c.Assert(Config.OptimizeDefinitions, Equals, false)
+}
+
+func (s *ConfigSuite) TestMaxCallDepth(c *C) {
+	config := DefaultInterpreterConfig()
+	config.MaxCallDepth = 50
+	c.Assert(ApplyConfig(config), IsNil)
+
+	_, err := ParseAndEval("(define (config-test-deep n) (if (= n 0) 0 (+ 1 (config-test-deep (- n 1)))))")
+	c.Assert(err, IsNil)
+	_, err = ParseAndEval("(config-test-deep 100)")
+	c.Assert(err, NotNil)
+	result, err := ParseAndEval("(config-test-deep 10)")
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(10))
+}
+
+func (s *ConfigSuite) TestDeprecatedSettings(c *C) {
+	StrictArityChecking = true
+	MaxCallDepth = 50
+
+	result, err := ParseAndEval("(strict-arity-checking)")
+	c.Assert(err, IsNil)
+	c.Assert(BooleanValue(result), Equals, true)
+	_, err = ParseAndEval("(define (config-test-deprecated n) (if (= n 0) 0 (+ 1 (config-test-deprecated (- n 1)))))")
+	c.Assert(err, IsNil)
+	_, err = ParseAndEval("(config-test-deprecated 100)")
+	c.Assert(err, NotNil)
+
+	_, err = ParseAndEval("(strict-arity-checking #f)")
+	c.Assert(err, IsNil)
+	c.Assert(StrictArityChecking, Equals, false)
+
+	c.Assert(ApplyConfig(DefaultInterpreterConfig()), IsNil)
+	c.Assert(MaxCallDepth, Equals, int32(100000))
+	_, err = ParseAndEval("(config-test-deprecated 100)")
+	c.Assert(err, IsNil)
+}
+
+func (s *ConfigSuite) TestLoadPath(c *C) {
+	directory, err := ioutil.TempDir("", "golisp-config")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(directory)
+	c.Assert(ioutil.WriteFile(filepath.Join(directory, "config-test-lib.lsp"), []byte("(define config-test-lib 42)"), 0644), IsNil)
+
+	_, err = ParseAndEval(`(load "config-test-lib.lsp")`)
+	c.Assert(err, NotNil)
+
+	config := DefaultInterpreterConfig()
+	config.LoadPath = []string{"no-such-directory", directory}
+	c.Assert(ApplyConfig(config), IsNil)
+
+	_, err = ParseAndEval(`(load "config-test-lib.lsp")`)
+	c.Assert(err, IsNil)
+	result, err := ParseAndEval("config-test-lib")
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(42))
+
+	filename, found := resolveRequire("config-test-lib")
+	c.Assert(found, Equals, true)
+	c.Assert(filename, Equals, filepath.Join(directory, "config-test-lib.lsp"))
+}
+
+func (s *ConfigSuite) TestLoadPathExpandsVariables(c *C) {
+	directory, err := ioutil.TempDir("", "golisp-config")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(directory)
+	c.Assert(ioutil.WriteFile(filepath.Join(directory, "config-test-vars.lsp"), []byte("(define config-test-vars 7)"), 0644), IsNil)
+	os.Setenv("GOLISP_CONFIG_TEST_DIR", directory)
+	defer os.Unsetenv("GOLISP_CONFIG_TEST_DIR")
+
+	config := DefaultInterpreterConfig()
+	config.LoadPath = []string{"${GOLISP_CONFIG_TEST_DIR}"}
+	c.Assert(ApplyConfig(config), IsNil)
+
+	_, err = ParseAndEval(`(load "config-test-vars.lsp")`)
+	c.Assert(err, IsNil)
+	_, err = ParseAndEval(`(load "$GOLISP_CONFIG_TEST_DIR/config-test-vars.lsp")`)
+	c.Assert(err, IsNil)
+	result, err := ParseAndEval("config-test-vars")
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(7))
+}
+
+func (s *ConfigSuite) TestExpandVars(c *C) {
+	os.Setenv("GOLISP_CONFIG_TEST_VAR", "value")
+	defer os.Unsetenv("GOLISP_CONFIG_TEST_VAR")
+	os.Unsetenv("GOLISP_CONFIG_TEST_UNSET")
+
+	c.Assert(ExpandVars("a/${GOLISP_CONFIG_TEST_VAR}/$GOLISP_CONFIG_TEST_VAR"), Equals, "a/value/value")
+	c.Assert(ExpandVars("[$GOLISP_CONFIG_TEST_UNSET]"), Equals, "[]")
+	c.Assert(ExpandVars("${GOLISP_CONFIG_TEST_UNSET:-/tmp}/x"), Equals, "/tmp/x")
+	c.Assert(ExpandVars("${GOLISP_CONFIG_TEST_VAR:-/tmp}"), Equals, "value")
+	c.Assert(ExpandVars("cost: $$5"), Equals, "cost: $5")
+}
+
+func (s *ConfigSuite) TestExpandVarsWhenRestricted(c *C) {
+	os.Setenv("GOLISP_CONFIG_TEST_SECRET", "secret")
+	defer os.Unsetenv("GOLISP_CONFIG_TEST_SECRET")
+	env := NewSymbolTableFrameBelow(Global, "restricted expand-vars test")
+	env.IsRestricted = true
+
+	code, err := Parse(`(expand-vars "[$GOLISP_CONFIG_TEST_SECRET/$NAME]" {NAME: "x"})`)
+	c.Assert(err, IsNil)
+	result, err := Eval(code, env)
+	c.Assert(err, IsNil)
+	c.Assert(StringValue(result), Equals, "[/x]")
+
+	result, err = ParseAndEval(`(expand-vars "$GOLISP_CONFIG_TEST_SECRET")`)
+	c.Assert(err, IsNil)
+	c.Assert(StringValue(result), Equals, "secret")
+}
+
+func (s *ConfigSuite) TestExtensions(c *C) {
+	config := DefaultInterpreterConfig()
+	config.Extensions = []string{"config-test"}
+	c.Assert(ApplyConfig(config), IsNil)
+	result, err := ParseAndEval("config-test-loaded")
+	c.Assert(err, IsNil)
+	c.Assert(BooleanValue(result), Equals, true)
+
+	config.Extensions = []string{"config-test-missing"}
+	c.Assert(ApplyConfig(config), NotNil)
+}
@@ -0,0 +1,182 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements parsing and evaluation of cron expressions.
+
+package golisp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed standard five field cron expression:
+// minute hour day-of-month month day-of-week.  Each field is a bit set of the values
+// that match.
+type CronSchedule struct {
+	Spec     string
+	minute   uint64
+	hour     uint64
+	dom      uint64
+	month    uint64
+	dow      uint64
+	domStar  bool
+	dowStar  bool
+	Location *time.Location
+}
+
+var cronMonthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var cronDayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// How far ahead Next looks before deciding an expression (e.g. "0 0 30 2 *") never matches
+const cronSearchYears = 5
+
+// ParseCron parses spec, whose times are interpreted in the local timezone
+func ParseCron(spec string) (schedule *CronSchedule, err error) {
+	text := strings.TrimSpace(spec)
+	if expansion, found := cronMacros[strings.ToLower(text)]; found {
+		text = expansion
+	}
+
+	fields := strings.Fields(text)
+	if len(fields) != 5 {
+		return nil, errors.New(fmt.Sprintf("cron expression %q must have 5 fields", spec))
+	}
+
+	schedule = &CronSchedule{Spec: spec, Location: time.Local}
+	if schedule.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if schedule.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, err
+	}
+	if schedule.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, err
+	}
+	if schedule.month, err = parseCronField(fields[3], 1, 12, cronMonthNames); err != nil {
+		return nil, err
+	}
+	if schedule.dow, err = parseCronField(fields[4], 0, 7, cronDayNames); err != nil {
+		return nil, err
+	}
+
+	// 7 is another name for sunday
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow |= 1
+	}
+	schedule.domStar = strings.HasPrefix(fields[2], "*")
+	schedule.dowStar = strings.HasPrefix(fields[4], "*")
+	return
+}
+
+func parseCronValue(text string, names map[string]int) (int, error) {
+	if n, found := names[strings.ToLower(text)]; found {
+		return n, nil
+	}
+	return strconv.Atoi(text)
+}
+
+// parseCronField handles comma separated lists of *, n, a-b, each optionally followed by /step
+func parseCronField(field string, min int, max int, names map[string]int) (bits uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		rangeText := part
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			rangeText = part[:i]
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return 0, errors.New(fmt.Sprintf("invalid step in cron field %q", field))
+			}
+		}
+
+		low, high := min, max
+		switch {
+		case rangeText == "*":
+		case strings.Contains(rangeText, "-"):
+			bounds := strings.SplitN(rangeText, "-", 2)
+			low, err = parseCronValue(bounds[0], names)
+			if err == nil {
+				high, err = parseCronValue(bounds[1], names)
+			}
+		default:
+			low, err = parseCronValue(rangeText, names)
+			high = low
+			if err == nil && step > 1 {
+				high = max
+			}
+		}
+
+		if err != nil || low < min || high > max || low > high {
+			return 0, errors.New(fmt.Sprintf("invalid cron field %q", field))
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return
+}
+
+func cronMatches(bits uint64, value int) bool {
+	return bits&(1<<uint(value)) != 0
+}
+
+func (self *CronSchedule) dayMatches(t time.Time) bool {
+	domMatch := cronMatches(self.dom, t.Day())
+	dowMatch := cronMatches(self.dow, int(t.Weekday()))
+
+	// as in standard cron, when both day fields are restricted a day matching either will do
+	if !self.domStar && !self.dowStar {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// Next returns the first matching time strictly after t, or the zero time if there isn't one
+func (self *CronSchedule) Next(t time.Time) time.Time {
+	t = t.In(self.Location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + cronSearchYears
+
+	for t.Year() <= limit {
+		if !cronMatches(self.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, self.Location)
+			continue
+		}
+		if !self.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, self.Location)
+			continue
+		}
+		if !cronMatches(self.hour, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, self.Location)
+			continue
+		}
+		if !cronMatches(self.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,86 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests cron expressions.
+
+package golisp
+
+import (
+	. "gopkg.in/check.v1"
+	"time"
+)
+
+type CronSuite struct {
+}
+
+var _ = Suite(&CronSuite{})
+
+func nextCron(c *C, spec string, from string) string {
+	schedule, err := ParseCron(spec)
+	c.Assert(err, IsNil)
+	start, err := time.Parse(time.RFC3339, from)
+	c.Assert(err, IsNil)
+	schedule.Location = start.Location()
+	next := schedule.Next(start)
+	if next.IsZero() {
+		return ""
+	}
+	return next.Format(time.RFC3339)
+}
+
+func (s *CronSuite) TestEveryMinute(c *C) {
+	c.Assert(nextCron(c, "* * * * *", "2015-03-01T12:30:15Z"), Equals, "2015-03-01T12:31:00Z")
+	c.Assert(nextCron(c, "* * * * *", "2015-03-01T12:30:00Z"), Equals, "2015-03-01T12:31:00Z")
+}
+
+func (s *CronSuite) TestSteps(c *C) {
+	c.Assert(nextCron(c, "*/5 * * * *", "2015-03-01T12:31:00Z"), Equals, "2015-03-01T12:35:00Z")
+	c.Assert(nextCron(c, "*/15 * * * *", "2015-03-01T12:50:00Z"), Equals, "2015-03-01T13:00:00Z")
+	c.Assert(nextCron(c, "10-20/5 * * * *", "2015-03-01T12:16:00Z"), Equals, "2015-03-01T12:20:00Z")
+	c.Assert(nextCron(c, "7/30 * * * *", "2015-03-01T12:08:00Z"), Equals, "2015-03-01T12:37:00Z")
+}
+
+func (s *CronSuite) TestListsAndNames(c *C) {
+	c.Assert(nextCron(c, "0 9,17 * * *", "2015-03-01T12:00:00Z"), Equals, "2015-03-01T17:00:00Z")
+	c.Assert(nextCron(c, "0 0 1 jan-mar *", "2015-03-02T00:00:00Z"), Equals, "2016-01-01T00:00:00Z")
+	// 2015-03-01 is a sunday
+	c.Assert(nextCron(c, "30 8 * * mon-fri", "2015-03-01T12:00:00Z"), Equals, "2015-03-02T08:30:00Z")
+	c.Assert(nextCron(c, "0 0 * * 7", "2015-03-02T12:00:00Z"), Equals, "2015-03-08T00:00:00Z")
+}
+
+func (s *CronSuite) TestDayFieldsMatchEitherWhenBothRestricted(c *C) {
+	c.Assert(nextCron(c, "0 0 13 * fri", "2015-03-01T12:00:00Z"), Equals, "2015-03-06T00:00:00Z")
+	c.Assert(nextCron(c, "0 0 2 * fri", "2015-03-01T12:00:00Z"), Equals, "2015-03-02T00:00:00Z")
+}
+
+func (s *CronSuite) TestMacros(c *C) {
+	c.Assert(nextCron(c, "@hourly", "2015-03-01T12:30:00Z"), Equals, "2015-03-01T13:00:00Z")
+	c.Assert(nextCron(c, "@daily", "2015-03-01T12:30:00Z"), Equals, "2015-03-02T00:00:00Z")
+	c.Assert(nextCron(c, "@yearly", "2015-03-01T12:30:00Z"), Equals, "2016-01-01T00:00:00Z")
+}
+
+func (s *CronSuite) TestLeapDay(c *C) {
+	c.Assert(nextCron(c, "0 0 29 2 *", "2015-03-01T00:00:00Z"), Equals, "2016-02-29T00:00:00Z")
+	c.Assert(nextCron(c, "0 0 30 2 *", "2015-03-01T00:00:00Z"), Equals, "")
+}
+
+func (s *CronSuite) TestTimezones(c *C) {
+	location, err := time.LoadLocation("Europe/Copenhagen")
+	c.Assert(err, IsNil)
+	schedule, err := ParseCron("30 2 * * *")
+	c.Assert(err, IsNil)
+	schedule.Location = location
+
+	// 02:30 doesn't exist on the day summer time starts, so the next run is the day after
+	start := time.Date(2015, 3, 28, 12, 0, 0, 0, location)
+	c.Assert(schedule.Next(start).Format(time.RFC3339), Equals, "2015-03-30T02:30:00+02:00")
+}
+
+func (s *CronSuite) TestInvalidExpressions(c *C) {
+	for _, spec := range []string{"", "* * * *", "* * * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@sometimes"} {
+		_, err := ParseCron(spec)
+		c.Assert(err, NotNil, Commentf("parsing %q", spec))
+	}
+}
@@ -10,11 +10,12 @@ package golisp
 import (
 	"errors"
 	"fmt"
-	"math"
 	"os"
 	"sort"
 	"strings"
 	"sync/atomic"
+	"time"
+	"unicode"
 	"unsafe"
 
 	"gopkg.in/fatih/set.v0"
@@ -37,11 +38,14 @@ const (
 	FrameType
 	EnvironmentType
 	PortType
+	VectorType
+	CharacterType
 )
 
 type ConsCell struct {
-	Car *Data
-	Cdr *Data
+	Car    *Data
+	Cdr    *Data
+	Source *SourcePosition
 }
 
 type BoxedObject struct {
@@ -73,7 +77,6 @@ var DebugSingleStep bool = false
 var DebugCurrentFrame *SymbolTableFrame = nil
 var DebugEvalInDebugRepl bool = false
 var DebugErrorEnv *SymbolTableFrame = nil
-var DebugOnError bool = false
 var IsInteractive bool = false
 var DebugReturnValue *Data = nil
 var DebugOnEntry *set.Set = set.New()
@@ -120,6 +123,10 @@ func TypeName(t uint8) string {
 		return "Environment"
 	case PortType:
 		return "Port"
+	case VectorType:
+		return "Vector"
+	case CharacterType:
+		return "Character"
 	default:
 		return "Unknown"
 	}
@@ -175,7 +182,7 @@ func NakedP(d *Data) bool {
 }
 
 func StringP(d *Data) bool {
-	return d != nil && TypeOf(d) == StringType
+	return d != nil && (TypeOf(d) == StringType || RopeP(d))
 }
 
 func IntegerP(d *Data) bool {
@@ -187,7 +194,7 @@ func FloatP(d *Data) bool {
 }
 
 func NumberP(d *Data) bool {
-	return IntegerP(d) || FloatP(d)
+	return IntegerP(d) || FloatP(d) || BigIntegerP(d)
 }
 
 func ObjectP(d *Data) bool {
@@ -222,6 +229,14 @@ func PortP(d *Data) bool {
 	return d != nil && TypeOf(d) == PortType
 }
 
+func VectorP(d *Data) bool {
+	return d != nil && TypeOf(d) == VectorType
+}
+
+func CharacterP(d *Data) bool {
+	return d != nil && TypeOf(d) == CharacterType
+}
+
 func EmptyCons() *Data {
 	cell := ConsCell{Car: nil, Cdr: nil}
 	return &Data{Type: ConsCellType, Value: unsafe.Pointer(&cell)}
@@ -366,6 +381,8 @@ func StringWithValue(s string) *Data {
 
 func SetStringValue(d *Data, s string) *Data {
 	if StringP(d) {
+		// a rope's pieces may be shared with other ropes, so it becomes a plain string
+		d.Type = StringType
 		d.Value = unsafe.Pointer(&s)
 		return d
 	} else {
@@ -407,7 +424,19 @@ func EnvironmentWithValue(e *SymbolTableFrame) *Data {
 }
 
 func PortWithValue(e *os.File) *Data {
-	return &Data{Type: PortType, Value: unsafe.Pointer(e)}
+	return PortWithStream(e.Name(), e)
+}
+
+func PortWithPort(p *Port) *Data {
+	return &Data{Type: PortType, Value: unsafe.Pointer(p)}
+}
+
+func VectorWithValue(v []*Data) *Data {
+	return &Data{Type: VectorType, Value: unsafe.Pointer(&v)}
+}
+
+func CharacterWithValue(ch rune) *Data {
+	return &Data{Type: CharacterType, Value: unsafe.Pointer(&ch)}
 }
 
 func ConsValue(d *Data) *ConsCell {
@@ -469,6 +498,10 @@ func IntegerValue(d *Data) int64 {
 		return int64(*((*float32)(d.Value)))
 	}
 
+	if BigIntegerP(d) {
+		return bigIntegerInt64(BigIntegerValue(d))
+	}
+
 	return 0
 }
 
@@ -485,6 +518,10 @@ func FloatValue(d *Data) float32 {
 		return float32(*((*int64)(d.Value)))
 	}
 
+	if BigIntegerP(d) {
+		return float32(bigIntegerFloat(BigIntegerValue(d)))
+	}
+
 	return 0
 }
 
@@ -493,6 +530,10 @@ func StringValue(d *Data) string {
 		return ""
 	}
 
+	if RopeP(d) {
+		return RopeValue(d).String()
+	}
+
 	if StringP(d) || SymbolP(d) {
 		return *((*string)(d.Value))
 	}
@@ -608,18 +649,51 @@ func EnvironmentValue(d *Data) *SymbolTableFrame {
 	return nil
 }
 
-func PortValue(d *Data) *os.File {
+func PortValue(d *Data) *Port {
 	if d == nil {
 		return nil
 	}
 
 	if PortP(d) {
-		return (*os.File)(d.Value)
+		return (*Port)(d.Value)
 	}
 
 	return nil
 }
 
+func VectorValue(d *Data) []*Data {
+	if d == nil {
+		return nil
+	}
+
+	if VectorP(d) {
+		return *((*[]*Data)(d.Value))
+	}
+
+	return nil
+}
+
+func SetVectorValue(d *Data, v []*Data) *Data {
+	if VectorP(d) {
+		d.Value = unsafe.Pointer(&v)
+		return d
+	} else {
+		return nil
+	}
+}
+
+func CharacterValue(d *Data) rune {
+	if d == nil {
+		return 0
+	}
+
+	if CharacterP(d) {
+		return *((*rune)(d.Value))
+	}
+
+	return 0
+}
+
 // Function has heavy traffic, try to keep it fast, at least for the list/bytearray cases
 func Length(d *Data) int {
 	if d == nil {
@@ -639,6 +713,14 @@ func Length(d *Data) int {
 		return len(dBytes)
 	}
 
+	if BytevectorP(d) {
+		return len(BytevectorValue(d))
+	}
+
+	if VectorP(d) {
+		return len(VectorValue(d))
+	}
+
 	if FrameP(d) {
 		frame := FrameValue(d)
 		frame.Mutex.RLock()
@@ -802,6 +884,15 @@ func Copy(d *Data) *Data {
 			frame.Mutex.RUnlock()
 			return FrameWithValue(&m)
 		}
+	case VectorType:
+		{
+			elements := VectorValue(d)
+			v := make([]*Data, len(elements))
+			for i, element := range elements {
+				v[i] = Copy(element)
+			}
+			return VectorWithValue(v)
+		}
 	}
 
 	return d
@@ -816,6 +907,10 @@ func IsEqual(d *Data, o *Data) bool {
 		return false
 	}
 
+	if (RopeP(d) || RopeP(o)) && StringP(d) && StringP(o) {
+		return StringValue(d) == StringValue(o)
+	}
+
 	if AlistP(d) {
 		if !AlistP(o) && !ListP(o) {
 			return false
@@ -879,6 +974,20 @@ func IsEqual(d *Data, o *Data) bool {
 		return true
 	}
 
+	if VectorP(d) {
+		dElements := VectorValue(d)
+		oElements := VectorValue(o)
+		if len(dElements) != len(oElements) {
+			return false
+		}
+		for i := range dElements {
+			if !IsEqual(dElements[i], oElements[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
 	// special case for byte arrays
 	if ObjectP(d) && ObjectType(d) == "[]byte" && ObjectType(o) == "[]byte" {
 		dBytes := *(*[]byte)(ObjectValue(d))
@@ -896,6 +1005,13 @@ func IsEqual(d *Data, o *Data) bool {
 		return true
 	}
 
+	// value-like objects, such as decimals and durations, compare with their registered equality
+	if ObjectP(d) && ObjectType(d) == ObjectType(o) {
+		if equality := objectEqualityFor(ObjectType(d)); equality != nil && equality.Equal != nil {
+			return equality.Equal(ObjectValue(d), ObjectValue(o))
+		}
+	}
+
 	switch TypeOf(d) {
 	case IntegerType:
 		return IntegerValue(d) == IntegerValue(o)
@@ -903,6 +1019,8 @@ func IsEqual(d *Data, o *Data) bool {
 		return FloatValue(d) == FloatValue(o)
 	case BooleanType:
 		return BooleanValue(d) == BooleanValue(o)
+	case CharacterType:
+		return CharacterValue(d) == CharacterValue(o)
 	case StringType, SymbolType: // check symbols not generated using intern (aka: gensym and gensym-naked)
 		return StringValue(d) == StringValue(o)
 	case FunctionType:
@@ -918,22 +1036,333 @@ func IsEqual(d *Data, o *Data) bool {
 	return *d == *o
 }
 
-func escapeQuotes(str string) string {
-	buffer := make([]rune, 0, 10)
+// IsEqv compares atoms by value and everything else by identity.  Integers and floats
+// are never eqv to each other, even when they have the same numeric value.
+func IsEqv(d *Data, o *Data) bool {
+	if d == o {
+		return true
+	}
+
+	if NilP(d) || NilP(o) {
+		return NilP(d) && NilP(o)
+	}
+
+	if TypeOf(d) != TypeOf(o) {
+		return false
+	}
+
+	switch TypeOf(d) {
+	case IntegerType:
+		return IntegerValue(d) == IntegerValue(o)
+	case FloatType:
+		return FloatValue(d) == FloatValue(o)
+	case BooleanType:
+		return BooleanValue(d) == BooleanValue(o)
+	case CharacterType:
+		return CharacterValue(d) == CharacterValue(o)
+	case SymbolType:
+		return StringValue(d) == StringValue(o)
+	case BoxedObjectType:
+		if BigIntegerP(d) && BigIntegerP(o) {
+			return BigIntegerValue(d).Cmp(BigIntegerValue(o)) == 0
+		}
+		return (ObjectType(d) == ObjectType(o)) && (ObjectValue(d) == ObjectValue(o))
+	}
+
+	return d.Value == o.Value
+}
+
+// IsDeepEqual recursively compares lists, vectors, frames, bytearrays and strings.  Numbers
+// are compared by exact numeric value, so 1 and 1.0 are equal.  Circular structure is handled.
+// Boxed objects and frames can supply their own equality, see hashing.go.
+func IsDeepEqual(d *Data, o *Data) bool {
+	return isDeepEqual(d, o, make(map[[2]unsafe.Pointer]bool))
+}
+
+// numericValue is the nearest float64 to d.  Integers beyond 2^53 can round, so use
+// numberOrder to compare numbers.
+func numericValue(d *Data) float64 {
+	if IntegerP(d) {
+		return float64(IntegerValue(d))
+	}
+	if BigIntegerP(d) {
+		return bigIntegerFloat(BigIntegerValue(d))
+	}
+	return float64(FloatValue(d))
+}
+
+func isPairType(d *Data) bool {
+	return d != nil && (d.Type == ConsCellType || d.Type == AlistType || d.Type == AlistCellType)
+}
+
+func isDeepEqual(d *Data, o *Data, inProgress map[[2]unsafe.Pointer]bool) bool {
+	if NumberP(d) && NumberP(o) {
+		if IntegerP(d) && IntegerP(o) {
+			return IntegerValue(d) == IntegerValue(o)
+		}
+		order, ordered := numberOrder(d, o)
+		return ordered && order == 0
+	}
+
+	if IsEqv(d, o) {
+		return true
+	}
+
+	if NilP(d) || NilP(o) {
+		return false
+	}
+
+	if (RopeP(d) || RopeP(o)) && StringP(d) && StringP(o) {
+		return StringValue(d) == StringValue(o)
+	}
+
+	if handled, equal := customEqual(d, o, inProgress); handled {
+		return equal
+	}
+
+	if !(isPairType(d) && isPairType(o)) && TypeOf(d) != TypeOf(o) {
+		return false
+	}
+
+	if isPairType(d) || VectorP(d) || FrameP(d) {
+		// if we get back to a pair we are already comparing, the structures match so far
+		key := [2]unsafe.Pointer{d.Value, o.Value}
+		if inProgress[key] {
+			return true
+		}
+		inProgress[key] = true
+		defer delete(inProgress, key)
+	}
+
+	switch {
+	case isPairType(d):
+		return isDeepEqual(Car(d), Car(o), inProgress) && isDeepEqual(Cdr(d), Cdr(o), inProgress)
+	case VectorP(d):
+		dElements := VectorValue(d)
+		oElements := VectorValue(o)
+		if len(dElements) != len(oElements) {
+			return false
+		}
+		for i := range dElements {
+			if !isDeepEqual(dElements[i], oElements[i], inProgress) {
+				return false
+			}
+		}
+		return true
+	case FrameP(d):
+		dValues := FrameValue(d).Clone().Data
+		oValues := FrameValue(o).Clone().Data
+		if len(dValues) != len(oValues) {
+			return false
+		}
+		for k, v := range dValues {
+			other, found := oValues[k]
+			if !found || !isDeepEqual(v, other, inProgress) {
+				return false
+			}
+		}
+		return true
+	case StringP(d):
+		return StringValue(d) == StringValue(o)
+	case ObjectP(d) && ObjectType(d) == "[]byte" && ObjectType(o) == "[]byte":
+		return string(*(*[]byte)(ObjectValue(d))) == string(*(*[]byte)(ObjectValue(o)))
+	case FunctionP(d):
+		return FunctionValue(d) == FunctionValue(o)
+	case MacroP(d):
+		return MacroValue(d) == MacroValue(o)
+	case PrimitiveP(d):
+		return PrimitiveValue(d) == PrimitiveValue(o)
+	}
+
+	return false
+}
+
+func escapeString(str string) string {
+	buffer := make([]rune, 0, len(str)+2)
 	for _, ch := range str {
-		if rune(ch) == '"' {
-			buffer = append(buffer, '\\')
+		switch ch {
+		case '"', '\\':
+			buffer = append(buffer, '\\', ch)
+		case '\n':
+			buffer = append(buffer, '\\', 'n')
+		case '\t':
+			buffer = append(buffer, '\\', 't')
+		case '\r':
+			buffer = append(buffer, '\\', 'r')
+		default:
+			if unicode.IsControl(ch) {
+				if ch > 0xff {
+					buffer = append(buffer, []rune(fmt.Sprintf("\\u%04x", ch))...)
+				} else {
+					buffer = append(buffer, []rune(fmt.Sprintf("\\x%02x", ch))...)
+				}
+			} else {
+				buffer = append(buffer, ch)
+			}
 		}
-		buffer = append(buffer, rune(ch))
 	}
 	return string(buffer)
 }
 
+// printLabels tracks the structure that String has to print with #n= / #n# labels
+type printLabels struct {
+	shared  map[unsafe.Pointer]bool
+	numbers map[unsafe.Pointer]int
+}
+
+func (self *printLabels) isShared(d *Data) bool {
+	return self != nil && isLabelable(d) && self.shared[labelKey(d)]
+}
+
+// labelKey identifies the structure d is, which for a boxed object is the object itself
+// rather than the box, since the same object can be in more than one box
+func labelKey(d *Data) unsafe.Pointer {
+	if ObjectP(d) {
+		return ObjectValue(d)
+	}
+	return d.Value
+}
+
+func isLabelable(d *Data) bool {
+	if d == nil {
+		return false
+	}
+	switch d.Type {
+	case ConsCellType, AlistType, AlistCellType:
+		return NotNilP(d)
+	case VectorType, FrameType:
+		return true
+	case BoxedObjectType:
+		return RecordP(d)
+	default:
+		return false
+	}
+}
+
+// isFlat reports whether d is a list or vector of atoms, which can't need any labels unless
+// the list's cdrs loop back on themselves.  Checking this is much cheaper than the full walk.
+func isFlat(d *Data) bool {
+	if VectorP(d) {
+		for _, element := range VectorValue(d) {
+			if isLabelable(element) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// the cdrs loop if c, moving a cell at a time, catches up with slow, moving at half speed
+	slow := d
+	c := d
+	for i := 0; c != nil && c.Type == ConsCellType && NotNilP(c); i++ {
+		if isLabelable(Car(c)) {
+			return false
+		}
+		c = Cdr(c)
+		if i%2 == 1 {
+			slow = Cdr(slow)
+		}
+		if c == slow {
+			return false
+		}
+	}
+
+	// a dotted list's tail can be structure of its own
+	return d.Type == ConsCellType && !isLabelable(c)
+}
+
+// findSharedStructure walks d looking for structure that is reached more than once.  Only
+// cycles are reported unless all is true.  Returns nil if nothing needs a label.
+func findSharedStructure(d *Data, all bool) *printLabels {
+	if !isLabelable(d) || isFlat(d) {
+		return nil
+	}
+
+	const (
+		inProgress = iota
+		visited
+	)
+	state := make(map[unsafe.Pointer]int)
+	shared := make(map[unsafe.Pointer]bool)
+
+	var visit func(d *Data)
+	visit = func(d *Data) {
+		chain := make([]unsafe.Pointer, 0, 10)
+		for isLabelable(d) {
+			key := labelKey(d)
+			if s, seen := state[key]; seen {
+				if s == inProgress || all {
+					shared[key] = true
+				}
+				break
+			}
+			state[key] = inProgress
+			chain = append(chain, key)
+
+			if VectorP(d) {
+				for _, element := range VectorValue(d) {
+					visit(element)
+				}
+				break
+			}
+
+			if FrameP(d) {
+				for _, v := range FrameValue(d).Values() {
+					visit(v)
+				}
+				break
+			}
+
+			if RecordP(d) {
+				for _, v := range RecordValue(d).Values {
+					visit(v)
+				}
+				break
+			}
+
+			// walk down the cdr chain iteratively so long lists don't use up the stack
+			visit(Car(d))
+			d = Cdr(d)
+		}
+		for _, key := range chain {
+			state[key] = visited
+		}
+	}
+	visit(d)
+
+	if len(shared) == 0 {
+		return nil
+	}
+	return &printLabels{shared: shared, numbers: make(map[unsafe.Pointer]int)}
+}
+
 func String(d *Data) string {
+	return stringWithLabels(d, findSharedStructure(d, false))
+}
+
+// SharedString is like String but labels every shared substructure, not just cycles
+func SharedString(d *Data) string {
+	return stringWithLabels(d, findSharedStructure(d, true))
+}
+
+func stringWithLabels(d *Data, labels *printLabels) string {
 	if d == nil {
 		return "()"
 	}
 
+	if labels.isShared(d) {
+		if n, found := labels.numbers[labelKey(d)]; found {
+			return fmt.Sprintf("#%d#", n)
+		}
+		n := len(labels.numbers)
+		labels.numbers[labelKey(d)] = n
+		return fmt.Sprintf("#%d=%s", n, stringOfStructure(d, labels))
+	}
+
+	return stringOfStructure(d, labels)
+}
+
+func stringOfStructure(d *Data, labels *printLabels) string {
 	switch d.Type {
 	case ConsCellType:
 		{
@@ -942,9 +1371,9 @@ func String(d *Data) string {
 			}
 			var c *Data = d
 
-			contents := make([]string, 0, Length(d))
-			for NotNilP(c) && PairP(c) {
-				contents = append(contents, String(Car(c)))
+			contents := make([]string, 0, 10)
+			for NotNilP(c) && PairP(c) && (len(contents) == 0 || !labels.isShared(c)) {
+				contents = append(contents, stringWithLabels(Car(c), labels))
 				c = Cdr(c)
 			}
 			if NilP(c) {
@@ -958,7 +1387,7 @@ func String(d *Data) string {
 					return fmt.Sprintf("(%s)", strings.Join(contents, " "))
 				}
 			} else {
-				return fmt.Sprintf("(%s . %s)", strings.Join(contents, " "), String(c))
+				return fmt.Sprintf("(%s . %s)", strings.Join(contents, " "), stringWithLabels(c, labels))
 			}
 		}
 	case AlistType:
@@ -966,35 +1395,22 @@ func String(d *Data) string {
 			if NilP(d) {
 				return "()"
 			}
-			contents := make([]string, 0, Length(d))
-			for c := d; NotNilP(c); c = Cdr(c) {
-				contents = append(contents, String(Car(c)))
+			contents := make([]string, 0, 10)
+			c := d
+			for ; NotNilP(c) && (len(contents) == 0 || !labels.isShared(c)); c = Cdr(c) {
+				contents = append(contents, stringWithLabels(Car(c), labels))
+			}
+			if NotNilP(c) {
+				return fmt.Sprintf("(%s . %s)", strings.Join(contents, " "), stringWithLabels(c, labels))
 			}
 			return fmt.Sprintf("(%s)", strings.Join(contents, " "))
 		}
 	case AlistCellType:
-		return fmt.Sprintf("(%s . %s)", String(Car(d)), String(Cdr(d)))
+		return fmt.Sprintf("(%s . %s)", stringWithLabels(Car(d), labels), stringWithLabels(Cdr(d), labels))
 	case IntegerType:
 		return fmt.Sprintf("%d", IntegerValue(d))
 	case FloatType:
-		{
-			v := FloatValue(d)
-			if math.IsInf(float64(v), 0) {
-				sign := "+"
-				if math.Signbit(float64(v)) {
-					sign = "-"
-				}
-				return fmt.Sprintf("%sinf", sign)
-			}
-			if math.IsNaN(float64(v)) {
-				return "nan"
-			}
-			raw := fmt.Sprintf("%g", FloatValue(d))
-			if strings.ContainsRune(raw, '.') {
-				return raw
-			}
-			return fmt.Sprintf("%s.0", raw)
-		}
+		return FormatFloat(FloatValue(d), CurrentFloatFormat())
 	case BooleanType:
 		if BooleanValue(d) {
 			return "#t"
@@ -1002,7 +1418,7 @@ func String(d *Data) string {
 			return "#f"
 		}
 	case StringType:
-		return fmt.Sprintf(`"%s"`, escapeQuotes(StringValue(d)))
+		return fmt.Sprintf(`"%s"`, escapeString(StringValue(d)))
 	case SymbolType:
 		return StringValue(d)
 	case FunctionType:
@@ -1019,6 +1435,38 @@ func String(d *Data) string {
 				contents = append(contents, fmt.Sprintf("%d", b))
 			}
 			return fmt.Sprintf("[%s]", strings.Join(contents, " "))
+		} else if ObjectType(d) == "Bytevector" {
+			return bytevectorString(BytevectorValue(d))
+		} else if ObjectType(d) == "Decimal" {
+			return DecimalValue(d).String()
+		} else if ObjectType(d) == "BigInteger" {
+			return BigIntegerValue(d).String()
+		} else if ObjectType(d) == "Duration" {
+			return DurationValue(d).String()
+		} else if ObjectType(d) == "Array" {
+			return ArrayValue(d).String()
+		} else if ObjectType(d) == "HashTable" {
+			return fmt.Sprintf("<hash-table: %d entries>", HashTableValue(d).Count())
+		} else if ObjectType(d) == "Firmware" {
+			return fmt.Sprintf("<firmware: %d bytes in %d ranges>", FirmwareValue(d).Size(), len(FirmwareValue(d).Segments))
+		} else if ObjectType(d) == "Rope" {
+			return fmt.Sprintf(`"%s"`, escapeString(StringValue(d)))
+		} else if ObjectType(d) == "StringBuilder" {
+			return fmt.Sprintf("<string-builder: %d bytes>", StringBuilderValue(d).Len())
+		} else if ObjectType(d) == "SymbolMacro" {
+			return fmt.Sprintf("<symbol-macro: %s>", SymbolMacroValue(d).Name)
+		} else if ObjectType(d) == "Record" {
+			return RecordValue(d).stringWithLabels(labels)
+		} else if ObjectType(d) == "RecordType" {
+			return fmt.Sprintf("<record-type: %s>", RecordTypeValue(d).Name)
+		} else if ObjectType(d) == "Set" {
+			return fmt.Sprintf("<set: %d members>", SetValue(d).Count())
+		} else if ObjectType(d) == "Time" {
+			return TimeValue(d).Format(time.RFC3339Nano)
+		} else if ObjectType(d) == "Error" {
+			return fmt.Sprintf("<%s: %s>", ErrorObjectValue(d).Category, ErrorObjectValue(d).Message)
+		} else if ObjectType(d) == "CharSet" && CharSetValue(d).Name != "" {
+			return fmt.Sprintf("<%s>", CharSetValue(d).Name)
 		} else {
 			return fmt.Sprintf("<opaque Go object of type %s : 0x%x>", ObjectType(d), (*uint64)(ObjectValue(d)))
 		}
@@ -1034,7 +1482,11 @@ func String(d *Data) string {
 		pairs := make([]string, 0, len(frame.Data))
 		for _, key := range keys {
 			val := frame.Data[key]
-			var valString string = String(val)
+			var valString string = stringWithLabels(val, labels)
+			if (ListP(val) && NotNilP(val)) || (SymbolP(val) && !NakedP(val)) {
+				// quote values that would otherwise be evaluated when the frame is read back in
+				valString = fmt.Sprintf("'%s", valString)
+			}
 			pairs = append(pairs, fmt.Sprintf("%s %s", key, valString))
 		}
 		frame.Mutex.RUnlock()
@@ -1043,6 +1495,15 @@ func String(d *Data) string {
 		return fmt.Sprintf("<environment: %s>", EnvironmentValue(d).Name)
 	case PortType:
 		return fmt.Sprintf("<port: %s>", PortValue(d).Name())
+	case VectorType:
+		elements := VectorValue(d)
+		contents := make([]string, 0, len(elements))
+		for _, element := range elements {
+			contents = append(contents, stringWithLabels(element, labels))
+		}
+		return fmt.Sprintf("#(%s)", strings.Join(contents, " "))
+	case CharacterType:
+		return fmt.Sprintf("#\\%s", CharacterName(CharacterValue(d)))
 	}
 
 	return ""
@@ -1051,6 +1512,8 @@ func String(d *Data) string {
 func PrintString(d *Data) string {
 	if StringP(d) {
 		return StringValue(d)
+	} else if CharacterP(d) {
+		return string(CharacterValue(d))
 	} else {
 		return String(d)
 	}
@@ -1090,26 +1553,26 @@ func postProcessShortcuts(d *Data) *Data {
 
 func printDashes(indent int) {
 	for i := indent; i > 0; i -= 1 {
-		fmt.Print("-")
+		fmt.Fprint(TraceWriter(), "-")
 	}
 }
 
 func logEval(d *Data, env *SymbolTableFrame) {
-	if LispTrace && !DebugEvalInDebugRepl {
+	if lispTraceEnabled() && !DebugEvalInDebugRepl {
 		depth := env.Depth()
-		fmt.Printf("%3d: ", depth)
+		fmt.Fprintf(TraceWriter(), "%3d: ", depth)
 		printDashes(depth)
-		fmt.Printf("> %s\n", String(d))
+		fmt.Fprintf(TraceWriter(), "> %s\n", String(d))
 		EvalDepth += 1
 	}
 }
 
 func logResult(result *Data, env *SymbolTableFrame) {
-	if LispTrace && !DebugEvalInDebugRepl {
+	if lispTraceEnabled() && !DebugEvalInDebugRepl {
 		depth := env.Depth()
-		fmt.Printf("%3d: <", depth)
+		fmt.Fprintf(TraceWriter(), "%3d: <", depth)
 		printDashes(depth)
-		fmt.Printf(" %s\n", String(result))
+		fmt.Fprintf(TraceWriter(), " %s\n", String(result))
 	}
 }
 
@@ -1119,10 +1582,20 @@ func evalHelper(d *Data, env *SymbolTableFrame, needFunction bool) (result *Data
 	}
 
 	logEval(d, env)
+	emitEvalStarted(d, env)
+	// every return, including errors, has to be matched with the start.  Errors are reported
+	// as they were before the context of this evaluation is added.
+	var eventErr error
+	defer func() {
+		if eventErr == nil {
+			eventErr = err
+		}
+		emitEvalReturned(d, result, eventErr, env)
+	}()
 
 	if DebugSingleStep {
 		DebugSingleStep = false
-		DebugRepl(env)
+		DebugStep(d, env)
 	}
 
 	if DebugCurrentFrame != nil && env == DebugCurrentFrame.Previous {
@@ -1141,13 +1614,17 @@ func evalHelper(d *Data, env *SymbolTableFrame, needFunction bool) (result *Data
 					return EmptyCons(), nil
 				}
 
+				previousForm := env.startEvaluating(d)
+
 				var function *Data
 				function, err = evalHelper(Car(d), env, true)
 
 				if err != nil {
+					env.finishEvaluating(previousForm)
 					return
 				}
 				if NilP(function) {
+					env.finishEvaluating(previousForm)
 					err = errors.New(fmt.Sprintf("Nil when function or macro expected for %s.", String(Car(d))))
 					return
 				}
@@ -1158,9 +1635,13 @@ func evalHelper(d *Data, env *SymbolTableFrame, needFunction bool) (result *Data
 
 				args := Cdr(d)
 
+				emitApplyStarted(function, args, env)
 				result, err = Apply(function, args, env)
+				env.finishEvaluating(previousForm)
 				if err != nil {
-					err = errors.New(fmt.Sprintf("\nEvaling %s. %s", String(d), err))
+					eventErr = err
+					noteCallsite(err, d, env)
+					err = addErrorContext(err, fmt.Sprintf("\nEvaling %s. ", String(d)))
 					return
 				} else if DebugReturnValue != nil {
 					result = DebugReturnValue
@@ -1172,6 +1653,11 @@ func evalHelper(d *Data, env *SymbolTableFrame, needFunction bool) (result *Data
 				result = d
 			} else {
 				result = env.ValueOfWithFunctionSlotCheck(d, needFunction)
+				if SymbolMacroP(result) {
+					if result, err = expandSymbolMacro(SymbolMacroValue(result), env); err != nil {
+						return
+					}
+				}
 			}
 		default:
 			result = d
@@ -1188,21 +1674,27 @@ func Eval(d *Data, env *SymbolTableFrame) (result *Data, err error) {
 	return evalHelper(d, env, false)
 }
 
-func formatApply(function *Data, args *Data) string {
-	var fname string
+// functionName returns the name of a function, macro, or primitive, or "" for anything else
+func functionName(function *Data) string {
+	switch TypeOf(function) {
+	case FunctionType:
+		return FunctionValue(function).Name
+	case MacroType:
+		return MacroValue(function).Name
+	case PrimitiveType:
+		return PrimitiveValue(function).Name
+	default:
+		return ""
+	}
+}
 
+func formatApply(function *Data, args *Data) string {
 	if NilP(function) {
 		return "Trying to apply nil!"
 	}
 
-	switch function.Type {
-	case FunctionType:
-		fname = FunctionValue(function).Name
-	case MacroType:
-		fname = MacroValue(function).Name
-	case PrimitiveType:
-		fname = PrimitiveValue(function).Name
-	default:
+	fname := functionName(function)
+	if fname == "" {
 		return fmt.Sprintf("%s when function or macro expected for %s.", TypeName(TypeOf(function)), String(function))
 	}
 	return fmt.Sprintf("Apply %s to %s", fname, String(args))
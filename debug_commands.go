@@ -0,0 +1,304 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements the registry of debugger commands.
+
+package golisp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DebugCommand is a command typed into the debugger after Config.DebugCommandPrefix
+type DebugCommand struct {
+	// Name is what the command is typed as, e.g. "b" or "(+"
+	Name string
+
+	// Args describes the arguments for the help summary, e.g. "func" or "on/off"
+	Args string
+
+	// MinArgs is how many arguments have to be given
+	MinArgs int
+
+	// Help is the one line description shown by :?
+	Help string
+
+	// Complete, if set, returns the completions of the last, partial argument
+	Complete func(partial string, env *SymbolTableFrame) []string
+
+	// Run carries out the command, returning whether to leave the debugger
+	Run func(args []string, env *SymbolTableFrame) (leave bool)
+}
+
+var debugCommands = make(map[string]*DebugCommand)
+var debugCommandsMutex sync.RWMutex
+
+// RegisterDebugCommand adds command to the debugger, replacing any with the same name
+func RegisterDebugCommand(command *DebugCommand) {
+	debugCommandsMutex.Lock()
+	defer debugCommandsMutex.Unlock()
+	debugCommands[command.Name] = command
+}
+
+func DebugCommandNamed(name string) *DebugCommand {
+	debugCommandsMutex.RLock()
+	defer debugCommandsMutex.RUnlock()
+	return debugCommands[name]
+}
+
+// DebugCommands returns the registered commands ordered by name
+func DebugCommands() []*DebugCommand {
+	debugCommandsMutex.RLock()
+	defer debugCommandsMutex.RUnlock()
+	commands := make([]*DebugCommand, 0, len(debugCommands))
+	for _, command := range debugCommands {
+		commands = append(commands, command)
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+	return commands
+}
+
+// DebugHelp returns the command summary shown by :?
+func DebugHelp() string {
+	var help strings.Builder
+	help.WriteString("SteelSeries/GoLisp Debugger\n")
+	help.WriteString("---------------------------\n")
+	for _, command := range DebugCommands() {
+		usage := strings.TrimSpace(command.Name + " " + command.Args)
+		fmt.Fprintf(&help, "%s%-8s - %s\n", debugCommandPrefix(), usage, command.Help)
+	}
+	return help.String()
+}
+
+// runDebugCommand carries out a line typed into the debugger without its prefix, returning
+// whether to leave the debugger
+func runDebugCommand(line string, env *SymbolTableFrame) (leave bool) {
+	tokens := strings.Fields(line)
+	if len(tokens) == 0 {
+		tokens = []string{""}
+	}
+	command := DebugCommandNamed(tokens[0])
+	if command == nil {
+		fmt.Fprintf(OutputWriter(), "Unknown command '%s'. Use %s? for a summary.\n", tokens[0], debugCommandPrefix())
+		return false
+	}
+	args := tokens[1:]
+	if len(args) < command.MinArgs {
+		fmt.Fprintf(OutputWriter(), "Usage: %s%s %s\n", debugCommandPrefix(), command.Name, command.Args)
+		return false
+	}
+	return command.Run(args, env)
+}
+
+// DebugCompletions returns the possible completions of a partial debugger line: command
+// names, then whatever the command completes its arguments with, or names bound in env
+// for code to evaluate
+func DebugCompletions(line string, env *SymbolTableFrame) (completions []string) {
+	if !strings.HasPrefix(line, debugCommandPrefix()) {
+		start := strings.LastIndexAny(line, "( '\t") + 1
+		for _, name := range boundNamesWithPrefix(line[start:], env, false) {
+			completions = append(completions, line[:start]+name)
+		}
+		return
+	}
+
+	commandLine := strings.TrimPrefix(line, debugCommandPrefix())
+	separator := strings.Index(commandLine, " ")
+	if separator < 0 {
+		for _, command := range DebugCommands() {
+			if strings.HasPrefix(command.Name, commandLine) {
+				completions = append(completions, debugCommandPrefix()+command.Name)
+			}
+		}
+		return
+	}
+
+	command := DebugCommandNamed(commandLine[:separator])
+	if command == nil || command.Complete == nil {
+		return
+	}
+	start := strings.LastIndex(line, " ") + 1
+	for _, completion := range command.Complete(line[start:], env) {
+		completions = append(completions, line[:start]+completion)
+	}
+	return
+}
+
+// boundNamesWithPrefix returns the sorted names bound in env and the environments it is
+// nested in that start with prefix, optionally only those of functions
+func boundNamesWithPrefix(prefix string, env *SymbolTableFrame, functionsOnly bool) []string {
+	seen := make(map[string]bool)
+	for frame := env; frame != nil; frame = frame.Parent {
+		frame.Mutex.RLock()
+		for name, binding := range frame.Bindings {
+			if !strings.HasPrefix(name, prefix) || seen[name] {
+				continue
+			}
+			if functionsOnly && TypeOf(binding.Value()) != FunctionType {
+				continue
+			}
+			seen[name] = true
+		}
+		frame.Mutex.RUnlock()
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func completeFunctionName(partial string, env *SymbolTableFrame) []string {
+	return boundNamesWithPrefix(partial, env, true)
+}
+
+func completeBoundName(partial string, env *SymbolTableFrame) []string {
+	return boundNamesWithPrefix(partial, env, false)
+}
+
+// dumpLimitsArgs reads the optional depth and width given to a dumping command, which
+// default to Config.DumpLimits
+func dumpLimitsArgs(args []string) (limits DumpLimits, ok bool) {
+	limits = Config.DumpLimits
+	for i, limit := range []*int{&limits.Depth, &limits.Width} {
+		if i >= len(args) {
+			break
+		}
+		if _, err := fmt.Sscanf(args[i], "%d", limit); err != nil {
+			fmt.Fprintf(OutputWriter(), "Bad limit: '%s'. %s\n", args[i], err)
+			return limits, false
+		}
+	}
+	return limits, true
+}
+
+func completeOnOff(partial string, env *SymbolTableFrame) (completions []string) {
+	for _, state := range []string{"on", "off"} {
+		if strings.HasPrefix(state, partial) {
+			completions = append(completions, state)
+		}
+	}
+	return
+}
+
+func init() {
+	RegisterDebugCommand(&DebugCommand{Name: "(+", Args: "func", MinArgs: 1, Help: "debug on entry to func", Complete: completeFunctionName,
+		Run: func(args []string, env *SymbolTableFrame) bool {
+			if f := funcOrNil(args[0], env); f != nil {
+				DebugOnEntry.Add(FunctionValue(f).Name)
+			}
+			return false
+		}})
+	RegisterDebugCommand(&DebugCommand{Name: "(-", Args: "func", MinArgs: 1, Help: "don't debug on entry to func", Complete: completeFunctionName,
+		Run: func(args []string, env *SymbolTableFrame) bool {
+			if f := funcOrNil(args[0], env); f != nil && DebugOnEntry.Has(FunctionValue(f).Name) {
+				DebugOnEntry.Remove(FunctionValue(f).Name)
+			}
+			return false
+		}})
+	RegisterDebugCommand(&DebugCommand{Name: "(", Help: "show functions marked as debug on entry",
+		Run: func(args []string, env *SymbolTableFrame) bool {
+			for _, f := range DebugOnEntry.List() {
+				fmt.Fprintf(OutputWriter(), "%s\n", f)
+			}
+			return false
+		}})
+	RegisterDebugCommand(&DebugCommand{Name: "?", Help: "show this command summary",
+		Run: func(args []string, env *SymbolTableFrame) bool {
+			fmt.Fprintf(OutputWriter(), "%s\n", DebugHelp())
+			return false
+		}})
+	RegisterDebugCommand(&DebugCommand{Name: "b", Args: "[depth]", Help: "show the environment stack",
+		Run: func(args []string, env *SymbolTableFrame) bool {
+			if limits, ok := dumpLimitsArgs(args); ok {
+				env.DumpHeadersWithLimits(limits)
+				fmt.Fprintf(OutputWriter(), "\n")
+			}
+			return false
+		}})
+	RegisterDebugCommand(&DebugCommand{Name: "c", Help: "continue, exiting the debugger",
+		Run: func(args []string, env *SymbolTableFrame) bool {
+			debugContinue()
+			return true
+		}})
+	RegisterDebugCommand(&DebugCommand{Name: "d", Args: "[depth [width]]", Help: "do a full dump of the environment stack",
+		Run: func(args []string, env *SymbolTableFrame) bool {
+			if limits, ok := dumpLimitsArgs(args); ok {
+				env.DumpWithLimits(limits)
+			}
+			return false
+		}})
+	RegisterDebugCommand(&DebugCommand{Name: "e", Args: "on/off", MinArgs: 1, Help: "Enable/disable debug on error", Complete: completeOnOff,
+		Run: func(args []string, env *SymbolTableFrame) bool {
+			if ok, state := processState(args[0]); ok {
+				Config.DebugOnError = state
+				DebugOnError = false
+			}
+			return false
+		}})
+	RegisterDebugCommand(&DebugCommand{Name: "f", Args: "frame#", MinArgs: 1, Help: "do a full dump of a single environment frame",
+		Run: func(args []string, env *SymbolTableFrame) bool {
+			var fnum int
+			if _, err := fmt.Sscanf(args[0], "%d", &fnum); err != nil {
+				fmt.Fprintf(OutputWriter(), "Bad frame number: '%s'. %s\n", args[0], err)
+			} else {
+				env.DumpSingleFrame(fnum)
+			}
+			return false
+		}})
+	RegisterDebugCommand(&DebugCommand{Name: "v", Args: "name [frame#]", MinArgs: 1, Help: "show the whole value of name, however long", Complete: completeBoundName,
+		Run: func(args []string, env *SymbolTableFrame) bool {
+			var fnum int
+			if len(args) > 1 {
+				if _, err := fmt.Sscanf(args[1], "%d", &fnum); err != nil {
+					fmt.Fprintf(OutputWriter(), "Bad frame number: '%s'. %s\n", args[1], err)
+					return false
+				}
+			}
+			env.DumpBinding(args[0], fnum)
+			return false
+		}})
+	RegisterDebugCommand(&DebugCommand{Name: "q", Help: "quit GoLisp",
+		Run: func(args []string, env *SymbolTableFrame) bool {
+			QuitImpl(nil, nil)
+			return true
+		}})
+	RegisterDebugCommand(&DebugCommand{Name: "r", Args: "sexpr", MinArgs: 1, Help: "return from the current evaluation with the specified value",
+		Run: func(args []string, env *SymbolTableFrame) bool {
+			d, err := debugEval(strings.Join(args, " "), env)
+			if err != nil {
+				fmt.Fprintf(OutputWriter(), "Error in evaluation: %s\n", err)
+				return false
+			}
+			DebugReturnValue = d
+			debugContinue()
+			return true
+		}})
+	RegisterDebugCommand(&DebugCommand{Name: "s", Help: "single step (run to the next evaluation)",
+		Run: func(args []string, env *SymbolTableFrame) bool {
+			debugStep()
+			return true
+		}})
+	RegisterDebugCommand(&DebugCommand{Name: "t", Args: "on/off", MinArgs: 1, Help: "Enable/disable tracing", Complete: completeOnOff,
+		Run: func(args []string, env *SymbolTableFrame) bool {
+			if ok, state := processState(args[0]); ok {
+				Config.LispTrace = state
+				LispTrace = false
+			}
+			return false
+		}})
+	RegisterDebugCommand(&DebugCommand{Name: "u", Help: "continue until the enclosing environment frame is returned to",
+		Run: func(args []string, env *SymbolTableFrame) bool {
+			if debugUntilReturn(env) {
+				return true
+			}
+			fmt.Fprintf(OutputWriter(), "Already at top frame.\n")
+			return false
+		}})
+}
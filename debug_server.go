@@ -0,0 +1,446 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements a web interface to the debugger.
+
+package golisp
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// debugServer serves the debugger over HTTP.  While it runs, anything that would stop in
+// DebugRepl pauses until a continue, step, or up command arrives from the browser.
+//
+// The server only listens on loopback addresses, and every request has to carry the token
+// made when it started, either as a token query parameter or an X-Debug-Token header, so
+// other web pages the browser has open can't drive it.  Expressions typed into it are
+// evaluated in a restricted environment.
+type debugServer struct {
+	listener net.Listener
+	token    string
+	mutex    sync.Mutex
+	paused   *SymbolTableFrame
+	commands chan debugCommand // made afresh for each pause
+	resumed  chan struct{}     // closed when that pause ends
+}
+
+type debugCommand struct {
+	name  string
+	reply chan error
+}
+
+var debugServers = struct {
+	sync.Mutex
+	active *debugServer
+}{}
+
+func currentDebugServer() *debugServer {
+	debugServers.Lock()
+	defer debugServers.Unlock()
+	return debugServers.active
+}
+
+// loopbackHost is whether host, a name or IP address, can only be reached from this machine
+func loopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// StartDebugServer serves the debugger's web interface at addr, e.g. "localhost:7070", and
+// returns the address it is listening on.  addr has to be a loopback address.
+func StartDebugServer(addr string) (string, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if !loopbackHost(host) {
+		return "", fmt.Errorf("The debug server can only listen on a loopback address, not %s", addr)
+	}
+
+	debugServers.Lock()
+	defer debugServers.Unlock()
+	if debugServers.active != nil {
+		return "", errors.New("The debug server is already running")
+	}
+	server, err := newDebugServer()
+	if err != nil {
+		return "", err
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", ioError(err)
+	}
+	server.listener = listener
+	debugServers.active = server
+	go http.Serve(listener, server.handler())
+	return listener.Addr().String(), nil
+}
+
+// StopDebugServer stops serving the debugger, letting any paused evaluation continue
+func StopDebugServer() {
+	debugServers.Lock()
+	server := debugServers.active
+	debugServers.active = nil
+	debugServers.Unlock()
+	if server != nil {
+		server.listener.Close()
+		server.sendCommand("continue")
+	}
+}
+
+// DebugServerAddress returns the address the debug server is listening on, or "" if it
+// isn't running
+func DebugServerAddress() string {
+	if server := currentDebugServer(); server != nil {
+		return server.listener.Addr().String()
+	}
+	return ""
+}
+
+// DebugServerURL returns the URL to open the debugger at, including its token, or "" if
+// the debug server isn't running
+func DebugServerURL() string {
+	if server := currentDebugServer(); server != nil {
+		return fmt.Sprintf("http://%s/?token=%s", server.listener.Addr(), server.token)
+	}
+	return ""
+}
+
+func newDebugServer() (*debugServer, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	return &debugServer{token: hex.EncodeToString(token)}, nil
+}
+
+func (self *debugServer) pausedEnv() *SymbolTableFrame {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.paused
+}
+
+// pause blocks evaluation in env until a command to carry on arrives.  Commands are sent
+// on a channel of this pause's own, so one that arrives too late can't be taken by the next.
+func (self *debugServer) pause(env *SymbolTableFrame) {
+	commands := make(chan debugCommand)
+	resumed := make(chan struct{})
+	self.mutex.Lock()
+	self.paused, self.commands, self.resumed = env, commands, resumed
+	self.mutex.Unlock()
+	defer func() {
+		self.mutex.Lock()
+		self.paused, self.commands, self.resumed = nil, nil, nil
+		self.mutex.Unlock()
+		close(resumed)
+	}()
+
+	for command := range commands {
+		switch command.name {
+		case "continue":
+			debugContinue()
+			command.reply <- nil
+			return
+		case "step":
+			debugStep()
+			command.reply <- nil
+			return
+		case "up":
+			if debugUntilReturn(env) {
+				command.reply <- nil
+				return
+			}
+			command.reply <- errors.New("Already at top frame.")
+		}
+	}
+}
+
+// sendCommand passes a command to the paused evaluation, if there is one, giving up if it
+// carries on before taking the command
+func (self *debugServer) sendCommand(name string) error {
+	self.mutex.Lock()
+	commands, resumed := self.commands, self.resumed
+	self.mutex.Unlock()
+	if commands == nil {
+		return errors.New("Not paused.")
+	}
+
+	command := debugCommand{name: name, reply: make(chan error)}
+	select {
+	case commands <- command:
+		return <-command.reply
+	case <-resumed:
+		return errors.New("Not paused.")
+	}
+}
+
+type debugFrame struct {
+	Number   int               `json:"frame"`
+	Name     string            `json:"name"`
+	Code     string            `json:"code"`
+	Bindings map[string]string `json:"bindings"`
+}
+
+// debugStack describes the frames from env out, leaving out primitives as dump does
+func debugStack(env *SymbolTableFrame) []debugFrame {
+	frames := make([]debugFrame, 0)
+	for frame := env; frame != nil; frame = frame.Previous {
+		bindings := make(map[string]string)
+		frame.Mutex.RLock()
+		for name, b := range frame.Bindings {
+			if v := b.Value(); v == nil || TypeOf(v) != PrimitiveType {
+				bindings[name] = String(v)
+			}
+		}
+		frame.Mutex.RUnlock()
+		frames = append(frames, debugFrame{Number: len(frames), Name: frame.Name, Code: frame.CurrentCodeString(), Bindings: bindings})
+	}
+	return frames
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// allowed is whether r comes from the debugger's own page: it has the token, and neither
+// its Host nor its Origin names anything but this machine, which stops DNS rebinding
+func (self *debugServer) allowed(r *http.Request) bool {
+	token := r.Header.Get("X-Debug-Token")
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(self.token)) != 1 {
+		return false
+	}
+	if host, _, err := net.SplitHostPort(r.Host); err != nil || !loopbackHost(host) {
+		return false
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		originURL, err := url.Parse(origin)
+		if err != nil || !loopbackHost(originURL.Hostname()) {
+			return false
+		}
+	}
+	return true
+}
+
+// restrictedEnv is where expressions from the browser are evaluated: below env, so its
+// bindings can be seen, but without access to restricted primitives
+func restrictedEnv(env *SymbolTableFrame) *SymbolTableFrame {
+	restricted := NewSymbolTableFrameBelow(env, "debug-server")
+	restricted.IsRestricted = true
+	return restricted
+}
+
+func (self *debugServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	checked := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !self.allowed(r) {
+			writeJSONError(w, http.StatusForbidden, errors.New("Forbidden"))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, debugPage)
+	})
+
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		status := map[string]interface{}{"paused": false}
+		if env := self.pausedEnv(); env != nil {
+			status["paused"] = true
+			status["code"] = env.CurrentCodeString()
+		}
+		writeJSON(w, http.StatusOK, status)
+	})
+
+	mux.HandleFunc("/stack", func(w http.ResponseWriter, r *http.Request) {
+		env := self.pausedEnv()
+		if env == nil {
+			env = Global
+		}
+		writeJSON(w, http.StatusOK, debugStack(env))
+	})
+
+	mux.HandleFunc("/breakpoints", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			name := r.FormValue("name")
+			switch r.FormValue("action") {
+			case "add":
+				f := Global.ValueOf(Intern(name))
+				if TypeOf(f) != FunctionType {
+					writeJSONError(w, http.StatusBadRequest, fmt.Errorf("No such function: %s", name))
+					return
+				}
+				DebugOnEntry.Add(FunctionValue(f).Name)
+			case "remove":
+				DebugOnEntry.Remove(name)
+			default:
+				writeJSONError(w, http.StatusBadRequest, errors.New("action has to be add or remove"))
+				return
+			}
+		}
+		names := make([]string, 0)
+		for _, name := range DebugOnEntry.List() {
+			names = append(names, name.(string))
+		}
+		sort.Strings(names)
+		writeJSON(w, http.StatusOK, names)
+	})
+
+	mux.HandleFunc("/eval", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			writeJSONError(w, http.StatusMethodNotAllowed, errors.New("eval needs a POST"))
+			return
+		}
+		env := self.pausedEnv()
+		if env == nil {
+			env = Global
+		}
+		result, err := debugEval(r.FormValue("expr"), restrictedEnv(env))
+		if err != nil {
+			writeJSONError(w, http.StatusOK, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"result": String(result)})
+	})
+
+	for _, name := range []string{"continue", "step", "up"} {
+		command := name
+		mux.HandleFunc("/"+command, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "POST" {
+				writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s needs a POST", command))
+				return
+			}
+			if err := self.sendCommand(command); err != nil {
+				writeJSONError(w, http.StatusConflict, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+		})
+	}
+
+	return checked
+}
+
+const debugPage = `<!DOCTYPE html>
+<html>
+<head>
+<title>GoLisp Debugger</title>
+<style>
+body { font-family: monospace; margin: 1em; }
+pre { background: #f4f4f4; padding: 0.5em; }
+.frame { margin-bottom: 1em; }
+</style>
+</head>
+<body>
+<h1>GoLisp Debugger</h1>
+<p id="status"></p>
+<button onclick="command('continue')">Continue</button>
+<button onclick="command('step')">Step</button>
+<button onclick="command('up')">Up</button>
+<h2>Evaluate</h2>
+<form onsubmit="evaluate(); return false;">
+<input id="expr" size="60"> <input type="submit" value="Eval">
+</form>
+<pre id="result"></pre>
+<h2>Breakpoints</h2>
+<form onsubmit="breakpoint('add', document.getElementById('function').value); return false;">
+<input id="function" placeholder="function name"> <input type="submit" value="Break on entry">
+</form>
+<ul id="breakpoints"></ul>
+<h2>Environment stack</h2>
+<div id="stack"></div>
+<script>
+var token = new URLSearchParams(location.search).get('token');
+function get(path) {
+  return fetch(path, {headers: {'X-Debug-Token': token}}).then(function (r) { return r.json(); });
+}
+function post(path, params) {
+  return fetch(path, {method: 'POST', headers: {'X-Debug-Token': token}, body: new URLSearchParams(params)}).then(function (r) { return r.json(); });
+}
+function text(s) { return document.createTextNode(s); }
+function refresh() {
+  get('/status').then(function (s) {
+    document.getElementById('status').textContent = s.paused ? 'Paused at: ' + s.code : 'Running';
+  });
+  get('/stack').then(function (frames) {
+    var stack = document.getElementById('stack');
+    stack.innerHTML = '';
+    frames.forEach(function (f) {
+      var div = document.createElement('div');
+      div.className = 'frame';
+      var pre = document.createElement('pre');
+      var lines = ['Frame ' + f.frame + ': ' + f.name + ' - ' + f.code];
+      Object.keys(f.bindings).sort().forEach(function (k) { lines.push('   ' + k + ' => ' + f.bindings[k]); });
+      pre.appendChild(text(lines.join('\n')));
+      div.appendChild(pre);
+      stack.appendChild(div);
+    });
+  });
+  get('/breakpoints').then(showBreakpoints);
+}
+function showBreakpoints(names) {
+  var list = document.getElementById('breakpoints');
+  list.innerHTML = '';
+  names.forEach(function (n) {
+    var item = document.createElement('li');
+    var remove = document.createElement('button');
+    remove.textContent = 'remove';
+    remove.onclick = function () { breakpoint('remove', n); };
+    item.appendChild(text(n + ' '));
+    item.appendChild(remove);
+    list.appendChild(item);
+  });
+}
+function breakpoint(action, name) {
+  post('/breakpoints', {action: action, name: name}).then(function (r) {
+    if (r.error) { alert(r.error); } else { showBreakpoints(r); }
+  });
+}
+function command(name) {
+  post('/' + name, {}).then(function (r) {
+    if (r.error) { document.getElementById('result').textContent = r.error; }
+    setTimeout(refresh, 100);
+  });
+}
+function evaluate() {
+  post('/eval', {expr: document.getElementById('expr').value}).then(function (r) {
+    document.getElementById('result').textContent = r.error ? 'Error: ' + r.error : '==> ' + r.result;
+    refresh();
+  });
+}
+refresh();
+setInterval(refresh, 2000);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,208 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests the web interface to the debugger.
+
+package golisp
+
+import (
+	"encoding/json"
+	. "gopkg.in/check.v1"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type DebugServerSuite struct {
+	base  string
+	token string
+}
+
+var _ = Suite(&DebugServerSuite{})
+
+func (s *DebugServerSuite) SetUpTest(c *C) {
+	address, err := StartDebugServer("127.0.0.1:0")
+	c.Assert(err, IsNil)
+	s.base = "http://" + address
+	debuggerURL, err := url.Parse(DebugServerURL())
+	c.Assert(err, IsNil)
+	s.token = debuggerURL.Query().Get("token")
+	c.Assert(s.token, Not(Equals), "")
+}
+
+func (s *DebugServerSuite) TearDownTest(c *C) {
+	DebugOnEntry.Remove("debug-server-test-fn")
+	StopDebugServer()
+}
+
+func (s *DebugServerSuite) do(c *C, request *http.Request, value interface{}) int {
+	response, err := http.DefaultClient.Do(request)
+	c.Assert(err, IsNil)
+	defer response.Body.Close()
+	c.Assert(json.NewDecoder(response.Body).Decode(value), IsNil)
+	return response.StatusCode
+}
+
+func (s *DebugServerSuite) get(c *C, path string, value interface{}) {
+	request, err := http.NewRequest("GET", s.base+path, nil)
+	c.Assert(err, IsNil)
+	request.Header.Set("X-Debug-Token", s.token)
+	c.Assert(s.do(c, request, value), Equals, http.StatusOK)
+}
+
+func (s *DebugServerSuite) post(c *C, path string, form url.Values, value interface{}) int {
+	request, err := http.NewRequest("POST", s.base+path, strings.NewReader(form.Encode()))
+	c.Assert(err, IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("X-Debug-Token", s.token)
+	return s.do(c, request, value)
+}
+
+func (s *DebugServerSuite) TestOnlyLoopback(c *C) {
+	StopDebugServer()
+	_, err := StartDebugServer("0.0.0.0:0")
+	c.Assert(err, NotNil)
+	_, err = StartDebugServer(":0")
+	c.Assert(err, NotNil)
+}
+
+func (s *DebugServerSuite) TestNeedsToken(c *C) {
+	var result map[string]string
+	response, err := http.PostForm(s.base+"/eval", url.Values{"expr": {"(+ 1 2)"}})
+	c.Assert(err, IsNil)
+	defer response.Body.Close()
+	c.Assert(response.StatusCode, Equals, http.StatusForbidden)
+
+	request, err := http.NewRequest("GET", s.base+"/status?token=wrong", nil)
+	c.Assert(err, IsNil)
+	c.Assert(s.do(c, request, &result), Equals, http.StatusForbidden)
+
+	request, err = http.NewRequest("GET", s.base+"/status?token="+s.token, nil)
+	c.Assert(err, IsNil)
+	var status map[string]interface{}
+	c.Assert(s.do(c, request, &status), Equals, http.StatusOK)
+}
+
+func (s *DebugServerSuite) TestRejectsOtherHostsAndOrigins(c *C) {
+	var result map[string]string
+	request, err := http.NewRequest("GET", s.base+"/status", nil)
+	c.Assert(err, IsNil)
+	request.Header.Set("X-Debug-Token", s.token)
+	request.Host = "attacker.example:80"
+	c.Assert(s.do(c, request, &result), Equals, http.StatusForbidden)
+
+	request, err = http.NewRequest("GET", s.base+"/status", nil)
+	c.Assert(err, IsNil)
+	request.Header.Set("X-Debug-Token", s.token)
+	request.Header.Set("Origin", "http://attacker.example")
+	c.Assert(s.do(c, request, &result), Equals, http.StatusForbidden)
+}
+
+func (s *DebugServerSuite) TestEvalIsRestricted(c *C) {
+	var result map[string]string
+	s.post(c, "/eval", url.Values{"expr": {`(exec "true")`}}, &result)
+	c.Assert(result["error"], Matches, "(?s).*restricted.*")
+}
+
+func (s *DebugServerSuite) TestOnlyOneServer(c *C) {
+	_, err := StartDebugServer("127.0.0.1:0")
+	c.Assert(err, NotNil)
+}
+
+func (s *DebugServerSuite) TestEvalWhileRunning(c *C) {
+	var result map[string]string
+	s.post(c, "/eval", url.Values{"expr": {"(+ 1 2)"}}, &result)
+	c.Assert(result["result"], Equals, "3")
+	var failure map[string]string
+	s.post(c, "/eval", url.Values{"expr": {`(error "boom")`}}, &failure)
+	c.Assert(failure["error"], Matches, "(?s).*boom.*")
+}
+
+func (s *DebugServerSuite) TestCommandsNeedAPause(c *C) {
+	var result map[string]string
+	c.Assert(s.post(c, "/continue", nil, &result), Equals, http.StatusConflict)
+}
+
+func (s *DebugServerSuite) TestRacingCommands(c *C) {
+	_, err := ParseAndEval(`(define (debug-server-test-fn x) (* x 2))`)
+	c.Assert(err, IsNil)
+	DebugOnEntry.Add("debug-server-test-fn")
+	server := currentDebugServer()
+
+	results := make(chan *Data)
+	go func() {
+		result, _ := ParseAndEval(`(debug-server-test-fn 5)`)
+		results <- result
+	}()
+	for i := 0; i < 200 && server.pausedEnv() == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Assert(server.pausedEnv(), NotNil)
+
+	// only one of several continues can be taken, and the others mustn't be left waiting
+	errs := make(chan error)
+	start := make(chan bool)
+	for i := 0; i < 10; i++ {
+		go func() {
+			<-start
+			errs <- server.sendCommand("continue")
+		}()
+	}
+	close(start)
+	taken := 0
+	for i := 0; i < 10; i++ {
+		select {
+		case err := <-errs:
+			if err == nil {
+				taken++
+			}
+		case <-time.After(5 * time.Second):
+			c.Fatal("a continue was left waiting")
+		}
+	}
+	c.Assert(taken, Equals, 1)
+	c.Assert(IntegerValue(<-results), Equals, int64(10))
+}
+
+func (s *DebugServerSuite) TestBreakpoints(c *C) {
+	_, err := ParseAndEval(`(define (debug-server-test-fn x) (* x 2))`)
+	c.Assert(err, IsNil)
+
+	var names []string
+	c.Assert(s.post(c, "/breakpoints", url.Values{"action": {"add"}, "name": {"debug-server-test-fn"}}, &names), Equals, http.StatusOK)
+	c.Assert(names, DeepEquals, []string{"debug-server-test-fn"})
+
+	results := make(chan *Data)
+	go func() {
+		result, _ := ParseAndEval(`(let ((debug-server-test-y 5)) (debug-server-test-fn debug-server-test-y))`)
+		results <- result
+	}()
+
+	var status map[string]interface{}
+	for i := 0; i < 200; i++ {
+		s.get(c, "/status", &status)
+		if status["paused"] == true {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Assert(status["paused"], Equals, true)
+
+	var eval map[string]string
+	s.post(c, "/eval", url.Values{"expr": {"debug-server-test-y"}}, &eval)
+	c.Assert(eval["result"], Equals, "5")
+
+	var stack []debugFrame
+	s.get(c, "/stack", &stack)
+	c.Assert(stack[0].Bindings["debug-server-test-y"], Equals, "5")
+
+	var ok map[string]bool
+	c.Assert(s.post(c, "/continue", nil, &ok), Equals, http.StatusOK)
+	c.Assert(IntegerValue(<-results), Equals, int64(10))
+
+	c.Assert(s.post(c, "/breakpoints", url.Values{"action": {"remove"}, "name": {"debug-server-test-fn"}}, &names), Equals, http.StatusOK)
+	c.Assert(names, DeepEquals, []string{})
+}
@@ -0,0 +1,102 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests the debugger's display of the code being stepped through, and its commands.
+
+package golisp
+
+import (
+	"bytes"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type DebugSuite struct {
+	env *SymbolTableFrame
+}
+
+var _ = Suite(&DebugSuite{})
+
+func (s *DebugSuite) SetUpTest(c *C) {
+	s.env = NewSymbolTableFrameBelow(Global, "debug-test")
+}
+
+func (s *DebugSuite) parse(c *C, src string) *Data {
+	sexpr, _, err := parseExpression(NewTokenizerFromFileContents(src, "step.lsp"))
+	c.Assert(err, IsNil)
+	return sexpr
+}
+
+func (s *DebugSuite) TestUnderlinesTheSubexpression(c *C) {
+	form := s.parse(c, "\n\n(+ (car x) 1)")
+	s.env.startEvaluating(form)
+	c.Assert(stepDescription(Cadr(form), s.env), Equals, "step.lsp:3: (+ (car x) 1)\n               ^^^^^^^\n")
+	c.Assert(stepDescription(Caddr(form), s.env), Equals, "step.lsp:3: (+ (car x) 1)\n                       ^\n")
+}
+
+func (s *DebugSuite) TestFindsNestedSubexpressions(c *C) {
+	form := s.parse(c, "(cond ((null? x) 'none) (else x))")
+	s.env.startEvaluating(form)
+	c.Assert(stepDescription(Car(Cadr(form)), s.env), Equals, "step.lsp:1: (cond ((null? x) 'none) (else x))\n                   ^^^^^^^^^\n")
+}
+
+func (s *DebugSuite) TestShowsTheFormAloneOutsideAnyEvaluation(c *C) {
+	form := s.parse(c, "(list 1 2)")
+	c.Assert(stepDescription(form, s.env), Equals, "step.lsp:1: (list 1 2)\n")
+	c.Assert(stepDescription(Intern("x"), s.env), Equals, "x\n")
+}
+
+func (s *DebugSuite) TestHelpIsGeneratedFromTheCommands(c *C) {
+	help := DebugHelp()
+	c.Assert(strings.HasPrefix(help, "SteelSeries/GoLisp Debugger\n"), Equals, true)
+	c.Assert(strings.Contains(help, ":(+ func  - debug on entry to func\n"), Equals, true)
+	c.Assert(strings.Contains(help, ":e on/off - Enable/disable debug on error\n"), Equals, true)
+	c.Assert(strings.Contains(help, ":c        - continue, exiting the debugger\n"), Equals, true)
+}
+
+func (s *DebugSuite) TestRunningCommands(c *C) {
+	var output bytes.Buffer
+	SetOutputWriter(&output)
+	defer SetOutputWriter(nil)
+	defer func() { Config.LispTrace = false }()
+
+	c.Assert(runDebugCommand("t on", s.env), Equals, false)
+	c.Assert(Config.LispTrace, Equals, true)
+	c.Assert(runDebugCommand("t", s.env), Equals, false)
+	c.Assert(output.String(), Equals, "Usage: :t on/off\n")
+	output.Reset()
+	c.Assert(runDebugCommand("zz", s.env), Equals, false)
+	c.Assert(output.String(), Equals, "Unknown command 'zz'. Use :? for a summary.\n")
+}
+
+func (s *DebugSuite) TestRegisteringACommand(c *C) {
+	var given []string
+	RegisterDebugCommand(&DebugCommand{Name: "debug-test", Args: "x y", MinArgs: 2, Help: "a test command",
+		Run: func(args []string, env *SymbolTableFrame) bool {
+			given = args
+			return true
+		}})
+	defer func() {
+		debugCommandsMutex.Lock()
+		delete(debugCommands, "debug-test")
+		debugCommandsMutex.Unlock()
+	}()
+
+	c.Assert(runDebugCommand("debug-test 1  2", s.env), Equals, true)
+	c.Assert(given, DeepEquals, []string{"1", "2"})
+	c.Assert(strings.Contains(DebugHelp(), ":debug-test x y - a test command\n"), Equals, true)
+}
+
+func (s *DebugSuite) TestCompletion(c *C) {
+	_, err := ParseAndEvalAllInEnvironment("(define (debug-test-alpha) 1) (define (debug-test-beta) 2) (define debug-test-value 3)", s.env)
+	c.Assert(err, IsNil)
+
+	c.Assert(DebugCompletions(":(", s.env), DeepEquals, []string{":(", ":(+", ":(-"})
+	c.Assert(DebugCompletions(":e o", s.env), DeepEquals, []string{":e on", ":e off"})
+	c.Assert(DebugCompletions(":(+ debug-test-", s.env), DeepEquals, []string{":(+ debug-test-alpha", ":(+ debug-test-beta"})
+	c.Assert(DebugCompletions(":b x", s.env), HasLen, 0)
+	c.Assert(DebugCompletions("(+ debug-test-v", s.env), DeepEquals, []string{"(+ debug-test-value"})
+}
@@ -0,0 +1,232 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements arbitrary precision decimal numbers.
+
+package golisp
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an exact decimal number: Unscaled * 10^-Scale.  Decimals are immutable; every
+// operation returns a new one.  Scale is never negative.
+type Decimal struct {
+	Unscaled *big.Int
+	Scale    int
+}
+
+type RoundingMode int
+
+const (
+	RoundHalfEven RoundingMode = iota
+	RoundHalfUp
+	RoundDown
+	RoundUp
+	RoundFloor
+	RoundCeiling
+)
+
+var roundingModes = map[string]RoundingMode{
+	"half-even": RoundHalfEven,
+	"half-up":   RoundHalfUp,
+	"down":      RoundDown,
+	"up":        RoundUp,
+	"floor":     RoundFloor,
+	"ceiling":   RoundCeiling,
+}
+
+var bigTen = big.NewInt(10)
+
+// maxDecimalScale bounds the scales and exponents that are accepted, as the time and
+// memory needed grow with them
+const maxDecimalScale = 10000
+
+func powerOfTen(n int) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+func DecimalFromInt(i int64) *Decimal {
+	return &Decimal{Unscaled: big.NewInt(i), Scale: 0}
+}
+
+// DecimalFromFloat uses the shortest decimal representation of f, so 0.1 becomes exactly 0.1
+func DecimalFromFloat(f float32) (*Decimal, error) {
+	return ParseDecimal(strconv.FormatFloat(float64(f), 'f', -1, 32))
+}
+
+// ParseDecimal reads numbers such as "12", "-0.125" and "1.5e3"
+func ParseDecimal(s string) (*Decimal, error) {
+	text := strings.TrimSpace(s)
+	exponent := 0
+	if i := strings.IndexAny(text, "eE"); i >= 0 {
+		e, err := strconv.Atoi(text[i+1:])
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("%q is not a decimal number", s))
+		}
+		if e > maxDecimalScale || e < -maxDecimalScale {
+			return nil, errors.New(fmt.Sprintf("%q has an exponent beyond %d", s, maxDecimalScale))
+		}
+		exponent = e
+		text = text[:i]
+	}
+
+	digits := text
+	scale := 0
+	if i := strings.Index(text, "."); i >= 0 {
+		digits = text[:i] + text[i+1:]
+		scale = len(text) - i - 1
+	}
+
+	unscaled, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("%q is not a decimal number", s))
+	}
+
+	scale -= exponent
+	if scale < 0 {
+		unscaled.Mul(unscaled, powerOfTen(-scale))
+		scale = 0
+	}
+	return &Decimal{Unscaled: unscaled, Scale: scale}, nil
+}
+
+func (self *Decimal) String() string {
+	digits := new(big.Int).Abs(self.Unscaled).String()
+	sign := ""
+	if self.Unscaled.Sign() < 0 {
+		sign = "-"
+	}
+	if self.Scale == 0 {
+		return sign + digits
+	}
+	if len(digits) <= self.Scale {
+		digits = strings.Repeat("0", self.Scale-len(digits)+1) + digits
+	}
+	point := len(digits) - self.Scale
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+func (self *Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(self.String(), 64)
+	return f
+}
+
+// rescaled returns the unscaled value of self expressed at a larger scale
+func (self *Decimal) rescaled(scale int) *big.Int {
+	if scale == self.Scale {
+		return self.Unscaled
+	}
+	return new(big.Int).Mul(self.Unscaled, powerOfTen(scale-self.Scale))
+}
+
+func maxScale(a *Decimal, b *Decimal) int {
+	if a.Scale > b.Scale {
+		return a.Scale
+	}
+	return b.Scale
+}
+
+func (self *Decimal) Add(other *Decimal) *Decimal {
+	scale := maxScale(self, other)
+	return &Decimal{Unscaled: new(big.Int).Add(self.rescaled(scale), other.rescaled(scale)), Scale: scale}
+}
+
+func (self *Decimal) Sub(other *Decimal) *Decimal {
+	scale := maxScale(self, other)
+	return &Decimal{Unscaled: new(big.Int).Sub(self.rescaled(scale), other.rescaled(scale)), Scale: scale}
+}
+
+func (self *Decimal) Mul(other *Decimal) *Decimal {
+	return &Decimal{Unscaled: new(big.Int).Mul(self.Unscaled, other.Unscaled), Scale: self.Scale + other.Scale}
+}
+
+func (self *Decimal) Neg() *Decimal {
+	return &Decimal{Unscaled: new(big.Int).Neg(self.Unscaled), Scale: self.Scale}
+}
+
+func (self *Decimal) Cmp(other *Decimal) int {
+	scale := maxScale(self, other)
+	return self.rescaled(scale).Cmp(other.rescaled(scale))
+}
+
+// divideRounding divides num by den, rounding the quotient according to mode
+func divideRounding(num *big.Int, den *big.Int, mode RoundingMode) *big.Int {
+	quotient, remainder := new(big.Int).QuoRem(num, den, new(big.Int))
+	if remainder.Sign() == 0 {
+		return quotient
+	}
+
+	sign := num.Sign() * den.Sign()
+	twiceRemainder := new(big.Int).Abs(remainder)
+	twiceRemainder.Lsh(twiceRemainder, 1)
+	half := twiceRemainder.Cmp(new(big.Int).Abs(den))
+
+	var awayFromZero bool
+	switch mode {
+	case RoundUp:
+		awayFromZero = true
+	case RoundDown:
+		awayFromZero = false
+	case RoundFloor:
+		awayFromZero = sign < 0
+	case RoundCeiling:
+		awayFromZero = sign > 0
+	case RoundHalfUp:
+		awayFromZero = half >= 0
+	case RoundHalfEven:
+		awayFromZero = half > 0 || (half == 0 && quotient.Bit(0) == 1)
+	}
+
+	if awayFromZero {
+		quotient.Add(quotient, big.NewInt(int64(sign)))
+	}
+	return quotient
+}
+
+// Round returns self with exactly scale digits after the decimal point
+func (self *Decimal) Round(scale int, mode RoundingMode) *Decimal {
+	if scale >= self.Scale {
+		return &Decimal{Unscaled: self.rescaled(scale), Scale: scale}
+	}
+	return &Decimal{Unscaled: divideRounding(self.Unscaled, powerOfTen(self.Scale-scale), mode), Scale: scale}
+}
+
+// Quo divides self by other, giving a result with scale digits after the decimal point
+func (self *Decimal) Quo(other *Decimal, scale int, mode RoundingMode) (*Decimal, error) {
+	if other.Unscaled.Sign() == 0 {
+		return nil, errors.New("Quotient: Divide by zero")
+	}
+
+	num := new(big.Int).Set(self.Unscaled)
+	den := new(big.Int).Set(other.Unscaled)
+	shift := scale - self.Scale + other.Scale
+	if shift >= 0 {
+		num.Mul(num, powerOfTen(shift))
+	} else {
+		den.Mul(den, powerOfTen(-shift))
+	}
+	return &Decimal{Unscaled: divideRounding(num, den, mode), Scale: scale}, nil
+}
+
+// normalized strips trailing zeros, so decimals that are numerically equal look the same
+func (self *Decimal) normalized() *Decimal {
+	unscaled := new(big.Int).Set(self.Unscaled)
+	scale := self.Scale
+	remainder := new(big.Int)
+	for scale > 0 {
+		quotient, r := new(big.Int).QuoRem(unscaled, bigTen, remainder)
+		if r.Sign() != 0 {
+			break
+		}
+		unscaled = quotient
+		scale--
+	}
+	return &Decimal{Unscaled: unscaled, Scale: scale}
+}
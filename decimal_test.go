@@ -0,0 +1,87 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests the decimal type.
+
+package golisp
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type DecimalSuite struct {
+}
+
+var _ = Suite(&DecimalSuite{})
+
+func mustParseDecimal(c *C, s string) *Decimal {
+	d, err := ParseDecimal(s)
+	c.Assert(err, IsNil)
+	return d
+}
+
+func (s *DecimalSuite) TestParsing(c *C) {
+	c.Assert(mustParseDecimal(c, "12").String(), Equals, "12")
+	c.Assert(mustParseDecimal(c, "-0.125").String(), Equals, "-0.125")
+	c.Assert(mustParseDecimal(c, ".5").String(), Equals, "0.5")
+	c.Assert(mustParseDecimal(c, "1.50").Scale, Equals, 2)
+	c.Assert(mustParseDecimal(c, "1.5e3").String(), Equals, "1500")
+	c.Assert(mustParseDecimal(c, "15e-3").String(), Equals, "0.015")
+}
+
+func (s *DecimalSuite) TestParsingErrors(c *C) {
+	for _, text := range []string{"", "abc", "1.2.3", "1e", "0x10", "1e999999999", "1e-999999999"} {
+		_, err := ParseDecimal(text)
+		c.Assert(err, NotNil, Commentf("parsing %q", text))
+	}
+}
+
+func (s *DecimalSuite) TestFloatConversionUsesShortestForm(c *C) {
+	d, err := DecimalFromFloat(0.1)
+	c.Assert(err, IsNil)
+	c.Assert(d.String(), Equals, "0.1")
+}
+
+func (s *DecimalSuite) TestArithmeticIsExact(c *C) {
+	sum := mustParseDecimal(c, "0.1").Add(mustParseDecimal(c, "0.2"))
+	c.Assert(sum.Cmp(mustParseDecimal(c, "0.3")), Equals, 0)
+	c.Assert(mustParseDecimal(c, "1.25").Mul(mustParseDecimal(c, "0.2")).String(), Equals, "0.250")
+	c.Assert(mustParseDecimal(c, "1").Sub(mustParseDecimal(c, "0.01")).String(), Equals, "0.99")
+}
+
+func (s *DecimalSuite) TestRounding(c *C) {
+	cases := []struct {
+		value    string
+		mode     RoundingMode
+		expected string
+	}{
+		{"2.5", RoundHalfEven, "2"},
+		{"3.5", RoundHalfEven, "4"},
+		{"-2.5", RoundHalfEven, "-2"},
+		{"2.5", RoundHalfUp, "3"},
+		{"-2.5", RoundHalfUp, "-3"},
+		{"2.9", RoundDown, "2"},
+		{"2.1", RoundUp, "3"},
+		{"-2.1", RoundFloor, "-3"},
+		{"-2.9", RoundCeiling, "-2"},
+	}
+	for _, t := range cases {
+		c.Assert(mustParseDecimal(c, t.value).Round(0, t.mode).String(), Equals, t.expected, Commentf("rounding %s", t.value))
+	}
+	c.Assert(mustParseDecimal(c, "1.5").Round(3, RoundHalfEven).String(), Equals, "1.500")
+}
+
+func (s *DecimalSuite) TestDivision(c *C) {
+	q, err := mustParseDecimal(c, "10").Quo(mustParseDecimal(c, "3"), 4, RoundHalfEven)
+	c.Assert(err, IsNil)
+	c.Assert(q.String(), Equals, "3.3333")
+
+	q, err = mustParseDecimal(c, "2").Quo(mustParseDecimal(c, "0.03"), 1, RoundHalfUp)
+	c.Assert(err, IsNil)
+	c.Assert(q.String(), Equals, "66.7")
+
+	_, err = mustParseDecimal(c, "1").Quo(mustParseDecimal(c, "0.0"), 2, RoundHalfEven)
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,101 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements deprecated bindings that forward to their replacements.
+
+package golisp
+
+import (
+	"fmt"
+	"sync"
+)
+
+// deprecation forwards calls made through an old name to whatever its replacement is bound
+// to at the time, warning the first time each call site uses the old name
+type deprecation struct {
+	oldName string
+	newName *Data
+	message string
+	env     *SymbolTableFrame
+	mutex   sync.Mutex
+	warned  map[interface{}]bool
+}
+
+// DefineDeprecated binds oldName in env to forward to newName, which has to be bound, and
+// log a warning including message once for every place it is called from.  A forwarder to
+// a macro or special form receives its arguments unevaluated.
+func DefineDeprecated(env *SymbolTableFrame, oldName string, newName string, message string) (result *Data, err error) {
+	newSymbol := Intern(newName)
+	binding, found := env.FindBindingFor(newSymbol)
+	if !found {
+		return nil, NewLispError(GeneralError, fmt.Sprintf("%s can't be deprecated in favour of the unbound %s.", oldName, newName))
+	}
+	target := binding.Value()
+	special := MacroP(target) || (PrimitiveP(target) && PrimitiveValue(target).Special)
+
+	forwarder := &deprecation{oldName: oldName, newName: newSymbol, message: message, env: env, warned: make(map[interface{}]bool)}
+	f := &PrimitiveFunction{Name: oldName, Special: special, NumberOfArgs: "*", Body: forwarder.forward}
+	return env.BindLocallyTo(Intern(oldName), PrimitiveWithNameAndFunc(oldName, f))
+}
+
+func (self *deprecation) forward(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	self.warnOnce(env.CurrentForm())
+	target := self.env.ValueOf(self.newName)
+	if MacroP(target) || (PrimitiveP(target) && PrimitiveValue(target).Special) {
+		return Apply(target, args, env)
+	}
+	return ApplyWithoutEval(target, args, env)
+}
+
+// warnOnce logs the deprecation warning unless it has already been logged for the call
+// site of form, identified by where it was read or, failing that, by the form itself
+func (self *deprecation) warnOnce(form *Data) {
+	var site interface{} = form
+	source := SourcePositionOf(form)
+	if source != nil {
+		site = *source
+	}
+
+	self.mutex.Lock()
+	alreadyWarned := self.warned[site]
+	self.warned[site] = true
+	self.mutex.Unlock()
+	if alreadyWarned {
+		return
+	}
+
+	location := ""
+	if form != nil {
+		location = fmt.Sprintf(" Called as %s", String(form))
+		if source != nil {
+			location += fmt.Sprintf(" at %s", source)
+		}
+		location += "."
+	}
+	LogWarnf("Warning: %s is deprecated, use %s instead. %s%s\n", self.oldName, StringValue(self.newName), self.message, location)
+}
+
+// DefineDeprecatedImpl handles (define-deprecated old new "message")
+func DefineDeprecatedImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	oldName := Car(args)
+	newName := Cadr(args)
+	if !SymbolP(oldName) || !SymbolP(newName) {
+		err = ProcessTypeError(fmt.Sprintf("define-deprecated expects the old and new names to be symbols, but received %s and %s.", String(oldName), String(newName)), env)
+		return
+	}
+	message, err := Eval(Caddr(args), env)
+	if err != nil {
+		return
+	}
+	if !StringP(message) {
+		err = ProcessTypeError(fmt.Sprintf("define-deprecated expects a message string, but received %s.", String(message)), env)
+		return
+	}
+	result, err = DefineDeprecated(env, StringValue(oldName), StringValue(newName), StringValue(message))
+	if err != nil {
+		err = ProcessError(err.Error(), env)
+	}
+	return
+}
@@ -0,0 +1,73 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests deprecated bindings.
+
+package golisp
+
+import (
+	"bytes"
+	. "gopkg.in/check.v1"
+	"os"
+	"strings"
+)
+
+type DeprecationSuite struct {
+	warnings *bytes.Buffer
+}
+
+var _ = Suite(&DeprecationSuite{})
+
+func (s *DeprecationSuite) SetUpTest(c *C) {
+	s.warnings = new(bytes.Buffer)
+	SetErrorWriter(s.warnings)
+}
+
+func (s *DeprecationSuite) TearDownTest(c *C) {
+	SetErrorWriter(os.Stderr)
+}
+
+func (s *DeprecationSuite) TestForwardsToTheNewName(c *C) {
+	result, err := ParseAndEvalAll(`(define (dep-new-add a b) (+ a b))
+(define-deprecated dep-old-add dep-new-add "Renamed for consistency.")
+(dep-old-add 1 2)`)
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(3))
+	c.Assert(s.warnings.String(), Matches, "Warning: dep-old-add is deprecated, use dep-new-add instead. Renamed for consistency. Called as \\(dep-old-add 1 2\\).*\n")
+}
+
+func (s *DeprecationSuite) TestWarnsOncePerCallSite(c *C) {
+	_, err := ParseAndEvalAll(`(define (dep-new-twice x) (* 2 x))
+(define-deprecated dep-old-twice dep-new-twice "")
+(define (dep-caller x) (dep-old-twice x))
+(dep-caller 1)
+(dep-caller 2)
+(dep-old-twice 3)`)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Count(s.warnings.String(), "Warning:"), Equals, 2)
+}
+
+func (s *DeprecationSuite) TestFollowsRedefinition(c *C) {
+	result, err := ParseAndEvalAll(`(define (dep-new-value) 1)
+(define-deprecated dep-old-value dep-new-value "")
+(define (dep-new-value) 2)
+(dep-old-value)`)
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(2))
+}
+
+func (s *DeprecationSuite) TestForwardsToSpecialForms(c *C) {
+	result, err := ParseAndEvalAll(`(define-deprecated dep-old-if if "")
+(dep-old-if #t 'yes (car '()))`)
+	c.Assert(err, IsNil)
+	c.Assert(StringValue(result), Equals, "yes")
+}
+
+func (s *DeprecationSuite) TestRequiresABoundReplacement(c *C) {
+	_, err := ParseAndEval(`(define-deprecated dep-old-missing dep-new-missing "")`)
+	c.Assert(err, NotNil)
+	_, err = DefineDeprecated(Global, "dep-old-missing", "dep-new-missing", "")
+	c.Assert(err, NotNil)
+}
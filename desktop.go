@@ -0,0 +1,135 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build desktop
+// +build desktop
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the desktop clipboard and notification primitive functions, which are
+// only built with -tags desktop.
+
+package golisp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+func RegisterDesktopPrimitives() {
+	MakeRestrictedPrimitiveFunction("clipboard-get", "0", ClipboardGetImpl)
+	MakeRestrictedPrimitiveFunction("clipboard-set", "1", ClipboardSetImpl)
+	MakeRestrictedPrimitiveFunction("notify", "1|2", NotifyImpl)
+}
+
+// The desktop is reached through the commands each platform provides for it; the first
+// one found on the path is used.
+
+var clipboardGetCommands = map[string][][]string{
+	"linux":   {{"wl-paste", "--no-newline"}, {"xclip", "-selection", "clipboard", "-out"}, {"xsel", "--clipboard", "--output"}},
+	"darwin":  {{"pbpaste"}},
+	"windows": {{"powershell", "-NoProfile", "-Command", "Get-Clipboard -Raw"}},
+}
+
+var clipboardSetCommands = map[string][][]string{
+	"linux":   {{"wl-copy"}, {"xclip", "-selection", "clipboard", "-in"}, {"xsel", "--clipboard", "--input"}},
+	"darwin":  {{"pbcopy"}},
+	"windows": {{"clip"}},
+}
+
+var lookPath = exec.LookPath
+
+func desktopCommand(candidates map[string][][]string, what string) (command []string, err error) {
+	for _, candidate := range candidates[runtime.GOOS] {
+		if _, err := lookPath(candidate[0]); err == nil {
+			return candidate, nil
+		}
+	}
+	return nil, fmt.Errorf("no %s command was found for %s", what, runtime.GOOS)
+}
+
+func runDesktopCommand(command []string, input string) (output string, err error) {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = strings.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			err = errors.New(message)
+		}
+		return
+	}
+	return stdout.String(), nil
+}
+
+func ClipboardGetImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	command, err := desktopCommand(clipboardGetCommands, "clipboard")
+	if err == nil {
+		var text string
+		if text, err = runDesktopCommand(command, ""); err == nil {
+			return StringWithValue(strings.TrimSuffix(text, "\r\n")), nil
+		}
+	}
+	err = ProcessError(fmt.Sprintf("clipboard-get: %s", err), env)
+	return
+}
+
+func ClipboardSetImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	text := Car(args)
+	if !StringP(text) {
+		err = ProcessTypeError(fmt.Sprintf("clipboard-set expects a string but received %s.", String(text)), env)
+		return
+	}
+	command, err := desktopCommand(clipboardSetCommands, "clipboard")
+	if err == nil {
+		if _, err = runDesktopCommand(command, StringValue(text)); err == nil {
+			return text, nil
+		}
+	}
+	err = ProcessError(fmt.Sprintf("clipboard-set: %s", err), env)
+	return
+}
+
+// appleScriptString quotes s for an AppleScript command
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func notifyCommand(title string, message string) (command []string, err error) {
+	switch runtime.GOOS {
+	case "linux":
+		if _, err = lookPath("notify-send"); err == nil {
+			return []string{"notify-send", title, message}, nil
+		}
+	case "darwin":
+		return []string{"osascript", "-e", fmt.Sprintf("display notification %s with title %s", appleScriptString(message), appleScriptString(title))}, nil
+	}
+	return nil, fmt.Errorf("desktop notifications aren't supported on %s", runtime.GOOS)
+}
+
+// NotifyImpl handles (notify title [message]), which shows a desktop notification
+func NotifyImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	title := Car(args)
+	if !StringP(title) {
+		err = ProcessTypeError(fmt.Sprintf("notify expects a string title but received %s.", String(title)), env)
+		return
+	}
+	message := ""
+	if NotNilP(Cdr(args)) {
+		message = PrintString(Cadr(args))
+	}
+
+	command, err := notifyCommand(StringValue(title), message)
+	if err == nil {
+		if _, err = runDesktopCommand(command, ""); err == nil {
+			return LispTrue, nil
+		}
+	}
+	err = ProcessError(fmt.Sprintf("notify: %s", err), env)
+	return
+}
@@ -0,0 +1,15 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !desktop
+// +build !desktop
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file leaves out the desktop primitives, which are only built with -tags desktop so that
+// embedded interpreters can't reach the host's clipboard.
+
+package golisp
+
+func RegisterDesktopPrimitives() {
+}
@@ -0,0 +1,66 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build desktop
+// +build desktop
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests the desktop primitives.
+
+package golisp
+
+import (
+	"errors"
+	"runtime"
+
+	. "gopkg.in/check.v1"
+)
+
+type DesktopSuite struct {
+}
+
+var _ = Suite(&DesktopSuite{})
+
+func (s *DesktopSuite) TearDownTest(c *C) {
+	lookPath = defaultLookPath
+}
+
+var defaultLookPath = lookPath
+
+func (s *DesktopSuite) TestAppleScriptString(c *C) {
+	c.Assert(appleScriptString(`say "hi" \ bye`), Equals, `"say \"hi\" \\ bye"`)
+}
+
+func (s *DesktopSuite) TestFirstCommandFoundIsUsed(c *C) {
+	lookPath = func(name string) (string, error) {
+		if name == "xsel" {
+			return "/usr/bin/xsel", nil
+		}
+		return "", errors.New("not found")
+	}
+	command, err := desktopCommand(map[string][][]string{runtime.GOOS: {{"xclip", "-out"}, {"xsel", "--output"}}}, "clipboard")
+	c.Assert(err, IsNil)
+	c.Assert(command, DeepEquals, []string{"xsel", "--output"})
+}
+
+func (s *DesktopSuite) TestNoCommandFound(c *C) {
+	lookPath = func(name string) (string, error) {
+		return "", errors.New("not found")
+	}
+	_, err := desktopCommand(clipboardGetCommands, "clipboard")
+	c.Assert(err, ErrorMatches, "no clipboard command was found for .*")
+	_, err = ClipboardGetImpl(nil, Global)
+	c.Assert(err, ErrorMatches, "clipboard-get: no clipboard command was found for .*")
+}
+
+func (s *DesktopSuite) TestRestricted(c *C) {
+	env := NewSymbolTableFrameBelow(Global, "restricted desktop test")
+	env.IsRestricted = true
+	for _, code := range []string{`(clipboard-get)`, `(clipboard-set "x")`, `(notify "x")`} {
+		sexpr, err := Parse(code)
+		c.Assert(err, IsNil)
+		_, err = Eval(sexpr, env)
+		c.Assert(err, ErrorMatches, "(?s).*restricted.*")
+	}
+}
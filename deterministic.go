@@ -0,0 +1,86 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements deterministic mode, for reproducible runs.
+
+package golisp
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DeterministicEpoch is the time the virtual clock starts at in deterministic mode
+var DeterministicEpoch = time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// In deterministic mode randomness comes from a seeded generator, the clock is a FakeClock
+// that only moves when ticked, and frame slots, environment bindings and hash table entries
+// are listed in a stable order, so that a run can be repeated exactly.
+var deterministic = struct {
+	sync.Mutex
+	enabled bool
+	random  *rand.Rand
+	clock   *FakeClock
+}{random: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// SetDeterministic turns deterministic mode on, seeding randomness with seed and setting
+// the clock to a FakeClock starting at DeterministicEpoch, or turns it off, going back to
+// the real clock
+func SetDeterministic(enabled bool, seed int64) {
+	deterministic.Lock()
+	defer deterministic.Unlock()
+	deterministic.enabled = enabled
+	if enabled {
+		deterministic.random = rand.New(rand.NewSource(seed))
+		deterministic.clock = NewFakeClock(DeterministicEpoch)
+		SetClock(deterministic.clock)
+	} else {
+		deterministic.random = rand.New(rand.NewSource(time.Now().UnixNano()))
+		deterministic.clock = nil
+		SetClock(nil)
+	}
+}
+
+func Deterministic() bool {
+	deterministic.Lock()
+	defer deterministic.Unlock()
+	return deterministic.enabled
+}
+
+// Tick advances the clock of deterministic mode by d, firing any timers that come due, and
+// returns the new time.  It only has an effect in deterministic mode.
+func Tick(d time.Duration) time.Time {
+	deterministic.Lock()
+	clock := deterministic.clock
+	deterministic.Unlock()
+	if clock == nil {
+		return CurrentTime()
+	}
+	clock.Advance(d)
+	return clock.Now()
+}
+
+// randomInt63n returns a random number in [0, n) from the generator lisp code uses
+func randomInt63n(n int64) int64 {
+	deterministic.Lock()
+	defer deterministic.Unlock()
+	return deterministic.random.Int63n(n)
+}
+
+func randomBytes(b []byte) {
+	deterministic.Lock()
+	defer deterministic.Unlock()
+	deterministic.random.Read(b)
+}
+
+// stableOrder sorts names in deterministic mode, leaving them in map order otherwise
+func stableOrder(names []string) []string {
+	if Deterministic() {
+		sort.Strings(names)
+	}
+	return names
+}
@@ -0,0 +1,71 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests deterministic mode.
+
+package golisp
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type DeterministicSuite struct {
+}
+
+var _ = Suite(&DeterministicSuite{})
+
+func (s *DeterministicSuite) TearDownTest(c *C) {
+	SetDeterministic(false, 0)
+}
+
+func (s *DeterministicSuite) run(c *C, code string) string {
+	SetDeterministic(true, 42)
+	result, err := ParseAndEvalAll(code)
+	c.Assert(err, IsNil)
+	return String(result)
+}
+
+func (s *DeterministicSuite) TestReproducibleRuns(c *C) {
+	code := `(list (random-byte) (random-byte) (random-byte) (time->milliseconds (now)))`
+	first := s.run(c, code)
+	c.Assert(s.run(c, code), Equals, first)
+}
+
+func (s *DeterministicSuite) TestVirtualClock(c *C) {
+	result := s.run(c, `(define start (millis))
+(tick 1500)
+(tick "2s")
+(list (- (millis) start) (time->milliseconds (now)))`)
+	c.Assert(result, Equals, "(3500 1420070403500)")
+}
+
+func (s *DeterministicSuite) TestStableFrameOrder(c *C) {
+	result := s.run(c, `(define f {zeta: 1 alpha: 2 mid: 3 beta: 4})
+(list (frame-keys f) (frame-values f))`)
+	c.Assert(result, Equals, "((alpha: beta: mid: zeta:) (2 4 3 1))")
+}
+
+func (s *DeterministicSuite) TestStableHashTableOrder(c *C) {
+	code := `(define table (make-hash-table))
+(for-each (lambda (n) (hash-set! table n (* n n))) (interval 1 50))
+(hash->alist table)`
+	first := s.run(c, code)
+	for i := 0; i < 10; i++ {
+		c.Assert(s.run(c, code), Equals, first)
+	}
+}
+
+func (s *DeterministicSuite) TestStableSetOrder(c *C) {
+	code := `(set->list (list->set (interval 1 50)))`
+	first := s.run(c, code)
+	for i := 0; i < 10; i++ {
+		c.Assert(s.run(c, code), Equals, first)
+	}
+}
+
+func (s *DeterministicSuite) TestTickOutsideDeterministicMode(c *C) {
+	_, err := ParseAndEval(`(tick 10)`)
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,163 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements the virtual load path of lisp files embedded in the host program.
+
+package golisp
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var embeddedFiles = make(map[string]string)
+var requiredFiles = make(map[string]bool)
+var embeddedFilesMutex sync.RWMutex
+
+func embeddedPath(filename string) string {
+	return strings.TrimPrefix(path.Clean(filename), "./")
+}
+
+// RegisterEmbeddedFile makes contents available to load and require under filename without
+// it existing on disk, e.g. for a library compiled into the host with go:embed.  Embedded
+// files take precedence over files on disk.
+func RegisterEmbeddedFile(filename string, contents string) {
+	embeddedFilesMutex.Lock()
+	embeddedFiles[embeddedPath(filename)] = contents
+	embeddedFilesMutex.Unlock()
+}
+
+// RegisterEmbeddedFiles registers every file in files, which maps filenames to contents
+func RegisterEmbeddedFiles(files map[string]string) {
+	for filename, contents := range files {
+		RegisterEmbeddedFile(filename, contents)
+	}
+}
+
+// EmbeddedFiles returns the sorted names of the embedded files
+func EmbeddedFiles() []string {
+	embeddedFilesMutex.RLock()
+	defer embeddedFilesMutex.RUnlock()
+	names := make([]string, 0, len(embeddedFiles))
+	for name, _ := range embeddedFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func readEmbeddedFile(filename string) (contents string, found bool) {
+	embeddedFilesMutex.RLock()
+	defer embeddedFilesMutex.RUnlock()
+	contents, found = embeddedFiles[embeddedPath(filename)]
+	return
+}
+
+func fileExists(filename string) bool {
+	info, err := os.Stat(filename)
+	return err == nil && !info.IsDir()
+}
+
+// resolveRequire finds the file that (require name) refers to: name itself or name.lsp,
+// embedded or on disk.
+func resolveRequire(name string) (filename string, found bool) {
+	candidates := []string{name}
+	if !strings.HasSuffix(name, ".lsp") {
+		candidates = append(candidates, name+".lsp")
+	}
+
+	for _, candidate := range candidates {
+		if _, found = readEmbeddedFile(candidate); found {
+			return embeddedPath(candidate), true
+		}
+	}
+	for _, candidate := range candidates {
+		if onDisk, found := findOnLoadPath(candidate); found {
+			return path.Clean(onDisk), true
+		}
+	}
+	return "", false
+}
+
+// expandVarsWith replaces $NAME and ${NAME} in s with the values lookup finds for them, or
+// nothing.  ${NAME:-default} uses default if NAME is unset or empty, and $$ is a $.
+func expandVarsWith(s string, lookup func(string) (string, bool)) string {
+	return os.Expand(s, func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		fallback, hasFallback := "", false
+		if i := strings.Index(name, ":-"); i >= 0 {
+			name, fallback, hasFallback = name[:i], name[i+2:], true
+		}
+		if value, found := lookup(name); found && (value != "" || !hasFallback) {
+			return value
+		}
+		return fallback
+	})
+}
+
+// ExpandVars replaces $NAME and ${NAME} in s with the values of environment variables, so
+// paths like ${HOME}/scripts work on every machine.  load and require expand the names they
+// are given, and the directories of Config.LoadPath, with it.
+func ExpandVars(s string) string {
+	return expandVarsWith(s, os.LookupEnv)
+}
+
+// findOnLoadPath finds filename on disk: as given, or in one of the directories of
+// Config.LoadPath if it is relative
+func findOnLoadPath(filename string) (found string, ok bool) {
+	if fileExists(filename) {
+		return filename, true
+	}
+	if path.IsAbs(filename) {
+		return "", false
+	}
+	for _, directory := range Config.LoadPath {
+		if candidate := path.Join(ExpandVars(directory), filename); fileExists(candidate) {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// markRequired records that filename has been required, returning false if it already had been
+func markRequired(filename string) bool {
+	embeddedFilesMutex.Lock()
+	defer embeddedFilesMutex.Unlock()
+	if requiredFiles[filename] {
+		return false
+	}
+	requiredFiles[filename] = true
+	return true
+}
+
+func forgetRequired(filename string) {
+	embeddedFilesMutex.Lock()
+	delete(requiredFiles, filename)
+	embeddedFilesMutex.Unlock()
+}
+
+// Require loads the file named by name (see resolveRequire) into the global environment
+// unless it has already been required.  It reports whether the file was loaded.
+func Require(name string) (loaded bool, err error) {
+	filename, found := resolveRequire(name)
+	if !found {
+		filename = name
+	}
+
+	if !markRequired(filename) {
+		return false, nil
+	}
+	_, err = ProcessFile(filename)
+	if err != nil {
+		forgetRequired(filename)
+		return false, err
+	}
+	return true, nil
+}
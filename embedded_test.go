@@ -0,0 +1,74 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests embedded lisp files.
+
+package golisp
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type EmbeddedSuite struct {
+}
+
+var _ = Suite(&EmbeddedSuite{})
+
+func (s *EmbeddedSuite) SetUpSuite(c *C) {
+	RegisterEmbeddedFiles(map[string]string{
+		"embedded/lib/counter.lsp": "(set! embedded-require-count (+ embedded-require-count 1))",
+		"embedded/lib/square.lsp":  "(define (embedded-square x) (* x x))",
+		"./embedded/broken.lsp":    "(car 1 2 3)",
+	})
+}
+
+func (s *EmbeddedSuite) TestEmbeddedFilesAreListed(c *C) {
+	files := EmbeddedFiles()
+	c.Assert(files, DeepEquals, []string{"embedded/broken.lsp", "embedded/lib/counter.lsp", "embedded/lib/square.lsp"})
+}
+
+func (s *EmbeddedSuite) TestReadingPrefersEmbeddedFiles(c *C) {
+	src, err := ReadFile("embedded/lib/../lib/square.lsp")
+	c.Assert(err, IsNil)
+	c.Assert(src, Equals, "(define (embedded-square x) (* x x))")
+
+	_, err = ReadFile("embedded/missing.lsp")
+	c.Assert(err, NotNil)
+}
+
+func (s *EmbeddedSuite) TestLoadingEmbeddedFile(c *C) {
+	_, err := ParseAndEval(`(load "embedded/lib/square.lsp")`)
+	c.Assert(err, IsNil)
+	result, err := ParseAndEval("(embedded-square 7)")
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(49))
+}
+
+func (s *EmbeddedSuite) TestRequireLoadsOnce(c *C) {
+	_, err := ParseAndEval("(define embedded-require-count 0)")
+	c.Assert(err, IsNil)
+
+	result, err := ParseAndEval(`(require "embedded/lib/counter")`)
+	c.Assert(err, IsNil)
+	c.Assert(BooleanValue(result), Equals, true)
+
+	result, err = ParseAndEval(`(require "embedded/lib/counter.lsp")`)
+	c.Assert(err, IsNil)
+	c.Assert(BooleanValue(result), Equals, false)
+
+	result, err = ParseAndEval("embedded-require-count")
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(1))
+}
+
+func (s *EmbeddedSuite) TestFailedRequireCanBeRetried(c *C) {
+	_, err := Require("embedded/broken")
+	c.Assert(err, NotNil)
+	_, err = Require("embedded/broken")
+	c.Assert(err, NotNil)
+
+	_, err = Require("embedded/nonexistent")
+	c.Assert(err, NotNil)
+}
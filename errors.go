@@ -0,0 +1,137 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements the categories of errors raised while evaluating lisp code.
+
+package golisp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrorCategory classifies an error so handlers can tell, e.g., a bad argument from a
+// failed file operation
+type ErrorCategory int
+
+const (
+	GeneralError ErrorCategory = iota
+	TypeError
+	ArityError
+	IndexError
+	IOError
+	ParseError
+	UserError
+)
+
+var errorCategoryNames = map[ErrorCategory]string{
+	GeneralError: "error",
+	TypeError:    "type-error",
+	ArityError:   "arity-error",
+	IndexError:   "index-error",
+	IOError:      "io-error",
+	ParseError:   "parse-error",
+	UserError:    "user-error",
+}
+
+func (self ErrorCategory) String() string {
+	return errorCategoryNames[self]
+}
+
+// LispError is an error with a category.  Errors keep their category as evaluation context
+// is added to their message, so callers can find it with errors.As or ErrorCategoryOf.
+// Arity and type errors also record the form whose evaluation raised them and where it was
+// read from.
+type LispError struct {
+	Category ErrorCategory
+	Message  string
+	Cause    error
+	Form     *Data
+	Source   *SourcePosition
+}
+
+func (self *LispError) Error() string {
+	if self.Form == nil {
+		return self.Message
+	}
+	callsite := fmt.Sprintf("%s\nCalled as %s", strings.TrimRight(self.Message, "\n"), String(self.Form))
+	if self.Source != nil {
+		callsite += " at " + self.Source.String()
+	}
+	return callsite
+}
+
+// Unwrap returns the underlying Go error, e.g. the *os.PathError behind an io-error
+func (self *LispError) Unwrap() error {
+	return self.Cause
+}
+
+func NewLispError(category ErrorCategory, message string) error {
+	return &LispError{Category: category, Message: message}
+}
+
+// ioError categorizes an error from the operating system, or returns nil if err is nil
+func ioError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &LispError{Category: IOError, Message: err.Error(), Cause: err}
+}
+
+// ErrorCategoryOf returns the category of err, which is GeneralError for errors that
+// don't have one
+func ErrorCategoryOf(err error) ErrorCategory {
+	var lispError *LispError
+	if errors.As(err, &lispError) {
+		return lispError.Category
+	}
+	return GeneralError
+}
+
+// noteCallsite records form as where an arity or type error was raised, unless it already
+// has a callsite from a form nested inside it.  Forms built by macros aren't read from
+// anywhere, so they are located by the closest call that was.
+func noteCallsite(err error, form *Data, env *SymbolTableFrame) {
+	var lispError *LispError
+	if !errors.As(err, &lispError) || lispError.Form != nil {
+		return
+	}
+	if lispError.Category != ArityError && lispError.Category != TypeError {
+		return
+	}
+	lispError.Form = form
+	lispError.Source = SourcePositionOf(form)
+	for _, frame := range CallStack(env) {
+		if lispError.Source != nil {
+			break
+		}
+		lispError.Source = frame.Source
+	}
+}
+
+// contextError prefixes an error's message with where it happened while leaving the error
+// itself available to errors.As
+type contextError struct {
+	context string
+	err     error
+}
+
+func (self *contextError) Error() string {
+	return self.context + self.err.Error()
+}
+
+func (self *contextError) Unwrap() error {
+	return self.err
+}
+
+// addErrorContext prefixes err's message with context, except for stack overflows which
+// would otherwise gather thousands of lines of it
+func addErrorContext(err error, context string) error {
+	if _, overflow := err.(*StackOverflowError); overflow {
+		return err
+	}
+	return &contextError{context: context, err: err}
+}
@@ -0,0 +1,76 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests error categories.
+
+package golisp
+
+import (
+	"errors"
+	. "gopkg.in/check.v1"
+	"os"
+)
+
+type ErrorsSuite struct {
+}
+
+var _ = Suite(&ErrorsSuite{})
+
+func (s *ErrorsSuite) TestCategoryNames(c *C) {
+	c.Assert(TypeError.String(), Equals, "type-error")
+	c.Assert(GeneralError.String(), Equals, "error")
+}
+
+func (s *ErrorsSuite) TestCategorySurvivesContext(c *C) {
+	ParseAndEval("(define (errors-test-inner) (string-length 1))")
+	_, err := ParseAndEval("(+ 1 (errors-test-inner))")
+	c.Assert(err, NotNil)
+	c.Assert(err.Error(), Matches, "(?s).*Evaling.*string-length requires a string.*")
+
+	var lispError *LispError
+	c.Assert(errors.As(err, &lispError), Equals, true)
+	c.Assert(lispError.Category, Equals, TypeError)
+	c.Assert(ErrorCategoryOf(err), Equals, TypeError)
+}
+
+func (s *ErrorsSuite) TestParseErrors(c *C) {
+	_, err := Parse("(+ 1")
+	c.Assert(ErrorCategoryOf(err), Equals, ParseError)
+}
+
+func (s *ErrorsSuite) TestIOErrorsWrapTheCause(c *C) {
+	_, err := ProcessFile("no/such/file.lsp")
+	c.Assert(ErrorCategoryOf(err), Equals, IOError)
+	c.Assert(errors.Is(err, os.ErrNotExist), Equals, true)
+}
+
+func (s *ErrorsSuite) TestUncategorizedErrors(c *C) {
+	c.Assert(ErrorCategoryOf(errors.New("plain")), Equals, GeneralError)
+}
+
+func (s *ErrorsSuite) TestErrorsIdentifyTheCallsite(c *C) {
+	_, err := ParseAndEval(`(map (lambda (x)
+                                    (string-length x))
+                                  '(1 2))`)
+	var lispError *LispError
+	c.Assert(errors.As(err, &lispError), Equals, true)
+	c.Assert(String(lispError.Form), Equals, "(string-length x)")
+	c.Assert(lispError.Source, NotNil)
+	c.Assert(lispError.Source.Line, Equals, 2)
+	c.Assert(err.Error(), Matches, "(?s).*string-length requires a string.*\nCalled as \\(string-length x\\) at line 2.*")
+
+	ParseAndEval("(define (errors-test-one x) x)")
+	_, err = ParseAndEval("(errors-test-one 1 2)")
+	c.Assert(ErrorCategoryOf(err), Equals, ArityError)
+	c.Assert(err.Error(), Matches, "(?s).*Called as \\(errors-test-one 1 2\\) at line 1.*")
+}
+
+func (s *ErrorsSuite) TestOtherErrorsHaveNoCallsite(c *C) {
+	_, err := ParseAndEval(`(error "boom")`)
+	var lispError *LispError
+	if errors.As(err, &lispError) {
+		c.Assert(lispError.Form, IsNil)
+	}
+}
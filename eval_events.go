@@ -0,0 +1,130 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements the structured stream of evaluation events.
+
+package golisp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type EvalEventKind int
+
+const (
+	EvalStarted EvalEventKind = iota
+	ApplyStarted
+	EvalReturned
+)
+
+var evalEventKindNames = map[EvalEventKind]string{
+	EvalStarted:  "eval",
+	ApplyStarted: "apply",
+	EvalReturned: "return",
+}
+
+func (self EvalEventKind) String() string {
+	return evalEventKindNames[self]
+}
+
+func (self EvalEventKind) MarshalJSON() ([]byte, error) {
+	return json.Marshal(self.String())
+}
+
+func (self *EvalEventKind) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	for kind, kindName := range evalEventKindNames {
+		if kindName == name {
+			*self = kind
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown eval event kind %q", name)
+}
+
+// EvalEvent describes a step of evaluation: an expression about to be evaluated, a
+// function about to be applied to its (unevaluated) arguments, or an expression that
+// returned a result or failed.  Depth is the depth of the environment the step happens in.
+type EvalEvent struct {
+	Kind       EvalEventKind `json:"kind"`
+	Time       time.Time     `json:"time"`
+	Depth      int           `json:"depth"`
+	Expression string        `json:"expr,omitempty"`
+	Function   string        `json:"function,omitempty"`
+	Args       string        `json:"args,omitempty"`
+	Result     string        `json:"result,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// EvalEventHandler is called synchronously, on the evaluating goroutine, for each event
+type EvalEventHandler func(event *EvalEvent)
+
+// evalEventHandler holds an EvalEventHandler, which is nil when events are off, so that
+// checking for it on every eval is a single atomic load
+var evalEventHandler atomic.Value
+
+// SetEvalEventHandler sends evaluation events to handler, or stops sending them if handler
+// is nil.  Handlers are called while evaluating, so they should be quick.
+func SetEvalEventHandler(handler EvalEventHandler) {
+	evalEventHandler.Store(handler)
+}
+
+func currentEvalEventHandler() EvalEventHandler {
+	handler, _ := evalEventHandler.Load().(EvalEventHandler)
+	return handler
+}
+
+// JSONEvalEventHandler returns a handler that writes each event to w as a line of JSON
+func JSONEvalEventHandler(w io.Writer) EvalEventHandler {
+	var mutex sync.Mutex
+	encoder := json.NewEncoder(w)
+	return func(event *EvalEvent) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		encoder.Encode(event)
+	}
+}
+
+func newEvalEvent(kind EvalEventKind, env *SymbolTableFrame) *EvalEvent {
+	return &EvalEvent{Kind: kind, Time: CurrentTime(), Depth: env.Depth()}
+}
+
+func emitEvalStarted(d *Data, env *SymbolTableFrame) {
+	if handler := currentEvalEventHandler(); handler != nil {
+		event := newEvalEvent(EvalStarted, env)
+		event.Expression = String(d)
+		handler(event)
+	}
+}
+
+func emitApplyStarted(function *Data, args *Data, env *SymbolTableFrame) {
+	if handler := currentEvalEventHandler(); handler != nil {
+		event := newEvalEvent(ApplyStarted, env)
+		event.Function = functionName(function)
+		event.Args = String(args)
+		handler(event)
+	}
+}
+
+func emitEvalReturned(d *Data, result *Data, err error, env *SymbolTableFrame) {
+	if handler := currentEvalEventHandler(); handler != nil {
+		event := newEvalEvent(EvalReturned, env)
+		event.Expression = String(d)
+		if err != nil {
+			event.Error = err.Error()
+		} else {
+			event.Result = String(result)
+		}
+		handler(event)
+	}
+}
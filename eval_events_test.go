@@ -0,0 +1,114 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests the structured stream of evaluation events.
+
+package golisp
+
+import (
+	"bytes"
+	"encoding/json"
+	. "gopkg.in/check.v1"
+	"strings"
+	"sync"
+)
+
+type EvalEventsSuite struct {
+	mutex  sync.Mutex
+	events []*EvalEvent
+}
+
+var _ = Suite(&EvalEventsSuite{})
+
+func (s *EvalEventsSuite) SetUpTest(c *C) {
+	s.events = nil
+}
+
+func (s *EvalEventsSuite) TearDownTest(c *C) {
+	SetEvalEventHandler(nil)
+}
+
+// record keeps the events about expressions mentioning name, ignoring anything evaluated
+// concurrently, e.g. by scheduled jobs in other tests
+func (s *EvalEventsSuite) record(name string) EvalEventHandler {
+	return func(event *EvalEvent) {
+		if strings.Contains(event.Expression, name) || event.Function == name {
+			s.mutex.Lock()
+			s.events = append(s.events, event)
+			s.mutex.Unlock()
+		}
+	}
+}
+
+func (s *EvalEventsSuite) TestEventSequence(c *C) {
+	_, err := ParseAndEval(`(define (event-test-fn x) (* x 2))`)
+	c.Assert(err, IsNil)
+	SetEvalEventHandler(s.record("event-test-fn"))
+	_, err = ParseAndEval(`(event-test-fn 21)`)
+	c.Assert(err, IsNil)
+	SetEvalEventHandler(nil)
+
+	kinds := make([]string, 0, len(s.events))
+	for _, event := range s.events {
+		kinds = append(kinds, event.Kind.String())
+	}
+	c.Assert(kinds, DeepEquals, []string{"eval", "eval", "return", "apply", "return"})
+	c.Assert(s.events[0].Expression, Equals, "(event-test-fn 21)")
+	c.Assert(s.events[3].Function, Equals, "event-test-fn")
+	c.Assert(s.events[3].Args, Equals, "(21)")
+	c.Assert(s.events[4].Result, Equals, "42")
+	c.Assert(s.events[4].Time.Before(s.events[0].Time), Equals, false)
+}
+
+func (s *EvalEventsSuite) TestErrorEvents(c *C) {
+	SetEvalEventHandler(s.record("event-test-error"))
+	_, err := ParseAndEval(`(error "event-test-error")`)
+	c.Assert(err, NotNil)
+	SetEvalEventHandler(nil)
+
+	last := s.events[len(s.events)-1]
+	c.Assert(last.Kind, Equals, EvalReturned)
+	c.Assert(last.Error, Matches, ".*event-test-error.*")
+	c.Assert(last.Result, Equals, "")
+}
+
+func (s *EvalEventsSuite) TestEveryEvalReturns(c *C) {
+	SetEvalEventHandler(s.record("event-test-balance"))
+	for _, code := range []string{
+		`(quote (event-test-balance ()))`,
+		`(event-test-balance-undefined 1)`,
+		`((car '(event-test-balance)) 1)`,
+		`(let ((event-test-balance nil)) (event-test-balance 1))`,
+	} {
+		ParseAndEval(code)
+	}
+	SetEvalEventHandler(nil)
+
+	depth := 0
+	for _, event := range s.events {
+		switch event.Kind {
+		case EvalStarted:
+			depth++
+		case EvalReturned:
+			depth--
+		}
+		c.Assert(depth >= 0, Equals, true)
+	}
+	c.Assert(depth, Equals, 0)
+}
+
+func (s *EvalEventsSuite) TestJSONEvents(c *C) {
+	output := new(bytes.Buffer)
+	SetEvalEventHandler(JSONEvalEventHandler(output))
+	_, err := ParseAndEval(`(+ 1 2)`)
+	c.Assert(err, IsNil)
+	SetEvalEventHandler(nil)
+
+	var event map[string]interface{}
+	line := strings.SplitN(output.String(), "\n", 2)[0]
+	c.Assert(json.Unmarshal([]byte(line), &event), IsNil)
+	c.Assert(event["kind"], Equals, "eval")
+	c.Assert(event["expr"], Equals, "(+ 1 2)")
+}
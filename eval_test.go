@@ -26,3 +26,28 @@ func (s *EvalSuite) TestEvalWithNilFunction(c *C) {
 	c.Assert(err, NotNil)
 	c.Assert(result, IsNil)
 }
+
+func (s *EvalSuite) BenchmarkPrimitiveApplication(c *C) {
+	code, _ := Parse("(+ 1 (* 2 3) (- 10 4) (car '(1 2 3)))")
+	c.ResetTimer()
+	for i := 0; i < c.N; i++ {
+		_, _ = Eval(code, Global)
+	}
+}
+
+func (s *EvalSuite) BenchmarkFunctionApplication(c *C) {
+	ParseAndEval("(define (benchmark-fib n) (if (< n 2) n (+ (benchmark-fib (- n 1)) (benchmark-fib (- n 2)))))")
+	code, _ := Parse("(benchmark-fib 15)")
+	c.ResetTimer()
+	for i := 0; i < c.N; i++ {
+		_, _ = Eval(code, Global)
+	}
+}
+
+func (s *EvalSuite) TestArgumentCountChecking(c *C) {
+	f := &PrimitiveFunction{Name: "prim", NumberOfArgs: "1|(3,4)|>=6"}
+	for count, ok := range []bool{false, true, false, true, true, false, true, true} {
+		c.Assert(f.checkArgumentCount(count), Equals, ok, Commentf("%d args", count))
+	}
+	c.Assert((&PrimitiveFunction{NumberOfArgs: "*"}).checkArgumentCount(0), Equals, true)
+}
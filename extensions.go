@@ -0,0 +1,118 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements extensions: bundles of primitives and lisp code loaded on request.
+
+package golisp
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Extension is a named bundle of primitives and lisp code.  A host registers extensions
+// with RegisterExtension, and nothing in them is defined until a script asks for them with
+// (use-extension 'name), so the primitives available by default stay few.
+type Extension struct {
+	Name       string
+	Primitives []*PrimitiveFunction
+	Prelude    string
+	loaded     bool
+	mutex      sync.Mutex
+}
+
+var extensions = struct {
+	sync.Mutex
+	byName map[string]*Extension
+}{byName: make(map[string]*Extension)}
+
+// NewExtension makes an empty extension, ready to have primitives added to it
+func NewExtension(name string, prelude string) *Extension {
+	return &Extension{Name: name, Prelude: prelude}
+}
+
+func (self *Extension) addPrimitive(name string, argCount string, special bool, restricted bool, function func(*Data, *SymbolTableFrame) (*Data, error)) *Extension {
+	f := &PrimitiveFunction{Name: name, Special: special, NumberOfArgs: argCount, Body: function, IsRestricted: restricted}
+	self.Primitives = append(self.Primitives, f)
+	return self
+}
+
+// AddPrimitiveFunction adds a primitive, as MakePrimitiveFunction would define it, and
+// returns the extension so calls can be chained
+func (self *Extension) AddPrimitiveFunction(name string, argCount string, function func(*Data, *SymbolTableFrame) (*Data, error)) *Extension {
+	return self.addPrimitive(name, argCount, false, false, function)
+}
+
+func (self *Extension) AddRestrictedPrimitiveFunction(name string, argCount string, function func(*Data, *SymbolTableFrame) (*Data, error)) *Extension {
+	return self.addPrimitive(name, argCount, false, true, function)
+}
+
+func (self *Extension) AddSpecialForm(name string, argCount string, function func(*Data, *SymbolTableFrame) (*Data, error)) *Extension {
+	return self.addPrimitive(name, argCount, true, false, function)
+}
+
+// RegisterExtension makes extension available to use-extension
+func RegisterExtension(extension *Extension) error {
+	extensions.Lock()
+	defer extensions.Unlock()
+	if _, found := extensions.byName[extension.Name]; found {
+		return fmt.Errorf("There is already an extension named %s", extension.Name)
+	}
+	extensions.byName[extension.Name] = extension
+	return nil
+}
+
+// ExtensionNamed returns the registered extension called name, if there is one
+func ExtensionNamed(name string) *Extension {
+	extensions.Lock()
+	defer extensions.Unlock()
+	return extensions.byName[name]
+}
+
+// ExtensionNames returns the names of the registered extensions, sorted
+func ExtensionNames() []string {
+	extensions.Lock()
+	defer extensions.Unlock()
+	names := make([]string, 0, len(extensions.byName))
+	for name, _ := range extensions.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UseExtension defines the primitives of the extension called name in the global
+// environment and then evaluates its prelude there.  Using an extension that is already
+// loaded does nothing; one whose prelude failed can be tried again.
+func UseExtension(name string) error {
+	extension := ExtensionNamed(name)
+	if extension == nil {
+		return NewLispError(GeneralError, fmt.Sprintf("There is no extension named %s", name))
+	}
+
+	extension.mutex.Lock()
+	defer extension.mutex.Unlock()
+	if extension.loaded {
+		return nil
+	}
+	for _, f := range extension.Primitives {
+		Global.BindToProtected(Intern(f.Name), PrimitiveWithNameAndFunc(f.Name, f))
+	}
+	if extension.Prelude != "" {
+		if _, err := ParseAndEvalAllInEnvironment(extension.Prelude, Global); err != nil {
+			return err
+		}
+	}
+	extension.loaded = true
+	return nil
+}
+
+// Loaded reports whether the extension has been used
+func (self *Extension) Loaded() bool {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.loaded
+}
@@ -0,0 +1,66 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests extensions.
+
+package golisp
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type ExtensionsSuite struct {
+}
+
+var _ = Suite(&ExtensionsSuite{})
+
+var extensionTestLoads = 0
+
+func init() {
+	extension := NewExtension("extension-test", `(define (extension-test-twice x) (* 2 (extension-test-base x)))
+(extension-test-count-load)`)
+	extension.AddPrimitiveFunction("extension-test-base", "1", func(args *Data, env *SymbolTableFrame) (*Data, error) {
+		return IntegerWithValue(IntegerValue(Car(args)) + 1), nil
+	})
+	extension.AddPrimitiveFunction("extension-test-count-load", "0", func(args *Data, env *SymbolTableFrame) (*Data, error) {
+		extensionTestLoads++
+		return nil, nil
+	})
+	RegisterExtension(extension)
+	RegisterExtension(NewExtension("extension-test-broken", `(extension-test-undefined)`))
+}
+
+func (s *ExtensionsSuite) TestUsingAnExtension(c *C) {
+	c.Assert(NilP(Global.ValueOf(Intern("extension-test-base"))), Equals, true)
+	result, err := ParseAndEval(`(extension-loaded? 'extension-test)`)
+	c.Assert(err, IsNil)
+	c.Assert(BooleanValue(result), Equals, false)
+
+	_, err = ParseAndEval(`(use-extension 'extension-test)`)
+	c.Assert(err, IsNil)
+	_, err = ParseAndEval(`(use-extension "extension-test")`)
+	c.Assert(err, IsNil)
+	c.Assert(extensionTestLoads, Equals, 1)
+
+	result, err = ParseAndEval(`(extension-test-twice 4)`)
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(10))
+	result, err = ParseAndEval(`(extension-loaded? 'extension-test)`)
+	c.Assert(err, IsNil)
+	c.Assert(BooleanValue(result), Equals, true)
+}
+
+func (s *ExtensionsSuite) TestUnknownAndFailingExtensions(c *C) {
+	_, err := ParseAndEval(`(use-extension 'no-such-extension)`)
+	c.Assert(err, NotNil)
+	_, err = ParseAndEval(`(use-extension 'extension-test-broken)`)
+	c.Assert(err, NotNil)
+	c.Assert(ExtensionNamed("extension-test-broken").Loaded(), Equals, false)
+}
+
+func (s *ExtensionsSuite) TestDuplicateNames(c *C) {
+	c.Assert(RegisterExtension(NewExtension("extension-test", "")), NotNil)
+	c.Assert(ExtensionNames(), Not(HasLen), 0)
+}
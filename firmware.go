@@ -0,0 +1,245 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements reading firmware images in Intel HEX and raw binary form.
+
+package golisp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Intel HEX record types
+const (
+	HexData                   = 0
+	HexEndOfFile              = 1
+	HexExtendedSegmentAddress = 2
+	HexStartSegmentAddress    = 3
+	HexExtendedLinearAddress  = 4
+	HexStartLinearAddress     = 5
+)
+
+// HexRecord is one line of an Intel HEX file.  Address is the record's own 16 bit address,
+// not including the extended address set by earlier records.
+type HexRecord struct {
+	Line    int
+	Type    int
+	Address uint32
+	Data    []byte
+}
+
+// FirmwareSegment is a run of contiguous bytes at Address
+type FirmwareSegment struct {
+	Address uint32
+	Data    []byte
+}
+
+func (self *FirmwareSegment) end() uint64 {
+	return uint64(self.Address) + uint64(len(self.Data))
+}
+
+// FirmwareImage is the bytes of a firmware image, as segments sorted by address with gaps
+// between them.  HasStart is set if the image gives the address execution starts at.
+type FirmwareImage struct {
+	Segments []*FirmwareSegment
+	Start    uint32
+	HasStart bool
+}
+
+func hexError(line int, message string) error {
+	return NewLispError(ParseError, fmt.Sprintf("Bad Intel HEX at line %d: %s", line, message))
+}
+
+// ParseHexRecords reads the records of Intel HEX text, checking each one's checksum.  Blank
+// lines are skipped, and so is anything after the end of file record, which is required.
+func ParseHexRecords(text string) (records []*HexRecord, err error) {
+	for number, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if line[0] != ':' {
+			return nil, hexError(number+1, "records have to start with a colon")
+		}
+		raw, decodeErr := hex.DecodeString(line[1:])
+		if decodeErr != nil {
+			return nil, hexError(number+1, "records have to be pairs of hex digits")
+		}
+		if len(raw) < 5 || len(raw) != int(raw[0])+5 {
+			return nil, hexError(number+1, "the record's length doesn't match its byte count")
+		}
+		var sum byte
+		for _, b := range raw {
+			sum += b
+		}
+		if sum != 0 {
+			return nil, hexError(number+1, "bad checksum")
+		}
+
+		record := &HexRecord{Line: number + 1, Type: int(raw[3]), Address: uint32(raw[1])<<8 | uint32(raw[2]), Data: raw[4 : len(raw)-1]}
+		if record.Type > HexStartLinearAddress {
+			return nil, hexError(record.Line, fmt.Sprintf("unknown record type %02X", record.Type))
+		}
+		records = append(records, record)
+		if record.Type == HexEndOfFile {
+			return
+		}
+	}
+	return nil, hexError(len(strings.Split(text, "\n")), "no end of file record")
+}
+
+func bigEndianValue(bytes []byte) (value uint32) {
+	for _, b := range bytes {
+		value = value<<8 | uint32(b)
+	}
+	return
+}
+
+// ParseIntelHex reads Intel HEX text into a firmware image
+func ParseIntelHex(text string) (image *FirmwareImage, err error) {
+	records, err := ParseHexRecords(text)
+	if err != nil {
+		return
+	}
+
+	image = &FirmwareImage{}
+	var base uint32
+	expectedLength := map[int]int{HexExtendedSegmentAddress: 2, HexStartSegmentAddress: 4, HexExtendedLinearAddress: 2, HexStartLinearAddress: 4}
+	for _, record := range records {
+		if length, ok := expectedLength[record.Type]; ok && len(record.Data) != length {
+			return nil, hexError(record.Line, fmt.Sprintf("type %02X records need %d bytes of data", record.Type, length))
+		}
+		switch record.Type {
+		case HexData:
+			if err = image.add(base+record.Address, record.Data); err != nil {
+				return nil, hexError(record.Line, err.Error())
+			}
+		case HexExtendedSegmentAddress:
+			base = bigEndianValue(record.Data) << 4
+		case HexExtendedLinearAddress:
+			base = bigEndianValue(record.Data) << 16
+		case HexStartSegmentAddress:
+			image.Start = bigEndianValue(record.Data[:2])<<4 + bigEndianValue(record.Data[2:])
+			image.HasStart = true
+		case HexStartLinearAddress:
+			image.Start = bigEndianValue(record.Data)
+			image.HasStart = true
+		}
+	}
+	return
+}
+
+// BinaryFirmwareImage makes an image of raw bytes loaded at address
+func BinaryFirmwareImage(bytes []byte, address uint32) (image *FirmwareImage, err error) {
+	image = &FirmwareImage{}
+	if err = image.add(address, bytes); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// add puts bytes into the image at address, joining them to the segments they touch.  Bytes
+// can't overlap ones already in the image.
+func (self *FirmwareImage) add(address uint32, bytes []byte) error {
+	if len(bytes) == 0 {
+		return nil
+	}
+	end := uint64(address) + uint64(len(bytes))
+	if end > 1<<32 {
+		return fmt.Errorf("data runs past the end of the 32 bit address space")
+	}
+
+	i := sort.Search(len(self.Segments), func(i int) bool { return self.Segments[i].end() >= uint64(address) })
+	for j := i; j < len(self.Segments) && uint64(self.Segments[j].Address) < end; j++ {
+		if self.Segments[j].end() > uint64(address) {
+			return fmt.Errorf("data at %08X overlaps earlier data", address)
+		}
+	}
+
+	segment := &FirmwareSegment{Address: address, Data: append([]byte(nil), bytes...)}
+	if i < len(self.Segments) && self.Segments[i].end() == uint64(address) {
+		segment = self.Segments[i]
+		segment.Data = append(segment.Data, bytes...)
+	} else {
+		self.Segments = append(self.Segments, nil)
+		copy(self.Segments[i+1:], self.Segments[i:])
+		self.Segments[i] = segment
+	}
+	if i+1 < len(self.Segments) && self.Segments[i+1].Address == uint32(segment.end()) {
+		segment.Data = append(segment.Data, self.Segments[i+1].Data...)
+		self.Segments = append(self.Segments[:i+1], self.Segments[i+2:]...)
+	}
+	return nil
+}
+
+// Size is the number of bytes in the image, not counting gaps
+func (self *FirmwareImage) Size() (size int) {
+	for _, segment := range self.Segments {
+		size += len(segment.Data)
+	}
+	return
+}
+
+// MaxFirmwareSpan is the most bytes Bytes will make, as the gaps of a sparse image can make
+// its span far larger than its data
+const MaxFirmwareSpan = 64 << 20
+
+// Bytes is the part of the image from start up to end, with gaps filled with fill, or an
+// error if that is more than MaxFirmwareSpan bytes
+func (self *FirmwareImage) Bytes(start uint64, end uint64, fill byte) ([]byte, error) {
+	if end < start {
+		return []byte{}, nil
+	}
+	if end-start > MaxFirmwareSpan {
+		return nil, fmt.Errorf("%08X to %08X is more than %d bytes", start, end, MaxFirmwareSpan)
+	}
+	bytes := make([]byte, end-start)
+	for i := range bytes {
+		bytes[i] = fill
+	}
+	for _, segment := range self.Segments {
+		from, to := uint64(segment.Address), segment.end()
+		if from < start {
+			from = start
+		}
+		if to > end {
+			to = end
+		}
+		if from < to {
+			copy(bytes[from-start:], segment.Data[from-uint64(segment.Address):to-uint64(segment.Address)])
+		}
+	}
+	return bytes, nil
+}
+
+// Extent is the lowest address in the image and the address after its highest
+func (self *FirmwareImage) Extent() (start uint64, end uint64) {
+	if len(self.Segments) == 0 {
+		return 0, 0
+	}
+	return uint64(self.Segments[0].Address), self.Segments[len(self.Segments)-1].end()
+}
+
+// Chunks splits the image into blocks of at most size bytes for transfer.  Blocks are
+// aligned to multiples of size and never span a gap, so the first and last block of a
+// segment can be shorter.
+func (self *FirmwareImage) Chunks(size int) (chunks []*FirmwareSegment) {
+	for _, segment := range self.Segments {
+		address := uint64(segment.Address)
+		for address < segment.end() {
+			next := (address/uint64(size) + 1) * uint64(size)
+			if next > segment.end() {
+				next = segment.end()
+			}
+			offset := address - uint64(segment.Address)
+			chunks = append(chunks, &FirmwareSegment{Address: uint32(address), Data: segment.Data[offset : offset+next-address]})
+			address = next
+		}
+	}
+	return
+}
@@ -0,0 +1,92 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests reading firmware images.
+
+package golisp
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type FirmwareSuite struct {
+}
+
+var _ = Suite(&FirmwareSuite{})
+
+const sampleHex = `:020000040800F2
+:0400000001020304F2
+:0400040005060708DE
+:02001000AABB89
+:0400000508000004EB
+:00000001FF
+`
+
+func (s *FirmwareSuite) TestParseIntelHex(c *C) {
+	image, err := ParseIntelHex(sampleHex)
+	c.Assert(err, IsNil)
+	c.Assert(image.Segments, HasLen, 2)
+	c.Assert(image.Segments[0].Address, Equals, uint32(0x08000000))
+	c.Assert(image.Segments[0].Data, DeepEquals, []byte{1, 2, 3, 4, 5, 6, 7, 8})
+	c.Assert(image.Segments[1].Address, Equals, uint32(0x08000010))
+	c.Assert(image.Size(), Equals, 10)
+	c.Assert(image.HasStart, Equals, true)
+	c.Assert(image.Start, Equals, uint32(0x08000004))
+}
+
+func (s *FirmwareSuite) TestSegmentAddressing(c *C) {
+	image, err := ParseIntelHex(":020000021000EC\n:020002000909EA\n:0400000312340010A3\n:00000001FF\n")
+	c.Assert(err, IsNil)
+	c.Assert(image.Segments[0].Address, Equals, uint32(0x10002))
+	c.Assert(image.Start, Equals, uint32(0x12350))
+}
+
+func (s *FirmwareSuite) TestBadRecords(c *C) {
+	_, err := ParseIntelHex(":0400000001020304F3\n:00000001FF\n")
+	c.Assert(err, ErrorMatches, ".*line 1: bad checksum")
+	_, err = ParseIntelHex(":0400000001020304F2\n")
+	c.Assert(err, ErrorMatches, ".*no end of file record")
+	_, err = ParseIntelHex("0400000001020304F2\n")
+	c.Assert(err, ErrorMatches, ".*start with a colon")
+	_, err = ParseIntelHex(":0500000001020304F2\n")
+	c.Assert(err, ErrorMatches, ".*length doesn't match.*")
+	_, err = ParseIntelHex(":0400000001020304F2\n:020002000909EA\n:00000001FF\n")
+	c.Assert(err, ErrorMatches, ".*line 2: data at 00000002 overlaps earlier data")
+}
+
+func (s *FirmwareSuite) TestAddingJoinsSegments(c *C) {
+	image := &FirmwareImage{}
+	c.Assert(image.add(10, []byte{3}), IsNil)
+	c.Assert(image.add(8, []byte{1}), IsNil)
+	c.Assert(image.add(9, []byte{2}), IsNil)
+	c.Assert(image.Segments, HasLen, 1)
+	c.Assert(image.Segments[0].Address, Equals, uint32(8))
+	c.Assert(image.Segments[0].Data, DeepEquals, []byte{1, 2, 3})
+	c.Assert(image.add(7, []byte{0, 0}), NotNil)
+}
+
+func (s *FirmwareSuite) TestChunks(c *C) {
+	image, _ := BinaryFirmwareImage([]byte{1, 2, 3, 4, 5, 6, 7}, 6)
+	chunks := image.Chunks(4)
+	c.Assert(chunks, HasLen, 3)
+	c.Assert(chunks[0].Address, Equals, uint32(6))
+	c.Assert(chunks[0].Data, DeepEquals, []byte{1, 2})
+	c.Assert(chunks[1].Address, Equals, uint32(8))
+	c.Assert(chunks[1].Data, DeepEquals, []byte{3, 4, 5, 6})
+	c.Assert(chunks[2].Data, DeepEquals, []byte{7})
+}
+
+func (s *FirmwareSuite) TestBytesOfSparseImage(c *C) {
+	image := &FirmwareImage{}
+	c.Assert(image.add(0x08000000, []byte{1, 2}), IsNil)
+	c.Assert(image.add(0x1FFF0000, []byte{3}), IsNil)
+	start, end := image.Extent()
+	_, err := image.Bytes(start, end, 0xFF)
+	c.Assert(err, ErrorMatches, "08000000 to 1FFF0001 is more than .* bytes")
+
+	bytes, err := image.Bytes(0x08000000, 0x08000004, 0xFF)
+	c.Assert(err, IsNil)
+	c.Assert(bytes, DeepEquals, []byte{1, 2, 0xFF, 0xFF})
+}
@@ -0,0 +1,104 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements the control of how floats are printed.
+
+package golisp
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type FloatNotation int
+
+const (
+	// GeneralNotation uses scientific notation only for large exponents, like %g
+	GeneralNotation FloatNotation = iota
+	FixedNotation
+	ScientificNotation
+)
+
+var floatNotationNames = map[FloatNotation]string{
+	GeneralNotation:    "general",
+	FixedNotation:      "fixed",
+	ScientificNotation: "scientific",
+}
+
+func (self FloatNotation) String() string {
+	return floatNotationNames[self]
+}
+
+// FloatFormat controls how floats are printed.  Precision is the number of digits after the
+// decimal point for fixed and scientific notation, and the number of significant digits for
+// general notation.  A negative precision uses the fewest digits that read back as the same
+// float.  TrimZeros drops trailing zeros after the decimal point.
+type FloatFormat struct {
+	Precision int
+	Notation  FloatNotation
+	TrimZeros bool
+}
+
+var DefaultFloatFormat = FloatFormat{Precision: -1, Notation: GeneralNotation}
+
+var floatFormat = struct {
+	sync.RWMutex
+	format FloatFormat
+}{format: DefaultFloatFormat}
+
+// SetFloatFormat changes how floats are printed by String and friends
+func SetFloatFormat(format FloatFormat) {
+	floatFormat.Lock()
+	floatFormat.format = format
+	floatFormat.Unlock()
+}
+
+func CurrentFloatFormat() FloatFormat {
+	floatFormat.RLock()
+	defer floatFormat.RUnlock()
+	return floatFormat.format
+}
+
+// FormatFloat prints f as described by format.  The result always has a decimal point, so
+// it reads back as a float rather than an integer.
+func FormatFloat(f float32, format FloatFormat) string {
+	v := float64(f)
+	if math.IsInf(v, 0) {
+		if math.Signbit(v) {
+			return "-inf"
+		}
+		return "+inf"
+	}
+	if math.IsNaN(v) {
+		return "nan"
+	}
+
+	var verb byte
+	switch format.Notation {
+	case FixedNotation:
+		verb = 'f'
+	case ScientificNotation:
+		verb = 'e'
+	default:
+		verb = 'g'
+	}
+	raw := strconv.FormatFloat(v, verb, format.Precision, 32)
+
+	mantissa, exponent := raw, ""
+	if i := strings.IndexByte(raw, 'e'); i >= 0 {
+		mantissa, exponent = raw[:i], raw[i:]
+	}
+	if !strings.ContainsRune(mantissa, '.') {
+		mantissa += ".0"
+	} else if format.TrimZeros {
+		mantissa = strings.TrimRight(mantissa, "0")
+		if strings.HasSuffix(mantissa, ".") {
+			mantissa += "0"
+		}
+	}
+	return mantissa + exponent
+}
@@ -66,3 +66,20 @@ func (s *FloatAtomSuite) TestBooleanValue(c *C) {
 	c.Assert(BooleanValue(s.neg), Equals, true)
 	c.Assert(BooleanValue(s.zero), Equals, true)
 }
+
+func (s *FloatAtomSuite) TestFormatFloat(c *C) {
+	c.Assert(FormatFloat(5.3, DefaultFloatFormat), Equals, "5.3")
+	c.Assert(FormatFloat(2.0, DefaultFloatFormat), Equals, "2.0")
+	c.Assert(FormatFloat(1e6, DefaultFloatFormat), Equals, "1.0e+06")
+	c.Assert(FormatFloat(2.5, FloatFormat{Precision: 3, Notation: FixedNotation}), Equals, "2.500")
+	c.Assert(FormatFloat(2.5, FloatFormat{Precision: 3, Notation: FixedNotation, TrimZeros: true}), Equals, "2.5")
+	c.Assert(FormatFloat(2.0, FloatFormat{Precision: 3, Notation: FixedNotation, TrimZeros: true}), Equals, "2.0")
+	c.Assert(FormatFloat(1234.5, FloatFormat{Precision: 2, Notation: ScientificNotation}), Equals, "1.23e+03")
+	c.Assert(FormatFloat(2.0, FloatFormat{Precision: 0, Notation: FixedNotation}), Equals, "2.0")
+}
+
+func (s *FloatAtomSuite) TestStringUsesFloatFormat(c *C) {
+	defer SetFloatFormat(CurrentFloatFormat())
+	SetFloatFormat(FloatFormat{Precision: 2, Notation: FixedNotation})
+	c.Assert(String(s.n), Equals, "5.30")
+}
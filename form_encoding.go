@@ -0,0 +1,159 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements converting frames to and from query strings and multipart forms.
+
+package golisp
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// formFieldValues returns the values a slot contributes to a form: one for a single value,
+// one per element for a list or vector, and none for nil
+func formFieldValues(d *Data) (values []string) {
+	var elements []*Data
+	switch {
+	case NilP(d):
+		return nil
+	case VectorP(d):
+		elements = VectorValue(d)
+	case ListP(d):
+		elements = ToArray(d)
+	default:
+		elements = []*Data{d}
+	}
+	for _, element := range elements {
+		values = append(values, PrintString(element))
+	}
+	return
+}
+
+// sortedSlots returns the names of frame's own slots, other than functions and parent
+// slots, without their trailing colons and in name order, along with their values
+func sortedSlots(frame *FrameMap) (names []string, values map[string]*Data) {
+	frame.Mutex.RLock()
+	defer frame.Mutex.RUnlock()
+	values = make(map[string]*Data, len(frame.Data))
+	for k, v := range frame.Data {
+		if FunctionP(v) || strings.HasSuffix(k, "*:") {
+			continue
+		}
+		name := strings.TrimRight(k, ":")
+		names = append(names, name)
+		values[name] = v
+	}
+	sort.Strings(names)
+	return
+}
+
+// FrameToQueryString encodes the slots of frame as a URL query string, without a leading
+// "?", in slot name order.  List and vector values repeat their key for each element.
+func FrameToQueryString(frame *FrameMap) string {
+	query := url.Values{}
+	names, values := sortedSlots(frame)
+	for _, name := range names {
+		for _, value := range formFieldValues(values[name]) {
+			query.Add(name, value)
+		}
+	}
+	return query.Encode()
+}
+
+// ParseQueryString decodes a URL query string, with or without a leading "?", into a frame
+// whose slots hold a string for keys given once and a list of strings for repeated keys
+func ParseQueryString(query string) (result *Data, err error) {
+	values, parseErr := url.ParseQuery(strings.TrimPrefix(query, "?"))
+	if parseErr != nil {
+		return nil, NewLispError(ParseError, fmt.Sprintf("Bad query string: %s", parseErr))
+	}
+	m := FrameMap{}
+	m.Data = make(FrameMapData, len(values))
+	for key, given := range values {
+		if len(given) == 1 {
+			m.Data[key+":"] = StringWithValue(given[0])
+			continue
+		}
+		elements := make([]*Data, 0, len(given))
+		for _, s := range given {
+			elements = append(elements, StringWithValue(s))
+		}
+		m.Data[key+":"] = ArrayToList(elements)
+	}
+	return FrameWithValue(&m), nil
+}
+
+// MultipartForm encodes the slots of frame as multipart/form-data, returning the body and
+// the content type, which includes the boundary.  A slot whose value is a frame with a
+// content: slot, a string or bytearray, becomes a file part named by its filename: slot and
+// typed by its content-type: slot; other slots are fields like in FrameToQueryString.
+func MultipartForm(frame *FrameMap) (body []byte, contentType string, err error) {
+	var buffer bytes.Buffer
+	writer := multipart.NewWriter(&buffer)
+	names, values := sortedSlots(frame)
+	for _, name := range names {
+		value := values[name]
+		if FrameP(value) && FrameValue(value).HasSlot("content:") {
+			if err = writeFilePart(writer, name, FrameValue(value)); err != nil {
+				return
+			}
+			continue
+		}
+		for _, field := range formFieldValues(value) {
+			if err = writer.WriteField(name, field); err != nil {
+				return nil, "", ioError(err)
+			}
+		}
+	}
+	if err = writer.Close(); err != nil {
+		return nil, "", ioError(err)
+	}
+	return buffer.Bytes(), writer.FormDataContentType(), nil
+}
+
+func writeFilePart(writer *multipart.Writer, name string, file *FrameMap) (err error) {
+	var content []byte
+	switch data := file.Get("content:"); {
+	case StringP(data):
+		content = []byte(StringValue(data))
+	case ObjectP(data) && ObjectType(data) == "[]byte":
+		content = *(*[]byte)(ObjectValue(data))
+	default:
+		return NewLispError(TypeError, fmt.Sprintf("The content of form file %s has to be a string or bytearray but was %s.", name, String(data)))
+	}
+
+	filename := name
+	if file.HasSlot("filename:") {
+		filename = PrintString(file.Get("filename:"))
+	}
+	contentType := "application/octet-stream"
+	if file.HasSlot("content-type:") {
+		contentType = PrintString(file.Get("content-type:"))
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(name), escapeQuotes(filename)))
+	header.Set("Content-Type", contentType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return ioError(err)
+	}
+	if _, err = part.Write(content); err != nil {
+		return ioError(err)
+	}
+	return nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
@@ -0,0 +1,46 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests encoding multipart forms.
+
+package golisp
+
+import (
+	"bytes"
+	. "gopkg.in/check.v1"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+)
+
+type FormEncodingSuite struct {
+}
+
+var _ = Suite(&FormEncodingSuite{})
+
+func (s *FormEncodingSuite) TestMultipartForm(c *C) {
+	frame, err := ParseAndEval(`{name: "K1" ports: '(1 2) firmware: {filename: "k1.bin" content-type: "application/x-firmware" content: [1 2 3]}}`)
+	c.Assert(err, IsNil)
+	body, contentType, err := MultipartForm(FrameValue(frame))
+	c.Assert(err, IsNil)
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	c.Assert(err, IsNil)
+	c.Assert(mediaType, Equals, "multipart/form-data")
+	form, err := multipart.NewReader(bytes.NewReader(body), params["boundary"]).ReadForm(1024)
+	c.Assert(err, IsNil)
+
+	c.Assert(form.Value["name"], DeepEquals, []string{"K1"})
+	c.Assert(form.Value["ports"], DeepEquals, []string{"1", "2"})
+	c.Assert(form.File["firmware"], HasLen, 1)
+	file := form.File["firmware"][0]
+	c.Assert(file.Filename, Equals, "k1.bin")
+	c.Assert(file.Header.Get("Content-Type"), Equals, "application/x-firmware")
+	opened, err := file.Open()
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(opened)
+	c.Assert(err, IsNil)
+	c.Assert(content, DeepEquals, []byte{1, 2, 3})
+}
@@ -0,0 +1,434 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements the control strings of format, after Common Lisp's.
+
+package golisp
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A directive is ~, then parameters separated by commas, then : and @ modifiers, then a
+// character naming it.  A parameter is an integer, 'c for a character, V to take it from the
+// next argument, or # for the number of arguments left.  The directives are:
+//
+//   ~mincol,colinc,minpad,padcharA   an argument as display shows it, @ pads on the left
+//   ~mincol,colinc,minpad,padcharS   an argument as write shows it
+//   ~mincol,padchar,commachar,intervalD   an integer in decimal, @ adds a + to positive
+//                                    numbers and : separates groups of digits with commas
+//   ~B, ~O, ~X                       the same in binary, octal and hexadecimal
+//   ~w,d,k,overflowchar,padcharF     a number with d digits after the point in a field w
+//                                    wide, scaled by 10^k
+//   ~n%                              n newlines
+//   ~n~                              n tildes
+//   ~newline                         skips the newline and the whitespace after it; @ keeps
+//                                    the newline
+//   ~n{body~}                        formats body with the elements of a list as its
+//                                    arguments, until they run out or after n times.  @ uses
+//                                    the rest of the arguments instead of a list, : takes
+//                                    each element as a list of arguments, and ~:} formats
+//                                    body at least once.
+//   ~^                               stops the enclosing ~{ or the control string if there
+//                                    are no arguments left
+
+// Numeric parameters, such as widths and counts, can be at most this large, so that a
+// control string can't ask for unbounded amounts of output
+const maxFormatParameter = 1 << 20
+
+type formatParam struct {
+	value int
+	given bool
+}
+
+type formatDirective struct {
+	params   []formatParam
+	colon    bool
+	at       bool
+	char     byte
+	position int
+}
+
+func (self *formatDirective) param(i int, otherwise int) int {
+	if i < len(self.params) && self.params[i].given {
+		return self.params[i].value
+	}
+	return otherwise
+}
+
+type formatter struct {
+	out strings.Builder
+	env *SymbolTableFrame
+}
+
+func (self *formatter) errorAt(position int, message string) error {
+	return ProcessError(fmt.Sprintf("format %s at index %d", message, position), self.env)
+}
+
+// parseDirective reads the directive whose ~ is at start, taking V parameters from args.
+// When scanning, V parameters are left unset instead.
+func (self *formatter) parseDirective(control string, start int, args []*Data, scanning bool) (directive *formatDirective, rest []*Data, next int, err error) {
+	directive = &formatDirective{position: start}
+	rest = args
+	i := start + 1
+	for {
+		param := formatParam{}
+		switch {
+		case i < len(control) && (unicode.IsDigit(rune(control[i])) || control[i] == '-' || control[i] == '+'):
+			j := i + 1
+			for j < len(control) && unicode.IsDigit(rune(control[j])) {
+				j++
+			}
+			var n int64
+			if n, err = strconv.ParseInt(control[i:j], 10, 32); err != nil {
+				err = self.errorAt(i, "expected a number")
+				return
+			}
+			if n > maxFormatParameter || n < -maxFormatParameter {
+				err = self.errorAt(i, fmt.Sprintf("expected a parameter of at most %d", maxFormatParameter))
+				return
+			}
+			param = formatParam{int(n), true}
+			i = j
+		case i+1 < len(control) && control[i] == '\'':
+			c, size := utf8.DecodeRuneInString(control[i+1:])
+			param = formatParam{int(c), true}
+			i += 1 + size
+		case i < len(control) && (control[i] == 'V' || control[i] == 'v') && scanning:
+			i++
+		case i < len(control) && (control[i] == 'V' || control[i] == 'v'):
+			if len(rest) == 0 || !(IntegerP(rest[0]) || CharacterP(rest[0]) || NilP(rest[0])) {
+				err = self.errorAt(i, "encountered a size argument mismatch")
+				return
+			}
+			if IntegerP(rest[0]) {
+				if n := IntegerValue(rest[0]); n > maxFormatParameter || n < -maxFormatParameter {
+					err = self.errorAt(i, fmt.Sprintf("expected a parameter of at most %d", maxFormatParameter))
+					return
+				}
+				param = formatParam{int(IntegerValue(rest[0])), true}
+			} else if CharacterP(rest[0]) {
+				param = formatParam{int(CharacterValue(rest[0])), true}
+			}
+			rest = rest[1:]
+			i++
+		case i < len(control) && control[i] == '#':
+			param = formatParam{len(rest), true}
+			i++
+		}
+		directive.params = append(directive.params, param)
+		if i >= len(control) || control[i] != ',' {
+			break
+		}
+		i++
+	}
+	for ; i < len(control) && (control[i] == ':' || control[i] == '@'); i++ {
+		if control[i] == ':' {
+			directive.colon = true
+		} else {
+			directive.at = true
+		}
+	}
+	if i >= len(control) {
+		err = self.errorAt(start, "found an unfinished directive")
+		return
+	}
+	directive.char = control[i]
+	return directive, rest, i + 1, nil
+}
+
+// iterationEnd finds the ~} closing the ~{ whose body starts at start
+func (self *formatter) iterationEnd(control string, start int) (bodyEnd int, directive *formatDirective, next int, err error) {
+	depth := 0
+	for i := start; i < len(control); i++ {
+		if control[i] != '~' {
+			continue
+		}
+		var d *formatDirective
+		var after int
+		if d, _, after, err = self.parseDirective(control, i, nil, true); err != nil {
+			return
+		}
+		switch d.char {
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return i, d, after, nil
+			}
+			depth--
+		}
+		i = after - 1
+	}
+	err = self.errorAt(start, "found a ~{ without a ~}")
+	return
+}
+
+func formatPad(s string, mincol int, colinc int, minpad int, padchar rune, left bool) string {
+	if colinc < 1 {
+		colinc = 1
+	}
+	length := utf8.RuneCountInString(s)
+	pad := minpad
+	for length+pad < mincol {
+		pad += colinc
+	}
+	if pad <= 0 {
+		return s
+	}
+	padding := strings.Repeat(string(padchar), pad)
+	if left {
+		return padding + s
+	}
+	return s + padding
+}
+
+func groupDigits(digits string, separator rune, interval int) string {
+	if interval < 1 || len(digits) <= interval {
+		return digits
+	}
+	var b strings.Builder
+	for i, c := range digits {
+		if i > 0 && (len(digits)-i)%interval == 0 {
+			b.WriteRune(separator)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func (self *formatter) formatInteger(directive *formatDirective, arg *Data, base int) string {
+	mincol, padchar := directive.param(0, 0), rune(directive.param(1, ' '))
+	if !ExactIntegerP(arg) {
+		return formatPad(PrintString(arg), mincol, 1, 0, padchar, true)
+	}
+	n := bigIntegerOf(arg)
+	digits := strings.ToUpper(strings.TrimPrefix(n.Text(base), "-"))
+	if directive.colon {
+		digits = groupDigits(digits, rune(directive.param(2, ',')), directive.param(3, 3))
+	}
+	if n.Sign() < 0 {
+		digits = "-" + digits
+	} else if directive.at {
+		digits = "+" + digits
+	}
+	return formatPad(digits, mincol, 1, 0, padchar, true)
+}
+
+func (self *formatter) formatFloat(directive *formatDirective, arg *Data) string {
+	width, digits, scale := directive.param(0, -1), directive.param(1, -1), directive.param(2, 0)
+	overflow, padchar := directive.param(3, -1), rune(directive.param(4, ' '))
+	var value float64
+	bits := 64
+	switch {
+	case IntegerP(arg):
+		value = float64(IntegerValue(arg))
+	case BigIntegerP(arg):
+		value = bigIntegerFloat(BigIntegerValue(arg))
+	case FloatP(arg):
+		value = float64(FloatValue(arg))
+		bits = 32
+	default:
+		return formatPad(PrintString(arg), directive.param(0, 0), 1, 0, padchar, true)
+	}
+	value *= math.Pow10(scale)
+
+	sign := ""
+	if value < 0 || (value == 0 && math.Signbit(value)) {
+		sign = "-"
+	} else if directive.at {
+		sign = "+"
+	}
+	text := strconv.FormatFloat(math.Abs(value), 'f', digits, bits)
+	if digits < 0 && width >= 0 && len(sign)+len(text) > width {
+		// as many digits after the point as fit
+		point := strings.IndexByte(text, '.')
+		if point < 0 {
+			point = len(text)
+		}
+		fit := width - len(sign) - point - 1
+		if fit < 1 {
+			fit = 1
+		}
+		text = strconv.FormatFloat(math.Abs(value), 'f', fit, bits)
+	}
+	if digits == 0 {
+		text += "."
+	} else if !strings.ContainsAny(text, ".IN") {
+		text += ".0"
+	}
+	text = sign + text
+
+	if width >= 0 && len(text) > width && overflow >= 0 {
+		return strings.Repeat(string(rune(overflow)), width)
+	}
+	return formatPad(text, width, 1, 0, padchar, true)
+}
+
+// iterate formats body for each step of a ~{ iteration
+func (self *formatter) iterate(directive *formatDirective, closing *formatDirective, body string, items []*Data) (rest []*Data, err error) {
+	limit := directive.param(0, -1)
+	for steps := 0; limit < 0 || steps < limit; steps++ {
+		if len(items) == 0 && !(steps == 0 && closing.colon) {
+			break
+		}
+		if directive.colon {
+			if len(items) == 0 {
+				_, _, err = self.format(body, nil, 0)
+				return
+			}
+			if !ListP(items[0]) {
+				err = self.errorAt(directive.position, fmt.Sprintf("~:{ expected a list of arguments but received %s", String(items[0])))
+				return
+			}
+			if _, _, err = self.format(body, ToArray(items[0]), 0); err != nil {
+				return
+			}
+			items = items[1:]
+			continue
+		}
+
+		var left []*Data
+		var escaped bool
+		if left, escaped, err = self.format(body, items, 0); err != nil {
+			return
+		}
+		if escaped {
+			return left, nil
+		}
+		if len(left) == len(items) && len(items) > 0 {
+			err = self.errorAt(directive.position, "~{ body has to use at least one argument")
+			return
+		}
+		items = left
+	}
+	return items, nil
+}
+
+// format writes control, starting at start, with args.  It returns the arguments it didn't
+// use, and whether it stopped early at a ~^.
+func (self *formatter) format(control string, args []*Data, start int) (rest []*Data, escaped bool, err error) {
+	next := func(position int) (arg *Data, err error) {
+		if len(args) == 0 {
+			return nil, self.errorAt(position, "ran out of arguments")
+		}
+		arg = args[0]
+		args = args[1:]
+		return
+	}
+
+	i := start
+	for i < len(control) {
+		tilde := strings.IndexByte(control[i:], '~')
+		if tilde < 0 {
+			self.out.WriteString(control[i:])
+			break
+		}
+		self.out.WriteString(control[i : i+tilde])
+
+		var directive *formatDirective
+		if directive, args, i, err = self.parseDirective(control, i+tilde, args, false); err != nil {
+			return
+		}
+
+		var arg *Data
+		switch directive.char {
+		case 'A', 'a', 'S', 's':
+			if arg, err = next(directive.position); err != nil {
+				return
+			}
+			text := PrintString(arg)
+			if directive.char == 'S' || directive.char == 's' {
+				text = String(arg)
+			}
+			self.out.WriteString(formatPad(text, directive.param(0, 0), directive.param(1, 1), directive.param(2, 0), rune(directive.param(3, ' ')), directive.at))
+
+		case 'D', 'd', 'B', 'b', 'O', 'o', 'X', 'x':
+			if arg, err = next(directive.position); err != nil {
+				return
+			}
+			base := map[byte]int{'D': 10, 'B': 2, 'O': 8, 'X': 16}[byte(unicode.ToUpper(rune(directive.char)))]
+			self.out.WriteString(self.formatInteger(directive, arg, base))
+
+		case 'F', 'f':
+			if arg, err = next(directive.position); err != nil {
+				return
+			}
+			self.out.WriteString(self.formatFloat(directive, arg))
+
+		case '%':
+			self.out.WriteString(strings.Repeat("\n", directive.param(0, 1)))
+
+		case '~':
+			self.out.WriteString(strings.Repeat("~", directive.param(0, 1)))
+
+		case '\n':
+			for i < len(control) && unicode.IsSpace(rune(control[i])) {
+				i++
+			}
+			if directive.at {
+				self.out.WriteString("\n")
+			}
+
+		case '{':
+			var bodyEnd, after int
+			var closing *formatDirective
+			if bodyEnd, closing, after, err = self.iterationEnd(control, i); err != nil {
+				return
+			}
+			body := control[i:bodyEnd]
+			if directive.at {
+				if args, err = self.iterate(directive, closing, body, args); err != nil {
+					return
+				}
+			} else {
+				if arg, err = next(directive.position); err != nil {
+					return
+				}
+				if !ListP(arg) {
+					err = self.errorAt(directive.position, fmt.Sprintf("~{ expected a list but received %s", String(arg)))
+					return
+				}
+				if _, err = self.iterate(directive, closing, body, ToArray(arg)); err != nil {
+					return
+				}
+			}
+			i = after
+
+		case '}':
+			err = self.errorAt(directive.position, "found a ~} without a ~{")
+			return
+
+		case '^':
+			if len(args) == 0 {
+				return args, true, nil
+			}
+
+		default:
+			err = self.errorAt(directive.position+1, "encountered an unsupported substitution")
+			return
+		}
+	}
+	return args, false, nil
+}
+
+// Format expands control with args, which have to all be used
+func Format(control string, args []*Data, env *SymbolTableFrame) (result string, err error) {
+	f := &formatter{env: env}
+	rest, _, err := f.format(control, args, 0)
+	if err != nil {
+		return
+	}
+	if len(rest) > 0 {
+		err = ProcessError("number of replacements in the control string and number of arguments must be equal", env)
+		return
+	}
+	return f.out.String(), nil
+}
@@ -11,15 +11,28 @@ import (
 	"gopkg.in/fatih/set.v0"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 type FrameMapData map[string]*Data
 
 type FrameMap struct {
-	Data  FrameMapData
-	Mutex sync.RWMutex
+	Data      FrameMapData
+	Mutex     sync.RWMutex
+	observers []slotObserver
 }
 
+// SlotChangeHandler is called after a slot is set, with the slot's previous and new values
+type SlotChangeHandler func(frame *FrameMap, key string, oldValue *Data, newValue *Data)
+
+type slotObserver struct {
+	id      int64
+	key     string
+	handler SlotChangeHandler
+}
+
+var nextSlotObserverId int64
+
 func (self *FrameMap) hasSlotLocally(key string) bool {
 	self.Mutex.RLock()
 	_, ok := self.Data[key]
@@ -156,13 +169,49 @@ func (self *FrameMap) Remove(key string) bool {
 
 func (self *FrameMap) Set(key string, value *Data) *Data {
 	self.Mutex.Lock()
+	oldValue := self.Data[key]
 	self.Data[key] = value
+	observers := self.observers
 	self.Mutex.Unlock()
+
+	for _, observer := range observers {
+		if observer.key == key {
+			observer.handler(self, key, oldValue, value)
+		}
+	}
 	return value
 }
 
 //------------------------------------------------------------
 
+// OnSlotChange arranges for handler to be called whenever key is set in this frame.
+// The returned id can be passed to RemoveSlotChangeHandler.
+func (self *FrameMap) OnSlotChange(key string, handler SlotChangeHandler) int64 {
+	id := atomic.AddInt64(&nextSlotObserverId, 1)
+	self.Mutex.Lock()
+	observers := make([]slotObserver, len(self.observers), len(self.observers)+1)
+	copy(observers, self.observers)
+	self.observers = append(observers, slotObserver{id: id, key: key, handler: handler})
+	self.Mutex.Unlock()
+	return id
+}
+
+func (self *FrameMap) RemoveSlotChangeHandler(id int64) bool {
+	self.Mutex.Lock()
+	defer self.Mutex.Unlock()
+	for i, observer := range self.observers {
+		if observer.id == id {
+			observers := make([]slotObserver, 0, len(self.observers)-1)
+			observers = append(observers, self.observers[:i]...)
+			self.observers = append(observers, self.observers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+//------------------------------------------------------------
+
 func (self *FrameMap) Clone() *FrameMap {
 	f := FrameMap{}
 	f.Data = make(FrameMapData)
@@ -175,21 +224,24 @@ func (self *FrameMap) Clone() *FrameMap {
 }
 
 func (self *FrameMap) Keys() []*Data {
-	self.Mutex.RLock()
-	keys := make([]*Data, 0, len(self.Data))
-	for k, _ := range self.Data {
+	slots := stableOrder(self.localSlots())
+	keys := make([]*Data, 0, len(slots))
+	for _, k := range slots {
 		keys = append(keys, Intern(k))
 	}
-	self.Mutex.RUnlock()
 	return keys
 }
 
 func (self *FrameMap) Values() []*Data {
 	self.Mutex.RLock()
-	values := make([]*Data, 0, len(self.Data))
-	for _, v := range self.Data {
-		values = append(values, v)
+	defer self.Mutex.RUnlock()
+	slots := make([]string, 0, len(self.Data))
+	for k, _ := range self.Data {
+		slots = append(slots, k)
+	}
+	values := make([]*Data, 0, len(slots))
+	for _, k := range stableOrder(slots) {
+		values = append(values, self.Data[k])
 	}
-	self.Mutex.RUnlock()
 	return values
 }
@@ -8,7 +8,6 @@
 package golisp
 
 import (
-	"errors"
 	"fmt"
 	"sync/atomic"
 	"unsafe"
@@ -49,16 +48,25 @@ func (self *Function) String() string {
 	return fmt.Sprintf("<func: %s>", self.Name)
 }
 
-func (self *Function) makeLocalBindings(args *Data, argEnv *SymbolTableFrame, localEnv *SymbolTableFrame, eval bool) (err error) {
+// arityError returns an ArityError if the function can't be called with argCount
+// arguments
+func (self *Function) arityError(argCount int) error {
 	if self.VarArgs {
-		if Length(args) < self.RequiredArgCount {
-			return errors.New(fmt.Sprintf("%s expected at least %d parameters, received %d.", self.Name, self.RequiredArgCount, Length(args)))
+		if argCount < self.RequiredArgCount {
+			return NewLispError(ArityError, fmt.Sprintf("%s expected at least %d parameters, received %d.", self.Name, self.RequiredArgCount, argCount))
 		}
 	} else {
-		if Length(args) != self.RequiredArgCount {
-			return errors.New(fmt.Sprintf("%s expected %d parameters, received %d.", self.Name, self.RequiredArgCount, Length(args)))
+		if argCount != self.RequiredArgCount {
+			return NewLispError(ArityError, fmt.Sprintf("%s expected %d parameters, received %d.", self.Name, self.RequiredArgCount, argCount))
 		}
 	}
+	return nil
+}
+
+func (self *Function) makeLocalBindings(args *Data, argEnv *SymbolTableFrame, localEnv *SymbolTableFrame, eval bool) (err error) {
+	if err = self.arityError(Length(args)); err != nil {
+		return
+	}
 
 	var argValue *Data
 	var accumulatingParam *Data = nil
@@ -99,7 +107,10 @@ func (self *Function) makeLocalBindings(args *Data, argEnv *SymbolTableFrame, lo
 
 func (self *Function) internalApply(args *Data, argEnv *SymbolTableFrame, frame *FrameMap, eval bool) (result *Data, err error) {
 	localEnv := NewSymbolTableFrameBelowWithFrame(self.Env, frame, self.Name)
-	localEnv.Previous = argEnv
+	localEnv.function = self
+	if err = localEnv.callFrom(argEnv); err != nil {
+		return
+	}
 	selfSym := Intern("self")
 	if frame != nil {
 		_, err = localEnv.BindLocallyTo(selfSym, FrameWithValue(frame))
@@ -109,7 +120,7 @@ func (self *Function) internalApply(args *Data, argEnv *SymbolTableFrame, frame
 	} else if atomic.LoadInt32(&self.SlotFunction) == 1 {
 		selfBinding, found := argEnv.findBindingInLocalFrameFor(selfSym)
 		if found {
-			_, err = localEnv.BindLocallyTo(selfSym, selfBinding.Val)
+			_, err = localEnv.BindLocallyTo(selfSym, selfBinding.Value())
 			if err != nil {
 				return
 			}
@@ -133,16 +144,18 @@ func (self *Function) internalApply(args *Data, argEnv *SymbolTableFrame, frame
 	localGuid := atomic.AddInt64(&ProfileGUID, 1) - 1
 
 	ProfileEnter("func", self.Name, localGuid)
+	started := profileStarted()
 
 	for s := self.Body; NotNilP(s); s = Cdr(s) {
 		result, err = Eval(Car(s), localEnv)
 		if err != nil {
-			result, err = nil, errors.New(fmt.Sprintf("In '%s': %s", self.Name, err))
+			result, err = nil, addErrorContext(err, fmt.Sprintf("In '%s': ", self.Name))
 			break
 		}
 	}
 
 	ProfileExit("func", self.Name, localGuid)
+	profileFinished(localEnv, started)
 
 	return
 }
@@ -165,6 +178,10 @@ func (self *Function) ApplyWithoutEvalWithFrame(args *Data, argEnv *SymbolTableF
 
 func (self *Function) ApplyOveriddingEnvironment(args *Data, argEnv *SymbolTableFrame) (result *Data, err error) {
 	localEnv := NewSymbolTableFrameBelow(argEnv, self.Name)
+	localEnv.function = self
+	if err = localEnv.callFrom(argEnv); err != nil {
+		return
+	}
 	err = self.makeLocalBindings(args, argEnv, localEnv, true)
 	if err != nil {
 		return
@@ -173,16 +190,18 @@ func (self *Function) ApplyOveriddingEnvironment(args *Data, argEnv *SymbolTable
 	localGuid := atomic.AddInt64(&ProfileGUID, 1) - 1
 
 	ProfileEnter("func", self.Name, localGuid)
+	started := profileStarted()
 
 	for s := self.Body; NotNilP(s); s = Cdr(s) {
 		result, err = Eval(Car(s), localEnv)
 		if err != nil {
-			result, err = nil, errors.New(fmt.Sprintf("In '%s': %s", self.Name, err))
+			result, err = nil, addErrorContext(err, fmt.Sprintf("In '%s': ", self.Name))
 			break
 		}
 	}
 
 	ProfileExit("func", self.Name, localGuid)
+	profileFinished(localEnv, started)
 
 	return
 }
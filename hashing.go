@@ -0,0 +1,261 @@
+// Copyright 2014 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements hashing and the equality protocol for user types.
+
+package golisp
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"math/big"
+	"sort"
+	"sync"
+	"unsafe"
+)
+
+// ObjectEquality lets Go code define equal? and hashing for a type of boxed object.
+// Objects that are equal must hash the same.
+type ObjectEquality struct {
+	Equal func(a unsafe.Pointer, b unsafe.Pointer) bool
+	Hash  func(o unsafe.Pointer) uint64
+	// HashWithDepth, if set, is used instead of Hash for objects that contain other data.
+	// It is given how much deeper hashing can go, for hashing the contents with hashHelper.
+	HashWithDepth func(o unsafe.Pointer, depth int) uint64
+	// EqualInProgress, if set, is used instead of Equal for objects that contain other data.
+	// It is given the pairs already being compared, for comparing the contents with
+	// isDeepEqual, so that comparing circular structure terminates.
+	EqualInProgress func(a unsafe.Pointer, b unsafe.Pointer, inProgress map[[2]unsafe.Pointer]bool) bool
+}
+
+var objectEqualities = make(map[string]*ObjectEquality)
+var objectEqualitiesMutex sync.RWMutex
+
+// RegisterObjectEquality installs equality and hash functions for boxed objects created
+// with ObjectWithTypeAndValue(typeName, ...).
+func RegisterObjectEquality(typeName string, equal func(a unsafe.Pointer, b unsafe.Pointer) bool, hash func(o unsafe.Pointer) uint64) {
+	objectEqualitiesMutex.Lock()
+	objectEqualities[typeName] = &ObjectEquality{Equal: equal, Hash: hash}
+	objectEqualitiesMutex.Unlock()
+}
+
+// registerObjectHashWithDepth installs a hash function for boxed objects that contain other
+// data, which might contain the object itself
+func registerObjectHashWithDepth(typeName string, hash func(o unsafe.Pointer, depth int) uint64) {
+	objectEqualitiesMutex.Lock()
+	defer objectEqualitiesMutex.Unlock()
+	if equality := objectEqualities[typeName]; equality != nil {
+		equality.HashWithDepth = hash
+	} else {
+		objectEqualities[typeName] = &ObjectEquality{HashWithDepth: hash}
+	}
+}
+
+// registerObjectEqualInProgress installs an equality function for boxed objects that contain
+// other data, which might contain the object itself
+func registerObjectEqualInProgress(typeName string, equal func(a unsafe.Pointer, b unsafe.Pointer, inProgress map[[2]unsafe.Pointer]bool) bool) {
+	objectEqualitiesMutex.Lock()
+	defer objectEqualitiesMutex.Unlock()
+	if equality := objectEqualities[typeName]; equality != nil {
+		equality.EqualInProgress = equal
+	} else {
+		objectEqualities[typeName] = &ObjectEquality{EqualInProgress: equal}
+	}
+}
+
+func objectEqualityFor(typeName string) *ObjectEquality {
+	objectEqualitiesMutex.RLock()
+	defer objectEqualitiesMutex.RUnlock()
+	return objectEqualities[typeName]
+}
+
+// Frames can take part in the protocol by defining equal?: and hash: slots, either
+// directly or through a parent.
+
+func frameProtocolFunction(f *Data, selector string) *Data {
+	fun := FrameValue(f).Get(selector)
+	if FunctionP(fun) {
+		return fun
+	}
+	return nil
+}
+
+func sendToFrame(f *Data, fun *Data, args *Data) (result *Data, err error) {
+	return FunctionValue(fun).ApplyWithoutEvalWithFrame(args, Global, FrameValue(f))
+}
+
+// customEqual reports whether d has user supplied equality, and if so what it says about o
+func customEqual(d *Data, o *Data, inProgress map[[2]unsafe.Pointer]bool) (handled bool, equal bool) {
+	if ObjectP(d) {
+		equality := objectEqualityFor(ObjectType(d))
+		switch {
+		case equality == nil:
+			return false, false
+		case equality.EqualInProgress != nil:
+			return true, ObjectType(d) == ObjectType(o) && equality.EqualInProgress(ObjectValue(d), ObjectValue(o), inProgress)
+		case equality.Equal != nil:
+			return true, ObjectType(d) == ObjectType(o) && equality.Equal(ObjectValue(d), ObjectValue(o))
+		}
+		return false, false
+	}
+
+	if FrameP(d) {
+		fun := frameProtocolFunction(d, "equal?:")
+		if fun == nil {
+			return false, false
+		}
+		result, err := sendToFrame(d, fun, InternalMakeList(o))
+		return true, err == nil && BooleanValue(result)
+	}
+
+	return false, false
+}
+
+// customHash reports whether d has a user supplied hash, and if so what it is
+func customHash(d *Data, depth int) (handled bool, hash uint64) {
+	if ObjectP(d) {
+		equality := objectEqualityFor(ObjectType(d))
+		switch {
+		case equality == nil:
+			return false, 0
+		case equality.HashWithDepth != nil:
+			return true, equality.HashWithDepth(ObjectValue(d), depth)
+		case equality.Hash != nil:
+			return true, equality.Hash(ObjectValue(d))
+		}
+		return false, 0
+	}
+
+	if FrameP(d) {
+		fun := frameProtocolFunction(d, "hash:")
+		if fun == nil {
+			return false, 0
+		}
+		result, err := sendToFrame(d, fun, nil)
+		if err != nil {
+			return true, 0
+		}
+		return true, uint64(IntegerValue(result))
+	}
+
+	return false, 0
+}
+
+// Structure is only hashed this deep (and this many elements wide), so hashing always
+// terminates, even on circular data.
+const maxHashDepth = 4
+const maxHashElements = 16
+
+// Hash computes a hash code that is consistent with IsDeepEqual: data that is equal?
+// hashes the same.
+func Hash(d *Data) uint64 {
+	return hashHelper(d, maxHashDepth)
+}
+
+func hashBytes(kind byte, b []byte) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte{kind})
+	h.Write(b)
+	return h.Sum64()
+}
+
+func hashUint64(kind byte, n uint64) uint64 {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], n)
+	return hashBytes(kind, buf[:])
+}
+
+func combineHashes(h uint64, other uint64) uint64 {
+	return h*31 + other
+}
+
+func hashHelper(d *Data, depth int) uint64 {
+	if NilP(d) {
+		return hashUint64('n', 0)
+	}
+
+	if handled, h := customHash(d, depth); handled {
+		return h
+	}
+
+	switch d.Type {
+	case IntegerType:
+		return hashUint64('#', uint64(IntegerValue(d)))
+	case FloatType:
+		f := float64(FloatValue(d))
+		if f == math.Trunc(f) && !math.IsInf(f, 0) {
+			// integral floats hash like the integers they are equal? to; 2^63 itself is
+			// beyond an int64, so is a BigInteger
+			if f >= math.MinInt64 && f < -math.MinInt64 {
+				return hashUint64('#', uint64(int64(f)))
+			}
+			n, _ := new(big.Float).SetFloat64(f).Int(nil)
+			return hashBigInteger(n)
+		}
+		return hashUint64('f', math.Float64bits(f))
+	case BooleanType:
+		if BooleanValue(d) {
+			return hashUint64('b', 1)
+		}
+		return hashUint64('b', 0)
+	case CharacterType:
+		return hashUint64('c', uint64(CharacterValue(d)))
+	case StringType:
+		return hashBytes('s', []byte(StringValue(d)))
+	case SymbolType:
+		return hashBytes('y', []byte(StringValue(d)))
+	case ConsCellType, AlistType, AlistCellType:
+		h := hashUint64('l', 0)
+		if depth == 0 {
+			return h
+		}
+		count := 0
+		var c *Data
+		for c = d; isPairType(c) && NotNilP(c) && count < maxHashElements; c = Cdr(c) {
+			h = combineHashes(h, hashHelper(Car(c), depth-1))
+			count++
+		}
+		if count < maxHashElements && NotNilP(c) {
+			h = combineHashes(h, hashHelper(c, depth-1))
+		}
+		return h
+	case VectorType:
+		h := hashUint64('v', uint64(Length(d)))
+		if depth == 0 {
+			return h
+		}
+		for i, element := range VectorValue(d) {
+			if i == maxHashElements {
+				break
+			}
+			h = combineHashes(h, hashHelper(element, depth-1))
+		}
+		return h
+	case FrameType:
+		frame := FrameValue(d).Clone().Data
+		h := hashUint64('{', uint64(len(frame)))
+		if depth == 0 {
+			return h
+		}
+		keys := make([]string, 0, len(frame))
+		for k, _ := range frame {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			h = combineHashes(h, hashBytes('y', []byte(k)))
+			h = combineHashes(h, hashHelper(frame[k], depth-1))
+		}
+		return h
+	case BoxedObjectType:
+		if ObjectType(d) == "[]byte" {
+			return hashBytes('[', *(*[]byte)(ObjectValue(d)))
+		}
+		return hashUint64('o', uint64(uintptr(ObjectValue(d))))
+	}
+
+	return hashUint64('p', uint64(uintptr(d.Value)))
+}
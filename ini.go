@@ -0,0 +1,66 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements reading INI files into frames.
+
+package golisp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// IniToLisp reads INI text into a frame.  Keys before the first [section] header are slots
+// of the frame itself, and each section is a slot holding a frame of its keys.  Values are
+// strings, with surrounding quotes removed; lines starting with ; or # are comments.
+func IniToLisp(text string) (result *Data, err error) {
+	root := &FrameMap{Data: make(FrameMapData)}
+	section := root
+	for number, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, iniError(number, "unterminated section header")
+			}
+			name := strings.TrimSpace(line[1:len(line)-1]) + ":"
+			if existing, found := root.Data[name]; found && FrameP(existing) {
+				section = FrameValue(existing)
+			} else {
+				section = &FrameMap{Data: make(FrameMapData)}
+				root.Data[name] = FrameWithValue(section)
+			}
+			continue
+		}
+
+		separator := strings.IndexAny(line, "=:")
+		if separator <= 0 {
+			return nil, iniError(number, "expected key = value")
+		}
+		key := strings.TrimSpace(line[:separator])
+		value := strings.TrimSpace(line[separator+1:])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			if value[0] == '"' {
+				if unquoted, err := strconv.Unquote(value); err == nil {
+					value = unquoted
+				} else {
+					value = value[1 : len(value)-1]
+				}
+			} else {
+				value = value[1 : len(value)-1]
+			}
+		}
+		section.Data[key+":"] = StringWithValue(value)
+	}
+	return FrameWithValue(root), nil
+}
+
+func iniError(lineIndex int, message string) error {
+	return NewLispError(ParseError, fmt.Sprintf("Bad INI at line %d: %s", lineIndex+1, message))
+}
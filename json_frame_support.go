@@ -11,6 +11,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"math/big"
 	"strings"
 )
 
@@ -38,7 +39,7 @@ func JsonToLispWithFrames(json interface{}) (result *Data) {
 
 	numValue, ok := json.(float64)
 	if ok {
-		if math.Trunc(numValue) == numValue {
+		if math.Trunc(numValue) == numValue && numValue >= math.MinInt64 && numValue < -math.MinInt64 {
 			return IntegerWithValue(int64(numValue))
 		} else {
 			return FloatWithValue(float32(numValue))
@@ -54,6 +55,16 @@ func JsonToLispWithFrames(json interface{}) (result *Data) {
 		}
 	}
 
+	intValue, ok := json.(int64)
+	if ok {
+		return IntegerWithValue(intValue)
+	}
+
+	bigValue, ok := json.(*big.Int)
+	if ok {
+		return BigIntegerWithValue(bigValue)
+	}
+
 	strValue, ok := json.(string)
 	if ok {
 		return StringWithValue(strValue)
@@ -72,7 +83,7 @@ func JsonStringToLispWithFrames(jsonData string) (result *Data) {
 	var data interface{}
 	err := json.Unmarshal(b, &data)
 	if err != nil {
-		fmt.Printf("Returning empty frame because of badly formed json: '%s'\n --> %v\n", jsonData, err)
+		fmt.Fprintf(ErrorWriter(), "Returning empty frame because of badly formed json: '%s'\n --> %v\n", jsonData, err)
 		m := FrameMap{}
 		m.Data = make(FrameMapData, 0)
 		return FrameWithValue(&m)
@@ -89,6 +100,10 @@ func LispWithFramesToJson(d *Data) (result interface{}) {
 		return IntegerValue(d)
 	}
 
+	if BigIntegerP(d) {
+		return BigIntegerValue(d)
+	}
+
 	if FloatP(d) {
 		return FloatValue(d)
 	}
@@ -0,0 +1,86 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements a regular expression driven lexer.
+
+package golisp
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// LexerRule turns text matching Pattern into a token of type Type, or drops it if Skip is
+// set, e.g. for whitespace
+type LexerRule struct {
+	Pattern *regexp.Regexp
+	Type    string
+	Skip    bool
+}
+
+// Token is a piece of lexed text, made by Rule.  Offset is the byte offset of Text in the
+// input, and Line and Column (both starting at 1, Column counting bytes) are where it starts.
+type Token struct {
+	Type   string
+	Text   string
+	Offset int
+	Line   int
+	Column int
+	Rule   *LexerRule
+}
+
+// Lexer splits text into tokens.  At each point the rule with the longest match wins, and
+// of those the one that was added first.
+type Lexer struct {
+	Rules []*LexerRule
+}
+
+// AddRule adds a rule for text matching pattern, which is implicitly anchored to where the
+// lexer is in its input.  An empty tokenType makes a rule whose matches are skipped.
+func (self *Lexer) AddRule(pattern string, tokenType string) error {
+	re, err := regexp.Compile(`^(?:` + pattern + `)`)
+	if err != nil {
+		return err
+	}
+	self.Rules = append(self.Rules, &LexerRule{Pattern: re, Type: tokenType, Skip: tokenType == ""})
+	return nil
+}
+
+// Tokenize returns the tokens of input, or an error pointing at the first text no rule
+// matches
+func (self *Lexer) Tokenize(input string) (tokens []Token, err error) {
+	tokens = make([]Token, 0)
+	line, column := 1, 1
+	for offset := 0; offset < len(input); {
+		rest := input[offset:]
+		var rule *LexerRule
+		length := 0
+		for _, r := range self.Rules {
+			if match := r.Pattern.FindStringIndex(rest); match != nil && match[1] > length {
+				rule, length = r, match[1]
+			}
+		}
+		if rule == nil {
+			excerpt := rest
+			if len(excerpt) > 20 {
+				excerpt = excerpt[:20] + "..."
+			}
+			return nil, NewLispError(ParseError, fmt.Sprintf("No lexer rule matches at line %d, column %d: %q", line, column, excerpt))
+		}
+		text := rest[:length]
+		if !rule.Skip {
+			tokens = append(tokens, Token{Type: rule.Type, Text: text, Offset: offset, Line: line, Column: column, Rule: rule})
+		}
+		if newlines := strings.Count(text, "\n"); newlines > 0 {
+			line += newlines
+			column = len(text) - strings.LastIndex(text, "\n")
+		} else {
+			column += len(text)
+		}
+		offset += length
+	}
+	return
+}
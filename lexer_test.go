@@ -0,0 +1,47 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests the lexer.
+
+package golisp
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type LexerSuite struct {
+	lexer *Lexer
+}
+
+var _ = Suite(&LexerSuite{})
+
+func (s *LexerSuite) SetUpTest(c *C) {
+	s.lexer = &Lexer{}
+	c.Assert(s.lexer.AddRule(`[0-9]+`, "number"), IsNil)
+	c.Assert(s.lexer.AddRule(`[a-z]+`, "word"), IsNil)
+	c.Assert(s.lexer.AddRule(`\s+`, ""), IsNil)
+}
+
+func (s *LexerSuite) TestTokenize(c *C) {
+	tokens, err := s.lexer.Tokenize("port 10\n  up")
+	c.Assert(err, IsNil)
+	c.Assert(tokens, HasLen, 3)
+	c.Assert(tokens[1].Type, Equals, "number")
+	c.Assert(tokens[1].Text, Equals, "10")
+	c.Assert(tokens[1].Offset, Equals, 5)
+	c.Assert(tokens[2].Line, Equals, 2)
+	c.Assert(tokens[2].Column, Equals, 3)
+	c.Assert(tokens[2].Rule, Equals, s.lexer.Rules[1])
+}
+
+func (s *LexerSuite) TestUnmatchedText(c *C) {
+	_, err := s.lexer.Tokenize("port\n#10")
+	c.Assert(ErrorCategoryOf(err), Equals, ParseError)
+	c.Assert(err, ErrorMatches, `No lexer rule matches at line 2, column 1: "#10"`)
+}
+
+func (s *LexerSuite) TestBadPattern(c *C) {
+	c.Assert(s.lexer.AddRule(`[0-9`, "number"), NotNil)
+}
@@ -22,26 +22,42 @@ func init() {
 }
 
 func LogPrintf(format string, a ...interface{}) {
-	fmt.Printf(format, a...)
+	fmt.Fprintf(OutputWriter(), format, a...)
 	for _, logger := range loggers {
 		logger.Printf(format, a...)
 	}
 }
 
 func LogPrint(a ...interface{}) {
-	fmt.Print(a...)
+	fmt.Fprint(OutputWriter(), a...)
 	for _, logger := range loggers {
 		logger.Print(a...)
 	}
 }
 
 func LogPrintln(a ...interface{}) {
-	fmt.Println(a...)
+	fmt.Fprintln(OutputWriter(), a...)
 	for _, logger := range loggers {
 		logger.Println(a...)
 	}
 }
 
+// LogErrorf reports a failure that has no caller to return it to
+func LogErrorf(format string, a ...interface{}) {
+	fmt.Fprintf(ErrorWriter(), format, a...)
+	for _, logger := range loggers {
+		logger.Printf(format, a...)
+	}
+}
+
+// LogWarnf reports something that works but should be fixed, such as use of a deprecated name
+func LogWarnf(format string, a ...interface{}) {
+	fmt.Fprintf(ErrorWriter(), format, a...)
+	for _, logger := range loggers {
+		logger.Printf(format, a...)
+	}
+}
+
 func AddLog(newLog *log.Logger) {
 	loggers = append(loggers, newLog)
 }
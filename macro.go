@@ -8,7 +8,6 @@
 package golisp
 
 import (
-	"errors"
 	"fmt"
 )
 
@@ -19,11 +18,87 @@ type Macro struct {
 	RequiredArgCount int
 	Body             *Data
 	Env              *SymbolTableFrame
+	lambdaList       macroLambdaList
+}
+
+// A macro's lambda list is its required parameters, followed by any number of optional
+// ones after &optional, each a name or a (name default) pair, and then a rest parameter
+// named after &rest or after a dot.
+type macroLambdaList struct {
+	required []*Data
+	optional []macroOptionalParam
+	rest     *Data
+}
+
+type macroOptionalParam struct {
+	name         *Data
+	defaultValue *Data
+}
+
+func parseMacroLambdaList(params *Data) (lambdaList macroLambdaList, err error) {
+	const (
+		requiredParams = iota
+		optionalParams
+		restParam
+		afterRestParam
+	)
+	state := requiredParams
+	cell := params
+	for ; PairP(cell) && NotNilP(cell); cell = Cdr(cell) {
+		param := Car(cell)
+		if SymbolP(param) && StringValue(param) == "&optional" {
+			if state != requiredParams {
+				return lambdaList, NewLispError(ParseError, "&optional has to come before &rest and can only appear once in a macro lambda list")
+			}
+			state = optionalParams
+			continue
+		}
+		if SymbolP(param) && StringValue(param) == "&rest" {
+			if state == restParam || state == afterRestParam {
+				return lambdaList, NewLispError(ParseError, "&rest can only appear once in a macro lambda list")
+			}
+			state = restParam
+			continue
+		}
+		switch state {
+		case requiredParams:
+			if !SymbolP(param) {
+				return lambdaList, NewLispError(ParseError, fmt.Sprintf("macro parameters have to be symbols, but found %s", String(param)))
+			}
+			lambdaList.required = append(lambdaList.required, param)
+		case optionalParams:
+			if SymbolP(param) {
+				lambdaList.optional = append(lambdaList.optional, macroOptionalParam{name: param})
+			} else if PairP(param) && SymbolP(Car(param)) && Length(param) == 2 {
+				lambdaList.optional = append(lambdaList.optional, macroOptionalParam{name: Car(param), defaultValue: Cadr(param)})
+			} else {
+				return lambdaList, NewLispError(ParseError, fmt.Sprintf("optional macro parameters have to be a symbol or a (symbol default) pair, but found %s", String(param)))
+			}
+		case restParam:
+			if !SymbolP(param) {
+				return lambdaList, NewLispError(ParseError, fmt.Sprintf("the &rest macro parameter has to be a symbol, but found %s", String(param)))
+			}
+			lambdaList.rest = param
+			state = afterRestParam
+		case afterRestParam:
+			return lambdaList, NewLispError(ParseError, fmt.Sprintf("a macro lambda list can only have one parameter after &rest, but found %s", String(param)))
+		}
+	}
+	if SymbolP(cell) {
+		if state == restParam || state == afterRestParam {
+			return lambdaList, NewLispError(ParseError, "a macro lambda list can't have both &rest and a dotted rest parameter")
+		}
+		lambdaList.rest = cell
+	} else if state == restParam {
+		return lambdaList, NewLispError(ParseError, "&rest has to be followed by a parameter name in a macro lambda list")
+	}
+	return
 }
 
 func MakeMacro(name string, params *Data, body *Data, parentEnv *SymbolTableFrame) *Macro {
-	requiredArgs, varArgs := computeRequiredArgumentCount(params)
-	return &Macro{Name: name, Params: params, VarArgs: varArgs, RequiredArgCount: requiredArgs, Body: body, Env: parentEnv}
+	// DefmacroImpl reports malformed lambda lists before the macro gets made
+	lambdaList, _ := parseMacroLambdaList(params)
+	return &Macro{Name: name, Params: params, VarArgs: lambdaList.rest != nil || len(lambdaList.optional) > 0, RequiredArgCount: len(lambdaList.required), Body: body, Env: parentEnv, lambdaList: lambdaList}
 }
 
 func (self *Macro) String() string {
@@ -31,55 +106,70 @@ func (self *Macro) String() string {
 }
 
 func (self *Macro) makeLocalBindings(args *Data, argEnv *SymbolTableFrame, localEnv *SymbolTableFrame, eval bool) (err error) {
-	if self.VarArgs {
-		if Length(args) < self.RequiredArgCount {
-			return errors.New(fmt.Sprintf("%s expected at least %d parameters, received %d.", self.Name, self.RequiredArgCount, Length(args)))
+	argCount := Length(args)
+	required := len(self.lambdaList.required)
+	optional := len(self.lambdaList.optional)
+	if argCount < required {
+		if self.VarArgs {
+			return NewLispError(ArityError, fmt.Sprintf("%s expected at least %d parameters, received %d.", self.Name, required, argCount))
 		}
-	} else {
-		if Length(args) != self.RequiredArgCount {
-			return errors.New(fmt.Sprintf("%s expected %d parameters, received %d.", self.Name, self.RequiredArgCount, Length(args)))
+		return NewLispError(ArityError, fmt.Sprintf("%s expected %d parameters, received %d.", self.Name, required, argCount))
+	}
+	if self.lambdaList.rest == nil && argCount > required+optional {
+		if optional > 0 {
+			return NewLispError(ArityError, fmt.Sprintf("%s expected at most %d parameters, received %d.", self.Name, required+optional, argCount))
 		}
+		return NewLispError(ArityError, fmt.Sprintf("%s expected %d parameters, received %d.", self.Name, required, argCount))
 	}
 
-	var argValue *Data
-	var accumulatingParam *Data = nil
-	accumulatedArgs := make([]*Data, 0)
-	for p, a := self.Params, args; NotNilP(a); a = Cdr(a) {
+	argValues := make([]*Data, 0, argCount)
+	for a := args; NotNilP(a); a = Cdr(a) {
 		if eval {
-			argValue, err = Eval(Car(a), argEnv)
+			argValue, err := Eval(Car(a), argEnv)
 			if err != nil {
-				return
+				return err
 			}
+			argValues = append(argValues, argValue)
 		} else {
-			argValue = Car(a)
+			argValues = append(argValues, Car(a))
 		}
-		if accumulatingParam != nil {
-			accumulatedArgs = append(accumulatedArgs, argValue)
-		} else {
-			_, err = localEnv.BindLocallyTo(Car(p), argValue)
+	}
+
+	for i, param := range self.lambdaList.required {
+		if _, err = localEnv.BindLocallyTo(param, argValues[i]); err != nil {
+			return
+		}
+	}
+	argValues = argValues[required:]
+
+	// defaults are evaluated in the macro's environment, so they can refer to earlier parameters
+	for _, param := range self.lambdaList.optional {
+		var value *Data
+		if len(argValues) > 0 {
+			value = argValues[0]
+			argValues = argValues[1:]
+		} else if param.defaultValue != nil {
+			value, err = Eval(param.defaultValue, localEnv)
 			if err != nil {
 				return
 			}
 		}
-		if accumulatingParam == nil {
-			p = Cdr(p)
-		}
-		if SymbolP(p) {
-			accumulatingParam = p
-		}
-	}
-	if accumulatingParam != nil {
-		_, err = localEnv.BindLocallyTo(accumulatingParam, ArrayToList(accumulatedArgs))
-		if err != nil {
+		if _, err = localEnv.BindLocallyTo(param.name, value); err != nil {
 			return
 		}
 	}
 
-	return nil
+	if self.lambdaList.rest != nil {
+		_, err = localEnv.BindLocallyTo(self.lambdaList.rest, ArrayToList(argValues))
+	}
+	return
 }
 
 func (self *Macro) Expand(args *Data, argEnv *SymbolTableFrame) (result *Data, err error) {
 	localEnv := NewSymbolTableFrameBelow(self.Env, self.Name)
+	if err = localEnv.callFrom(argEnv); err != nil {
+		return
+	}
 	err = self.makeLocalBindings(args, argEnv, localEnv, false)
 	if err != nil {
 		return
@@ -16,6 +16,7 @@ import (
 var (
 	runTests     bool = false
 	verboseTests bool = false
+	skipInit     bool = false
 )
 
 func test() {
@@ -40,10 +41,16 @@ func test() {
 func main() {
 	flag.BoolVar(&runTests, "t", false, "Whether to run tests and exit.  Defaults to false.")
 	flag.BoolVar(&verboseTests, "v", false, "Whether tests should be verbose.  Defaults to false.")
+	flag.BoolVar(&golisp.Config.StrictArityChecking, "strict", false, "Whether loading a file fails on calls with the wrong number of arguments.  Defaults to false.")
+	flag.BoolVar(&skipInit, "norc", false, "Whether to skip loading ~/.golisprc and ./.golisprc.  Defaults to false.")
 	flag.Parse()
 	if runTests {
 		test()
 	} else {
+		if !skipInit {
+			golisp.LoadInitFiles()
+		}
+
 		for i := 0; i < flag.NArg(); i = i + 1 {
 			fmt.Printf("Loading %s\n", flag.Arg(i))
 			_, err := golisp.ProcessFile(flag.Arg(i))
@@ -0,0 +1,246 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements the registry of counters, gauges, and histograms that scripts report.
+
+package golisp
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type MetricKind int
+
+const (
+	CounterMetric MetricKind = iota
+	GaugeMetric
+	HistogramMetric
+)
+
+var metricKindNames = map[MetricKind]string{
+	CounterMetric:   "counter",
+	GaugeMetric:     "gauge",
+	HistogramMetric: "histogram",
+}
+
+func (self MetricKind) String() string {
+	return metricKindNames[self]
+}
+
+// DefaultHistogramBuckets are the upper bounds used for histograms that aren't given any
+var DefaultHistogramBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metric is a counter, which only goes up; a gauge, which is set to whatever it currently
+// measures; or a histogram, which counts observations into buckets by upper bound.
+type Metric struct {
+	Name    string
+	Kind    MetricKind
+	Help    string
+	Buckets []float64
+	mutex   sync.Mutex
+	value   float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+// MetricSnapshot is a copy of a metric's state at one time.  Counts has a count per bucket,
+// each including the observations counted in the ones before it, as Prometheus expects.
+type MetricSnapshot struct {
+	Name    string    `json:"name"`
+	Kind    string    `json:"kind"`
+	Help    string    `json:"help,omitempty"`
+	Value   float64   `json:"value"`
+	Buckets []float64 `json:"buckets,omitempty"`
+	Counts  []uint64  `json:"counts,omitempty"`
+	Sum     float64   `json:"sum,omitempty"`
+	Count   uint64    `json:"count,omitempty"`
+}
+
+var metrics = struct {
+	sync.Mutex
+	byName map[string]*Metric
+}{byName: make(map[string]*Metric)}
+
+// DefineMetric registers a metric, or returns the existing one with that name if it is
+// the same kind.  buckets is only used for histograms, and must be increasing.
+func DefineMetric(name string, kind MetricKind, help string, buckets []float64) (metric *Metric, err error) {
+	metrics.Lock()
+	defer metrics.Unlock()
+	if existing, found := metrics.byName[name]; found {
+		if existing.Kind != kind {
+			return nil, fmt.Errorf("%s is already a %s", name, existing.Kind)
+		}
+		return existing, nil
+	}
+
+	metric = &Metric{Name: name, Kind: kind, Help: help}
+	if kind == HistogramMetric {
+		if buckets == nil {
+			buckets = DefaultHistogramBuckets
+		}
+		for i := 1; i < len(buckets); i++ {
+			if buckets[i] <= buckets[i-1] {
+				return nil, fmt.Errorf("histogram buckets have to be increasing")
+			}
+		}
+		metric.Buckets = append([]float64(nil), buckets...)
+		metric.counts = make([]uint64, len(buckets))
+	}
+	metrics.byName[name] = metric
+	return
+}
+
+// LookupMetric returns the metric registered as name, if there is one
+func LookupMetric(name string) *Metric {
+	metrics.Lock()
+	defer metrics.Unlock()
+	return metrics.byName[name]
+}
+
+// ResetMetrics forgets every metric
+func ResetMetrics() {
+	metrics.Lock()
+	defer metrics.Unlock()
+	metrics.byName = make(map[string]*Metric)
+}
+
+// Add increases a counter, or changes a gauge, by amount
+func (self *Metric) Add(amount float64) error {
+	if self.Kind == HistogramMetric {
+		return fmt.Errorf("%s is a histogram, which can only observe values", self.Name)
+	}
+	if self.Kind == CounterMetric && amount < 0 {
+		return fmt.Errorf("%s is a counter, which can't be decreased", self.Name)
+	}
+	self.mutex.Lock()
+	self.value += amount
+	self.mutex.Unlock()
+	return nil
+}
+
+// Set sets a gauge to value
+func (self *Metric) Set(value float64) error {
+	if self.Kind != GaugeMetric {
+		return fmt.Errorf("%s is a %s, which can't be set", self.Name, self.Kind)
+	}
+	self.mutex.Lock()
+	self.value = value
+	self.mutex.Unlock()
+	return nil
+}
+
+// Observe records value in a histogram
+func (self *Metric) Observe(value float64) error {
+	if self.Kind != HistogramMetric {
+		return fmt.Errorf("%s is a %s, which can't observe values", self.Name, self.Kind)
+	}
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if i := sort.SearchFloat64s(self.Buckets, value); i < len(self.counts) {
+		self.counts[i]++
+	}
+	self.sum += value
+	self.count++
+	return nil
+}
+
+func (self *Metric) Snapshot() MetricSnapshot {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	snapshot := MetricSnapshot{Name: self.Name, Kind: self.Kind.String(), Help: self.Help, Value: self.value}
+	if self.Kind == HistogramMetric {
+		snapshot.Buckets = append([]float64(nil), self.Buckets...)
+		snapshot.Counts = make([]uint64, len(self.counts))
+		var cumulative uint64
+		for i, count := range self.counts {
+			cumulative += count
+			snapshot.Counts[i] = cumulative
+		}
+		snapshot.Sum = self.sum
+		snapshot.Count = self.count
+	}
+	return snapshot
+}
+
+// MetricsSnapshot returns the state of every metric, sorted by name
+func MetricsSnapshot() []MetricSnapshot {
+	metrics.Lock()
+	all := make([]*Metric, 0, len(metrics.byName))
+	for _, metric := range metrics.byName {
+		all = append(all, metric)
+	}
+	metrics.Unlock()
+
+	snapshots := make([]MetricSnapshot, 0, len(all))
+	for _, metric := range all {
+		snapshots = append(snapshots, metric.Snapshot())
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Name < snapshots[j].Name })
+	return snapshots
+}
+
+// PublishMetrics makes the metrics available through expvar as name, e.g. "golisp", so they
+// are served at /debug/vars along with the host's own.  It can only be called once per name.
+func PublishMetrics(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} { return MetricsSnapshot() }))
+}
+
+func formatMetricValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// prometheusName makes name a valid Prometheus metric name, [a-zA-Z_:][a-zA-Z0-9_:]*, by
+// replacing other characters, such as the dashes in lisp style names, with underscores and
+// putting an underscore before a leading digit
+func prometheusName(name string) string {
+	valid := []byte(name)
+	for i, ch := range valid {
+		if !(ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || ch == '_' || ch == ':') {
+			valid[i] = '_'
+		}
+	}
+	if len(valid) == 0 || valid[0] >= '0' && valid[0] <= '9' {
+		return "_" + string(valid)
+	}
+	return string(valid)
+}
+
+var prometheusHelpEscaper = strings.NewReplacer("\\", "\\\\", "\n", "\\n")
+
+// WritePrometheusMetrics writes every metric in the Prometheus text exposition format, for
+// a host to serve on its metrics endpoint
+func WritePrometheusMetrics(w io.Writer) error {
+	for _, metric := range MetricsSnapshot() {
+		name := prometheusName(metric.Name)
+		if metric.Help != "" {
+			if _, err := fmt.Fprintf(w, "# HELP %s %s\n", name, prometheusHelpEscaper.Replace(metric.Help)); err != nil {
+				return ioError(err)
+			}
+		}
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, metric.Kind)
+		if metric.Kind != HistogramMetric.String() {
+			fmt.Fprintf(w, "%s %s\n", name, formatMetricValue(metric.Value))
+			continue
+		}
+		for i, bound := range metric.Buckets {
+			fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, formatMetricValue(bound), metric.Counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, metric.Count)
+		fmt.Fprintf(w, "%s_sum %s\n", name, formatMetricValue(metric.Sum))
+		fmt.Fprintf(w, "%s_count %d\n", name, metric.Count)
+	}
+	return nil
+}
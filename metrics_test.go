@@ -0,0 +1,97 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests the metrics registry.
+
+package golisp
+
+import (
+	"bytes"
+	. "gopkg.in/check.v1"
+)
+
+type MetricsSuite struct {
+}
+
+var _ = Suite(&MetricsSuite{})
+
+func (s *MetricsSuite) SetUpTest(c *C) {
+	ResetMetrics()
+}
+
+func (s *MetricsSuite) TearDownTest(c *C) {
+	ResetMetrics()
+}
+
+func (s *MetricsSuite) TestCounter(c *C) {
+	counter, err := DefineMetric("jobs_total", CounterMetric, "", nil)
+	c.Assert(err, IsNil)
+	c.Assert(counter.Add(2), IsNil)
+	c.Assert(counter.Add(1), IsNil)
+	c.Assert(counter.Add(-1), NotNil)
+	c.Assert(counter.Set(5), NotNil)
+	c.Assert(counter.Snapshot().Value, Equals, 3.0)
+}
+
+func (s *MetricsSuite) TestRedefining(c *C) {
+	gauge, err := DefineMetric("temperature", GaugeMetric, "", nil)
+	c.Assert(err, IsNil)
+	again, err := DefineMetric("temperature", GaugeMetric, "", nil)
+	c.Assert(err, IsNil)
+	c.Assert(again, Equals, gauge)
+	_, err = DefineMetric("temperature", CounterMetric, "", nil)
+	c.Assert(err, NotNil)
+}
+
+func (s *MetricsSuite) TestHistogram(c *C) {
+	_, err := DefineMetric("bad", HistogramMetric, "", []float64{1, 1})
+	c.Assert(err, NotNil)
+
+	histogram, err := DefineMetric("latency", HistogramMetric, "", []float64{1, 5})
+	c.Assert(err, IsNil)
+	for _, value := range []float64{0.5, 1, 3, 10} {
+		c.Assert(histogram.Observe(value), IsNil)
+	}
+	snapshot := histogram.Snapshot()
+	c.Assert(snapshot.Counts, DeepEquals, []uint64{2, 3})
+	c.Assert(snapshot.Count, Equals, uint64(4))
+	c.Assert(snapshot.Sum, Equals, 14.5)
+}
+
+func (s *MetricsSuite) TestPrometheusFormat(c *C) {
+	counter, _ := DefineMetric("provisioned_total", CounterMetric, "Devices provisioned.", nil)
+	counter.Add(7)
+	histogram, _ := DefineMetric("provision_seconds", HistogramMetric, "", []float64{0.5, 2})
+	histogram.Observe(1)
+
+	var buf bytes.Buffer
+	c.Assert(WritePrometheusMetrics(&buf), IsNil)
+	c.Assert(buf.String(), Equals, `# TYPE provision_seconds histogram
+provision_seconds_bucket{le="0.5"} 0
+provision_seconds_bucket{le="2"} 1
+provision_seconds_bucket{le="+Inf"} 1
+provision_seconds_sum 1
+provision_seconds_count 1
+# HELP provisioned_total Devices provisioned.
+# TYPE provisioned_total counter
+provisioned_total 7
+`)
+}
+
+func (s *MetricsSuite) TestPrometheusNamesAndHelp(c *C) {
+	gauge, _ := DefineMetric("device-errors", GaugeMetric, "Errors per device,\nfrom C:\\logs.", nil)
+	gauge.Set(2)
+	leading, _ := DefineMetric("2xx.responses", CounterMetric, "", nil)
+	leading.Add(1)
+
+	var buf bytes.Buffer
+	c.Assert(WritePrometheusMetrics(&buf), IsNil)
+	c.Assert(buf.String(), Equals, `# TYPE _2xx_responses counter
+_2xx_responses 1
+# HELP device_errors Errors per device,\nfrom C:\\logs.
+# TYPE device_errors gauge
+device_errors 2
+`)
+}
@@ -0,0 +1,69 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests the network diagnostic primitives.
+
+package golisp
+
+import (
+	"fmt"
+	. "gopkg.in/check.v1"
+	"net"
+)
+
+type NetworkSuite struct {
+}
+
+var _ = Suite(&NetworkSuite{})
+
+func (s *NetworkSuite) TestProbingPorts(c *C) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	port := listener.Addr().(*net.TCPAddr).Port
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	result, err := ParseAndEval(fmt.Sprintf(`(port-open? "127.0.0.1" %d)`, port))
+	c.Assert(err, IsNil)
+	c.Assert(BooleanValue(result), Equals, true)
+	result, err = ParseAndEval(fmt.Sprintf(`(ping "127.0.0.1" %d 500)`, port))
+	c.Assert(err, IsNil)
+	c.Assert(FloatP(result), Equals, true)
+
+	listener.Close()
+	result, err = ParseAndEval(fmt.Sprintf(`(port-open? "127.0.0.1" %d 500)`, port))
+	c.Assert(err, IsNil)
+	c.Assert(BooleanValue(result), Equals, false)
+	result, err = ParseAndEval(fmt.Sprintf(`(ping "127.0.0.1" %d 500)`, port))
+	c.Assert(err, IsNil)
+	c.Assert(BooleanP(result), Equals, true)
+	c.Assert(BooleanValue(result), Equals, false)
+}
+
+func (s *NetworkSuite) TestResolvingHosts(c *C) {
+	result, err := ParseAndEval(`(resolve-host "127.0.0.1")`)
+	c.Assert(err, IsNil)
+	c.Assert(String(result), Equals, `("127.0.0.1")`)
+
+	result, err = ParseAndEval(`(my-ip-addresses)`)
+	c.Assert(err, IsNil)
+	c.Assert(ListP(result), Equals, true)
+}
+
+func (s *NetworkSuite) TestRejectsBadArguments(c *C) {
+	_, err := ParseAndEval(`(port-open? "127.0.0.1" 70000)`)
+	c.Assert(err, NotNil)
+	_, err = ParseAndEval(`(ping 'localhost 80)`)
+	c.Assert(err, NotNil)
+	_, err = ParseAndEval(`(resolve-host 42)`)
+	c.Assert(err, NotNil)
+}
@@ -42,3 +42,18 @@ func (s *IntegerAtomSuite) TestEval(c *C) {
 func (s *IntegerAtomSuite) TestBooleanValue(c *C) {
 	c.Assert(BooleanValue(s.n), Equals, true)
 }
+
+func (s *IntegerAtomSuite) TestUint64Value(c *C) {
+	var serial uint64 = 0xfedcba9876543210
+	n := Uint64WithValue(serial)
+	c.Assert(BigIntegerP(n), Equals, true)
+	c.Assert(Uint64P(n), Equals, true)
+	c.Assert(Uint64Value(n), Equals, serial)
+	c.Assert(String(n), Equals, "18364758544493064720")
+}
+
+func (s *IntegerAtomSuite) TestSmallUint64Value(c *C) {
+	n := Uint64WithValue(42)
+	c.Assert(IntegerP(n), Equals, true)
+	c.Assert(Uint64Value(n), Equals, uint64(42))
+}
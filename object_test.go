@@ -40,3 +40,29 @@ func (s *ObjectAtomSuite) TestObjectForNonObject(c *C) {
 	c.Assert(ObjectValue(o), Equals, unsafe.Pointer(nil))
 	c.Assert(ObjectType(o), Equals, "")
 }
+
+func (s *ObjectAtomSuite) TestObjectsUseRegisteredEquality(c *C) {
+	RegisterObjectEquality("EqualityTestStruct",
+		func(a unsafe.Pointer, b unsafe.Pointer) bool {
+			return (*TestStruct)(a).D == (*TestStruct)(b).D
+		},
+		func(o unsafe.Pointer) uint64 {
+			return uint64((*TestStruct)(o).D)
+		})
+
+	o1 := ObjectWithTypeAndValue("EqualityTestStruct", unsafe.Pointer(&TestStruct{D: 5}))
+	o2 := ObjectWithTypeAndValue("EqualityTestStruct", unsafe.Pointer(&TestStruct{D: 5}))
+	o3 := ObjectWithTypeAndValue("EqualityTestStruct", unsafe.Pointer(&TestStruct{D: 6}))
+	c.Assert(IsDeepEqual(o1, o2), Equals, true)
+	c.Assert(IsDeepEqual(o1, o3), Equals, false)
+	c.Assert(IsDeepEqual(InternalMakeList(o1), InternalMakeList(o2)), Equals, true)
+	c.Assert(Hash(o1), Equals, Hash(o2))
+	c.Assert(Hash(o1), Equals, uint64(5))
+}
+
+func (s *ObjectAtomSuite) TestUnregisteredObjectsCompareByIdentity(c *C) {
+	o1 := ObjectWithTypeAndValue("TestStruct", unsafe.Pointer(&TestStruct{D: 5}))
+	o2 := ObjectWithTypeAndValue("TestStruct", unsafe.Pointer(&TestStruct{D: 5}))
+	c.Assert(IsDeepEqual(o1, o2), Equals, false)
+	c.Assert(IsDeepEqual(o1, ObjectWithTypeAndValue("TestStruct", ObjectValue(o1))), Equals, true)
+}
@@ -0,0 +1,91 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements the writers that the interpreter sends its output to.
+
+package golisp
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// The interpreter writes printed output, the repl and the debugger to the output writer,
+// failures that have no caller to return them to (e.g. in forked processes and scheduled
+// jobs) to the error writer, and eval tracing and profiling to the trace writer.
+var interpreterOutput = struct {
+	sync.RWMutex
+	output io.Writer
+	error  io.Writer
+	trace  io.Writer
+}{output: os.Stdout, error: os.Stderr, trace: os.Stdout}
+
+// writerOrDiscard lets hosts pass nil to suppress a kind of output
+func writerOrDiscard(w io.Writer) io.Writer {
+	if w == nil {
+		return ioutil.Discard
+	}
+	return w
+}
+
+// SetOutputWriter redirects printed output, which goes to stdout by default.  A nil writer
+// discards it.
+func SetOutputWriter(w io.Writer) {
+	interpreterOutput.Lock()
+	interpreterOutput.output = writerOrDiscard(w)
+	interpreterOutput.Unlock()
+}
+
+// SetErrorWriter redirects reports of errors that can't be returned, which go to stderr by
+// default.  A nil writer discards them.
+func SetErrorWriter(w io.Writer) {
+	interpreterOutput.Lock()
+	interpreterOutput.error = writerOrDiscard(w)
+	interpreterOutput.Unlock()
+}
+
+// SetTraceWriter redirects eval tracing and profiling, which go to stdout by default.  A nil
+// writer discards them.
+func SetTraceWriter(w io.Writer) {
+	interpreterOutput.Lock()
+	interpreterOutput.trace = writerOrDiscard(w)
+	interpreterOutput.Unlock()
+}
+
+func OutputWriter() io.Writer {
+	interpreterOutput.RLock()
+	defer interpreterOutput.RUnlock()
+	return interpreterOutput.output
+}
+
+func ErrorWriter() io.Writer {
+	interpreterOutput.RLock()
+	defer interpreterOutput.RUnlock()
+	return interpreterOutput.error
+}
+
+func TraceWriter() io.Writer {
+	interpreterOutput.RLock()
+	defer interpreterOutput.RUnlock()
+	return interpreterOutput.trace
+}
+
+// currentOutput returns where printing in env goes: the writer set up by the innermost
+// with-output-to-string or with-output-to-port in the code that led to env, or OutputWriter
+func currentOutput(env *SymbolTableFrame) io.Writer {
+	for e := env; e != nil; {
+		if e.output != nil {
+			return e.output
+		}
+		if e.Previous != nil {
+			e = e.Previous
+		} else {
+			e = e.Parent
+		}
+	}
+	return OutputWriter()
+}
@@ -0,0 +1,80 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests redirecting the interpreter's output.
+
+package golisp
+
+import (
+	"bytes"
+	. "gopkg.in/check.v1"
+	"io/ioutil"
+	"os"
+)
+
+type OutputSuite struct {
+	output *bytes.Buffer
+}
+
+var _ = Suite(&OutputSuite{})
+
+func (s *OutputSuite) SetUpTest(c *C) {
+	s.output = new(bytes.Buffer)
+	SetOutputWriter(s.output)
+}
+
+func (s *OutputSuite) TearDownTest(c *C) {
+	SetOutputWriter(os.Stdout)
+	SetErrorWriter(os.Stderr)
+	SetTraceWriter(os.Stdout)
+}
+
+func (s *OutputSuite) TestPrintPrimitives(c *C) {
+	_, err := ParseAndEvalAll(`(display "a") (write "b") (newline) (write-string "c") (write-line "d") (write-log "e") (format #t "~A" 1)`)
+	c.Assert(err, IsNil)
+	c.Assert(s.output.String(), Equals, "a\"b\"\ncd\ne\r\n1")
+}
+
+func (s *OutputSuite) TestDiscarding(c *C) {
+	SetOutputWriter(nil)
+	_, err := ParseAndEval(`(display "a")`)
+	c.Assert(err, IsNil)
+	c.Assert(s.output.Len(), Equals, 0)
+}
+
+func (s *OutputSuite) TestTracing(c *C) {
+	trace := new(bytes.Buffer)
+	SetTraceWriter(trace)
+	Config.LispTrace = true
+	_, err := ParseAndEval("(+ 1 2)")
+	Config.LispTrace = false
+	c.Assert(err, IsNil)
+	c.Assert(trace.String(), Matches, "(?s).*> \\(\\+ 1 2\\).*")
+	c.Assert(s.output.Len(), Equals, 0)
+}
+
+func (s *OutputSuite) TestErrors(c *C) {
+	errors := new(bytes.Buffer)
+	SetErrorWriter(errors)
+	LogErrorf("failed: %d", 42)
+	c.Assert(errors.String(), Equals, "failed: 42")
+	c.Assert(s.output.Len(), Equals, 0)
+}
+
+func (s *OutputSuite) TestOutputToPort(c *C) {
+	f, err := ioutil.TempFile("", "golisp-output")
+	c.Assert(err, IsNil)
+	defer os.Remove(f.Name())
+
+	Global.BindTo(Intern("output-test-port"), PortWithValue(f))
+	_, err = ParseAndEval(`(with-output-to-port output-test-port (display 42) (newline))`)
+	c.Assert(err, IsNil)
+	f.Close()
+
+	contents, err := ioutil.ReadFile(f.Name())
+	c.Assert(err, IsNil)
+	c.Assert(string(contents), Equals, "42\n")
+	c.Assert(s.output.Len(), Equals, 0)
+}
@@ -8,10 +8,11 @@
 package golisp
 
 import (
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
 	"unsafe"
 )
 
@@ -21,6 +22,9 @@ func makeInteger(str string) (n *Data, err error) {
 	var i int64
 	_, err = fmt.Sscanf(str, "%d", &i)
 	if err != nil {
+		if big, ok := parseBigInteger(str, 10); ok {
+			return big, nil
+		}
 		return
 	}
 	n = IntegerWithValue(i)
@@ -31,6 +35,9 @@ func makeBinaryInteger(str string) (n *Data, err error) {
 	var i int64
 	_, err = fmt.Sscanf(str, "%b", &i)
 	if err != nil {
+		if big, ok := parseBigInteger(str, 2); ok {
+			return big, nil
+		}
 		return
 	}
 	n = IntegerWithValue(i)
@@ -41,6 +48,9 @@ func makeHexInteger(str string) (n *Data, err error) {
 	var i int64
 	_, err = fmt.Sscanf(str, "%x", &i)
 	if err != nil {
+		if big, ok := parseBigInteger(str, 16); ok {
+			return big, nil
+		}
 		return
 	}
 	n = IntegerWithValue(i)
@@ -67,6 +77,16 @@ func makeSymbol(str string) (s *Data, err error) {
 	return
 }
 
+func makeCharacter(str string) (c *Data, err error) {
+	var ch rune
+	ch, err = CharacterFromName(str)
+	if err != nil {
+		return
+	}
+	c = CharacterWithValue(ch)
+	return
+}
+
 func parseConsCell(s *Tokenizer) (sexpr *Data, eof bool, err error) {
 	tok, _ := s.NextToken()
 
@@ -82,7 +102,7 @@ func parseConsCell(s *Tokenizer) (sexpr *Data, eof bool, err error) {
 			}
 			tok, _ = s.NextToken()
 			if tok != RPAREN {
-				err = errors.New("Expected ')'")
+				err = NewLispError(ParseError, "Expected ')'")
 				return
 			}
 			s.ConsumeToken()
@@ -91,7 +111,7 @@ func parseConsCell(s *Tokenizer) (sexpr *Data, eof bool, err error) {
 		} else {
 			car, eof, err = parseExpression(s)
 			if eof {
-				err = errors.New("Unexpected EOF (expected closing parenthesis)")
+				err = NewLispError(ParseError, "Unexpected EOF (expected closing parenthesis)")
 				return
 			}
 			if err != nil {
@@ -107,6 +127,113 @@ func parseConsCell(s *Tokenizer) (sexpr *Data, eof bool, err error) {
 	return
 }
 
+func parseVector(s *Tokenizer) (sexpr *Data, eof bool, err error) {
+	tok, _ := s.NextToken()
+
+	var element *Data
+	elements := make([]*Data, 0, 10)
+	for tok != RPAREN {
+		element, eof, err = parseExpression(s)
+		if eof {
+			err = NewLispError(ParseError, "Unexpected EOF (expected closing parenthesis)")
+			return
+		}
+		if err != nil {
+			return
+		}
+		elements = append(elements, element)
+		tok, _ = s.NextToken()
+	}
+
+	s.ConsumeToken()
+	sexpr = VectorWithValue(elements)
+	return
+}
+
+// parseShorthandLambda reads #[body...] as a lambda whose parameters are the placeholders
+// used in body: % or %1 for the first argument, %2 for the second, and so on, and %& for
+// the rest, which needs a positional placeholder before it.  E.g. #[(+ % 1)] reads as
+// (lambda (%1) (+ %1 1)).
+func parseShorthandLambda(s *Tokenizer) (sexpr *Data, eof bool, err error) {
+	if s.inShorthand {
+		err = NewLispError(ParseError, "Lambda shorthands can't be nested")
+		return
+	}
+	s.inShorthand = true
+	defer func() { s.inShorthand = false }()
+
+	tok, _ := s.NextToken()
+	var element *Data
+	body := make([]*Data, 0, 1)
+	for tok != RBRACKET {
+		element, eof, err = parseExpression(s)
+		if eof {
+			err = NewLispError(ParseError, "Unexpected EOF (expected closing bracket)")
+			return
+		}
+		if err != nil {
+			return
+		}
+		body = append(body, element)
+		tok, _ = s.NextToken()
+	}
+	s.ConsumeToken()
+
+	if len(body) == 0 {
+		err = NewLispError(ParseError, "Lambda shorthands need a body")
+		return
+	}
+
+	count, rest := 0, false
+	for i, form := range body {
+		body[i] = shorthandPlaceholders(form, &count, &rest)
+	}
+	if rest && count == 0 {
+		err = NewLispError(ParseError, "Lambda shorthands can only use %& after a positional placeholder")
+		return
+	}
+	params := make([]*Data, count)
+	for i := range params {
+		params[i] = Intern(fmt.Sprintf("%%%d", i+1))
+	}
+	var restParam *Data
+	if rest {
+		restParam = Intern("%&")
+	}
+	sexpr = Cons(Intern("lambda"), Cons(ArrayToListWithTail(params, restParam), ArrayToList(body)))
+	return
+}
+
+// shorthandPlaceholders finds the placeholders in the lambda shorthand body form, counting
+// the positional ones in count and noting %& in rest, and renames % to %1.  Quoted forms
+// and vector literals are left alone.
+func shorthandPlaceholders(form *Data, count *int, rest *bool) *Data {
+	switch {
+	case SymbolP(form):
+		name := StringValue(form)
+		if name == "%" {
+			name = "%1"
+			form = Intern(name)
+		}
+		if name == "%&" {
+			*rest = true
+		} else if n, err := strconv.Atoi(strings.TrimPrefix(name, "%")); err == nil && strings.HasPrefix(name, "%") && n > *count {
+			*count = n
+		}
+	case PairP(form) && NotNilP(form):
+		if SymbolP(Car(form)) && StringValue(Car(form)) == "quote" {
+			break
+		}
+		for c := form; PairP(c) && NotNilP(c); c = Cdr(c) {
+			ConsValue(c).Car = shorthandPlaceholders(Car(c), count, rest)
+			if !PairP(Cdr(c)) && NotNilP(Cdr(c)) {
+				ConsValue(c).Cdr = shorthandPlaceholders(Cdr(c), count, rest)
+			}
+		}
+	}
+	return form
+}
+
 func allIntegers(data []*Data) bool {
 	for _, n := range data {
 		if !IntegerP(n) {
@@ -139,18 +266,18 @@ func parseBytearray(s *Tokenizer) (sexpr *Data, eof bool, err error) {
 	for tok != RBRACKET {
 		element, eof, err = parseExpression(s)
 		if eof {
-			err = errors.New("Unexpected EOF (expected closing bracket)")
+			err = NewLispError(ParseError, "Unexpected EOF (expected closing bracket)")
 			return
 		}
 		if err != nil {
 			return
 		}
 		if IntegerP(element) && IntegerValue(element) > 255 {
-			err = errors.New(fmt.Sprintf("Numeric literals in a bytearray must be bytes. Encountered %s.", String(element)))
+			err = NewLispError(ParseError, fmt.Sprintf("Numeric literals in a bytearray must be bytes. Encountered %s.", String(element)))
 			return
 		}
 		if !IntegerP(element) && !SymbolP(element) && !ListP(element) {
-			err = errors.New(fmt.Sprintf("Bytearray elements must be numbers, symbols, or lists (function calls). Encountered %s.", String(element)))
+			err = NewLispError(ParseError, fmt.Sprintf("Bytearray elements must be numbers, symbols, or lists (function calls). Encountered %s.", String(element)))
 			return
 		}
 		cells = append(cells, element)
@@ -166,9 +293,39 @@ func parseBytearray(s *Tokenizer) (sexpr *Data, eof bool, err error) {
 	return
 }
 
+// parseBytevector reads the rest of a #u8( ) literal, which unlike a [ ] bytearray can
+// only contain bytes
+func parseBytevector(s *Tokenizer) (sexpr *Data, eof bool, err error) {
+	var element *Data
+	cells := make([]*Data, 0, 10)
+	for tok, _ := s.NextToken(); tok != RPAREN; tok, _ = s.NextToken() {
+		element, eof, err = parseExpression(s)
+		if eof {
+			err = NewLispError(ParseError, "Unexpected EOF (expected closing parenthesis)")
+			return
+		}
+		if err != nil {
+			return
+		}
+		if !IntegerP(element) || IntegerValue(element) < 0 || IntegerValue(element) > 255 {
+			err = NewLispError(ParseError, fmt.Sprintf("#u8 literals can only contain bytes. Encountered %s.", String(element)))
+			return
+		}
+		cells = append(cells, element)
+	}
+
+	s.ConsumeToken()
+	bytes := make([]byte, 0, len(cells))
+	for _, cell := range cells {
+		bytes = append(bytes, byte(IntegerValue(cell)))
+	}
+	sexpr = BytevectorWithValue(bytes)
+	return
+}
+
 func parseFrame(s *Tokenizer) (sexpr *Data, eof bool, err error) {
 	tok, _ := s.NextToken()
-	if tok == RBRACKET {
+	if tok == RBRACE {
 		s.ConsumeToken()
 		f := FrameMap{}
 		f.Data = make(FrameMapData)
@@ -181,7 +338,7 @@ func parseFrame(s *Tokenizer) (sexpr *Data, eof bool, err error) {
 	for tok != RBRACE {
 		element, eof, err = parseExpression(s)
 		if eof {
-			err = errors.New("Unexpected EOF (expected closing brace)")
+			err = NewLispError(ParseError, "Unexpected EOF (expected closing brace)")
 			return
 		}
 		if err != nil {
@@ -192,10 +349,83 @@ func parseFrame(s *Tokenizer) (sexpr *Data, eof bool, err error) {
 	}
 
 	s.ConsumeToken()
+	// in a quasiquoted frame, ,@ can splice in any number of slots and , can supply a name
+	for i := 0; i < len(cells); i += 2 {
+		if quasiquoteForm(cells[i], "unquote-splicing") {
+			i--
+			continue
+		}
+		if !NakedP(cells[i]) && !quasiquoteForm(cells[i], "unquote") {
+			err = NewLispError(ParseError, fmt.Sprintf("Frame literal slot names must be naked symbols (ending in ':'). Encountered %s.", String(cells[i])))
+			return
+		}
+		if i+1 == len(cells) {
+			err = NewLispError(ParseError, fmt.Sprintf("Frame literals need a value for every slot, but %s has none.", String(cells[i])))
+			return
+		}
+	}
 	sexpr = Cons(Intern("make-frame"), ArrayToList(cells))
 	return
 }
 
+// replacePlaceholder swaps every reference to placeholder inside d for value, which is how
+// #n= labels that are referred to within their own datum get tied back together.
+func replacePlaceholder(d *Data, placeholder *Data, value *Data, seen map[*Data]bool) {
+	for d != nil && !seen[d] {
+		seen[d] = true
+		switch d.Type {
+		case ConsCellType, AlistType, AlistCellType:
+			cell := ConsValue(d)
+			if cell == nil {
+				return
+			}
+			if cell.Car == placeholder {
+				cell.Car = value
+			} else {
+				replacePlaceholder(cell.Car, placeholder, value, seen)
+			}
+			if cell.Cdr == placeholder {
+				cell.Cdr = value
+				return
+			}
+			d = cell.Cdr
+		case VectorType:
+			elements := VectorValue(d)
+			for i, element := range elements {
+				if element == placeholder {
+					elements[i] = value
+				} else {
+					replacePlaceholder(element, placeholder, value, seen)
+				}
+			}
+			return
+		default:
+			return
+		}
+	}
+}
+
+func parseLabeledExpression(s *Tokenizer, label string) (sexpr *Data, eof bool, err error) {
+	if s.Labels == nil {
+		s.Labels = make(map[string]*Data)
+	}
+	placeholder := SymbolWithName(fmt.Sprintf("#%s#", label))
+	s.Labels[label] = placeholder
+
+	sexpr, eof, err = parseExpression(s)
+	if eof || err != nil {
+		return
+	}
+	if sexpr == placeholder {
+		err = NewLispError(ParseError, fmt.Sprintf("Datum label #%s= can not refer only to itself", label))
+		return
+	}
+
+	s.Labels[label] = sexpr
+	replacePlaceholder(sexpr, placeholder, sexpr, make(map[*Data]bool))
+	return
+}
+
 func parseExpression(s *Tokenizer) (sexpr *Data, eof bool, err error) {
 	for {
 		tok, lit := s.NextToken()
@@ -228,8 +458,40 @@ func parseExpression(s *Tokenizer) (sexpr *Data, eof bool, err error) {
 			sexpr, err = makeString(lit)
 			return
 		case LPAREN:
+			position := s.LookaheadPosition()
 			s.ConsumeToken()
 			sexpr, eof, err = parseConsCell(s)
+			if err == nil && NotNilP(sexpr) {
+				setSourcePosition(sexpr, position)
+			}
+			return
+		case HASHLPAREN:
+			s.ConsumeToken()
+			sexpr, eof, err = parseVector(s)
+			return
+		case HASHU8LPAREN:
+			s.ConsumeToken()
+			sexpr, eof, err = parseBytevector(s)
+			return
+		case HASHLBRACKET:
+			s.ConsumeToken()
+			sexpr, eof, err = parseShorthandLambda(s)
+			return
+		case CHARACTER:
+			s.ConsumeToken()
+			sexpr, err = makeCharacter(lit)
+			return
+		case LABELDEF:
+			s.ConsumeToken()
+			sexpr, eof, err = parseLabeledExpression(s, lit)
+			return
+		case LABELREF:
+			s.ConsumeToken()
+			var found bool
+			sexpr, found = s.Labels[lit]
+			if !found {
+				err = NewLispError(ParseError, fmt.Sprintf("Reference to undefined datum label #%s#", lit))
+			}
 			return
 		case LBRACKET:
 			s.ConsumeToken()
@@ -280,7 +542,7 @@ func parseExpression(s *Tokenizer) (sexpr *Data, eof bool, err error) {
 			}
 			return
 		case ILLEGAL:
-			err = errors.New(fmt.Sprintf("Illegal character: %s", lit))
+			err = NewLispError(ParseError, fmt.Sprintf("Illegal character: %s", lit))
 			return
 		default:
 			s.ConsumeToken()
@@ -310,9 +572,18 @@ func ParseAll(src string) (result []*Data, err error) {
 	return
 }
 
+// ReadFile reads filename from the embedded files (see RegisterEmbeddedFile) or the disk
 func ReadFile(filename string) (s string, err error) {
+	if embedded, found := readEmbeddedFile(filename); found {
+		return embedded, nil
+	}
+
+	if onDisk, found := findOnLoadPath(filename); found {
+		filename = onDisk
+	}
 	contents, err := ioutil.ReadFile(filename)
 	if err != nil {
+		err = ioError(err)
 		return
 	}
 
@@ -337,12 +608,17 @@ func ProcessFileInEnvironment(filename string, env *SymbolTableFrame) (result *D
 	if err != nil {
 		return
 	}
-	result, err = ParseAndEvalAllInEnvironment(src, env)
+	result, err = evalAllFromTokenizer(NewTokenizerFromFileContents(src, filename), env, true)
 	return
 }
 
 func ParseAndEvalAllInEnvironment(src string, env *SymbolTableFrame) (result *Data, err error) {
-	s := NewTokenizerFromString(src)
+	return evalAllFromTokenizer(NewTokenizerFromString(src), env, false)
+}
+
+// evalAllFromTokenizer evaluates each expression s reads in turn, first checking the calls
+// in it for the wrong number of arguments if checkArity is set
+func evalAllFromTokenizer(s *Tokenizer, env *SymbolTableFrame, checkArity bool) (result *Data, err error) {
 	var sexpr *Data
 	var eof bool
 	for {
@@ -356,6 +632,11 @@ func ParseAndEvalAllInEnvironment(src string, env *SymbolTableFrame) (result *Da
 		if NilP(sexpr) {
 			return
 		}
+		if checkArity {
+			if err = reportArityProblems(sexpr, env); err != nil {
+				return
+			}
+		}
 		result, err = Eval(sexpr, env)
 		if err != nil {
 			return
@@ -380,7 +661,12 @@ func ParseAndEvalInEnvironment(src string, env *SymbolTableFrame) (result *Data,
 }
 
 func ParseObjectFromFileInEnv(port *os.File, env *SymbolTableFrame) (result *Data, err error) {
-	result, eof, err := parseExpression(NewTokenizerFromFile(port))
+	return parseObjectFromTokenizer(NewTokenizerFromFile(port))
+}
+
+// parseObjectFromTokenizer reads the next object s has, or EofObject if there isn't one
+func parseObjectFromTokenizer(s *Tokenizer) (result *Data, err error) {
+	result, eof, err := parseExpression(s)
 	if err != nil {
 		return
 	}
@@ -272,6 +272,148 @@ func (s *ParsingSuite) TestEmptyByteArray(c *C) {
 	c.Assert(len(*bytes), Equals, 0)
 }
 
+func (s *ParsingSuite) TestVector(c *C) {
+	sexpr, err := Parse(`#(1 "two" (3))`)
+	c.Assert(err, IsNil)
+	c.Assert(int(TypeOf(sexpr)), Equals, VectorType)
+	elements := VectorValue(sexpr)
+	c.Assert(len(elements), Equals, 3)
+	c.Assert(IntegerValue(elements[0]), Equals, int64(1))
+	c.Assert(StringValue(elements[1]), Equals, "two")
+	c.Assert(PairP(elements[2]), Equals, true)
+}
+
+func (s *ParsingSuite) TestEmptyVector(c *C) {
+	sexpr, err := Parse("#()")
+	c.Assert(err, IsNil)
+	c.Assert(int(TypeOf(sexpr)), Equals, VectorType)
+	c.Assert(len(VectorValue(sexpr)), Equals, 0)
+}
+
+func (s *ParsingSuite) TestUnterminatedVector(c *C) {
+	_, err := Parse("#(1 2")
+	c.Assert(err, NotNil)
+}
+
+func (s *ParsingSuite) TestBytevector(c *C) {
+	sexpr, err := Parse("#u8(1 255)")
+	c.Assert(err, IsNil)
+	c.Assert(ObjectType(sexpr), Equals, "Bytevector")
+	bytes := (*[]byte)(ObjectValue(sexpr))
+	c.Assert(*bytes, DeepEquals, []byte{1, 255})
+}
+
+func (s *ParsingSuite) TestEmptyBytevector(c *C) {
+	sexpr, err := Parse("#u8()")
+	c.Assert(err, IsNil)
+	c.Assert(ObjectType(sexpr), Equals, "Bytevector")
+	c.Assert(len(*(*[]byte)(ObjectValue(sexpr))), Equals, 0)
+}
+
+func (s *ParsingSuite) TestInvalidBytevectors(c *C) {
+	_, err := Parse("#u8(1 256)")
+	c.Assert(err, NotNil)
+	_, err = Parse("#u8(a)")
+	c.Assert(err, NotNil)
+	_, err = Parse("#u8(1 2")
+	c.Assert(err, NotNil)
+	_, err = Parse("#u8[1]")
+	c.Assert(err, NotNil)
+}
+
+func (s *ParsingSuite) TestLambdaShorthand(c *C) {
+	sexpr, err := Parse("#[(+ % 1)]")
+	c.Assert(err, IsNil)
+	c.Assert(String(sexpr), Equals, "(lambda (%1) (+ %1 1))")
+
+	sexpr, err = Parse("#[(f %2 '%3 %&)]")
+	c.Assert(err, IsNil)
+	c.Assert(String(sexpr), Equals, "(lambda (%1 %2 . %&) (f %2 '%3 %&))")
+}
+
+func (s *ParsingSuite) TestInvalidLambdaShorthands(c *C) {
+	_, err := Parse("#[]")
+	c.Assert(err, NotNil)
+	_, err = Parse("#[(f #[%])]")
+	c.Assert(err, NotNil)
+	_, err = Parse("#[(list %&)]")
+	c.Assert(err, NotNil)
+	_, err = Parse("#[(+ % 1)")
+	c.Assert(err, NotNil)
+}
+
+func (s *ParsingSuite) TestCharacter(c *C) {
+	sexpr, err := Parse(`#\a`)
+	c.Assert(err, IsNil)
+	c.Assert(int(TypeOf(sexpr)), Equals, CharacterType)
+	c.Assert(CharacterValue(sexpr), Equals, 'a')
+}
+
+func (s *ParsingSuite) TestNamedCharacter(c *C) {
+	sexpr, err := Parse(`#\space`)
+	c.Assert(err, IsNil)
+	c.Assert(CharacterValue(sexpr), Equals, ' ')
+}
+
+func (s *ParsingSuite) TestHexCharacter(c *C) {
+	sexpr, err := Parse(`#\x41`)
+	c.Assert(err, IsNil)
+	c.Assert(CharacterValue(sexpr), Equals, 'A')
+}
+
+func (s *ParsingSuite) TestDelimiterCharacter(c *C) {
+	sexpr, err := Parse(`(#\( #\))`)
+	c.Assert(err, IsNil)
+	c.Assert(CharacterValue(Car(sexpr)), Equals, '(')
+	c.Assert(CharacterValue(Cadr(sexpr)), Equals, ')')
+}
+
+func (s *ParsingSuite) TestUnknownCharacterName(c *C) {
+	_, err := Parse(`#\bogus`)
+	c.Assert(err, NotNil)
+}
+
+func (s *ParsingSuite) TestDatumLabels(c *C) {
+	sexpr, err := Parse("(#0=(a b) #0# #1=#(1 #1#))")
+	c.Assert(err, IsNil)
+	c.Assert(Car(sexpr), Equals, Cadr(sexpr))
+	v := Caddr(sexpr)
+	c.Assert(VectorValue(v)[1], Equals, v)
+}
+
+func (s *ParsingSuite) TestUndefinedDatumLabel(c *C) {
+	_, err := Parse("(#0# 1)")
+	c.Assert(err, NotNil)
+}
+
+func (s *ParsingSuite) TestFrame(c *C) {
+	sexpr, err := Parse(`{a: 1 b: "two"}`)
+	c.Assert(err, IsNil)
+	c.Assert(String(sexpr), Equals, `(make-frame a: 1 b: "two")`)
+}
+
+func (s *ParsingSuite) TestEmptyFrame(c *C) {
+	sexpr, err := Parse("{}")
+	c.Assert(err, IsNil)
+	c.Assert(FrameP(sexpr), Equals, true)
+	c.Assert(Length(sexpr), Equals, 0)
+}
+
+func (s *ParsingSuite) TestFrameWithMissingValue(c *C) {
+	_, err := Parse("{a: 1 b:}")
+	c.Assert(err, NotNil)
+}
+
+func (s *ParsingSuite) TestFrameWithBadSlotName(c *C) {
+	_, err := Parse("{a 1}")
+	c.Assert(err, NotNil)
+}
+
+func (s *ParsingSuite) TestUnterminatedFrame(c *C) {
+	_, err := Parse("{a: 1")
+	c.Assert(err, NotNil)
+}
+
 func (s *ParsingSuite) TestQuote(c *C) {
 	sexpr, err := Parse("'a")
 	c.Assert(err, IsNil)
@@ -347,3 +489,14 @@ func (s *ParsingSuite) BenchmarkParse(c *C) {
 		_, _ = ParseAndEval(src)
 	}
 }
+
+func (s *ParsingSuite) TestSourcePositions(c *C) {
+	sexpr, _, err := parseExpression(NewTokenizerFromFileContents("; comment\n  (a\n (b c))", "test.lsp"))
+	c.Assert(err, IsNil)
+	position := SourcePositionOf(sexpr)
+	c.Assert(position, NotNil)
+	c.Assert(*position, Equals, SourcePosition{File: "test.lsp", Line: 2, Column: 3})
+	c.Assert(position.String(), Equals, "test.lsp:2")
+	c.Assert(*SourcePositionOf(Cadr(sexpr)), Equals, SourcePosition{File: "test.lsp", Line: 3, Column: 2})
+	c.Assert(SourcePositionOf(Car(sexpr)), IsNil)
+}
@@ -0,0 +1,249 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements rendering simple line and bar charts as SVG, PNG, or HTML.
+
+package golisp
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Chart is one or more series of numbers drawn as lines, or as groups of bars, against a
+// shared vertical axis.  Names label the series in a legend.
+type Chart struct {
+	Kind   string
+	Title  string
+	Width  int
+	Height int
+	Series [][]float64
+	Names  []string
+}
+
+var chartColors = []color.RGBA{
+	{0x1f, 0x77, 0xb4, 0xff},
+	{0xff, 0x7f, 0x0e, 0xff},
+	{0x2c, 0xa0, 0x2c, 0xff},
+	{0xd6, 0x27, 0x28, 0xff},
+	{0x94, 0x67, 0xbd, 0xff},
+	{0x8c, 0x56, 0x4b, 0xff},
+}
+
+var chartAxisColor = color.RGBA{0x44, 0x44, 0x44, 0xff}
+var chartGridColor = color.RGBA{0xdd, 0xdd, 0xdd, 0xff}
+
+const chartTicks = 5
+
+func chartColor(series int) color.RGBA {
+	return chartColors[series%len(chartColors)]
+}
+
+func cssColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// chartLayout maps values onto the drawing area of a chart
+type chartLayout struct {
+	chart  *Chart
+	left   float64
+	top    float64
+	width  float64
+	height float64
+	low    float64
+	high   float64
+	points int
+}
+
+func (self *Chart) layout() *chartLayout {
+	layout := &chartLayout{chart: self, low: math.Inf(1), high: math.Inf(-1)}
+	for _, series := range self.Series {
+		if len(series) > layout.points {
+			layout.points = len(series)
+		}
+		for _, v := range series {
+			layout.low = math.Min(layout.low, v)
+			layout.high = math.Max(layout.high, v)
+		}
+	}
+	if self.Kind == "bar" {
+		// bars grow from zero
+		layout.low = math.Min(layout.low, 0)
+		layout.high = math.Max(layout.high, 0)
+	}
+	if layout.low == layout.high {
+		layout.low--
+		layout.high++
+	}
+
+	top := 20.0
+	if self.Title != "" {
+		top = 40
+	}
+	right := 20.0
+	if len(self.Names) > 0 {
+		right = 120
+	}
+	layout.left, layout.top = 60, top
+	layout.width = math.Max(float64(self.Width)-layout.left-right, 1)
+	layout.height = math.Max(float64(self.Height)-top-30, 1)
+	return layout
+}
+
+func (self *chartLayout) y(v float64) float64 {
+	return self.top + (self.high-v)/(self.high-self.low)*self.height
+}
+
+func (self *chartLayout) bottom() float64 {
+	return self.top + self.height
+}
+
+// linePoint is where the ith value of a line goes
+func (self *chartLayout) linePoint(i int, v float64) (x float64, y float64) {
+	if self.points == 1 {
+		return self.left + self.width/2, self.y(v)
+	}
+	return self.left + float64(i)*self.width/float64(self.points-1), self.y(v)
+}
+
+// bar is the rectangle of the ith value of a series of bars; bars of the same index are
+// grouped side by side
+func (self *chartLayout) bar(series int, i int, v float64) (x float64, y float64, width float64, height float64) {
+	group := self.width / float64(self.points)
+	width = group * 0.8 / float64(len(self.chart.Series))
+	x = self.left + float64(i)*group + group*0.1 + float64(series)*width
+	top, bottom := self.y(math.Max(v, 0)), self.y(math.Min(v, 0))
+	return x, top, width, bottom - top
+}
+
+func (self *chartLayout) tick(i int) (v float64, y float64) {
+	v = self.low + (self.high-self.low)*float64(i)/chartTicks
+	return v, self.y(v)
+}
+
+func formatTick(v float64) string {
+	return strconv.FormatFloat(v, 'g', 4, 64)
+}
+
+// SVG renders the chart as an SVG document
+func (self *Chart) SVG() string {
+	layout := self.layout()
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n", self.Width, self.Height, self.Width, self.Height)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="white"/>`+"\n", self.Width, self.Height)
+	if self.Title != "" {
+		fmt.Fprintf(&b, `<text x="%g" y="24" text-anchor="middle" font-size="16">%s</text>`+"\n", float64(self.Width)/2, html.EscapeString(self.Title))
+	}
+
+	for i := 0; i <= chartTicks; i++ {
+		v, y := layout.tick(i)
+		fmt.Fprintf(&b, `<line x1="%g" y1="%g" x2="%g" y2="%g" stroke="%s"/>`+"\n", layout.left, y, layout.left+layout.width, y, cssColor(chartGridColor))
+		fmt.Fprintf(&b, `<text x="%g" y="%g" text-anchor="end">%s</text>`+"\n", layout.left-6, y+4, formatTick(v))
+	}
+	fmt.Fprintf(&b, `<line x1="%g" y1="%g" x2="%g" y2="%g" stroke="%s"/>`+"\n", layout.left, layout.top, layout.left, layout.bottom(), cssColor(chartAxisColor))
+	fmt.Fprintf(&b, `<line x1="%g" y1="%g" x2="%g" y2="%g" stroke="%s"/>`+"\n", layout.left, layout.y(math.Max(layout.low, 0)), layout.left+layout.width, layout.y(math.Max(layout.low, 0)), cssColor(chartAxisColor))
+
+	for s, series := range self.Series {
+		c := cssColor(chartColor(s))
+		if self.Kind == "bar" {
+			for i, v := range series {
+				x, y, w, h := layout.bar(s, i, v)
+				fmt.Fprintf(&b, `<rect x="%g" y="%g" width="%g" height="%g" fill="%s"/>`+"\n", x, y, w, h, c)
+			}
+			continue
+		}
+		points := make([]string, len(series))
+		for i, v := range series {
+			x, y := layout.linePoint(i, v)
+			points[i] = fmt.Sprintf("%g,%g", x, y)
+		}
+		fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`+"\n", strings.Join(points, " "), c)
+	}
+
+	for s, name := range self.Names {
+		y := layout.top + float64(s)*18
+		fmt.Fprintf(&b, `<rect x="%g" y="%g" width="12" height="12" fill="%s"/>`+"\n", layout.left+layout.width+12, y, cssColor(chartColor(s)))
+		fmt.Fprintf(&b, `<text x="%g" y="%g">%s</text>`+"\n", layout.left+layout.width+30, y+10, html.EscapeString(name))
+	}
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// HTML renders the chart as a page containing its SVG
+func (self *Chart) HTML() string {
+	return fmt.Sprintf("<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\"><title>%s</title></head>\n<body>\n%s</body>\n</html>\n", html.EscapeString(self.Title), self.SVG())
+}
+
+// PNG renders the chart as a PNG image.  There's no text in it: without a font, titles,
+// tick labels, and names are left out.
+func (self *Chart) PNG() ([]byte, error) {
+	layout := self.layout()
+	img := image.NewRGBA(image.Rect(0, 0, self.Width, self.Height))
+	fillRect(img, 0, 0, float64(self.Width), float64(self.Height), color.RGBA{0xff, 0xff, 0xff, 0xff})
+
+	for i := 0; i <= chartTicks; i++ {
+		_, y := layout.tick(i)
+		drawLine(img, layout.left, y, layout.left+layout.width, y, 1, chartGridColor)
+	}
+	drawLine(img, layout.left, layout.top, layout.left, layout.bottom(), 1, chartAxisColor)
+	axis := layout.y(math.Max(layout.low, 0))
+	drawLine(img, layout.left, axis, layout.left+layout.width, axis, 1, chartAxisColor)
+
+	for s, series := range self.Series {
+		c := chartColor(s)
+		for i, v := range series {
+			if self.Kind == "bar" {
+				x, y, w, h := layout.bar(s, i, v)
+				fillRect(img, x, y, w, h, c)
+			} else if i > 0 {
+				x1, y1 := layout.linePoint(i-1, series[i-1])
+				x2, y2 := layout.linePoint(i, v)
+				drawLine(img, x1, y1, x2, y2, 2, c)
+			}
+		}
+	}
+	for s := range self.Names {
+		fillRect(img, layout.left+layout.width+12, layout.top+float64(s)*18, 12, 12, chartColor(s))
+	}
+
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func fillRect(img *image.RGBA, x float64, y float64, width float64, height float64, c color.RGBA) {
+	r := image.Rect(int(math.Round(x)), int(math.Round(y)), int(math.Round(x+width)), int(math.Round(y+height))).Intersect(img.Bounds())
+	for py := r.Min.Y; py < r.Max.Y; py++ {
+		for px := r.Min.X; px < r.Max.X; px++ {
+			img.SetRGBA(px, py, c)
+		}
+	}
+}
+
+// drawLine draws a line thickness pixels wide by stepping along its longer axis
+func drawLine(img *image.RGBA, x1 float64, y1 float64, x2 float64, y2 float64, thickness int, c color.RGBA) {
+	steps := int(math.Max(math.Abs(x2-x1), math.Abs(y2-y1))) + 1
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		x := int(math.Round(x1 + (x2-x1)*t))
+		y := int(math.Round(y1 + (y2-y1)*t))
+		for dy := 0; dy < thickness; dy++ {
+			for dx := 0; dx < thickness; dx++ {
+				if (image.Point{x + dx, y + dy}).In(img.Bounds()) {
+					img.SetRGBA(x+dx, y+dy, c)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests rendering charts.
+
+package golisp
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type PlotSuite struct {
+}
+
+var _ = Suite(&PlotSuite{})
+
+func (s *PlotSuite) TestLineLayout(c *C) {
+	chart := &Chart{Kind: "line", Width: 200, Height: 150, Series: [][]float64{{1, 3, 2}}}
+	layout := chart.layout()
+	c.Assert(layout.low, Equals, 1.0)
+	c.Assert(layout.high, Equals, 3.0)
+	x, y := layout.linePoint(0, 3)
+	c.Assert(x, Equals, layout.left)
+	c.Assert(y, Equals, layout.top)
+	x, y = layout.linePoint(2, 1)
+	c.Assert(x, Equals, layout.left+layout.width)
+	c.Assert(y, Equals, layout.bottom())
+}
+
+func (s *PlotSuite) TestBarsStartAtZero(c *C) {
+	chart := &Chart{Kind: "bar", Width: 200, Height: 150, Series: [][]float64{{2, 4}, {3, 1}}}
+	layout := chart.layout()
+	c.Assert(layout.low, Equals, 0.0)
+	x1, y, w, h := layout.bar(0, 1, 4)
+	c.Assert(y, Equals, layout.top)
+	c.Assert(h, Equals, layout.height)
+	x2, _, _, _ := layout.bar(1, 1, 1)
+	c.Assert(x2, Equals, x1+w)
+}
+
+func (s *PlotSuite) TestFlatSeries(c *C) {
+	layout := (&Chart{Kind: "line", Width: 200, Height: 150, Series: [][]float64{{5}}}).layout()
+	c.Assert(layout.low < layout.high, Equals, true)
+	x, _ := layout.linePoint(0, 5)
+	c.Assert(x, Equals, layout.left+layout.width/2)
+}
+
+func (s *PlotSuite) TestSVG(c *C) {
+	chart := &Chart{Kind: "line", Title: "a < b", Width: 300, Height: 200, Series: [][]float64{{1, 2}, {2, 1}}, Names: []string{"up", "down"}}
+	svg := chart.SVG()
+	c.Assert(strings.HasPrefix(svg, "<svg "), Equals, true)
+	c.Assert(strings.Count(svg, "<polyline"), Equals, 2)
+	c.Assert(strings.Contains(svg, "a &lt; b"), Equals, true)
+	c.Assert(strings.Contains(svg, ">down</text>"), Equals, true)
+	c.Assert(strings.Contains(chart.HTML(), svg), Equals, true)
+}
+
+func (s *PlotSuite) TestPNG(c *C) {
+	chart := &Chart{Kind: "bar", Width: 300, Height: 200, Series: [][]float64{{1, -2, 3}}}
+	data, err := chart.PNG()
+	c.Assert(err, IsNil)
+	img, err := png.Decode(bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	c.Assert(img.Bounds().Dx(), Equals, 300)
+	c.Assert(img.Bounds().Dy(), Equals, 200)
+	layout := chart.layout()
+	x, y, w, h := layout.bar(0, 2, 3)
+	r, g, b, _ := img.At(int(x+w/2), int(y+h/2)).RGBA()
+	first := chartColor(0)
+	c.Assert([]uint32{r >> 8, g >> 8, b >> 8}, DeepEquals, []uint32{uint32(first.R), uint32(first.G), uint32(first.B)})
+}
+
+func (s *PlotSuite) TestRestricted(c *C) {
+	env := NewSymbolTableFrameBelow(Global, "restricted plot test")
+	env.IsRestricted = true
+	code, err := Parse(`(plot '(1 2 3) {file: "restricted-plot-test.svg"})`)
+	c.Assert(err, IsNil)
+	_, err = Eval(code, env)
+	c.Assert(err, ErrorMatches, "(?s).*restricted.*")
+}
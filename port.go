@@ -0,0 +1,145 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements ports over files and other byte streams.
+
+package golisp
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/SteelSeries/bufrr"
+)
+
+// Port is what a lisp port reads from and writes to: a file or any other byte stream, such
+// as a TCP connection or a device transport provided by the host, so that the reading and
+// writing primitives work the same over all of them
+type Port struct {
+	name      string
+	stream    io.ReadWriteCloser
+	timeout   int64
+	tokenizer *Tokenizer
+}
+
+// deadliner is implemented by streams that support timeouts, such as net.Conn and pipes
+type deadliner interface {
+	SetDeadline(t time.Time) error
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+}
+
+// PortWithStream makes a port named name, for printing, over stream
+func PortWithStream(name string, stream io.ReadWriteCloser) *Data {
+	return PortWithPort(&Port{name: name, stream: stream})
+}
+
+// OpenTCPPort connects to address, e.g. "localhost:8080", failing if that takes longer
+// than timeout when it isn't zero, and returns a port over the connection whose reads
+// and writes have the same timeout
+func OpenTCPPort(address string, timeout time.Duration) (port *Port, err error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, ioError(err)
+	}
+	port = &Port{name: address, stream: conn}
+	port.timeout = int64(timeout)
+	return port, nil
+}
+
+func (self *Port) Name() string {
+	return self.name
+}
+
+// File returns the file the port is over, or nil if it is over some other stream
+func (self *Port) File() *os.File {
+	file, _ := self.stream.(*os.File)
+	return file
+}
+
+// Timeout returns how long reads and writes may take before failing, where zero means they
+// can take forever
+func (self *Port) Timeout() time.Duration {
+	return time.Duration(atomic.LoadInt64(&self.timeout))
+}
+
+// SetTimeout limits how long each read and write may take, or removes the limit if timeout
+// is zero.  Only streams with deadlines, such as network connections, support timeouts.
+func (self *Port) SetTimeout(timeout time.Duration) error {
+	stream, supported := self.stream.(deadliner)
+	if !supported || stream.SetDeadline(time.Time{}) != nil {
+		return NewLispError(IOError, "Port "+self.name+" does not support timeouts.")
+	}
+	atomic.StoreInt64(&self.timeout, int64(timeout))
+	return nil
+}
+
+func (self *Port) deadline() time.Time {
+	if timeout := self.Timeout(); timeout > 0 {
+		return time.Now().Add(timeout)
+	}
+	return time.Time{}
+}
+
+func (self *Port) Read(p []byte) (n int, err error) {
+	if stream, supported := self.stream.(deadliner); supported && self.Timeout() > 0 {
+		stream.SetReadDeadline(self.deadline())
+	}
+	return self.stream.Read(p)
+}
+
+func (self *Port) Write(p []byte) (n int, err error) {
+	if stream, supported := self.stream.(deadliner); supported && self.Timeout() > 0 {
+		stream.SetWriteDeadline(self.deadline())
+	}
+	return self.stream.Write(p)
+}
+
+func (self *Port) Close() error {
+	return self.stream.Close()
+}
+
+// Tokenizer returns the tokenizer that read uses for the port.  It reads ahead, so bytes
+// read with it aren't available to read-bytes.
+func (self *Port) Tokenizer() *Tokenizer {
+	if self.tokenizer == nil {
+		self.tokenizer = NewTokenizer(bufrr.NewReader(self))
+	}
+	return self.tokenizer
+}
+
+// readBytesChunk is the most ReadBytes reads at a time, so that a huge count doesn't
+// allocate a huge buffer before any bytes have arrived
+const readBytesChunk = 64 * 1024
+
+// ReadBytes reads count bytes, or fewer if the stream ends first, returning io.EOF if it
+// had already ended
+func (self *Port) ReadBytes(count int) (bytes []byte, err error) {
+	for len(bytes) < count {
+		chunk := count - len(bytes)
+		if chunk > readBytesChunk {
+			chunk = readBytesChunk
+		}
+		start := len(bytes)
+		bytes = append(bytes, make([]byte, chunk)...)
+		var n int
+		n, err = io.ReadFull(self, bytes[start:])
+		bytes = bytes[:start+n]
+		if err != nil {
+			break
+		}
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || (err == io.EOF && len(bytes) > 0) {
+		err = nil
+	}
+	if bytes == nil {
+		bytes = []byte{}
+	}
+	return
+}
@@ -0,0 +1,109 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests ports over byte streams other than files.
+
+package golisp
+
+import (
+	"fmt"
+	. "gopkg.in/check.v1"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"time"
+)
+
+type PortSuite struct {
+}
+
+var _ = Suite(&PortSuite{})
+
+func (s *PortSuite) TestReadingFromAHostStream(c *C) {
+	device, host := net.Pipe()
+	defer device.Close()
+	go func() {
+		io.WriteString(host, "(1 2 3) done")
+		host.Close()
+	}()
+
+	Global.BindTo(Intern("port-test-device"), PortWithStream("device", device))
+	result, err := ParseAndEval("(list (read port-test-device) (read port-test-device) (eof-object? (read port-test-device)))")
+	c.Assert(err, IsNil)
+	c.Assert(String(result), Equals, "((1 2 3) done #t)")
+}
+
+func (s *PortSuite) TestBytesOverTCP(c *C) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		request := make([]byte, 3)
+		io.ReadFull(conn, request)
+		conn.Write([]byte{request[2], request[1], request[0]})
+	}()
+
+	code := fmt.Sprintf(`(let ((p (open-tcp-port "%s" 1000)))
+  (write-bytes [1 2 3] p)
+  (let ((reply (read-bytes p 3)))
+    (close-port p)
+    reply))`, listener.Addr())
+	result, err := ParseAndEval(code)
+	c.Assert(err, IsNil)
+	c.Assert(String(result), Equals, "[3 2 1]")
+}
+
+func (s *PortSuite) TestTimeouts(c *C) {
+	device, host := net.Pipe()
+	defer device.Close()
+	defer host.Close()
+
+	Global.BindTo(Intern("port-test-silent"), PortWithStream("silent", device))
+	result, err := ParseAndEval("(port-timeout (set-port-timeout! port-test-silent 20))")
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(20))
+
+	start := time.Now()
+	_, err = ParseAndEval("(read-bytes port-test-silent 1)")
+	c.Assert(err, ErrorMatches, "(?s).*timeout.*")
+	c.Assert(time.Since(start) < time.Second, Equals, true)
+}
+
+func (s *PortSuite) TestFilesDoNotSupportTimeouts(c *C) {
+	filename := filepath.Join(c.MkDir(), "port.txt")
+	c.Assert(ioutil.WriteFile(filename, []byte("hello"), 0644), IsNil)
+	code := fmt.Sprintf(`(open-input-file "%s")`, filename)
+	port, err := ParseAndEval(code)
+	c.Assert(err, IsNil)
+	c.Assert(PortValue(port).File(), NotNil)
+	c.Assert(PortValue(port).SetTimeout(time.Second), NotNil)
+	PortValue(port).Close()
+}
+
+func (s *PortSuite) TestReadingHugeCounts(c *C) {
+	device, host := net.Pipe()
+	defer device.Close()
+	go func() {
+		host.Write(make([]byte, 100000))
+		host.Close()
+	}()
+
+	Global.BindTo(Intern("port-test-huge"), PortWithStream("huge", device))
+	result, err := ParseAndEval("(length (read-bytes port-test-huge 9223372036854775807))")
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(100000))
+	result, err = ParseAndEval("(eof-object? (read-bytes port-test-huge 9223372036854775807))")
+	c.Assert(err, IsNil)
+	c.Assert(BooleanValue(result), Equals, true)
+	result, err = ParseAndEval("(length (read-bytes port-test-huge 0))")
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(0))
+}
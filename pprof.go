@@ -0,0 +1,155 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements writing profiles in pprof's protobuf format.
+
+package golisp
+
+// protobuf is just enough of a protocol buffer encoder for pprof profiles
+type protobuf struct {
+	data []byte
+}
+
+func (self *protobuf) varint(n uint64) {
+	for n >= 0x80 {
+		self.data = append(self.data, byte(n)|0x80)
+		n >>= 7
+	}
+	self.data = append(self.data, byte(n))
+}
+
+func (self *protobuf) key(field int, wireType int) {
+	self.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (self *protobuf) uint64Field(field int, n uint64) {
+	if n != 0 {
+		self.key(field, 0)
+		self.varint(n)
+	}
+}
+
+func (self *protobuf) int64Field(field int, n int64) {
+	self.uint64Field(field, uint64(n))
+}
+
+func (self *protobuf) bytesField(field int, b []byte) {
+	self.key(field, 2)
+	self.varint(uint64(len(b)))
+	self.data = append(self.data, b...)
+}
+
+func (self *protobuf) messageField(field int, message *protobuf) {
+	self.bytesField(field, message.data)
+}
+
+// packedField writes numbers as a packed repeated field
+func (self *protobuf) packedField(field int, numbers []uint64) {
+	var packed protobuf
+	for _, n := range numbers {
+		packed.varint(n)
+	}
+	self.bytesField(field, packed.data)
+}
+
+// Field numbers from pprof's profile.proto
+const (
+	pprofSampleType  = 1
+	pprofSample      = 2
+	pprofLocation    = 4
+	pprofFunction    = 5
+	pprofStringTable = 6
+
+	pprofValueTypeType = 1
+	pprofValueTypeUnit = 2
+
+	pprofSampleLocationID = 1
+	pprofSampleValue      = 2
+
+	pprofLocationID   = 1
+	pprofLocationLine = 4
+	pprofLineFunction = 1
+
+	pprofFunctionID   = 1
+	pprofFunctionName = 2
+)
+
+// pprofBuilder collects the samples of a profile, giving each function a location
+type pprofBuilder struct {
+	profile   protobuf
+	strings   []string
+	stringIDs map[string]int64
+	functions map[string]uint64
+	tail      protobuf
+}
+
+func newPprofBuilder() *pprofBuilder {
+	return &pprofBuilder{strings: []string{""}, stringIDs: map[string]int64{"": 0}, functions: make(map[string]uint64)}
+}
+
+func (self *pprofBuilder) stringID(s string) int64 {
+	if id, found := self.stringIDs[s]; found {
+		return id
+	}
+	id := int64(len(self.strings))
+	self.strings = append(self.strings, s)
+	self.stringIDs[s] = id
+	return id
+}
+
+func (self *pprofBuilder) sampleType(kind string, unit string) {
+	var valueType protobuf
+	valueType.int64Field(pprofValueTypeType, self.stringID(kind))
+	valueType.int64Field(pprofValueTypeUnit, self.stringID(unit))
+	self.profile.messageField(pprofSampleType, &valueType)
+}
+
+// locationID returns the id of the location of the function called name, which is also
+// the function's id, adding both the first time name is seen
+func (self *pprofBuilder) locationID(name string) uint64 {
+	if id, found := self.functions[name]; found {
+		return id
+	}
+	id := uint64(len(self.functions) + 1)
+	self.functions[name] = id
+
+	var function protobuf
+	function.uint64Field(pprofFunctionID, id)
+	function.int64Field(pprofFunctionName, self.stringID(name))
+	self.tail.messageField(pprofFunction, &function)
+
+	var line protobuf
+	line.uint64Field(pprofLineFunction, id)
+	var location protobuf
+	location.uint64Field(pprofLocationID, id)
+	location.messageField(pprofLocationLine, &line)
+	self.tail.messageField(pprofLocation, &location)
+	return id
+}
+
+// sample adds a sample for stack, which is outermost first
+func (self *pprofBuilder) sample(stack []string, values ...int64) {
+	locations := make([]uint64, len(stack))
+	for i, name := range stack {
+		locations[len(stack)-1-i] = self.locationID(name)
+	}
+	numbers := make([]uint64, len(values))
+	for i, value := range values {
+		numbers[i] = uint64(value)
+	}
+
+	var sample protobuf
+	sample.packedField(pprofSampleLocationID, locations)
+	sample.packedField(pprofSampleValue, numbers)
+	self.profile.messageField(pprofSample, &sample)
+}
+
+func (self *pprofBuilder) bytes() []byte {
+	result := protobuf{data: append(append([]byte{}, self.profile.data...), self.tail.data...)}
+	for _, s := range self.strings {
+		result.bytesField(pprofStringTable, []byte(s))
+	}
+	return result.data
+}
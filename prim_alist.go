@@ -46,14 +46,14 @@ func AconsImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func PairlisImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	keys := Car(args)
 	if !PairP(keys) {
-		err = ProcessError("First arg of pairlis must be a list", env)
+		err = ProcessTypeError("First arg of pairlis must be a list", env)
 		return
 	}
 
 	values := Cadr(args)
 
 	if !PairP(values) {
-		err = ProcessError("Second arg of Pairlis must be a list", env)
+		err = ProcessTypeError("Second arg of Pairlis must be a list", env)
 		return
 	}
 
@@ -66,7 +66,7 @@ func PairlisImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 
 	if NotNilP(result) {
 		if !PairP(result) {
-			err = ProcessError("Third arg of pairlis must be an association list (if provided)", env)
+			err = ProcessTypeError("Third arg of pairlis must be an association list (if provided)", env)
 			return
 		}
 	}
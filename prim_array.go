@@ -0,0 +1,246 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the multi-dimensional array primitive functions.
+
+package golisp
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unsafe"
+)
+
+// Array is an N-dimensional array whose elements are stored in row-major order, so the
+// last index varies fastest
+type Array struct {
+	Dimensions []int
+	Elements   []*Data
+}
+
+func RegisterArrayPrimitives() {
+	MakePrimitiveFunction("make-array", "1|2", MakeArrayImpl)
+	MakePrimitiveFunction("array?", "1", IsArrayImpl)
+	MakePrimitiveFunction("array-ref", ">=1", ArrayRefImpl)
+	MakePrimitiveFunction("array-set!", ">=2", ArraySetImpl)
+	MakePrimitiveFunction("array-dimensions", "1", ArrayDimensionsImpl)
+	MakePrimitiveFunction("array-rank", "1", ArrayRankImpl)
+	MakePrimitiveFunction("array->vector", "1", ArrayToVectorImpl)
+
+	RegisterObjectEquality("Array",
+		func(a unsafe.Pointer, b unsafe.Pointer) bool {
+			return (*Array)(a).equal((*Array)(b))
+		},
+		func(o unsafe.Pointer) uint64 {
+			return (*Array)(o).hash()
+		})
+}
+
+// Arrays can have at most this many elements
+const maxArrayElements = math.MaxInt32
+
+// NewArray makes an array of the given dimensions with every element set to fill, or an
+// error if a dimension is negative or there would be more than maxArrayElements elements
+func NewArray(dimensions []int, fill *Data) (array *Array, err error) {
+	size := 1
+	for _, dimension := range dimensions {
+		if dimension < 0 {
+			return nil, fmt.Errorf("dimension %d is negative", dimension)
+		}
+		if dimension > 0 && size > maxArrayElements/dimension {
+			return nil, fmt.Errorf("an array can't have more than %d elements", maxArrayElements)
+		}
+		size *= dimension
+	}
+	elements := make([]*Data, size)
+	for i := range elements {
+		elements[i] = fill
+	}
+	return &Array{Dimensions: dimensions, Elements: elements}, nil
+}
+
+func (self *Array) String() string {
+	dimensions := make([]string, len(self.Dimensions))
+	for i, dimension := range self.Dimensions {
+		dimensions[i] = fmt.Sprintf("%d", dimension)
+	}
+	return fmt.Sprintf("<array %s>", strings.Join(dimensions, "x"))
+}
+
+func (self *Array) equal(other *Array) bool {
+	if len(self.Dimensions) != len(other.Dimensions) || len(self.Elements) != len(other.Elements) {
+		return false
+	}
+	for i, dimension := range self.Dimensions {
+		if other.Dimensions[i] != dimension {
+			return false
+		}
+	}
+	for i, element := range self.Elements {
+		if !IsDeepEqual(element, other.Elements[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (self *Array) hash() uint64 {
+	h := hashUint64('a', uint64(len(self.Dimensions)))
+	for _, dimension := range self.Dimensions {
+		h = combineHashes(h, uint64(dimension))
+	}
+	for i := 0; i < len(self.Elements) && i < maxHashElements; i++ {
+		h = combineHashes(h, hashHelper(self.Elements[i], maxHashDepth-1))
+	}
+	return h
+}
+
+// Offset returns where the element at indices is stored, or an error if there aren't as
+// many indices as dimensions or one of them is out of range
+func (self *Array) Offset(indices []int) (offset int, err error) {
+	if len(indices) != len(self.Dimensions) {
+		return 0, fmt.Errorf("%d indices were given for an array of rank %d", len(indices), len(self.Dimensions))
+	}
+	for i, index := range indices {
+		if index < 0 || index >= self.Dimensions[i] {
+			return 0, fmt.Errorf("index %d was %d but that dimension has a size of %d", i, index, self.Dimensions[i])
+		}
+		offset = offset*self.Dimensions[i] + index
+	}
+	return
+}
+
+func ArrayWithValue(array *Array) *Data {
+	return ObjectWithTypeAndValue("Array", unsafe.Pointer(array))
+}
+
+func ArrayP(d *Data) bool {
+	return ObjectP(d) && ObjectType(d) == "Array"
+}
+
+func ArrayValue(d *Data) *Array {
+	if !ArrayP(d) {
+		return nil
+	}
+	return (*Array)(ObjectValue(d))
+}
+
+func arrayArg(name string, d *Data, env *SymbolTableFrame) (array *Array, err error) {
+	if !ArrayP(d) {
+		err = ProcessTypeError(fmt.Sprintf("%s requires an array but was given %s.", name, String(d)), env)
+		return
+	}
+	return ArrayValue(d), nil
+}
+
+// arrayOffsetArg finds where the element of array at the indices in the list l is stored
+func arrayOffsetArg(name string, array *Array, l *Data, env *SymbolTableFrame) (offset int, err error) {
+	indices := make([]int, 0, len(array.Dimensions))
+	for c := l; NotNilP(c); c = Cdr(c) {
+		if !IntegerP(Car(c)) {
+			err = ProcessTypeError(fmt.Sprintf("%s requires integer indices but was given %s.", name, String(Car(c))), env)
+			return
+		}
+		indices = append(indices, int(IntegerValue(Car(c))))
+	}
+	offset, err = array.Offset(indices)
+	if err != nil {
+		err = ProcessIndexError(fmt.Sprintf("%s: %s.", name, err), env)
+	}
+	return
+}
+
+// MakeArrayImpl handles (make-array dimensions [fill]), where dimensions is a list of
+// sizes, or a single size for a one-dimensional array
+func MakeArrayImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	sizes := Car(args)
+	if IntegerP(sizes) {
+		sizes = InternalMakeList(sizes)
+	}
+	if !ListP(sizes) || NilP(sizes) {
+		err = ProcessTypeError(fmt.Sprintf("make-array requires a list of dimensions but was given %s.", String(Car(args))), env)
+		return
+	}
+
+	dimensions := make([]int, 0, Length(sizes))
+	for c := sizes; NotNilP(c); c = Cdr(c) {
+		if !IntegerP(Car(c)) || IntegerValue(Car(c)) < 0 {
+			err = ProcessTypeError(fmt.Sprintf("make-array requires non-negative integer dimensions but was given %s.", String(Car(c))), env)
+			return
+		}
+		dimensions = append(dimensions, int(IntegerValue(Car(c))))
+	}
+	array, err := NewArray(dimensions, Cadr(args))
+	if err != nil {
+		err = ProcessError(fmt.Sprintf("make-array: %s.", err), env)
+		return
+	}
+	return ArrayWithValue(array), nil
+}
+
+func IsArrayImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(ArrayP(Car(args))), nil
+}
+
+// ArrayRefImpl handles (array-ref array index...), with one index per dimension
+func ArrayRefImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	array, err := arrayArg("array-ref", Car(args), env)
+	if err != nil {
+		return
+	}
+	offset, err := arrayOffsetArg("array-ref", array, Cdr(args), env)
+	if err != nil {
+		return
+	}
+	return array.Elements[offset], nil
+}
+
+// ArraySetImpl handles (array-set! array index... value), resulting in value
+func ArraySetImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	array, err := arrayArg("array-set!", Car(args), env)
+	if err != nil {
+		return
+	}
+	rest := ToArray(Cdr(args))
+	offset, err := arrayOffsetArg("array-set!", array, ArrayToList(rest[:len(rest)-1]), env)
+	if err != nil {
+		return
+	}
+	value := rest[len(rest)-1]
+	array.Elements[offset] = value
+	return value, nil
+}
+
+func ArrayDimensionsImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	array, err := arrayArg("array-dimensions", Car(args), env)
+	if err != nil {
+		return
+	}
+	dimensions := make([]*Data, len(array.Dimensions))
+	for i, dimension := range array.Dimensions {
+		dimensions[i] = IntegerWithValue(int64(dimension))
+	}
+	return ArrayToList(dimensions), nil
+}
+
+func ArrayRankImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	array, err := arrayArg("array-rank", Car(args), env)
+	if err != nil {
+		return
+	}
+	return IntegerWithValue(int64(len(array.Dimensions))), nil
+}
+
+// ArrayToVectorImpl results in a new vector of the array's elements in row-major order
+func ArrayToVectorImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	array, err := arrayArg("array->vector", Car(args), env)
+	if err != nil {
+		return
+	}
+	elements := make([]*Data, len(array.Elements))
+	copy(elements, array.Elements)
+	return VectorWithValue(elements), nil
+}
@@ -9,95 +9,145 @@ package golisp
 
 import (
 	"fmt"
+	"math"
 )
 
 func RegisterBinaryPrimitives() {
 	MakePrimitiveFunction("binary-and", "2", BinaryAndImpl)
 	MakePrimitiveFunction("binary-or", "2", BinaryOrImpl)
-	MakePrimitiveFunction("binary-not", "1", BinaryNotImpl)
+	MakePrimitiveFunction("binary-xor", "2", BinaryXorImpl)
+	MakePrimitiveFunction("binary-not", "1|2", BinaryNotImpl)
 	MakePrimitiveFunction("left-shift", "2", LeftShiftImpl)
 	MakePrimitiveFunction("right-shift", "2", RightShiftImpl)
+	MakePrimitiveFunction("uint64?", "1", IsUint64Impl)
+	MakePrimitiveFunction("int64->uint64", "1", Int64ToUint64Impl)
+	MakePrimitiveFunction("uint64->int64", "1", Uint64ToInt64Impl)
 }
 
-func BinaryAndImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	arg1 := First(args)
-	if !IntegerP(arg1) {
-		err = ProcessError(fmt.Sprintf("Integer expected, received %s %s", TypeName(TypeOf(arg1)), String(arg1)), env)
-		return
-	}
-	b1 := uint64(IntegerValue(arg1))
+// Binary operations work on 64 bits.  Integers up to 2^64-1 are treated as unsigned, and so
+// are the results of operating on them; negative integers are treated as two's complement,
+// and make the result signed as well.
 
-	arg2 := Second(args)
-	if !IntegerP(arg2) {
-		err = ProcessError(fmt.Sprintf("Integer expected, received %s %s", TypeName(TypeOf(arg2)), String(arg2)), env)
+func bitsArg(arg *Data, env *SymbolTableFrame) (bits uint64, signed bool, err error) {
+	if IntegerP(arg) {
+		return uint64(IntegerValue(arg)), IntegerValue(arg) < 0, nil
+	}
+	if Uint64P(arg) {
+		return Uint64Value(arg), false, nil
+	}
+	if BigIntegerP(arg) {
+		err = ProcessError(fmt.Sprintf("Integer of at most 64 bits expected, received %s", String(arg)), env)
 		return
 	}
-	b2 := uint64(IntegerValue(arg2))
+	err = ProcessTypeError(fmt.Sprintf("Integer expected, received %s %s", TypeName(TypeOf(arg)), String(arg)), env)
+	return
+}
 
-	return IntegerWithValue(int64(b1 & b2)), nil
+func bitsResult(bits uint64, signed bool) *Data {
+	if signed {
+		return IntegerWithValue(int64(bits))
+	}
+	return Uint64WithValue(bits)
 }
 
-func BinaryOrImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	arg1 := First(args)
-	if !IntegerP(arg1) {
-		err = ProcessError(fmt.Sprintf("Integer expected, received %s %s", TypeName(TypeOf(arg1)), String(arg1)), env)
+func binaryOperation(args *Data, env *SymbolTableFrame, op func(uint64, uint64) uint64) (result *Data, err error) {
+	b1, signed1, err := bitsArg(First(args), env)
+	if err != nil {
 		return
 	}
-	b1 := uint64(IntegerValue(arg1))
-
-	arg2 := Second(args)
-	if !IntegerP(arg2) {
-		err = ProcessError(fmt.Sprintf("Integer expected, received %s %s", TypeName(TypeOf(arg2)), String(arg2)), env)
+	b2, signed2, err := bitsArg(Second(args), env)
+	if err != nil {
 		return
 	}
-	b2 := uint64(IntegerValue(arg2))
+	return bitsResult(op(b1, b2), signed1 || signed2), nil
+}
 
-	return IntegerWithValue(int64(b1 | b2)), nil
+func BinaryAndImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return binaryOperation(args, env, func(b1 uint64, b2 uint64) uint64 { return b1 & b2 })
+}
+
+func BinaryOrImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return binaryOperation(args, env, func(b1 uint64, b2 uint64) uint64 { return b1 | b2 })
 }
 
+func BinaryXorImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return binaryOperation(args, env, func(b1 uint64, b2 uint64) uint64 { return b1 ^ b2 })
+}
+
+// BinaryNotImpl handles (binary-not n [bits]), which inverts the low bits of n, 32 of them
+// unless specified
 func BinaryNotImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	arg1 := First(args)
-	if !IntegerP(arg1) {
-		err = ProcessError(fmt.Sprintf("Integer expected, received %s %s", TypeName(TypeOf(arg1)), String(arg1)), env)
+	b1, signed, err := bitsArg(First(args), env)
+	if err != nil {
 		return
 	}
-	b1 := uint64(IntegerValue(arg1))
 
-	return IntegerWithValue(int64(b1 ^ uint64(0xFFFFFFFF))), nil
+	var mask uint64 = 0xFFFFFFFF
+	if NotNilP(Cdr(args)) {
+		width := Second(args)
+		if !IntegerP(width) || IntegerValue(width) < 1 || IntegerValue(width) > 64 {
+			err = ProcessError(fmt.Sprintf("binary-not expected a bit width from 1 to 64, received %s", String(width)), env)
+			return
+		}
+		mask = math.MaxUint64 >> uint(64-IntegerValue(width))
+	}
+
+	return bitsResult(b1^mask, signed), nil
 }
 
-func LeftShiftImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	arg1 := First(args)
-	if !IntegerP(arg1) {
-		err = ProcessError(fmt.Sprintf("Integer expected, received %s %s", TypeName(TypeOf(arg1)), String(arg1)), env)
+func shiftArgs(args *Data, env *SymbolTableFrame) (bits uint64, signed bool, count uint64, err error) {
+	bits, signed, err = bitsArg(First(args), env)
+	if err != nil {
 		return
 	}
-	b1 := uint64(IntegerValue(arg1))
 
 	arg2 := Second(args)
 	if !IntegerP(arg2) {
-		err = ProcessError(fmt.Sprintf("Integer expected, received %s %s", TypeName(TypeOf(arg2)), String(arg2)), env)
+		err = ProcessTypeError(fmt.Sprintf("Integer expected, received %s %s", TypeName(TypeOf(arg2)), String(arg2)), env)
 		return
 	}
-	b2 := uint64(IntegerValue(arg2))
+	count = uint64(IntegerValue(arg2))
+	return
+}
 
-	return IntegerWithValue(int64(b1 << b2)), nil
+func LeftShiftImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	b1, signed, b2, err := shiftArgs(args, env)
+	if err != nil {
+		return
+	}
+	return bitsResult(b1<<b2, signed), nil
 }
 
 func RightShiftImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	arg1 := First(args)
-	if !IntegerP(arg1) {
-		err = ProcessError(fmt.Sprintf("Integer expected, received %s %s", TypeName(TypeOf(arg1)), String(arg1)), env)
+	b1, signed, b2, err := shiftArgs(args, env)
+	if err != nil {
 		return
 	}
-	b1 := uint64(IntegerValue(arg1))
+	return bitsResult(b1>>b2, signed), nil
+}
 
-	arg2 := Second(args)
-	if !IntegerP(arg2) {
-		err = ProcessError(fmt.Sprintf("Integer expected, received %s %s", TypeName(TypeOf(arg2)), String(arg2)), env)
+func IsUint64Impl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(Uint64P(Car(args))), nil
+}
+
+// Int64ToUint64Impl reinterprets a signed 64 bit value as unsigned, e.g. a serial number that
+// came from a device as an int64: (int64->uint64 -1) is 18446744073709551615
+func Int64ToUint64Impl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	n := Car(args)
+	if !IntegerP(n) {
+		err = ProcessTypeError(fmt.Sprintf("int64->uint64 expected a 64 bit integer, received %s", String(n)), env)
 		return
 	}
-	b2 := uint64(IntegerValue(arg2))
+	return Uint64WithValue(uint64(IntegerValue(n))), nil
+}
 
-	return IntegerWithValue(int64(b1 >> b2)), nil
+// Uint64ToInt64Impl reinterprets an unsigned 64 bit value as signed, the reverse of
+// int64->uint64
+func Uint64ToInt64Impl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	n := Car(args)
+	if !Uint64P(n) {
+		err = ProcessTypeError(fmt.Sprintf("uint64->int64 expected an unsigned 64 bit integer, received %s", String(n)), env)
+		return
+	}
+	return IntegerWithValue(int64(Uint64Value(n))), nil
 }
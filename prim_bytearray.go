@@ -23,6 +23,7 @@ func RegisterBytearrayPrimitives() {
 	MakePrimitiveFunction("append-bytes", "*", AppendBytesImpl)
 	MakePrimitiveFunction("append-bytes!", "*", AppendBytesBangImpl)
 	MakePrimitiveFunction("extract-bytes", "3", ExtractBytesImpl)
+	MakeSpecialForm("bit-match", ">=2", BitMatchImpl)
 }
 
 func ListToBytesImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
@@ -32,7 +33,7 @@ func ListToBytesImpl(args *Data, env *SymbolTableFrame) (result *Data, err error
 		return
 	}
 	if !ListP(list) {
-		err = ProcessError("Argument to list->bytes must be a list.", env)
+		err = ProcessTypeError("Argument to list->bytes must be a list.", env)
 		return
 	}
 
@@ -41,7 +42,7 @@ func ListToBytesImpl(args *Data, env *SymbolTableFrame) (result *Data, err error
 		var n *Data
 		n, err = Eval(Car(c), env)
 		if !IntegerP(n) && !(ObjectP(n) && ObjectType(n) == "[]byte") {
-			err = ProcessError(fmt.Sprintf("Byte arrays can only contain numbers, but found %v.", n), env)
+			err = ProcessTypeError(fmt.Sprintf("Byte arrays can only contain numbers, but found %v.", n), env)
 			return
 		}
 
@@ -49,7 +50,7 @@ func ListToBytesImpl(args *Data, env *SymbolTableFrame) (result *Data, err error
 			b := IntegerValue(n)
 			if b < 0 || b > 255 {
 
-				err = ProcessError(fmt.Sprintf("Byte arrays can only contain bytes, but found %d.", b), env)
+				err = ProcessTypeError(fmt.Sprintf("Byte arrays can only contain bytes, but found %d.", b), env)
 				return
 			}
 			bytes = append(bytes, byte(b))
@@ -82,7 +83,7 @@ func BytesToListImpl(args *Data, env *SymbolTableFrame) (result *Data, err error
 func internalReplaceByte(args *Data, env *SymbolTableFrame, makeCopy bool) (result *Data, err error) {
 	dataByteObject := First(args)
 	if !ObjectP(dataByteObject) || ObjectType(dataByteObject) != "[]byte" {
-		err = ProcessError(fmt.Sprintf("replace-byte expects a bytearray as it's first argument but received %s.", ObjectType(dataByteObject)), env)
+		err = ProcessTypeError(fmt.Sprintf("replace-byte expects a bytearray as it's first argument but received %s.", ObjectType(dataByteObject)), env)
 		return
 	}
 
@@ -98,24 +99,24 @@ func internalReplaceByte(args *Data, env *SymbolTableFrame, makeCopy bool) (resu
 
 	indexObject := Second(args)
 	if !IntegerP(indexObject) {
-		err = ProcessError("Bytearray index should be an integer.", env)
+		err = ProcessTypeError("Bytearray index should be an integer.", env)
 		return
 	}
 	index := int(IntegerValue(indexObject))
 
 	if index >= len(*dataBytes) {
-		err = ProcessError(fmt.Sprintf("replace-byte index was out of range. Was %d but bytearray has length of %d.", index, len(*dataBytes)), env)
+		err = ProcessIndexError(fmt.Sprintf("replace-byte index was out of range. Was %d but bytearray has length of %d.", index, len(*dataBytes)), env)
 		return
 	}
 
 	if index < 0 {
-		err = ProcessError(fmt.Sprintf("replace-byte index was out of range: %d.", index), env)
+		err = ProcessIndexError(fmt.Sprintf("replace-byte index was out of range: %d.", index), env)
 		return
 	}
 
 	valueObject := Third(args)
 	if !IntegerP(valueObject) {
-		err = ProcessError("Bytearray value should be an integer.", env)
+		err = ProcessTypeError("Bytearray value should be an integer.", env)
 		return
 	}
 
@@ -155,18 +156,18 @@ func ExtractByteImpl(args *Data, env *SymbolTableFrame) (result *Data, err error
 
 	indexObject := Cadr(args)
 	if !IntegerP(indexObject) {
-		err = ProcessError("Bytearray index should be a number.", env)
+		err = ProcessTypeError("Bytearray index should be a number.", env)
 		return
 	}
 	index := int(IntegerValue(indexObject))
 
 	if index >= len(*dataBytes) {
-		err = ProcessError(fmt.Sprintf("extract-byte index was out of range. Was %d but bytearray has length of %d.", index, len(*dataBytes)), env)
+		err = ProcessIndexError(fmt.Sprintf("extract-byte index was out of range. Was %d but bytearray has length of %d.", index, len(*dataBytes)), env)
 		return
 	}
 
 	if index < 0 {
-		err = ProcessError(fmt.Sprintf("extract-byte index was out of range: %d.", index), env)
+		err = ProcessIndexError(fmt.Sprintf("extract-byte index was out of range: %d.", index), env)
 		return
 	}
 
@@ -178,7 +179,7 @@ func ExtractByteImpl(args *Data, env *SymbolTableFrame) (result *Data, err error
 func internalAppendBytes(args *Data, env *SymbolTableFrame) (newBytes *[]byte, err error) {
 	dataByteObject := Car(args)
 	if !ObjectP(dataByteObject) || ObjectType(dataByteObject) != "[]byte" {
-		err = ProcessError(fmt.Sprintf("append-bytes extects first argument to be a bytearray, but was %s.", ObjectType(dataByteObject)), env)
+		err = ProcessTypeError(fmt.Sprintf("append-bytes extects first argument to be a bytearray, but was %s.", ObjectType(dataByteObject)), env)
 		return
 	}
 
@@ -244,18 +245,18 @@ func ExtractBytesImpl(args *Data, env *SymbolTableFrame) (result *Data, err erro
 
 	indexObject := Cadr(args)
 	if !IntegerP(indexObject) {
-		err = ProcessError("Bytearray index should be a number.", env)
+		err = ProcessTypeError("Bytearray index should be a number.", env)
 		return
 	}
 	index := int(IntegerValue(indexObject))
 	if index < 0 || index >= len(*dataBytes) {
-		err = ProcessError(fmt.Sprintf("extract-bytes index was out of range. Was %d but bytearray has length of %d.", index, len(*dataBytes)), env)
+		err = ProcessIndexError(fmt.Sprintf("extract-bytes index was out of range. Was %d but bytearray has length of %d.", index, len(*dataBytes)), env)
 		return
 	}
 
 	numToExtractObject := Caddr(args)
 	if !IntegerP(numToExtractObject) {
-		err = ProcessError(fmt.Sprintf("Number to extract must be a number, but was %s.", TypeName(TypeOf(numToExtractObject))), env)
+		err = ProcessTypeError(fmt.Sprintf("Number to extract must be a number, but was %s.", TypeName(TypeOf(numToExtractObject))), env)
 		return
 	}
 	numToExtract := int(IntegerValue(numToExtractObject))
@@ -264,7 +265,7 @@ func ExtractBytesImpl(args *Data, env *SymbolTableFrame) (result *Data, err erro
 		return
 	}
 	if index+numToExtract > len(*dataBytes) {
-		err = ProcessError(fmt.Sprintf("extract-bytes final index was out of range.  Was %d but bytearray has length of %d.", index+numToExtract-1, len(*dataBytes)), env)
+		err = ProcessIndexError(fmt.Sprintf("extract-bytes final index was out of range.  Was %d but bytearray has length of %d.", index+numToExtract-1, len(*dataBytes)), env)
 		return
 	}
 
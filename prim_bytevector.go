@@ -0,0 +1,389 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the bytevector primitive functions.  A bytevector is a []byte in a
+// boxed object of its own type, written #u8(1 2 3), so unlike a [ ] bytearray it can
+// only hold bytes and is never mistaken for one.
+
+package golisp
+
+import (
+	"bytes"
+	"fmt"
+	"unsafe"
+)
+
+func RegisterBytevectorPrimitives() {
+	MakePrimitiveFunction("bytevector?", "1", IsBytevectorImpl)
+	MakePrimitiveFunction("make-bytevector", "1|2", MakeBytevectorImpl)
+	MakePrimitiveFunction("bytevector", "*", BytevectorImpl)
+	MakePrimitiveFunction("bytevector-length", "1", BytevectorLengthImpl)
+	MakePrimitiveFunction("bytevector-u8-ref", "2", BytevectorU8RefImpl)
+	MakePrimitiveFunction("bytevector-u8-set!", "3", BytevectorU8SetImpl)
+	MakePrimitiveFunction("bytevector-copy", "1|2|3", BytevectorCopyImpl)
+	MakePrimitiveFunction("bytevector-copy!", "3|4|5", BytevectorCopyBangImpl)
+	MakePrimitiveFunction("bytevector-append", "*", BytevectorAppendImpl)
+	MakePrimitiveFunction("bytevector->list", "1|2|3", BytevectorToListImpl)
+	MakePrimitiveFunction("list->bytevector", "1", ListToBytevectorImpl)
+	MakePrimitiveFunction("bytevector->vector", "1|2|3", BytevectorToVectorImpl)
+	MakePrimitiveFunction("vector->bytevector", "1|2|3", VectorToBytevectorImpl)
+	MakePrimitiveFunction("utf8->string", "1|2|3", Utf8ToStringImpl)
+	MakePrimitiveFunction("string->utf8", "1", StringToUtf8Impl)
+	MakePrimitiveFunction("bytevector->bytearray", "1", BytevectorToBytearrayImpl)
+	MakePrimitiveFunction("bytearray->bytevector", "1", BytearrayToBytevectorImpl)
+
+	MakePrimitiveFunction("u8vector?", "1", IsBytevectorImpl)
+	MakePrimitiveFunction("make-u8vector", "1|2", MakeBytevectorImpl)
+	MakePrimitiveFunction("u8vector", "*", BytevectorImpl)
+	MakePrimitiveFunction("u8vector-length", "1", BytevectorLengthImpl)
+	MakePrimitiveFunction("u8vector-ref", "2", BytevectorU8RefImpl)
+	MakePrimitiveFunction("u8vector-set!", "3", BytevectorU8SetImpl)
+	MakePrimitiveFunction("u8vector->list", "1|2|3", BytevectorToListImpl)
+	MakePrimitiveFunction("list->u8vector", "1", ListToBytevectorImpl)
+
+	RegisterObjectEquality("Bytevector",
+		func(a unsafe.Pointer, b unsafe.Pointer) bool {
+			return bytes.Equal(*(*[]byte)(a), *(*[]byte)(b))
+		},
+		func(o unsafe.Pointer) uint64 {
+			return hashBytes('u', *(*[]byte)(o))
+		})
+}
+
+func BytevectorWithValue(bytes []byte) *Data {
+	return ObjectWithTypeAndValue("Bytevector", unsafe.Pointer(&bytes))
+}
+
+func BytevectorP(d *Data) bool {
+	return ObjectP(d) && ObjectType(d) == "Bytevector"
+}
+
+// BytevectorValue returns the bytes of a bytevector, which are shared with it
+func BytevectorValue(d *Data) []byte {
+	if !BytevectorP(d) {
+		return nil
+	}
+	return *(*[]byte)(ObjectValue(d))
+}
+
+func bytevectorArg(name string, d *Data, env *SymbolTableFrame) (bytes []byte, err error) {
+	if !BytevectorP(d) {
+		err = ProcessTypeError(fmt.Sprintf("%s requires a bytevector but was given %s.", name, String(d)), env)
+		return
+	}
+	return BytevectorValue(d), nil
+}
+
+// byteSlice returns the bytes of a bytearray or bytevector, for primitives that read
+// either
+func byteSlice(d *Data) (contents []byte, ok bool) {
+	if BytevectorP(d) {
+		return BytevectorValue(d), true
+	}
+	if ObjectP(d) && ObjectType(d) == "[]byte" {
+		return *(*[]byte)(ObjectValue(d)), true
+	}
+	return nil, false
+}
+
+func bytevectorString(contents []byte) string {
+	var buffer bytes.Buffer
+	buffer.WriteString("#u8(")
+	for i, b := range contents {
+		if i > 0 {
+			buffer.WriteByte(' ')
+		}
+		fmt.Fprintf(&buffer, "%d", b)
+	}
+	buffer.WriteByte(')')
+	return buffer.String()
+}
+
+func byteArg(name string, d *Data, env *SymbolTableFrame) (b byte, err error) {
+	if !IntegerP(d) || IntegerValue(d) < 0 || IntegerValue(d) > 255 {
+		err = ProcessTypeError(fmt.Sprintf("%s requires a byte but was given %s.", name, String(d)), env)
+		return
+	}
+	return byte(IntegerValue(d)), nil
+}
+
+// byteRangeArgs returns the optional start and end indexes in args of a part of something
+// with length elements, defaulting to all of it
+func byteRangeArgs(name string, length int, args *Data, env *SymbolTableFrame) (start int, end int, err error) {
+	start, end = 0, length
+	if NotNilP(args) {
+		if !IntegerP(Car(args)) {
+			err = ProcessTypeError(fmt.Sprintf("%s requires an integer start but was given %s.", name, String(Car(args))), env)
+			return
+		}
+		start = int(IntegerValue(Car(args)))
+	}
+	if NotNilP(Cdr(args)) {
+		if !IntegerP(Cadr(args)) {
+			err = ProcessTypeError(fmt.Sprintf("%s requires an integer end but was given %s.", name, String(Cadr(args))), env)
+			return
+		}
+		end = int(IntegerValue(Cadr(args)))
+	}
+	if start < 0 || end > length || start > end {
+		err = ProcessIndexError(fmt.Sprintf("%s range %d to %d is out of range for length %d.", name, start, end, length), env)
+	}
+	return
+}
+
+func bytesFrom(name string, elements []*Data, env *SymbolTableFrame) (bytes []byte, err error) {
+	bytes = make([]byte, len(elements))
+	for i, element := range elements {
+		if bytes[i], err = byteArg(name, element, env); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func MakeBytevectorImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	k := Car(args)
+	if !IntegerP(k) || IntegerValue(k) < 0 {
+		err = ProcessTypeError(fmt.Sprintf("make-bytevector requires a non-negative integer size but was given %s.", String(k)), env)
+		return
+	}
+	if IntegerValue(k) > maxArrayElements {
+		err = ProcessError(fmt.Sprintf("make-bytevector can't make a bytevector of %d bytes, the most is %d.", IntegerValue(k), maxArrayElements), env)
+		return
+	}
+	var fill byte
+	if Length(args) == 2 {
+		if fill, err = byteArg("make-bytevector", Cadr(args), env); err != nil {
+			return
+		}
+	}
+
+	bytes := make([]byte, IntegerValue(k))
+	for i := range bytes {
+		bytes[i] = fill
+	}
+	return BytevectorWithValue(bytes), nil
+}
+
+func IsBytevectorImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(BytevectorP(Car(args))), nil
+}
+
+func BytevectorImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	bytes, err := bytesFrom("bytevector", ToArray(args), env)
+	if err != nil {
+		return
+	}
+	return BytevectorWithValue(bytes), nil
+}
+
+func BytevectorLengthImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	bytes, err := bytevectorArg("bytevector-length", Car(args), env)
+	if err != nil {
+		return
+	}
+	return IntegerWithValue(int64(len(bytes))), nil
+}
+
+func bytevectorIndex(name string, bytes []byte, indexObject *Data, env *SymbolTableFrame) (index int, err error) {
+	if !IntegerP(indexObject) {
+		err = ProcessTypeError(fmt.Sprintf("%s requires an integer index but was given %s.", name, String(indexObject)), env)
+		return
+	}
+	index = int(IntegerValue(indexObject))
+	if index < 0 || index >= len(bytes) {
+		err = ProcessIndexError(fmt.Sprintf("%s index was out of range. Was %d but bytevector has length of %d.", name, index, len(bytes)), env)
+	}
+	return
+}
+
+func BytevectorU8RefImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	bytes, err := bytevectorArg("bytevector-u8-ref", Car(args), env)
+	if err != nil {
+		return
+	}
+	index, err := bytevectorIndex("bytevector-u8-ref", bytes, Cadr(args), env)
+	if err != nil {
+		return
+	}
+	return IntegerWithValue(int64(bytes[index])), nil
+}
+
+func BytevectorU8SetImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	bytes, err := bytevectorArg("bytevector-u8-set!", Car(args), env)
+	if err != nil {
+		return
+	}
+	index, err := bytevectorIndex("bytevector-u8-set!", bytes, Cadr(args), env)
+	if err != nil {
+		return
+	}
+	if bytes[index], err = byteArg("bytevector-u8-set!", Caddr(args), env); err != nil {
+		return
+	}
+	return Car(args), nil
+}
+
+func BytevectorCopyImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	bytes, err := bytevectorArg("bytevector-copy", Car(args), env)
+	if err != nil {
+		return
+	}
+	start, end, err := byteRangeArgs("bytevector-copy", len(bytes), Cdr(args), env)
+	if err != nil {
+		return
+	}
+	return BytevectorWithValue(append([]byte{}, bytes[start:end]...)), nil
+}
+
+// BytevectorCopyBangImpl handles (bytevector-copy! to at from [start [end]]), copying the
+// bytes of from into to starting at index at
+func BytevectorCopyBangImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	to, err := bytevectorArg("bytevector-copy!", Car(args), env)
+	if err != nil {
+		return
+	}
+	if !IntegerP(Cadr(args)) {
+		err = ProcessTypeError(fmt.Sprintf("bytevector-copy! requires an integer index but was given %s.", String(Cadr(args))), env)
+		return
+	}
+	at := int(IntegerValue(Cadr(args)))
+	from, err := bytevectorArg("bytevector-copy!", Caddr(args), env)
+	if err != nil {
+		return
+	}
+	start, end, err := byteRangeArgs("bytevector-copy!", len(from), Cdddr(args), env)
+	if err != nil {
+		return
+	}
+	if at < 0 || at+end-start > len(to) {
+		err = ProcessIndexError(fmt.Sprintf("bytevector-copy! can't copy %d bytes to index %d of a bytevector of length %d.", end-start, at, len(to)), env)
+		return
+	}
+	copy(to[at:], from[start:end])
+	return Car(args), nil
+}
+
+func BytevectorAppendImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	total := 0
+	parts := make([][]byte, 0, Length(args))
+	for c := args; NotNilP(c); c = Cdr(c) {
+		var bytes []byte
+		if bytes, err = bytevectorArg("bytevector-append", Car(c), env); err != nil {
+			return
+		}
+		parts = append(parts, bytes)
+		total += len(bytes)
+	}
+
+	appended := make([]byte, 0, total)
+	for _, part := range parts {
+		appended = append(appended, part...)
+	}
+	return BytevectorWithValue(appended), nil
+}
+
+// integersFrom returns the bytes of a bytevector between the range in args as integers
+func integersFrom(name string, args *Data, env *SymbolTableFrame) (elements []*Data, err error) {
+	bytes, err := bytevectorArg(name, Car(args), env)
+	if err != nil {
+		return
+	}
+	start, end, err := byteRangeArgs(name, len(bytes), Cdr(args), env)
+	if err != nil {
+		return
+	}
+	elements = make([]*Data, 0, end-start)
+	for _, b := range bytes[start:end] {
+		elements = append(elements, IntegerWithValue(int64(b)))
+	}
+	return
+}
+
+func BytevectorToListImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	elements, err := integersFrom("bytevector->list", args, env)
+	if err != nil {
+		return
+	}
+	return ArrayToList(elements), nil
+}
+
+func ListToBytevectorImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if !ListP(Car(args)) {
+		err = ProcessTypeError(fmt.Sprintf("list->bytevector requires a list but was given %s.", String(Car(args))), env)
+		return
+	}
+	bytes, err := bytesFrom("list->bytevector", ToArray(Car(args)), env)
+	if err != nil {
+		return
+	}
+	return BytevectorWithValue(bytes), nil
+}
+
+func BytevectorToVectorImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	elements, err := integersFrom("bytevector->vector", args, env)
+	if err != nil {
+		return
+	}
+	return VectorWithValue(elements), nil
+}
+
+func VectorToBytevectorImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	v := Car(args)
+	if !VectorP(v) {
+		err = ProcessTypeError(fmt.Sprintf("vector->bytevector requires a vector but was given %s.", String(v)), env)
+		return
+	}
+	elements := VectorValue(v)
+	start, end, err := byteRangeArgs("vector->bytevector", len(elements), Cdr(args), env)
+	if err != nil {
+		return
+	}
+	bytes, err := bytesFrom("vector->bytevector", elements[start:end], env)
+	if err != nil {
+		return
+	}
+	return BytevectorWithValue(bytes), nil
+}
+
+func Utf8ToStringImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	bytes, err := bytevectorArg("utf8->string", Car(args), env)
+	if err != nil {
+		return
+	}
+	start, end, err := byteRangeArgs("utf8->string", len(bytes), Cdr(args), env)
+	if err != nil {
+		return
+	}
+	return StringWithValue(string(bytes[start:end])), nil
+}
+
+func StringToUtf8Impl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if !StringP(Car(args)) {
+		err = ProcessTypeError(fmt.Sprintf("string->utf8 requires a string but was given %s.", String(Car(args))), env)
+		return
+	}
+	return BytevectorWithValue([]byte(StringValue(Car(args)))), nil
+}
+
+// BytevectorToBytearrayImpl copies a bytevector into a bytearray, e.g. to use it with
+// bit-match or write-bytes
+func BytevectorToBytearrayImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	bytes, err := bytevectorArg("bytevector->bytearray", Car(args), env)
+	if err != nil {
+		return
+	}
+	copied := append([]byte{}, bytes...)
+	return ObjectWithTypeAndValue("[]byte", unsafe.Pointer(&copied)), nil
+}
+
+func BytearrayToBytevectorImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	bytearray := Car(args)
+	if !ObjectP(bytearray) || ObjectType(bytearray) != "[]byte" {
+		err = ProcessTypeError(fmt.Sprintf("bytearray->bytevector requires a bytearray but was given %s.", String(bytearray)), env)
+		return
+	}
+	return BytevectorWithValue(append([]byte{}, *(*[]byte)(ObjectValue(bytearray))...)), nil
+}
@@ -29,7 +29,7 @@ func MakeChannelImpl(args *Data, env *SymbolTableFrame) (result *Data, err error
 	if Length(args) == 1 {
 		lengthObj := Car(args)
 		if !IntegerP(lengthObj) {
-			err = ProcessError(fmt.Sprintf("make-channel expects an Integer as its second argument but received %s.", TypeName(TypeOf(lengthObj))), env)
+			err = ProcessTypeError(fmt.Sprintf("make-channel expects an Integer as its second argument but received %s.", TypeName(TypeOf(lengthObj))), env)
 			return
 		}
 
@@ -58,7 +58,7 @@ func MakeChannelImpl(args *Data, env *SymbolTableFrame) (result *Data, err error
 func ChannelWriteImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	channelObj := Car(args)
 	if !ObjectP(channelObj) || ObjectType(channelObj) != "Channel" {
-		err = ProcessError(fmt.Sprintf("channel<- expects an Channel object but received %s.", ObjectType(channelObj)), env)
+		err = ProcessTypeError(fmt.Sprintf("channel<- expects an Channel object but received %s.", ObjectType(channelObj)), env)
 		return
 	}
 
@@ -84,7 +84,7 @@ func ChannelWriteImpl(args *Data, env *SymbolTableFrame) (result *Data, err erro
 func ChannelReadImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	channelObj := Car(args)
 	if !ObjectP(channelObj) || ObjectType(channelObj) != "Channel" {
-		err = ProcessError(fmt.Sprintf("<-channel expects an Channel object but received %s.", ObjectType(channelObj)), env)
+		err = ProcessTypeError(fmt.Sprintf("<-channel expects an Channel object but received %s.", ObjectType(channelObj)), env)
 		return
 	}
 
@@ -98,7 +98,7 @@ func ChannelReadImpl(args *Data, env *SymbolTableFrame) (result *Data, err error
 func ChannelTryWriteImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	channelObj := Car(args)
 	if !ObjectP(channelObj) || ObjectType(channelObj) != "Channel" {
-		err = ProcessError(fmt.Sprintf("channel-try-write expects an Channel object but received %s.", ObjectType(channelObj)), env)
+		err = ProcessTypeError(fmt.Sprintf("channel-try-write expects an Channel object but received %s.", ObjectType(channelObj)), env)
 		return
 	}
 
@@ -129,7 +129,7 @@ func ChannelTryWriteImpl(args *Data, env *SymbolTableFrame) (result *Data, err e
 func ChannelTryReadImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	channelObj := Car(args)
 	if !ObjectP(channelObj) || ObjectType(channelObj) != "Channel" {
-		err = ProcessError(fmt.Sprintf("<-channel expects an Channel object but received %s.", ObjectType(channelObj)), env)
+		err = ProcessTypeError(fmt.Sprintf("<-channel expects an Channel object but received %s.", ObjectType(channelObj)), env)
 		return
 	}
 
@@ -151,7 +151,7 @@ func ChannelTryReadImpl(args *Data, env *SymbolTableFrame) (result *Data, err er
 func CloseChannelImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	channelObj := Car(args)
 	if !ObjectP(channelObj) || ObjectType(channelObj) != "Channel" {
-		err = ProcessError(fmt.Sprintf("<-channel expects an Channel object but received %s.", ObjectType(channelObj)), env)
+		err = ProcessTypeError(fmt.Sprintf("<-channel expects an Channel object but received %s.", ObjectType(channelObj)), env)
 		return
 	}
 
@@ -0,0 +1,72 @@
+// Copyright 2014 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the character primitive functions.
+
+package golisp
+
+import (
+	"fmt"
+)
+
+func RegisterCharacterPrimitives() {
+	MakePrimitiveFunction("char->integer", "1", CharToIntegerImpl)
+	MakePrimitiveFunction("integer->char", "1", IntegerToCharImpl)
+	MakePrimitiveFunction("char=?", "2", CharEqualImpl)
+	MakePrimitiveFunction("char<?", "2", CharLessThanImpl)
+	MakePrimitiveFunction("char>?", "2", CharGreaterThanImpl)
+	MakePrimitiveFunction("char<=?", "2", CharLessThanEqualImpl)
+	MakePrimitiveFunction("char>=?", "2", CharGreaterThanEqualImpl)
+}
+
+func CharToIntegerImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	ch := Car(args)
+	if !CharacterP(ch) {
+		err = ProcessTypeError(fmt.Sprintf("char->integer requires a character but was given %s.", String(ch)), env)
+		return
+	}
+	return IntegerWithValue(int64(CharacterValue(ch))), nil
+}
+
+func IntegerToCharImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	n := Car(args)
+	if !IntegerP(n) {
+		err = ProcessTypeError(fmt.Sprintf("integer->char requires an integer but was given %s.", String(n)), env)
+		return
+	}
+	return CharacterWithValue(rune(IntegerValue(n))), nil
+}
+
+// charCompare checks that both arguments are characters and results in whether holds is
+// true of their code points
+func charCompare(name string, args *Data, env *SymbolTableFrame, holds func(a rune, b rune) bool) (result *Data, err error) {
+	for c := args; NotNilP(c); c = Cdr(c) {
+		if !CharacterP(Car(c)) {
+			err = ProcessTypeError(fmt.Sprintf("%s requires a character but was given %s.", name, String(Car(c))), env)
+			return
+		}
+	}
+	return BooleanWithValue(holds(CharacterValue(Car(args)), CharacterValue(Cadr(args)))), nil
+}
+
+func CharEqualImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return charCompare("char=?", args, env, func(a rune, b rune) bool { return a == b })
+}
+
+func CharLessThanImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return charCompare("char<?", args, env, func(a rune, b rune) bool { return a < b })
+}
+
+func CharGreaterThanImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return charCompare("char>?", args, env, func(a rune, b rune) bool { return a > b })
+}
+
+func CharLessThanEqualImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return charCompare("char<=?", args, env, func(a rune, b rune) bool { return a <= b })
+}
+
+func CharGreaterThanEqualImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return charCompare("char>=?", args, env, func(a rune, b rune) bool { return a >= b })
+}
@@ -0,0 +1,294 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the character set primitive functions, in the style of SRFI-14, and the
+// string scanning functions that use them.
+
+package golisp
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// CharSet is a set of characters, described by a predicate so that sets like all letters
+// don't have to be enumerated
+type CharSet struct {
+	Name     string
+	contains func(rune) bool
+}
+
+func (self *CharSet) Contains(ch rune) bool {
+	return self.contains(ch)
+}
+
+var predefinedCharSets = map[string]func(rune) bool{
+	"letter":       unicode.IsLetter,
+	"digit":        unicode.IsDigit,
+	"letter+digit": func(ch rune) bool { return unicode.IsLetter(ch) || unicode.IsDigit(ch) },
+	"whitespace":   unicode.IsSpace,
+	"upper-case":   unicode.IsUpper,
+	"lower-case":   unicode.IsLower,
+	"punctuation":  unicode.IsPunct,
+	"symbol":       unicode.IsSymbol,
+	"graphic":      unicode.IsGraphic,
+	"hex-digit":    func(ch rune) bool { return strings.ContainsRune("0123456789abcdefABCDEF", ch) },
+	"blank":        func(ch rune) bool { return ch == ' ' || ch == '\t' || unicode.Is(unicode.Zs, ch) },
+	"full":         func(ch rune) bool { return true },
+	"empty":        func(ch rune) bool { return false },
+}
+
+func RegisterCharSetPrimitives() {
+	MakePrimitiveFunction("char-set", "*", CharSetImpl)
+	MakePrimitiveFunction("string->char-set", "1", StringToCharSetImpl)
+	MakePrimitiveFunction("char-set?", "1", IsCharSetImpl)
+	MakePrimitiveFunction("char-set-contains?", "2", CharSetContainsImpl)
+	MakePrimitiveFunction("char-set-union", ">=1", CharSetUnionImpl)
+	MakePrimitiveFunction("char-set-intersection", ">=1", CharSetIntersectionImpl)
+	MakePrimitiveFunction("char-set-difference", ">=1", CharSetDifferenceImpl)
+	MakePrimitiveFunction("char-set-complement", "1", CharSetComplementImpl)
+	MakePrimitiveFunction("string-index", "2|3", StringIndexImpl)
+	MakePrimitiveFunction("string-index-right", "2", StringIndexRightImpl)
+	MakePrimitiveFunction("string-skip", "2|3", StringSkipImpl)
+
+	for name, contains := range predefinedCharSets {
+		Global.BindToProtected(Intern("char-set:"+name), CharSetWithValue(&CharSet{Name: "char-set:" + name, contains: contains}))
+	}
+}
+
+func CharSetWithValue(charSet *CharSet) *Data {
+	return ObjectWithTypeAndValue("CharSet", unsafe.Pointer(charSet))
+}
+
+func CharSetP(d *Data) bool {
+	return ObjectP(d) && ObjectType(d) == "CharSet"
+}
+
+func CharSetValue(d *Data) *CharSet {
+	if !CharSetP(d) {
+		return nil
+	}
+	return (*CharSet)(ObjectValue(d))
+}
+
+func charSetOfRunes(runes map[rune]bool) *CharSet {
+	return &CharSet{contains: func(ch rune) bool { return runes[ch] }}
+}
+
+func charSetArg(name string, d *Data, env *SymbolTableFrame) (result *CharSet, err error) {
+	if !CharSetP(d) {
+		err = ProcessTypeError(fmt.Sprintf("%s requires a char-set but was given %s.", name, String(d)), env)
+		return
+	}
+	return CharSetValue(d), nil
+}
+
+func charSetArgs(name string, args *Data, env *SymbolTableFrame) (result []*CharSet, err error) {
+	for c := args; NotNilP(c); c = Cdr(c) {
+		var charSet *CharSet
+		charSet, err = charSetArg(name, Car(c), env)
+		if err != nil {
+			return
+		}
+		result = append(result, charSet)
+	}
+	return
+}
+
+// charMatcherArg turns d, which can be a character, a char-set, or a predicate on
+// characters, into a function that tests characters against it
+func charMatcherArg(name string, d *Data, env *SymbolTableFrame) (matcher func(rune) (bool, error), err error) {
+	switch {
+	case CharacterP(d):
+		ch := CharacterValue(d)
+		matcher = func(r rune) (bool, error) { return r == ch, nil }
+	case CharSetP(d):
+		charSet := CharSetValue(d)
+		matcher = func(r rune) (bool, error) { return charSet.Contains(r), nil }
+	case FunctionOrPrimitiveP(d):
+		matcher = func(r rune) (bool, error) {
+			matches, err := ApplyWithoutEval(d, InternalMakeList(CharacterWithValue(r)), env)
+			return BooleanValue(matches), err
+		}
+	default:
+		err = ProcessTypeError(fmt.Sprintf("%s requires a character, char-set, or predicate but was given %s.", name, String(d)), env)
+	}
+	return
+}
+
+func CharSetImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	runes := make(map[rune]bool)
+	for c := args; NotNilP(c); c = Cdr(c) {
+		switch {
+		case CharacterP(Car(c)):
+			runes[CharacterValue(Car(c))] = true
+		case StringP(Car(c)):
+			for _, ch := range StringValue(Car(c)) {
+				runes[ch] = true
+			}
+		default:
+			err = ProcessTypeError(fmt.Sprintf("char-set requires characters or strings but was given %s.", String(Car(c))), env)
+			return
+		}
+	}
+	return CharSetWithValue(charSetOfRunes(runes)), nil
+}
+
+func StringToCharSetImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if !StringP(Car(args)) {
+		err = ProcessTypeError(fmt.Sprintf("string->char-set requires a string but was given %s.", String(Car(args))), env)
+		return
+	}
+	runes := make(map[rune]bool)
+	for _, ch := range StringValue(Car(args)) {
+		runes[ch] = true
+	}
+	return CharSetWithValue(charSetOfRunes(runes)), nil
+}
+
+func IsCharSetImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(CharSetP(Car(args))), nil
+}
+
+func CharSetContainsImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	charSet, err := charSetArg("char-set-contains?", Car(args), env)
+	if err != nil {
+		return
+	}
+	if !CharacterP(Cadr(args)) {
+		err = ProcessTypeError(fmt.Sprintf("char-set-contains? requires a character but was given %s.", String(Cadr(args))), env)
+		return
+	}
+	return BooleanWithValue(charSet.Contains(CharacterValue(Cadr(args)))), nil
+}
+
+func CharSetUnionImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	sets, err := charSetArgs("char-set-union", args, env)
+	if err != nil {
+		return
+	}
+	return CharSetWithValue(&CharSet{contains: func(ch rune) bool {
+		for _, set := range sets {
+			if set.Contains(ch) {
+				return true
+			}
+		}
+		return false
+	}}), nil
+}
+
+func CharSetIntersectionImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	sets, err := charSetArgs("char-set-intersection", args, env)
+	if err != nil {
+		return
+	}
+	return CharSetWithValue(&CharSet{contains: func(ch rune) bool {
+		for _, set := range sets {
+			if !set.Contains(ch) {
+				return false
+			}
+		}
+		return true
+	}}), nil
+}
+
+// CharSetDifferenceImpl makes the set of characters in the first set and none of the others
+func CharSetDifferenceImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	sets, err := charSetArgs("char-set-difference", args, env)
+	if err != nil {
+		return
+	}
+	return CharSetWithValue(&CharSet{contains: func(ch rune) bool {
+		for _, set := range sets[1:] {
+			if set.Contains(ch) {
+				return false
+			}
+		}
+		return sets[0].Contains(ch)
+	}}), nil
+}
+
+func CharSetComplementImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	charSet, err := charSetArg("char-set-complement", Car(args), env)
+	if err != nil {
+		return
+	}
+	return CharSetWithValue(&CharSet{contains: func(ch rune) bool { return !charSet.Contains(ch) }}), nil
+}
+
+// scanString returns the index of the first character of the string in args, from the
+// optional start index on, for which the matcher in args answers wanted, or #f if none does
+func scanString(name string, wanted bool, args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if !StringP(Car(args)) {
+		err = ProcessTypeError(fmt.Sprintf("%s requires a string but was given %s.", name, String(Car(args))), env)
+		return
+	}
+	s := StringValue(Car(args))
+	matcher, err := charMatcherArg(name, Cadr(args), env)
+	if err != nil {
+		return
+	}
+	start := 0
+	if Length(args) == 3 {
+		if !IntegerP(Caddr(args)) {
+			err = ProcessTypeError(fmt.Sprintf("%s requires an integer start but was given %s.", name, String(Caddr(args))), env)
+			return
+		}
+		start = int(IntegerValue(Caddr(args)))
+		if start < 0 || start > len(s) {
+			err = ProcessIndexError(fmt.Sprintf("%s requires start to be within the string.", name), env)
+			return
+		}
+	}
+	for i, ch := range s[start:] {
+		var matches bool
+		matches, err = matcher(ch)
+		if err != nil {
+			return
+		}
+		if matches == wanted {
+			return IntegerWithValue(int64(start + i)), nil
+		}
+	}
+	return LispFalse, nil
+}
+
+func StringIndexImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return scanString("string-index", true, args, env)
+}
+
+// StringSkipImpl finds the first character that doesn't match, e.g. the end of leading
+// whitespace
+func StringSkipImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return scanString("string-skip", false, args, env)
+}
+
+func StringIndexRightImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if !StringP(Car(args)) {
+		err = ProcessTypeError(fmt.Sprintf("string-index-right requires a string but was given %s.", String(Car(args))), env)
+		return
+	}
+	s := StringValue(Car(args))
+	matcher, err := charMatcherArg("string-index-right", Cadr(args), env)
+	if err != nil {
+		return
+	}
+	for end := len(s); end > 0; {
+		ch, size := utf8.DecodeLastRuneInString(s[:end])
+		end -= size
+		var matches bool
+		matches, err = matcher(ch)
+		if err != nil {
+			return
+		}
+		if matches {
+			return IntegerWithValue(int64(end)), nil
+		}
+	}
+	return LispFalse, nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the parser combinator primitive functions.
+
+package golisp
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// combinatorBox holds a Combinator so it can be stored in a boxed object
+type combinatorBox struct {
+	parser Combinator
+}
+
+func RegisterCombinatorPrimitives() {
+	MakePrimitiveFunction("p-token", "1", PTokenImpl)
+	MakePrimitiveFunction("p-seq", "*", PSeqImpl)
+	MakePrimitiveFunction("p-alt", ">=1", PAltImpl)
+	MakePrimitiveFunction("p-many", "1", PManyImpl)
+	MakePrimitiveFunction("p-many1", "1", PMany1Impl)
+	MakePrimitiveFunction("p-optional", "1|2", POptionalImpl)
+	MakePrimitiveFunction("p-map", "2", PMapImpl)
+	MakePrimitiveFunction("p-lazy", "1", PLazyImpl)
+	MakePrimitiveFunction("p-parse", "2", PParseImpl)
+	MakePrimitiveFunction("parser?", "1", IsCombinatorImpl)
+}
+
+func CombinatorWithValue(parser Combinator) *Data {
+	return ObjectWithTypeAndValue("Parser", unsafe.Pointer(&combinatorBox{parser}))
+}
+
+func CombinatorP(d *Data) bool {
+	return ObjectP(d) && ObjectType(d) == "Parser"
+}
+
+func CombinatorValue(d *Data) Combinator {
+	if !CombinatorP(d) {
+		return nil
+	}
+	return (*combinatorBox)(ObjectValue(d)).parser
+}
+
+func combinatorArg(name string, d *Data, env *SymbolTableFrame) (result Combinator, err error) {
+	if !CombinatorP(d) {
+		err = ProcessTypeError(fmt.Sprintf("%s requires a parser but was given %s.", name, String(d)), env)
+		return
+	}
+	return CombinatorValue(d), nil
+}
+
+func combinatorArgs(name string, args *Data, env *SymbolTableFrame) (result []Combinator, err error) {
+	result = make([]Combinator, 0, Length(args))
+	for c := args; NotNilP(c); c = Cdr(c) {
+		var parser Combinator
+		parser, err = combinatorArg(name, Car(c), env)
+		if err != nil {
+			return
+		}
+		result = append(result, parser)
+	}
+	return
+}
+
+// PTokenImpl makes a parser that matches one token by its type, if given a symbol, or its
+// text, if given a string, and results in the token's value
+func PTokenImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	match := Car(args)
+	if !SymbolP(match) && !StringP(match) {
+		err = ProcessTypeError(fmt.Sprintf("p-token requires a symbol or string but was given %s.", String(match)), env)
+		return
+	}
+	return CombinatorWithValue(&tokenParser{match: match}), nil
+}
+
+// PSeqImpl makes a parser that matches each of its parsers in turn and results in the list
+// of their results
+func PSeqImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	parsers, err := combinatorArgs("p-seq", args, env)
+	if err != nil {
+		return
+	}
+	return CombinatorWithValue(&seqParser{parsers: parsers}), nil
+}
+
+// PAltImpl makes a parser that results in the result of the first of its parsers that
+// matches
+func PAltImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	parsers, err := combinatorArgs("p-alt", args, env)
+	if err != nil {
+		return
+	}
+	return CombinatorWithValue(&altParser{parsers: parsers}), nil
+}
+
+func PManyImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	parser, err := combinatorArg("p-many", Car(args), env)
+	if err != nil {
+		return
+	}
+	return CombinatorWithValue(&manyParser{parser: parser}), nil
+}
+
+func PMany1Impl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	parser, err := combinatorArg("p-many1", Car(args), env)
+	if err != nil {
+		return
+	}
+	return CombinatorWithValue(&manyParser{parser: parser, min: 1}), nil
+}
+
+// POptionalImpl makes a parser that matches its parser or nothing, resulting in the given
+// default (or nil) in the latter case
+func POptionalImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	parser, err := combinatorArg("p-optional", Car(args), env)
+	if err != nil {
+		return
+	}
+	return CombinatorWithValue(&optionalParser{parser: parser, otherwise: Cadr(args)}), nil
+}
+
+func PMapImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	parser, err := combinatorArg("p-map", Car(args), env)
+	if err != nil {
+		return
+	}
+	if !FunctionOrPrimitiveP(Cadr(args)) {
+		err = ProcessTypeError(fmt.Sprintf("p-map requires a function but was given %s.", String(Cadr(args))), env)
+		return
+	}
+	return CombinatorWithValue(&mapParser{parser: parser, function: Cadr(args)}), nil
+}
+
+// PLazyImpl makes a parser from a function of no arguments that returns the real parser,
+// for recursive grammars
+func PLazyImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if !FunctionOrPrimitiveP(Car(args)) {
+		err = ProcessTypeError(fmt.Sprintf("p-lazy requires a function but was given %s.", String(Car(args))), env)
+		return
+	}
+	return CombinatorWithValue(&lazyParser{thunk: Car(args)}), nil
+}
+
+// PParseImpl parses a list or vector of tokens with a parser, which has to consume all of
+// them
+func PParseImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	parser, err := combinatorArg("p-parse", Car(args), env)
+	if err != nil {
+		return
+	}
+	var tokens []*Data
+	switch {
+	case VectorP(Cadr(args)):
+		tokens = VectorValue(Cadr(args))
+	case ListP(Cadr(args)):
+		tokens = ToArray(Cadr(args))
+	default:
+		err = ProcessTypeError(fmt.Sprintf("p-parse requires a list or vector of tokens but was given %s.", String(Cadr(args))), env)
+		return
+	}
+	return ParseTokens(parser, tokens, env)
+}
+
+func IsCombinatorImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(CombinatorP(Car(args))), nil
+}
@@ -26,7 +26,7 @@ type Process struct {
 	Restart       chan empty
 	ReturnValue   chan *Data
 	Joined        int32
-	ScheduleTimer *time.Timer
+	ScheduleTimer ClockTimer
 }
 
 func RegisterConcurrencyPrimitives() {
@@ -50,7 +50,7 @@ func ForkImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	f := Car(args)
 
 	if !FunctionP(f) {
-		err = ProcessError(fmt.Sprintf("fork expected a function, but received %v.", f), env)
+		err = ProcessTypeError(fmt.Sprintf("fork expected a function, but received %v.", f), env)
 		return
 	}
 
@@ -59,11 +59,11 @@ func ForkImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 
 	if function.VarArgs {
 		if argsCount < function.RequiredArgCount {
-			return nil, ProcessError(fmt.Sprintf("fork expected a function with arity of at most %d, but it was %d.", argsCount, function.RequiredArgCount), env)
+			return nil, ProcessErrorWithCategory(ArityError, fmt.Sprintf("fork expected a function with arity of at most %d, but it was %d.", argsCount, function.RequiredArgCount), env)
 		}
 	} else {
 		if argsCount != function.RequiredArgCount {
-			return nil, ProcessError(fmt.Sprintf("fork expected a function with arity of %d, but it was %d.", argsCount, function.RequiredArgCount), env)
+			return nil, ProcessErrorWithCategory(ArityError, fmt.Sprintf("fork expected a function with arity of %d, but it was %d.", argsCount, function.RequiredArgCount), env)
 		}
 	}
 
@@ -88,7 +88,7 @@ func ForkImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 			var forkedErr error
 			returnValue, forkedErr = function.ApplyWithoutEval(Cons(procObj, Cdr(args)), env)
 			if forkedErr != nil {
-				fmt.Println(forkedErr)
+				fmt.Fprintln(ErrorWriter(), forkedErr)
 			}
 		}, "fork")
 	}()
@@ -100,7 +100,7 @@ func ProcSleepImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 	procObj := Car(args)
 
 	if !ObjectP(procObj) || ObjectType(procObj) != "Process" {
-		err = ProcessError(fmt.Sprintf("proc-sleep expects a Process object expected but received %s.", ObjectType(procObj)), env)
+		err = ProcessTypeError(fmt.Sprintf("proc-sleep expects a Process object expected but received %s.", ObjectType(procObj)), env)
 		return
 	}
 
@@ -108,15 +108,17 @@ func ProcSleepImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 
 	millis := Cadr(args)
 	if !IntegerP(millis) {
-		err = ProcessError(fmt.Sprintf("proc-sleep expected an integer as a delay, but received %v.", millis), env)
+		err = ProcessTypeError(fmt.Sprintf("proc-sleep expected an integer as a delay, but received %v.", millis), env)
 		return
 	}
 
 	woken := false
+	timer := CurrentClock().NewTimer(time.Duration(IntegerValue(millis)) * time.Millisecond)
 	select {
 	case <-proc.Wake:
 		woken = true
-	case <-time.After(time.Duration(IntegerValue(millis)) * time.Millisecond):
+		timer.Stop()
+	case <-timer.C():
 	}
 
 	return BooleanWithValue(woken), nil
@@ -126,7 +128,7 @@ func WakeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	procObj := Car(args)
 
 	if !ObjectP(procObj) || ObjectType(procObj) != "Process" {
-		err = ProcessError(fmt.Sprintf("wake expects a Process object expected but received %s.", ObjectType(procObj)), env)
+		err = ProcessTypeError(fmt.Sprintf("wake expects a Process object expected but received %s.", ObjectType(procObj)), env)
 		return
 	}
 
@@ -139,15 +141,19 @@ func WakeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 }
 
 func ScheduleImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if NakedP(Car(args)) && StringValue(Car(args)) == "every:" {
+		return ScheduleEveryImpl(Cdr(args), env)
+	}
+
 	millis := Car(args)
 	if !IntegerP(millis) {
-		err = ProcessError(fmt.Sprintf("schedule expected an integer as a delay, but received %v.", millis), env)
+		err = ProcessTypeError(fmt.Sprintf("schedule expected an integer as a delay, but received %v.", millis), env)
 		return
 	}
 	f := Cadr(args)
 
 	if !FunctionP(f) {
-		err = ProcessError(fmt.Sprintf("schedule expected a function, but received %v.", f), env)
+		err = ProcessTypeError(fmt.Sprintf("schedule expected a function, but received %v.", f), env)
 		return
 	}
 
@@ -156,11 +162,11 @@ func ScheduleImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 
 	if function.VarArgs {
 		if argsCount < function.RequiredArgCount {
-			return nil, ProcessError(fmt.Sprintf("schedule expected a function with arity of at most %d, but it was %d.", argsCount, function.RequiredArgCount), env)
+			return nil, ProcessErrorWithCategory(ArityError, fmt.Sprintf("schedule expected a function with arity of at most %d, but it was %d.", argsCount, function.RequiredArgCount), env)
 		}
 	} else {
 		if argsCount != function.RequiredArgCount {
-			return nil, ProcessError(fmt.Sprintf("schedule expected a function with arity of %d, but it was %d.", argsCount, function.RequiredArgCount), env)
+			return nil, ProcessErrorWithCategory(ArityError, fmt.Sprintf("schedule expected a function with arity of %d, but it was %d.", argsCount, function.RequiredArgCount), env)
 		}
 	}
 
@@ -171,7 +177,7 @@ func ScheduleImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 		Abort:         make(chan empty, 1),
 		Restart:       make(chan empty, 1),
 		ReturnValue:   make(chan *Data, 1),
-		ScheduleTimer: time.NewTimer(time.Duration(IntegerValue(millis)) * time.Millisecond)}
+		ScheduleTimer: CurrentClock().NewTimer(time.Duration(IntegerValue(millis)) * time.Millisecond)}
 	procObj := ObjectWithTypeAndValue("Process", unsafe.Pointer(proc))
 
 	function.ParentProcess = proc
@@ -191,11 +197,11 @@ func ScheduleImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 					break Loop
 				case <-proc.Restart:
 					proc.ScheduleTimer.Reset(time.Duration(IntegerValue(millis)) * time.Millisecond)
-				case <-proc.ScheduleTimer.C:
+				case <-proc.ScheduleTimer.C():
 					var forkedErr error
 					returnValue, forkedErr = function.ApplyWithoutEval(Cons(procObj, Cddr(args)), env)
 					if forkedErr != nil {
-						fmt.Println(forkedErr)
+						fmt.Fprintln(ErrorWriter(), forkedErr)
 					}
 					break Loop
 				}
@@ -211,7 +217,7 @@ func AbandonImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	procObj := Car(args)
 
 	if !ObjectP(procObj) || ObjectType(procObj) != "Process" {
-		err = ProcessError(fmt.Sprintf("adandon expects a Process object expected but received %s.", ObjectType(procObj)), env)
+		err = ProcessTypeError(fmt.Sprintf("adandon expects a Process object expected but received %s.", ObjectType(procObj)), env)
 		return
 	}
 
@@ -232,7 +238,7 @@ func ResetTimeoutImpl(args *Data, env *SymbolTableFrame) (result *Data, err erro
 	procObj := Car(args)
 
 	if !ObjectP(procObj) || ObjectType(procObj) != "Process" {
-		err = ProcessError(fmt.Sprintf("restart expects a Process object expected but received %s.", ObjectType(procObj)), env)
+		err = ProcessTypeError(fmt.Sprintf("restart expects a Process object expected but received %s.", ObjectType(procObj)), env)
 		return
 	}
 
@@ -256,7 +262,7 @@ func JoinImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	procObj := Car(args)
 
 	if !ObjectP(procObj) || ObjectType(procObj) != "Process" {
-		err = ProcessError(fmt.Sprintf("join expects a Process object but received %s.", ObjectType(procObj)), env)
+		err = ProcessTypeError(fmt.Sprintf("join expects a Process object but received %s.", ObjectType(procObj)), env)
 		return
 	}
 	proc := (*Process)(ObjectValue(procObj))
@@ -286,7 +292,7 @@ func AtomicImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if Length(args) == 1 {
 		initObj := Car(args)
 		if !IntegerP(initObj) {
-			err = ProcessError(fmt.Sprintf("atomic expects an Integer as its argument but received %s.", TypeName(TypeOf(initObj))), env)
+			err = ProcessTypeError(fmt.Sprintf("atomic expects an Integer as its argument but received %s.", TypeName(TypeOf(initObj))), env)
 			return
 		}
 		atomicVal = IntegerValue(initObj)
@@ -298,7 +304,7 @@ func AtomicImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func AtomicLoadImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	atomicObj := Car(args)
 	if !ObjectP(atomicObj) || ObjectType(atomicObj) != "Atomic" {
-		err = ProcessError(fmt.Sprintf("atomic-load expects an Atomic object but received %s.", ObjectType(atomicObj)), env)
+		err = ProcessTypeError(fmt.Sprintf("atomic-load expects an Atomic object but received %s.", ObjectType(atomicObj)), env)
 		return
 	}
 
@@ -312,7 +318,7 @@ func AtomicLoadImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 func AtomicStoreImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	atomicObj := Car(args)
 	if !ObjectP(atomicObj) || ObjectType(atomicObj) != "Atomic" {
-		err = ProcessError(fmt.Sprintf("atomic-store! expects an Atomic object but received %s.", ObjectType(atomicObj)), env)
+		err = ProcessTypeError(fmt.Sprintf("atomic-store! expects an Atomic object but received %s.", ObjectType(atomicObj)), env)
 		return
 	}
 
@@ -321,7 +327,7 @@ func AtomicStoreImpl(args *Data, env *SymbolTableFrame) (result *Data, err error
 	newObj := Cadr(args)
 
 	if !IntegerP(newObj) {
-		err = ProcessError(fmt.Sprintf("atomic-store! expects an Integer as its second argument but received %s.", TypeName(TypeOf(newObj))), env)
+		err = ProcessTypeError(fmt.Sprintf("atomic-store! expects an Integer as its second argument but received %s.", TypeName(TypeOf(newObj))), env)
 		return
 	}
 
@@ -335,7 +341,7 @@ func AtomicStoreImpl(args *Data, env *SymbolTableFrame) (result *Data, err error
 func AtomicAddImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	atomicObj := Car(args)
 	if !ObjectP(atomicObj) || ObjectType(atomicObj) != "Atomic" {
-		err = ProcessError(fmt.Sprintf("atomic-add! expects an Atomic object but received %s.", ObjectType(atomicObj)), env)
+		err = ProcessTypeError(fmt.Sprintf("atomic-add! expects an Atomic object but received %s.", ObjectType(atomicObj)), env)
 		return
 	}
 
@@ -344,7 +350,7 @@ func AtomicAddImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 	deltaObj := Cadr(args)
 
 	if !IntegerP(deltaObj) {
-		err = ProcessError(fmt.Sprintf("atomic-add! expects an Integer as its second argument but received %s.", TypeName(TypeOf(deltaObj))), env)
+		err = ProcessTypeError(fmt.Sprintf("atomic-add! expects an Integer as its second argument but received %s.", TypeName(TypeOf(deltaObj))), env)
 		return
 	}
 
@@ -358,7 +364,7 @@ func AtomicAddImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 func AtomicSwapImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	atomicObj := Car(args)
 	if !ObjectP(atomicObj) || ObjectType(atomicObj) != "Atomic" {
-		err = ProcessError(fmt.Sprintf("atomic-swap! expects an Atomic object but received %s.", ObjectType(atomicObj)), env)
+		err = ProcessTypeError(fmt.Sprintf("atomic-swap! expects an Atomic object but received %s.", ObjectType(atomicObj)), env)
 		return
 	}
 
@@ -367,7 +373,7 @@ func AtomicSwapImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 	newObj := Cadr(args)
 
 	if !IntegerP(newObj) {
-		err = ProcessError(fmt.Sprintf("atomic-swap! expects an Integer as its second argument but received %s.", TypeName(TypeOf(newObj))), env)
+		err = ProcessTypeError(fmt.Sprintf("atomic-swap! expects an Integer as its second argument but received %s.", TypeName(TypeOf(newObj))), env)
 		return
 	}
 
@@ -381,7 +387,7 @@ func AtomicSwapImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 func AtomicCompareAndSwapImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	atomicObj := Car(args)
 	if !ObjectP(atomicObj) || ObjectType(atomicObj) != "Atomic" {
-		err = ProcessError(fmt.Sprintf("atomic-compare-and-swap! expects an Atomic object but received %s.", ObjectType(atomicObj)), env)
+		err = ProcessTypeError(fmt.Sprintf("atomic-compare-and-swap! expects an Atomic object but received %s.", ObjectType(atomicObj)), env)
 		return
 	}
 
@@ -390,14 +396,14 @@ func AtomicCompareAndSwapImpl(args *Data, env *SymbolTableFrame) (result *Data,
 	oldObj := Cadr(args)
 
 	if !IntegerP(oldObj) {
-		err = ProcessError(fmt.Sprintf("atomic-compare-and-swap! expects an Integer as its second argument but received %s.", TypeName(TypeOf(oldObj))), env)
+		err = ProcessTypeError(fmt.Sprintf("atomic-compare-and-swap! expects an Integer as its second argument but received %s.", TypeName(TypeOf(oldObj))), env)
 		return
 	}
 
 	newObj := Caddr(args)
 
 	if !IntegerP(newObj) {
-		err = ProcessError(fmt.Sprintf("atomic-compare-and-swap! expects an Integer as its third argument but received %s.", TypeName(TypeOf(newObj))), env)
+		err = ProcessTypeError(fmt.Sprintf("atomic-compare-and-swap! expects an Integer as its third argument but received %s.", TypeName(TypeOf(newObj))), env)
 		return
 	}
 
@@ -416,7 +422,7 @@ func callWithPanicProtection(f func(), prefix string) {
 			stackBuf = stackBuf[:runtime.Stack(stackBuf, false)]
 			stack := strings.Split(string(stackBuf), "\n")
 			for i := 0; i < 7; i++ {
-				fmt.Println(stack[i])
+				fmt.Fprintln(ErrorWriter(), stack[i])
 			}
 		}
 	}()
@@ -0,0 +1,66 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the primitive functions for reading configuration formats.
+
+package golisp
+
+import (
+	"fmt"
+	"io/ioutil"
+)
+
+func RegisterConfigPrimitives() {
+	MakePrimitiveFunction("read-ini", "1", ReadIniImpl)
+	MakePrimitiveFunction("read-toml", "1", ReadTomlImpl)
+	MakePrimitiveFunction("yaml->lisp", "1", YamlToLispImpl)
+	MakePrimitiveFunction("lisp->yaml", "1", LispToYamlImpl)
+}
+
+// configTextArg returns the text of a configuration, given either as a string or as a port
+// to read it all from
+func configTextArg(name string, d *Data, env *SymbolTableFrame) (text string, err error) {
+	switch {
+	case StringP(d):
+		return StringValue(d), nil
+	case PortP(d):
+		bytes, readErr := ioutil.ReadAll(PortValue(d))
+		if readErr != nil {
+			return "", ioError(readErr)
+		}
+		return string(bytes), nil
+	default:
+		err = ProcessTypeError(fmt.Sprintf("%s requires a string or a port but was given %s.", name, String(d)), env)
+		return
+	}
+}
+
+func ReadIniImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	text, err := configTextArg("read-ini", Car(args), env)
+	if err != nil {
+		return
+	}
+	return IniToLisp(text)
+}
+
+func ReadTomlImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	text, err := configTextArg("read-toml", Car(args), env)
+	if err != nil {
+		return
+	}
+	return TomlToLisp(text)
+}
+
+func YamlToLispImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	text, err := configTextArg("yaml->lisp", Car(args), env)
+	if err != nil {
+		return
+	}
+	return YamlToLisp(text)
+}
+
+func LispToYamlImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return StringWithValue(LispToYaml(Car(args))), nil
+}
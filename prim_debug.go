@@ -11,40 +11,220 @@ import (
 	"errors"
 	"fmt"
 	"gopkg.in/fatih/set.v0"
+	"os"
 	"strings"
 )
 
-var DebugCommandPrefix string = ":"
-
 func RegisterDebugPrimitives() {
 	MakePrimitiveFunction("debug-trace", "0|1", DebugTraceImpl)
 	MakePrimitiveFunction("lisp-trace", "0|1", LispTraceImpl)
 	MakePrimitiveFunction("debug-on-entry", "0", DebugOnEntryImpl)
 	MakePrimitiveFunction("remove-debug-on-entry", "1", RemoveDebugOnEntryImpl)
-	MakePrimitiveFunction("dump", "0", DumpSymbolTableImpl)
+	MakePrimitiveFunction("dump", "0|1|2", DumpSymbolTableImpl)
+	MakePrimitiveFunction("current-stack", "0", CurrentStackImpl)
+	MakePrimitiveFunction("stack-frame-ref", "1", StackFrameRefImpl)
 
 	MakeRestrictedPrimitiveFunction("debug", "0", DebugImpl)
 	MakeRestrictedPrimitiveFunction("debug-on-error", "0|1", DebugOnErrorImpl)
 	MakeRestrictedPrimitiveFunction("add-debug-on-entry", "1", AddDebugOnEntryImpl)
+	MakeRestrictedPrimitiveFunction("eval-events", "0|1", EvalEventsImpl)
+	MakeRestrictedPrimitiveFunction("start-recording", "0", StartRecordingImpl)
+	MakeRestrictedPrimitiveFunction("stop-recording", "0|1", StopRecordingImpl)
+	MakeRestrictedPrimitiveFunction("replay-recording", "0|1", ReplayRecordingImpl)
+	MakeRestrictedPrimitiveFunction("debug-server", "0|1", DebugServerImpl)
 }
 
+// DumpSymbolTableImpl handles (dump [depth [width]]), where the limits default to
+// Config.DumpLimits
 func DumpSymbolTableImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	env.Dump()
+	limits := Config.DumpLimits
+	given := []*int{&limits.Depth, &limits.Width}
+	for i, c := 0, args; NotNilP(c); i, c = i+1, Cdr(c) {
+		if !IntegerP(Car(c)) || IntegerValue(Car(c)) < 0 {
+			err = ProcessTypeError(fmt.Sprintf("dump requires non-negative integer limits but was given %s.", String(Car(c))), env)
+			return
+		}
+		*given[i] = int(IntegerValue(Car(c)))
+	}
+	env.DumpWithLimits(limits)
 	return
 }
 
+// stackFrameDescriptor describes a call as a frame with name:, args:, form:, file:, and
+// line: slots, where the last three are nil if they aren't known
+func stackFrameDescriptor(frame StackFrame) *Data {
+	m := FrameMap{}
+	m.Data = FrameMapData{
+		"name:": StringWithValue(frame.Function),
+		"args:": frame.Args,
+		"form:": frame.Form,
+		"file:": nil,
+		"line:": nil,
+	}
+	if frame.Source != nil {
+		if frame.Source.File != "" {
+			m.Data["file:"] = StringWithValue(frame.Source.File)
+		}
+		m.Data["line:"] = IntegerWithValue(int64(frame.Source.Line))
+	}
+	return FrameWithValue(&m)
+}
+
+// CurrentStackImpl handles (current-stack), returning a vector describing the calls to user
+// written functions that led to it, innermost first
+func CurrentStackImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	frames := CallStack(env)
+	descriptors := make([]*Data, len(frames))
+	for i, frame := range frames {
+		descriptors[i] = stackFrameDescriptor(frame)
+	}
+	return VectorWithValue(descriptors), nil
+}
+
+func StackFrameRefImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	n := Car(args)
+	if !IntegerP(n) {
+		err = ProcessTypeError(fmt.Sprintf("stack-frame-ref expects an integer, but received %s.", String(n)), env)
+		return
+	}
+	frames := CallStack(env)
+	if IntegerValue(n) < 0 || IntegerValue(n) >= int64(len(frames)) {
+		err = ProcessIndexError(fmt.Sprintf("stack-frame-ref: there are %d stack frames, so %d is out of range.", len(frames), IntegerValue(n)), env)
+		return
+	}
+	return stackFrameDescriptor(frames[IntegerValue(n)]), nil
+}
+
 func DebugTraceImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if Length(args) == 1 {
-		DebugTrace = BooleanValue(Car(args))
+		Config.DebugTrace = BooleanValue(Car(args))
+		DebugTrace = false
 	}
-	return BooleanWithValue(DebugTrace), nil
+	return BooleanWithValue(debugTraceEnabled()), nil
 }
 
 func LispTraceImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if Length(args) == 1 {
-		LispTrace = BooleanValue(Car(args))
+		Config.LispTrace = BooleanValue(Car(args))
+		LispTrace = false
+	}
+	return BooleanWithValue(lispTraceEnabled()), nil
+}
+
+var evalEventsFile *os.File
+
+// EvalEventsImpl handles (eval-events [destination]), where destination is a filename to
+// write evaluation events to as JSON lines, #t to write them to the trace writer, or #f to
+// stop writing them
+func EvalEventsImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if Length(args) == 1 {
+		SetEvalEventHandler(nil)
+		if evalEventsFile != nil {
+			evalEventsFile.Close()
+			evalEventsFile = nil
+		}
+		destination := Car(args)
+		if StringP(destination) {
+			evalEventsFile, err = os.Create(StringValue(destination))
+			if err != nil {
+				err = ProcessErrorWithCategory(IOError, fmt.Sprintf("eval-events could not open %s: %s", StringValue(destination), err), env)
+				return
+			}
+			SetEvalEventHandler(JSONEvalEventHandler(evalEventsFile))
+		} else if BooleanValue(destination) {
+			SetEvalEventHandler(JSONEvalEventHandler(TraceWriter()))
+		}
+	}
+	return BooleanWithValue(currentEvalEventHandler() != nil), nil
+}
+
+var activeRecorder *Recorder
+var lastRecording *Recording
+
+func StartRecordingImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if activeRecorder != nil {
+		activeRecorder.Stop()
+	}
+	activeRecorder = &Recorder{}
+	activeRecorder.Start()
+	return
+}
+
+// StopRecordingImpl handles (stop-recording [filename]), saving the recording to filename
+// if one is given, and returns the number of steps recorded
+func StopRecordingImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if activeRecorder == nil {
+		err = ProcessError("stop-recording called when not recording", env)
+		return
+	}
+	lastRecording = activeRecorder.Stop()
+	activeRecorder = nil
+
+	if Length(args) == 1 {
+		if !StringP(Car(args)) {
+			err = ProcessTypeError(fmt.Sprintf("stop-recording expects a filename, but received %s.", String(Car(args))), env)
+			return
+		}
+		f, err := os.Create(StringValue(Car(args)))
+		if err != nil {
+			return nil, ProcessErrorWithCategory(IOError, fmt.Sprintf("stop-recording could not open %s: %s", StringValue(Car(args)), err), env)
+		}
+		defer f.Close()
+		if err = lastRecording.Save(f); err != nil {
+			return nil, err
+		}
+	}
+	return IntegerWithValue(int64(len(lastRecording.Steps))), nil
+}
+
+// ReplayRecordingImpl handles (replay-recording [filename]), stepping through the recording
+// in filename, or the last one made if there isn't one
+func ReplayRecordingImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	recording := lastRecording
+	if Length(args) == 1 {
+		if !StringP(Car(args)) {
+			err = ProcessTypeError(fmt.Sprintf("replay-recording expects a filename, but received %s.", String(Car(args))), env)
+			return
+		}
+		f, err := os.Open(StringValue(Car(args)))
+		if err != nil {
+			return nil, ProcessErrorWithCategory(IOError, fmt.Sprintf("replay-recording could not open %s: %s", StringValue(Car(args)), err), env)
+		}
+		defer f.Close()
+		if recording, err = LoadRecording(f); err != nil {
+			return nil, err
+		}
+	}
+	if recording == nil {
+		err = ProcessError("replay-recording needs a filename when nothing has been recorded", env)
+		return
 	}
-	return BooleanWithValue(LispTrace), nil
+	ReplayRepl(recording)
+	return
+}
+
+// DebugServerImpl handles (debug-server [address]), starting the web debugger at address,
+// e.g. "localhost:7070", or stopping it if address is #f.  It returns the URL to open the
+// debugger at, which includes the token it requires, or #f if it isn't running.
+func DebugServerImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if Length(args) == 1 {
+		address := Car(args)
+		if StringP(address) {
+			if _, err = StartDebugServer(StringValue(address)); err != nil {
+				err = ProcessErrorWithCategory(ErrorCategoryOf(err), fmt.Sprintf("debug-server: %s", err), env)
+				return
+			}
+		} else if BooleanP(address) && !BooleanValue(address) {
+			StopDebugServer()
+		} else {
+			err = ProcessTypeError(fmt.Sprintf("debug-server expects an address string or #f, but received %s.", String(address)), env)
+			return
+		}
+	}
+	if debuggerURL := DebugServerURL(); debuggerURL != "" {
+		return StringWithValue(debuggerURL), nil
+	}
+	return LispFalse, nil
 }
 
 func DebugOnEntryImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
@@ -79,53 +259,163 @@ func RemoveDebugOnEntryImpl(args *Data, env *SymbolTableFrame) (result *Data, er
 
 func DebugOnErrorImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if Length(args) == 1 {
-		DebugOnError = BooleanValue(Car(args))
+		Config.DebugOnError = BooleanValue(Car(args))
+		DebugOnError = false
 	}
 
-	return BooleanWithValue(DebugOnError), nil
+	return BooleanWithValue(debugOnErrorEnabled()), nil
 }
 
 func DebugImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	fmt.Printf("Debugger\n")
+	fmt.Fprintf(OutputWriter(), "Debugger\n")
 
 	DebugRepl(env)
 	return
 }
 
-func processState(tokens []string) (ok bool, state bool) {
-	if len(tokens) != 2 {
-		fmt.Printf("Missing on/off.\n")
+// processState interprets an on/off argument to a debugger command
+func processState(token string) (ok bool, state bool) {
+	switch token {
+	case "on":
+		return true, true
+	case "off":
+		return true, false
+	default:
+		fmt.Fprintf(OutputWriter(), "on/off expected.\n")
 		return false, false
-	} else {
-		switch tokens[1] {
-		case "on":
-			return true, true
-		case "off":
-			return true, false
-		default:
-			fmt.Printf("on/off expected.\n")
-			return false, false
-		}
 	}
 }
 
 func funcOrNil(fname string, env *SymbolTableFrame) *Data {
 	f := env.ValueOf(Intern(fname))
 	if f == nil || TypeOf(f) != FunctionType {
-		fmt.Printf("No such function\n")
+		fmt.Fprintf(OutputWriter(), "No such function\n")
 		return nil
 	}
 	return f
 }
 
+// debugContinue leaves the debugger and lets evaluation run
+func debugContinue() {
+	DebugCurrentFrame = nil
+	DebugSingleStep = false
+	DebugEvalInDebugRepl = false
+}
+
+// debugStep leaves the debugger until the next evaluation
+func debugStep() {
+	DebugSingleStep = true
+}
+
+// debugUntilReturn leaves the debugger until env's caller is returned to, and reports
+// whether env has one
+func debugUntilReturn(env *SymbolTableFrame) bool {
+	if env.Parent == nil {
+		return false
+	}
+	DebugCurrentFrame = env
+	return true
+}
+
+// debugEval evaluates code typed into the debugger in env without stopping in the debugger
+func debugEval(code string, env *SymbolTableFrame) (result *Data, err error) {
+	sexpr, err := Parse(code)
+	if err != nil {
+		return
+	}
+	DebugEvalInDebugRepl = true
+	defer func() { DebugEvalInDebugRepl = false }()
+	return Eval(sexpr, env)
+}
+
+// writeLocating writes d as String would, noting where sub starts and ends in the text if it
+// is found within d.  Quoted data is written without being searched.
+func writeLocating(text *strings.Builder, d *Data, sub *Data, start *int, end *int) {
+	if d == sub {
+		*start = text.Len()
+		defer func() { *end = text.Len() }()
+	}
+	if !nonEmptyPairP(d) || (SymbolP(Car(d)) && StringValue(Car(d)) == "quote") {
+		text.WriteString(String(d))
+		return
+	}
+
+	text.WriteString("(")
+	c := d
+	for ; nonEmptyPairP(c); c = Cdr(c) {
+		if c != d {
+			text.WriteString(" ")
+		}
+		writeLocating(text, Car(c), sub, start, end)
+	}
+	if NotNilP(c) {
+		text.WriteString(" . ")
+		writeLocating(text, c, sub, start, end)
+	}
+	text.WriteString(")")
+}
+
+// stepDescription shows sub, which single stepping has stopped before evaluating, as part of
+// the form being evaluated in env, with where that was read and sub underlined, e.g.
+//
+//	test.lsp:3: (+ (car x) 1)
+//	               ^^^^^^^
+func stepDescription(sub *Data, env *SymbolTableFrame) string {
+	form := env.CurrentForm()
+	if form == nil {
+		form = sub
+	}
+	start, end := -1, -1
+	var text strings.Builder
+	writeLocating(&text, form, sub, &start, &end)
+	if start < 0 {
+		form = sub
+		text.Reset()
+		writeLocating(&text, form, sub, &start, &end)
+	}
+
+	source := SourcePositionOf(sub)
+	if source == nil {
+		source = SourcePositionOf(form)
+	}
+	for _, frame := range CallStack(env) {
+		if source != nil {
+			break
+		}
+		source = frame.Source
+	}
+	prefix := ""
+	if source != nil {
+		prefix = fmt.Sprintf("%s: ", source)
+	}
+
+	if form == sub {
+		return fmt.Sprintf("%s%s\n", prefix, text.String())
+	}
+	return fmt.Sprintf("%s%s\n%s%s\n", prefix, text.String(), strings.Repeat(" ", len(prefix)+start), strings.Repeat("^", end-start))
+}
+
+// DebugStep enters the debugger before sub is evaluated in env when single stepping, showing
+// the source being stepped through
+func DebugStep(sub *Data, env *SymbolTableFrame) {
+	if currentDebugServer() == nil {
+		fmt.Fprint(OutputWriter(), stepDescription(sub, env))
+	}
+	DebugRepl(env)
+}
+
 func DebugRepl(env *SymbolTableFrame) {
+	if server := currentDebugServer(); server != nil {
+		server.pause(env)
+		return
+	}
 	env.DumpHeader()
 	prompt := "D> "
 	lastInput := ""
 	for true {
 		defer func() {
 			if x := recover(); x != nil {
-				println("Don't Panic!")
+				fmt.Fprintln(OutputWriter(), "Don't Panic!")
 			}
 		}()
 		input := *ReadLine(&prompt)
@@ -134,117 +424,16 @@ func DebugRepl(env *SymbolTableFrame) {
 				AddHistory(input)
 			}
 			lastInput = input
-			if strings.HasPrefix(input, DebugCommandPrefix) {
-				cmd := strings.TrimPrefix(input, DebugCommandPrefix)
-				tokens := strings.Split(cmd, " ")
-				switch tokens[0] {
-				case "(+":
-					f := funcOrNil(tokens[1], env)
-					if f != nil {
-						DebugOnEntry.Add(FunctionValue(f).Name)
-					}
-				case "(-":
-					f := funcOrNil(tokens[1], env)
-					if f != nil && DebugOnEntry.Has(FunctionValue(f).Name) {
-						DebugOnEntry.Remove(FunctionValue(f).Name)
-					}
-				case "(":
-					for _, f := range DebugOnEntry.List() {
-						fmt.Printf("%s\n", f)
-					}
-				case "?":
-					fmt.Printf("SteelSeries/GoLisp Debugger\n")
-					fmt.Printf("---------------------------\n")
-					fmt.Printf(":(+ func  - debug on entry to func\n")
-					fmt.Printf(":(- func  - don't debug on entry to func\n")
-					fmt.Printf(":(        - show functions marked as debug on entry\n")
-					fmt.Printf(":?        - show this command summary\n")
-					fmt.Printf(":b        - show the environment stack\n")
-					fmt.Printf(":c        - continue, exiting the debugger\n")
-					fmt.Printf(":d        - do a full dump of the environment stack\n")
-					fmt.Printf(":e on/off - Enable/disable debug on error\n")
-					fmt.Printf(":f frame# - do a full dump of a single environment frame\n")
-					//fmt.Printf(":n        - step to next (run to the next evaluation in this frame)\n")
-					fmt.Printf(":q        - quit GoLisp\n")
-					fmt.Printf(":r sexpr  - return from the current evaluation with the specified value\n")
-					fmt.Printf(":s        - single step (run to the next evaluation)\n")
-					fmt.Printf(":t on/off - Enable/disable tracing\n")
-					fmt.Printf(":u        - continue until the enclosing environment frame is returned to\n")
-					fmt.Printf("\n")
-				case "b":
-					env.DumpHeaders()
-					fmt.Printf("\n")
-				case "c":
-					DebugCurrentFrame = nil
-					DebugSingleStep = false
-					DebugEvalInDebugRepl = false
-					return
-				case "d":
-					env.Dump()
-				case "e":
-					ok, state := processState(tokens)
-					if ok {
-						DebugOnError = state
-					}
-				case "f":
-					var fnum int
-					if len(tokens) != 2 {
-						fmt.Printf("Missing frame number.\n")
-					} else {
-						_, err := fmt.Sscanf(tokens[1], "%d", &fnum)
-						if err != nil {
-							fmt.Printf("Bad frame number: '%s'. %s\n", tokens[1], err)
-						} else {
-							env.DumpSingleFrame(fnum)
-						}
-					}
-					//				case "n":
-
-				case "q":
-					QuitImpl(nil, nil)
-				case "r":
-					DebugEvalInDebugRepl = true
-					code, err := Parse(strings.Join(tokens[1:], " "))
-					d, err := Eval(code, env)
-					DebugEvalInDebugRepl = false
-					if err != nil {
-						fmt.Printf("Error in evaluation: %s\n", err)
-					} else {
-						DebugReturnValue = d
-						DebugCurrentFrame = nil
-						DebugSingleStep = false
-						DebugEvalInDebugRepl = false
-						return
-					}
-				case "s":
-					DebugSingleStep = true
+			if strings.HasPrefix(input, debugCommandPrefix()) {
+				if runDebugCommand(strings.TrimPrefix(input, debugCommandPrefix()), env) {
 					return
-				case "t":
-					ok, state := processState(tokens)
-					if ok {
-						LispTrace = state
-					}
-				case "u":
-					if env.Parent != nil {
-						DebugCurrentFrame = env
-						return
-					} else {
-						fmt.Printf("Already at top frame.\n")
-					}
 				}
 			} else {
-				code, err := Parse(input)
+				d, err := debugEval(input, env)
 				if err != nil {
-					fmt.Printf("Error: %s\n", err)
+					fmt.Fprintf(OutputWriter(), "Error in evaluation: %s\n", err)
 				} else {
-					DebugEvalInDebugRepl = true
-					d, err := Eval(code, env)
-					DebugEvalInDebugRepl = false
-					if err != nil {
-						fmt.Printf("Error in evaluation: %s\n", err)
-					} else {
-						fmt.Printf("==> %s\n", String(d))
-					}
+					fmt.Fprintf(OutputWriter(), "==> %s\n", String(d))
 				}
 			}
 		}
@@ -252,11 +441,23 @@ func DebugRepl(env *SymbolTableFrame) {
 }
 
 func ProcessError(errorMessage string, env *SymbolTableFrame) error {
-	if DebugOnError && IsInteractive {
-		fmt.Printf("ERROR!  %s\n", errorMessage)
+	return ProcessErrorWithCategory(GeneralError, errorMessage, env)
+}
+
+func ProcessTypeError(errorMessage string, env *SymbolTableFrame) error {
+	return ProcessErrorWithCategory(TypeError, errorMessage, env)
+}
+
+func ProcessIndexError(errorMessage string, env *SymbolTableFrame) error {
+	return ProcessErrorWithCategory(IndexError, errorMessage, env)
+}
+
+func ProcessErrorWithCategory(category ErrorCategory, errorMessage string, env *SymbolTableFrame) error {
+	if debugOnErrorEnabled() && IsInteractive {
+		fmt.Fprintf(OutputWriter(), "ERROR!  %s\n", errorMessage)
 		DebugRepl(env)
 		return nil
 	} else {
-		return errors.New(errorMessage)
+		return NewLispError(category, errorMessage)
 	}
 }
@@ -0,0 +1,255 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the decimal number primitive functions.
+
+package golisp
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+func RegisterDecimalPrimitives() {
+	MakePrimitiveFunction("decimal", "1|2|3", DecimalImpl)
+	MakePrimitiveFunction("decimal?", "1", IsDecimalImpl)
+	MakePrimitiveFunction("decimal+", "*", DecimalAddImpl)
+	MakePrimitiveFunction("decimal-", ">=1", DecimalSubImpl)
+	MakePrimitiveFunction("decimal*", "*", DecimalMulImpl)
+	MakePrimitiveFunction("decimal/", "3|4", DecimalQuoImpl)
+	MakePrimitiveFunction("decimal-round", "2|3", DecimalRoundImpl)
+	MakePrimitiveFunction("decimal-scale", "1", DecimalScaleImpl)
+	MakePrimitiveFunction("decimal-compare", "2", DecimalCompareImpl)
+	MakePrimitiveFunction("decimal->string", "1", DecimalToStringImpl)
+	MakePrimitiveFunction("decimal->float", "1", DecimalToFloatImpl)
+
+	RegisterObjectEquality("Decimal",
+		func(a unsafe.Pointer, b unsafe.Pointer) bool {
+			return (*Decimal)(a).Cmp((*Decimal)(b)) == 0
+		},
+		func(o unsafe.Pointer) uint64 {
+			return hashBytes('d', []byte((*Decimal)(o).normalized().String()))
+		})
+}
+
+func DecimalWithValue(d *Decimal) *Data {
+	return ObjectWithTypeAndValue("Decimal", unsafe.Pointer(d))
+}
+
+func DecimalP(d *Data) bool {
+	return ObjectP(d) && ObjectType(d) == "Decimal"
+}
+
+func DecimalValue(d *Data) *Decimal {
+	if !DecimalP(d) {
+		return nil
+	}
+	return (*Decimal)(ObjectValue(d))
+}
+
+// decimalArg converts decimals, integers, floats and numeric strings to a decimal
+func decimalArg(name string, d *Data, env *SymbolTableFrame) (result *Decimal, err error) {
+	switch {
+	case DecimalP(d):
+		return DecimalValue(d), nil
+	case IntegerP(d):
+		return DecimalFromInt(IntegerValue(d)), nil
+	case FloatP(d):
+		result, err = DecimalFromFloat(FloatValue(d))
+	case StringP(d):
+		result, err = ParseDecimal(StringValue(d))
+	default:
+		err = ProcessTypeError(fmt.Sprintf("%s expected a number but received %s.", name, String(d)), env)
+		return
+	}
+
+	if err != nil {
+		err = ProcessError(fmt.Sprintf("%s could not convert %s to a decimal.", name, String(d)), env)
+	}
+	return
+}
+
+func scaleArg(name string, d *Data, env *SymbolTableFrame) (scale int, err error) {
+	if !IntegerP(d) || IntegerValue(d) < 0 {
+		err = ProcessTypeError(fmt.Sprintf("%s expected a non-negative integer scale but received %s.", name, String(d)), env)
+		return
+	}
+	if IntegerValue(d) > maxDecimalScale {
+		err = ProcessError(fmt.Sprintf("%s expected a scale of at most %d but received %s.", name, maxDecimalScale, String(d)), env)
+		return
+	}
+	return int(IntegerValue(d)), nil
+}
+
+// roundingArg accepts a rounding mode as a symbol, e.g. 'half-up or half-up:, defaulting to half-even
+func roundingArg(name string, d *Data, env *SymbolTableFrame) (mode RoundingMode, err error) {
+	if NilP(d) {
+		return RoundHalfEven, nil
+	}
+	if !SymbolP(d) {
+		err = ProcessTypeError(fmt.Sprintf("%s expected a rounding mode but received %s.", name, String(d)), env)
+		return
+	}
+
+	mode, found := roundingModes[strings.TrimSuffix(StringValue(d), ":")]
+	if !found {
+		err = ProcessError(fmt.Sprintf("%s received an unknown rounding mode %s.", name, String(d)), env)
+	}
+	return
+}
+
+func DecimalImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	d, err := decimalArg("decimal", Car(args), env)
+	if err != nil {
+		return
+	}
+
+	if Length(args) > 1 {
+		var scale int
+		scale, err = scaleArg("decimal", Cadr(args), env)
+		if err != nil {
+			return
+		}
+		var mode RoundingMode
+		mode, err = roundingArg("decimal", Caddr(args), env)
+		if err != nil {
+			return
+		}
+		d = d.Round(scale, mode)
+	}
+
+	return DecimalWithValue(d), nil
+}
+
+func IsDecimalImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(DecimalP(Car(args))), nil
+}
+
+func DecimalAddImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	sum := DecimalFromInt(0)
+	for c := args; NotNilP(c); c = Cdr(c) {
+		var d *Decimal
+		d, err = decimalArg("decimal+", Car(c), env)
+		if err != nil {
+			return
+		}
+		sum = sum.Add(d)
+	}
+	return DecimalWithValue(sum), nil
+}
+
+func DecimalSubImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	difference, err := decimalArg("decimal-", Car(args), env)
+	if err != nil {
+		return
+	}
+	if NilP(Cdr(args)) {
+		return DecimalWithValue(difference.Neg()), nil
+	}
+
+	for c := Cdr(args); NotNilP(c); c = Cdr(c) {
+		var d *Decimal
+		d, err = decimalArg("decimal-", Car(c), env)
+		if err != nil {
+			return
+		}
+		difference = difference.Sub(d)
+	}
+	return DecimalWithValue(difference), nil
+}
+
+func DecimalMulImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	product := DecimalFromInt(1)
+	for c := args; NotNilP(c); c = Cdr(c) {
+		var d *Decimal
+		d, err = decimalArg("decimal*", Car(c), env)
+		if err != nil {
+			return
+		}
+		product = product.Mul(d)
+	}
+	return DecimalWithValue(product), nil
+}
+
+// DecimalQuoImpl handles (decimal/ dividend divisor scale [rounding]).  Division needs an
+// explicit scale since the exact quotient may not have a finite decimal expansion.
+func DecimalQuoImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	dividend, err := decimalArg("decimal/", Car(args), env)
+	if err != nil {
+		return
+	}
+	divisor, err := decimalArg("decimal/", Cadr(args), env)
+	if err != nil {
+		return
+	}
+	scale, err := scaleArg("decimal/", Caddr(args), env)
+	if err != nil {
+		return
+	}
+	mode, err := roundingArg("decimal/", Car(Cdddr(args)), env)
+	if err != nil {
+		return
+	}
+
+	quotient, err := dividend.Quo(divisor, scale, mode)
+	if err != nil {
+		err = ProcessError(err.Error(), env)
+		return
+	}
+	return DecimalWithValue(quotient), nil
+}
+
+func DecimalRoundImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	d, err := decimalArg("decimal-round", Car(args), env)
+	if err != nil {
+		return
+	}
+	scale, err := scaleArg("decimal-round", Cadr(args), env)
+	if err != nil {
+		return
+	}
+	mode, err := roundingArg("decimal-round", Caddr(args), env)
+	if err != nil {
+		return
+	}
+	return DecimalWithValue(d.Round(scale, mode)), nil
+}
+
+func DecimalScaleImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	d, err := decimalArg("decimal-scale", Car(args), env)
+	if err != nil {
+		return
+	}
+	return IntegerWithValue(int64(d.Scale)), nil
+}
+
+func DecimalCompareImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	a, err := decimalArg("decimal-compare", Car(args), env)
+	if err != nil {
+		return
+	}
+	b, err := decimalArg("decimal-compare", Cadr(args), env)
+	if err != nil {
+		return
+	}
+	return IntegerWithValue(int64(a.Cmp(b))), nil
+}
+
+func DecimalToStringImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	d, err := decimalArg("decimal->string", Car(args), env)
+	if err != nil {
+		return
+	}
+	return StringWithValue(d.String()), nil
+}
+
+func DecimalToFloatImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	d, err := decimalArg("decimal->float", Car(args), env)
+	if err != nil {
+		return
+	}
+	return FloatWithValue(float32(d.Float64())), nil
+}
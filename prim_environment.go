@@ -7,12 +7,18 @@
 
 package golisp
 
+import (
+	"fmt"
+	"unsafe"
+)
+
 func RegisterEnvironmentPrimitives() {
 	MakePrimitiveFunction("environment?", "1", EnvironmentPImpl)
 	MakePrimitiveFunction("environment-has-parent?", "1", EnvironmentParentPImpl)
 	MakePrimitiveFunction("environment-bound-names", "1", EnvironmentBoundNamesImpl)
 	MakePrimitiveFunction("environment-macro-names", "1", EnvironmentMacroNamesImpl)
 	MakePrimitiveFunction("environment-bindings", "1", EnvironmentBindingsImpl)
+	MakePrimitiveFunction("environment-diff", "2", EnvironmentDiffImpl)
 	MakePrimitiveFunction("environment-reference-type", "2", EnvironmentReferenceTypeImpl)
 	MakePrimitiveFunction("environment-bound?", "2", EnvironmentBoundPImpl)
 	MakePrimitiveFunction("environment-assigned?", "2", EnvironmentAssignedPImpl)
@@ -24,11 +30,12 @@ func RegisterEnvironmentPrimitives() {
 	MakePrimitiveFunction("environment-define", "3", EnvironmentDefineImpl)
 	MakePrimitiveFunction("the-environment", "0", TheEnvironmentImpl)
 	MakePrimitiveFunction("procedure-environment", "1", ProcedureEnvironmentImpl)
+	MakePrimitiveFunction("host-value", "1", HostValueImpl)
 
 	MakePrimitiveFunction("restrict-environment", "0", RestrictEnvironmentImpl)
 	MakeRestrictedPrimitiveFunction("environment-parent", "1", EnvironmentParentImpl)
 	MakeRestrictedPrimitiveFunction("system-global-environment", "0", SystemGlobalEnvironmentImpl)
-	MakeRestrictedPrimitiveFunction("make-top-level-environment", "1|2|3", MakeTopLevelEnvironmentImpl)
+	MakeRestrictedPrimitiveFunction("make-top-level-environment", "0|1|2|3", MakeTopLevelEnvironmentImpl)
 	MakeRestrictedPrimitiveFunction("find-top-level-environment", "1", FindTopLevelEnvironmentImpl)
 }
 
@@ -38,7 +45,7 @@ func EnvironmentPImpl(args *Data, env *SymbolTableFrame) (result *Data, err erro
 
 func EnvironmentParentPImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if !EnvironmentP(Car(args)) {
-		err = ProcessError("environment-has-parent? requires an environment as it's argument", env)
+		err = ProcessTypeError("environment-has-parent? requires an environment as it's argument", env)
 		return
 	}
 	e := EnvironmentValue(Car(args))
@@ -47,7 +54,7 @@ func EnvironmentParentPImpl(args *Data, env *SymbolTableFrame) (result *Data, er
 
 func EnvironmentParentImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if !EnvironmentP(Car(args)) {
-		err = ProcessError("environment-parent requires an environment as it's argument", env)
+		err = ProcessTypeError("environment-parent requires an environment as it's argument", env)
 		return
 	}
 	e := EnvironmentValue(Car(args))
@@ -58,14 +65,30 @@ func EnvironmentParentImpl(args *Data, env *SymbolTableFrame) (result *Data, err
 	}
 }
 
+// sortedBindings lists the bindings of e, in name order in deterministic mode.  The caller
+// has to hold e's lock.
+func sortedBindings(e *SymbolTableFrame) []*Binding {
+	names := make([]string, 0, len(e.Bindings))
+	for name, _ := range e.Bindings {
+		names = append(names, name)
+	}
+	bindings := make([]*Binding, 0, len(names))
+	for _, name := range stableOrder(names) {
+		bindings = append(bindings, e.Bindings[name])
+	}
+	return bindings
+}
+
 func EnvironmentBoundNamesImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if !EnvironmentP(Car(args)) {
-		err = ProcessError("environment-bound-names requires an environment as it's argument", env)
+		err = ProcessTypeError("environment-bound-names requires an environment as it's argument", env)
 		return
 	}
 	e := EnvironmentValue(Car(args))
 	keys := make([]*Data, 0, 0)
-	for _, val := range e.Bindings {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	for _, val := range sortedBindings(e) {
 		keys = append(keys, val.Sym)
 	}
 	return ArrayToList(keys), nil
@@ -73,13 +96,15 @@ func EnvironmentBoundNamesImpl(args *Data, env *SymbolTableFrame) (result *Data,
 
 func EnvironmentMacroNamesImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if !EnvironmentP(Car(args)) {
-		err = ProcessError("environment-macro-names requires an environment as it's argument", env)
+		err = ProcessTypeError("environment-macro-names requires an environment as it's argument", env)
 		return
 	}
 	e := EnvironmentValue(Car(args))
 	keys := make([]*Data, 0, 0)
-	for _, val := range e.Bindings {
-		if MacroP(val.Val) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	for _, val := range sortedBindings(e) {
+		if MacroP(val.Value()) {
 			keys = append(keys, val.Sym)
 		}
 	}
@@ -88,28 +113,82 @@ func EnvironmentMacroNamesImpl(args *Data, env *SymbolTableFrame) (result *Data,
 
 func EnvironmentBindingsImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if !EnvironmentP(Car(args)) {
-		err = ProcessError("environment-bindings requires an environment as it's argument", env)
+		err = ProcessTypeError("environment-bindings requires an environment as it's argument", env)
 		return
 	}
 	e := EnvironmentValue(Car(args))
 	keys := make([]*Data, 0, 0)
-	for _, val := range e.Bindings {
-		if NilP(val.Val) {
+	e.Mutex.RLock()
+	defer e.Mutex.RUnlock()
+	for _, val := range sortedBindings(e) {
+		if NilP(val.Value()) {
 			keys = append(keys, InternalMakeList(val.Sym))
 		} else {
-			keys = append(keys, InternalMakeList(val.Sym, val.Val))
+			keys = append(keys, InternalMakeList(val.Sym, val.Value()))
 		}
 	}
 	return ArrayToList(keys), nil
 }
 
+// environmentSnapshotArg takes a snapshot of an environment, or reads one back from a list
+// of bindings returned by environment-bindings
+func environmentSnapshotArg(d *Data, env *SymbolTableFrame) (snapshot map[string]*Data, err error) {
+	if EnvironmentP(d) {
+		return EnvironmentValue(d).Snapshot(), nil
+	}
+	if !ListP(d) {
+		err = ProcessTypeError(fmt.Sprintf("environment-diff requires environments or lists of bindings but was given %s.", String(d)), env)
+		return
+	}
+	snapshot = make(map[string]*Data)
+	for c := d; NotNilP(c); c = Cdr(c) {
+		binding := Car(c)
+		if !PairP(binding) || !SymbolP(Car(binding)) {
+			err = ProcessTypeError(fmt.Sprintf("environment-diff requires bindings to be (name value) but was given %s.", String(binding)), env)
+			return
+		}
+		snapshot[StringValue(Car(binding))] = Cadr(binding)
+	}
+	return
+}
+
+func symbolList(names []string) *Data {
+	symbols := make([]*Data, 0, len(names))
+	for _, name := range names {
+		symbols = append(symbols, Intern(name))
+	}
+	return ArrayToList(symbols)
+}
+
+// EnvironmentDiffImpl compares two environments, or an environment's bindings from before
+// something happened with the environment afterwards, and returns a frame listing the names
+// that were added:, changed:, and removed:
+func EnvironmentDiffImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	before, err := environmentSnapshotArg(Car(args), env)
+	if err != nil {
+		return
+	}
+	after, err := environmentSnapshotArg(Cadr(args), env)
+	if err != nil {
+		return
+	}
+	diff := DiffSnapshots(before, after)
+	m := FrameMap{}
+	m.Data = FrameMapData{
+		"added:":   symbolList(diff.Added),
+		"changed:": symbolList(diff.Changed),
+		"removed:": symbolList(diff.Removed),
+	}
+	return FrameWithValue(&m), nil
+}
+
 func EnvironmentReferenceTypeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if !EnvironmentP(Car(args)) {
-		err = ProcessError("environment-reference-type? requires an environment as it's first argument", env)
+		err = ProcessTypeError("environment-reference-type? requires an environment as it's first argument", env)
 		return
 	}
 	if !SymbolP(Cadr(args)) {
-		err = ProcessError("environment-reference-type? requires a symbol as it's second argument", env)
+		err = ProcessTypeError("environment-reference-type? requires a symbol as it's second argument", env)
 		return
 	}
 
@@ -117,9 +196,9 @@ func EnvironmentReferenceTypeImpl(args *Data, env *SymbolTableFrame) (result *Da
 	binding, found := localEnv.FindBindingFor(Cadr(args))
 	if !found {
 		result = Intern("unbound")
-	} else if binding.Val == nil {
+	} else if binding.Value() == nil {
 		result = Intern("unassigned")
-	} else if MacroP(binding.Val) {
+	} else if MacroP(binding.Value()) {
 		result = Intern("macro")
 	} else {
 		result = Intern("normal")
@@ -129,11 +208,11 @@ func EnvironmentReferenceTypeImpl(args *Data, env *SymbolTableFrame) (result *Da
 
 func EnvironmentBoundPImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if !EnvironmentP(Car(args)) {
-		err = ProcessError("environment-bound? requires an environment as it's first argument", env)
+		err = ProcessTypeError("environment-bound? requires an environment as it's first argument", env)
 		return
 	}
 	if !SymbolP(Cadr(args)) {
-		err = ProcessError("environment-bound? requires a symbol as it's second argument", env)
+		err = ProcessTypeError("environment-bound? requires a symbol as it's second argument", env)
 		return
 	}
 
@@ -144,20 +223,20 @@ func EnvironmentBoundPImpl(args *Data, env *SymbolTableFrame) (result *Data, err
 
 func EnvironmentAssignedPImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if !EnvironmentP(Car(args)) {
-		err = ProcessError("environment-asigned? requires an environment as it's first argument", env)
+		err = ProcessTypeError("environment-asigned? requires an environment as it's first argument", env)
 		return
 	}
 	if !SymbolP(Cadr(args)) {
-		err = ProcessError("environment-assigned? requires a symbol as it's second argument", env)
+		err = ProcessTypeError("environment-assigned? requires a symbol as it's second argument", env)
 		return
 	}
 
 	localEnv := EnvironmentValue(Car(args))
 	binding, found := localEnv.FindBindingFor(Cadr(args))
 	if found {
-		if binding.Val == nil {
+		if binding.Value() == nil {
 			result = LispFalse
-		} else if MacroP(binding.Val) {
+		} else if MacroP(binding.Value()) {
 			err = ProcessError("environment-assigned?: name is bound to a macro", env)
 			return
 		} else {
@@ -172,25 +251,25 @@ func EnvironmentAssignedPImpl(args *Data, env *SymbolTableFrame) (result *Data,
 
 func EnvironmentLookupImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if !EnvironmentP(Car(args)) {
-		err = ProcessError("environment-lookup requires an environment as it's first argument", env)
+		err = ProcessTypeError("environment-lookup requires an environment as it's first argument", env)
 		return
 	}
 	if !SymbolP(Cadr(args)) {
-		err = ProcessError("environment-lookup requires a symbol as it's second argument", env)
+		err = ProcessTypeError("environment-lookup requires a symbol as it's second argument", env)
 		return
 	}
 
 	localEnv := EnvironmentValue(Car(args))
 	binding, found := localEnv.FindBindingFor(Cadr(args))
 	if found {
-		if binding.Val == nil {
+		if binding.Value() == nil {
 			err = ProcessError("environment-lookup: name is unassigned", env)
 			return
-		} else if MacroP(binding.Val) {
+		} else if MacroP(binding.Value()) {
 			err = ProcessError("environment-lookup: name is bound to a macro", env)
 			return
 		} else {
-			return binding.Val, nil
+			return binding.Value(), nil
 		}
 	} else {
 		err = ProcessError("environment-lookup: name is unbound", env)
@@ -200,18 +279,18 @@ func EnvironmentLookupImpl(args *Data, env *SymbolTableFrame) (result *Data, err
 
 func EnvironmentLookupMacroImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if !EnvironmentP(Car(args)) {
-		err = ProcessError("environment-lookup-macro requires an environment as it's first argument", env)
+		err = ProcessTypeError("environment-lookup-macro requires an environment as it's first argument", env)
 		return
 	}
 	if !SymbolP(Cadr(args)) {
-		err = ProcessError("environment-lookup-macro requires a symbol as it's second argument", env)
+		err = ProcessTypeError("environment-lookup-macro requires a symbol as it's second argument", env)
 		return
 	}
 
 	localEnv := EnvironmentValue(Car(args))
 	binding, found := localEnv.FindBindingFor(Cadr(args))
-	if found && MacroP(binding.Val) {
-		result = binding.Val
+	if found && MacroP(binding.Value()) {
+		result = binding.Value()
 	} else {
 		result = LispFalse
 	}
@@ -220,11 +299,11 @@ func EnvironmentLookupMacroImpl(args *Data, env *SymbolTableFrame) (result *Data
 
 func EnvironmentAssignablePImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if !EnvironmentP(Car(args)) {
-		err = ProcessError("environment-assignable? requires an environment as it's first argument", env)
+		err = ProcessTypeError("environment-assignable? requires an environment as it's first argument", env)
 		return
 	}
 	if !SymbolP(Cadr(args)) {
-		err = ProcessError("environment-assignable? requires a symbol as it's second argument", env)
+		err = ProcessTypeError("environment-assignable? requires a symbol as it's second argument", env)
 		return
 	}
 
@@ -235,11 +314,11 @@ func EnvironmentAssignablePImpl(args *Data, env *SymbolTableFrame) (result *Data
 
 func EnvironmentAssignBangImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if !EnvironmentP(Car(args)) {
-		err = ProcessError("environment-assign! requires an environment as it's first argument", env)
+		err = ProcessTypeError("environment-assign! requires an environment as it's first argument", env)
 		return
 	}
 	if !SymbolP(Cadr(args)) {
-		err = ProcessError("environment-assign! requires a symbol as it's second argument", env)
+		err = ProcessTypeError("environment-assign! requires a symbol as it's second argument", env)
 		return
 	}
 
@@ -247,18 +326,18 @@ func EnvironmentAssignBangImpl(args *Data, env *SymbolTableFrame) (result *Data,
 	binding, found := localEnv.FindBindingFor(Cadr(args))
 	if found {
 		result = Caddr(args)
-		binding.Val = result
+		binding.SetValue(result)
 	}
 	return
 }
 
 func EnvironmentDefinablePImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if !EnvironmentP(Car(args)) {
-		err = ProcessError("environment-definable? requires an environment as it's first argument", env)
+		err = ProcessTypeError("environment-definable? requires an environment as it's first argument", env)
 		return
 	}
 	if !SymbolP(Cadr(args)) {
-		err = ProcessError("environment-definable? requires a symbol as it's second argument", env)
+		err = ProcessTypeError("environment-definable? requires a symbol as it's second argument", env)
 		return
 	}
 
@@ -267,11 +346,11 @@ func EnvironmentDefinablePImpl(args *Data, env *SymbolTableFrame) (result *Data,
 
 func EnvironmentDefineImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if !EnvironmentP(Car(args)) {
-		err = ProcessError("environment-define requires an environment as it's first argument", env)
+		err = ProcessTypeError("environment-define requires an environment as it's first argument", env)
 		return
 	}
 	if !SymbolP(Cadr(args)) {
-		err = ProcessError("environment-define requires a symbol as it's second argument", env)
+		err = ProcessTypeError("environment-define requires a symbol as it's second argument", env)
 		return
 	}
 	_, err = EnvironmentValue(Car(args)).BindLocallyTo(Cadr(args), Caddr(args))
@@ -346,7 +425,7 @@ func MakeTopLevelEnvironmentImpl(args *Data, env *SymbolTableFrame) (result *Dat
 
 func FindTopLevelEnvironmentImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if !StringP(Car(args)) && !SymbolP(Car(args)) {
-		err = ProcessError("find-top-level-environment expects a symbol or string environment name", env)
+		err = ProcessTypeError("find-top-level-environment expects a symbol or string environment name", env)
 		return
 	}
 	TopLevelEnvironments.Mutex.RLock()
@@ -361,13 +440,54 @@ func FindTopLevelEnvironmentImpl(args *Data, env *SymbolTableFrame) (result *Dat
 
 func ProcedureEnvironmentImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if TypeOf(Car(args)) != FunctionType {
-		err = ProcessError("procedure-environment requires a user written function as it's argument", env)
+		err = ProcessTypeError("procedure-environment requires a user written function as it's argument", env)
 		return
 	}
 
 	return EnvironmentWithValue(FunctionValue(Car(args)).Env), nil
 }
 
+// hostValueBox holds a host value so it can be stored in a boxed object
+type hostValueBox struct {
+	value interface{}
+}
+
+// HostValueWithValue boxes an opaque host value for lisp code to hold and pass back to the
+// host's primitives, which unbox it with HostValueOf
+func HostValueWithValue(value interface{}) *Data {
+	return ObjectWithTypeAndValue("HostValue", unsafe.Pointer(&hostValueBox{value}))
+}
+
+func HostValueP(d *Data) bool {
+	return ObjectP(d) && ObjectType(d) == "HostValue"
+}
+
+func HostValueOf(d *Data) interface{} {
+	if !HostValueP(d) {
+		return nil
+	}
+	return (*hostValueBox)(ObjectValue(d)).value
+}
+
+// HostValueImpl handles (host-value key), returning the value the host attached under key
+// to the environment, or nil.  Values that are already lisp data are returned as they are
+// and anything else is boxed.
+func HostValueImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	key := Car(args)
+	if !SymbolP(key) && !StringP(key) {
+		err = ProcessTypeError(fmt.Sprintf("host-value requires a symbol or string key but was given %s.", String(key)), env)
+		return
+	}
+	value, found := env.GetHostValue(StringValue(key))
+	if !found {
+		return
+	}
+	if d, isData := value.(*Data); isData {
+		return d, nil
+	}
+	return HostValueWithValue(value), nil
+}
+
 func RestrictEnvironmentImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	env.IsRestricted = true
 	return StringWithValue("OK"), nil
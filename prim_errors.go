@@ -0,0 +1,78 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the primitive functions for inspecting errors caught by on-error.
+
+package golisp
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+func RegisterErrorPrimitives() {
+	MakePrimitiveFunction("error-object?", "1", IsErrorObjectImpl)
+	MakePrimitiveFunction("error-message", "1", ErrorMessageImpl)
+	MakePrimitiveFunction("error-category", "1", ErrorCategoryImpl)
+	for category, name := range errorCategoryNames {
+		if category != GeneralError {
+			makeErrorCategoryPredicate(name+"?", category)
+		}
+	}
+}
+
+func ErrorObjectWithValue(e *LispError) *Data {
+	return ObjectWithTypeAndValue("Error", unsafe.Pointer(e))
+}
+
+func ErrorObjectP(d *Data) bool {
+	return ObjectP(d) && ObjectType(d) == "Error"
+}
+
+func ErrorObjectValue(d *Data) *LispError {
+	if !ErrorObjectP(d) {
+		return nil
+	}
+	return (*LispError)(ObjectValue(d))
+}
+
+// ErrorObjectFor wraps err, with all the context it has gathered, for lisp error handlers
+func ErrorObjectFor(err error) *Data {
+	return ErrorObjectWithValue(&LispError{Category: ErrorCategoryOf(err), Message: err.Error(), Cause: err})
+}
+
+func errorObjectArg(name string, d *Data, env *SymbolTableFrame) (result *LispError, err error) {
+	if !ErrorObjectP(d) {
+		err = ProcessTypeError(fmt.Sprintf("%s expects an error object but received %s.", name, String(d)), env)
+		return
+	}
+	return ErrorObjectValue(d), nil
+}
+
+func makeErrorCategoryPredicate(name string, category ErrorCategory) {
+	MakePrimitiveFunction(name, "1", func(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+		return BooleanWithValue(ErrorObjectP(Car(args)) && ErrorObjectValue(Car(args)).Category == category), nil
+	})
+}
+
+func IsErrorObjectImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(ErrorObjectP(Car(args))), nil
+}
+
+func ErrorMessageImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	e, err := errorObjectArg("error-message", Car(args), env)
+	if err != nil {
+		return
+	}
+	return StringWithValue(e.Message), nil
+}
+
+func ErrorCategoryImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	e, err := errorObjectArg("error-category", Car(args), env)
+	if err != nil {
+		return
+	}
+	return Intern(e.Category.String()), nil
+}
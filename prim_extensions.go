@@ -0,0 +1,56 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the extension primitive functions.
+
+package golisp
+
+import (
+	"fmt"
+)
+
+func RegisterExtensionPrimitives() {
+	MakePrimitiveFunction("use-extension", "1", UseExtensionImpl)
+	MakePrimitiveFunction("extension-loaded?", "1", ExtensionLoadedImpl)
+	MakePrimitiveFunction("extension-names", "0", ExtensionNamesImpl)
+}
+
+func extensionNameArg(name string, d *Data, env *SymbolTableFrame) (extensionName string, err error) {
+	if !SymbolP(d) && !StringP(d) {
+		err = ProcessTypeError(fmt.Sprintf("%s expects an extension name as a symbol or string, but received %s.", name, String(d)), env)
+		return
+	}
+	return StringValue(d), nil
+}
+
+// UseExtensionImpl handles (use-extension 'name), loading the extension if it isn't already
+func UseExtensionImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	name, err := extensionNameArg("use-extension", Car(args), env)
+	if err != nil {
+		return
+	}
+	if err = UseExtension(name); err != nil {
+		return
+	}
+	return Car(args), nil
+}
+
+func ExtensionLoadedImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	name, err := extensionNameArg("extension-loaded?", Car(args), env)
+	if err != nil {
+		return
+	}
+	extension := ExtensionNamed(name)
+	return BooleanWithValue(extension != nil && extension.Loaded()), nil
+}
+
+func ExtensionNamesImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	names := ExtensionNames()
+	symbols := make([]*Data, 0, len(names))
+	for _, name := range names {
+		symbols = append(symbols, Intern(name))
+	}
+	return ArrayToList(symbols), nil
+}
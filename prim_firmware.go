@@ -0,0 +1,290 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the firmware image primitive functions.
+
+package golisp
+
+import (
+	"fmt"
+	"hash/crc32"
+	"unsafe"
+)
+
+func RegisterFirmwarePrimitives() {
+	MakePrimitiveFunction("parse-intel-hex", "1", ParseIntelHexImpl)
+	MakePrimitiveFunction("intel-hex-records", "1", IntelHexRecordsImpl)
+	MakePrimitiveFunction("binary->firmware", "1|2", BinaryToFirmwareImpl)
+	MakePrimitiveFunction("firmware?", "1", IsFirmwareImpl)
+	MakePrimitiveFunction("firmware-size", "1", FirmwareSizeImpl)
+	MakePrimitiveFunction("firmware-ranges", "1", FirmwareRangesImpl)
+	MakePrimitiveFunction("firmware-start-address", "1", FirmwareStartAddressImpl)
+	MakePrimitiveFunction("firmware-bytes", "3|4", FirmwareBytesImpl)
+	MakePrimitiveFunction("firmware->binary", "1|2", FirmwareToBinaryImpl)
+	MakePrimitiveFunction("firmware-chunks", "2", FirmwareChunksImpl)
+	MakePrimitiveFunction("crc32", "1", Crc32Impl)
+	MakePrimitiveFunction("crc16", "1", Crc16Impl)
+}
+
+func FirmwareWithValue(image *FirmwareImage) *Data {
+	return ObjectWithTypeAndValue("Firmware", unsafe.Pointer(image))
+}
+
+func FirmwareP(d *Data) bool {
+	return ObjectP(d) && ObjectType(d) == "Firmware"
+}
+
+func FirmwareValue(d *Data) *FirmwareImage {
+	if !FirmwareP(d) {
+		return nil
+	}
+	return (*FirmwareImage)(ObjectValue(d))
+}
+
+func bytesWithValue(bytes []byte) *Data {
+	return ObjectWithTypeAndValue("[]byte", unsafe.Pointer(&bytes))
+}
+
+func firmwareArg(name string, args *Data, env *SymbolTableFrame) (image *FirmwareImage, err error) {
+	if !FirmwareP(Car(args)) {
+		err = ProcessTypeError(fmt.Sprintf("%s expects a firmware image but received %s.", name, String(Car(args))), env)
+		return
+	}
+	return FirmwareValue(Car(args)), nil
+}
+
+func addressArg(name string, d *Data, env *SymbolTableFrame) (address uint64, err error) {
+	if !Uint64P(d) || Uint64Value(d) > 1<<32 {
+		err = ProcessTypeError(fmt.Sprintf("%s expects a 32 bit address but received %s.", name, String(d)), env)
+		return
+	}
+	return Uint64Value(d), nil
+}
+
+func fillArg(name string, args *Data, env *SymbolTableFrame) (fill byte, err error) {
+	if NilP(args) {
+		return 0xFF, nil
+	}
+	if !IntegerP(Car(args)) || IntegerValue(Car(args)) < 0 || IntegerValue(Car(args)) > 255 {
+		err = ProcessTypeError(fmt.Sprintf("%s expects a byte to fill gaps with but received %s.", name, String(Car(args))), env)
+		return
+	}
+	return byte(IntegerValue(Car(args))), nil
+}
+
+// ParseIntelHexImpl handles (parse-intel-hex text)
+func ParseIntelHexImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if !StringP(Car(args)) {
+		err = ProcessTypeError(fmt.Sprintf("parse-intel-hex expects a string but received %s.", String(Car(args))), env)
+		return
+	}
+	image, err := ParseIntelHex(StringValue(Car(args)))
+	if err != nil {
+		return
+	}
+	return FirmwareWithValue(image), nil
+}
+
+// IntelHexRecordsImpl handles (intel-hex-records text), which is a list of frames with the
+// line:, type:, address: and data: of each record
+func IntelHexRecordsImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if !StringP(Car(args)) {
+		err = ProcessTypeError(fmt.Sprintf("intel-hex-records expects a string but received %s.", String(Car(args))), env)
+		return
+	}
+	records, err := ParseHexRecords(StringValue(Car(args)))
+	if err != nil {
+		return
+	}
+	frames := make([]*Data, 0, len(records))
+	for _, record := range records {
+		frames = append(frames, FrameWithValue(&FrameMap{Data: FrameMapData{
+			"line:":    IntegerWithValue(int64(record.Line)),
+			"type:":    IntegerWithValue(int64(record.Type)),
+			"address:": IntegerWithValue(int64(record.Address)),
+			"data:":    bytesWithValue(record.Data),
+		}}))
+	}
+	return ArrayToList(frames), nil
+}
+
+// BinaryToFirmwareImpl handles (binary->firmware bytes [address]), for raw images that load
+// at address, or 0
+func BinaryToFirmwareImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	bytesObj := Car(args)
+	if !ObjectP(bytesObj) || ObjectType(bytesObj) != "[]byte" {
+		err = ProcessTypeError(fmt.Sprintf("binary->firmware expects a bytearray but received %s.", String(bytesObj)), env)
+		return
+	}
+	var address uint64
+	if NotNilP(Cdr(args)) {
+		if address, err = addressArg("binary->firmware", Cadr(args), env); err != nil {
+			return
+		}
+	}
+	image, err := BinaryFirmwareImage(*(*[]byte)(ObjectValue(bytesObj)), uint32(address))
+	if err != nil {
+		err = ProcessError(fmt.Sprintf("binary->firmware: %s", err), env)
+		return
+	}
+	return FirmwareWithValue(image), nil
+}
+
+func IsFirmwareImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(FirmwareP(Car(args))), nil
+}
+
+// FirmwareSizeImpl is the number of bytes in the image, not counting gaps
+func FirmwareSizeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	image, err := firmwareArg("firmware-size", args, env)
+	if err != nil {
+		return
+	}
+	return IntegerWithValue(int64(image.Size())), nil
+}
+
+// FirmwareRangesImpl is a list of the (start end) address ranges the image has data for,
+// where end is the address after the last byte
+func FirmwareRangesImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	image, err := firmwareArg("firmware-ranges", args, env)
+	if err != nil {
+		return
+	}
+	ranges := make([]*Data, 0, len(image.Segments))
+	for _, segment := range image.Segments {
+		ranges = append(ranges, InternalMakeList(IntegerWithValue(int64(segment.Address)), IntegerWithValue(int64(segment.end()))))
+	}
+	return ArrayToList(ranges), nil
+}
+
+func FirmwareStartAddressImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	image, err := firmwareArg("firmware-start-address", args, env)
+	if err != nil {
+		return
+	}
+	if !image.HasStart {
+		return LispFalse, nil
+	}
+	return IntegerWithValue(int64(image.Start)), nil
+}
+
+// FirmwareBytesImpl handles (firmware-bytes image start end [fill]), the bytes from start up
+// to end with gaps filled with fill, or 0xFF like erased flash
+func FirmwareBytesImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	image, err := firmwareArg("firmware-bytes", args, env)
+	if err != nil {
+		return
+	}
+	start, err := addressArg("firmware-bytes", Cadr(args), env)
+	if err != nil {
+		return
+	}
+	end, err := addressArg("firmware-bytes", Caddr(args), env)
+	if err != nil {
+		return
+	}
+	if end < start {
+		err = ProcessError(fmt.Sprintf("firmware-bytes expects start <= end, but received %d and %d.", start, end), env)
+		return
+	}
+	fill, err := fillArg("firmware-bytes", Cdddr(args), env)
+	if err != nil {
+		return
+	}
+	bytes, err := image.Bytes(start, end, fill)
+	if err != nil {
+		err = ProcessError(fmt.Sprintf("firmware-bytes: %s", err), env)
+		return
+	}
+	return bytesWithValue(bytes), nil
+}
+
+// FirmwareToBinaryImpl handles (firmware->binary image [fill]), the image as one bytearray
+// from its lowest address to its highest
+func FirmwareToBinaryImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	image, err := firmwareArg("firmware->binary", args, env)
+	if err != nil {
+		return
+	}
+	fill, err := fillArg("firmware->binary", Cdr(args), env)
+	if err != nil {
+		return
+	}
+	start, end := image.Extent()
+	bytes, err := image.Bytes(start, end, fill)
+	if err != nil {
+		err = ProcessError(fmt.Sprintf("firmware->binary: %s, use firmware-bytes or firmware-chunks on a sparse image", err), env)
+		return
+	}
+	return bytesWithValue(bytes), nil
+}
+
+// FirmwareChunksImpl handles (firmware-chunks image size), a list of (address bytes) blocks
+// of at most size bytes, aligned to size, for sending the image a block at a time
+func FirmwareChunksImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	image, err := firmwareArg("firmware-chunks", args, env)
+	if err != nil {
+		return
+	}
+	size := Cadr(args)
+	if !IntegerP(size) || IntegerValue(size) < 1 {
+		err = ProcessTypeError(fmt.Sprintf("firmware-chunks expects a positive block size but received %s.", String(size)), env)
+		return
+	}
+	chunks := image.Chunks(int(IntegerValue(size)))
+	blocks := make([]*Data, 0, len(chunks))
+	for _, chunk := range chunks {
+		blocks = append(blocks, InternalMakeList(IntegerWithValue(int64(chunk.Address)), bytesWithValue(append([]byte(nil), chunk.Data...))))
+	}
+	return ArrayToList(blocks), nil
+}
+
+// checksummedBytes is the bytes of a bytearray, or of a firmware image with its gaps filled
+// with 0xFF
+func checksummedBytes(name string, args *Data, env *SymbolTableFrame) (bytes []byte, err error) {
+	d := Car(args)
+	if FirmwareP(d) {
+		start, end := FirmwareValue(d).Extent()
+		bytes, err = FirmwareValue(d).Bytes(start, end, 0xFF)
+		if err != nil {
+			err = ProcessError(fmt.Sprintf("%s: %s", name, err), env)
+		}
+		return
+	}
+	bytes, ok := byteSlice(d)
+	if !ok {
+		err = ProcessTypeError(fmt.Sprintf("%s expects a bytearray, bytevector, or firmware image but received %s.", name, String(d)), env)
+	}
+	return
+}
+
+// Crc32Impl is the IEEE CRC-32 used by zip and ethernet
+func Crc32Impl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	bytes, err := checksummedBytes("crc32", args, env)
+	if err != nil {
+		return
+	}
+	return IntegerWithValue(int64(crc32.ChecksumIEEE(bytes))), nil
+}
+
+// Crc16Impl is CRC-16/CCITT-FALSE: polynomial 0x1021 starting from 0xFFFF
+func Crc16Impl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	bytes, err := checksummedBytes("crc16", args, env)
+	if err != nil {
+		return
+	}
+	var crc uint16 = 0xFFFF
+	for _, b := range bytes {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return IntegerWithValue(int64(crc)), nil
+}
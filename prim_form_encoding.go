@@ -0,0 +1,62 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the primitive functions for query strings and forms.
+
+package golisp
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+func RegisterFormEncodingPrimitives() {
+	MakePrimitiveFunction("frame->query-string", "1", FrameToQueryStringImpl)
+	MakePrimitiveFunction("parse-query-string", "1", ParseQueryStringImpl)
+	MakePrimitiveFunction("make-multipart-form", "1", MakeMultipartFormImpl)
+}
+
+func formFrameArg(name string, d *Data, env *SymbolTableFrame) (result *FrameMap, err error) {
+	if !FrameP(d) {
+		err = ProcessTypeError(fmt.Sprintf("%s requires a frame but was given %s.", name, String(d)), env)
+		return
+	}
+	return FrameValue(d), nil
+}
+
+func FrameToQueryStringImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	frame, err := formFrameArg("frame->query-string", Car(args), env)
+	if err != nil {
+		return
+	}
+	return StringWithValue(FrameToQueryString(frame)), nil
+}
+
+func ParseQueryStringImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if !StringP(Car(args)) {
+		err = ProcessTypeError(fmt.Sprintf("parse-query-string requires a string but was given %s.", String(Car(args))), env)
+		return
+	}
+	return ParseQueryString(StringValue(Car(args)))
+}
+
+// MakeMultipartFormImpl encodes a frame as multipart/form-data, resulting in a frame with
+// the content-type: to send, including the boundary, and the body: as a bytearray
+func MakeMultipartFormImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	frame, err := formFrameArg("make-multipart-form", Car(args), env)
+	if err != nil {
+		return
+	}
+	body, contentType, err := MultipartForm(frame)
+	if err != nil {
+		return
+	}
+	m := FrameMap{}
+	m.Data = FrameMapData{
+		"content-type:": StringWithValue(contentType),
+		"body:":         ObjectWithTypeAndValue("[]byte", unsafe.Pointer(&body)),
+	}
+	return FrameWithValue(&m), nil
+}
@@ -9,6 +9,7 @@ package golisp
 
 import (
 	"fmt"
+	"strings"
 )
 
 func RegisterFramePrimitives() {
@@ -27,6 +28,13 @@ func RegisterFramePrimitives() {
 	MakePrimitiveFunction("lisp->json", "1", LispToJsonImpl)
 	MakePrimitiveFunction("frame-keys", "1", FrameKeysImpl)
 	MakePrimitiveFunction("frame-values", "1", FrameValuesImpl)
+	MakePrimitiveFunction("frame-get-in", "2|3", FrameGetInImpl)
+	MakePrimitiveFunction("frame-set-in!", "3", FrameSetInImpl)
+	MakePrimitiveFunction("frame-merge", "*", FrameMergeImpl)
+	MakePrimitiveFunction("frame-deep-merge", "*", FrameDeepMergeImpl)
+	MakePrimitiveFunction("frame-map", "2", FrameMapImpl)
+	MakePrimitiveFunction("on-slot-change!", "3", OnSlotChangeImpl)
+	MakePrimitiveFunction("remove-slot-change!", "2", RemoveSlotChangeImpl)
 }
 
 func MakeFrameImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
@@ -39,7 +47,7 @@ func MakeFrameImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 	for c := args; NotNilP(c); c = Cddr(c) {
 		k := Car(c)
 		if !NakedP(k) {
-			err = ProcessError(fmt.Sprintf("Frame keys must be naked symbols, but was given %s.", String(k)), env)
+			err = ProcessTypeError(fmt.Sprintf("Frame keys must be naked symbols, but was given %s.", String(k)), env)
 			return
 		}
 		v := Cadr(c)
@@ -51,13 +59,13 @@ func MakeFrameImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 func HasSlotImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	f := Car(args)
 	if !FrameP(f) {
-		err = ProcessError(fmt.Sprintf("has-slot? requires a frame as it's first argument, but was given %s.", String(f)), env)
+		err = ProcessTypeError(fmt.Sprintf("has-slot? requires a frame as it's first argument, but was given %s.", String(f)), env)
 		return
 	}
 
 	k := Cadr(args)
 	if !NakedP(k) {
-		err = ProcessError(fmt.Sprintf("has-slot? requires a naked symbol as it's second argument, but was given %s.", String(k)), env)
+		err = ProcessTypeError(fmt.Sprintf("has-slot? requires a naked symbol as it's second argument, but was given %s.", String(k)), env)
 		return
 	}
 
@@ -67,7 +75,7 @@ func HasSlotImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func GetSlotImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	f := Car(args)
 	if !FrameP(f) {
-		err = ProcessError(fmt.Sprintf("get-slot requires a frame as it's first argument, but was given %s.", String(f)), env)
+		err = ProcessTypeError(fmt.Sprintf("get-slot requires a frame as it's first argument, but was given %s.", String(f)), env)
 		return
 	}
 
@@ -78,7 +86,7 @@ func GetSlotImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 
 	k := Cadr(args)
 	if !NakedP(k) {
-		err = ProcessError(fmt.Sprintf("get-slot requires a naked symbol as it's second argument, but was given %s.", String(k)), env)
+		err = ProcessTypeError(fmt.Sprintf("get-slot requires a naked symbol as it's second argument, but was given %s.", String(k)), env)
 		return
 	}
 
@@ -93,13 +101,13 @@ func GetSlotImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func GetSlotOrNilImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	f := Car(args)
 	if !FrameP(f) {
-		err = ProcessError(fmt.Sprintf("get-slot-or-nil requires a frame as it's first argument, but was given %s.", String(f)), env)
+		err = ProcessTypeError(fmt.Sprintf("get-slot-or-nil requires a frame as it's first argument, but was given %s.", String(f)), env)
 		return
 	}
 
 	k := Cadr(args)
 	if !NakedP(k) {
-		err = ProcessError(fmt.Sprintf("get-slot-or-nil requires a naked symbol as it's second argument, but was given %s.", String(k)), env)
+		err = ProcessTypeError(fmt.Sprintf("get-slot-or-nil requires a naked symbol as it's second argument, but was given %s.", String(k)), env)
 		return
 	}
 
@@ -114,13 +122,13 @@ func RemoveSlotImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 	}
 
 	if !FrameP(f) {
-		err = ProcessError(fmt.Sprintf("remove-slot! requires a frame as it's first argument, but was given %s.", String(f)), env)
+		err = ProcessTypeError(fmt.Sprintf("remove-slot! requires a frame as it's first argument, but was given %s.", String(f)), env)
 		return
 	}
 
 	k := Cadr(args)
 	if !NakedP(k) {
-		err = ProcessError(fmt.Sprintf("remove-slot! requires a naked symbol as it's second argument, but was given %s.", String(k)), env)
+		err = ProcessTypeError(fmt.Sprintf("remove-slot! requires a naked symbol as it's second argument, but was given %s.", String(k)), env)
 		return
 	}
 
@@ -130,13 +138,13 @@ func RemoveSlotImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 func SetSlotImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	f := Car(args)
 	if !FrameP(f) {
-		err = ProcessError(fmt.Sprintf("set-slot! requires a frame as it's first argument, but was given %s.", String(f)), env)
+		err = ProcessTypeError(fmt.Sprintf("set-slot! requires a frame as it's first argument, but was given %s.", String(f)), env)
 		return
 	}
 
 	k := Cadr(args)
 	if !NakedP(k) {
-		err = ProcessError(fmt.Sprintf("set-slot! requires a naked symbol as it's second argument, but was given %s.", String(k)), env)
+		err = ProcessTypeError(fmt.Sprintf("set-slot! requires a naked symbol as it's second argument, but was given %s.", String(k)), env)
 		return
 	}
 
@@ -148,15 +156,18 @@ func SetSlotImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func SendImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	f := Car(args)
 	if !FrameP(f) {
-		err = ProcessError(fmt.Sprintf("send requires a frame as it's first argument, but was given %s.", String(f)), env)
+		err = ProcessTypeError(fmt.Sprintf("send requires a frame as it's first argument, but was given %s.", String(f)), env)
 		return
 	}
 
 	k := Cadr(args)
-	if !NakedP(k) {
-		err = ProcessError(fmt.Sprintf("send requires a naked symbol as it's second argument, but was given %s.", String(k)), env)
+	if !SymbolP(k) {
+		err = ProcessTypeError(fmt.Sprintf("send requires a symbol as it's second argument, but was given %s.", String(k)), env)
 		return
 	}
+	if !NakedP(k) {
+		k = NakedSymbolFrom(k)
+	}
 
 	if !FrameValue(f).HasSlot(StringValue(k)) {
 		err = ProcessError(fmt.Sprintf("send requires an existing slot, but was given %s.", String(k)), env)
@@ -165,7 +176,7 @@ func SendImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 
 	fun := FrameValue(f).Get(StringValue(k))
 	if !FunctionP(fun) {
-		err = ProcessError(fmt.Sprintf("send requires a function slot, but was given a slot containing a %s.", TypeName(TypeOf(fun))), env)
+		err = ProcessTypeError(fmt.Sprintf("send requires a function slot, but was given a slot containing a %s.", TypeName(TypeOf(fun))), env)
 		return
 	}
 
@@ -197,13 +208,13 @@ func SendSuperImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 
 	selector := Car(args)
 	if !NakedP(selector) {
-		err = ProcessError(fmt.Sprintf("Selector must be a naked symbol but was %s.", TypeName(TypeOf(selector))), env)
+		err = ProcessTypeError(fmt.Sprintf("Selector must be a naked symbol but was %s.", TypeName(TypeOf(selector))), env)
 		return
 	}
 
 	fun := getSuperFunction(StringValue(selector), env)
 	if fun == nil || !FunctionP(fun) {
-		err = ProcessError(fmt.Sprintf("Message sent must select a function slot but was %s.", TypeName(TypeOf(fun))), env)
+		err = ProcessTypeError(fmt.Sprintf("Message sent must select a function slot but was %s.", TypeName(TypeOf(fun))), env)
 		return
 	}
 
@@ -222,7 +233,7 @@ func ApplySlotImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 		return
 	}
 	if !FrameP(f) {
-		err = ProcessError(fmt.Sprintf("apply-slot requires a frame as it's first argument, but was given %s.", String(f)), env)
+		err = ProcessTypeError(fmt.Sprintf("apply-slot requires a frame as it's first argument, but was given %s.", String(f)), env)
 		return
 	}
 
@@ -231,7 +242,7 @@ func ApplySlotImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 		return
 	}
 	if !NakedP(k) {
-		err = ProcessError(fmt.Sprintf("apply-slot requires a naked symbol as it's second argument, but was given %s.", String(k)), env)
+		err = ProcessTypeError(fmt.Sprintf("apply-slot requires a naked symbol as it's second argument, but was given %s.", String(k)), env)
 		return
 	}
 
@@ -242,7 +253,7 @@ func ApplySlotImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 
 	fun := FrameValue(f).Get(StringValue(k))
 	if !FunctionP(fun) {
-		err = ProcessError(fmt.Sprintf("apply-slot requires a function slot, but was given a slot containing a %s.", TypeName(TypeOf(fun))), env)
+		err = ProcessTypeError(fmt.Sprintf("apply-slot requires a function slot, but was given a slot containing a %s.", TypeName(TypeOf(fun))), env)
 		return
 	}
 
@@ -265,7 +276,7 @@ func ApplySlotImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 			argList = ary[0]
 		}
 	} else {
-		err = ProcessError("The last argument to apply must be a list", env)
+		err = ProcessTypeError("The last argument to apply must be a list", env)
 		return
 	}
 
@@ -283,13 +294,13 @@ func ApplySlotSuperImpl(args *Data, env *SymbolTableFrame) (result *Data, err er
 		return
 	}
 	if !NakedP(selector) {
-		err = ProcessError(fmt.Sprintf("Selector must be a naked symbol but was %s.", TypeName(TypeOf(selector))), env)
+		err = ProcessTypeError(fmt.Sprintf("Selector must be a naked symbol but was %s.", TypeName(TypeOf(selector))), env)
 		return
 	}
 
 	fun := getSuperFunction(StringValue(selector), env)
 	if fun == nil || !FunctionP(fun) {
-		err = ProcessError(fmt.Sprintf("Message sent must select a function slot but was %s.", TypeName(TypeOf(fun))), env)
+		err = ProcessTypeError(fmt.Sprintf("Message sent must select a function slot but was %s.", TypeName(TypeOf(fun))), env)
 		return
 	}
 
@@ -312,7 +323,7 @@ func ApplySlotSuperImpl(args *Data, env *SymbolTableFrame) (result *Data, err er
 			argList = ary[0]
 		}
 	} else {
-		err = ProcessError("The last argument to apply must be a list", env)
+		err = ProcessTypeError("The last argument to apply must be a list", env)
 		return
 	}
 
@@ -327,7 +338,7 @@ func ApplySlotSuperImpl(args *Data, env *SymbolTableFrame) (result *Data, err er
 func CloneImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	f := Car(args)
 	if !FrameP(f) {
-		err = ProcessError(fmt.Sprintf("clone requires a frame as it's argument, but was given %s.", String(f)), env)
+		err = ProcessTypeError(fmt.Sprintf("clone requires a frame as it's argument, but was given %s.", String(f)), env)
 		return
 	}
 
@@ -337,7 +348,7 @@ func CloneImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func JsonToLispImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	j := Car(args)
 	if !StringP(j) {
-		err = ProcessError(fmt.Sprintf("json->lisp requires a string as it's argument, but was given %s.", String(j)), env)
+		err = ProcessTypeError(fmt.Sprintf("json->lisp requires a string as it's argument, but was given %s.", String(j)), env)
 		return
 	}
 
@@ -353,7 +364,7 @@ func LispToJsonImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 func FrameKeysImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	f := Car(args)
 	if !FrameP(f) {
-		err = ProcessError(fmt.Sprintf("frame-keys requires a frame as it's argument, but was given %s.", String(f)), env)
+		err = ProcessTypeError(fmt.Sprintf("frame-keys requires a frame as it's argument, but was given %s.", String(f)), env)
 		return
 	}
 
@@ -363,9 +374,191 @@ func FrameKeysImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 func FrameValuesImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	f := Car(args)
 	if !FrameP(f) {
-		err = ProcessError(fmt.Sprintf("frame-values requires a frame as it's argument, but was given %s.", String(f)), env)
+		err = ProcessTypeError(fmt.Sprintf("frame-values requires a frame as it's argument, but was given %s.", String(f)), env)
 		return
 	}
 
 	return ArrayToList(FrameValue(f).Values()), nil
 }
+
+func checkSlotPath(name string, path *Data, env *SymbolTableFrame) (err error) {
+	if !ListP(path) || NilP(path) {
+		return ProcessTypeError(fmt.Sprintf("%s requires a non-empty list of slot names as a path, but was given %s.", name, String(path)), env)
+	}
+	for c := path; NotNilP(c); c = Cdr(c) {
+		if !NakedP(Car(c)) {
+			return ProcessTypeError(fmt.Sprintf("%s requires slot names in the path to be naked symbols, but was given %s.", name, String(Car(c))), env)
+		}
+	}
+	return
+}
+
+func FrameGetInImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	f := Car(args)
+	if !FrameP(f) {
+		err = ProcessTypeError(fmt.Sprintf("frame-get-in requires a frame as it's first argument, but was given %s.", String(f)), env)
+		return
+	}
+
+	path := Cadr(args)
+	err = checkSlotPath("frame-get-in", path, env)
+	if err != nil {
+		return
+	}
+
+	defaultValue := Caddr(args)
+	for c := path; NotNilP(c); c = Cdr(c) {
+		if !FrameP(f) || !FrameValue(f).HasSlot(StringValue(Car(c))) {
+			return defaultValue, nil
+		}
+		f = FrameValue(f).Get(StringValue(Car(c)))
+	}
+	return f, nil
+}
+
+func FrameSetInImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	f := Car(args)
+	if !FrameP(f) {
+		err = ProcessTypeError(fmt.Sprintf("frame-set-in! requires a frame as it's first argument, but was given %s.", String(f)), env)
+		return
+	}
+
+	path := Cadr(args)
+	err = checkSlotPath("frame-set-in!", path, env)
+	if err != nil {
+		return
+	}
+
+	// walk down to the frame holding the last slot, adding empty frames where the path is missing
+	c := path
+	for ; NotNilP(Cdr(c)); c = Cdr(c) {
+		key := StringValue(Car(c))
+		next := FrameValue(f).Get(key)
+		if next == nil {
+			m := FrameMap{}
+			m.Data = make(FrameMapData)
+			next = FrameValue(f).Set(key, FrameWithValue(&m))
+		} else if !FrameP(next) {
+			err = ProcessError(fmt.Sprintf("frame-set-in! found %s at %s in the path, which is not a frame.", String(next), String(Car(c))), env)
+			return
+		}
+		f = next
+	}
+
+	return FrameValue(f).Set(StringValue(Car(c)), Caddr(args)), nil
+}
+
+// mergeFrames builds a new frame with the slots of each frame in turn, later frames winning.
+// When deep is true, slots that hold frames in both are merged rather than replaced.
+func mergeFrames(frames []*FrameMap, deep bool) *FrameMap {
+	m := FrameMap{}
+	m.Data = make(FrameMapData)
+	for _, frame := range frames {
+		for k, v := range frame.Clone().Data {
+			existing, found := m.Data[k]
+			if deep && found && FrameP(existing) && FrameP(v) && !strings.HasSuffix(k, "*:") {
+				v = FrameWithValue(mergeFrames([]*FrameMap{FrameValue(existing), FrameValue(v)}, true))
+			}
+			m.Data[k] = v
+		}
+	}
+	return &m
+}
+
+func frameMergeArgs(name string, args *Data, env *SymbolTableFrame) (frames []*FrameMap, err error) {
+	for c := args; NotNilP(c); c = Cdr(c) {
+		if !FrameP(Car(c)) {
+			err = ProcessTypeError(fmt.Sprintf("%s requires frames, but was given %s.", name, String(Car(c))), env)
+			return
+		}
+		frames = append(frames, FrameValue(Car(c)))
+	}
+	return
+}
+
+func FrameMergeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	frames, err := frameMergeArgs("frame-merge", args, env)
+	if err != nil {
+		return
+	}
+	return FrameWithValue(mergeFrames(frames, false)), nil
+}
+
+func FrameDeepMergeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	frames, err := frameMergeArgs("frame-deep-merge", args, env)
+	if err != nil {
+		return
+	}
+	return FrameWithValue(mergeFrames(frames, true)), nil
+}
+
+func FrameMapImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	f := Car(args)
+	if !FunctionOrPrimitiveP(f) {
+		err = ProcessTypeError(fmt.Sprintf("frame-map requires a function as it's first argument, but was given %s.", String(f)), env)
+		return
+	}
+
+	frame := Cadr(args)
+	if !FrameP(frame) {
+		err = ProcessTypeError(fmt.Sprintf("frame-map requires a frame as it's second argument, but was given %s.", String(frame)), env)
+		return
+	}
+
+	m := FrameMap{}
+	m.Data = make(FrameMapData)
+	for k, v := range FrameValue(frame).Clone().Data {
+		m.Data[k], err = ApplyWithoutEval(f, InternalMakeList(Intern(k), v), env)
+		if err != nil {
+			return
+		}
+	}
+	return FrameWithValue(&m), nil
+}
+
+func OnSlotChangeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	f := Car(args)
+	if !FrameP(f) {
+		err = ProcessTypeError(fmt.Sprintf("on-slot-change! requires a frame as it's first argument, but was given %s.", String(f)), env)
+		return
+	}
+
+	k := Cadr(args)
+	if !SymbolP(k) {
+		err = ProcessTypeError(fmt.Sprintf("on-slot-change! requires a symbol as it's second argument, but was given %s.", String(k)), env)
+		return
+	}
+	if !NakedP(k) {
+		k = NakedSymbolFrom(k)
+	}
+
+	handler := Caddr(args)
+	if !FunctionOrPrimitiveP(handler) {
+		err = ProcessTypeError(fmt.Sprintf("on-slot-change! requires a function as it's third argument, but was given %s.", String(handler)), env)
+		return
+	}
+
+	id := FrameValue(f).OnSlotChange(StringValue(k), func(frame *FrameMap, key string, oldValue *Data, newValue *Data) {
+		_, handlerErr := ApplyWithoutEval(handler, InternalMakeList(f, Intern(key), oldValue, newValue), env)
+		if handlerErr != nil {
+			LogErrorf("Slot change handler for %s failed: %s\n", key, handlerErr)
+		}
+	})
+	return IntegerWithValue(id), nil
+}
+
+func RemoveSlotChangeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	f := Car(args)
+	if !FrameP(f) {
+		err = ProcessTypeError(fmt.Sprintf("remove-slot-change! requires a frame as it's first argument, but was given %s.", String(f)), env)
+		return
+	}
+
+	id := Cadr(args)
+	if !IntegerP(id) {
+		err = ProcessTypeError(fmt.Sprintf("remove-slot-change! requires a handler id as it's second argument, but was given %s.", String(id)), env)
+		return
+	}
+
+	return BooleanWithValue(FrameValue(f).RemoveSlotChangeHandler(IntegerValue(id))), nil
+}
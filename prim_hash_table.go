@@ -0,0 +1,330 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the hash table primitive functions.
+
+package golisp
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"unsafe"
+)
+
+// HashTable maps keys to values, comparing keys with equal?.  Keys are bucketed by Hash,
+// so lookups don't depend on how many entries there are.  Mutating a key after using it
+// loses its entry, as it then hashes differently.
+type HashTable struct {
+	buckets map[uint64][]*hashEntry
+	count   int
+	mutex   sync.RWMutex
+}
+
+type hashEntry struct {
+	key   *Data
+	value *Data
+}
+
+func NewHashTable() *HashTable {
+	return &HashTable{buckets: make(map[uint64][]*hashEntry)}
+}
+
+// Hashing and comparing keys can call back into Lisp, or into this table when a key
+// contains it, so neither is done while holding the lock.  Set and Remove look up the key
+// in a snapshot of its bucket, then make their change only if the bucket hasn't changed in
+// the meantime, trying again if it has.
+
+func (self *HashTable) bucket(h uint64) []*hashEntry {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	return self.buckets[h]
+}
+
+func indexOfKey(bucket []*hashEntry, key *Data) int {
+	for i, entry := range bucket {
+		if IsDeepEqual(entry.key, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sameBucket is whether a bucket is as it was when snapshot was taken
+func sameBucket(bucket []*hashEntry, snapshot []*hashEntry) bool {
+	return len(bucket) == len(snapshot) && (len(bucket) == 0 || &bucket[0] == &snapshot[0])
+}
+
+// Get returns the value for key, and whether there is one
+func (self *HashTable) Get(key *Data) (value *Data, found bool) {
+	bucket := self.bucket(Hash(key))
+	index := indexOfKey(bucket, key)
+	if index < 0 {
+		return nil, false
+	}
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	return bucket[index].value, true
+}
+
+func (self *HashTable) Set(key *Data, value *Data) {
+	h := Hash(key)
+	for {
+		snapshot := self.bucket(h)
+		index := indexOfKey(snapshot, key)
+		self.mutex.Lock()
+		if !sameBucket(self.buckets[h], snapshot) {
+			self.mutex.Unlock()
+			continue
+		}
+		if index >= 0 {
+			snapshot[index].value = value
+		} else {
+			self.buckets[h] = append(snapshot, &hashEntry{key: key, value: value})
+			self.count++
+		}
+		self.mutex.Unlock()
+		return
+	}
+}
+
+// Remove removes the entry for key, returning whether there was one
+func (self *HashTable) Remove(key *Data) bool {
+	h := Hash(key)
+	for {
+		snapshot := self.bucket(h)
+		index := indexOfKey(snapshot, key)
+		if index < 0 {
+			return false
+		}
+		self.mutex.Lock()
+		if !sameBucket(self.buckets[h], snapshot) {
+			self.mutex.Unlock()
+			continue
+		}
+		if len(snapshot) == 1 {
+			delete(self.buckets, h)
+		} else {
+			self.buckets[h] = append(snapshot[:index:index], snapshot[index+1:]...)
+		}
+		self.count--
+		self.mutex.Unlock()
+		return true
+	}
+}
+
+func (self *HashTable) Count() int {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	return self.count
+}
+
+func (self *HashTable) Clear() {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.buckets = make(map[uint64][]*hashEntry)
+	self.count = 0
+}
+
+// entries returns a snapshot of the entries, so they can be visited without holding the
+// lock.  They are in no particular order, except in deterministic mode, where they are in
+// order of hash, and then of insertion.
+func (self *HashTable) entries() []hashEntry {
+	ordered := Deterministic()
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	hashes := make([]uint64, 0, len(self.buckets))
+	for h := range self.buckets {
+		hashes = append(hashes, h)
+	}
+	if ordered {
+		sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+	}
+	entries := make([]hashEntry, 0, self.count)
+	for _, h := range hashes {
+		for _, entry := range self.buckets[h] {
+			entries = append(entries, *entry)
+		}
+	}
+	return entries
+}
+
+func RegisterHashTablePrimitives() {
+	MakePrimitiveFunction("make-hash-table", "0|1", MakeHashTableImpl)
+	MakePrimitiveFunction("hash-table?", "1", IsHashTableImpl)
+	MakePrimitiveFunction("hash-ref", "2|3", HashRefImpl)
+	MakePrimitiveFunction("hash-set!", "3", HashSetImpl)
+	MakePrimitiveFunction("hash-remove!", "2", HashRemoveImpl)
+	MakePrimitiveFunction("hash-has-key?", "2", HashHasKeyImpl)
+	MakePrimitiveFunction("hash-count", "1", HashCountImpl)
+	MakePrimitiveFunction("hash-clear!", "1", HashClearImpl)
+	MakePrimitiveFunction("hash-keys", "1", HashKeysImpl)
+	MakePrimitiveFunction("hash-values", "1", HashValuesImpl)
+	MakePrimitiveFunction("hash-map", "2", HashMapImpl)
+	MakePrimitiveFunction("hash->alist", "1", HashToAlistImpl)
+}
+
+func HashTableWithValue(table *HashTable) *Data {
+	return ObjectWithTypeAndValue("HashTable", unsafe.Pointer(table))
+}
+
+func HashTableP(d *Data) bool {
+	return ObjectP(d) && ObjectType(d) == "HashTable"
+}
+
+func HashTableValue(d *Data) *HashTable {
+	if !HashTableP(d) {
+		return nil
+	}
+	return (*HashTable)(ObjectValue(d))
+}
+
+func hashTableArg(name string, args *Data, env *SymbolTableFrame) (table *HashTable, err error) {
+	tableObj := Car(args)
+	if !HashTableP(tableObj) {
+		err = ProcessTypeError(fmt.Sprintf("%s expects a hash table but received %s.", name, String(tableObj)), env)
+		return
+	}
+	return HashTableValue(tableObj), nil
+}
+
+// MakeHashTableImpl handles (make-hash-table [alist]), filling the table from the pairs
+// of alist if it's given
+func MakeHashTableImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	table := NewHashTable()
+	for c := Car(args); NotNilP(c); c = Cdr(c) {
+		pair := Car(c)
+		if !PairP(c) || !PairP(pair) {
+			err = ProcessTypeError(fmt.Sprintf("make-hash-table expects an association list but received %s.", String(Car(args))), env)
+			return
+		}
+		table.Set(Car(pair), Cdr(pair))
+	}
+	return HashTableWithValue(table), nil
+}
+
+func IsHashTableImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(HashTableP(Car(args))), nil
+}
+
+// HashRefImpl handles (hash-ref table key [default]), resulting in default, or nil, if key
+// has no value
+func HashRefImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	table, err := hashTableArg("hash-ref", args, env)
+	if err != nil {
+		return
+	}
+	if value, found := table.Get(Cadr(args)); found {
+		return value, nil
+	}
+	return Caddr(args), nil
+}
+
+// HashSetImpl handles (hash-set! table key value), resulting in value
+func HashSetImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	table, err := hashTableArg("hash-set!", args, env)
+	if err != nil {
+		return
+	}
+	table.Set(Cadr(args), Caddr(args))
+	return Caddr(args), nil
+}
+
+// HashRemoveImpl results in whether there was an entry to remove
+func HashRemoveImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	table, err := hashTableArg("hash-remove!", args, env)
+	if err != nil {
+		return
+	}
+	return BooleanWithValue(table.Remove(Cadr(args))), nil
+}
+
+func HashHasKeyImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	table, err := hashTableArg("hash-has-key?", args, env)
+	if err != nil {
+		return
+	}
+	_, found := table.Get(Cadr(args))
+	return BooleanWithValue(found), nil
+}
+
+func HashCountImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	table, err := hashTableArg("hash-count", args, env)
+	if err != nil {
+		return
+	}
+	return IntegerWithValue(int64(table.Count())), nil
+}
+
+func HashClearImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	table, err := hashTableArg("hash-clear!", args, env)
+	if err != nil {
+		return
+	}
+	table.Clear()
+	return Car(args), nil
+}
+
+func HashKeysImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	table, err := hashTableArg("hash-keys", args, env)
+	if err != nil {
+		return
+	}
+	entries := table.entries()
+	keys := make([]*Data, len(entries))
+	for i, entry := range entries {
+		keys[i] = entry.key
+	}
+	return ArrayToList(keys), nil
+}
+
+func HashValuesImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	table, err := hashTableArg("hash-values", args, env)
+	if err != nil {
+		return
+	}
+	entries := table.entries()
+	values := make([]*Data, len(entries))
+	for i, entry := range entries {
+		values[i] = entry.value
+	}
+	return ArrayToList(values), nil
+}
+
+// HashMapImpl handles (hash-map table function), resulting in the list of what function
+// returns for each key and value
+func HashMapImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	table, err := hashTableArg("hash-map", args, env)
+	if err != nil {
+		return
+	}
+	f := Cadr(args)
+	if !FunctionOrPrimitiveP(f) {
+		err = ProcessTypeError(fmt.Sprintf("hash-map expects a function but received %s.", String(f)), env)
+		return
+	}
+	entries := table.entries()
+	results := make([]*Data, len(entries))
+	for i, entry := range entries {
+		results[i], err = ApplyWithoutEval(f, InternalMakeList(entry.key, entry.value), env)
+		if err != nil {
+			return
+		}
+	}
+	return ArrayToList(results), nil
+}
+
+func HashToAlistImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	table, err := hashTableArg("hash->alist", args, env)
+	if err != nil {
+		return
+	}
+	entries := table.entries()
+	pairs := make([]*Data, len(entries))
+	for i, entry := range entries {
+		pairs[i] = Cons(entry.key, entry.value)
+	}
+	return ArrayToList(pairs), nil
+}
@@ -0,0 +1,360 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the priority queue (binary heap) primitive functions.
+
+package golisp
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+// Heap is a binary heap ordered by a lisp comparator: the item for which (less? item other)
+// holds against every other item is the one at the top.  The comparator isn't run with the
+// heap locked, so it can look at (or even change) the heap without deadlocking: two items
+// are compared unlocked, and then swapped in place only if the heap hasn't changed since.
+type Heap struct {
+	Items   []*Data
+	Compare *Data
+	Mutex   sync.Mutex
+	version uint64 // counts changes to Items
+	ordered uint64 // the version at which Items was last known to be in heap order
+}
+
+func (self *Heap) Len() int {
+	return len(self.Items)
+}
+
+// heapCursor sifts items of a heap one version-checked step at a time.  If the heap is
+// changed by anyone else along the way the cursor stops, as the positions it is working
+// with no longer mean anything.
+type heapCursor struct {
+	h        *Heap
+	env      *SymbolTableFrame
+	version  uint64
+	fromHeap bool // whether the heap was in heap order when the cursor started
+	stale    bool
+	err      error
+}
+
+// cursor starts an operation on the heap, which must be locked
+func (self *Heap) cursor(env *SymbolTableFrame) *heapCursor {
+	return &heapCursor{h: self, env: env, version: self.version, fromHeap: self.version == self.ordered}
+}
+
+// changed records a change the cursor made to the heap, which must be locked
+func (self *heapCursor) changed() {
+	self.h.version++
+	self.version = self.h.version
+}
+
+// finish records that the heap is in heap order if the cursor's operation ran undisturbed
+// from a heap that was
+func (self *heapCursor) finish() {
+	self.h.Mutex.Lock()
+	if self.fromHeap && !self.stale && self.err == nil && self.h.version == self.version {
+		self.h.ordered = self.version
+	}
+	self.h.Mutex.Unlock()
+}
+
+func (self *heapCursor) less(i, j int) bool {
+	if self.stale || self.err != nil {
+		return false
+	}
+	self.h.Mutex.Lock()
+	if self.h.version != self.version {
+		self.stale = true
+		self.h.Mutex.Unlock()
+		return false
+	}
+	a, b := self.h.Items[i], self.h.Items[j]
+	self.h.Mutex.Unlock()
+
+	var comparison *Data
+	comparison, self.err = ApplyWithoutEval(self.h.Compare, InternalMakeList(a, b), self.env)
+	return BooleanValue(comparison)
+}
+
+func (self *heapCursor) swap(i, j int) bool {
+	self.h.Mutex.Lock()
+	defer self.h.Mutex.Unlock()
+	if self.h.version != self.version {
+		self.stale = true
+		return false
+	}
+	self.h.Items[i], self.h.Items[j] = self.h.Items[j], self.h.Items[i]
+	self.changed()
+	return true
+}
+
+// up and down are as in container/heap
+
+func (self *heapCursor) up(j int) {
+	for j > 0 {
+		i := (j - 1) / 2
+		if !self.less(j, i) || !self.swap(i, j) {
+			return
+		}
+		j = i
+	}
+}
+
+func (self *heapCursor) down(i int, n int) {
+	for {
+		j := 2*i + 1
+		if j >= n || j < 0 {
+			return
+		}
+		if j2 := j + 1; j2 < n && self.less(j2, j) {
+			j = j2
+		}
+		if !self.less(j, i) || !self.swap(i, j) {
+			return
+		}
+		i = j
+	}
+}
+
+// settle puts the heap in heap order if it isn't known to be, which is only the case when
+// a change was made while another was being sifted into place
+func (self *Heap) settle(env *SymbolTableFrame) error {
+	for {
+		self.Mutex.Lock()
+		if self.version == self.ordered {
+			self.Mutex.Unlock()
+			return nil
+		}
+		c := self.cursor(env)
+		c.fromHeap = true
+		n := len(self.Items)
+		self.Mutex.Unlock()
+
+		for i := n/2 - 1; i >= 0 && !c.stale && c.err == nil; i-- {
+			c.down(i, n)
+		}
+		if c.err != nil {
+			return c.err
+		}
+		c.finish()
+	}
+}
+
+// push adds item to the heap.  If the comparator fails the item is taken back out.
+func (self *Heap) push(item *Data, env *SymbolTableFrame) error {
+	self.Mutex.Lock()
+	c := self.cursor(env)
+	self.Items = append(self.Items, item)
+	c.changed()
+	last := len(self.Items) - 1
+	self.Mutex.Unlock()
+
+	c.up(last)
+	if c.err != nil {
+		self.Mutex.Lock()
+		for i, other := range self.Items {
+			if other == item {
+				self.Items[i] = self.Items[len(self.Items)-1]
+				self.Items[len(self.Items)-1] = nil
+				self.Items = self.Items[:len(self.Items)-1]
+				self.version++
+				break
+			}
+		}
+		self.Mutex.Unlock()
+		return c.err
+	}
+	c.finish()
+	return nil
+}
+
+// peek returns the top item of the heap, with found false if it is empty
+func (self *Heap) peek(env *SymbolTableFrame) (item *Data, found bool, err error) {
+	for {
+		err = self.settle(env)
+		if err != nil {
+			return
+		}
+		self.Mutex.Lock()
+		if self.version == self.ordered {
+			if len(self.Items) > 0 {
+				item, found = self.Items[0], true
+			}
+			self.Mutex.Unlock()
+			return
+		}
+		self.Mutex.Unlock()
+	}
+}
+
+// pop removes and returns the top item of the heap, with found false if it is empty.  If
+// the comparator fails the item is put back.
+func (self *Heap) pop(env *SymbolTableFrame) (item *Data, found bool, err error) {
+	for {
+		err = self.settle(env)
+		if err != nil {
+			return
+		}
+		self.Mutex.Lock()
+		if self.version != self.ordered {
+			// changed while it was being settled
+			self.Mutex.Unlock()
+			continue
+		}
+		n := len(self.Items) - 1
+		if n < 0 {
+			self.Mutex.Unlock()
+			return nil, false, nil
+		}
+		c := self.cursor(env)
+		item = self.Items[0]
+		self.Items[0] = self.Items[n]
+		self.Items[n] = nil
+		self.Items = self.Items[:n]
+		c.changed()
+		self.Mutex.Unlock()
+
+		c.down(0, n)
+		if c.err != nil {
+			self.Mutex.Lock()
+			self.Items = append(self.Items, item)
+			self.version++
+			self.Mutex.Unlock()
+			return nil, false, c.err
+		}
+		c.finish()
+		return item, true, nil
+	}
+}
+
+func RegisterHeapPrimitives() {
+	MakePrimitiveFunction("make-heap", "1|2", MakeHeapImpl)
+	MakePrimitiveFunction("heap?", "1", IsHeapImpl)
+	MakePrimitiveFunction("heap-push!", "2", HeapPushImpl)
+	MakePrimitiveFunction("heap-pop!", "1", HeapPopImpl)
+	MakePrimitiveFunction("heap-peek", "1", HeapPeekImpl)
+	MakePrimitiveFunction("heap-size", "1", HeapSizeImpl)
+	MakePrimitiveFunction("heap-empty?", "1", HeapEmptyImpl)
+	MakePrimitiveFunction("heap->list", "1", HeapToListImpl)
+}
+
+func heapArg(name string, args *Data, env *SymbolTableFrame) (h *Heap, err error) {
+	heapObj := Car(args)
+	if !ObjectP(heapObj) || ObjectType(heapObj) != "Heap" {
+		err = ProcessTypeError(fmt.Sprintf("%s expects a Heap object but received %s.", name, String(heapObj)), env)
+		return
+	}
+	h = (*Heap)(ObjectValue(heapObj))
+	return
+}
+
+func MakeHeapImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	less := Car(args)
+	if !FunctionOrPrimitiveP(less) {
+		err = ProcessTypeError(fmt.Sprintf("make-heap expects a comparison function but received %s.", String(less)), env)
+		return
+	}
+
+	h := &Heap{Compare: less, Items: make([]*Data, 0, 16)}
+
+	if Length(args) == 2 {
+		initial := Cadr(args)
+		if !ListP(initial) {
+			err = ProcessTypeError(fmt.Sprintf("make-heap expects a list of initial items but received %s.", String(initial)), env)
+			return
+		}
+		h.Items = append(h.Items, ToArray(initial)...)
+		h.version++
+		err = h.settle(env)
+		if err != nil {
+			return
+		}
+	}
+
+	return ObjectWithTypeAndValue("Heap", unsafe.Pointer(h)), nil
+}
+
+func IsHeapImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(ObjectP(Car(args)) && ObjectType(Car(args)) == "Heap"), nil
+}
+
+func HeapPushImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	h, err := heapArg("heap-push!", args, env)
+	if err != nil {
+		return
+	}
+
+	err = h.push(Cadr(args), env)
+	return Car(args), err
+}
+
+func HeapPopImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	h, err := heapArg("heap-pop!", args, env)
+	if err != nil {
+		return
+	}
+
+	result, found, err := h.pop(env)
+	if err == nil && !found {
+		err = ProcessError("heap-pop! can not pop from an empty heap.", env)
+	}
+	return
+}
+
+func HeapPeekImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	h, err := heapArg("heap-peek", args, env)
+	if err != nil {
+		return
+	}
+
+	result, found, err := h.peek(env)
+	if err == nil && !found {
+		err = ProcessError("heap-peek can not peek into an empty heap.", env)
+	}
+	return
+}
+
+func HeapSizeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	h, err := heapArg("heap-size", args, env)
+	if err != nil {
+		return
+	}
+
+	h.Mutex.Lock()
+	defer h.Mutex.Unlock()
+	return IntegerWithValue(int64(h.Len())), nil
+}
+
+func HeapEmptyImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	h, err := heapArg("heap-empty?", args, env)
+	if err != nil {
+		return
+	}
+
+	h.Mutex.Lock()
+	defer h.Mutex.Unlock()
+	return BooleanWithValue(h.Len() == 0), nil
+}
+
+// HeapToListImpl returns the items in priority order without disturbing the heap
+func HeapToListImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	h, err := heapArg("heap->list", args, env)
+	if err != nil {
+		return
+	}
+
+	h.Mutex.Lock()
+	items := make([]*Data, len(h.Items))
+	copy(items, h.Items)
+	h.Mutex.Unlock()
+
+	sorted, err := StableSort(items, h.Compare, nil, env)
+	if err != nil {
+		return
+	}
+	return ArrayToList(sorted), nil
+}
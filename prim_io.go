@@ -8,12 +8,13 @@
 package golisp
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"strconv"
-	"strings"
-	"unicode"
+	"time"
+	"unsafe"
 )
 
 func RegisterIOPrimitives() {
@@ -21,10 +22,18 @@ func RegisterIOPrimitives() {
 	MakeRestrictedPrimitiveFunction("open-output-file", "1|2", OpenOutputFileImpl)
 	MakeRestrictedPrimitiveFunction("close-port", "1", ClosePortImpl)
 	MakeRestrictedPrimitiveFunction("write-bytes", "2", WriteBytesImpl)
+	MakeRestrictedPrimitiveFunction("read-bytes", "2", ReadBytesImpl)
+	MakeRestrictedPrimitiveFunction("open-tcp-port", "1|2", OpenTCPPortImpl)
+	MakePrimitiveFunction("set-port-timeout!", "2", SetPortTimeoutImpl)
+	MakePrimitiveFunction("port-timeout", "1", PortTimeoutImpl)
 
 	MakePrimitiveFunction("write-string", "1|2", WriteStringImpl)
 	MakePrimitiveFunction("newline", "0|1", NewlineImpl)
 	MakePrimitiveFunction("write", "1|2", WriteImpl)
+	MakePrimitiveFunction("write-shared", "1|2", WriteSharedImpl)
+	MakePrimitiveFunction("display", "1|2", DisplayImpl)
+	MakeSpecialForm("with-output-to-string", "*", WithOutputToStringImpl)
+	MakeSpecialForm("with-output-to-port", ">=1", WithOutputToPortImpl)
 	MakePrimitiveFunction("read", "1", ReadImpl)
 	MakePrimitiveFunction("eof-object?", "1", EofObjectImpl)
 
@@ -36,7 +45,7 @@ func RegisterIOPrimitives() {
 func OpenOutputFileImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	filename := Car(args)
 	if !StringP(filename) {
-		err = ProcessError("open-output-port expects its argument to be a string", env)
+		err = ProcessTypeError("open-output-port expects its argument to be a string", env)
 		return
 	}
 
@@ -47,6 +56,7 @@ func OpenOutputFileImpl(args *Data, env *SymbolTableFrame) (result *Data, err er
 
 	f, err := os.OpenFile(StringValue(filename), openFlag, 0666)
 	if err != nil {
+		err = ioError(err)
 		return
 	}
 	return PortWithValue(f), nil
@@ -55,12 +65,13 @@ func OpenOutputFileImpl(args *Data, env *SymbolTableFrame) (result *Data, err er
 func OpenInputFileImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	filename := Car(args)
 	if !StringP(filename) {
-		err = ProcessError("open-input-port expects its argument to be a string", env)
+		err = ProcessTypeError("open-input-port expects its argument to be a string", env)
 		return
 	}
 
 	f, err := os.Open(StringValue(filename))
 	if err != nil {
+		err = ioError(err)
 		return
 	}
 	return PortWithValue(f), nil
@@ -69,106 +80,253 @@ func OpenInputFileImpl(args *Data, env *SymbolTableFrame) (result *Data, err err
 func ClosePortImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	p := Car(args)
 	if !PortP(p) {
-		err = ProcessError("close-port expects its argument be a port", env)
+		err = ProcessTypeError("close-port expects its argument be a port", env)
 		return
 	}
 
-	(*os.File)(PortValue(p)).Close()
+	PortValue(p).Close()
 	return
 
 }
 
 func WriteBytesImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	bytes := Car(args)
-	if !ObjectP(bytes) || ObjectType(bytes) != "[]byte" {
-		err = ProcessError("write expects its first argument to be a bytearray", env)
+	bytes, ok := byteSlice(Car(args))
+	if !ok {
+		err = ProcessTypeError("write expects its first argument to be a bytearray or bytevector", env)
 		return
 	}
 
 	p := Cadr(args)
 	if !PortP(p) {
-		err = ProcessError("write expects its second argument be a port", env)
+		err = ProcessTypeError("write expects its second argument be a port", env)
 		return
 	}
 
-	_, err = (*os.File)(PortValue(p)).Write(*(*[]byte)(ObjectValue(bytes)))
+	_, err = PortValue(p).Write(bytes)
 	return
 }
 
+// ReadBytesImpl handles (read-bytes port count), returning a bytearray of count bytes, or
+// fewer if the port ends first, or the eof object if it already has
+func ReadBytesImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	p := Car(args)
+	if !PortP(p) {
+		err = ProcessTypeError("read-bytes expects its first argument be a port", env)
+		return
+	}
+	count := Cadr(args)
+	if !IntegerP(count) || IntegerValue(count) < 0 {
+		err = ProcessTypeError(fmt.Sprintf("read-bytes expects a count of bytes but received %s.", String(count)), env)
+		return
+	}
+
+	bytes, readErr := PortValue(p).ReadBytes(int(IntegerValue(count)))
+	if readErr == io.EOF {
+		return EofObject, nil
+	} else if readErr != nil {
+		err = ioError(readErr)
+		return
+	}
+	return ObjectWithTypeAndValue("[]byte", unsafe.Pointer(&bytes)), nil
+}
+
+// millisecondsArg returns a non-negative number of milliseconds as a duration
+func millisecondsArg(name string, d *Data, env *SymbolTableFrame) (duration time.Duration, err error) {
+	if !IntegerP(d) || IntegerValue(d) < 0 {
+		err = ProcessTypeError(fmt.Sprintf("%s expects a timeout in milliseconds but received %s.", name, String(d)), env)
+		return
+	}
+	return time.Duration(IntegerValue(d)) * time.Millisecond, nil
+}
+
+// OpenTCPPortImpl handles (open-tcp-port address [timeout-millis]), where the timeout applies
+// to connecting and to each read and write after that
+func OpenTCPPortImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	address := Car(args)
+	if !StringP(address) {
+		err = ProcessTypeError("open-tcp-port expects its first argument to be a string", env)
+		return
+	}
+	var timeout time.Duration
+	if Length(args) == 2 {
+		if timeout, err = millisecondsArg("open-tcp-port", Cadr(args), env); err != nil {
+			return
+		}
+	}
+
+	port, err := OpenTCPPort(StringValue(address), timeout)
+	if err != nil {
+		return
+	}
+	return PortWithPort(port), nil
+}
+
+func SetPortTimeoutImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	p := Car(args)
+	if !PortP(p) {
+		err = ProcessTypeError("set-port-timeout! expects its first argument be a port", env)
+		return
+	}
+	timeout, err := millisecondsArg("set-port-timeout!", Cadr(args), env)
+	if err != nil {
+		return
+	}
+	if err = PortValue(p).SetTimeout(timeout); err != nil {
+		return
+	}
+	return p, nil
+}
+
+func PortTimeoutImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	p := Car(args)
+	if !PortP(p) {
+		err = ProcessTypeError("port-timeout expects its argument be a port", env)
+		return
+	}
+	return IntegerWithValue(int64(PortValue(p).Timeout() / time.Millisecond)), nil
+}
+
 func WriteStringImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	str := Car(args)
 	if !StringP(str) {
-		err = ProcessError("write-string expects its first argument to be a string", env)
+		err = ProcessTypeError("write-string expects its first argument to be a string", env)
 		return
 	}
 
-	var port *os.File
+	var port io.Writer
 	if Length(args) == 1 {
-		port = os.Stdout
+		port = currentOutput(env)
 	} else {
 		p := Cadr(args)
 		if !PortP(p) {
-			err = ProcessError("write-string expects its second argument be a port", env)
+			err = ProcessTypeError("write-string expects its second argument be a port", env)
 			return
 		}
 		port = PortValue(p)
 	}
 
-	_, err = port.WriteString(StringValue(str))
+	_, err = io.WriteString(port, StringValue(str))
 	return
 }
 
 func WriteImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	var port *os.File
+	var port io.Writer
+
+	if Length(args) == 1 {
+		port = currentOutput(env)
+	} else {
+		p := Cadr(args)
+		if !PortP(p) {
+			err = ProcessTypeError("write expects its second argument be a port", env)
+			return
+		}
+		port = PortValue(p)
+	}
+
+	_, err = io.WriteString(port, String(Car(args)))
+	return
+}
+
+func WriteSharedImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	var port io.Writer
+
+	if Length(args) == 1 {
+		port = currentOutput(env)
+	} else {
+		p := Cadr(args)
+		if !PortP(p) {
+			err = ProcessTypeError("write-shared expects its second argument be a port", env)
+			return
+		}
+		port = PortValue(p)
+	}
+
+	_, err = io.WriteString(port, SharedString(Car(args)))
+	return
+}
+
+func DisplayImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	var port io.Writer
 
 	if Length(args) == 1 {
-		port = os.Stdout
+		port = currentOutput(env)
 	} else {
 		p := Cadr(args)
 		if !PortP(p) {
-			err = ProcessError("write expects its second argument be a port", env)
+			err = ProcessTypeError("display expects its second argument be a port", env)
 			return
 		}
 		port = PortValue(p)
 	}
 
-	_, err = port.WriteString(String(Car(args)))
+	_, err = io.WriteString(port, PrintString(Car(args)))
 	return
 }
 
 func NewlineImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	var port *os.File
+	var port io.Writer
 
 	if Length(args) == 0 {
-		port = os.Stdout
+		port = currentOutput(env)
 	} else {
 		p := Car(args)
 		if !PortP(p) {
-			err = ProcessError("newline expects its argument be a port", env)
+			err = ProcessTypeError("newline expects its argument be a port", env)
 			return
 		}
 		port = PortValue(p)
 	}
 
-	_, err = port.WriteString("\n")
+	_, err = io.WriteString(port, "\n")
 	return
 }
 
+// withOutputTo evaluates body with printing that would go to the current output going to w
+func withOutputTo(w io.Writer, body *Data, env *SymbolTableFrame) (result *Data, err error) {
+	localEnv := NewSymbolTableFrameBelow(env, "with-output")
+	if err = localEnv.callFrom(env); err != nil {
+		return
+	}
+	localEnv.output = w
+	return evaluateBody(body, localEnv)
+}
+
+func WithOutputToStringImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	var output bytes.Buffer
+	_, err = withOutputTo(&output, args, env)
+	if err != nil {
+		return
+	}
+	return StringWithValue(output.String()), nil
+}
+
+func WithOutputToPortImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	p, err := Eval(Car(args), env)
+	if err != nil {
+		return
+	}
+	if !PortP(p) {
+		err = ProcessTypeError(fmt.Sprintf("with-output-to-port expects a port but received %s.", String(p)), env)
+		return
+	}
+	return withOutputTo(PortValue(p), Cdr(args), env)
+}
+
 func ReadImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	var port *os.File
+	var tokenizer *Tokenizer
 
 	if Length(args) == 0 {
-		port = os.Stdin
+		tokenizer = NewTokenizerFromFile(os.Stdin)
 	} else {
 		p := Car(args)
 		if !PortP(p) {
-			err = ProcessError("read expects its argument be a port", env)
+			err = ProcessTypeError("read expects its argument be a port", env)
 			return
 		}
-		port = PortValue(p)
+		tokenizer = PortValue(p).Tokenizer()
 	}
 
-	result, err = ParseObjectFromFileInEnv(port, env)
+	result, err = parseObjectFromTokenizer(tokenizer)
 	return
 }
 
@@ -196,156 +354,36 @@ func ListDirectoryImpl(args *Data, env *SymbolTableFrame) (result *Data, err err
 	return ArrayToList(names), nil
 }
 
+// FormatImpl handles (format destination control-string arg...), writing to the current output
+// port if destination is #t, to destination if it's a port, or returning a string if it's #f
 func FormatImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	destination := Car(args)
 	if !BooleanP(destination) && !PortP(destination) {
-		err = ProcessError(fmt.Sprintf("format expects its second argument be a boolean or port, but was %s", String(destination)), env)
+		err = ProcessTypeError(fmt.Sprintf("format expects its second argument be a boolean or port, but was %s", String(destination)), env)
 		return
 	}
 
 	controlStringObj := Cadr(args)
 	if !StringP(controlStringObj) {
-		err = ProcessError("format expects its second argument be a string", env)
+		err = ProcessTypeError("format expects its second argument be a string", env)
 		return
 	}
-	controlString := StringValue(controlStringObj)
-
-	arguments := Cddr(args)
-
-	numberOfSubstitutions := strings.Count(controlString, "~")
-	parts := make([]string, 0, numberOfSubstitutions*2+1)
-	start := 0
-	var i int
-	var numericArg int
-	var atModifier bool
-	var substitution string
-	var padding string
-	var n int64
-
-	for i < len(controlString) {
-		if controlString[i] == '~' { // start of a substitution
-			parts = append(parts, controlString[start:i])
-			i++
-			start = i
-			for unicode.IsDigit(rune(controlString[i])) {
-				i++
-			}
-			if i == start {
-				if controlString[i] == '#' {
-					numericArg = Length(arguments)
-					i++
-				} else if controlString[i] == 'V' || controlString[i] == 'v' {
-					if IntegerP(Car(arguments)) {
-						numericArg = int(IntegerValue(Car(arguments)))
-						arguments = Cdr(arguments)
-					} else {
-						err = ProcessError(fmt.Sprintf("format encountered a size argument mismatch at index %d", i), env)
-						return
-					}
-					i++
-				} else {
-					numericArg = 0
-				}
-			} else {
-				n, err = strconv.ParseInt(string(controlString[start:i]), 10, 64)
-				if err != nil {
-					return
-				}
-				numericArg = int(n)
-			}
-			if controlString[i] == '@' {
-				atModifier = true
-				i++
-			}
-			switch controlString[i] {
-			case 'A', 'a':
-				substitution = PrintString(Car(arguments))
-				if len(substitution) < numericArg {
-					padding = strings.Repeat(" ", numericArg-len(substitution))
-				} else {
-					padding = ""
-				}
-				if atModifier {
-					parts = append(parts, padding)
-				}
-				parts = append(parts, substitution)
-				if !atModifier {
-					parts = append(parts, padding)
-				}
-				arguments = Cdr(arguments)
-				start = i + 1
-
-			case 'S', 's':
-				substitution = String(Car(arguments))
-				if len(substitution) < numericArg {
-					padding = strings.Repeat(" ", numericArg-len(substitution))
-				} else {
-					padding = ""
-				}
-				if atModifier {
-					parts = append(parts, padding)
-				}
-				parts = append(parts, substitution)
-				if !atModifier {
-					parts = append(parts, padding)
-				}
-				arguments = Cdr(arguments)
-				start = i + 1
-
-			case '%':
-				if numericArg > 0 {
-					parts = append(parts, strings.Repeat("\n", numericArg))
-				} else {
-					parts = append(parts, "\n")
-				}
-				start = i + 1
-
-			case '~':
-				if numericArg > 0 {
-					parts = append(parts, strings.Repeat("~", numericArg))
-				} else {
-					parts = append(parts, "~")
-				}
-				start = i + 1
-
-			case '\n':
-				for i < len(controlString) && unicode.IsSpace(rune(controlString[i])) {
-					i++
-				}
-				if atModifier {
-					parts = append(parts, "\n")
-				}
-				start = i
-				i--
-
-			default:
-				err = ProcessError(fmt.Sprintf("format encountered an unsupported substitution at index %d", i), env)
-				return
-			}
-		}
-		i++
-	}
-
-	if start < len(controlString) {
-		parts = append(parts, controlString[start:i])
-	}
-
-	if i < len(controlString) || !NilP(arguments) {
-		err = ProcessError("number of replacements in the control string and number of arguments must be equal", env)
+	combinedString, err := Format(StringValue(controlStringObj), ToArray(Cddr(args)), env)
+	if err != nil {
 		return
 	}
 
-	combinedString := strings.Join(parts, "")
-
 	if PortP(destination) {
-		port := PortValue(destination)
-		_, err = port.WriteString(combinedString)
+		_, err = io.WriteString(PortValue(destination), combinedString)
 	} else if BooleanValue(destination) {
+		output := currentOutput(env)
 		// Make sure Stdout exists before writing to it, prevents issues with LDFLAGS="-H windowsgui"
-		stat, statErr := os.Stdout.Stat()
-		if stat != nil && statErr == nil {
-			_, err = os.Stdout.WriteString(combinedString)
+		if file, isFile := output.(*os.File); isFile {
+			if stat, statErr := file.Stat(); stat == nil || statErr != nil {
+				return
+			}
 		}
+		_, err = io.WriteString(output, combinedString)
 	} else {
 		result = StringWithValue(combinedString)
 	}
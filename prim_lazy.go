@@ -0,0 +1,214 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains memoized lazy sequences and their primitive functions.
+
+package golisp
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+// LazySeq is a sequence whose contents are computed the first time they are needed and
+// then cached.  Once realized, its value is either nil (the sequence is empty) or a cons
+// cell holding the first element and the rest of the sequence, which may be another lazy
+// seq or an ordinary list.
+type LazySeq struct {
+	Mutex    sync.Mutex
+	realize  func() (*Data, error)
+	realized bool
+	value    *Data
+}
+
+func RegisterLazyPrimitives() {
+	MakeSpecialForm("lazy-seq", "*", LazySeqImpl)
+	MakePrimitiveFunction("lazy-seq?", "1", IsLazySeqImpl)
+	MakePrimitiveFunction("realized?", "1", RealizedImpl)
+	MakePrimitiveFunction("lazy-map", "2", LazyMapImpl)
+	MakePrimitiveFunction("lazy-filter", "2", LazyFilterImpl)
+	MakePrimitiveFunction("doall", "1", DoallImpl)
+}
+
+func LazySeqWithFunction(realize func() (*Data, error)) *Data {
+	return ObjectWithTypeAndValue("LazySeq", unsafe.Pointer(&LazySeq{realize: realize}))
+}
+
+func LazySeqP(d *Data) bool {
+	return ObjectP(d) && ObjectType(d) == "LazySeq"
+}
+
+func LazySeqValue(d *Data) *LazySeq {
+	if !LazySeqP(d) {
+		return nil
+	}
+	return (*LazySeq)(ObjectValue(d))
+}
+
+// Value realizes the sequence if needed.  A failed realization isn't cached, so it will be
+// attempted again the next time the sequence is used.
+func (self *LazySeq) Value() (value *Data, err error) {
+	self.Mutex.Lock()
+	defer self.Mutex.Unlock()
+
+	if self.realized {
+		return self.value, nil
+	}
+
+	value, err = self.realize()
+	if err != nil {
+		return
+	}
+	if LazySeqP(value) {
+		value, err = LazySeqValue(value).Value()
+		if err != nil {
+			return
+		}
+	}
+
+	self.value = value
+	self.realized = true
+	self.realize = nil
+	return
+}
+
+// SeqStep splits a list or lazy seq into its first element and the rest of it, realizing
+// just as much as is needed to do so.
+func SeqStep(seq *Data, env *SymbolTableFrame) (first *Data, rest *Data, empty bool, err error) {
+	if LazySeqP(seq) {
+		seq, err = LazySeqValue(seq).Value()
+		if err != nil {
+			return
+		}
+		if NotNilP(seq) && !PairP(seq) {
+			err = ProcessError(fmt.Sprintf("lazy-seq must produce a list or another lazy-seq, but produced %s.", String(seq)), env)
+			return
+		}
+	} else if !ListP(seq) {
+		err = ProcessTypeError(fmt.Sprintf("Expected a list or lazy-seq, but received %s.", String(seq)), env)
+		return
+	}
+
+	if NilP(seq) {
+		return nil, nil, true, nil
+	}
+	return Car(seq), Cdr(seq), false, nil
+}
+
+// takeSeq realizes up to n elements of seq (all of them if n is negative)
+func takeSeq(n int, seq *Data, env *SymbolTableFrame) (result *Data, err error) {
+	items := make([]*Data, 0, 16)
+	for n < 0 || len(items) < n {
+		var first *Data
+		var empty bool
+		first, seq, empty, err = SeqStep(seq, env)
+		if err != nil {
+			return
+		}
+		if empty {
+			break
+		}
+		items = append(items, first)
+	}
+	return ArrayToList(items), nil
+}
+
+func LazySeqImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	body := args
+	return LazySeqWithFunction(func() (*Data, error) {
+		return BeginImpl(body, env)
+	}), nil
+}
+
+func IsLazySeqImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(LazySeqP(Car(args))), nil
+}
+
+func RealizedImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	seq := Car(args)
+	if !LazySeqP(seq) {
+		err = ProcessTypeError(fmt.Sprintf("realized? expects a lazy-seq but received %s.", String(seq)), env)
+		return
+	}
+
+	s := LazySeqValue(seq)
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	return BooleanWithValue(s.realized), nil
+}
+
+func lazyMap(f *Data, seq *Data, env *SymbolTableFrame) *Data {
+	return LazySeqWithFunction(func() (*Data, error) {
+		first, rest, empty, err := SeqStep(seq, env)
+		if err != nil || empty {
+			return nil, err
+		}
+		value, err := ApplyWithoutEval(f, InternalMakeList(first), env)
+		if err != nil {
+			return nil, err
+		}
+		return Cons(value, lazyMap(f, rest, env)), nil
+	})
+}
+
+func lazyFilter(f *Data, seq *Data, env *SymbolTableFrame) *Data {
+	return LazySeqWithFunction(func() (*Data, error) {
+		for {
+			first, rest, empty, err := SeqStep(seq, env)
+			if err != nil || empty {
+				return nil, err
+			}
+			keep, err := ApplyWithoutEval(f, InternalMakeList(first), env)
+			if err != nil {
+				return nil, err
+			}
+			if BooleanValue(keep) {
+				return Cons(first, lazyFilter(f, rest, env)), nil
+			}
+			seq = rest
+		}
+	})
+}
+
+func lazySeqArgs(name string, args *Data, env *SymbolTableFrame) (f *Data, seq *Data, err error) {
+	f = Car(args)
+	if !FunctionOrPrimitiveP(f) {
+		err = ProcessTypeError(fmt.Sprintf("%s expects a function as its first argument but received %s.", name, String(f)), env)
+		return
+	}
+
+	seq = Cadr(args)
+	if !LazySeqP(seq) && !ListP(seq) {
+		err = ProcessTypeError(fmt.Sprintf("%s expects a list or lazy-seq as its second argument but received %s.", name, String(seq)), env)
+	}
+	return
+}
+
+func LazyMapImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	f, seq, err := lazySeqArgs("lazy-map", args, env)
+	if err != nil {
+		return
+	}
+	return lazyMap(f, seq, env), nil
+}
+
+func LazyFilterImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	f, seq, err := lazySeqArgs("lazy-filter", args, env)
+	if err != nil {
+		return
+	}
+	return lazyFilter(f, seq, env), nil
+}
+
+// DoallImpl realizes an entire (finite) lazy seq and returns its elements as a list
+func DoallImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	seq := Car(args)
+	if !LazySeqP(seq) && !ListP(seq) {
+		err = ProcessTypeError(fmt.Sprintf("doall expects a list or lazy-seq but received %s.", String(seq)), env)
+		return
+	}
+	return takeSeq(-1, seq, env)
+}
@@ -0,0 +1,136 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the lexer primitive functions.
+
+package golisp
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// ScriptLexer is a Lexer made by make-lexer, along with the functions that convert the text
+// of its rules' tokens to values
+type ScriptLexer struct {
+	Lexer
+	converters map[*LexerRule]*Data
+}
+
+func RegisterLexerPrimitives() {
+	MakePrimitiveFunction("make-lexer", "1", MakeLexerImpl)
+	MakePrimitiveFunction("lexer?", "1", IsLexerImpl)
+	MakePrimitiveFunction("lex", "2", LexImpl)
+}
+
+func LexerWithValue(lexer *ScriptLexer) *Data {
+	return ObjectWithTypeAndValue("Lexer", unsafe.Pointer(lexer))
+}
+
+func LexerP(d *Data) bool {
+	return ObjectP(d) && ObjectType(d) == "Lexer"
+}
+
+func LexerValue(d *Data) *ScriptLexer {
+	if !LexerP(d) {
+		return nil
+	}
+	return (*ScriptLexer)(ObjectValue(d))
+}
+
+// MakeLexerImpl makes a lexer from a list of rules, each of the form (regex type) or
+// (regex type converter).  Text matching a rule whose type is #f or nil is skipped; the
+// converter, if given, is applied to the text of each of the rule's tokens to get its value.
+func MakeLexerImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	rules := Car(args)
+	if !ListP(rules) {
+		err = ProcessTypeError(fmt.Sprintf("make-lexer requires a list of rules but was given %s.", String(rules)), env)
+		return
+	}
+
+	lexer := &ScriptLexer{converters: make(map[*LexerRule]*Data)}
+	for c := rules; NotNilP(c); c = Cdr(c) {
+		rule := Car(c)
+		pattern, tokenType, converter := First(rule), Second(rule), Third(rule)
+		if !ListP(rule) || !StringP(pattern) {
+			err = ProcessTypeError(fmt.Sprintf("make-lexer rules must be (regex type [converter]) but was given %s.", String(rule)), env)
+			return
+		}
+		typeName := ""
+		if SymbolP(tokenType) {
+			typeName = StringValue(tokenType)
+		} else if !NilP(tokenType) && !(BooleanP(tokenType) && !BooleanValue(tokenType)) {
+			err = ProcessTypeError(fmt.Sprintf("make-lexer requires a token type to be a symbol or #f but was given %s.", String(tokenType)), env)
+			return
+		}
+		if err = lexer.AddRule(StringValue(pattern), typeName); err != nil {
+			err = ProcessError(fmt.Sprintf("make-lexer got a bad regex %s: %s", String(pattern), err), env)
+			return
+		}
+		if NotNilP(converter) {
+			if !FunctionOrPrimitiveP(converter) {
+				err = ProcessTypeError(fmt.Sprintf("make-lexer requires a token converter to be a function but was given %s.", String(converter)), env)
+				return
+			}
+			lexer.converters[lexer.Rules[len(lexer.Rules)-1]] = converter
+		}
+	}
+	return LexerWithValue(lexer), nil
+}
+
+func IsLexerImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(LexerP(Car(args))), nil
+}
+
+// tokenFrame describes token for lisp code, with its text converted to a value if its rule
+// has a converter
+func (self *ScriptLexer) tokenFrame(token Token, env *SymbolTableFrame) (result *Data, err error) {
+	value := StringWithValue(token.Text)
+	if converter, found := self.converters[token.Rule]; found {
+		value, err = ApplyWithoutEval(converter, InternalMakeList(value), env)
+		if err != nil {
+			return
+		}
+	}
+	m := FrameMap{}
+	m.Data = FrameMapData{
+		"type:":   Intern(token.Type),
+		"text:":   StringWithValue(token.Text),
+		"value:":  value,
+		"offset:": IntegerWithValue(int64(token.Offset)),
+		"line:":   IntegerWithValue(int64(token.Line)),
+		"column:": IntegerWithValue(int64(token.Column)),
+	}
+	return FrameWithValue(&m), nil
+}
+
+// LexImpl returns the tokens of a string as a list of frames with type:, text:, value:,
+// offset:, line:, and column: slots
+func LexImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if !LexerP(Car(args)) {
+		err = ProcessTypeError(fmt.Sprintf("lex requires a lexer but was given %s.", String(Car(args))), env)
+		return
+	}
+	lexer := LexerValue(Car(args))
+	if !StringP(Cadr(args)) {
+		err = ProcessTypeError(fmt.Sprintf("lex requires a string but was given %s.", String(Cadr(args))), env)
+		return
+	}
+
+	tokens, err := lexer.Tokenize(StringValue(Cadr(args)))
+	if err != nil {
+		return
+	}
+	frames := make([]*Data, 0, len(tokens))
+	for _, token := range tokens {
+		var frame *Data
+		frame, err = lexer.tokenFrame(token, env)
+		if err != nil {
+			return
+		}
+		frames = append(frames, frame)
+	}
+	return ArrayToList(frames), nil
+}
@@ -255,12 +255,12 @@ func TenthImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func NthImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	col := Car(args)
 	if !PairP(col) {
-		err = ProcessError("First arg to nth must be a list", env)
+		err = ProcessTypeError("First arg to nth must be a list", env)
 		return
 	}
 	count := Cadr(args)
 	if !IntegerP(count) {
-		err = ProcessError("Second arg to nth must be a number", env)
+		err = ProcessTypeError("Second arg to nth must be a number", env)
 		return
 	}
 
@@ -270,7 +270,7 @@ func NthImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func TakeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	n := Car(args)
 	if !IntegerP(n) {
-		err = ProcessError("take requires a number as its first argument.", env)
+		err = ProcessTypeError("take requires a number as its first argument.", env)
 	}
 	size := int(IntegerValue(n))
 
@@ -294,8 +294,10 @@ func TakeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 			newBytes[i] = v
 		}
 		result = ObjectWithTypeAndValue("[]byte", unsafe.Pointer(&newBytes))
+	} else if LazySeqP(l) {
+		result, err = takeSeq(size, l, env)
 	} else {
-		err = ProcessError("take requires a list or bytearray as its second argument.", env)
+		err = ProcessTypeError("take requires a list, bytearray, or lazy-seq as its second argument.", env)
 	}
 	return
 }
@@ -303,7 +305,7 @@ func TakeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func DropImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	n := Car(args)
 	if !IntegerP(n) {
-		err = ProcessError("drop requires a number as its first argument.", env)
+		err = ProcessTypeError("drop requires a number as its first argument.", env)
 	}
 	size := int(IntegerValue(n))
 
@@ -328,7 +330,7 @@ func DropImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 			result = ObjectWithTypeAndValue("[]byte", unsafe.Pointer(&newBytes))
 		}
 	} else {
-		err = ProcessError("drop requires a list or bytearray as its second argument.", env)
+		err = ProcessTypeError("drop requires a list or bytearray as its second argument.", env)
 	}
 	return
 }
@@ -336,12 +338,12 @@ func DropImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func ListRefImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	col := Car(args)
 	if !PairP(col) {
-		err = ProcessError("First arg to list-ref must be a list", env)
+		err = ProcessTypeError("First arg to list-ref must be a list", env)
 		return
 	}
 	count := Cadr(args)
 	if !IntegerP(count) {
-		err = ProcessError("Second arg to list-ref must be a number", env)
+		err = ProcessTypeError("Second arg to list-ref must be a number", env)
 		return
 	}
 
@@ -351,7 +353,7 @@ func ListRefImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func ListHeadImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	n := Cadr(args)
 	if !IntegerP(n) {
-		err = ProcessError("list-head requires a number as its second argument.", env)
+		err = ProcessTypeError("list-head requires a number as its second argument.", env)
 	}
 	size := int(IntegerValue(n))
 
@@ -363,7 +365,7 @@ func ListHeadImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 		}
 		result = ArrayToList(items)
 	} else {
-		err = ProcessError("list-head requires a list as its first argument.", env)
+		err = ProcessTypeError("list-head requires a list as its first argument.", env)
 	}
 	return
 }
@@ -371,7 +373,7 @@ func ListHeadImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func ListTailImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	n := Cadr(args)
 	if !IntegerP(n) {
-		err = ProcessError("list-tail requires a number as its second argument.", env)
+		err = ProcessTypeError("list-tail requires a number as its second argument.", env)
 	}
 	size := int(IntegerValue(n))
 
@@ -384,7 +386,7 @@ func ListTailImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 		}
 		result = cell
 	} else {
-		err = ProcessError("list-tail requires a list or bytearray as its first argument.", env)
+		err = ProcessTypeError("list-tail requires a list or bytearray as its first argument.", env)
 	}
 	return
 }
@@ -393,12 +395,12 @@ func LastPairImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	l := Car(args)
 
 	if NilP(l) {
-		err = ProcessError("last-pair requires a non-empty list as its argument.", env)
+		err = ProcessTypeError("last-pair requires a non-empty list as its argument.", env)
 		return
 	}
 
 	if !ListP(l) {
-		err = ProcessError("last-pair requires a list as its argument.", env)
+		err = ProcessTypeError("last-pair requires a list as its argument.", env)
 		return
 	}
 
@@ -39,7 +39,7 @@ func intMin(x, y int64) int64 {
 func MapImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	f := First(args)
 	if !FunctionOrPrimitiveP(f) {
-		err = ProcessError(fmt.Sprintf("map needs a function as its first argument, but got %s.", String(f)), env)
+		err = ProcessTypeError(fmt.Sprintf("map needs a function as its first argument, but got %s.", String(f)), env)
 		return
 	}
 
@@ -49,7 +49,7 @@ func MapImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	for a := Cdr(args); NotNilP(a); a = Cdr(a) {
 		col = Car(a)
 		if !ListP(col) {
-			err = ProcessError(fmt.Sprintf("map needs lists as its other arguments, but got %s.", String(col)), env)
+			err = ProcessTypeError(fmt.Sprintf("map needs lists as its other arguments, but got %s.", String(col)), env)
 			return
 		}
 		if NilP(col) || col == nil {
@@ -85,7 +85,7 @@ func MapImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func ForEachImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	f := First(args)
 	if !FunctionOrPrimitiveP(f) {
-		err = ProcessError(fmt.Sprintf("foreach needs a function as its first argument, but got %s.", String(f)), env)
+		err = ProcessTypeError(fmt.Sprintf("foreach needs a function as its first argument, but got %s.", String(f)), env)
 		return
 	}
 
@@ -95,7 +95,7 @@ func ForEachImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	for a := Cdr(args); NotNilP(a); a = Cdr(a) {
 		col = Car(a)
 		if !ListP(col) {
-			err = ProcessError(fmt.Sprintf("foreach needs lists as its other arguments, but got %s.", String(col)), env)
+			err = ProcessTypeError(fmt.Sprintf("foreach needs lists as its other arguments, but got %s.", String(col)), env)
 			return
 		}
 		collections = append(collections, col)
@@ -125,7 +125,7 @@ func ForEachImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func AnyImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	f := First(args)
 	if !FunctionOrPrimitiveP(f) {
-		err = ProcessError(fmt.Sprintf("any needs a function as its first argument, but got %s.", String(f)), env)
+		err = ProcessTypeError(fmt.Sprintf("any needs a function as its first argument, but got %s.", String(f)), env)
 		return
 	}
 
@@ -135,7 +135,7 @@ func AnyImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	for a := Cdr(args); NotNilP(a); a = Cdr(a) {
 		col = Car(a)
 		if !ListP(col) {
-			err = ProcessError(fmt.Sprintf("any needs lists as its other arguments, but got %s.", String(col)), env)
+			err = ProcessTypeError(fmt.Sprintf("any needs lists as its other arguments, but got %s.", String(col)), env)
 			return
 		}
 		collections = append(collections, col)
@@ -170,7 +170,7 @@ func AnyImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func EveryImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	f := First(args)
 	if !FunctionOrPrimitiveP(f) {
-		err = ProcessError(fmt.Sprintf("every needs a function as its first argument, but got %s.", String(f)), env)
+		err = ProcessTypeError(fmt.Sprintf("every needs a function as its first argument, but got %s.", String(f)), env)
 		return
 	}
 
@@ -180,7 +180,7 @@ func EveryImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	for a := Cdr(args); NotNilP(a); a = Cdr(a) {
 		col = Car(a)
 		if !ListP(col) {
-			err = ProcessError(fmt.Sprintf("every needs lists as its other arguments, but got %s.", String(col)), env)
+			err = ProcessTypeError(fmt.Sprintf("every needs lists as its other arguments, but got %s.", String(col)), env)
 			return
 		}
 		collections = append(collections, col)
@@ -215,7 +215,7 @@ func EveryImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func ReduceImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	f := First(args)
 	if !FunctionOrPrimitiveP(f) {
-		err = ProcessError("reduce needs a function as its first argument", env)
+		err = ProcessTypeError("reduce needs a function as its first argument", env)
 		return
 	}
 
@@ -223,7 +223,7 @@ func ReduceImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	col := Third(args)
 
 	if !ListP(col) {
-		err = ProcessError("map needs a list as its third argument", env)
+		err = ProcessTypeError("map needs a list as its third argument", env)
 		return
 	}
 
@@ -249,13 +249,13 @@ func ReduceImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func FilterImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	f := First(args)
 	if !FunctionOrPrimitiveP(f) {
-		err = ProcessError(fmt.Sprintf("filter needs a function as its first argument, but got %s.", String(f)), env)
+		err = ProcessTypeError(fmt.Sprintf("filter needs a function as its first argument, but got %s.", String(f)), env)
 		return
 	}
 
 	col := Second(args)
 	if !ListP(col) {
-		err = ProcessError(fmt.Sprintf("filter needs a list as its second argument, but got %s.", String(col)), env)
+		err = ProcessTypeError(fmt.Sprintf("filter needs a list as its second argument, but got %s.", String(col)), env)
 		return
 	}
 
@@ -267,7 +267,7 @@ func FilterImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 			return
 		}
 		if !BooleanP(v) {
-			err = ProcessError("filter needs a predicate function as its first argument.", env)
+			err = ProcessTypeError("filter needs a predicate function as its first argument.", env)
 			return
 		}
 
@@ -282,13 +282,13 @@ func FilterImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func RemoveImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	f := First(args)
 	if !FunctionOrPrimitiveP(f) {
-		err = ProcessError(fmt.Sprintf("remove needs a function as its first argument, but got %s.", String(f)), env)
+		err = ProcessTypeError(fmt.Sprintf("remove needs a function as its first argument, but got %s.", String(f)), env)
 		return
 	}
 
 	col := Second(args)
 	if !ListP(col) {
-		err = ProcessError(fmt.Sprintf("remove needs a list as its second argument, but got %s.", String(col)), env)
+		err = ProcessTypeError(fmt.Sprintf("remove needs a list as its second argument, but got %s.", String(col)), env)
 		return
 	}
 
@@ -300,7 +300,7 @@ func RemoveImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 			return
 		}
 		if !BooleanP(v) {
-			err = ProcessError("remove needs a predicate function as its first argument.", env)
+			err = ProcessTypeError("remove needs a predicate function as its first argument.", env)
 			return
 		}
 
@@ -329,13 +329,13 @@ func MemqImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func FindTailImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	f := First(args)
 	if !FunctionOrPrimitiveP(f) {
-		err = ProcessError("find-tail/memp needs a function as its first argument", env)
+		err = ProcessTypeError("find-tail/memp needs a function as its first argument", env)
 		return
 	}
 
 	l := Second(args)
 	if !ListP(l) {
-		err = ProcessError(fmt.Sprintf("find-tail needs a list as its second argument, but got %s.", String(l)), env)
+		err = ProcessTypeError(fmt.Sprintf("find-tail needs a list as its second argument, but got %s.", String(l)), env)
 		return
 	}
 
@@ -344,7 +344,7 @@ func FindTailImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 		found, err = ApplyWithoutEval(f, InternalMakeList(Car(c)), env)
 
 		if !BooleanP(found) {
-			err = ProcessError("find-tail needs a predicate function as its first argument.", env)
+			err = ProcessTypeError("find-tail needs a predicate function as its first argument.", env)
 			return
 		}
 		if BooleanValue(found) {
@@ -358,13 +358,13 @@ func FindTailImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func FindImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	f := First(args)
 	if !FunctionOrPrimitiveP(f) {
-		err = ProcessError("find needs a function as its first argument", env)
+		err = ProcessTypeError("find needs a function as its first argument", env)
 		return
 	}
 
 	l := Second(args)
 	if !ListP(l) {
-		err = ProcessError(fmt.Sprintf("find needs a list as its second argument, but got %s.", String(l)), env)
+		err = ProcessTypeError(fmt.Sprintf("find needs a list as its second argument, but got %s.", String(l)), env)
 		return
 	}
 
@@ -372,7 +372,7 @@ func FindImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	for c := l; NotNilP(c); c = Cdr(c) {
 		found, err = ApplyWithoutEval(f, InternalMakeList(Car(c)), env)
 		if !BooleanP(found) {
-			err = ProcessError("find needs a predicate function as its first argument.", env)
+			err = ProcessTypeError("find needs a predicate function as its first argument.", env)
 			return
 		}
 		if BooleanValue(found) {
@@ -7,6 +7,11 @@
 
 package golisp
 
+import (
+	"fmt"
+	"sort"
+)
+
 func RegisterListManipulationPrimitives() {
 	MakePrimitiveFunction("list", "*", ListImpl)
 	MakePrimitiveFunction("make-list", "1|2", MakeListImpl)
@@ -21,13 +26,13 @@ func RegisterListManipulationPrimitives() {
 	MakePrimitiveFunction("copy", "1", CopyImpl)
 	MakePrimitiveFunction("partition", "2", PartitionImpl)
 	MakePrimitiveFunction("sublist", "3", SublistImpl)
-	MakePrimitiveFunction("sort", "2", SortImpl)
+	MakePrimitiveFunction("sort", "2|4", SortImpl)
 }
 
 func MakeListImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	kVal := Car(args)
 	if !IntegerP(kVal) {
-		err = ProcessError("make-list requires a integer as it's first argument.", env)
+		err = ProcessTypeError("make-list requires a integer as it's first argument.", env)
 		return
 	}
 
@@ -35,7 +40,7 @@ func MakeListImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	var element *Data
 
 	if k < 0 {
-		err = ProcessError("make-list requires a non-negative integer as it's first argument.", env)
+		err = ProcessTypeError("make-list requires a non-negative integer as it's first argument.", env)
 		return
 	}
 
@@ -186,13 +191,13 @@ func partitionByPredicate(determiner *Data, l *Data, env *SymbolTableFrame) (res
 func PartitionImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	determiner := Car(args)
 	if !IntegerP(determiner) && !FunctionOrPrimitiveP(determiner) {
-		err = ProcessError("partition requires an integer or function as it's first argument.", env)
+		err = ProcessTypeError("partition requires an integer or function as it's first argument.", env)
 		return
 	}
 
 	l := Cadr(args)
 	if !ListP(l) {
-		err = ProcessError("partition requires a list as it's second argument.", env)
+		err = ProcessTypeError("partition requires a list as it's second argument.", env)
 		return
 	}
 
@@ -206,31 +211,31 @@ func PartitionImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 func SublistImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	l := Car(args)
 	if !ListP(l) {
-		err = ProcessError("sublist requires a list as it's first argument.", env)
+		err = ProcessTypeError("sublist requires a list as it's first argument.", env)
 		return
 	}
 
 	n := Cadr(args)
 	if !IntegerP(n) {
-		err = ProcessError("sublist requires a number as it's second argument (start).", env)
+		err = ProcessTypeError("sublist requires a number as it's second argument (start).", env)
 		return
 	}
 	first := int(IntegerValue(n))
 
 	if first <= 0 {
-		err = ProcessError("sublist requires positive indecies.", env)
+		err = ProcessIndexError("sublist requires positive indecies.", env)
 		return
 	}
 
 	n = Caddr(args)
 	if !IntegerP(n) {
-		err = ProcessError("sublist requires a number as it's third argument (end).", env)
+		err = ProcessTypeError("sublist requires a number as it's third argument (end).", env)
 		return
 	}
 	last := int(IntegerValue(n))
 
 	if last <= 0 {
-		err = ProcessError("sublist requires positive indecies.", env)
+		err = ProcessIndexError("sublist requires positive indecies.", env)
 		return
 	}
 
@@ -251,90 +256,160 @@ func SublistImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	return
 }
 
-func mergeCompare(a *Data, b *Data, proc *Data, env *SymbolTableFrame) (result bool, err error) {
-	if FunctionP(proc) {
-		b, err := FunctionValue(proc).Apply(InternalMakeList(a, b), env)
-		if err == nil {
-			result = BooleanValue(b)
-		}
-	} else {
-		b, err := PrimitiveValue(proc).Apply(InternalMakeList(a, b), env)
-		if err == nil {
-			result = BooleanValue(b)
-		}
-	}
-	return
+type sortEntry struct {
+	key  *Data
+	item *Data
 }
 
-func merge(a []*Data, b []*Data, proc *Data, env *SymbolTableFrame) (result []*Data, err error) {
-	var r = make([]*Data, len(a)+len(b))
-	var i = 0
-	var j = 0
-	var comparison = false
+// nativeLess returns a Go comparison equivalent to proc when proc is a primitive we know
+// how to compare with directly and all the keys are of a type it accepts.  Otherwise it
+// returns nil, and the sort falls back to applying proc for every comparison.
+func nativeLess(proc *Data, entries []sortEntry) func(a *Data, b *Data) bool {
+	if !PrimitiveP(proc) {
+		return nil
+	}
 
-	for i < len(a) && j < len(b) {
-		comparison, err = mergeCompare(a[i], b[j], proc, env)
-		if err != nil {
-			return
-		}
-		if comparison {
-			r[i+j] = a[i]
-			i++
-		} else {
-			r[i+j] = b[j]
-			j++
+	allKeys := func(pred func(*Data) bool) bool {
+		for _, entry := range entries {
+			if !pred(entry.key) {
+				return false
+			}
 		}
+		return true
 	}
 
-	for i < len(a) {
-		r[i+j] = a[i]
-		i++
-	}
-	for j < len(b) {
-		r[i+j] = b[j]
-		j++
+	switch PrimitiveValue(proc).Name {
+	case "<":
+		if allKeys(NumberP) {
+			return func(a *Data, b *Data) bool {
+				order, ordered := numberOrder(a, b)
+				return ordered && order < 0
+			}
+		}
+	case ">":
+		if allKeys(NumberP) {
+			return func(a *Data, b *Data) bool {
+				order, ordered := numberOrder(a, b)
+				return ordered && order > 0
+			}
+		}
+	case "char<?":
+		if allKeys(CharacterP) {
+			return func(a *Data, b *Data) bool { return CharacterValue(a) < CharacterValue(b) }
+		}
+	case "char>?":
+		if allKeys(CharacterP) {
+			return func(a *Data, b *Data) bool { return CharacterValue(a) > CharacterValue(b) }
+		}
+	case "string<?":
+		if allKeys(StringP) {
+			return func(a *Data, b *Data) bool { return StringValue(a) < StringValue(b) }
+		}
+	case "string>?":
+		if allKeys(StringP) {
+			return func(a *Data, b *Data) bool { return StringValue(a) > StringValue(b) }
+		}
+	case "string-natural<?":
+		if allKeys(StringP) {
+			return func(a *Data, b *Data) bool { return naturalCompare(StringValue(a), StringValue(b)) < 0 }
+		}
 	}
-
-	return r, nil
+	return nil
 }
 
-func mergesort(items []*Data, proc *Data, env *SymbolTableFrame) (result []*Data, err error) {
-	if len(items) < 2 {
-		return items, nil
+// StableSort sorts items by proc, applying key (if not nil) to each item once to get the
+// value that is compared.  Items that compare equal keep their original order.
+func StableSort(items []*Data, proc *Data, key *Data, env *SymbolTableFrame) (result []*Data, err error) {
+	entries := make([]sortEntry, len(items))
+	for i, item := range items {
+		entries[i].item = item
+		entries[i].key = item
+		if key != nil {
+			entries[i].key, err = ApplyWithoutEval(key, InternalMakeList(item), env)
+			if err != nil {
+				return
+			}
+		}
 	}
 
-	var middle = len(items) / 2
-
-	a, err := mergesort(items[:middle], proc, env)
-	if err != nil {
-		return
+	less := nativeLess(proc, entries)
+	if less == nil {
+		less = func(a *Data, b *Data) bool {
+			if err != nil {
+				return false
+			}
+			var comparison *Data
+			comparison, err = ApplyWithoutEval(proc, InternalMakeList(a, b), env)
+			return BooleanValue(comparison)
+		}
 	}
 
-	b, err := mergesort(items[middle:], proc, env)
+	sort.SliceStable(entries, func(i, j int) bool { return less(entries[i].key, entries[j].key) })
 	if err != nil {
 		return
 	}
 
-	return merge(a, b, proc, env)
+	result = make([]*Data, len(entries))
+	for i, entry := range entries {
+		result[i] = entry.item
+	}
+	return
 }
 
 func SortImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	coll := Car(args)
-	if !ListP(coll) {
-		err = ProcessError("sort requires a list as it's first argument.", env)
+	if !ListP(coll) && !VectorP(coll) && !StringP(coll) {
+		err = ProcessTypeError(fmt.Sprintf("sort requires a list, vector, or string as it's first argument but was given %s.", String(coll)), env)
 		return
 	}
 
 	proc := Cadr(args)
 	if !FunctionOrPrimitiveP(proc) {
-		err = ProcessError("sort requires a function or primitive as it's second argument.", env)
+		err = ProcessTypeError("sort requires a function or primitive as it's second argument.", env)
 		return
 	}
 
-	sorted, err := mergesort(ToArray(coll), proc, env)
+	var key *Data
+	if Length(args) == 4 {
+		option := Caddr(args)
+		if !NakedP(option) || StringValue(option) != "key:" {
+			err = ProcessTypeError(fmt.Sprintf("sort only accepts a key: option but was given %s.", String(option)), env)
+			return
+		}
+		key = Car(Cdddr(args))
+		if !FunctionOrPrimitiveP(key) {
+			err = ProcessTypeError(fmt.Sprintf("sort requires key: to be a function or primitive but was given %s.", String(key)), env)
+			return
+		}
+	}
+
+	var items []*Data
+	switch {
+	case VectorP(coll):
+		items = VectorValue(coll)
+	case StringP(coll):
+		for _, ch := range StringValue(coll) {
+			items = append(items, CharacterWithValue(ch))
+		}
+	default:
+		items = ToArray(coll)
+	}
+
+	sorted, err := StableSort(items, proc, key, env)
 	if err != nil {
 		return
 	}
 
-	return ArrayToList(sorted), nil
+	switch {
+	case VectorP(coll):
+		return VectorWithValue(sorted), nil
+	case StringP(coll):
+		runes := make([]rune, len(sorted))
+		for i, ch := range sorted {
+			runes[i] = CharacterValue(ch)
+		}
+		return StringWithValue(string(runes)), nil
+	default:
+		return ArrayToList(sorted), nil
+	}
 }
@@ -16,7 +16,10 @@ func RegisterMacroPrimitives() {
 	MakeSpecialForm("quasiquote", "1", QuasiquoteImpl)
 	MakeSpecialForm("unquote", "1", UnquoteImpl)
 	MakeSpecialForm("unquote-splicing", "1", UnquoteSplicingImpl)
+	MakeSpecialForm("define-symbol-macro", "2", DefineSymbolMacroImpl)
 	MakeSpecialForm("expand", ">=1", ExpandImpl)
+	MakeSpecialForm("with-gensyms", ">=1", WithGensymsImpl)
+	MakeSpecialForm("once-only", ">=1", OnceOnlyImpl)
 }
 
 func QuoteImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
@@ -27,81 +30,103 @@ func QuoteImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	}
 }
 
+// quasiquoteForm reports whether sexpr is a two element list headed by the symbol name,
+// e.g. (unquote x)
+func quasiquoteForm(sexpr *Data, name string) bool {
+	return PairP(sexpr) && NotNilP(sexpr) && SymbolP(Car(sexpr)) && StringValue(Car(sexpr)) == name && PairP(Cdr(sexpr)) && NilP(Cddr(sexpr))
+}
+
+// processQuasiquoted fills in the template sexpr, which is nested level quasiquotes deep.
+// Unquotes at level 1 are evaluated; deeper ones are kept, with their own contents filled
+// in one level shallower.  Vectors are filled in element by element.
 func processQuasiquoted(sexpr *Data, level int, env *SymbolTableFrame) (result *Data, err error) {
-	if !ListP(sexpr) {
-		return Cons(sexpr, nil), nil
-	} else if SymbolP(Car(sexpr)) && StringValue(Car(sexpr)) == "quasiquote" {
-		processed, err := processQuasiquoted(Cadr(sexpr), level+1, env)
+	switch {
+	case VectorP(sexpr):
+		var elements *Data
+		elements, err = processQuasiquotedList(ArrayToList(VectorValue(sexpr)), level, env)
 		if err != nil {
-			return nil, err
+			return
 		}
-		return Cons(Cons(Intern("quasiquote"), processed), nil), nil
-	} else if SymbolP(Car(sexpr)) && StringValue(Car(sexpr)) == "unquote" {
+		return VectorWithValue(ToArray(elements)), nil
+	case !PairP(sexpr) || NilP(sexpr):
+		return sexpr, nil
+	case quasiquoteForm(sexpr, "quasiquote"):
+		return nestedQuasiquoted(sexpr, level+1, env)
+	case quasiquoteForm(sexpr, "unquote"), quasiquoteForm(sexpr, "unquote-splicing"):
 		if level == 1 {
-			processed, err := processQuasiquoted(Cadr(sexpr), level, env)
-			if err != nil {
-				return nil, err
-			}
-			r, err := Eval(Car(processed), env)
-			if err != nil {
-				return nil, err
-			}
-			return Cons(r, nil), nil
-		} else {
-			processed, err := processQuasiquoted(Cadr(sexpr), level-1, env)
-			if err != nil {
-				return nil, err
-			}
-			return Cons(Cons(Intern("unquote"), processed), nil), nil
+			return Eval(Cadr(sexpr), env)
 		}
-	} else if SymbolP(Car(sexpr)) && StringValue(Car(sexpr)) == "unquote-splicing" {
-		if level == 1 {
-			processed, err := processQuasiquoted(Cadr(sexpr), level, env)
-			if err != nil {
-				return nil, err
-			}
-			r, err := Eval(Car(processed), env)
+		return nestedQuasiquoted(sexpr, level-1, env)
+	default:
+		return processQuasiquotedList(sexpr, level, env)
+	}
+}
+
+// nestedQuasiquoted keeps the quasiquote, unquote, or unquote-splicing form sexpr, filling
+// in its contents at level
+func nestedQuasiquoted(sexpr *Data, level int, env *SymbolTableFrame) (result *Data, err error) {
+	contents, err := processQuasiquotedList(Cdr(sexpr), level, env)
+	if err != nil {
+		return
+	}
+	return Cons(Car(sexpr), contents), nil
+}
+
+// processQuasiquotedList fills in each element of the list template l, splicing in the
+// values of unquote-splicing elements at level 1, and fills in its tail if it's dotted, as
+// in (a . ,b)
+func processQuasiquotedList(l *Data, level int, env *SymbolTableFrame) (result *Data, err error) {
+	elements := make([]*Data, 0, Length(l))
+	var tail *Data
+	c := l
+	for ; PairP(c) && NotNilP(c); c = Cdr(c) {
+		if c != l && (quasiquoteForm(c, "unquote") || quasiquoteForm(c, "quasiquote")) {
+			break
+		}
+
+		element := Car(c)
+		if level == 1 && quasiquoteForm(element, "unquote-splicing") {
+			var spliced *Data
+			spliced, err = Eval(Cadr(element), env)
 			if err != nil {
-				return nil, err
+				return
 			}
-			if NilP(r) {
-				return nil, nil
+			if ListP(spliced) {
+				elements = append(elements, ToArray(spliced)...)
+			} else if NilP(Cdr(c)) {
+				tail = spliced
 			} else {
-				return r, nil
+				err = ProcessError(fmt.Sprintf("unquote-splicing needs a list to splice, but %s is %s.", String(Cadr(element)), String(spliced)), env)
+				return
 			}
-		} else {
-			processed, err := processQuasiquoted(Cadr(sexpr), level-1, env)
-			if err != nil {
-				return nil, err
-			}
-			return Cons(Cons(Intern("unquote-splicing"), processed), nil), nil
+			continue
 		}
-	} else {
-		parts := make([]*Data, 0, Length(Cdr(sexpr)))
-		for _, exp := range ToArray(sexpr) {
-			processed, err := processQuasiquoted(exp, level, env)
-			if err != nil {
-				return nil, err
-			}
-			if processed != nil {
-				parts = append(parts, processed)
-			}
+
+		var processed *Data
+		if processed, err = processQuasiquoted(element, level, env); err != nil {
+			return
 		}
-		flat, err := Flatten(ArrayToList(parts))
-		if err != nil {
-			return nil, err
+		elements = append(elements, processed)
+	}
+
+	if NotNilP(c) {
+		if tail, err = processQuasiquoted(c, level, env); err != nil {
+			return
+		}
+	}
+	result = tail
+	for i := len(elements) - 1; i >= 0; i-- {
+		element := elements[i]
+		if element == nil {
+			element = EmptyCons()
 		}
-		return Cons(flat, nil), nil
+		result = Cons(element, result)
 	}
 	return
 }
 
 func QuasiquoteImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	r, err := processQuasiquoted(Car(args), 1, env)
-	if err != nil {
-		return nil, err
-	}
-	return Car(r), nil
+	return processQuasiquoted(Car(args), 1, env)
 }
 
 func UnquoteImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
@@ -114,14 +139,95 @@ func UnquoteSplicingImpl(args *Data, env *SymbolTableFrame) (result *Data, err e
 	return
 }
 
+// ExpandImpl handles (expand macro args...), as well as (expand symbol-macro-name), which
+// results in the symbol macro's expansion
 func ExpandImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if SymbolP(Car(args)) && NilP(Cdr(args)) {
+		if m := SymbolMacroValue(env.ValueOf(Car(args))); m != nil {
+			return m.Expansion, nil
+		}
+	}
 	n, err := Eval(Car(args), env)
 	if err != nil {
 		return
 	}
 	if !MacroP(n) {
-		err = ProcessError(fmt.Sprintf("expand expected a macro, received %s", String(n)), env)
+		err = ProcessTypeError(fmt.Sprintf("expand expected a macro, received %s", String(n)), env)
 		return
 	}
 	return MacroValue(n).Expand(Cdr(args), env)
 }
+
+// macroHelperNames checks that a with-gensyms or once-only form starts with a list of names
+func macroHelperNames(name string, names *Data, env *SymbolTableFrame) (err error) {
+	if !ListP(names) {
+		return ProcessTypeError(fmt.Sprintf("%s expects a list of names as it's first argument, but received %s.", name, String(names)), env)
+	}
+	for cell := names; NotNilP(cell); cell = Cdr(cell) {
+		if !SymbolP(Car(cell)) {
+			return ProcessTypeError(fmt.Sprintf("%s expects names to be symbols, but received %s.", name, String(Car(cell))), env)
+		}
+	}
+	return
+}
+
+// WithGensymsImpl handles (with-gensyms (name...) body...), evaluating body with each name
+// bound to a fresh symbol prefixed with the name.
+func WithGensymsImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	names := Car(args)
+	if err = macroHelperNames("with-gensyms", names, env); err != nil {
+		return
+	}
+
+	localEnv := NewSymbolTableFrameBelow(env, "with-gensyms")
+	if err = localEnv.callFrom(env); err != nil {
+		return
+	}
+	for cell := names; NotNilP(cell); cell = Cdr(cell) {
+		sym, err := GensymImpl(InternalMakeList(Car(cell)), env)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = localEnv.BindLocallyTo(Car(cell), sym); err != nil {
+			return nil, err
+		}
+	}
+	return evaluateBody(Cdr(args), localEnv)
+}
+
+// OnceOnlyImpl handles (once-only (name...) body...) in a macro body.  Each name is bound to
+// a form passed to the macro; body is evaluated with the names bound to fresh symbols instead
+// and its expansion is wrapped in a let that binds those symbols to the values of the forms,
+// so each form is evaluated exactly once, before the expansion.
+func OnceOnlyImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	names := Car(args)
+	if err = macroHelperNames("once-only", names, env); err != nil {
+		return
+	}
+
+	localEnv := NewSymbolTableFrameBelow(env, "once-only")
+	if err = localEnv.callFrom(env); err != nil {
+		return
+	}
+	bindings := make([]*Data, 0, Length(names))
+	for cell := names; NotNilP(cell); cell = Cdr(cell) {
+		form, err := Eval(Car(cell), env)
+		if err != nil {
+			return nil, err
+		}
+		sym, err := GensymImpl(InternalMakeList(Car(cell)), env)
+		if err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, InternalMakeList(sym, form))
+		if _, err = localEnv.BindLocallyTo(Car(cell), sym); err != nil {
+			return nil, err
+		}
+	}
+
+	expansion, err := evaluateBody(Cdr(args), localEnv)
+	if err != nil {
+		return
+	}
+	return InternalMakeList(Intern("let"), ArrayToList(bindings), expansion), nil
+}
@@ -10,7 +10,7 @@ package golisp
 import (
 	"fmt"
 	"math"
-	"math/rand"
+	"math/big"
 )
 
 func RegisterMathPrimitives() {
@@ -20,19 +20,42 @@ func RegisterMathPrimitives() {
 	MakePrimitiveFunction("/", "*", QuotientImpl)
 	MakePrimitiveFunction("succ", "1", IncrementImpl)
 	MakePrimitiveFunction("pred", "1", DecrementImpl)
-	MakePrimitiveFunction("quotient", "*", QuotientImpl)
-	MakePrimitiveFunction("%", "2", RemainderImpl)
-	MakePrimitiveFunction("modulo", "2", RemainderImpl)
+	makeDivisionFunction("quotient", truncateDivision, true, false)
+	makeDivisionFunction("%", truncateDivision, false, true)
+	makeDivisionFunction("remainder", truncateDivision, false, true)
+	makeDivisionFunction("modulo", floorDivision, false, true)
+	makeDivisionFunction("truncate/", truncateDivision, true, true)
+	makeDivisionFunction("truncate-quotient", truncateDivision, true, false)
+	makeDivisionFunction("truncate-remainder", truncateDivision, false, true)
+	makeDivisionFunction("floor/", floorDivision, true, true)
+	makeDivisionFunction("floor-quotient", floorDivision, true, false)
+	makeDivisionFunction("floor-remainder", floorDivision, false, true)
+	makeDivisionFunction("euclidean/", euclideanDivision, true, true)
+	makeDivisionFunction("euclidean-quotient", euclideanDivision, true, false)
+	makeDivisionFunction("euclidean-remainder", euclideanDivision, false, true)
+	MakePrimitiveFunction("gcd", "*", GcdImpl)
+	MakePrimitiveFunction("lcm", "*", LcmImpl)
 	MakePrimitiveFunction("random-byte", "0", RandomByteImpl)
 	MakePrimitiveFunction("interval", "1|2|3", IntervalImpl)
 	MakePrimitiveFunction("integer", "1", ToIntImpl)
 	MakePrimitiveFunction("float", "1", ToFloatImpl)
 	MakePrimitiveFunction("number->string", "1|2", NumberToStringImpl)
 	MakePrimitiveFunction("string->number", "1|2", StringToNumberImpl)
-	MakePrimitiveFunction("min", "1", MinImpl)
-	MakePrimitiveFunction("max", "1", MaxImpl)
+	MakePrimitiveFunction("float-precision", "0|1", FloatPrecisionImpl)
+	MakePrimitiveFunction("float-notation", "0|1", FloatNotationImpl)
+	MakePrimitiveFunction("float-trim-zeros", "0|1", FloatTrimZerosImpl)
+	MakePrimitiveFunction("min", ">=1", MinImpl)
+	MakePrimitiveFunction("max", ">=1", MaxImpl)
 	MakePrimitiveFunction("floor", "1", FloorImpl)
 	MakePrimitiveFunction("ceiling", "1", CeilingImpl)
+	MakePrimitiveFunction("round", "1", RoundImpl)
+	MakePrimitiveFunction("truncate", "1", TruncateImpl)
+	MakePrimitiveFunction("exact?", "1", IsExactImpl)
+	MakePrimitiveFunction("inexact?", "1", IsInexactImpl)
+	MakePrimitiveFunction("exact->inexact", "1", ExactToInexactImpl)
+	MakePrimitiveFunction("inexact", "1", ExactToInexactImpl)
+	MakePrimitiveFunction("inexact->exact", "1", InexactToExactImpl)
+	MakePrimitiveFunction("exact", "1", InexactToExactImpl)
 	MakePrimitiveFunction("abs", "1", AbsImpl)
 	MakePrimitiveFunction("zero?", "1", ZeroImpl)
 	MakePrimitiveFunction("positive?", "1", PositiveImpl)
@@ -41,11 +64,15 @@ func RegisterMathPrimitives() {
 	MakePrimitiveFunction("odd?", "1", OddImpl)
 	MakePrimitiveFunction("sign", "1", SignImpl)
 	MakePrimitiveFunction("pow", "2", PowImpl)
+	MakePrimitiveFunction("expt", "2", PowImpl)
+	MakePrimitiveFunction("bignum?", "1", IsBigIntegerImpl)
 	MakePrimitiveFunction("inf?", "1", IsInfImpl)
 	MakePrimitiveFunction("nan?", "1", IsNaNImpl)
 	MakePrimitiveFunction("float->bits", "1", FloatToBitsImpl)
 	MakePrimitiveFunction("bits->float", "1", BitsToFloatImpl)
 
+	registerBigIntegerEquality()
+
 	makeUnaryFloatFunction("acos", math.Acos)
 	makeUnaryFloatFunction("acosh", math.Acosh)
 	makeUnaryFloatFunction("asin", math.Asin)
@@ -97,7 +124,7 @@ func makeUnaryFloatFunction(name string, f func(float64) float64) {
 		valObj := Car(args)
 
 		if !NumberP(valObj) {
-			err = ProcessError(fmt.Sprintf("%s expects a number as a parameter, got %s", name, String(valObj)), env)
+			err = ProcessTypeError(fmt.Sprintf("%s expects a number as a parameter, got %s", name, String(valObj)), env)
 			return
 		}
 
@@ -126,23 +153,21 @@ func intSgn(a int64) int64 {
 }
 
 func IncrementImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	if !IntegerP(Car(args)) {
-		err = ProcessError("1+ requires an integer argument", env)
+	if !ExactIntegerP(Car(args)) {
+		err = ProcessTypeError("1+ requires an integer argument", env)
 		return
 	}
 
-	val := IntegerValue(Car(args))
-	return IntegerWithValue(val + 1), nil
+	return addInts(Cons(Car(args), Cons(IntegerWithValue(1), nil)), env)
 }
 
 func DecrementImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	if !IntegerP(Car(args)) {
-		err = ProcessError("1- requires an integer argument", env)
+	if !ExactIntegerP(Car(args)) {
+		err = ProcessTypeError("1- requires an integer argument", env)
 		return
 	}
 
-	val := IntegerValue(Car(args))
-	return IntegerWithValue(val - 1), nil
+	return subtractInts(Cons(Car(args), Cons(IntegerWithValue(1), nil)), env)
 }
 
 func addFloats(args *Data, env *SymbolTableFrame) (result *Data, err error) {
@@ -153,18 +178,30 @@ func addFloats(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	return FloatWithValue(acc), nil
 }
 
+// addInts adds integers, starting over with big.Ints if there's a bignum or the sum overflows
 func addInts(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	var acc int64 = 0
 	for c := args; NotNilP(c); c = Cdr(c) {
-		acc += IntegerValue(Car(c))
+		var ok bool
+		if acc, ok = addInt64(acc, IntegerValue(Car(c))); !ok || BigIntegerP(Car(c)) {
+			return addBigInts(args), nil
+		}
 	}
 	return IntegerWithValue(acc), nil
 }
 
+func addBigInts(args *Data) *Data {
+	acc := new(big.Int)
+	for c := args; NotNilP(c); c = Cdr(c) {
+		acc.Add(acc, bigIntegerOf(Car(c)))
+	}
+	return BigIntegerWithValue(acc)
+}
+
 func anyFloats(args *Data, env *SymbolTableFrame) (result bool, err error) {
 	for c := args; NotNilP(c); c = Cdr(c) {
 		if !NumberP(Car(c)) {
-			err = ProcessError(fmt.Sprintf("Number expected, received %s", String(Car(c))), env)
+			err = ProcessTypeError(fmt.Sprintf("Number expected, received %s", String(Car(c))), env)
 			return
 		}
 		if FloatP(Car(c)) {
@@ -187,15 +224,44 @@ func AddImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 }
 
 func subtractInts(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if NilP(Cdr(args)) {
+		return negateInt(Car(args)), nil
+	}
+	if BigIntegerP(Car(args)) {
+		return subtractBigInts(args), nil
+	}
 	acc := IntegerValue(Car(args))
 	for c := Cdr(args); NotNilP(c); c = Cdr(c) {
-		acc -= IntegerValue(Car(c))
+		var ok bool
+		if acc, ok = subtractInt64(acc, IntegerValue(Car(c))); !ok || BigIntegerP(Car(c)) {
+			return subtractBigInts(args), nil
+		}
 	}
 	return IntegerWithValue(acc), nil
 }
 
+// negateInt is -n, which is a BigInteger when n is math.MinInt64
+func negateInt(n *Data) *Data {
+	if IntegerP(n) && IntegerValue(n) != math.MinInt64 {
+		return IntegerWithValue(-IntegerValue(n))
+	}
+	negated := bigIntegerOf(n)
+	return BigIntegerWithValue(negated.Neg(negated))
+}
+
+func subtractBigInts(args *Data) *Data {
+	acc := bigIntegerOf(Car(args))
+	for c := Cdr(args); NotNilP(c); c = Cdr(c) {
+		acc.Sub(acc, bigIntegerOf(Car(c)))
+	}
+	return BigIntegerWithValue(acc)
+}
+
 func subtractFloats(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	acc := FloatValue(Car(args))
+	if NilP(Cdr(args)) {
+		return FloatWithValue(-acc), nil
+	}
 	for c := Cdr(args); NotNilP(c); c = Cdr(c) {
 		acc -= FloatValue(Car(c))
 	}
@@ -217,11 +283,22 @@ func SubtractImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func multiplyInts(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	var acc int64 = 1
 	for c := args; NotNilP(c); c = Cdr(c) {
-		acc *= IntegerValue(Car(c))
+		var ok bool
+		if acc, ok = multiplyInt64(acc, IntegerValue(Car(c))); !ok || BigIntegerP(Car(c)) {
+			return multiplyBigInts(args), nil
+		}
 	}
 	return IntegerWithValue(acc), nil
 }
 
+func multiplyBigInts(args *Data) *Data {
+	acc := big.NewInt(1)
+	for c := args; NotNilP(c); c = Cdr(c) {
+		acc.Mul(acc, bigIntegerOf(Car(c)))
+	}
+	return BigIntegerWithValue(acc)
+}
+
 func multiplyFloats(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	var acc float32 = 1.0
 	for c := args; NotNilP(c); c = Cdr(c) {
@@ -243,12 +320,20 @@ func MultiplyImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 }
 
 func quotientInts(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	for c := args; NotNilP(c); c = Cdr(c) {
+		if BigIntegerP(Car(c)) {
+			return quotientBigInts(args, env)
+		}
+	}
 	acc := IntegerValue(Car(args))
 	for c := Cdr(args); NotNilP(c); c = Cdr(c) {
 		v := IntegerValue(Car(c))
 		if v == 0 {
 			err = ProcessError(fmt.Sprintf("Quotent: %s -> Divide by zero.", String(args)), env)
 			return
+		} else if acc == math.MinInt64 && v == -1 {
+			// the only quotient that overflows
+			return quotientBigInts(args, env)
 		} else {
 			acc /= v
 		}
@@ -256,6 +341,19 @@ func quotientInts(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	return IntegerWithValue(acc), nil
 }
 
+func quotientBigInts(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	acc := bigIntegerOf(Car(args))
+	for c := Cdr(args); NotNilP(c); c = Cdr(c) {
+		v := bigIntegerOf(Car(c))
+		if v.Sign() == 0 {
+			err = ProcessError(fmt.Sprintf("Quotent: %s -> Divide by zero.", String(args)), env)
+			return
+		}
+		acc.Quo(acc, v)
+	}
+	return BigIntegerWithValue(acc), nil
+}
+
 func quotientFloats(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	var acc float32 = FloatValue(Car(args))
 	for c := Cdr(args); NotNilP(c); c = Cdr(c) {
@@ -282,26 +380,155 @@ func QuotientImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	}
 }
 
-func RemainderImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	dividend := Car(args)
-	if !IntegerP(dividend) {
-		err = ProcessError(fmt.Sprintf("%/modulo expected an integer first arg, received %s", String(dividend)), env)
+// Integer division rounds the quotient one of three ways, which decides the sign of the
+// remainder: truncating toward zero leaves it with the sign of the dividend, flooring toward
+// negative infinity gives it the sign of the divisor, and euclidean division never leaves it
+// negative.
+const (
+	truncateDivision = iota
+	floorDivision
+	euclideanDivision
+)
+
+func divisionArgs(name string, args *Data, env *SymbolTableFrame) (dividend *Data, divisor *Data, err error) {
+	dividend = Car(args)
+	if !NumberP(dividend) {
+		err = ProcessTypeError(fmt.Sprintf("%s expected a number first arg, received %s", name, String(dividend)), env)
 		return
 	}
 
-	divisor := Cadr(args)
-	if !IntegerP(divisor) {
-		err = ProcessError(fmt.Sprintf("%/modulo expected an integer second arg, received %s", String(divisor)), env)
+	divisor = Cadr(args)
+	if !NumberP(divisor) {
+		err = ProcessTypeError(fmt.Sprintf("%s expected a number second arg, received %s", name, String(divisor)), env)
 		return
 	}
+	if !BigIntegerP(divisor) && FloatValue(divisor) == 0 {
+		err = ProcessError(fmt.Sprintf("%s: division by zero", name), env)
+		return
+	}
+	return
+}
 
-	val := IntegerValue(dividend) % IntegerValue(divisor)
-	return IntegerWithValue(val), nil
+// divide divides dividend by the non-zero divisor, rounding the quotient as rounding says.
+// Either being a float makes both results floats.
+func divide(dividend *Data, divisor *Data, rounding int) (quotient *Data, remainder *Data) {
+	if FloatP(dividend) || FloatP(divisor) {
+		q, r := divideFloats(float64(FloatValue(dividend)), float64(FloatValue(divisor)), rounding)
+		return FloatWithValue(float32(q)), FloatWithValue(float32(r))
+	}
+	if IntegerP(dividend) && IntegerP(divisor) && !(IntegerValue(dividend) == math.MinInt64 && IntegerValue(divisor) == -1) {
+		q, r := divideInts(IntegerValue(dividend), IntegerValue(divisor), rounding)
+		return IntegerWithValue(q), IntegerWithValue(r)
+	}
+	q, r := divideBigInts(bigIntegerOf(dividend), bigIntegerOf(divisor), rounding)
+	return BigIntegerWithValue(q), BigIntegerWithValue(r)
+}
+
+func divideInts(a int64, b int64, rounding int) (q int64, r int64) {
+	q, r = a/b, a%b
+	switch {
+	case rounding == floorDivision && r != 0 && (r < 0) != (b < 0),
+		rounding == euclideanDivision && r < 0 && b > 0:
+		q, r = q-1, r+b
+	case rounding == euclideanDivision && r < 0 && b < 0:
+		q, r = q+1, r-b
+	}
+	return
 }
 
-// Not tested since it just wraps rand.Int()
+func divideBigInts(a *big.Int, b *big.Int, rounding int) (q *big.Int, r *big.Int) {
+	q, r = new(big.Int).QuoRem(a, b, new(big.Int))
+	one := big.NewInt(1)
+	switch {
+	case rounding == floorDivision && r.Sign() != 0 && r.Sign() != b.Sign(),
+		rounding == euclideanDivision && r.Sign() < 0 && b.Sign() > 0:
+		q.Sub(q, one)
+		r.Add(r, b)
+	case rounding == euclideanDivision && r.Sign() < 0 && b.Sign() < 0:
+		q.Add(q, one)
+		r.Sub(r, b)
+	}
+	return
+}
+
+func divideFloats(a float64, b float64, rounding int) (q float64, r float64) {
+	r = math.Mod(a, b)
+	switch {
+	case rounding == floorDivision && r != 0 && (r < 0) != (b < 0),
+		rounding == euclideanDivision && r < 0 && b > 0:
+		r += b
+	case rounding == euclideanDivision && r < 0 && b < 0:
+		r -= b
+	}
+	return math.Round((a - r) / b), r
+}
+
+// makeDivisionFunction defines a primitive that divides its two arguments.  It results in
+// the quotient, the remainder, or a list of both.
+func makeDivisionFunction(name string, rounding int, quotient bool, remainder bool) {
+	primFunc := func(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+		dividend, divisor, err := divisionArgs(name, args, env)
+		if err != nil {
+			return
+		}
+		q, r := divide(dividend, divisor, rounding)
+		switch {
+		case quotient && remainder:
+			return InternalMakeList(q, r), nil
+		case quotient:
+			return q, nil
+		default:
+			return r, nil
+		}
+	}
+
+	MakePrimitiveFunction(name, "2", primFunc)
+}
+
+func integerArgs(name string, args *Data, env *SymbolTableFrame) (numbers []*big.Int, err error) {
+	for c := args; NotNilP(c); c = Cdr(c) {
+		if !ExactIntegerP(Car(c)) {
+			err = ProcessTypeError(fmt.Sprintf("%s requires integers, received %s", name, String(Car(c))), env)
+			return
+		}
+		numbers = append(numbers, bigIntegerOf(Car(c)).Abs(bigIntegerOf(Car(c))))
+	}
+	return
+}
+
+// GcdImpl handles (gcd n...), the greatest common divisor, which is 0 with no arguments
+func GcdImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	numbers, err := integerArgs("gcd", args, env)
+	if err != nil {
+		return
+	}
+	acc := new(big.Int)
+	for _, n := range numbers {
+		acc.GCD(nil, nil, acc, n)
+	}
+	return BigIntegerWithValue(acc), nil
+}
+
+// LcmImpl handles (lcm n...), the least common multiple, which is 1 with no arguments
+func LcmImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	numbers, err := integerArgs("lcm", args, env)
+	if err != nil {
+		return
+	}
+	acc := big.NewInt(1)
+	for _, n := range numbers {
+		if n.Sign() == 0 {
+			return IntegerWithValue(0), nil
+		}
+		divisor := new(big.Int).GCD(nil, nil, acc, n)
+		acc.Mul(acc.Quo(acc, divisor), n)
+	}
+	return BigIntegerWithValue(acc), nil
+}
+
+// Not tested since it just returns a random number
 func RandomByteImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	r := uint8(rand.Int())
+	r := uint8(randomInt63n(256))
 	result = IntegerWithValue(int64(r))
 	return
 }
@@ -330,7 +557,7 @@ func IntervalImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 
 		if Length(args) == 3 {
 			if !IntegerP(Caddr(args)) {
-				err = ProcessError(fmt.Sprintf("interval step must be an integer, received %s", String(Caddr(args))), env)
+				err = ProcessTypeError(fmt.Sprintf("interval step must be an integer, received %s", String(Caddr(args))), env)
 				return
 			}
 			step = IntegerValue(Caddr(args))
@@ -360,25 +587,130 @@ func IntervalImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func ToIntImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	n := Car(args)
 	if !NumberP(n) {
-		err = ProcessError(fmt.Sprintf("integer expected an number, received %s", String(n)), env)
+		err = ProcessTypeError(fmt.Sprintf("integer expected an number, received %s", String(n)), env)
 		return
 	}
 
+	if BigIntegerP(n) {
+		return n, nil
+	}
 	return IntegerWithValue(IntegerValue(n)), nil
 }
 
 func ToFloatImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	n := Car(args)
 	if !NumberP(n) {
-		err = ProcessError(fmt.Sprintf("float expected a number, received %s", String(n)), env)
+		err = ProcessTypeError(fmt.Sprintf("float expected a number, received %s", String(n)), env)
 		return
 	}
 
 	return FloatWithValue(FloatValue(n)), nil
 }
 
+func floatPrecisionArg(name string, d *Data, env *SymbolTableFrame) (precision int, err error) {
+	if BooleanP(d) && !BooleanValue(d) {
+		return -1, nil
+	}
+	if !IntegerP(d) || IntegerValue(d) < 0 {
+		err = ProcessTypeError(fmt.Sprintf("%s expects a non-negative integer or #f, but received %s.", name, String(d)), env)
+		return
+	}
+	return int(IntegerValue(d)), nil
+}
+
+func floatNotationArg(name string, d *Data, env *SymbolTableFrame) (notation FloatNotation, err error) {
+	if StringP(d) || SymbolP(d) {
+		for n, notationName := range floatNotationNames {
+			if StringValue(d) == notationName {
+				return n, nil
+			}
+		}
+	}
+	err = ProcessTypeError(fmt.Sprintf("%s expects general, fixed, or scientific, but received %s.", name, String(d)), env)
+	return
+}
+
+// floatFormatOptions overrides format with the precision:, notation:, and trim-zeros: slots
+// of options
+func floatFormatOptions(name string, options *FrameMap, format FloatFormat, env *SymbolTableFrame) (result FloatFormat, err error) {
+	result = format
+	if options.HasSlot("precision:") {
+		if result.Precision, err = floatPrecisionArg(name, options.Get("precision:"), env); err != nil {
+			return
+		}
+	}
+	if options.HasSlot("notation:") {
+		if result.Notation, err = floatNotationArg(name, options.Get("notation:"), env); err != nil {
+			return
+		}
+	}
+	if options.HasSlot("trim-zeros:") {
+		result.TrimZeros = BooleanValue(options.Get("trim-zeros:"))
+	}
+	return
+}
+
+func floatPrecisionValue(precision int) *Data {
+	if precision < 0 {
+		return LispFalse
+	}
+	return IntegerWithValue(int64(precision))
+}
+
+// FloatPrecisionImpl handles (float-precision [digits]), where #f restores printing the
+// fewest digits that read back as the same float
+func FloatPrecisionImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	format := CurrentFloatFormat()
+	if NotNilP(args) {
+		if format.Precision, err = floatPrecisionArg("float-precision", Car(args), env); err != nil {
+			return
+		}
+		SetFloatFormat(format)
+	}
+	return floatPrecisionValue(format.Precision), nil
+}
+
+func FloatNotationImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	format := CurrentFloatFormat()
+	if NotNilP(args) {
+		if format.Notation, err = floatNotationArg("float-notation", Car(args), env); err != nil {
+			return
+		}
+		SetFloatFormat(format)
+	}
+	return Intern(format.Notation.String()), nil
+}
+
+func FloatTrimZerosImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	format := CurrentFloatFormat()
+	if NotNilP(args) {
+		format.TrimZeros = BooleanValue(Car(args))
+		SetFloatFormat(format)
+	}
+	return BooleanWithValue(format.TrimZeros), nil
+}
+
+// NumberToStringImpl handles (number->string n [base]), and (number->string f [options])
+// for floats, where options is a frame that can override the float-precision,
+// float-notation, and float-trim-zeros settings.
 func NumberToStringImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	valObj := First(args)
+	if FloatP(valObj) {
+		format := CurrentFloatFormat()
+		if Length(args) == 2 {
+			options := Second(args)
+			if FrameP(options) {
+				if format, err = floatFormatOptions("number->string", FrameValue(options), format, env); err != nil {
+					return
+				}
+			} else if !IntegerP(options) || IntegerValue(options) != 10 {
+				err = ProcessTypeError(fmt.Sprintf("number->string expects a frame of formatting options or base 10 for a float, but received %s.", String(options)), env)
+				return
+			}
+		}
+		return StringWithValue(FormatFloat(FloatValue(valObj), format)), nil
+	}
+
 	val := IntegerValue(valObj)
 	var base int64
 	if Length(args) == 2 {
@@ -388,6 +720,13 @@ func NumberToStringImpl(args *Data, env *SymbolTableFrame) (result *Data, err er
 		base = 10
 	}
 
+	if BigIntegerP(valObj) {
+		switch base {
+		case 2, 8, 10, 16:
+			return StringWithValue(BigIntegerValue(valObj).Text(int(base))), nil
+		}
+	}
+
 	var format string
 	switch base {
 	case 2:
@@ -432,6 +771,9 @@ func StringToNumberImpl(args *Data, env *SymbolTableFrame) (result *Data, err er
 	var val int64
 	_, err = fmt.Sscanf(str, format, &val)
 	if err != nil {
+		if big, ok := parseBigInteger(str, int(base)); ok {
+			return big, nil
+		}
 		return
 	}
 	return IntegerWithValue(val), nil
@@ -439,30 +781,30 @@ func StringToNumberImpl(args *Data, env *SymbolTableFrame) (result *Data, err er
 
 func minInts(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	n := Car(args)
-	if !IntegerP(n) {
-		err = ProcessError(fmt.Sprintf("min requires numbers, received %s", String(n)), env)
+	if !ExactIntegerP(n) {
+		err = ProcessTypeError(fmt.Sprintf("min requires numbers, received %s", String(n)), env)
 		return
 	}
-	var acc int64 = IntegerValue(n)
+	acc := n
 
 	for c := Cdr(args); NotNilP(c); c = Cdr(c) {
 		n = Car(c)
-		if !IntegerP(n) {
-			err = ProcessError(fmt.Sprintf("min requires numbers, received %s", String(n)), env)
+		if !ExactIntegerP(n) {
+			err = ProcessTypeError(fmt.Sprintf("min requires numbers, received %s", String(n)), env)
 			return
 		}
-		if IntegerValue(n) < acc {
-			acc = IntegerValue(n)
+		if order, _ := numberOrder(n, acc); order < 0 {
+			acc = n
 		}
 	}
 
-	return IntegerWithValue(acc), nil
+	return acc, nil
 }
 
 func minFloats(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	n := Car(args)
 	if !NumberP(n) {
-		err = ProcessError(fmt.Sprintf("min requires numbers, received %s", String(n)), env)
+		err = ProcessTypeError(fmt.Sprintf("min requires numbers, received %s", String(n)), env)
 		return
 	}
 	var acc float32 = FloatValue(n)
@@ -470,7 +812,7 @@ func minFloats(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	for c := Cdr(args); NotNilP(c); c = Cdr(c) {
 		n = Car(c)
 		if !NumberP(n) {
-			err = ProcessError(fmt.Sprintf("min requires numbers, received %s", String(n)), env)
+			err = ProcessTypeError(fmt.Sprintf("min requires numbers, received %s", String(n)), env)
 			return
 		}
 		if FloatValue(n) < acc {
@@ -481,11 +823,15 @@ func minFloats(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	return FloatWithValue(acc), nil
 }
 
+// MinImpl handles (min n...), as well as (min list-of-numbers)
 func MinImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	numbers := Car(args)
-	if !ListP(numbers) {
-		err = ProcessError(fmt.Sprintf("min requires a list of numbers, received %s", String(numbers)), env)
-		return
+	numbers := args
+	if Length(args) == 1 && !NumberP(Car(args)) {
+		numbers = Car(args)
+		if !ListP(numbers) {
+			err = ProcessTypeError(fmt.Sprintf("min requires numbers or a list of numbers, received %s", String(numbers)), env)
+			return
+		}
 	}
 	if Length(numbers) == 0 {
 		return IntegerWithValue(0), nil
@@ -504,30 +850,30 @@ func MinImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 
 func maxInts(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	n := Car(args)
-	if !IntegerP(n) {
-		err = ProcessError(fmt.Sprintf("max requires numbers, received %s", String(n)), env)
+	if !ExactIntegerP(n) {
+		err = ProcessTypeError(fmt.Sprintf("max requires numbers, received %s", String(n)), env)
 		return
 	}
-	var acc int64 = IntegerValue(n)
+	acc := n
 
 	for c := Cdr(args); NotNilP(c); c = Cdr(c) {
 		n = Car(c)
-		if !IntegerP(n) {
-			err = ProcessError(fmt.Sprintf("max requires numbers, received %s", String(n)), env)
+		if !ExactIntegerP(n) {
+			err = ProcessTypeError(fmt.Sprintf("max requires numbers, received %s", String(n)), env)
 			return
 		}
-		if IntegerValue(n) > acc {
-			acc = IntegerValue(n)
+		if order, _ := numberOrder(n, acc); order > 0 {
+			acc = n
 		}
 	}
 
-	return IntegerWithValue(acc), nil
+	return acc, nil
 }
 
 func maxFloats(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	n := Car(args)
 	if !NumberP(n) {
-		err = ProcessError(fmt.Sprintf("max requires numbers, received %s", String(n)), env)
+		err = ProcessTypeError(fmt.Sprintf("max requires numbers, received %s", String(n)), env)
 		return
 	}
 	var acc float32 = FloatValue(n)
@@ -535,7 +881,7 @@ func maxFloats(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	for c := Cdr(args); NotNilP(c); c = Cdr(c) {
 		n = Car(c)
 		if !NumberP(n) {
-			err = ProcessError(fmt.Sprintf("max requires numbers, received %s", String(n)), env)
+			err = ProcessTypeError(fmt.Sprintf("max requires numbers, received %s", String(n)), env)
 			return
 		}
 		if FloatValue(n) > acc {
@@ -546,11 +892,15 @@ func maxFloats(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	return FloatWithValue(acc), nil
 }
 
+// MaxImpl handles (max n...), as well as (max list-of-numbers)
 func MaxImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	numbers := Car(args)
-	if !ListP(numbers) {
-		err = ProcessError(fmt.Sprintf("max requires a list of numbers, received %s", String(numbers)), env)
-		return
+	numbers := args
+	if Length(args) == 1 && !NumberP(Car(args)) {
+		numbers = Car(args)
+		if !ListP(numbers) {
+			err = ProcessTypeError(fmt.Sprintf("max requires numbers or a list of numbers, received %s", String(numbers)), env)
+			return
+		}
 	}
 
 	if Length(numbers) == 0 {
@@ -568,47 +918,116 @@ func MaxImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	}
 }
 
-func FloorImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+// roundNumber rounds the number in args with round.  Exact integers are already whole so
+// they are returned as they are; floats stay floats.
+func roundNumber(name string, round func(float64) float64, args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	val := Car(args)
 
 	if !NumberP(val) {
-		err = ProcessError(fmt.Sprintf("floor expected an number, received %s", String(Car(args))), env)
+		err = ProcessTypeError(fmt.Sprintf("%s expected a number, received %s", name, String(val)), env)
 		return
 	}
 
-	return FloatWithValue(float32(math.Floor(float64(FloatValue(val))))), nil
+	if ExactIntegerP(val) {
+		return val, nil
+	}
+	return FloatWithValue(float32(round(float64(FloatValue(val))))), nil
+}
+
+func FloorImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return roundNumber("floor", math.Floor, args, env)
 }
 
 func CeilingImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return roundNumber("ceiling", math.Ceil, args, env)
+}
+
+// RoundImpl rounds to the nearest integer, with halves going to the even one
+func RoundImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return roundNumber("round", math.RoundToEven, args, env)
+}
+
+// TruncateImpl rounds toward zero
+func TruncateImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return roundNumber("truncate", math.Trunc, args, env)
+}
+
+// Integers and bignums are exact; floats are inexact.
+
+func IsExactImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	val := Car(args)
+	if !NumberP(val) {
+		err = ProcessTypeError(fmt.Sprintf("exact? expected a number, received %s", String(val)), env)
+		return
+	}
+	return BooleanWithValue(ExactIntegerP(val)), nil
+}
 
+func IsInexactImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	val := Car(args)
 	if !NumberP(val) {
-		err = ProcessError(fmt.Sprintf("ceiling expected a number, received %s", String(Car(args))), env)
+		err = ProcessTypeError(fmt.Sprintf("inexact? expected a number, received %s", String(val)), env)
 		return
 	}
+	return BooleanWithValue(FloatP(val)), nil
+}
 
-	return FloatWithValue(float32(math.Ceil(float64(FloatValue(val))))), nil
+func ExactToInexactImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	val := Car(args)
+	if !NumberP(val) {
+		err = ProcessTypeError(fmt.Sprintf("exact->inexact expected a number, received %s", String(val)), env)
+		return
+	}
+	return FloatWithValue(FloatValue(val)), nil
+}
+
+// InexactToExactImpl converts a float to the integer with the same value, which may be a
+// bignum.  There are no exact fractions, so a float with a fractional part, or an infinity
+// or NaN, is an error.
+func InexactToExactImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	val := Car(args)
+	if !NumberP(val) {
+		err = ProcessTypeError(fmt.Sprintf("inexact->exact expected a number, received %s", String(val)), env)
+		return
+	}
+	if ExactIntegerP(val) {
+		return val, nil
+	}
+
+	f := float64(FloatValue(val))
+	if math.IsInf(f, 0) || math.IsNaN(f) || f != math.Trunc(f) {
+		err = ProcessError(fmt.Sprintf("inexact->exact: %s has no exact integer value", String(val)), env)
+		return
+	}
+	if f >= math.MinInt64 && f < math.MaxInt64 {
+		return IntegerWithValue(int64(f)), nil
+	}
+	n, _ := new(big.Float).SetFloat64(f).Int(nil)
+	return BigIntegerWithValue(n), nil
 }
 
 func AbsImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	val := Car(args)
 	if !NumberP(val) {
-		err = ProcessError(fmt.Sprintf("abs expected a number, received %s", String(Car(args))), env)
+		err = ProcessTypeError(fmt.Sprintf("abs expected a number, received %s", String(Car(args))), env)
 		return
 	}
-	absval := math.Abs(float64(FloatValue(val)))
+	if BigIntegerP(val) {
+		return BigIntegerWithValue(new(big.Int).Abs(BigIntegerValue(val))), nil
+	}
 	if IntegerP(val) {
-		result = IntegerWithValue(int64(absval))
-	} else {
-		result = FloatWithValue(float32(absval))
+		if IntegerValue(val) < 0 {
+			return negateInt(val), nil
+		}
+		return val, nil
 	}
-	return
+	return FloatWithValue(float32(math.Abs(float64(FloatValue(val))))), nil
 }
 
 func ZeroImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	val := Car(args)
 	if !NumberP(val) {
-		err = ProcessError(fmt.Sprintf("zero? expected a number, received %s", String(Car(args))), env)
+		err = ProcessTypeError(fmt.Sprintf("zero? expected a number, received %s", String(Car(args))), env)
 		return
 	}
 	return BooleanWithValue(FloatValue(val) == 0.0), nil
@@ -617,7 +1036,7 @@ func ZeroImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func PositiveImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	val := Car(args)
 	if !NumberP(val) {
-		err = ProcessError(fmt.Sprintf("positive? expected a number, received %s", String(Car(args))), env)
+		err = ProcessTypeError(fmt.Sprintf("positive? expected a number, received %s", String(Car(args))), env)
 		return
 	}
 	return BooleanWithValue(FloatValue(val) > 0.0), nil
@@ -626,7 +1045,7 @@ func PositiveImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func NegativeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	val := Car(args)
 	if !NumberP(val) {
-		err = ProcessError(fmt.Sprintf("negative expected a number, received %s", String(Car(args))), env)
+		err = ProcessTypeError(fmt.Sprintf("negative expected a number, received %s", String(Car(args))), env)
 		return
 	}
 	return BooleanWithValue(FloatValue(val) < 0.0), nil
@@ -634,26 +1053,26 @@ func NegativeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 
 func EvenImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	val := Car(args)
-	if !IntegerP(val) {
-		err = ProcessError(fmt.Sprintf("even? expected an integer, received %s", String(Car(args))), env)
+	if !ExactIntegerP(val) {
+		err = ProcessTypeError(fmt.Sprintf("even? expected an integer, received %s", String(Car(args))), env)
 		return
 	}
-	return BooleanWithValue(IntegerValue(val)%2 == 0), nil
+	return BooleanWithValue(bigIntegerOf(val).Bit(0) == 0), nil
 }
 
 func OddImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	val := Car(args)
-	if !IntegerP(val) {
-		err = ProcessError(fmt.Sprintf("odd? expected an integer, received %s", String(Car(args))), env)
+	if !ExactIntegerP(val) {
+		err = ProcessTypeError(fmt.Sprintf("odd? expected an integer, received %s", String(Car(args))), env)
 		return
 	}
-	return BooleanWithValue(IntegerValue(val)%2 != 0), nil
+	return BooleanWithValue(bigIntegerOf(val).Bit(0) != 0), nil
 }
 
 func SignImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	val := Car(args)
 	if !NumberP(val) {
-		err = ProcessError(fmt.Sprintf("sign expected a nunber, received %s", String(Car(args))), env)
+		err = ProcessTypeError(fmt.Sprintf("sign expected a nunber, received %s", String(Car(args))), env)
 		return
 	}
 
@@ -675,25 +1094,50 @@ func PowImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 
 	if areFloats {
 		return FloatWithValue(float32(math.Pow(float64(FloatValue(base)), float64(FloatValue(exponent))))), nil
+	} else if BigIntegerP(exponent) {
+		err = ProcessError(fmt.Sprintf("pow: exponent %s is too large", String(exponent)), env)
+		return
 	} else {
+		if BigIntegerP(base) || IntegerValue(exponent) <= 0 {
+			return powBigInts(base, exponent), nil
+		}
 		ret := int64(1)
 		b := IntegerValue(base)
 		e := IntegerValue(exponent)
 		for e > 0 {
+			var ok bool
 			if e&1 != 0 {
-				ret *= b
+				if ret, ok = multiplyInt64(ret, b); !ok {
+					return powBigInts(base, exponent), nil
+				}
 			}
-			b *= b
 			e >>= 1
+			if e > 0 {
+				if b, ok = multiplyInt64(b, b); !ok {
+					return powBigInts(base, exponent), nil
+				}
+			}
 		}
 		return IntegerWithValue(ret), nil
 	}
 }
 
+// powBigInts raises base to exponent, which are integers, as 1 for non-positive exponents
+func powBigInts(base *Data, exponent *Data) *Data {
+	if IntegerValue(exponent) <= 0 {
+		return IntegerWithValue(1)
+	}
+	return BigIntegerWithValue(new(big.Int).Exp(bigIntegerOf(base), bigIntegerOf(exponent), nil))
+}
+
+func IsBigIntegerImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(BigIntegerP(Car(args))), nil
+}
+
 func IsInfImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	val := Car(args)
 	if !NumberP(val) {
-		err = ProcessError(fmt.Sprintf("inf? expected a nunber, received %s", String(val)), env)
+		err = ProcessTypeError(fmt.Sprintf("inf? expected a nunber, received %s", String(val)), env)
 		return
 	}
 
@@ -707,7 +1151,7 @@ func IsInfImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func IsNaNImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	val := Car(args)
 	if !NumberP(val) {
-		err = ProcessError(fmt.Sprintf("nan? expected a nunber, received %s", String(val)), env)
+		err = ProcessTypeError(fmt.Sprintf("nan? expected a nunber, received %s", String(val)), env)
 		return
 	}
 
@@ -721,7 +1165,7 @@ func IsNaNImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func FloatToBitsImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	float := Car(args)
 	if !FloatP(float) {
-		err = ProcessError(fmt.Sprintf("float->bits expected a float, received %s", String(float)), env)
+		err = ProcessTypeError(fmt.Sprintf("float->bits expected a float, received %s", String(float)), env)
 		return
 	}
 
@@ -731,7 +1175,7 @@ func FloatToBitsImpl(args *Data, env *SymbolTableFrame) (result *Data, err error
 func BitsToFloatImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	bits := Car(args)
 	if !IntegerP(bits) {
-		err = ProcessError(fmt.Sprintf("bits->float expected an integer, received %s", String(bits)), env)
+		err = ProcessTypeError(fmt.Sprintf("bits->float expected an integer, received %s", String(bits)), env)
 		return
 	}
 
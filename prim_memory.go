@@ -0,0 +1,69 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains primitives for monitoring and managing memory use.
+
+package golisp
+
+import (
+	"runtime"
+)
+
+func RegisterMemoryPrimitives() {
+	MakePrimitiveFunction("memory-stats", "0", MemoryStatsImpl)
+	MakePrimitiveFunction("gc", "0", GcImpl)
+}
+
+// MemoryStats returns counts of the interpreter's long lived objects along with the Go
+// runtime's heap statistics, keyed by slot name
+func MemoryStats() map[string]int64 {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	internedSymbols.Mutex.RLock()
+	symbols := len(internedSymbols.Symbols)
+	internedSymbols.Mutex.RUnlock()
+
+	Global.Mutex.RLock()
+	globalBindings := len(Global.Bindings)
+	Global.Mutex.RUnlock()
+
+	TopLevelEnvironments.Mutex.RLock()
+	environments := len(TopLevelEnvironments.Environments)
+	TopLevelEnvironments.Mutex.RUnlock()
+
+	scheduledJobsMutex.Lock()
+	jobs := len(scheduledJobs)
+	scheduledJobsMutex.Unlock()
+
+	return map[string]int64{
+		"symbols:":           int64(symbols),
+		"global-bindings:":   int64(globalBindings),
+		"environments:":      int64(environments),
+		"scheduled-jobs:":    int64(jobs),
+		"goroutines:":        int64(runtime.NumGoroutine()),
+		"heap-alloc:":        int64(mem.HeapAlloc),
+		"heap-sys:":          int64(mem.HeapSys),
+		"heap-objects:":      int64(mem.HeapObjects),
+		"total-alloc:":       int64(mem.TotalAlloc),
+		"sys:":               int64(mem.Sys),
+		"gc-count:":          int64(mem.NumGC),
+		"gc-pause-total-ms:": int64(mem.PauseTotalNs / 1000000),
+	}
+}
+
+func MemoryStatsImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	m := FrameMap{}
+	m.Data = make(FrameMapData)
+	for key, value := range MemoryStats() {
+		m.Data[key] = IntegerWithValue(value)
+	}
+	return FrameWithValue(&m), nil
+}
+
+func GcImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	runtime.GC()
+	return
+}
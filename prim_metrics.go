@@ -0,0 +1,212 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the metrics primitive functions.
+
+package golisp
+
+import (
+	"fmt"
+	"math"
+)
+
+func RegisterMetricsPrimitives() {
+	MakePrimitiveFunction("define-metric", "2|3", DefineMetricImpl)
+	MakePrimitiveFunction("metric-inc!", "1|2", MetricIncImpl)
+	MakePrimitiveFunction("metric-set!", "2", MetricSetImpl)
+	MakePrimitiveFunction("metric-observe!", "2", MetricObserveImpl)
+	MakePrimitiveFunction("metric-value", "1", MetricValueImpl)
+	MakePrimitiveFunction("metric-names", "0", MetricNamesImpl)
+}
+
+func metricNameArg(name string, d *Data, env *SymbolTableFrame) (metricName string, err error) {
+	if !StringP(d) && !SymbolP(d) {
+		err = ProcessTypeError(fmt.Sprintf("%s expects a metric name as a string or symbol, but received %s.", name, String(d)), env)
+		return
+	}
+	return StringValue(d), nil
+}
+
+func metricNumberArg(name string, d *Data, env *SymbolTableFrame) (value float64, err error) {
+	if !NumberP(d) {
+		err = ProcessTypeError(fmt.Sprintf("%s expects a number, but received %s.", name, String(d)), env)
+		return
+	}
+	return numericValue(d), nil
+}
+
+// metricArg finds the metric named by d, defining it as kind if there isn't one yet
+func metricArg(name string, d *Data, kind MetricKind, env *SymbolTableFrame) (metric *Metric, err error) {
+	metricName, err := metricNameArg(name, d, env)
+	if err != nil {
+		return
+	}
+	if metric = LookupMetric(metricName); metric != nil {
+		return
+	}
+	if metric, err = DefineMetric(metricName, kind, "", nil); err != nil {
+		err = ProcessError(fmt.Sprintf("%s: %s.", name, err), env)
+	}
+	return
+}
+
+func metricNumber(value float64) *Data {
+	if value == math.Trunc(value) && math.Abs(value) < 1<<53 {
+		return IntegerWithValue(int64(value))
+	}
+	return FloatWithValue(float32(value))
+}
+
+// DefineMetricImpl handles (define-metric name kind [options]), where kind is counter,
+// gauge, or histogram, and options is a frame with help: and, for histograms, buckets:,
+// a list of increasing upper bounds
+func DefineMetricImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	metricName, err := metricNameArg("define-metric", Car(args), env)
+	if err != nil {
+		return
+	}
+
+	kindObj := Cadr(args)
+	kind := MetricKind(-1)
+	for k, kindName := range metricKindNames {
+		if (SymbolP(kindObj) || StringP(kindObj)) && StringValue(kindObj) == kindName {
+			kind = k
+		}
+	}
+	if kind < 0 {
+		err = ProcessTypeError(fmt.Sprintf("define-metric expects counter, gauge, or histogram, but received %s.", String(kindObj)), env)
+		return
+	}
+
+	var help string
+	var buckets []float64
+	if Length(args) == 3 {
+		options := Third(args)
+		if !FrameP(options) {
+			err = ProcessTypeError(fmt.Sprintf("define-metric expects a frame of options, but received %s.", String(options)), env)
+			return
+		}
+		frame := FrameValue(options)
+		if frame.HasSlot("help:") {
+			help = StringValue(frame.Get("help:"))
+		}
+		if frame.HasSlot("buckets:") {
+			bucketList := frame.Get("buckets:")
+			if !ListP(bucketList) {
+				err = ProcessTypeError(fmt.Sprintf("define-metric expects buckets: to be a list of numbers, but received %s.", String(bucketList)), env)
+				return
+			}
+			buckets = make([]float64, 0, Length(bucketList))
+			for c := bucketList; NotNilP(c); c = Cdr(c) {
+				var bound float64
+				if bound, err = metricNumberArg("define-metric", Car(c), env); err != nil {
+					return
+				}
+				buckets = append(buckets, bound)
+			}
+		}
+	}
+
+	if _, err = DefineMetric(metricName, kind, help, buckets); err != nil {
+		err = ProcessError(fmt.Sprintf("define-metric: %s.", err), env)
+		return
+	}
+	return Car(args), nil
+}
+
+// MetricIncImpl handles (metric-inc! name [amount]), which adds amount (default 1) to a
+// counter or gauge, defining a counter if there's no metric with that name yet
+func MetricIncImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	metric, err := metricArg("metric-inc!", Car(args), CounterMetric, env)
+	if err != nil {
+		return
+	}
+	amount := 1.0
+	if Length(args) == 2 {
+		if amount, err = metricNumberArg("metric-inc!", Cadr(args), env); err != nil {
+			return
+		}
+	}
+	if err = metric.Add(amount); err != nil {
+		err = ProcessError(fmt.Sprintf("metric-inc!: %s.", err), env)
+		return
+	}
+	return metricNumber(metric.Snapshot().Value), nil
+}
+
+// MetricSetImpl handles (metric-set! name value), defining a gauge if needed
+func MetricSetImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	metric, err := metricArg("metric-set!", Car(args), GaugeMetric, env)
+	if err != nil {
+		return
+	}
+	value, err := metricNumberArg("metric-set!", Cadr(args), env)
+	if err != nil {
+		return
+	}
+	if err = metric.Set(value); err != nil {
+		err = ProcessError(fmt.Sprintf("metric-set!: %s.", err), env)
+		return
+	}
+	return Cadr(args), nil
+}
+
+// MetricObserveImpl handles (metric-observe! name value), defining a histogram with the
+// default buckets if needed
+func MetricObserveImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	metric, err := metricArg("metric-observe!", Car(args), HistogramMetric, env)
+	if err != nil {
+		return
+	}
+	value, err := metricNumberArg("metric-observe!", Cadr(args), env)
+	if err != nil {
+		return
+	}
+	if err = metric.Observe(value); err != nil {
+		err = ProcessError(fmt.Sprintf("metric-observe!: %s.", err), env)
+		return
+	}
+	return Cadr(args), nil
+}
+
+// MetricValueImpl results in the value of a counter or gauge, or a frame with the count:,
+// sum:, and buckets: (an alist of upper bound to cumulative count) of a histogram.  It is
+// nil for names that aren't metrics.
+func MetricValueImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	metricName, err := metricNameArg("metric-value", Car(args), env)
+	if err != nil {
+		return
+	}
+	metric := LookupMetric(metricName)
+	if metric == nil {
+		return
+	}
+
+	snapshot := metric.Snapshot()
+	if metric.Kind != HistogramMetric {
+		return metricNumber(snapshot.Value), nil
+	}
+
+	buckets := make([]*Data, 0, len(snapshot.Buckets))
+	for i, bound := range snapshot.Buckets {
+		buckets = append(buckets, Cons(metricNumber(bound), IntegerWithValue(int64(snapshot.Counts[i]))))
+	}
+	m := FrameMap{}
+	m.Data = FrameMapData{
+		"count:":   IntegerWithValue(int64(snapshot.Count)),
+		"sum:":     metricNumber(snapshot.Sum),
+		"buckets:": ArrayToList(buckets),
+	}
+	return FrameWithValue(&m), nil
+}
+
+func MetricNamesImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	snapshots := MetricsSnapshot()
+	names := make([]*Data, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		names = append(names, StringWithValue(snapshot.Name))
+	}
+	return ArrayToList(names), nil
+}
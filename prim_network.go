@@ -0,0 +1,121 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the network diagnostic primitive functions.
+
+package golisp
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// defaultProbeTimeout is how long ping and port-open? wait for a connection by default
+const defaultProbeTimeout = time.Second
+
+func RegisterNetworkPrimitives() {
+	MakeRestrictedPrimitiveFunction("resolve-host", "1", ResolveHostImpl)
+	MakeRestrictedPrimitiveFunction("my-ip-addresses", "0", MyIpAddressesImpl)
+	MakeRestrictedPrimitiveFunction("ping", "2|3", PingImpl)
+	MakeRestrictedPrimitiveFunction("port-open?", "2|3", PortOpenImpl)
+}
+
+func stringsToList(strings []string) *Data {
+	elements := make([]*Data, 0, len(strings))
+	for _, s := range strings {
+		elements = append(elements, StringWithValue(s))
+	}
+	return ArrayToList(elements)
+}
+
+// ResolveHostImpl handles (resolve-host name), returning the list of its addresses
+func ResolveHostImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if !StringP(Car(args)) {
+		err = ProcessTypeError(fmt.Sprintf("resolve-host requires a host name but was given %s.", String(Car(args))), env)
+		return
+	}
+	addresses, lookupErr := net.LookupHost(StringValue(Car(args)))
+	if lookupErr != nil {
+		err = ioError(lookupErr)
+		return
+	}
+	return stringsToList(addresses), nil
+}
+
+// MyIpAddressesImpl returns the addresses of this machine's network interfaces, other than
+// loopback ones
+func MyIpAddressesImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	interfaceAddresses, addrErr := net.InterfaceAddrs()
+	if addrErr != nil {
+		err = ioError(addrErr)
+		return
+	}
+	addresses := make([]string, 0, len(interfaceAddresses))
+	for _, address := range interfaceAddresses {
+		if network, isIP := address.(*net.IPNet); isIP && !network.IP.IsLoopback() {
+			addresses = append(addresses, network.IP.String())
+		}
+	}
+	return stringsToList(addresses), nil
+}
+
+// probeArgs returns the host:port address and timeout given to ping or port-open? as
+// (name host port [timeout-millis])
+func probeArgs(name string, args *Data, env *SymbolTableFrame) (address string, timeout time.Duration, err error) {
+	host := Car(args)
+	if !StringP(host) {
+		err = ProcessTypeError(fmt.Sprintf("%s requires a host name but was given %s.", name, String(host)), env)
+		return
+	}
+	port := Cadr(args)
+	if !IntegerP(port) || IntegerValue(port) < 0 || IntegerValue(port) > 65535 {
+		err = ProcessTypeError(fmt.Sprintf("%s requires a port number but was given %s.", name, String(port)), env)
+		return
+	}
+	timeout = defaultProbeTimeout
+	if Length(args) == 3 {
+		if timeout, err = millisecondsArg(name, Caddr(args), env); err != nil {
+			return
+		}
+	}
+	return net.JoinHostPort(StringValue(host), strconv.FormatInt(IntegerValue(port), 10)), timeout, nil
+}
+
+// probe connects to address and hangs up, reporting how long connecting took
+func probe(address string, timeout time.Duration) (elapsed time.Duration, err error) {
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return
+	}
+	elapsed = time.Since(start)
+	conn.Close()
+	return
+}
+
+// PingImpl handles (ping host port [timeout-millis]), returning how many milliseconds it
+// took to connect to the port, or #f if it couldn't be
+func PingImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	address, timeout, err := probeArgs("ping", args, env)
+	if err != nil {
+		return
+	}
+	elapsed, probeErr := probe(address, timeout)
+	if probeErr != nil {
+		return LispFalse, nil
+	}
+	return FloatWithValue(float32(elapsed.Seconds() * 1000)), nil
+}
+
+func PortOpenImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	address, timeout, err := probeArgs("port-open?", args, env)
+	if err != nil {
+		return
+	}
+	_, probeErr := probe(address, timeout)
+	return BooleanWithValue(probeErr == nil), nil
+}
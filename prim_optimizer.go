@@ -0,0 +1,320 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the optional optimizer that simplifies definitions as they are made.
+
+package golisp
+
+import (
+	"fmt"
+)
+
+// Primitives that always give the same result for the same literal arguments and have no
+// side effects, so calls to them with literal arguments can be evaluated ahead of time
+var foldablePrimitives = map[string]bool{
+	"+": true, "-": true, "*": true, "/": true, "quotient": true, "%": true, "modulo": true,
+	"remainder": true, "truncate-quotient": true, "truncate-remainder": true, "floor-quotient": true,
+	"floor-remainder": true, "euclidean-quotient": true, "euclidean-remainder": true,
+	"gcd": true, "lcm": true, "min": true, "max": true,
+	"succ": true, "pred": true, "abs": true, "floor": true, "ceiling": true, "sign": true,
+	"round": true, "truncate": true, "pow": true, "expt": true, "integer": true, "float": true,
+	"exact?": true, "inexact?": true, "exact->inexact": true, "inexact": true,
+	"inexact->exact": true, "exact": true,
+	"zero?": true, "positive?": true, "negative?": true, "even?": true, "odd?": true,
+	"<": true, ">": true, "<=": true, ">=": true, "=": true, "==": true, "!=": true,
+	"eq?": true, "eqv?": true, "equal?": true, "neq?": true, "not": true, "!": true,
+}
+
+// Symbols that mark a lambda body as too complex to inline
+var nonInlinableForms = map[string]bool{
+	"lambda": true, "named-lambda": true, "define": true, "defmacro": true, "let": true,
+	"let*": true, "letrec": true, "do": true, "set!": true, "quasiquote": true,
+	"fluid-let": true,
+}
+
+func RegisterOptimizerPrimitives() {
+	MakePrimitiveFunction("optimize", "0|1", OptimizeImpl)
+	MakePrimitiveFunction("optimize-expression", "1", OptimizeExpressionImpl)
+}
+
+// literalP reports whether d evaluates to itself and can't be mutated
+func literalP(d *Data) bool {
+	return NumberP(d) || BooleanP(d) || StringP(d) || CharacterP(d) || NakedP(d)
+}
+
+func nonEmptyPairP(d *Data) bool {
+	return NotNilP(d) && PairP(d)
+}
+
+// Optimize returns sexpr with constant subexpressions pre-evaluated, arithmetic on literals
+// folded, if expressions with literal tests reduced to the chosen branch, and immediately
+// applied single expression lambdas with literal arguments inlined.  Symbols are resolved in
+// env, the environment sexpr will be evaluated in.  Anything the optimizer doesn't
+// understand, such as macro calls and quoted data, is left unchanged; sexpr itself is never
+// modified.
+func Optimize(sexpr *Data, env *SymbolTableFrame) *Data {
+	return optimize(sexpr, env, nil)
+}
+
+// shadowedBy returns shadowed extended with every symbol in names, which may be a parameter
+// list, a dotted parameter list or a single symbol
+func shadowedBy(shadowed map[string]bool, names ...*Data) map[string]bool {
+	extended := make(map[string]bool, len(shadowed)+len(names))
+	for name, _ := range shadowed {
+		extended[name] = true
+	}
+	var add func(d *Data)
+	add = func(d *Data) {
+		if SymbolP(d) {
+			extended[StringValue(d)] = true
+		} else if nonEmptyPairP(d) {
+			add(Car(d))
+			add(Cdr(d))
+		}
+	}
+	for _, name := range names {
+		add(name)
+	}
+	return extended
+}
+
+// globalValueOf returns the value of symbol in env, or nil if it is locally rebound within
+// the code being optimized
+func globalValueOf(symbol *Data, env *SymbolTableFrame, shadowed map[string]bool) *Data {
+	if !SymbolP(symbol) || shadowed[StringValue(symbol)] {
+		return nil
+	}
+	return env.ValueOf(symbol)
+}
+
+// specialFormNamed returns the name of the special form that head refers to, if any
+func specialFormNamed(head *Data, env *SymbolTableFrame, shadowed map[string]bool) string {
+	value := globalValueOf(head, env, shadowed)
+	if PrimitiveP(value) && PrimitiveValue(value).Special {
+		return PrimitiveValue(value).Name
+	}
+	return ""
+}
+
+func optimizeEach(sexprs *Data, env *SymbolTableFrame, shadowed map[string]bool) *Data {
+	if !nonEmptyPairP(sexprs) {
+		return sexprs
+	}
+	optimized := make([]*Data, 0, Length(sexprs))
+	for c := sexprs; NotNilP(c); c = Cdr(c) {
+		optimized = append(optimized, optimize(Car(c), env, shadowed))
+	}
+	return ArrayToList(optimized)
+}
+
+func optimizeLetBindings(bindings *Data, env *SymbolTableFrame, shadowed map[string]bool) *Data {
+	optimized := make([]*Data, 0, Length(bindings))
+	for c := bindings; NotNilP(c); c = Cdr(c) {
+		binding := Car(c)
+		if nonEmptyPairP(binding) && Length(binding) == 2 {
+			binding = InternalMakeList(Car(binding), optimize(Cadr(binding), env, shadowed))
+		}
+		optimized = append(optimized, binding)
+	}
+	return ArrayToList(optimized)
+}
+
+func letBoundNames(bindings *Data) []*Data {
+	names := make([]*Data, 0, Length(bindings))
+	for c := bindings; NotNilP(c); c = Cdr(c) {
+		if nonEmptyPairP(Car(c)) {
+			names = append(names, Caar(c))
+		} else {
+			names = append(names, Car(c))
+		}
+	}
+	return names
+}
+
+func optimizeSpecialForm(form string, sexpr *Data, env *SymbolTableFrame, shadowed map[string]bool) *Data {
+	head := Car(sexpr)
+	args := Cdr(sexpr)
+
+	switch form {
+	case "if":
+		optimizedArgs := optimizeEach(args, env, shadowed)
+		test := Car(optimizedArgs)
+		if literalP(test) {
+			if BooleanValue(test) {
+				return Second(optimizedArgs)
+			} else if Length(optimizedArgs) == 3 {
+				return Third(optimizedArgs)
+			}
+		}
+		return Cons(head, optimizedArgs)
+	case "when", "unless", "begin", "and", "or":
+		return Cons(head, optimizeEach(args, env, shadowed))
+	case "set!":
+		if Length(args) != 2 {
+			return sexpr
+		}
+		return InternalMakeList(head, Car(args), optimize(Cadr(args), env, shadowed))
+	case "cond", "case":
+		clauses := args
+		var key []*Data
+		if form == "case" {
+			key = []*Data{optimize(Car(args), env, shadowed)}
+			clauses = Cdr(args)
+		}
+		optimized := make([]*Data, 0, Length(clauses))
+		for c := clauses; NotNilP(c); c = Cdr(c) {
+			clause := Car(c)
+			if nonEmptyPairP(clause) {
+				test := Car(clause)
+				if form == "cond" {
+					test = optimize(test, env, shadowed)
+				}
+				clause = Cons(test, optimizeEach(Cdr(clause), env, shadowed))
+			}
+			optimized = append(optimized, clause)
+		}
+		return Cons(head, ArrayToListWithTail(key, ArrayToList(optimized)))
+	case "lambda", "named-lambda":
+		params := Car(args)
+		inner := shadowedBy(shadowed, params)
+		return Cons(head, Cons(params, optimizeEach(Cdr(args), env, inner)))
+	case "define":
+		target := Car(args)
+		if nonEmptyPairP(target) {
+			inner := shadowedBy(shadowed, target)
+			return Cons(head, Cons(target, optimizeEach(Cdr(args), env, inner)))
+		} else if Length(args) != 2 {
+			return sexpr
+		}
+		return InternalMakeList(head, target, optimize(Cadr(args), env, shadowed))
+	case "let", "let*", "letrec":
+		if SymbolP(Car(args)) {
+			bindings := Cadr(args)
+			inner := shadowedBy(shadowed, append(letBoundNames(bindings), Car(args))...)
+			return Cons(head, Cons(Car(args), Cons(optimizeLetBindings(bindings, env, inner), optimizeEach(Cddr(args), env, inner))))
+		}
+		bindings := Car(args)
+		inner := shadowedBy(shadowed, letBoundNames(bindings)...)
+		return Cons(head, Cons(optimizeLetBindings(bindings, env, inner), optimizeEach(Cdr(args), env, inner)))
+	default:
+		return sexpr
+	}
+}
+
+// inlinableBody reports whether body can have parameters substituted into it without
+// worrying about them being rebound or assigned
+func inlinableBody(body *Data) bool {
+	if SymbolP(body) {
+		return !nonInlinableForms[StringValue(body)]
+	}
+	if !nonEmptyPairP(body) {
+		return true
+	}
+	if SymbolP(Car(body)) && StringValue(Car(body)) == "quote" {
+		return true
+	}
+	for c := body; NotNilP(c); c = Cdr(c) {
+		if !nonEmptyPairP(c) || !inlinableBody(Car(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+func substitute(body *Data, bindings map[string]*Data) *Data {
+	if SymbolP(body) {
+		if value, found := bindings[StringValue(body)]; found {
+			return value
+		}
+		return body
+	}
+	if !nonEmptyPairP(body) || (SymbolP(Car(body)) && StringValue(Car(body)) == "quote") {
+		return body
+	}
+	substituted := make([]*Data, 0, Length(body))
+	for c := body; NotNilP(c); c = Cdr(c) {
+		substituted = append(substituted, substitute(Car(c), bindings))
+	}
+	return ArrayToList(substituted)
+}
+
+// inlineLambda turns ((lambda (x y) body) 1 2) into body with x and y replaced by 1 and 2,
+// returning nil if the application is anything more complicated than that
+func inlineLambda(lambda *Data, args *Data, env *SymbolTableFrame, shadowed map[string]bool) *Data {
+	if specialFormNamed(Car(lambda), env, shadowed) != "lambda" || Length(lambda) != 3 {
+		return nil
+	}
+	params := Cadr(lambda)
+	body := Caddr(lambda)
+	if Length(params) != Length(args) || !inlinableBody(body) {
+		return nil
+	}
+
+	bindings := make(map[string]*Data)
+	for p, a := params, args; NotNilP(p); p, a = Cdr(p), Cdr(a) {
+		if !nonEmptyPairP(p) || !SymbolP(Car(p)) || !literalP(Car(a)) {
+			return nil
+		}
+		bindings[StringValue(Car(p))] = Car(a)
+	}
+	return optimize(substitute(body, bindings), env, shadowed)
+}
+
+func optimize(sexpr *Data, env *SymbolTableFrame, shadowed map[string]bool) *Data {
+	if !nonEmptyPairP(sexpr) {
+		return sexpr
+	}
+
+	head := Car(sexpr)
+	if form := specialFormNamed(head, env, shadowed); form != "" {
+		return optimizeSpecialForm(form, sexpr, env, shadowed)
+	}
+
+	if nonEmptyPairP(head) {
+		args := optimizeEach(Cdr(sexpr), env, shadowed)
+		if inlined := inlineLambda(head, args, env, shadowed); inlined != nil {
+			return inlined
+		}
+		return Cons(optimize(head, env, shadowed), args)
+	}
+
+	// the arguments of macros and of names that aren't defined yet may not be expressions
+	function := globalValueOf(head, env, shadowed)
+	if !PrimitiveP(function) && !FunctionP(function) {
+		return sexpr
+	}
+
+	args := optimizeEach(Cdr(sexpr), env, shadowed)
+	if PrimitiveP(function) && foldablePrimitives[PrimitiveValue(function).Name] {
+		allLiteral := true
+		for a := args; NotNilP(a); a = Cdr(a) {
+			allLiteral = allLiteral && literalP(Car(a))
+		}
+		if allLiteral {
+			// errors, e.g. dividing by zero, are left to happen when the code runs
+			folded, err := PrimitiveValue(function).Apply(args, env)
+			if err == nil && (NumberP(folded) || BooleanP(folded) || CharacterP(folded)) {
+				return folded
+			}
+		}
+	}
+	return Cons(head, args)
+}
+
+func OptimizeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if NotNilP(args) {
+		if !BooleanP(Car(args)) {
+			err = ProcessTypeError(fmt.Sprintf("optimize expects a boolean but received %s.", String(Car(args))), env)
+			return
+		}
+		Config.OptimizeDefinitions = BooleanValue(Car(args))
+		OptimizeDefinitions = false
+	}
+	return BooleanWithValue(optimizingDefinitions()), nil
+}
+
+func OptimizeExpressionImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return Optimize(Car(args), env), nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the parallel mapping primitive functions.
+
+package golisp
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+func RegisterParallelPrimitives() {
+	MakePrimitiveFunction("pmap", ">=2", PmapImpl)
+	MakePrimitiveFunction("pvector-map", ">=2", PvectorMapImpl)
+}
+
+// parallelWorkers is how many goroutines to split count applications across
+func parallelWorkers(count int) int {
+	workers := Config.ParallelWorkers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > count {
+		workers = count
+	}
+	return workers
+}
+
+// parallelMap applies f to the index'th elements of columns for every index below count,
+// splitting the indices into contiguous runs, one per worker goroutine.  Each worker
+// evaluates in its own environment below env, so closures can run concurrently.  The
+// results are in order; if any applications fail, the error from the earliest is returned.
+func parallelMap(name string, f *Data, columns [][]*Data, count int, env *SymbolTableFrame) (results []*Data, err error) {
+	results = make([]*Data, count)
+	if count == 0 {
+		return
+	}
+
+	workers := parallelWorkers(count)
+	failures := make([]error, workers)
+	failedAt := int64(count)
+	var done sync.WaitGroup
+	done.Add(workers)
+	for worker := 0; worker < workers; worker++ {
+		start, end := worker*count/workers, (worker+1)*count/workers
+		go func(worker int, start int, end int) {
+			defer done.Done()
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					failures[worker] = errors.New(fmt.Sprintf("panic: %v", recovered))
+				}
+			}()
+
+			workerEnv := NewSymbolTableFrameBelow(env, name)
+			if failures[worker] = workerEnv.callFrom(env); failures[worker] != nil {
+				return
+			}
+			for index := start; index < end && int64(index) < atomic.LoadInt64(&failedAt); index++ {
+				args := make([]*Data, len(columns))
+				for column, values := range columns {
+					args[column] = values[index]
+				}
+				results[index], failures[worker] = ApplyWithoutEval(f, ArrayToList(args), workerEnv)
+				if failures[worker] != nil {
+					for failed := atomic.LoadInt64(&failedAt); int64(index) < failed; failed = atomic.LoadInt64(&failedAt) {
+						if atomic.CompareAndSwapInt64(&failedAt, failed, int64(index)) {
+							break
+						}
+					}
+					return
+				}
+			}
+		}(worker, start, end)
+	}
+	done.Wait()
+
+	for _, failure := range failures {
+		if failure != nil {
+			return nil, ProcessError(fmt.Sprintf("%s: %s", name, failure), env)
+		}
+	}
+	return
+}
+
+// parallelMapArgs checks the function and collections of (name f collection...), where
+// each collection satisfies isCollection and elementsOf gives its elements, and returns
+// the elements along with how many applications there are to make
+func parallelMapArgs(name string, args *Data, kind string, isCollection func(*Data) bool, elementsOf func(*Data) []*Data, env *SymbolTableFrame) (f *Data, columns [][]*Data, count int, err error) {
+	f = Car(args)
+	if !FunctionOrPrimitiveP(f) {
+		err = ProcessTypeError(fmt.Sprintf("%s needs a function as its first argument, but got %s.", name, String(f)), env)
+		return
+	}
+
+	count = -1
+	for a := Cdr(args); NotNilP(a); a = Cdr(a) {
+		if !isCollection(Car(a)) {
+			err = ProcessTypeError(fmt.Sprintf("%s needs %s as its other arguments, but got %s.", name, kind, String(Car(a))), env)
+			return
+		}
+		elements := elementsOf(Car(a))
+		columns = append(columns, elements)
+		if count < 0 || len(elements) < count {
+			count = len(elements)
+		}
+	}
+	return
+}
+
+// PmapImpl is map with the applications spread across goroutines, for lists long enough
+// and functions pure enough for that to pay off
+func PmapImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	f, columns, count, err := parallelMapArgs("pmap", args, "lists", ListP, ToArray, env)
+	if err != nil {
+		return
+	}
+	results, err := parallelMap("pmap", f, columns, count, env)
+	if err != nil {
+		return
+	}
+	return ArrayToList(results), nil
+}
+
+// PvectorMapImpl is pmap over vectors, resulting in a vector
+func PvectorMapImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	f, columns, count, err := parallelMapArgs("pvector-map", args, "vectors", VectorP, VectorValue, env)
+	if err != nil {
+		return
+	}
+	results, err := parallelMap("pvector-map", f, columns, count, env)
+	if err != nil {
+		return
+	}
+	return VectorWithValue(results), nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the plot primitive function.
+
+package golisp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+func RegisterPlotPrimitives() {
+	MakeRestrictedPrimitiveFunction("plot", "1|2", PlotImpl)
+}
+
+// plotNumbers reads a list or vector of numbers
+func plotNumbers(d *Data) (numbers []float64, ok bool) {
+	var elements []*Data
+	switch {
+	case VectorP(d):
+		elements = VectorValue(d)
+	case ListP(d):
+		elements = ToArray(d)
+	default:
+		return nil, false
+	}
+	numbers = make([]float64, 0, len(elements))
+	for _, element := range elements {
+		if !NumberP(element) {
+			return nil, false
+		}
+		if IntegerP(element) {
+			numbers = append(numbers, float64(IntegerValue(element)))
+		} else {
+			numbers = append(numbers, float64(FloatValue(element)))
+		}
+	}
+	return numbers, true
+}
+
+// plotSeries reads data, which is either one series of numbers or a list or vector of them
+func plotSeries(data *Data, env *SymbolTableFrame) (series [][]float64, err error) {
+	if numbers, ok := plotNumbers(data); ok && len(numbers) > 0 {
+		return [][]float64{numbers}, nil
+	}
+
+	var elements []*Data
+	if VectorP(data) {
+		elements = VectorValue(data)
+	} else if ListP(data) {
+		elements = ToArray(data)
+	}
+	for _, element := range elements {
+		numbers, ok := plotNumbers(element)
+		if !ok || len(numbers) == 0 {
+			elements = nil
+			break
+		}
+		series = append(series, numbers)
+	}
+	if len(elements) == 0 {
+		err = ProcessTypeError(fmt.Sprintf("plot expects a list or vector of numbers, or a list of them, but received %s.", String(data)), env)
+	}
+	return
+}
+
+func plotSizeOption(options *FrameMap, slot string, size *int, env *SymbolTableFrame) (err error) {
+	if options.HasSlot(slot) {
+		value := options.Get(slot)
+		if !IntegerP(value) || IntegerValue(value) < 100 || IntegerValue(value) > 10000 {
+			return ProcessTypeError(fmt.Sprintf("plot expects %s to be an integer from 100 to 10000, but received %s.", slot, String(value)), env)
+		}
+		*size = int(IntegerValue(value))
+	}
+	return
+}
+
+// openWithDesktop opens file with the desktop's default application, without waiting
+func openWithDesktop(file string) error {
+	var command *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		command = exec.Command("open", file)
+	case "windows":
+		command = exec.Command("rundll32", "url.dll,FileProtocolHandler", file)
+	default:
+		command = exec.Command("xdg-open", file)
+	}
+	return command.Start()
+}
+
+// PlotImpl handles (plot data [options]), which draws data as a chart.  Options is a frame
+// that can have:
+//
+//	type:    line (the default) or bar
+//	title:   a title for the chart
+//	names:   a list of names for the series, for a legend
+//	width:   and height: in pixels, 640 by 400 unless given
+//	file:    where to write the chart; .svg, .png, and .html files are supported
+//	open:    #t to open file once it's written
+//
+// The result is the file name, or the chart's SVG if there's no file:.
+func PlotImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	series, err := plotSeries(Car(args), env)
+	if err != nil {
+		return
+	}
+	chart := &Chart{Kind: "line", Width: 640, Height: 400, Series: series}
+
+	options := &FrameMap{Data: make(FrameMapData)}
+	if NotNilP(Cdr(args)) {
+		if !FrameP(Cadr(args)) {
+			err = ProcessTypeError(fmt.Sprintf("plot expects a frame of options, but received %s.", String(Cadr(args))), env)
+			return
+		}
+		options = FrameValue(Cadr(args))
+	}
+
+	if options.HasSlot("type:") {
+		kind := strings.TrimSuffix(StringValue(options.Get("type:")), ":")
+		if kind != "line" && kind != "bar" {
+			err = ProcessError(fmt.Sprintf("plot expects type: to be line or bar, but received %s.", String(options.Get("type:"))), env)
+			return
+		}
+		chart.Kind = kind
+	}
+	if options.HasSlot("title:") {
+		chart.Title = PrintString(options.Get("title:"))
+	}
+	if options.HasSlot("names:") {
+		for c := options.Get("names:"); NotNilP(c); c = Cdr(c) {
+			chart.Names = append(chart.Names, PrintString(Car(c)))
+		}
+	}
+	if err = plotSizeOption(options, "width:", &chart.Width, env); err != nil {
+		return
+	}
+	if err = plotSizeOption(options, "height:", &chart.Height, env); err != nil {
+		return
+	}
+
+	if !options.HasSlot("file:") {
+		return StringWithValue(chart.SVG()), nil
+	}
+
+	file := StringValue(options.Get("file:"))
+	var contents []byte
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".svg":
+		contents = []byte(chart.SVG())
+	case ".html", ".htm":
+		contents = []byte(chart.HTML())
+	case ".png":
+		if contents, err = chart.PNG(); err != nil {
+			err = ProcessError(fmt.Sprintf("plot: %s", err), env)
+			return
+		}
+	default:
+		err = ProcessError(fmt.Sprintf("plot can write .svg, .png, or .html files, not %s.", file), env)
+		return
+	}
+	if err = ioutil.WriteFile(file, contents, 0644); err != nil {
+		err = ProcessError(fmt.Sprintf("plot: %s", err), env)
+		return
+	}
+
+	if BooleanValue(options.Get("open:")) {
+		if err = openWithDesktop(file); err != nil {
+			err = ProcessError(fmt.Sprintf("plot couldn't open %s: %s", file, err), env)
+			return
+		}
+	}
+	return StringWithValue(file), nil
+}
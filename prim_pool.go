@@ -0,0 +1,261 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains worker pools and their primitive functions.
+
+package golisp
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// WorkerPool runs submitted functions on a fixed number of goroutines, each with its own
+// environment.  Submitting blocks while the queue is full, so a producer can't get
+// arbitrarily far ahead of the workers.  As with scheduled jobs, an error or panic in a
+// task is recorded and logged, but doesn't stop the pool.
+type WorkerPool struct {
+	Size      int
+	Runs      int64
+	Failures  int64
+	tasks     chan poolTask
+	pending   sync.WaitGroup
+	sending   sync.WaitGroup
+	workers   sync.WaitGroup
+	mutex     sync.Mutex
+	closed    bool
+	draining  int
+	lastError error
+	errMutex  sync.Mutex
+}
+
+type poolTask struct {
+	function *Data
+	args     *Data
+}
+
+func RegisterPoolPrimitives() {
+	MakePrimitiveFunction("make-pool", "1|2", MakePoolImpl)
+	MakePrimitiveFunction("pool?", "1", IsPoolImpl)
+	MakePrimitiveFunction("pool-submit!", ">=2", PoolSubmitImpl)
+	MakePrimitiveFunction("pool-drain!", "1", PoolDrainImpl)
+	MakePrimitiveFunction("pool-shutdown!", "1", PoolShutdownImpl)
+	MakePrimitiveFunction("pool-runs", "1", PoolRunsImpl)
+	MakePrimitiveFunction("pool-failures", "1", PoolFailuresImpl)
+	MakePrimitiveFunction("pool-last-error", "1", PoolLastErrorImpl)
+}
+
+// NewWorkerPool starts size workers taking tasks from a queue that holds up to queueSize
+// tasks that haven't been started yet.  Each worker runs tasks in its own environment
+// below env.
+func NewWorkerPool(size int, queueSize int, env *SymbolTableFrame) *WorkerPool {
+	pool := &WorkerPool{Size: size, tasks: make(chan poolTask, queueSize)}
+	pool.workers.Add(size)
+	for i := 0; i < size; i++ {
+		go pool.work(NewSymbolTableFrameBelow(env, fmt.Sprintf("pool worker %d", i+1)))
+	}
+	return pool
+}
+
+func (self *WorkerPool) work(env *SymbolTableFrame) {
+	defer self.workers.Done()
+	for task := range self.tasks {
+		self.run(task, env)
+	}
+}
+
+func (self *WorkerPool) run(task poolTask, env *SymbolTableFrame) {
+	defer self.pending.Done()
+
+	var err error
+	func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				err = errors.New(fmt.Sprintf("panic: %v", recovered))
+			}
+		}()
+		_, err = ApplyWithoutEval(task.function, task.args, env)
+	}()
+
+	atomic.AddInt64(&self.Runs, 1)
+	if err != nil {
+		atomic.AddInt64(&self.Failures, 1)
+		self.errMutex.Lock()
+		self.lastError = err
+		self.errMutex.Unlock()
+		LogErrorf("Pool task %s failed: %s\n", functionName(task.function), err)
+	}
+}
+
+// Submit queues function to be applied to args, waiting for room in the queue if it is
+// full.  Tasks can't be submitted once the pool is shut down, or while it is draining.
+// Submitting to a full pool from one of its own tasks can deadlock.
+func (self *WorkerPool) Submit(function *Data, args *Data) error {
+	self.mutex.Lock()
+	switch {
+	case self.closed:
+		self.mutex.Unlock()
+		return errors.New("the pool has been shut down")
+	case self.draining > 0:
+		self.mutex.Unlock()
+		return errors.New("the pool is draining")
+	}
+	self.pending.Add(1)
+	self.sending.Add(1)
+	self.mutex.Unlock()
+
+	defer self.sending.Done()
+	self.tasks <- poolTask{function: function, args: args}
+	return nil
+}
+
+// Drain waits until every task submitted so far has finished, refusing new ones until
+// then.  It mustn't be called from one of the pool's own tasks.
+func (self *WorkerPool) Drain() {
+	self.mutex.Lock()
+	self.draining++
+	self.mutex.Unlock()
+
+	self.pending.Wait()
+
+	self.mutex.Lock()
+	self.draining--
+	self.mutex.Unlock()
+}
+
+// Shutdown stops accepting tasks, lets the queued ones finish, and stops the workers
+func (self *WorkerPool) Shutdown() {
+	self.mutex.Lock()
+	closing := !self.closed
+	self.closed = true
+	self.mutex.Unlock()
+
+	if closing {
+		// submitters still waiting for room in the queue have to finish before it closes
+		self.sending.Wait()
+		close(self.tasks)
+	}
+	self.workers.Wait()
+}
+
+func (self *WorkerPool) LastError() error {
+	self.errMutex.Lock()
+	defer self.errMutex.Unlock()
+	return self.lastError
+}
+
+func poolArg(name string, args *Data, env *SymbolTableFrame) (pool *WorkerPool, err error) {
+	poolObj := Car(args)
+	if !ObjectP(poolObj) || ObjectType(poolObj) != "WorkerPool" {
+		err = ProcessTypeError(fmt.Sprintf("%s expects a WorkerPool object but received %s.", name, String(poolObj)), env)
+		return
+	}
+	pool = (*WorkerPool)(ObjectValue(poolObj))
+	return
+}
+
+func poolSizeArg(name string, d *Data, env *SymbolTableFrame) (size int, err error) {
+	if !IntegerP(d) || IntegerValue(d) < 1 || IntegerValue(d) > 4096 {
+		err = ProcessTypeError(fmt.Sprintf("%s expects an integer between 1 and 4096, but received %s.", name, String(d)), env)
+		return
+	}
+	return int(IntegerValue(d)), nil
+}
+
+// MakePoolImpl handles (make-pool size [queue-size]), where queue-size defaults to size
+func MakePoolImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	size, err := poolSizeArg("make-pool", Car(args), env)
+	if err != nil {
+		return
+	}
+	queueSize := size
+	if Length(args) == 2 {
+		queueSize, err = poolSizeArg("make-pool", Cadr(args), env)
+		if err != nil {
+			return
+		}
+	}
+	return ObjectWithTypeAndValue("WorkerPool", unsafe.Pointer(NewWorkerPool(size, queueSize, env))), nil
+}
+
+func IsPoolImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(ObjectP(Car(args)) && ObjectType(Car(args)) == "WorkerPool"), nil
+}
+
+// PoolSubmitImpl handles (pool-submit! pool function args...), waiting while the pool's
+// queue is full
+func PoolSubmitImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	pool, err := poolArg("pool-submit!", args, env)
+	if err != nil {
+		return
+	}
+
+	f := Cadr(args)
+	if !FunctionOrPrimitiveP(f) {
+		err = ProcessTypeError(fmt.Sprintf("pool-submit! expected a function, but received %s.", String(f)), env)
+		return
+	}
+	taskArgs := Cddr(args)
+	if FunctionP(f) && !acceptsArgCount(FunctionValue(f), Length(taskArgs)) {
+		err = ProcessErrorWithCategory(ArityError, fmt.Sprintf("pool-submit! expected a function that accepts %d arguments, but it requires %d.", Length(taskArgs), FunctionValue(f).RequiredArgCount), env)
+		return
+	}
+
+	if err = pool.Submit(f, taskArgs); err != nil {
+		err = ProcessError(fmt.Sprintf("pool-submit!: %s.", err), env)
+		return
+	}
+	return Car(args), nil
+}
+
+// PoolDrainImpl waits for the pool's tasks to finish, and results in how many have run
+func PoolDrainImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	pool, err := poolArg("pool-drain!", args, env)
+	if err != nil {
+		return
+	}
+	pool.Drain()
+	return IntegerWithValue(atomic.LoadInt64(&pool.Runs)), nil
+}
+
+// PoolShutdownImpl finishes the pool's tasks and stops it, resulting in how many have run
+func PoolShutdownImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	pool, err := poolArg("pool-shutdown!", args, env)
+	if err != nil {
+		return
+	}
+	pool.Shutdown()
+	return IntegerWithValue(atomic.LoadInt64(&pool.Runs)), nil
+}
+
+func PoolRunsImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	pool, err := poolArg("pool-runs", args, env)
+	if err != nil {
+		return
+	}
+	return IntegerWithValue(atomic.LoadInt64(&pool.Runs)), nil
+}
+
+func PoolFailuresImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	pool, err := poolArg("pool-failures", args, env)
+	if err != nil {
+		return
+	}
+	return IntegerWithValue(atomic.LoadInt64(&pool.Failures)), nil
+}
+
+func PoolLastErrorImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	pool, err := poolArg("pool-last-error", args, env)
+	if err != nil {
+		return
+	}
+	if lastError := pool.LastError(); lastError != nil {
+		return StringWithValue(lastError.Error()), nil
+	}
+	return
+}
@@ -9,57 +9,94 @@ package golisp
 
 import (
 	"fmt"
+	"math"
 )
 
 func RegisterRelativePrimitives() {
-	MakePrimitiveFunction("<", "2", LessThanImpl)
-	MakePrimitiveFunction(">", "2", GreaterThanImpl)
+	MakePrimitiveFunction("<", ">=2", LessThanImpl)
+	MakePrimitiveFunction(">", ">=2", GreaterThanImpl)
+	MakePrimitiveFunction("=", ">=2", NumericEqualImpl)
 	MakePrimitiveFunction("==", "2", EqualToImpl)
-	MakePrimitiveFunction("eqv?", "2", EqualToImpl)
+	MakePrimitiveFunction("eqv?", "2", EqvImpl)
 	MakePrimitiveFunction("eq?", "2", EqualToImpl)
-	MakePrimitiveFunction("equal?", "2", EqualToImpl)
+	MakePrimitiveFunction("equal?", "2", EqualImpl)
+	MakePrimitiveFunction("equal-hash", "1", EqualHashImpl)
 	MakePrimitiveFunction("!=", "2", NotEqualImpl)
 	MakePrimitiveFunction("neq?", "2", NotEqualImpl)
-	MakePrimitiveFunction("<=", "2", LessThanOrEqualToImpl)
-	MakePrimitiveFunction(">=", "2", GreaterThanOrEqualToImpl)
+	MakePrimitiveFunction("<=", ">=2", LessThanOrEqualToImpl)
+	MakePrimitiveFunction(">=", ">=2", GreaterThanOrEqualToImpl)
 	MakePrimitiveFunction("!", "1", BooleanNotImpl)
 	MakePrimitiveFunction("not", "1", BooleanNotImpl)
 	MakeSpecialForm("and", "*", BooleanAndImpl)
 	MakeSpecialForm("or", "*", BooleanOrImpl)
 }
 
-func LessThanImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	arg1 := Car(args)
-	if !NumberP(arg1) {
-		err = ProcessError(fmt.Sprintf("Number expected, received %s", String(arg1)), env)
-		return
+// numberOrder results in -1, 0, or 1 as a is less than, equal to, or greater than b,
+// comparing exactly even when integers and floats are mixed, and ordered is false when
+// either is NaN
+func numberOrder(a *Data, b *Data) (order int, ordered bool) {
+	if IntegerP(a) && IntegerP(b) {
+		x, y := IntegerValue(a), IntegerValue(b)
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
 	}
-
-	arg2 := Cadr(args)
-	if !NumberP(arg2) {
-		err = ProcessError(fmt.Sprintf("Number expected, received %s", String(arg2)), env)
-		return
+	if ExactIntegerP(a) && ExactIntegerP(b) {
+		return bigIntegerOf(a).Cmp(bigIntegerOf(b)), true
+	}
+	x, y := numericValue(a), numericValue(b)
+	if math.IsNaN(x) || math.IsNaN(y) {
+		return 0, false
 	}
+	if FloatP(a) && FloatP(b) {
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	return bigFloatOf(a).Cmp(bigFloatOf(b)), true
+}
 
-	val := FloatValue(arg1) < FloatValue(arg2)
+// compareChain handles the n-ary comparisons, which are true when holds is true of the
+// order of each adjacent pair of arguments, e.g. (< 1 2 3).  All arguments are checked to
+// be numbers, even after the result is known.
+func compareChain(args *Data, env *SymbolTableFrame, holds func(order int) bool) (result *Data, err error) {
+	val := true
+	var previous *Data
+	for c := args; NotNilP(c); c = Cdr(c) {
+		n := Car(c)
+		if !NumberP(n) {
+			err = ProcessTypeError(fmt.Sprintf("Number expected, received %s", String(n)), env)
+			return
+		}
+		if previous != nil && val {
+			order, ordered := numberOrder(previous, n)
+			val = ordered && holds(order)
+		}
+		previous = n
+	}
 	return BooleanWithValue(val), nil
 }
 
-func GreaterThanImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	arg1 := Car(args)
-	if !NumberP(arg1) {
-		err = ProcessError(fmt.Sprintf("Number expected, received %s", String(arg1)), env)
-		return
-	}
+func LessThanImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return compareChain(args, env, func(order int) bool { return order < 0 })
+}
 
-	arg2 := Cadr(args)
-	if !NumberP(arg2) {
-		err = ProcessError(fmt.Sprintf("Number expected, received %s", String(arg2)), env)
-		return
-	}
+func GreaterThanImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return compareChain(args, env, func(order int) bool { return order > 0 })
+}
 
-	val := FloatValue(arg1) > FloatValue(arg2)
-	return BooleanWithValue(val), nil
+// NumericEqualImpl handles =, which unlike == compares integers and floats by value and
+// requires numbers
+func NumericEqualImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return compareChain(args, env, func(order int) bool { return order == 0 })
 }
 
 func EqualToImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
@@ -68,6 +105,18 @@ func EqualToImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	return BooleanWithValue(IsEqual(arg1, arg2)), nil
 }
 
+func EqvImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(IsEqv(Car(args), Cadr(args))), nil
+}
+
+func EqualImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(IsDeepEqual(Car(args), Cadr(args))), nil
+}
+
+func EqualHashImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return IntegerWithValue(int64(Hash(Car(args)))), nil
+}
+
 func NotEqualImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	arg1 := Car(args)
 	arg2 := Cadr(args)
@@ -75,44 +124,21 @@ func NotEqualImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 }
 
 func LessThanOrEqualToImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	arg1 := Car(args)
-	if !NumberP(arg1) {
-		err = ProcessError(fmt.Sprintf("Number expected, received %s", String(arg1)), env)
-		return
-	}
-
-	arg2 := Cadr(args)
-	if !NumberP(arg2) {
-		err = ProcessError(fmt.Sprintf("Number expected, received %s", String(arg2)), env)
-		return
-	}
-
-	val := FloatValue(arg1) <= FloatValue(arg2)
-	return BooleanWithValue(val), nil
+	return compareChain(args, env, func(order int) bool { return order <= 0 })
 }
 
 func GreaterThanOrEqualToImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	arg1 := Car(args)
-	if !NumberP(arg1) {
-		err = ProcessError(fmt.Sprintf("Number expected, received %s", String(arg1)), env)
-		return
-	}
-
-	arg2 := Cadr(args)
-	if !NumberP(arg2) {
-		err = ProcessError(fmt.Sprintf("Number expected, received %s", String(arg2)), env)
-		return
-	}
-
-	val := FloatValue(arg1) >= FloatValue(arg2)
-	return BooleanWithValue(val), nil
+	return compareChain(args, env, func(order int) bool { return order >= 0 })
 }
 
 func BooleanNotImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	return BooleanWithValue(!BooleanValue(Car(args))), nil
 }
 
+// BooleanAndImpl evaluates its arguments until one is false, resulting in the value of the
+// last one evaluated.  (and) is #t.
 func BooleanAndImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	result = LispTrue
 	for c := args; NotNilP(c); c = Cdr(c) {
 		result, err = Eval(Car(c), env)
 		if err != nil || !BooleanValue(result) {
@@ -122,7 +148,10 @@ func BooleanAndImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 	return
 }
 
+// BooleanOrImpl evaluates its arguments until one is true, resulting in the value of the
+// last one evaluated.  (or) is #f.
 func BooleanOrImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	result = LispFalse
 	for c := args; NotNilP(c); c = Cdr(c) {
 		result, err = Eval(Car(c), env)
 		if err != nil || BooleanValue(result) {
@@ -0,0 +1,214 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the ring buffer primitive functions.
+
+package golisp
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+// Ring is a fixed capacity circular buffer.  Putting into a full ring overwrites the oldest item.
+type Ring struct {
+	Items []*Data
+	Start int
+	Count int
+	Mutex sync.Mutex
+}
+
+func (self *Ring) put(item *Data) {
+	end := (self.Start + self.Count) % len(self.Items)
+	self.Items[end] = item
+	if self.Count == len(self.Items) {
+		self.Start = (self.Start + 1) % len(self.Items)
+	} else {
+		self.Count++
+	}
+}
+
+func (self *Ring) get() *Data {
+	item := self.Items[self.Start]
+	self.Items[self.Start] = nil
+	self.Start = (self.Start + 1) % len(self.Items)
+	self.Count--
+	return item
+}
+
+// contents returns the items oldest first
+func (self *Ring) contents() []*Data {
+	items := make([]*Data, self.Count)
+	for i := 0; i < self.Count; i++ {
+		items[i] = self.Items[(self.Start+i)%len(self.Items)]
+	}
+	return items
+}
+
+func RegisterRingPrimitives() {
+	MakePrimitiveFunction("make-ring", "1", MakeRingImpl)
+	MakePrimitiveFunction("ring?", "1", IsRingImpl)
+	MakePrimitiveFunction("ring-put!", "2", RingPutImpl)
+	MakePrimitiveFunction("ring-get", "1", RingGetImpl)
+	MakePrimitiveFunction("ring-peek", "1", RingPeekImpl)
+	MakePrimitiveFunction("ring-count", "1", RingCountImpl)
+	MakePrimitiveFunction("ring-capacity", "1", RingCapacityImpl)
+	MakePrimitiveFunction("ring-empty?", "1", RingEmptyImpl)
+	MakePrimitiveFunction("ring-full?", "1", RingFullImpl)
+	MakePrimitiveFunction("ring-clear!", "1", RingClearImpl)
+	MakePrimitiveFunction("ring->vector", "1", RingToVectorImpl)
+	MakePrimitiveFunction("ring->list", "1", RingToListImpl)
+}
+
+func ringArg(name string, args *Data, env *SymbolTableFrame) (r *Ring, err error) {
+	ringObj := Car(args)
+	if !ObjectP(ringObj) || ObjectType(ringObj) != "Ring" {
+		err = ProcessTypeError(fmt.Sprintf("%s expects a Ring object but received %s.", name, String(ringObj)), env)
+		return
+	}
+	r = (*Ring)(ObjectValue(ringObj))
+	return
+}
+
+func MakeRingImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	capacity := Car(args)
+	if !IntegerP(capacity) || IntegerValue(capacity) < 1 {
+		err = ProcessTypeError(fmt.Sprintf("make-ring expects a positive integer capacity but received %s.", String(capacity)), env)
+		return
+	}
+	if IntegerValue(capacity) > maxArrayElements {
+		err = ProcessError(fmt.Sprintf("make-ring can't make a ring of %d items, the most is %d.", IntegerValue(capacity), maxArrayElements), env)
+		return
+	}
+
+	r := &Ring{Items: make([]*Data, IntegerValue(capacity))}
+	return ObjectWithTypeAndValue("Ring", unsafe.Pointer(r)), nil
+}
+
+func IsRingImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(ObjectP(Car(args)) && ObjectType(Car(args)) == "Ring"), nil
+}
+
+func RingPutImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	r, err := ringArg("ring-put!", args, env)
+	if err != nil {
+		return
+	}
+
+	r.Mutex.Lock()
+	r.put(Cadr(args))
+	r.Mutex.Unlock()
+	return Car(args), nil
+}
+
+func RingGetImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	r, err := ringArg("ring-get", args, env)
+	if err != nil {
+		return
+	}
+
+	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+	if r.Count == 0 {
+		err = ProcessError("ring-get can not get from an empty ring.", env)
+		return
+	}
+	return r.get(), nil
+}
+
+func RingPeekImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	r, err := ringArg("ring-peek", args, env)
+	if err != nil {
+		return
+	}
+
+	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+	if r.Count == 0 {
+		err = ProcessError("ring-peek can not peek into an empty ring.", env)
+		return
+	}
+	return r.Items[r.Start], nil
+}
+
+func RingCountImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	r, err := ringArg("ring-count", args, env)
+	if err != nil {
+		return
+	}
+
+	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+	return IntegerWithValue(int64(r.Count)), nil
+}
+
+func RingCapacityImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	r, err := ringArg("ring-capacity", args, env)
+	if err != nil {
+		return
+	}
+	return IntegerWithValue(int64(len(r.Items))), nil
+}
+
+func RingEmptyImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	r, err := ringArg("ring-empty?", args, env)
+	if err != nil {
+		return
+	}
+
+	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+	return BooleanWithValue(r.Count == 0), nil
+}
+
+func RingFullImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	r, err := ringArg("ring-full?", args, env)
+	if err != nil {
+		return
+	}
+
+	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+	return BooleanWithValue(r.Count == len(r.Items)), nil
+}
+
+func RingClearImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	r, err := ringArg("ring-clear!", args, env)
+	if err != nil {
+		return
+	}
+
+	r.Mutex.Lock()
+	for i := range r.Items {
+		r.Items[i] = nil
+	}
+	r.Start = 0
+	r.Count = 0
+	r.Mutex.Unlock()
+	return Car(args), nil
+}
+
+func RingToVectorImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	r, err := ringArg("ring->vector", args, env)
+	if err != nil {
+		return
+	}
+
+	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+	return VectorWithValue(r.contents()), nil
+}
+
+func RingToListImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	r, err := ringArg("ring->list", args, env)
+	if err != nil {
+		return
+	}
+
+	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+	return ArrayToList(r.contents()), nil
+}
@@ -0,0 +1,241 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the rope primitive functions.
+
+package golisp
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// Rope is a string built from shared pieces, so appending to a large string, e.g. a device
+// log, doesn't copy it and taking part of one shares the original's memory.  A rope is a
+// string as far as every other primitive is concerned: StringP is true of it and StringValue
+// flattens it, once, the first time its contents are needed.
+type Rope struct {
+	left   *Rope
+	right  *Rope
+	leaf   string
+	length int
+	depth  int
+	once   sync.Once
+	flat   string
+}
+
+// Pieces up to this long are copied together rather than joined, so appending a character
+// at a time doesn't build a tree of tiny leaves
+const ropeLeafSize = 256
+
+// Deeper ropes are rebalanced so walking them stays cheap
+const maxRopeDepth = 48
+
+func ropeLeaf(s string) *Rope {
+	return &Rope{leaf: s, length: len(s)}
+}
+
+func (self *Rope) isLeaf() bool {
+	return self.left == nil
+}
+
+func (self *Rope) Len() int {
+	return self.length
+}
+
+func (self *Rope) String() string {
+	if self.isLeaf() {
+		return self.leaf
+	}
+	self.once.Do(func() {
+		var b strings.Builder
+		b.Grow(self.length)
+		self.writeTo(&b)
+		self.flat = b.String()
+	})
+	return self.flat
+}
+
+func (self *Rope) writeTo(b *strings.Builder) {
+	if self.isLeaf() {
+		b.WriteString(self.leaf)
+		return
+	}
+	self.left.writeTo(b)
+	self.right.writeTo(b)
+}
+
+func (self *Rope) leaves(into []*Rope) []*Rope {
+	if self.isLeaf() {
+		if self.length > 0 {
+			into = append(into, self)
+		}
+		return into
+	}
+	return self.right.leaves(self.left.leaves(into))
+}
+
+// ropeConcat joins left and right without copying either, unless both are small
+func ropeConcat(left *Rope, right *Rope) *Rope {
+	switch {
+	case left.length == 0:
+		return right
+	case right.length == 0:
+		return left
+	case left.isLeaf() && right.isLeaf() && left.length+right.length <= ropeLeafSize:
+		return ropeLeaf(left.leaf + right.leaf)
+	}
+
+	depth := left.depth
+	if right.depth > depth {
+		depth = right.depth
+	}
+	r := &Rope{left: left, right: right, length: left.length + right.length, depth: depth + 1}
+	if r.depth > maxRopeDepth {
+		return ropeBalanced(r.leaves(nil))
+	}
+	return r
+}
+
+// ropeBalanced builds a rope of minimal depth over leaves
+func ropeBalanced(leaves []*Rope) *Rope {
+	switch len(leaves) {
+	case 0:
+		return ropeLeaf("")
+	case 1:
+		return leaves[0]
+	}
+	middle := len(leaves) / 2
+	left := ropeBalanced(leaves[:middle])
+	right := ropeBalanced(leaves[middle:])
+	depth := left.depth
+	if right.depth > depth {
+		depth = right.depth
+	}
+	return &Rope{left: left, right: right, length: left.length + right.length, depth: depth + 1}
+}
+
+// Slice is the part of the rope from byte start up to end, sharing its leaves
+func (self *Rope) Slice(start int, end int) *Rope {
+	if start == 0 && end == self.length {
+		return self
+	}
+	if self.isLeaf() {
+		return ropeLeaf(self.leaf[start:end])
+	}
+	split := self.left.length
+	if end <= split {
+		return self.left.Slice(start, end)
+	}
+	if start >= split {
+		return self.right.Slice(start-split, end-split)
+	}
+	return ropeConcat(self.left.Slice(start, split), self.right.Slice(0, end-split))
+}
+
+func registerRopeEquality() {
+	RegisterObjectEquality("Rope",
+		func(a unsafe.Pointer, b unsafe.Pointer) bool {
+			return (*Rope)(a).String() == (*Rope)(b).String()
+		},
+		func(o unsafe.Pointer) uint64 {
+			// the same as the equivalent string
+			return hashBytes('s', []byte((*Rope)(o).String()))
+		})
+}
+
+func RopeWithValue(r *Rope) *Data {
+	return ObjectWithTypeAndValue("Rope", unsafe.Pointer(r))
+}
+
+func RopeP(d *Data) bool {
+	return ObjectP(d) && ObjectType(d) == "Rope"
+}
+
+func RopeValue(d *Data) *Rope {
+	if !RopeP(d) {
+		return nil
+	}
+	return (*Rope)(ObjectValue(d))
+}
+
+// ropeOf is the rope for the string or rope d
+func ropeOf(d *Data) *Rope {
+	if RopeP(d) {
+		return RopeValue(d)
+	}
+	return ropeLeaf(StringValue(d))
+}
+
+func RegisterRopePrimitives() {
+	registerRopeEquality()
+	MakePrimitiveFunction("rope", "*", RopeImpl)
+	MakePrimitiveFunction("rope-append", ">=1", RopeImpl)
+	MakePrimitiveFunction("rope?", "1", IsRopeImpl)
+	MakePrimitiveFunction("subrope", "2|3", SubropeImpl)
+	MakePrimitiveFunction("rope->string", "1", RopeToStringImpl)
+}
+
+// RopeImpl handles (rope string...) and (rope-append rope string...), which join strings and
+// ropes into a new rope without copying them
+func RopeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	r := ropeLeaf("")
+	for c := args; NotNilP(c); c = Cdr(c) {
+		if !StringP(Car(c)) {
+			err = ProcessTypeError(fmt.Sprintf("rope expects strings or ropes but received %s.", String(Car(c))), env)
+			return
+		}
+		r = ropeConcat(r, ropeOf(Car(c)))
+	}
+	return RopeWithValue(r), nil
+}
+
+func IsRopeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(RopeP(Car(args))), nil
+}
+
+// SubropeImpl handles (subrope rope start [end]), which shares the rope's memory rather than
+// copying the part it takes
+func SubropeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	theRope := Car(args)
+	if !StringP(theRope) {
+		err = ProcessTypeError(fmt.Sprintf("subrope expects a rope or string but received %s.", String(theRope)), env)
+		return
+	}
+	r := ropeOf(theRope)
+
+	startObj := Cadr(args)
+	if !IntegerP(startObj) {
+		err = ProcessTypeError(fmt.Sprintf("subrope expects an integer start but received %s.", String(startObj)), env)
+		return
+	}
+	start := int(IntegerValue(startObj))
+
+	end := r.Len()
+	if NotNilP(Cddr(args)) {
+		endObj := Caddr(args)
+		if !IntegerP(endObj) {
+			err = ProcessTypeError(fmt.Sprintf("subrope expects an integer end but received %s.", String(endObj)), env)
+			return
+		}
+		end = int(IntegerValue(endObj))
+	}
+
+	if start < 0 || end > r.Len() || start > end {
+		err = ProcessIndexError(fmt.Sprintf("subrope expects 0 <= start <= end <= %d, but received %d and %d.", r.Len(), start, end), env)
+		return
+	}
+	return RopeWithValue(r.Slice(start, end)), nil
+}
+
+func RopeToStringImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if !StringP(Car(args)) {
+		err = ProcessTypeError(fmt.Sprintf("rope->string expects a rope but received %s.", String(Car(args))), env)
+		return
+	}
+	return StringWithValue(StringValue(Car(args))), nil
+}
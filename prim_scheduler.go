@@ -0,0 +1,411 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the recurring job scheduler and its primitive functions.
+
+package golisp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// ScheduledJob runs a function every Interval (plus a random amount up to Jitter), or at
+// the times matched by Cron, until it is cancelled.  An error or panic in one run is
+// recorded and logged, but doesn't stop later runs.
+//
+// Jobs are only serialized against each other: a job can run while the host or another
+// goroutine is evaluating code.  Hosts that need to keep jobs out while they evaluate
+// can bracket that work with LockScheduledJobs and UnlockScheduledJobs.
+type ScheduledJob struct {
+	Interval  time.Duration
+	Jitter    time.Duration
+	Cron      *CronSchedule
+	Function  *Data
+	Args      *Data
+	Env       *SymbolTableFrame
+	Runs      int64
+	Failures  int64
+	id        int64
+	paused    int32
+	cancelled int32
+	stop      chan empty
+	object    *Data
+	omitJob   bool
+	lastError error
+	errMutex  sync.Mutex
+}
+
+var (
+	// Scheduled jobs are run one at a time so they never run concurrently with each other.
+	scheduledJobEntry sync.Mutex
+
+	scheduledJobs      = make(map[*ScheduledJob]bool)
+	scheduledJobsMutex sync.Mutex
+	lastScheduledJobId int64
+)
+
+func RegisterSchedulerPrimitives() {
+	MakePrimitiveFunction("pause-job!", "1", PauseJobImpl)
+	MakePrimitiveFunction("resume-job!", "1", ResumeJobImpl)
+	MakePrimitiveFunction("cancel-job!", "1", CancelJobImpl)
+	MakePrimitiveFunction("job?", "1", IsJobImpl)
+	MakePrimitiveFunction("job-paused?", "1", JobPausedImpl)
+	MakePrimitiveFunction("job-runs", "1", JobRunsImpl)
+	MakePrimitiveFunction("job-failures", "1", JobFailuresImpl)
+	MakePrimitiveFunction("job-last-error", "1", JobLastErrorImpl)
+	MakePrimitiveFunction("scheduled-jobs", "0", ScheduledJobsImpl)
+	MakePrimitiveFunction("schedule-cron", ">=2", ScheduleCronImpl)
+	MakePrimitiveFunction("cron-next", "1|2", CronNextImpl)
+}
+
+// StartScheduledJob begins running job in the background, passing the job object as the
+// function's first argument followed by job.Args (cron jobs may leave the job object out).
+func StartScheduledJob(job *ScheduledJob) *Data {
+	job.stop = make(chan empty)
+	job.id = atomic.AddInt64(&lastScheduledJobId, 1)
+	job.object = ObjectWithTypeAndValue("ScheduledJob", unsafe.Pointer(job))
+
+	scheduledJobsMutex.Lock()
+	scheduledJobs[job] = true
+	scheduledJobsMutex.Unlock()
+
+	go job.loop()
+	return job.object
+}
+
+// StopAllScheduledJobs cancels every running job, e.g. when the host is shutting down
+func StopAllScheduledJobs() {
+	scheduledJobsMutex.Lock()
+	jobs := make([]*ScheduledJob, 0, len(scheduledJobs))
+	for job, _ := range scheduledJobs {
+		jobs = append(jobs, job)
+	}
+	scheduledJobsMutex.Unlock()
+
+	for _, job := range jobs {
+		job.Cancel()
+	}
+}
+
+// LockScheduledJobs waits for any running job to finish and keeps jobs from starting
+// until UnlockScheduledJobs is called.  It must not be called from within a job.
+func LockScheduledJobs() {
+	scheduledJobEntry.Lock()
+}
+
+func UnlockScheduledJobs() {
+	scheduledJobEntry.Unlock()
+}
+
+func (self *ScheduledJob) nextDelay() time.Duration {
+	if self.Cron != nil {
+		now := CurrentTime()
+		next := self.Cron.Next(now)
+		if next.IsZero() {
+			return time.Duration(math.MaxInt64)
+		}
+		return next.Sub(now)
+	}
+	if self.Jitter <= 0 {
+		return self.Interval
+	}
+	return self.Interval + time.Duration(randomInt63n(int64(self.Jitter)))
+}
+
+func (self *ScheduledJob) loop() {
+	for {
+		timer := CurrentClock().NewTimer(self.nextDelay())
+		select {
+		case <-self.stop:
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		if atomic.LoadInt32(&self.paused) == 0 {
+			self.runOnce()
+		}
+	}
+}
+
+func (self *ScheduledJob) runOnce() {
+	scheduledJobEntry.Lock()
+	defer scheduledJobEntry.Unlock()
+	if atomic.LoadInt32(&self.cancelled) == 1 {
+		return
+	}
+
+	var err error
+	func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				err = errors.New(fmt.Sprintf("panic: %v", recovered))
+			}
+		}()
+		args := Cons(self.object, self.Args)
+		if self.omitJob {
+			args = self.Args
+		}
+		_, err = FunctionValue(self.Function).ApplyWithoutEval(args, self.Env)
+	}()
+
+	atomic.AddInt64(&self.Runs, 1)
+	if err != nil {
+		atomic.AddInt64(&self.Failures, 1)
+		self.errMutex.Lock()
+		self.lastError = err
+		self.errMutex.Unlock()
+		LogErrorf("Scheduled job %s failed: %s\n", FunctionValue(self.Function).Name, err)
+	}
+}
+
+func (self *ScheduledJob) Pause() {
+	atomic.StoreInt32(&self.paused, 1)
+}
+
+func (self *ScheduledJob) Resume() {
+	atomic.StoreInt32(&self.paused, 0)
+}
+
+// Cancel stops the job.  A run that is already in progress is allowed to finish.
+func (self *ScheduledJob) Cancel() {
+	if atomic.CompareAndSwapInt32(&self.cancelled, 0, 1) {
+		close(self.stop)
+		scheduledJobsMutex.Lock()
+		delete(scheduledJobs, self)
+		scheduledJobsMutex.Unlock()
+	}
+}
+
+func (self *ScheduledJob) LastError() error {
+	self.errMutex.Lock()
+	defer self.errMutex.Unlock()
+	return self.lastError
+}
+
+// durationArg accepts a duration, an integer number of milliseconds, or a string such as "1.5s"
+func durationArg(name string, d *Data, env *SymbolTableFrame) (duration time.Duration, err error) {
+	duration, err = durationValueArg(name, d, env)
+	if err != nil {
+		return
+	}
+
+	if duration < 0 {
+		err = ProcessTypeError(fmt.Sprintf("%s requires a non-negative duration, but received %s.", name, String(d)), env)
+	}
+	return
+}
+
+// ScheduleEveryImpl handles (schedule every: interval [jitter: amount] function args...)
+func ScheduleEveryImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	job := &ScheduledJob{Env: env}
+
+	job.Interval, err = durationArg("schedule every:", Car(args), env)
+	if err != nil {
+		return
+	}
+	if job.Interval == 0 {
+		err = ProcessError("schedule every: requires an interval greater than zero.", env)
+		return
+	}
+	args = Cdr(args)
+
+	if NakedP(Car(args)) && StringValue(Car(args)) == "jitter:" {
+		job.Jitter, err = durationArg("schedule jitter:", Cadr(args), env)
+		if err != nil {
+			return
+		}
+		args = Cddr(args)
+	}
+
+	f := Car(args)
+	if !FunctionP(f) {
+		err = ProcessTypeError(fmt.Sprintf("schedule expected a function, but received %s.", String(f)), env)
+		return
+	}
+
+	argsCount := Length(Cdr(args)) + 1
+	function := FunctionValue(f)
+	if !acceptsArgCount(function, argsCount) {
+		err = ProcessErrorWithCategory(ArityError, fmt.Sprintf("schedule expected a function that accepts %d arguments (the job and its arguments), but it requires %d.", argsCount, function.RequiredArgCount), env)
+		return
+	}
+
+	job.Function = f
+	job.Args = Cdr(args)
+	return StartScheduledJob(job), nil
+}
+
+func acceptsArgCount(function *Function, count int) bool {
+	if function.VarArgs {
+		return count >= function.RequiredArgCount
+	}
+	return count == function.RequiredArgCount
+}
+
+// ScheduleCronImpl handles (schedule-cron "*/5 * * * *" function args...).  The function
+// is passed the job object and the args, like jobs scheduled with every:, or just the args
+// if it doesn't accept the job.
+func ScheduleCronImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	spec := Car(args)
+	if !StringP(spec) {
+		err = ProcessTypeError(fmt.Sprintf("schedule-cron expected a cron expression string, but received %s.", String(spec)), env)
+		return
+	}
+	cron, err := ParseCron(StringValue(spec))
+	if err != nil {
+		err = ProcessError(fmt.Sprintf("schedule-cron: %s.", err), env)
+		return
+	}
+
+	f := Cadr(args)
+	if !FunctionP(f) {
+		err = ProcessTypeError(fmt.Sprintf("schedule-cron expected a function, but received %s.", String(f)), env)
+		return
+	}
+
+	job := &ScheduledJob{Cron: cron, Function: f, Args: Cddr(args), Env: env}
+	argsCount := Length(job.Args)
+	function := FunctionValue(f)
+	if !acceptsArgCount(function, argsCount+1) {
+		if !acceptsArgCount(function, argsCount) {
+			err = ProcessErrorWithCategory(ArityError, fmt.Sprintf("schedule-cron expected a function that accepts %d arguments (optionally preceded by the job), but it requires %d.", argsCount, function.RequiredArgCount), env)
+			return
+		}
+		job.omitJob = true
+	}
+
+	return StartScheduledJob(job), nil
+}
+
+// CronNextImpl returns the next time after the given time (or now) matched by a cron
+// expression, interpreted in the timezone of the given time.
+func CronNextImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	spec := Car(args)
+	if !StringP(spec) {
+		err = ProcessTypeError(fmt.Sprintf("cron-next expected a cron expression string, but received %s.", String(spec)), env)
+		return
+	}
+	cron, err := ParseCron(StringValue(spec))
+	if err != nil {
+		err = ProcessError(fmt.Sprintf("cron-next: %s.", err), env)
+		return
+	}
+
+	from := CurrentTime()
+	if Length(args) == 2 {
+		from, err = timeArg("cron-next", Cadr(args), env)
+		if err != nil {
+			return
+		}
+		cron.Location = from.Location()
+	}
+
+	next := cron.Next(from)
+	if next.IsZero() {
+		return
+	}
+	return TimeWithValue(next), nil
+}
+
+func jobArg(name string, args *Data, env *SymbolTableFrame) (job *ScheduledJob, err error) {
+	jobObj := Car(args)
+	if !ObjectP(jobObj) || ObjectType(jobObj) != "ScheduledJob" {
+		err = ProcessTypeError(fmt.Sprintf("%s expects a ScheduledJob object but received %s.", name, String(jobObj)), env)
+		return
+	}
+	job = (*ScheduledJob)(ObjectValue(jobObj))
+	return
+}
+
+func PauseJobImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	job, err := jobArg("pause-job!", args, env)
+	if err != nil {
+		return
+	}
+	job.Pause()
+	return Car(args), nil
+}
+
+func ResumeJobImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	job, err := jobArg("resume-job!", args, env)
+	if err != nil {
+		return
+	}
+	job.Resume()
+	return Car(args), nil
+}
+
+func CancelJobImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	job, err := jobArg("cancel-job!", args, env)
+	if err != nil {
+		return
+	}
+	job.Cancel()
+	return Car(args), nil
+}
+
+func IsJobImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(ObjectP(Car(args)) && ObjectType(Car(args)) == "ScheduledJob"), nil
+}
+
+func JobPausedImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	job, err := jobArg("job-paused?", args, env)
+	if err != nil {
+		return
+	}
+	return BooleanWithValue(atomic.LoadInt32(&job.paused) == 1), nil
+}
+
+func JobRunsImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	job, err := jobArg("job-runs", args, env)
+	if err != nil {
+		return
+	}
+	return IntegerWithValue(atomic.LoadInt64(&job.Runs)), nil
+}
+
+func JobFailuresImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	job, err := jobArg("job-failures", args, env)
+	if err != nil {
+		return
+	}
+	return IntegerWithValue(atomic.LoadInt64(&job.Failures)), nil
+}
+
+func JobLastErrorImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	job, err := jobArg("job-last-error", args, env)
+	if err != nil {
+		return
+	}
+	if lastError := job.LastError(); lastError != nil {
+		return StringWithValue(lastError.Error()), nil
+	}
+	return
+}
+
+// ScheduledJobsImpl returns the running jobs in the order they were scheduled
+func ScheduledJobsImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	scheduledJobsMutex.Lock()
+	jobs := make([]*ScheduledJob, 0, len(scheduledJobs))
+	for job, _ := range scheduledJobs {
+		jobs = append(jobs, job)
+	}
+	scheduledJobsMutex.Unlock()
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].id < jobs[j].id })
+	objects := make([]*Data, len(jobs))
+	for i, job := range jobs {
+		objects[i] = job.object
+	}
+	return ArrayToList(objects), nil
+}
@@ -0,0 +1,286 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the set primitive functions.
+
+package golisp
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// Set is a collection of distinct members, compared with equal?.  It is a hash table whose
+// keys are the members, so membership tests don't depend on the size of the set.
+type Set struct {
+	members *HashTable
+}
+
+func NewSet(members ...*Data) *Set {
+	set := &Set{members: NewHashTable()}
+	for _, member := range members {
+		set.Add(member)
+	}
+	return set
+}
+
+func (self *Set) Add(member *Data) {
+	self.members.Set(member, member)
+}
+
+// Remove removes member, returning whether it was there
+func (self *Set) Remove(member *Data) bool {
+	return self.members.Remove(member)
+}
+
+func (self *Set) Contains(member *Data) bool {
+	_, found := self.members.Get(member)
+	return found
+}
+
+func (self *Set) Count() int {
+	return self.members.Count()
+}
+
+// Members returns the members in no particular order, except in deterministic mode, where
+// the order is stable
+func (self *Set) Members() []*Data {
+	entries := self.members.entries()
+	members := make([]*Data, len(entries))
+	for i, entry := range entries {
+		members[i] = entry.key
+	}
+	return members
+}
+
+func RegisterSetPrimitives() {
+	MakePrimitiveFunction("set", "*", SetImpl)
+	MakePrimitiveFunction("set?", "1", IsSetImpl)
+	MakePrimitiveFunction("set-add!", ">=2", SetAddImpl)
+	MakePrimitiveFunction("set-remove!", ">=2", SetRemoveImpl)
+	MakePrimitiveFunction("set-contains?", "2", SetContainsImpl)
+	MakePrimitiveFunction("set-count", "1", SetCountImpl)
+	MakePrimitiveFunction("set-union", "*", SetUnionImpl)
+	MakePrimitiveFunction("set-intersection", ">=1", SetIntersectionImpl)
+	MakePrimitiveFunction("set-difference", ">=1", SetDifferenceImpl)
+	MakePrimitiveFunction("set-subset?", "2", SetSubsetImpl)
+	MakePrimitiveFunction("set->list", "1", SetToListImpl)
+	MakePrimitiveFunction("list->set", "1", ListToSetImpl)
+
+	RegisterObjectEquality("Set",
+		func(a unsafe.Pointer, b unsafe.Pointer) bool {
+			return (*Set)(a).equal((*Set)(b))
+		},
+		func(o unsafe.Pointer) uint64 {
+			return (*Set)(o).hash(maxHashDepth)
+		})
+	registerObjectHashWithDepth("Set",
+		func(o unsafe.Pointer, depth int) uint64 {
+			return (*Set)(o).hash(depth)
+		})
+}
+
+func (self *Set) equal(other *Set) bool {
+	if self.Count() != other.Count() {
+		return false
+	}
+	for _, member := range self.Members() {
+		if !other.Contains(member) {
+			return false
+		}
+	}
+	return true
+}
+
+// hash combines the hashes of the members so that it doesn't depend on their order.  Members
+// are hashed depth levels deep, so a set that contains itself still hashes.
+func (self *Set) hash(depth int) uint64 {
+	members := self.Members()
+	h := hashUint64('S', uint64(len(members)))
+	if depth == 0 {
+		return h
+	}
+	for _, member := range members {
+		h += hashHelper(member, depth-1)
+	}
+	return h
+}
+
+func SetWithValue(set *Set) *Data {
+	return ObjectWithTypeAndValue("Set", unsafe.Pointer(set))
+}
+
+func SetP(d *Data) bool {
+	return ObjectP(d) && ObjectType(d) == "Set"
+}
+
+func SetValue(d *Data) *Set {
+	if !SetP(d) {
+		return nil
+	}
+	return (*Set)(ObjectValue(d))
+}
+
+func setArg(name string, d *Data, env *SymbolTableFrame) (set *Set, err error) {
+	if !SetP(d) {
+		err = ProcessTypeError(fmt.Sprintf("%s expects a set but received %s.", name, String(d)), env)
+		return
+	}
+	return SetValue(d), nil
+}
+
+func setArgs(name string, args *Data, env *SymbolTableFrame) (sets []*Set, err error) {
+	sets = make([]*Set, 0, Length(args))
+	for c := args; NotNilP(c); c = Cdr(c) {
+		var set *Set
+		if set, err = setArg(name, Car(c), env); err != nil {
+			return
+		}
+		sets = append(sets, set)
+	}
+	return
+}
+
+func SetImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return SetWithValue(NewSet(ToArray(args)...)), nil
+}
+
+func IsSetImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(SetP(Car(args))), nil
+}
+
+// SetAddImpl handles (set-add! set member...), resulting in the set
+func SetAddImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	set, err := setArg("set-add!", Car(args), env)
+	if err != nil {
+		return
+	}
+	for c := Cdr(args); NotNilP(c); c = Cdr(c) {
+		set.Add(Car(c))
+	}
+	return Car(args), nil
+}
+
+// SetRemoveImpl handles (set-remove! set member...), resulting in the set
+func SetRemoveImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	set, err := setArg("set-remove!", Car(args), env)
+	if err != nil {
+		return
+	}
+	for c := Cdr(args); NotNilP(c); c = Cdr(c) {
+		set.Remove(Car(c))
+	}
+	return Car(args), nil
+}
+
+func SetContainsImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	set, err := setArg("set-contains?", Car(args), env)
+	if err != nil {
+		return
+	}
+	return BooleanWithValue(set.Contains(Cadr(args))), nil
+}
+
+func SetCountImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	set, err := setArg("set-count", Car(args), env)
+	if err != nil {
+		return
+	}
+	return IntegerWithValue(int64(set.Count())), nil
+}
+
+// SetUnionImpl results in a new set of the members of any of the sets
+func SetUnionImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	sets, err := setArgs("set-union", args, env)
+	if err != nil {
+		return
+	}
+	union := NewSet()
+	for _, set := range sets {
+		for _, member := range set.Members() {
+			union.Add(member)
+		}
+	}
+	return SetWithValue(union), nil
+}
+
+// SetIntersectionImpl results in a new set of the members of the first set that are in all
+// the others
+func SetIntersectionImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	sets, err := setArgs("set-intersection", args, env)
+	if err != nil {
+		return
+	}
+	intersection := NewSet()
+	for _, member := range sets[0].Members() {
+		inAll := true
+		for _, other := range sets[1:] {
+			if !other.Contains(member) {
+				inAll = false
+				break
+			}
+		}
+		if inAll {
+			intersection.Add(member)
+		}
+	}
+	return SetWithValue(intersection), nil
+}
+
+// SetDifferenceImpl results in a new set of the members of the first set that aren't in any
+// of the others
+func SetDifferenceImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	sets, err := setArgs("set-difference", args, env)
+	if err != nil {
+		return
+	}
+	difference := NewSet()
+	for _, member := range sets[0].Members() {
+		inAny := false
+		for _, other := range sets[1:] {
+			if other.Contains(member) {
+				inAny = true
+				break
+			}
+		}
+		if !inAny {
+			difference.Add(member)
+		}
+	}
+	return SetWithValue(difference), nil
+}
+
+// SetSubsetImpl handles (set-subset? a b), which is whether every member of a is in b
+func SetSubsetImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	sets, err := setArgs("set-subset?", args, env)
+	if err != nil {
+		return
+	}
+	for _, member := range sets[0].Members() {
+		if !sets[1].Contains(member) {
+			return BooleanWithValue(false), nil
+		}
+	}
+	return BooleanWithValue(true), nil
+}
+
+// SetToListImpl results in the members of the set in no particular order, though it is
+// stable in deterministic mode
+func SetToListImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	set, err := setArg("set->list", Car(args), env)
+	if err != nil {
+		return
+	}
+	return ArrayToList(set.Members()), nil
+}
+
+func ListToSetImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	l := Car(args)
+	if !ListP(l) {
+		err = ProcessTypeError(fmt.Sprintf("list->set expects a list but received %s.", String(l)), env)
+		return
+	}
+	return SetWithValue(NewSet(ToArray(l)...)), nil
+}
@@ -7,8 +7,6 @@
 
 package golisp
 
-var DebugTrace = false
-var LispTrace = false
 var quasiquoteLevel = 1
 
 func init() {
@@ -41,11 +39,44 @@ func InitBuiltins() {
 	RegisterAListPrimitives()
 	RegisterSystemPrimitives()
 	RegisterBytearrayPrimitives()
+	RegisterBytevectorPrimitives()
+	RegisterFirmwarePrimitives()
+	RegisterPlotPrimitives()
+	RegisterTerminalPrimitives()
+	RegisterClosureSerializationPrimitives()
+	RegisterVectorPrimitives()
+	RegisterArrayPrimitives()
+	RegisterCharacterPrimitives()
+	RegisterCharSetPrimitives()
+	RegisterLexerPrimitives()
+	RegisterCombinatorPrimitives()
+	RegisterConfigPrimitives()
+	RegisterFormEncodingPrimitives()
 	RegisterStringPrimitives()
+	RegisterRopePrimitives()
+	RegisterStringBuilderPrimitives()
 	RegisterDebugPrimitives()
 	RegisterFramePrimitives()
 	RegisterConcurrencyPrimitives()
+	RegisterParallelPrimitives()
 	RegisterEnvironmentPrimitives()
 	RegisterIOPrimitives()
+	RegisterNetworkPrimitives()
 	RegisterChannelPrimitives()
+	RegisterHeapPrimitives()
+	RegisterRingPrimitives()
+	RegisterHashTablePrimitives()
+	RegisterSetPrimitives()
+	RegisterSchedulerPrimitives()
+	RegisterPoolPrimitives()
+	RegisterMetricsPrimitives()
+	RegisterSpanPrimitives()
+	RegisterExtensionPrimitives()
+	RegisterLazyPrimitives()
+	RegisterDecimalPrimitives()
+	RegisterTimePrimitives()
+	RegisterOptimizerPrimitives()
+	RegisterMemoryPrimitives()
+	RegisterErrorPrimitives()
+	RegisterDesktopPrimitives()
 }
@@ -0,0 +1,87 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the tracing span primitive functions.
+
+package golisp
+
+import (
+	"fmt"
+	"strings"
+)
+
+func RegisterSpanPrimitives() {
+	MakeSpecialForm("with-span", ">=2", WithSpanImpl)
+	MakePrimitiveFunction("span-attribute!", "2", SpanAttributeImpl)
+}
+
+func spanAttributeKey(d *Data) string {
+	return strings.TrimSuffix(StringValue(d), ":")
+}
+
+// WithSpanImpl handles (with-span name attributes body...), evaluating body in a span that
+// is exported when body finishes, whether or not it fails.  attributes is a frame, or nil.
+func WithSpanImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	name, err := Eval(Car(args), env)
+	if err != nil {
+		return
+	}
+	if !StringP(name) && !SymbolP(name) {
+		err = ProcessTypeError(fmt.Sprintf("with-span expects a name as a string or symbol, but received %s.", String(name)), env)
+		return
+	}
+	attributes, err := Eval(Cadr(args), env)
+	if err != nil {
+		return
+	}
+	if NotNilP(attributes) && !FrameP(attributes) {
+		err = ProcessTypeError(fmt.Sprintf("with-span expects a frame of attributes, but received %s.", String(attributes)), env)
+		return
+	}
+
+	exporter := currentSpanExporter()
+	if exporter == nil {
+		return evaluateBody(Cddr(args), env)
+	}
+
+	span := newSpan(StringValue(name), currentSpan(env))
+	if NotNilP(attributes) {
+		frame := FrameValue(attributes)
+		frame.Mutex.RLock()
+		for key, value := range frame.Data {
+			span.SetAttribute(strings.TrimSuffix(key, ":"), value)
+		}
+		frame.Mutex.RUnlock()
+	}
+
+	spanEnv := NewSymbolTableFrameBelow(env, "with-span")
+	if err = spanEnv.callFrom(env); err != nil {
+		return
+	}
+	spanEnv.span = span
+
+	defer func() {
+		span.End = CurrentTime()
+		if err != nil {
+			span.Error = err.Error()
+		}
+		exporter.ExportSpan(span)
+	}()
+	return evaluateBody(Cddr(args), spanEnv)
+}
+
+// SpanAttributeImpl handles (span-attribute! key value), recording an attribute of the
+// innermost span.  It does nothing outside of a span.
+func SpanAttributeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	key := Car(args)
+	if !StringP(key) && !SymbolP(key) {
+		err = ProcessTypeError(fmt.Sprintf("span-attribute! expects a key as a string or symbol, but received %s.", String(key)), env)
+		return
+	}
+	if span := currentSpan(env); span != nil && span.Attributes != nil {
+		span.SetAttribute(spanAttributeKey(key), Cadr(args))
+	}
+	return Cadr(args), nil
+}
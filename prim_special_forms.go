@@ -20,10 +20,15 @@ func RegisterSpecialFormPrimitives() {
 	MakeSpecialForm("lambda", ">=1", LambdaImpl)
 	MakeSpecialForm("named-lambda", ">=1", NamedLambdaImpl)
 	MakeSpecialForm("define", ">=1", DefineImpl)
+	MakeSpecialForm("define-compiled", ">=2", DefineCompiledImpl)
+	MakePrimitiveFunction("compiled?", "1", IsCompiledImpl)
+	MakeSpecialForm("define-deprecated", "3", DefineDeprecatedImpl)
+	MakeSpecialForm("define-record-type", ">=3", DefineRecordTypeImpl)
 	MakeSpecialForm("defmacro", ">=1", DefmacroImpl)
 	MakeSpecialForm("let", ">=1", LetImpl)
 	MakeSpecialForm("let*", ">=1", LetStarImpl)
 	MakeSpecialForm("letrec", ">=1", LetRecImpl)
+	MakeSpecialForm("fluid-let", ">=1", FluidLetImpl)
 	MakeSpecialForm("begin", "*", BeginImpl)
 	MakeSpecialForm("do", ">=2", DoImpl)
 	MakePrimitiveFunction("apply", ">=1", ApplyImpl)
@@ -42,6 +47,30 @@ func evaluateBody(sexprs *Data, env *SymbolTableFrame) (result *Data, err error)
 	return
 }
 
+// receiverClauseP reports whether the body of a cond or case clause is => receiver
+func receiverClauseP(body *Data) bool {
+	return SymbolP(Car(body)) && StringValue(Car(body)) == "=>"
+}
+
+// applyReceiver handles the body of a (test => receiver) clause, passing value to receiver
+func applyReceiver(form string, body *Data, value *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if Length(body) != 2 {
+		err = ProcessError(fmt.Sprintf("%s expects a single receiver after =>", form), env)
+		return
+	}
+	receiver, err := Eval(Cadr(body), env)
+	if err != nil {
+		return
+	}
+	if !FunctionOrPrimitiveP(receiver) {
+		err = ProcessTypeError(fmt.Sprintf("%s expects the receiver after => to be a function, but received %s.", form, String(receiver)), env)
+		return
+	}
+	return ApplyWithoutEval(receiver, InternalMakeList(value), env)
+}
+
+// CondImpl handles cond, whose clauses can be (test body...), (test => receiver), where
+// receiver is passed the value of test, or just (test), whose value is the value of test.
 func CondImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	var condition *Data
 	for c := args; NotNilP(c); c = Cdr(c) {
@@ -58,13 +87,27 @@ func CondImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 				return
 			}
 			if BooleanValue(condition) {
-				return evaluateBody(Cdr(clause), env)
+				body := Cdr(clause)
+				if NilP(body) {
+					return condition, nil
+				} else if receiverClauseP(body) {
+					return applyReceiver("cond", body, condition, env)
+				}
+				return evaluateBody(body, env)
 			}
 		}
 	}
 	return
 }
 
+// caseClauseBody evaluates the body of the case clause that matched keyValue
+func caseClauseBody(body *Data, keyValue *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if receiverClauseP(body) {
+		return applyReceiver("case", body, keyValue, env)
+	}
+	return evaluateBody(body, env)
+}
+
 func CaseImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	var keyValue *Data
 
@@ -80,11 +123,11 @@ func CaseImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 			return
 		}
 		if IsEqual(Car(clause), Intern("else")) {
-			return evaluateBody(Cdr(clause), env)
+			return caseClauseBody(Cdr(clause), keyValue, env)
 		} else if ListP(Car(clause)) {
 			for v := Car(clause); NotNilP(v); v = Cdr(v) {
 				if IsEqual(Car(v), keyValue) {
-					return evaluateBody(Cdr(clause), env)
+					return caseClauseBody(Cdr(clause), keyValue, env)
 				}
 			}
 		} else {
@@ -147,7 +190,7 @@ func UnlessImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 
 func LambdaImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if !PairP(Car(args)) {
-		err = ProcessError("A lambda requires a parameter list", env)
+		err = ProcessTypeError("A lambda requires a parameter list", env)
 		return
 	}
 	params := Car(args)
@@ -157,12 +200,12 @@ func LambdaImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 
 func NamedLambdaImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if !PairP(Car(args)) {
-		err = ProcessError("A lambda requires a name/parameter list", env)
+		err = ProcessTypeError("A lambda requires a name/parameter list", env)
 		return
 	}
 	name := Caar(args)
 	if !SymbolP(name) {
-		err = ProcessError("A named lambda requires a name that is a symbol", env)
+		err = ProcessTypeError("A named lambda requires a name that is a symbol", env)
 		return
 	}
 	params := Cdar(args)
@@ -174,7 +217,11 @@ func DefineImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	var value *Data
 	thing := Car(args)
 	if SymbolP(thing) {
-		value, err = Eval(Cadr(args), env)
+		valueExpression := Cadr(args)
+		if optimizingDefinitions() {
+			valueExpression = Optimize(valueExpression, env)
+		}
+		value, err = Eval(valueExpression, env)
 		if err != nil {
 			return
 		}
@@ -192,6 +239,9 @@ func DefineImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 			return
 		}
 		body := Cdr(args)
+		if optimizingDefinitions() {
+			body = optimizeEach(body, env, shadowedBy(nil, Car(args)))
+		}
 		value = FunctionWithNameParamsBodyAndParent(StringValue(name), params, body, env)
 	} else {
 		err = ProcessError("Invalid definition", env)
@@ -212,6 +262,10 @@ func DefmacroImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 			err = ProcessError("Macro name has to be a symbol", env)
 			return
 		}
+		if _, err = parseMacroLambdaList(params); err != nil {
+			err = ProcessErrorWithCategory(ParseError, fmt.Sprintf("Invalid lambda list for macro %s: %s", StringValue(name), err), env)
+			return
+		}
 		body := Cadr(args)
 		value = MacroWithNameParamsBodyAndParent(StringValue(name), params, body, env)
 	} else {
@@ -229,12 +283,12 @@ func bindLetLocals(bindingForms *Data, rec bool, localEnv *SymbolTableFrame, eva
 	for cell := bindingForms; NotNilP(cell); cell = Cdr(cell) {
 		bindingPair := Car(cell)
 		if !PairP(bindingPair) {
-			err = ProcessError("Let requires a list of bindings (with are pairs) as it's first argument", evalEnv)
+			err = ProcessTypeError("Let requires a list of bindings (with are pairs) as it's first argument", evalEnv)
 			return
 		}
 		name = Car(bindingPair)
 		if !SymbolP(name) {
-			err = ProcessError("First part of a let binding pair must be a symbol", evalEnv)
+			err = ProcessTypeError("First part of a let binding pair must be a symbol", evalEnv)
 			return
 		}
 
@@ -263,12 +317,14 @@ func bindLetLocals(bindingForms *Data, rec bool, localEnv *SymbolTableFrame, eva
 
 func LetCommon(args *Data, env *SymbolTableFrame, star bool, rec bool) (result *Data, err error) {
 	if !PairP(Car(args)) {
-		err = ProcessError("Let requires a list of bindings as it's first argument", env)
+		err = ProcessTypeError("Let requires a list of bindings as it's first argument", env)
 		return
 	}
 
 	localEnv := NewSymbolTableFrameBelow(env, "let")
-	localEnv.Previous = env
+	if err = localEnv.callFrom(env); err != nil {
+		return
+	}
 	var evalEnv *SymbolTableFrame
 	if star || rec {
 		evalEnv = localEnv
@@ -294,13 +350,13 @@ func LetCommon(args *Data, env *SymbolTableFrame, star bool, rec bool) (result *
 func namedLetImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	name := Car(args)
 	if !SymbolP(name) {
-		err = ProcessError("A named let requires a symbol name as its first argument", env)
+		err = ProcessTypeError("A named let requires a symbol name as its first argument", env)
 		return
 	}
 
 	bindings := Cadr(args)
 	if !PairP(bindings) {
-		err = ProcessError("A named let requires a list of bindings as it's second argument", env)
+		err = ProcessTypeError("A named let requires a list of bindings as it's second argument", env)
 		return
 	}
 	body := Cddr(args)
@@ -309,7 +365,7 @@ func namedLetImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	for remainingBindings := bindings; NotNilP(remainingBindings); remainingBindings = Cdr(remainingBindings) {
 		binding := Car(remainingBindings)
 		if !SymbolP(Car(binding)) {
-			err = ProcessError("The first element of a binding must be a symbol", env)
+			err = ProcessTypeError("The first element of a binding must be a symbol", env)
 			return
 		}
 		vars = append(vars, Car(binding))
@@ -318,7 +374,9 @@ func namedLetImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	varsList := ArrayToList(vars)
 	initialsList := ArrayToList(initials)
 	localEnv := NewSymbolTableFrameBelow(env, StringValue(name))
-	localEnv.Previous = env
+	if err = localEnv.callFrom(env); err != nil {
+		return
+	}
 	_, err = localEnv.BindLocallyTo(name, nil)
 	if err != nil {
 		return
@@ -347,6 +405,59 @@ func LetRecImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	return LetCommon(args, env, false, true)
 }
 
+// FluidLetImpl handles (fluid-let ((name value)...) body...), which assigns new values to
+// existing variables while body is evaluated and restores the old values afterwards, even
+// if body fails.  Unlike let, anything that sees the variables during body, including
+// functions defined elsewhere, sees the new values.
+func FluidLetImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	bindings := Car(args)
+	if !ListP(bindings) {
+		err = ProcessError("fluid-let requires a list of bindings as its first argument", env)
+		return
+	}
+
+	var names []*Data
+	var values []*Data
+	for cell := bindings; NotNilP(cell); cell = Cdr(cell) {
+		binding := Car(cell)
+		if !PairP(binding) || !SymbolP(Car(binding)) {
+			err = ProcessError(fmt.Sprintf("fluid-let expects bindings of the form (name value), but received %s", String(binding)), env)
+			return
+		}
+		name := Car(binding)
+		if _, found := env.FindBindingFor(name); !found {
+			err = ProcessError(fmt.Sprintf("fluid-let can only rebind existing variables, and %s is unbound", StringValue(name)), env)
+			return
+		}
+		var value *Data
+		value, err = Eval(Cadr(binding), env)
+		if err != nil {
+			return
+		}
+		names = append(names, name)
+		values = append(values, value)
+	}
+
+	oldValues := make([]*Data, len(names))
+	for i, name := range names {
+		oldValues[i] = env.ValueOf(name)
+	}
+	defer func() {
+		for i := len(names) - 1; i >= 0; i-- {
+			if _, restoreErr := env.SetTo(names[i], oldValues[i]); restoreErr != nil && err == nil {
+				err = restoreErr
+			}
+		}
+	}()
+
+	for i, name := range names {
+		if _, err = env.SetTo(name, values[i]); err != nil {
+			return
+		}
+	}
+	return evaluateBody(Cdr(args), env)
+}
+
 func BeginImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	for cell := args; NotNilP(cell); cell = Cdr(cell) {
 		sexpr := Car(cell)
@@ -391,18 +502,20 @@ func rebindDoLocals(bindingForms *Data, env *SymbolTableFrame) (err error) {
 func DoImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	bindings := Car(args)
 	if !PairP(bindings) {
-		err = ProcessError("Do requires a list of bindings as it's first argument", env)
+		err = ProcessTypeError("Do requires a list of bindings as it's first argument", env)
 		return
 	}
 
 	testClause := Cadr(args)
 	if !PairP(testClause) {
-		err = ProcessError("Do requires a list as it's second argument", env)
+		err = ProcessTypeError("Do requires a list as it's second argument", env)
 		return
 	}
 
 	localEnv := NewSymbolTableFrameBelow(env, "do")
-	localEnv.Previous = env
+	if err = localEnv.callFrom(env); err != nil {
+		return
+	}
 	err = bindLetLocals(bindings, false, localEnv, env)
 	if err != nil {
 		return
@@ -448,7 +561,7 @@ func ApplyImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	f := Car(args)
 
 	if !FunctionOrPrimitiveP(f) {
-		err = ProcessError(fmt.Sprintf("apply requires a function as it's first argument, but got %s.", String(f)), env)
+		err = ProcessTypeError(fmt.Sprintf("apply requires a function as it's first argument, but got %s.", String(f)), env)
 		return
 	}
 
@@ -461,7 +574,7 @@ func ApplyImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 			argList = ary[0]
 		}
 	} else {
-		err = ProcessError("The last argument to apply must be a list", env)
+		err = ProcessTypeError("The last argument to apply must be a list", env)
 		return
 	}
 
@@ -531,7 +644,7 @@ func DefinitionOfImpl(args *Data, env *SymbolTableFrame) (result *Data, err erro
 		return
 	}
 	if !FunctionP(f) {
-		err = ProcessError(fmt.Sprintf("code requires a function argument, but received a %s.", TypeName(TypeOf(f))), env)
+		err = ProcessTypeError(fmt.Sprintf("code requires a function argument, but received a %s.", TypeName(TypeOf(f))), env)
 		return
 	}
 
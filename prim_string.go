@@ -9,7 +9,10 @@ package golisp
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 const (
@@ -19,7 +22,7 @@ const (
 )
 
 func RegisterStringPrimitives() {
-	MakePrimitiveFunction("string-split", "2", StringSplitImpl)
+	MakePrimitiveFunction("string-split", "2|3", StringSplitImpl)
 	MakePrimitiveFunction("string-join", "1|2", StringJoinImpl)
 	MakePrimitiveFunction("string-trim", "1|2", StringTrimImpl)
 	MakePrimitiveFunction("string-trim-left", "1|2", StringTrimLeftImpl)
@@ -30,6 +33,7 @@ func RegisterStringPrimitives() {
 	MakePrimitiveFunction("string-downcase!", "1", StringDowncaseBangImpl)
 	MakePrimitiveFunction("string-capitalize", "1", StringCapitalizeImpl)
 	MakePrimitiveFunction("string-capitalize!", "1", StringCapitalizeBangImpl)
+	MakePrimitiveFunction("string-titlecase", "1", StringTitlecaseImpl)
 	MakePrimitiveFunction("string-length", "1", StringLengthImpl)
 	MakePrimitiveFunction("string-null?", "1", StringNullImpl)
 	MakePrimitiveFunction("substring", "3", SubstringImpl)
@@ -47,26 +51,89 @@ func RegisterStringPrimitives() {
 	MakePrimitiveFunction("string-ci<=?", "2", StringLessThanEqualCiImpl)
 	MakePrimitiveFunction("string>=?", "2", StringGreaterThanEqualImpl)
 	MakePrimitiveFunction("string-ci>=?", "2", StringGreaterThanEqualCiImpl)
+	MakePrimitiveFunction("string-natural<?", "2", StringNaturalLessThanImpl)
+	MakePrimitiveFunction("string-natural>?", "2", StringNaturalGreaterThanImpl)
+	MakePrimitiveFunction("string-natural-ci<?", "2", StringNaturalLessThanCiImpl)
 
 	MakePrimitiveFunction("parse", "1", ParseImpl)
 }
 
+// splitFunc splits s at every rune for which separator is true, into at most n pieces if n
+// is positive.  Adjacent separators have an empty piece between them, as with strings.Split.
+func splitFunc(s string, separator func(rune) bool, n int) (pieces []string) {
+	start := 0
+	for i, ch := range s {
+		if n > 0 && len(pieces) == n-1 {
+			break
+		}
+		if separator(ch) {
+			pieces = append(pieces, s[start:i])
+			start = i + utf8.RuneLen(ch)
+		}
+	}
+	return append(pieces, s[start:])
+}
+
+// StringSplitImpl handles (string-split string separator [options]).  The separator is a
+// string, a character, or a char-set, any of whose characters separate pieces.  Options is
+// a frame that can have:
+//
+//	regexp:     #t to treat a string separator as a regular expression
+//	limit:      the most pieces to split into; the last one holds the rest of the string
+//	omit-empty: #t to leave out empty pieces, like those between repeated separators
 func StringSplitImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	theString := Car(args)
 	if !StringP(theString) {
-		err = ProcessError(fmt.Sprintf("trim requires a string but was given %s.", String(theString)), env)
+		err = ProcessTypeError(fmt.Sprintf("trim requires a string but was given %s.", String(theString)), env)
 		return
 	}
 
+	options := &FrameMap{Data: make(FrameMapData)}
+	if NotNilP(Cddr(args)) {
+		if !FrameP(Caddr(args)) {
+			err = ProcessTypeError(fmt.Sprintf("string-split requires a frame of options but was given %s.", String(Caddr(args))), env)
+			return
+		}
+		options = FrameValue(Caddr(args))
+	}
+	limit := -1
+	if options.HasSlot("limit:") {
+		theLimit := options.Get("limit:")
+		if !IntegerP(theLimit) || IntegerValue(theLimit) < 1 {
+			err = ProcessTypeError(fmt.Sprintf("string-split requires limit: to be a positive integer but was given %s.", String(theLimit)), env)
+			return
+		}
+		limit = int(IntegerValue(theLimit))
+	}
+
+	var pieces []string
 	theSeparator := Cadr(args)
-	if !StringP(theSeparator) {
-		err = ProcessError(fmt.Sprintf("string-split requires a string separater but was given %s.", String(theSeparator)), env)
+	switch {
+	case StringP(theSeparator) && BooleanValue(options.Get("regexp:")):
+		pattern, compileErr := regexp.Compile(StringValue(theSeparator))
+		if compileErr != nil {
+			err = ProcessError(fmt.Sprintf("string-split was given a bad regexp: %s.", compileErr), env)
+			return
+		}
+		pieces = pattern.Split(StringValue(theString), limit)
+	case StringP(theSeparator):
+		pieces = strings.SplitN(StringValue(theString), StringValue(theSeparator), limit)
+	case CharacterP(theSeparator):
+		separator := CharacterValue(theSeparator)
+		pieces = splitFunc(StringValue(theString), func(ch rune) bool { return ch == separator }, limit)
+	case CharSetP(theSeparator):
+		pieces = splitFunc(StringValue(theString), CharSetValue(theSeparator).Contains, limit)
+	default:
+		err = ProcessTypeError(fmt.Sprintf("string-split requires a string, character, or char-set separater but was given %s.", String(theSeparator)), env)
 		return
 	}
 
-	pieces := strings.Split(StringValue(theString), StringValue(theSeparator))
+	omitEmpty := BooleanValue(options.Get("omit-empty:"))
 	ary := make([]*Data, 0, len(pieces))
 	for _, p := range pieces {
+		if omitEmpty && p == "" {
+			continue
+		}
 		ary = append(ary, StringWithValue(p))
 	}
 	return ArrayToList(ary), nil
@@ -74,8 +141,11 @@ func StringSplitImpl(args *Data, env *SymbolTableFrame) (result *Data, err error
 
 func StringJoinImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	theStrings := Car(args)
+	if VectorP(theStrings) {
+		theStrings = ArrayToList(VectorValue(theStrings))
+	}
 	if !ListP(theStrings) {
-		err = ProcessError(fmt.Sprintf("string-join requires a list of strings to be joined but was given %s.", String(theStrings)), env)
+		err = ProcessTypeError(fmt.Sprintf("string-join requires a list or vector of strings to be joined but was given %s.", String(theStrings)), env)
 		return
 	}
 
@@ -83,7 +153,7 @@ func StringJoinImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 	separator := ""
 	if !NilP(theSeparator) {
 		if !StringP(theSeparator) {
-			err = ProcessError(fmt.Sprintf("string-join requires a string separater but was given %s.", String(theSeparator)), env)
+			err = ProcessTypeError(fmt.Sprintf("string-join requires a string separater but was given %s.", String(theSeparator)), env)
 			return
 		}
 		separator = StringValue(theSeparator)
@@ -93,7 +163,7 @@ func StringJoinImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 	for c := theStrings; NotNilP(c); c = Cdr(c) {
 		val := Car(c)
 		if !StringP(val) {
-			err = ProcessError(fmt.Sprintf("string-join requires a list of strings but %s was in the list.", String(val)), env)
+			err = ProcessTypeError(fmt.Sprintf("string-join requires a list of strings but %s was in the list.", String(val)), env)
 			return
 		}
 		resultStrings = append(resultStrings, StringValue(val))
@@ -106,29 +176,36 @@ func doTrim(lrb int, args *Data, env *SymbolTableFrame) (result *Data, err error
 	theString := Car(args)
 
 	if !StringP(theString) {
-		err = ProcessError(fmt.Sprintf("string-trim requires a string but was given %s.", String(theString)), env)
+		err = ProcessTypeError(fmt.Sprintf("string-trim requires a string but was given %s.", String(theString)), env)
 		return
 	}
 
-	var trimset string
+	var trimmed func(rune) bool
 	if Length(args) == 2 {
 		theTrimSet := Cadr(args)
-		if !StringP(theTrimSet) {
-			err = ProcessError(fmt.Sprintf("string-trim requires a string set of trim characters but was given %s.", String(theTrimSet)), env)
+		switch {
+		case StringP(theTrimSet):
+			trimset := StringValue(theTrimSet)
+			trimmed = func(ch rune) bool { return strings.ContainsRune(trimset, ch) }
+		case CharacterP(theTrimSet):
+			trimChar := CharacterValue(theTrimSet)
+			trimmed = func(ch rune) bool { return ch == trimChar }
+		case CharSetP(theTrimSet):
+			trimmed = CharSetValue(theTrimSet).Contains
+		default:
+			err = ProcessTypeError(fmt.Sprintf("string-trim requires a string set of trim characters, a character, or a char-set but was given %s.", String(theTrimSet)), env)
 			return
 		}
-
-		trimset = StringValue(theTrimSet)
 	} else {
-		trimset = " \t\r\n\v\f"
+		trimmed = func(ch rune) bool { return strings.ContainsRune(" \t\r\n\v\f", ch) }
 	}
 	switch lrb {
 	case TrimLeft:
-		return StringWithValue(strings.TrimLeft(StringValue(theString), trimset)), nil
+		return StringWithValue(strings.TrimLeftFunc(StringValue(theString), trimmed)), nil
 	case TrimBoth:
-		return StringWithValue(strings.Trim(StringValue(theString), trimset)), nil
+		return StringWithValue(strings.TrimFunc(StringValue(theString), trimmed)), nil
 	case TrimRight:
-		return StringWithValue(strings.TrimRight(StringValue(theString), trimset)), nil
+		return StringWithValue(strings.TrimRightFunc(StringValue(theString), trimmed)), nil
 	default:
 		return theString, nil
 	}
@@ -149,7 +226,7 @@ func StringTrimRightImpl(args *Data, env *SymbolTableFrame) (result *Data, err e
 func StringUpcaseImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	theString := Car(args)
 	if !StringP(theString) {
-		err = ProcessError(fmt.Sprintf("string-upcase requires a string but was given %s.", String(theString)), env)
+		err = ProcessTypeError(fmt.Sprintf("string-upcase requires a string but was given %s.", String(theString)), env)
 		return
 	}
 	return StringWithValue(strings.ToUpper(StringValue(theString))), nil
@@ -158,7 +235,7 @@ func StringUpcaseImpl(args *Data, env *SymbolTableFrame) (result *Data, err erro
 func StringUpcaseBangImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	theString := Car(args)
 	if !StringP(theString) {
-		err = ProcessError(fmt.Sprintf("string-upcase! requires a string but was given %s.", String(theString)), env)
+		err = ProcessTypeError(fmt.Sprintf("string-upcase! requires a string but was given %s.", String(theString)), env)
 		return
 	}
 	return SetStringValue(theString, strings.ToUpper(StringValue(theString))), nil
@@ -167,7 +244,7 @@ func StringUpcaseBangImpl(args *Data, env *SymbolTableFrame) (result *Data, err
 func StringDowncaseImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	theString := Car(args)
 	if !StringP(theString) {
-		err = ProcessError(fmt.Sprintf("string-downcase requires a string but was given %s.", String(theString)), env)
+		err = ProcessTypeError(fmt.Sprintf("string-downcase requires a string but was given %s.", String(theString)), env)
 		return
 	}
 	return StringWithValue(strings.ToLower(StringValue(theString))), nil
@@ -176,7 +253,7 @@ func StringDowncaseImpl(args *Data, env *SymbolTableFrame) (result *Data, err er
 func StringDowncaseBangImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	theString := Car(args)
 	if !StringP(theString) {
-		err = ProcessError(fmt.Sprintf("string-downcase! requires a string but was given %s.", String(theString)), env)
+		err = ProcessTypeError(fmt.Sprintf("string-downcase! requires a string but was given %s.", String(theString)), env)
 		return
 	}
 	return SetStringValue(theString, strings.ToLower(StringValue(theString))), nil
@@ -195,7 +272,7 @@ func StringCapitalizeImpl(args *Data, env *SymbolTableFrame) (result *Data, err
 	theString := Car(args)
 
 	if !StringP(theString) {
-		err = ProcessError(fmt.Sprintf("string-capitalize requires a string but was given %s.", String(theString)), env)
+		err = ProcessTypeError(fmt.Sprintf("string-capitalize requires a string but was given %s.", String(theString)), env)
 		return
 	}
 	return StringWithValue(capitalize(StringValue(theString))), nil
@@ -205,19 +282,46 @@ func StringCapitalizeBangImpl(args *Data, env *SymbolTableFrame) (result *Data,
 	theString := Car(args)
 
 	if !StringP(theString) {
-		err = ProcessError(fmt.Sprintf("string-capitalize! requires a string but was given %s.", String(theString)), env)
+		err = ProcessTypeError(fmt.Sprintf("string-capitalize! requires a string but was given %s.", String(theString)), env)
 		return
 	}
 	return SetStringValue(theString, capitalize(StringValue(theString))), nil
 }
 
+// titlecase capitalizes each word of s, a word being a run of letters and digits
+func titlecase(s string) string {
+	inWord := false
+	return strings.Map(func(r rune) rune {
+		startsWord := !inWord
+		inWord = unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\''
+		if startsWord {
+			return unicode.ToTitle(r)
+		}
+		return unicode.ToLower(r)
+	}, s)
+}
+
+func StringTitlecaseImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	theString := Car(args)
+
+	if !StringP(theString) {
+		err = ProcessTypeError(fmt.Sprintf("string-titlecase requires a string but was given %s.", String(theString)), env)
+		return
+	}
+	return StringWithValue(titlecase(StringValue(theString))), nil
+}
+
 func StringLengthImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	theString := Car(args)
 
 	if !StringP(theString) {
-		err = ProcessError(fmt.Sprintf("string-length requires a string but was given %s.", String(theString)), env)
+		err = ProcessTypeError(fmt.Sprintf("string-length requires a string but was given %s.", String(theString)), env)
 		return
 	}
+	if RopeP(theString) {
+		// without flattening it
+		return IntegerWithValue(int64(RopeValue(theString).Len())), nil
+	}
 	return IntegerWithValue(int64(len(StringValue(theString)))), nil
 }
 
@@ -225,7 +329,7 @@ func StringNullImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 	theString := Car(args)
 
 	if !StringP(theString) {
-		err = ProcessError(fmt.Sprintf("string-null? requires a string but was given %s.", String(theString)), env)
+		err = ProcessTypeError(fmt.Sprintf("string-null? requires a string but was given %s.", String(theString)), env)
 		return
 	}
 	return BooleanWithValue(len(StringValue(theString)) == 0), nil
@@ -234,30 +338,30 @@ func StringNullImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 func SubstringImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	theString := Car(args)
 	if !StringP(theString) {
-		err = ProcessError(fmt.Sprintf("substring requires a string but was given %s.", String(theString)), env)
+		err = ProcessTypeError(fmt.Sprintf("substring requires a string but was given %s.", String(theString)), env)
 		return
 	}
 	stringValue := StringValue(theString)
 
 	startObj := Cadr(args)
 	if !IntegerP(startObj) {
-		err = ProcessError(fmt.Sprintf("substring requires integer start but was given %s.", String(startObj)), env)
+		err = ProcessTypeError(fmt.Sprintf("substring requires integer start but was given %s.", String(startObj)), env)
 		return
 	}
 	startValue := int(IntegerValue(startObj))
 	if startValue > len(stringValue) {
-		err = ProcessError(fmt.Sprintf("substring requires start < length of the string."), env)
+		err = ProcessIndexError(fmt.Sprintf("substring requires start < length of the string."), env)
 		return
 	}
 
 	endObj := Caddr(args)
 	if !IntegerP(endObj) {
-		err = ProcessError(fmt.Sprintf("substring requires integer end but was given %s.", String(endObj)), env)
+		err = ProcessTypeError(fmt.Sprintf("substring requires integer end but was given %s.", String(endObj)), env)
 		return
 	}
 	endValue := int(IntegerValue(endObj))
 	if endValue > len(stringValue) {
-		err = ProcessError(fmt.Sprintf("substring requires end < length of the string."), env)
+		err = ProcessIndexError(fmt.Sprintf("substring requires end < length of the string."), env)
 		return
 	}
 
@@ -272,14 +376,14 @@ func SubstringImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 func SubstringpImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	substringObj := Car(args)
 	if !StringP(substringObj) {
-		err = ProcessError(fmt.Sprintf("substring? requires strings but was given %s.", String(substringObj)), env)
+		err = ProcessTypeError(fmt.Sprintf("substring? requires strings but was given %s.", String(substringObj)), env)
 		return
 	}
 	substringValue := StringValue(substringObj)
 
 	theString := Cadr(args)
 	if !StringP(theString) {
-		err = ProcessError(fmt.Sprintf("substring? requires strings but was given %s.", String(theString)), env)
+		err = ProcessTypeError(fmt.Sprintf("substring? requires strings but was given %s.", String(theString)), env)
 		return
 	}
 	stringValue := StringValue(theString)
@@ -290,14 +394,14 @@ func SubstringpImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 func StringPrefixpImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	prefixObj := Car(args)
 	if !StringP(prefixObj) {
-		err = ProcessError(fmt.Sprintf("string-prefix? requires a string but was given %s.", String(prefixObj)), env)
+		err = ProcessTypeError(fmt.Sprintf("string-prefix? requires a string but was given %s.", String(prefixObj)), env)
 		return
 	}
 	prefixValue := StringValue(prefixObj)
 
 	theString := Cadr(args)
 	if !StringP(theString) {
-		err = ProcessError(fmt.Sprintf("string-prefix? requires a string but was given %s.", String(theString)), env)
+		err = ProcessTypeError(fmt.Sprintf("string-prefix? requires a string but was given %s.", String(theString)), env)
 		return
 	}
 	stringValue := StringValue(theString)
@@ -308,14 +412,14 @@ func StringPrefixpImpl(args *Data, env *SymbolTableFrame) (result *Data, err err
 func StringSuffixpImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	suffixObj := Car(args)
 	if !StringP(suffixObj) {
-		err = ProcessError(fmt.Sprintf("string-suffix? requires a string but was given %s.", String(suffixObj)), env)
+		err = ProcessTypeError(fmt.Sprintf("string-suffix? requires a string but was given %s.", String(suffixObj)), env)
 		return
 	}
 	suffixValue := StringValue(suffixObj)
 
 	theString := Cadr(args)
 	if !StringP(theString) {
-		err = ProcessError(fmt.Sprintf("string-suffix? requires a string but was given %s.", String(theString)), env)
+		err = ProcessTypeError(fmt.Sprintf("string-suffix? requires a string but was given %s.", String(theString)), env)
 		return
 	}
 	stringValue := StringValue(theString)
@@ -326,7 +430,7 @@ func StringSuffixpImpl(args *Data, env *SymbolTableFrame) (result *Data, err err
 func stringProcessArgs(name string, caseInsensitive bool, args *Data, env *SymbolTableFrame) (string1 string, string2 string, err error) {
 	string1Obj := Car(args)
 	if !StringP(string1Obj) {
-		err = ProcessError(fmt.Sprintf("%s requires a string but was given %s.", name, String(string1Obj)), env)
+		err = ProcessTypeError(fmt.Sprintf("%s requires a string but was given %s.", name, String(string1Obj)), env)
 		return
 	}
 	if caseInsensitive {
@@ -337,7 +441,7 @@ func stringProcessArgs(name string, caseInsensitive bool, args *Data, env *Symbo
 
 	string2Obj := Cadr(args)
 	if !StringP(string2Obj) {
-		err = ProcessError(fmt.Sprintf("%s requires a string but was given %s.", name, String(string2Obj)), env)
+		err = ProcessTypeError(fmt.Sprintf("%s requires a string but was given %s.", name, String(string2Obj)), env)
 		return
 	}
 
@@ -430,6 +534,81 @@ func StringGreaterThanEqualCiImpl(args *Data, env *SymbolTableFrame) (result *Da
 	return
 }
 
+// naturalCompare compares a and b like strings.Compare, except that runs of digits are
+// compared by their numeric value, so "port2" comes before "port10".  Runs with the same value
+// are ordered by their number of leading zeros.
+func naturalCompare(a string, b string) int {
+	for len(a) > 0 && len(b) > 0 {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			aDigits, bDigits := digitRun(a), digitRun(b)
+			aValue, bValue := strings.TrimLeft(aDigits, "0"), strings.TrimLeft(bDigits, "0")
+			if len(aValue) != len(bValue) {
+				return compareInts(len(aValue), len(bValue))
+			}
+			if c := strings.Compare(aValue, bValue); c != 0 {
+				return c
+			}
+			if len(aDigits) != len(bDigits) {
+				return compareInts(len(aDigits), len(bDigits))
+			}
+			a, b = a[len(aDigits):], b[len(bDigits):]
+			continue
+		}
+		if a[0] != b[0] {
+			return compareInts(int(a[0]), int(b[0]))
+		}
+		a, b = a[1:], b[1:]
+	}
+	return compareInts(len(a), len(b))
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func digitRun(s string) string {
+	end := 0
+	for end < len(s) && isDigit(s[end]) {
+		end++
+	}
+	return s[:end]
+}
+
+func compareInts(a int, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func StringNaturalLessThanImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	string1, string2, err := stringProcessArgs("string-natural<?", false, args, env)
+	if err == nil {
+		result = BooleanWithValue(naturalCompare(string1, string2) < 0)
+	}
+	return
+}
+
+func StringNaturalGreaterThanImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	string1, string2, err := stringProcessArgs("string-natural>?", false, args, env)
+	if err == nil {
+		result = BooleanWithValue(naturalCompare(string1, string2) > 0)
+	}
+	return
+}
+
+func StringNaturalLessThanCiImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	string1, string2, err := stringProcessArgs("string-natural-ci<?", true, args, env)
+	if err == nil {
+		result = BooleanWithValue(naturalCompare(string1, string2) < 0)
+	}
+	return
+}
+
 func ParseImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	str := First(args)
 	if !StringP(str) {
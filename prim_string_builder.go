@@ -0,0 +1,148 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the string builder primitive functions.
+
+package golisp
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// StringBuilder accumulates a string in place, so building a large report a piece at a time
+// takes time proportional to its length rather than copying everything so far on each append
+type StringBuilder struct {
+	mutex   sync.Mutex
+	builder strings.Builder
+}
+
+func RegisterStringBuilderPrimitives() {
+	MakePrimitiveFunction("make-string-builder", "0|1", MakeStringBuilderImpl)
+	MakePrimitiveFunction("string-builder?", "1", IsStringBuilderImpl)
+	MakePrimitiveFunction("string-builder-append!", ">=1", StringBuilderAppendImpl)
+	MakePrimitiveFunction("string-builder->string", "1", StringBuilderToStringImpl)
+	MakePrimitiveFunction("string-builder-length", "1", StringBuilderLengthImpl)
+	MakePrimitiveFunction("string-builder-reset!", "1", StringBuilderResetImpl)
+}
+
+func StringBuilderWithValue(builder *StringBuilder) *Data {
+	return ObjectWithTypeAndValue("StringBuilder", unsafe.Pointer(builder))
+}
+
+func StringBuilderP(d *Data) bool {
+	return ObjectP(d) && ObjectType(d) == "StringBuilder"
+}
+
+func StringBuilderValue(d *Data) *StringBuilder {
+	if !StringBuilderP(d) {
+		return nil
+	}
+	return (*StringBuilder)(ObjectValue(d))
+}
+
+func (self *StringBuilder) Append(s string) {
+	self.mutex.Lock()
+	self.builder.WriteString(s)
+	self.mutex.Unlock()
+}
+
+func (self *StringBuilder) String() string {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.builder.String()
+}
+
+func (self *StringBuilder) Len() int {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.builder.Len()
+}
+
+func (self *StringBuilder) Reset() {
+	self.mutex.Lock()
+	self.builder.Reset()
+	self.mutex.Unlock()
+}
+
+func stringBuilderArg(name string, args *Data, env *SymbolTableFrame) (builder *StringBuilder, err error) {
+	if !StringBuilderP(Car(args)) {
+		err = ProcessTypeError(fmt.Sprintf("%s expects a string builder but received %s.", name, String(Car(args))), env)
+		return
+	}
+	return StringBuilderValue(Car(args)), nil
+}
+
+// A capacity hint is only acted on up to this many bytes; beyond that the builder grows as
+// text is appended, so a huge hint can't exhaust memory up front
+const maxStringBuilderCapacity = 1 << 20
+
+// MakeStringBuilderImpl handles (make-string-builder [capacity]), where capacity is the
+// number of bytes to make room for up front
+func MakeStringBuilderImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	builder := &StringBuilder{}
+	if NotNilP(args) {
+		if !IntegerP(Car(args)) || IntegerValue(Car(args)) < 0 {
+			err = ProcessTypeError(fmt.Sprintf("make-string-builder expects a capacity but received %s.", String(Car(args))), env)
+			return
+		}
+		capacity := IntegerValue(Car(args))
+		if capacity > maxStringBuilderCapacity {
+			capacity = maxStringBuilderCapacity
+		}
+		builder.builder.Grow(int(capacity))
+	}
+	return StringBuilderWithValue(builder), nil
+}
+
+func IsStringBuilderImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(StringBuilderP(Car(args))), nil
+}
+
+// StringBuilderAppendImpl handles (string-builder-append! builder value...).  Strings and
+// characters are appended as they are and anything else as display would print it.  The
+// result is the builder, so appends can be chained.
+func StringBuilderAppendImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	builder, err := stringBuilderArg("string-builder-append!", args, env)
+	if err != nil {
+		return
+	}
+	for c := Cdr(args); NotNilP(c); c = Cdr(c) {
+		if CharacterP(Car(c)) {
+			builder.Append(string(CharacterValue(Car(c))))
+		} else {
+			builder.Append(PrintString(Car(c)))
+		}
+	}
+	return Car(args), nil
+}
+
+func StringBuilderToStringImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	builder, err := stringBuilderArg("string-builder->string", args, env)
+	if err != nil {
+		return
+	}
+	return StringWithValue(builder.String()), nil
+}
+
+// StringBuilderLengthImpl is the length of the string so far, counted like string-length
+func StringBuilderLengthImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	builder, err := stringBuilderArg("string-builder-length", args, env)
+	if err != nil {
+		return
+	}
+	return IntegerWithValue(int64(builder.Len())), nil
+}
+
+func StringBuilderResetImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	builder, err := stringBuilderArg("string-builder-reset!", args, env)
+	if err != nil {
+		return
+	}
+	builder.Reset()
+	return Car(args), nil
+}
@@ -30,14 +30,18 @@ func RegisterSystemPrimitives() {
 	MakePrimitiveFunction("gensym-naked", "0|1", GensymNakedImpl)
 	MakePrimitiveFunction("eval", "1|2", EvalImpl)
 
+	MakePrimitiveFunction("expand-vars", "1|2", ExpandVarsImpl)
 	MakeRestrictedPrimitiveFunction("load", "1", LoadFileImpl)
+	MakeRestrictedPrimitiveFunction("require", "1", RequireImpl)
+	MakeRestrictedPrimitiveFunction("strict-arity-checking", "0|1", StrictArityCheckingImpl)
 	MakeRestrictedPrimitiveFunction("global-eval", "1", GlobalEvalImpl)
 	MakeRestrictedPrimitiveFunction("panic!", "1", PanicImpl)
+	MakeRestrictedPrimitiveFunction("max-call-depth", "0|1", MaxCallDepthImpl)
 	MakePrimitiveFunction("error", "1", ErrorImpl)
 	MakeSpecialForm("on-error", "2|3", OnErrorImpl)
 
 	MakeSpecialForm("time", "1", TimeImpl)
-	MakeSpecialForm("profile", "1|2", ProfileImpl)
+	MakeSpecialForm("profile", "1|2|3", ProfileImpl)
 
 	MakeRestrictedPrimitiveFunction("exec", ">=1", ExecImpl)
 }
@@ -45,11 +49,68 @@ func RegisterSystemPrimitives() {
 func LoadFileImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	filename := Car(args)
 	if !StringP(filename) {
-		err = ProcessError("Filename must be a string", env)
+		err = ProcessTypeError("Filename must be a string", env)
 		return
 	}
 
-	return ProcessFile(StringValue(filename))
+	return ProcessFile(ExpandVars(StringValue(filename)))
+}
+
+// ExpandVarsImpl handles (expand-vars string [variables]), which replaces $NAME and ${NAME}
+// in string with the values of environment variables, or with the slots of the variables
+// frame, which take precedence.  ${NAME:-default} gives a default for unset variables.  In
+// a restricted environment only the variables frame is used, so scripts can't read the
+// process's environment.
+func ExpandVarsImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if !StringP(Car(args)) {
+		err = ProcessTypeError(fmt.Sprintf("expand-vars expects a string but received %s.", String(Car(args))), env)
+		return
+	}
+
+	variables := &FrameMap{Data: make(FrameMapData)}
+	if NotNilP(Cdr(args)) {
+		if !FrameP(Cadr(args)) {
+			err = ProcessTypeError(fmt.Sprintf("expand-vars expects a frame of variables but received %s.", String(Cadr(args))), env)
+			return
+		}
+		variables = FrameValue(Cadr(args))
+	}
+	lookup := func(name string) (string, bool) {
+		if variables.HasSlot(name + ":") {
+			return PrintString(variables.Get(name + ":")), true
+		}
+		if env.IsRestricted {
+			return "", false
+		}
+		return os.LookupEnv(name)
+	}
+	return StringWithValue(expandVarsWith(StringValue(Car(args)), lookup)), nil
+}
+
+func StrictArityCheckingImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if NotNilP(args) {
+		if !BooleanP(Car(args)) {
+			err = ProcessTypeError(fmt.Sprintf("strict-arity-checking expects a boolean but received %s.", String(Car(args))), env)
+			return
+		}
+		Config.StrictArityChecking = BooleanValue(Car(args))
+		StrictArityChecking = false
+	}
+	return BooleanWithValue(strictArityChecking()), nil
+}
+
+func RequireImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	name := Car(args)
+	if !StringP(name) {
+		err = ProcessTypeError("Filename must be a string", env)
+		return
+	}
+
+	loaded, err := Require(ExpandVars(StringValue(name)))
+	if err != nil {
+		return
+	}
+	return BooleanWithValue(loaded), nil
 }
 
 var goodbyes []string = []string{
@@ -73,9 +134,11 @@ func PanicImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 }
 
 func ErrorImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	return nil, ProcessError(String(Car(args)), env)
+	return nil, ProcessErrorWithCategory(UserError, String(Car(args)), env)
 }
 
+// OnErrorImpl handles (on-error expr handler [no-error-handler]).  The handler is passed the
+// error message, and also the error object if it accepts a second argument.
 func OnErrorImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	result, errThrown := Eval(Car(args), env)
 	if errThrown == nil {
@@ -85,7 +148,7 @@ func OnErrorImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 				return nil, err
 			}
 			if !FunctionP(f) {
-				return nil, ProcessError("on-error requires a function as it's third argument", env)
+				return nil, ProcessTypeError("on-error requires a function as it's third argument", env)
 			}
 			noErrHandler := FunctionValue(f)
 			return noErrHandler.Apply(nil, env)
@@ -101,14 +164,29 @@ func OnErrorImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	}
 
 	if !FunctionP(f) {
-		err = ProcessError("on-error requires a function as it's second argument", env)
+		err = ProcessTypeError("on-error requires a function as it's second argument", env)
 		return
 	}
 	handler := FunctionValue(f)
 	errString := StringWithValue(errThrown.Error())
+	if acceptsArgCount(handler, 2) {
+		return handler.Apply(InternalMakeList(errString, ErrorObjectFor(errThrown)), env)
+	}
 	return handler.Apply(InternalMakeList(errString), env)
 }
 
+func MaxCallDepthImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if NotNilP(args) {
+		depth := Car(args)
+		if !IntegerP(depth) || IntegerValue(depth) < 0 {
+			err = ProcessTypeError(fmt.Sprintf("max-call-depth expects a non-negative integer but received %s.", String(depth)), env)
+			return
+		}
+		SetMaxCallDepth(int32(IntegerValue(depth)))
+	}
+	return IntegerWithValue(int64(maxCallDepth())), nil
+}
+
 func QuitImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if IsInteractive || DebugEvalInDebugRepl {
 		WriteHistoryToFile(".golisp_history")
@@ -121,17 +199,21 @@ func QuitImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 
 func SleepImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	n := Car(args)
+	if DurationP(n) {
+		CurrentClock().Sleep(DurationValue(n))
+		return
+	}
 	if !IntegerP(n) {
-		err = ProcessError(fmt.Sprintf("Number expected, received %s", String(n)), env)
+		err = ProcessTypeError(fmt.Sprintf("Number expected, received %s", String(n)), env)
 		return
 	}
 	millis := IntegerValue(n)
-	time.Sleep(time.Duration(millis) * time.Millisecond)
+	CurrentClock().Sleep(time.Duration(millis) * time.Millisecond)
 	return
 }
 
 func MillisImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	result = IntegerWithValue(int64(time.Now().UnixNano() / 1e6))
+	result = IntegerWithValue(int64(CurrentTime().UnixNano() / 1e6))
 	return
 }
 
@@ -147,7 +229,7 @@ func concatStringForms(args *Data) (str string) {
 }
 
 func WriteLineImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	println(concatStringForms(args))
+	fmt.Fprintln(currentOutput(env), concatStringForms(args))
 	return
 }
 
@@ -161,7 +243,8 @@ func MakeStringImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 }
 
 func TimeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	startTime := time.Now()
+	clock := CurrentClock()
+	startTime := clock.Now()
 
 	for cell := args; NotNilP(cell); cell = Cdr(cell) {
 		sexpr := Car(cell)
@@ -171,7 +254,7 @@ func TimeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 		}
 	}
 
-	d := time.Since(startTime)
+	d := clock.Now().Sub(startTime)
 	result = IntegerWithValue(int64(d.Nanoseconds() / 1000000))
 	return
 }
@@ -179,7 +262,7 @@ func TimeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func InternImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	sym := Car(args)
 	if !StringP(sym) {
-		err = ProcessError(fmt.Sprintf("intern expects a string, but received %s.", String(sym)), env)
+		err = ProcessTypeError(fmt.Sprintf("intern expects a string, but received %s.", String(sym)), env)
 		return
 	}
 
@@ -188,7 +271,7 @@ func InternImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 
 func gensymHelper(primitiveName string, args *Data, env *SymbolTableFrame) (prefix string, count int, err error) {
 	if Length(args) > 1 {
-		err = ProcessError(fmt.Sprintf("%s expects 0 or 1 argument, but received %d.", primitiveName, Length(args)), env)
+		err = ProcessErrorWithCategory(ArityError, fmt.Sprintf("%s expects 0 or 1 argument, but received %d.", primitiveName, Length(args)), env)
 		return
 	}
 
@@ -197,7 +280,7 @@ func gensymHelper(primitiveName string, args *Data, env *SymbolTableFrame) (pref
 	} else {
 		arg := Car(args)
 		if !StringP(arg) && !SymbolP(arg) {
-			err = ProcessError(fmt.Sprintf("%s expects a string or symbol, but recieved %s.", primitiveName, String(arg)), env)
+			err = ProcessTypeError(fmt.Sprintf("%s expects a string or symbol, but recieved %s.", primitiveName, String(arg)), env)
 			return
 		}
 		prefix = StringValue(arg)
@@ -236,7 +319,7 @@ func EvalImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	sexpr := Car(args)
 	if Length(args) == 2 {
 		if !EnvironmentP(Cadr(args)) {
-			err = ProcessError(fmt.Sprintf("eval expects an environment as it's second argument, but recieved %s.", String(Cadr(args))), env)
+			err = ProcessTypeError(fmt.Sprintf("eval expects an environment as it's second argument, but recieved %s.", String(Cadr(args))), env)
 			return
 		}
 		evalEnv = EnvironmentValue(Cadr(args))
@@ -250,15 +333,44 @@ func GlobalEvalImpl(args *Data, env *SymbolTableFrame) (result *Data, err error)
 	return Eval(Car(args), Global)
 }
 
+// ProfileImpl handles (profile expr [filename [format]]), where format is 'events (the
+// default), 'folded for flamegraph tools, or 'pprof.  The filename and format are evaluated,
+// and a nil filename profiles to the trace writer.
 func ProfileImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	if Length(args) == 2 {
-		if !StringP(Cadr(args)) {
-			err = ProcessError(fmt.Sprintf("profile requires a string filename, but received %s.", String(Cadr(args))), env)
+	filename := ""
+	if Length(args) >= 2 {
+		var name *Data
+		name, err = Eval(Cadr(args), env)
+		if err != nil {
+			return
+		}
+		if NotNilP(name) && !StringP(name) {
+			err = ProcessTypeError(fmt.Sprintf("profile requires a string filename, but received %s.", String(name)), env)
+			return
+		}
+		filename = StringValue(name)
+	}
+
+	format := ProfileEvents
+	if Length(args) == 3 {
+		var formatName *Data
+		formatName, err = Eval(Caddr(args), env)
+		if err != nil {
+			return
+		}
+		switch formatName {
+		case Intern("events"):
+			format = ProfileEvents
+		case Intern("folded"):
+			format = ProfileFolded
+		case Intern("pprof"):
+			format = ProfilePprof
+		default:
+			err = ProcessError(fmt.Sprintf("profile requires a format of events, folded, or pprof, but received %s.", String(formatName)), env)
+			return
 		}
-		StartProfiling(StringValue(Cadr(args)))
-	} else {
-		StartProfiling("")
 	}
+	StartProfilingWithFormat(filename, format)
 
 	result, err = Eval(Car(args), env)
 
@@ -269,7 +381,7 @@ func ProfileImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 
 func ExecImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if !StringP(First(args)) {
-		err = ProcessError(fmt.Sprintf("exec requires a string command, but received %s.", String(First(args))), env)
+		err = ProcessTypeError(fmt.Sprintf("exec requires a string command, but received %s.", String(First(args))), env)
 	}
 	cmdString := StringValue(First(args))
 
@@ -0,0 +1,371 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the terminal output primitive functions: cursor movement, colored text,
+// progress bars and tables, all done with ANSI escape sequences.
+
+package golisp
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+func RegisterTerminalPrimitives() {
+	MakePrimitiveFunction("terminal?", "0", IsTerminalImpl)
+	MakePrimitiveFunction("cursor-to", "2", CursorToImpl)
+	MakePrimitiveFunction("cursor-up", "0|1", makeCursorFunction("cursor-up", 'A'))
+	MakePrimitiveFunction("cursor-down", "0|1", makeCursorFunction("cursor-down", 'B'))
+	MakePrimitiveFunction("cursor-right", "0|1", makeCursorFunction("cursor-right", 'C'))
+	MakePrimitiveFunction("cursor-left", "0|1", makeCursorFunction("cursor-left", 'D'))
+	MakePrimitiveFunction("cursor-hide", "0", makeEscapeFunction("?25l"))
+	MakePrimitiveFunction("cursor-show", "0", makeEscapeFunction("?25h"))
+	MakePrimitiveFunction("clear-screen", "0", makeEscapeFunction("2J\x1b[H"))
+	MakePrimitiveFunction("clear-line", "0", makeEscapeFunction("2K\r"))
+	MakePrimitiveFunction("colorize", "2|3", ColorizeImpl)
+	MakePrimitiveFunction("progress-bar", "2|3", ProgressBarImpl)
+	MakePrimitiveFunction("show-progress", "2|3", ShowProgressImpl)
+	MakePrimitiveFunction("format-table", "1|2", FormatTableImpl)
+	MakePrimitiveFunction("print-table", "1|2", PrintTableImpl)
+}
+
+const csi = "\x1b["
+
+var terminalColors = map[string]int{
+	"black":   0,
+	"red":     1,
+	"green":   2,
+	"yellow":  3,
+	"blue":    4,
+	"magenta": 5,
+	"cyan":    6,
+	"white":   7,
+}
+
+// colorsEnabled follows the NO_COLOR convention (https://no-color.org)
+func colorsEnabled() bool {
+	return os.Getenv("NO_COLOR") == ""
+}
+
+func writeTerminal(s string, env *SymbolTableFrame) (result *Data, err error) {
+	_, err = io.WriteString(currentOutput(env), s)
+	return
+}
+
+// visibleWidth is the number of columns s takes up, not counting escape sequences
+func visibleWidth(s string) (width int) {
+	for i := 0; i < len(s); {
+		if strings.HasPrefix(s[i:], csi) {
+			i += len(csi)
+			for i < len(s) && (s[i] < 0x40 || s[i] > 0x7e) {
+				i++
+			}
+			i++
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		width++
+		i += size
+	}
+	return
+}
+
+func IsTerminalImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	file, isFile := currentOutput(env).(*os.File)
+	if !isFile {
+		return LispFalse, nil
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		return LispFalse, nil
+	}
+	return BooleanWithValue(stat.Mode()&os.ModeCharDevice != 0), nil
+}
+
+// CursorToImpl handles (cursor-to row column), where the top left corner is row 1 column 1
+func CursorToImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	for c := args; NotNilP(c); c = Cdr(c) {
+		if !IntegerP(Car(c)) || IntegerValue(Car(c)) < 1 {
+			err = ProcessTypeError(fmt.Sprintf("cursor-to expects a positive row and column, but received %s.", String(Car(c))), env)
+			return
+		}
+	}
+	return writeTerminal(fmt.Sprintf("%s%d;%dH", csi, IntegerValue(Car(args)), IntegerValue(Cadr(args))), env)
+}
+
+func makeCursorFunction(name string, direction byte) func(*Data, *SymbolTableFrame) (*Data, error) {
+	return func(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+		n := int64(1)
+		if NotNilP(args) {
+			if !IntegerP(Car(args)) || IntegerValue(Car(args)) < 0 {
+				err = ProcessTypeError(fmt.Sprintf("%s expects a number of cells to move, but received %s.", name, String(Car(args))), env)
+				return
+			}
+			n = IntegerValue(Car(args))
+		}
+		if n == 0 {
+			return
+		}
+		return writeTerminal(fmt.Sprintf("%s%d%c", csi, n, direction), env)
+	}
+}
+
+func makeEscapeFunction(sequence string) func(*Data, *SymbolTableFrame) (*Data, error) {
+	return func(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+		return writeTerminal(csi+sequence, env)
+	}
+}
+
+// colorCode is the SGR parameters for a color, which is a name like red or bright-red, or
+// an integer from the 256 color palette.  base is 30 for text and 40 for backgrounds.
+func colorCode(name string, color *Data, base int, env *SymbolTableFrame) (code string, err error) {
+	if IntegerP(color) && IntegerValue(color) >= 0 && IntegerValue(color) < 256 {
+		return fmt.Sprintf("%d;5;%d", base+8, IntegerValue(color)), nil
+	}
+	if StringP(color) || SymbolP(color) {
+		colorName := strings.TrimSuffix(StringValue(color), ":")
+		bright := strings.HasPrefix(colorName, "bright-")
+		if n, found := terminalColors[strings.TrimPrefix(colorName, "bright-")]; found {
+			if bright {
+				return fmt.Sprint(base + 60 + n), nil
+			}
+			return fmt.Sprint(base + n), nil
+		}
+	}
+	err = ProcessError(fmt.Sprintf("%s expects a color name or an integer from 0 to 255, but received %s.", name, String(color)), env)
+	return
+}
+
+// ColorizeImpl handles (colorize text color [options]), which is text with escape sequences
+// to show it in color.  Options can have background: for a background color, and bold: or
+// underline: set to #t.  Text is left alone if the NO_COLOR environment variable is set.
+func ColorizeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	text := PrintString(Car(args))
+	var codes []string
+	if NotNilP(Cadr(args)) {
+		var code string
+		if code, err = colorCode("colorize", Cadr(args), 30, env); err != nil {
+			return
+		}
+		codes = append(codes, code)
+	}
+
+	if NotNilP(Cddr(args)) {
+		if !FrameP(Caddr(args)) {
+			err = ProcessTypeError(fmt.Sprintf("colorize expects a frame of options, but received %s.", String(Caddr(args))), env)
+			return
+		}
+		options := FrameValue(Caddr(args))
+		if options.HasSlot("background:") {
+			var code string
+			if code, err = colorCode("colorize", options.Get("background:"), 40, env); err != nil {
+				return
+			}
+			codes = append(codes, code)
+		}
+		if BooleanValue(options.Get("bold:")) {
+			codes = append(codes, "1")
+		}
+		if BooleanValue(options.Get("underline:")) {
+			codes = append(codes, "4")
+		}
+	}
+
+	if len(codes) == 0 || !colorsEnabled() {
+		return StringWithValue(text), nil
+	}
+	return StringWithValue(fmt.Sprintf("%s%sm%s%s0m", csi, strings.Join(codes, ";"), text, csi)), nil
+}
+
+// Progress bars can be at most this many cells wide
+const maxProgressBarWidth = 1000
+
+// progressBar renders (progress-bar done total [options]).  Options can have width: for the
+// number of cells in the bar, 30 unless given, and label: for text to show after it.
+func progressBar(name string, args *Data, env *SymbolTableFrame) (bar string, finished bool, err error) {
+	done, total := Car(args), Cadr(args)
+	if !NumberP(done) || !NumberP(total) || FloatValue(total) <= 0 {
+		err = ProcessTypeError(fmt.Sprintf("%s expects a number done out of a positive total, but received %s and %s.", name, String(done), String(total)), env)
+		return
+	}
+
+	width := 30
+	label := ""
+	if NotNilP(Cddr(args)) {
+		if !FrameP(Caddr(args)) {
+			err = ProcessTypeError(fmt.Sprintf("%s expects a frame of options, but received %s.", name, String(Caddr(args))), env)
+			return
+		}
+		options := FrameValue(Caddr(args))
+		if options.HasSlot("width:") {
+			if !IntegerP(options.Get("width:")) || IntegerValue(options.Get("width:")) < 1 {
+				err = ProcessTypeError(fmt.Sprintf("%s expects width: to be a positive integer, but received %s.", name, String(options.Get("width:"))), env)
+				return
+			}
+			if IntegerValue(options.Get("width:")) > maxProgressBarWidth {
+				err = ProcessError(fmt.Sprintf("%s expects width: to be at most %d, but received %s.", name, maxProgressBarWidth, String(options.Get("width:"))), env)
+				return
+			}
+			width = int(IntegerValue(options.Get("width:")))
+		}
+		if options.HasSlot("label:") {
+			label = " " + PrintString(options.Get("label:"))
+		}
+	}
+
+	fraction := float64(FloatValue(done)) / float64(FloatValue(total))
+	if fraction < 0 {
+		fraction = 0
+	} else if fraction > 1 {
+		fraction = 1
+	}
+	filled := int(fraction * float64(width))
+	bar = strings.Repeat("=", filled)
+	if filled < width {
+		bar += ">" + strings.Repeat(" ", width-filled-1)
+	}
+	finished = fraction >= 1
+	return fmt.Sprintf("[%s] %3d%%%s", bar, int(fraction*100), label), finished, nil
+}
+
+func ProgressBarImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	bar, _, err := progressBar("progress-bar", args, env)
+	if err != nil {
+		return
+	}
+	return StringWithValue(bar), nil
+}
+
+// ShowProgressImpl handles (show-progress done total [options]), which draws a progress bar
+// over the current line, and moves to the next line once done reaches total
+func ShowProgressImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	bar, finished, err := progressBar("show-progress", args, env)
+	if err != nil {
+		return
+	}
+	if finished {
+		bar += "\n"
+	}
+	return writeTerminal("\r"+csi+"2K"+bar, env)
+}
+
+// tableCells reads a row of a table, which is a list or vector of values
+func tableCells(name string, row *Data, env *SymbolTableFrame) (cells []string, err error) {
+	var values []*Data
+	switch {
+	case VectorP(row):
+		values = VectorValue(row)
+	case ListP(row):
+		values = ToArray(row)
+	default:
+		err = ProcessTypeError(fmt.Sprintf("%s expects each row to be a list or vector, but received %s.", name, String(row)), env)
+		return
+	}
+	for _, value := range values {
+		cells = append(cells, PrintString(value))
+	}
+	return
+}
+
+// formatTable renders (format-table rows [options]).  Options can have header: for a row of
+// column names, which is underlined, and align: for a list with left or right for each
+// column.  Columns are left aligned unless given.
+func formatTable(name string, args *Data, env *SymbolTableFrame) (table string, err error) {
+	if !ListP(Car(args)) && !VectorP(Car(args)) {
+		err = ProcessTypeError(fmt.Sprintf("%s expects a list of rows, but received %s.", name, String(Car(args))), env)
+		return
+	}
+	var rows [][]string
+	var header []string
+	var align []string
+	if NotNilP(Cdr(args)) {
+		if !FrameP(Cadr(args)) {
+			err = ProcessTypeError(fmt.Sprintf("%s expects a frame of options, but received %s.", name, String(Cadr(args))), env)
+			return
+		}
+		options := FrameValue(Cadr(args))
+		if options.HasSlot("header:") {
+			if header, err = tableCells(name, options.Get("header:"), env); err != nil {
+				return
+			}
+			rows = append(rows, header)
+		}
+		if options.HasSlot("align:") {
+			if align, err = tableCells(name, options.Get("align:"), env); err != nil {
+				return
+			}
+		}
+	}
+	body := VectorValue(Car(args))
+	if ListP(Car(args)) {
+		body = ToArray(Car(args))
+	}
+	for _, row := range body {
+		var cells []string
+		if cells, err = tableCells(name, row, env); err != nil {
+			return
+		}
+		rows = append(rows, cells)
+	}
+
+	var widths []int
+	for _, row := range rows {
+		for column, cell := range row {
+			if column == len(widths) {
+				widths = append(widths, 0)
+			}
+			if w := visibleWidth(cell); w > widths[column] {
+				widths[column] = w
+			}
+		}
+	}
+
+	var b strings.Builder
+	writeRow := func(row []string) {
+		line := make([]string, len(row))
+		for column, cell := range row {
+			padding := strings.Repeat(" ", widths[column]-visibleWidth(cell))
+			if column < len(align) && strings.TrimSuffix(align[column], ":") == "right" {
+				line[column] = padding + cell
+			} else if column < len(row)-1 {
+				line[column] = cell + padding
+			} else {
+				line[column] = cell
+			}
+		}
+		b.WriteString(strings.Join(line, "  "))
+		b.WriteString("\n")
+	}
+	for i, row := range rows {
+		writeRow(row)
+		if i == 0 && header != nil {
+			rules := make([]string, len(widths))
+			for column, width := range widths {
+				rules[column] = strings.Repeat("-", width)
+			}
+			writeRow(rules)
+		}
+	}
+	return b.String(), nil
+}
+
+func FormatTableImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	table, err := formatTable("format-table", args, env)
+	if err != nil {
+		return
+	}
+	return StringWithValue(table), nil
+}
+
+func PrintTableImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	table, err := formatTable("print-table", args, env)
+	if err != nil {
+		return
+	}
+	return writeTerminal(table, env)
+}
@@ -0,0 +1,525 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the duration, time and timezone primitive functions.
+
+package golisp
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+// Named layouts that can be given to parse-time and format-time instead of a Go layout string
+var timeLayouts = map[string]string{
+	"rfc3339":      time.RFC3339,
+	"rfc3339-nano": time.RFC3339Nano,
+	"rfc1123":      time.RFC1123,
+	"rfc1123z":     time.RFC1123Z,
+	"rfc822":       time.RFC822,
+	"rfc822z":      time.RFC822Z,
+	"ansic":        time.ANSIC,
+	"kitchen":      time.Kitchen,
+	"date-time":    "2006-01-02 15:04:05",
+	"date":         "2006-01-02",
+	"time":         "15:04:05",
+}
+
+func RegisterTimePrimitives() {
+	MakePrimitiveFunction("milliseconds", "1", MillisecondsImpl)
+	MakePrimitiveFunction("seconds", "1", SecondsImpl)
+	MakePrimitiveFunction("minutes", "1", MinutesImpl)
+	MakePrimitiveFunction("hours", "1", HoursImpl)
+	MakePrimitiveFunction("days", "1", DaysImpl)
+	MakePrimitiveFunction("duration", "1", DurationImpl)
+	MakePrimitiveFunction("duration?", "1", IsDurationImpl)
+	MakePrimitiveFunction("duration+", "*", DurationAddImpl)
+	MakePrimitiveFunction("duration-", ">=1", DurationSubImpl)
+	MakePrimitiveFunction("duration->milliseconds", "1", DurationToMillisecondsImpl)
+	MakePrimitiveFunction("duration->seconds", "1", DurationToSecondsImpl)
+
+	MakePrimitiveFunction("now", "0", NowImpl)
+	MakePrimitiveFunction("time?", "1", IsTimeImpl)
+	MakePrimitiveFunction("time+", ">=1", TimeAddImpl)
+	MakePrimitiveFunction("time-", "2", TimeSubImpl)
+	MakePrimitiveFunction("time-before?", "2", TimeBeforeImpl)
+	MakePrimitiveFunction("time-after?", "2", TimeAfterImpl)
+	MakePrimitiveFunction("make-time", "6|7", MakeTimeImpl)
+	MakePrimitiveFunction("time-add-date", "4", TimeAddDateImpl)
+	MakePrimitiveFunction("parse-time", "1|2|3", ParseTimeImpl)
+	MakePrimitiveFunction("format-time", "1|2", FormatTimeImpl)
+	MakePrimitiveFunction("time->milliseconds", "1", TimeToMillisecondsImpl)
+	MakePrimitiveFunction("milliseconds->time", "1", MillisecondsToTimeImpl)
+	MakePrimitiveFunction("in-timezone", "2", InTimezoneImpl)
+	MakePrimitiveFunction("local-timezone", "0", LocalTimezoneImpl)
+	MakePrimitiveFunction("time-zone", "1", TimeZoneImpl)
+	MakePrimitiveFunction("time-zone-offset", "1", TimeZoneOffsetImpl)
+
+	MakeRestrictedPrimitiveFunction("deterministic-mode", "0|1|2", DeterministicModeImpl)
+	MakePrimitiveFunction("tick", "1", TickImpl)
+
+	RegisterObjectEquality("Duration",
+		func(a unsafe.Pointer, b unsafe.Pointer) bool {
+			return *(*time.Duration)(a) == *(*time.Duration)(b)
+		},
+		func(o unsafe.Pointer) uint64 {
+			return hashUint64('D', uint64(*(*time.Duration)(o)))
+		})
+	RegisterObjectEquality("Time",
+		func(a unsafe.Pointer, b unsafe.Pointer) bool {
+			return (*time.Time)(a).Equal(*(*time.Time)(b))
+		},
+		func(o unsafe.Pointer) uint64 {
+			return hashUint64('T', uint64((*time.Time)(o).UnixNano()))
+		})
+}
+
+func DurationWithValue(d time.Duration) *Data {
+	return ObjectWithTypeAndValue("Duration", unsafe.Pointer(&d))
+}
+
+func DurationP(d *Data) bool {
+	return ObjectP(d) && ObjectType(d) == "Duration"
+}
+
+func DurationValue(d *Data) time.Duration {
+	if !DurationP(d) {
+		return 0
+	}
+	return *(*time.Duration)(ObjectValue(d))
+}
+
+func TimeWithValue(t time.Time) *Data {
+	return ObjectWithTypeAndValue("Time", unsafe.Pointer(&t))
+}
+
+func TimeP(d *Data) bool {
+	return ObjectP(d) && ObjectType(d) == "Time"
+}
+
+func TimeValue(d *Data) time.Time {
+	if !TimeP(d) {
+		return time.Time{}
+	}
+	return *(*time.Time)(ObjectValue(d))
+}
+
+// durationValueArg accepts a duration, an integer number of milliseconds, or a string
+// such as "1h30m"
+func durationValueArg(name string, d *Data, env *SymbolTableFrame) (duration time.Duration, err error) {
+	switch {
+	case DurationP(d):
+		duration = DurationValue(d)
+	case IntegerP(d):
+		duration = time.Duration(IntegerValue(d)) * time.Millisecond
+	case StringP(d):
+		duration, err = time.ParseDuration(StringValue(d))
+		if err != nil {
+			err = ProcessError(fmt.Sprintf("%s could not parse the duration %s.", name, String(d)), env)
+		}
+	default:
+		err = ProcessTypeError(fmt.Sprintf("%s expected a duration but received %s.", name, String(d)), env)
+	}
+	return
+}
+
+func timeArg(name string, d *Data, env *SymbolTableFrame) (t time.Time, err error) {
+	if !TimeP(d) {
+		err = ProcessTypeError(fmt.Sprintf("%s expected a time but received %s.", name, String(d)), env)
+		return
+	}
+	return TimeValue(d), nil
+}
+
+// layoutArg accepts a Go layout string or the name of one of the timeLayouts, defaulting to RFC3339
+func layoutArg(name string, d *Data, env *SymbolTableFrame) (layout string, err error) {
+	switch {
+	case NilP(d):
+		layout = time.RFC3339
+	case StringP(d):
+		layout = StringValue(d)
+	case SymbolP(d):
+		var found bool
+		layout, found = timeLayouts[strings.TrimSuffix(StringValue(d), ":")]
+		if !found {
+			err = ProcessError(fmt.Sprintf("%s received an unknown time layout %s.", name, String(d)), env)
+		}
+	default:
+		err = ProcessTypeError(fmt.Sprintf("%s expected a time layout but received %s.", name, String(d)), env)
+	}
+	return
+}
+
+// locationArg accepts a timezone name from the IANA database, such as "Europe/Copenhagen",
+// as well as "UTC" and "Local"
+func locationArg(name string, d *Data, env *SymbolTableFrame) (location *time.Location, err error) {
+	if !StringP(d) {
+		err = ProcessTypeError(fmt.Sprintf("%s expected a timezone name but received %s.", name, String(d)), env)
+		return
+	}
+
+	location, err = time.LoadLocation(StringValue(d))
+	if err != nil {
+		err = ProcessError(fmt.Sprintf("%s received an unknown timezone %s.", name, String(d)), env)
+	}
+	return
+}
+
+func makeDuration(name string, unit time.Duration, args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	n := Car(args)
+	switch {
+	case IntegerP(n):
+		return DurationWithValue(time.Duration(IntegerValue(n)) * unit), nil
+	case FloatP(n):
+		return DurationWithValue(time.Duration(float64(FloatValue(n)) * float64(unit))), nil
+	default:
+		err = ProcessTypeError(fmt.Sprintf("%s expected a number but received %s.", name, String(n)), env)
+		return
+	}
+}
+
+func MillisecondsImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return makeDuration("milliseconds", time.Millisecond, args, env)
+}
+
+func SecondsImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return makeDuration("seconds", time.Second, args, env)
+}
+
+func MinutesImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return makeDuration("minutes", time.Minute, args, env)
+}
+
+func HoursImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return makeDuration("hours", time.Hour, args, env)
+}
+
+func DaysImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return makeDuration("days", 24*time.Hour, args, env)
+}
+
+func DurationImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	d, err := durationValueArg("duration", Car(args), env)
+	if err != nil {
+		return
+	}
+	return DurationWithValue(d), nil
+}
+
+func IsDurationImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(DurationP(Car(args))), nil
+}
+
+func DurationAddImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	var sum time.Duration
+	for c := args; NotNilP(c); c = Cdr(c) {
+		var d time.Duration
+		d, err = durationValueArg("duration+", Car(c), env)
+		if err != nil {
+			return
+		}
+		sum += d
+	}
+	return DurationWithValue(sum), nil
+}
+
+func DurationSubImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	difference, err := durationValueArg("duration-", Car(args), env)
+	if err != nil {
+		return
+	}
+	if NilP(Cdr(args)) {
+		return DurationWithValue(-difference), nil
+	}
+
+	for c := Cdr(args); NotNilP(c); c = Cdr(c) {
+		var d time.Duration
+		d, err = durationValueArg("duration-", Car(c), env)
+		if err != nil {
+			return
+		}
+		difference -= d
+	}
+	return DurationWithValue(difference), nil
+}
+
+func DurationToMillisecondsImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	d, err := durationValueArg("duration->milliseconds", Car(args), env)
+	if err != nil {
+		return
+	}
+	return IntegerWithValue(int64(d / time.Millisecond)), nil
+}
+
+func DurationToSecondsImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	d, err := durationValueArg("duration->seconds", Car(args), env)
+	if err != nil {
+		return
+	}
+	return FloatWithValue(float32(d.Seconds())), nil
+}
+
+func NowImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return TimeWithValue(CurrentTime()), nil
+}
+
+// DeterministicModeImpl handles (deterministic-mode [on [seed]]), which turns deterministic
+// mode on, seeding randomness with seed (default 0), or off, and results in whether it is on
+func DeterministicModeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if NotNilP(args) {
+		var seed int64
+		if Length(args) == 2 {
+			if !IntegerP(Cadr(args)) {
+				err = ProcessTypeError(fmt.Sprintf("deterministic-mode expected an integer seed but received %s.", String(Cadr(args))), env)
+				return
+			}
+			seed = IntegerValue(Cadr(args))
+		}
+		SetDeterministic(BooleanValue(Car(args)), seed)
+	}
+	return BooleanWithValue(Deterministic()), nil
+}
+
+// TickImpl handles (tick duration), advancing the virtual clock of deterministic mode
+func TickImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	if !Deterministic() {
+		err = ProcessError("tick can only be used in deterministic mode.", env)
+		return
+	}
+	d, err := durationArg("tick", Car(args), env)
+	if err != nil {
+		return
+	}
+	return TimeWithValue(Tick(d)), nil
+}
+
+func IsTimeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(TimeP(Car(args))), nil
+}
+
+// TimeAddImpl handles (time+ time duration...)
+func TimeAddImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	t, err := timeArg("time+", Car(args), env)
+	if err != nil {
+		return
+	}
+
+	for c := Cdr(args); NotNilP(c); c = Cdr(c) {
+		var d time.Duration
+		d, err = durationValueArg("time+", Car(c), env)
+		if err != nil {
+			return
+		}
+		t = t.Add(d)
+	}
+	return TimeWithValue(t), nil
+}
+
+// TimeSubImpl subtracts a duration from a time, giving a time, or a time from a time,
+// giving the duration between them.
+func TimeSubImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	t, err := timeArg("time-", Car(args), env)
+	if err != nil {
+		return
+	}
+
+	other := Cadr(args)
+	if TimeP(other) {
+		return DurationWithValue(t.Sub(TimeValue(other))), nil
+	}
+
+	d, err := durationValueArg("time-", other, env)
+	if err != nil {
+		return
+	}
+	return TimeWithValue(t.Add(-d)), nil
+}
+
+func TimeBeforeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	a, err := timeArg("time-before?", Car(args), env)
+	if err != nil {
+		return
+	}
+	b, err := timeArg("time-before?", Cadr(args), env)
+	if err != nil {
+		return
+	}
+	return BooleanWithValue(a.Before(b)), nil
+}
+
+func TimeAfterImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	a, err := timeArg("time-after?", Car(args), env)
+	if err != nil {
+		return
+	}
+	b, err := timeArg("time-after?", Cadr(args), env)
+	if err != nil {
+		return
+	}
+	return BooleanWithValue(a.After(b)), nil
+}
+
+// MakeTimeImpl handles (make-time year month day hour minute second [timezone]).  The
+// time is built from the wall clock in the timezone, so it is correct across DST changes.
+func MakeTimeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	var fields [6]int
+	c := args
+	for i := range fields {
+		if !IntegerP(Car(c)) {
+			err = ProcessTypeError(fmt.Sprintf("make-time expected integer date and time fields but received %s.", String(Car(c))), env)
+			return
+		}
+		fields[i] = int(IntegerValue(Car(c)))
+		c = Cdr(c)
+	}
+
+	location := time.Local
+	if NotNilP(c) {
+		location, err = locationArg("make-time", Car(c), env)
+		if err != nil {
+			return
+		}
+	}
+
+	t := time.Date(fields[0], time.Month(fields[1]), fields[2], fields[3], fields[4], fields[5], 0, location)
+	return TimeWithValue(t), nil
+}
+
+// TimeAddDateImpl handles (time-add-date time years months days).  Unlike adding a
+// duration, this keeps the wall clock time, so a day is 23 or 25 hours across a DST change.
+func TimeAddDateImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	t, err := timeArg("time-add-date", Car(args), env)
+	if err != nil {
+		return
+	}
+
+	var amounts [3]int
+	c := Cdr(args)
+	for i := range amounts {
+		if !IntegerP(Car(c)) {
+			err = ProcessTypeError(fmt.Sprintf("time-add-date expected integer years, months and days but received %s.", String(Car(c))), env)
+			return
+		}
+		amounts[i] = int(IntegerValue(Car(c)))
+		c = Cdr(c)
+	}
+	return TimeWithValue(t.AddDate(amounts[0], amounts[1], amounts[2])), nil
+}
+
+func ParseTimeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	s := Car(args)
+	if !StringP(s) {
+		err = ProcessTypeError(fmt.Sprintf("parse-time expected a string but received %s.", String(s)), env)
+		return
+	}
+	layout, err := layoutArg("parse-time", Cadr(args), env)
+	if err != nil {
+		return
+	}
+
+	location := time.UTC
+	if Length(args) == 3 {
+		location, err = locationArg("parse-time", Caddr(args), env)
+		if err != nil {
+			return
+		}
+	}
+
+	t, err := time.ParseInLocation(layout, StringValue(s), location)
+	if err != nil {
+		err = ProcessError(fmt.Sprintf("parse-time could not parse %s: %s", String(s), err), env)
+		return
+	}
+	return TimeWithValue(t), nil
+}
+
+func FormatTimeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	t, err := timeArg("format-time", Car(args), env)
+	if err != nil {
+		return
+	}
+	layout, err := layoutArg("format-time", Cadr(args), env)
+	if err != nil {
+		return
+	}
+	return StringWithValue(t.Format(layout)), nil
+}
+
+func TimeToMillisecondsImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	t, err := timeArg("time->milliseconds", Car(args), env)
+	if err != nil {
+		return
+	}
+	return IntegerWithValue(t.UnixNano() / int64(time.Millisecond)), nil
+}
+
+func MillisecondsToTimeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	ms := Car(args)
+	if !IntegerP(ms) {
+		err = ProcessTypeError(fmt.Sprintf("milliseconds->time expected an integer but received %s.", String(ms)), env)
+		return
+	}
+	return TimeWithValue(time.Unix(0, IntegerValue(ms)*int64(time.Millisecond))), nil
+}
+
+func InTimezoneImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	t, err := timeArg("in-timezone", Car(args), env)
+	if err != nil {
+		return
+	}
+	location, err := locationArg("in-timezone", Cadr(args), env)
+	if err != nil {
+		return
+	}
+	return TimeWithValue(t.In(location)), nil
+}
+
+// LocalTimezoneImpl returns the IANA name of the host's timezone, taken from $TZ or from
+// where /etc/localtime links to, or "Local" when it can't be found.  Any of them can be
+// given to in-timezone, make-time and parse-time.
+func LocalTimezoneImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return StringWithValue(localTimezoneName()), nil
+}
+
+func localTimezoneName() string {
+	name, ok := os.LookupEnv("TZ")
+	if ok {
+		// as with the C library, an empty TZ is UTC
+		name = strings.TrimPrefix(name, ":")
+		if name == "" {
+			return "UTC"
+		}
+	} else if link, err := os.Readlink("/etc/localtime"); err == nil {
+		if i := strings.LastIndex(link, "zoneinfo/"); i >= 0 {
+			name = link[i+len("zoneinfo/"):]
+		}
+	}
+	if name != "" {
+		if _, err := time.LoadLocation(name); err == nil {
+			return name
+		}
+	}
+	return "Local"
+}
+
+func TimeZoneImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	t, err := timeArg("time-zone", Car(args), env)
+	if err != nil {
+		return
+	}
+	return StringWithValue(t.Location().String()), nil
+}
+
+func TimeZoneOffsetImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	t, err := timeArg("time-zone-offset", Car(args), env)
+	if err != nil {
+		return
+	}
+	_, offset := t.Zone()
+	return DurationWithValue(time.Duration(offset) * time.Second), nil
+}
@@ -27,11 +27,13 @@ func RegisterTypePredicatePrimitives() {
 	MakePrimitiveFunction("bytearray?", "1", IsByteArrayImpl)
 	MakePrimitiveFunction("port?", "1", IsPortImpl)
 	MakePrimitiveFunction("boolean?", "1", IsBooleanImpl)
+	MakePrimitiveFunction("vector?", "1", IsVectorImpl)
+	MakePrimitiveFunction("char?", "1", IsCharacterImpl)
 }
 
 func IsAtomImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	val := Car(args)
-	return BooleanWithValue(NumberP(val) || SymbolP(val) || StringP(val) || BooleanP(val)), nil
+	return BooleanWithValue(NumberP(val) || SymbolP(val) || StringP(val) || BooleanP(val) || CharacterP(val)), nil
 }
 
 func IsPairImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
@@ -59,7 +61,7 @@ func IsStringImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 }
 
 func IsIntegerImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
-	return BooleanWithValue(IntegerP(Car(args))), nil
+	return BooleanWithValue(ExactIntegerP(Car(args))), nil
 }
 
 func IsNumberImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
@@ -93,3 +95,11 @@ func IsPortImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 func IsBooleanImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	return BooleanWithValue(BooleanP(Car(args))), nil
 }
+
+func IsVectorImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(VectorP(Car(args))), nil
+}
+
+func IsCharacterImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return BooleanWithValue(CharacterP(Car(args))), nil
+}
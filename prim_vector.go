@@ -0,0 +1,296 @@
+// Copyright 2014 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file contains the vector primitive functions.
+
+package golisp
+
+import (
+	"fmt"
+)
+
+func RegisterVectorPrimitives() {
+	MakePrimitiveFunction("make-vector", "1|2", MakeVectorImpl)
+	MakePrimitiveFunction("vector", "*", VectorImpl)
+	MakePrimitiveFunction("vector-length", "1", VectorLengthImpl)
+	MakePrimitiveFunction("vector-ref", "2", VectorRefImpl)
+	MakePrimitiveFunction("vector-set!", "3", VectorSetImpl)
+	MakePrimitiveFunction("vector->list", "1", VectorToListImpl)
+	MakePrimitiveFunction("list->vector", "1", ListToVectorImpl)
+	MakePrimitiveFunction("vector-append", "*", VectorAppendImpl)
+	MakePrimitiveFunction("vector-concatenate", "1", VectorConcatenateImpl)
+	MakePrimitiveFunction("vector-index", ">=2", VectorIndexImpl)
+	MakePrimitiveFunction("vector-count", ">=2", VectorCountImpl)
+	MakePrimitiveFunction("vector-push!", ">=2", VectorPushImpl)
+	MakePrimitiveFunction("vector-pop!", "1", VectorPopImpl)
+	MakePrimitiveFunction("vector-extend!", "2", VectorExtendImpl)
+	MakePrimitiveFunction("vector-capacity", "1", VectorCapacityImpl)
+}
+
+func vectorIndex(name string, v *Data, indexObject *Data, env *SymbolTableFrame) (index int, err error) {
+	if !IntegerP(indexObject) {
+		err = ProcessTypeError(fmt.Sprintf("%s requires an integer index but was given %s.", name, String(indexObject)), env)
+		return
+	}
+	index = int(IntegerValue(indexObject))
+	if index < 0 || index >= Length(v) {
+		err = ProcessIndexError(fmt.Sprintf("%s index was out of range. Was %d but vector has length of %d.", name, index, Length(v)), env)
+	}
+	return
+}
+
+func MakeVectorImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	k := Car(args)
+	if !IntegerP(k) || IntegerValue(k) < 0 {
+		err = ProcessTypeError(fmt.Sprintf("make-vector requires a non-negative integer size but was given %s.", String(k)), env)
+		return
+	}
+	if IntegerValue(k) > maxArrayElements {
+		err = ProcessError(fmt.Sprintf("make-vector can't make a vector of %d elements, the most is %d.", IntegerValue(k), maxArrayElements), env)
+		return
+	}
+
+	fill := Cadr(args)
+	elements := make([]*Data, IntegerValue(k))
+	for i := range elements {
+		elements[i] = fill
+	}
+	return VectorWithValue(elements), nil
+}
+
+func VectorImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return VectorWithValue(ToArray(args)), nil
+}
+
+func VectorLengthImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	v := Car(args)
+	if !VectorP(v) {
+		err = ProcessTypeError(fmt.Sprintf("vector-length requires a vector but was given %s.", String(v)), env)
+		return
+	}
+	return IntegerWithValue(int64(Length(v))), nil
+}
+
+func VectorRefImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	v := Car(args)
+	if !VectorP(v) {
+		err = ProcessTypeError(fmt.Sprintf("vector-ref requires a vector but was given %s.", String(v)), env)
+		return
+	}
+
+	index, err := vectorIndex("vector-ref", v, Cadr(args), env)
+	if err != nil {
+		return
+	}
+	return VectorValue(v)[index], nil
+}
+
+func VectorSetImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	v := Car(args)
+	if !VectorP(v) {
+		err = ProcessTypeError(fmt.Sprintf("vector-set! requires a vector but was given %s.", String(v)), env)
+		return
+	}
+
+	index, err := vectorIndex("vector-set!", v, Cadr(args), env)
+	if err != nil {
+		return
+	}
+	VectorValue(v)[index] = Caddr(args)
+	return v, nil
+}
+
+func VectorToListImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	v := Car(args)
+	if !VectorP(v) {
+		err = ProcessTypeError(fmt.Sprintf("vector->list requires a vector but was given %s.", String(v)), env)
+		return
+	}
+	return ArrayToList(VectorValue(v)), nil
+}
+
+func ListToVectorImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	l := Car(args)
+	if !ListP(l) {
+		err = ProcessTypeError(fmt.Sprintf("list->vector requires a list but was given %s.", String(l)), env)
+		return
+	}
+	c := l
+	for ; ListP(c) && NotNilP(c); c = Cdr(c) {
+	}
+	if NotNilP(c) {
+		err = ProcessTypeError(fmt.Sprintf("list->vector requires a proper list but was given %s.", String(l)), env)
+		return
+	}
+	return VectorWithValue(ToArray(l)), nil
+}
+
+// appendVectors returns a new vector of the elements of each of vectors in turn, sized up
+// front so the elements are copied once
+func appendVectors(name string, vectors []*Data, env *SymbolTableFrame) (result *Data, err error) {
+	total := 0
+	for _, v := range vectors {
+		if !VectorP(v) {
+			err = ProcessTypeError(fmt.Sprintf("%s requires vectors but was given %s.", name, String(v)), env)
+			return
+		}
+		total += len(VectorValue(v))
+	}
+
+	elements := make([]*Data, 0, total)
+	for _, v := range vectors {
+		elements = append(elements, VectorValue(v)...)
+	}
+	return VectorWithValue(elements), nil
+}
+
+func VectorAppendImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	return appendVectors("vector-append", ToArray(args), env)
+}
+
+func VectorConcatenateImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	l := Car(args)
+	if !ListP(l) {
+		err = ProcessTypeError(fmt.Sprintf("vector-concatenate requires a list of vectors but was given %s.", String(l)), env)
+		return
+	}
+	return appendVectors("vector-concatenate", ToArray(l), env)
+}
+
+// eachVectorPosition calls pred, a function given first in args, with the elements at each
+// index of the vectors following it, up to the length of the shortest, until visit returns
+// false for pred's result at that index
+func eachVectorPosition(name string, args *Data, env *SymbolTableFrame, visit func(index int, satisfied bool) bool) (err error) {
+	pred := Car(args)
+	if !FunctionOrPrimitiveP(pred) {
+		err = ProcessTypeError(fmt.Sprintf("%s requires a function but was given %s.", name, String(pred)), env)
+		return
+	}
+	vectors := make([][]*Data, 0, Length(args)-1)
+	shortest := -1
+	for c := Cdr(args); NotNilP(c); c = Cdr(c) {
+		if !VectorP(Car(c)) {
+			err = ProcessTypeError(fmt.Sprintf("%s requires vectors but was given %s.", name, String(Car(c))), env)
+			return
+		}
+		elements := VectorValue(Car(c))
+		vectors = append(vectors, elements)
+		if shortest == -1 || len(elements) < shortest {
+			shortest = len(elements)
+		}
+	}
+
+	predArgs := make([]*Data, len(vectors))
+	for i := 0; i < shortest; i++ {
+		for v, elements := range vectors {
+			predArgs[v] = elements[i]
+		}
+		var satisfied *Data
+		if satisfied, err = ApplyWithoutEval(pred, ArrayToList(predArgs), env); err != nil {
+			return
+		}
+		if !visit(i, BooleanValue(satisfied)) {
+			return
+		}
+	}
+	return
+}
+
+// VectorIndexImpl handles (vector-index pred vector...), returning the first index at which
+// pred is true of the vectors' elements, or #f if there isn't one
+func VectorIndexImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	result = LispFalse
+	err = eachVectorPosition("vector-index", args, env, func(index int, satisfied bool) bool {
+		if satisfied {
+			result = IntegerWithValue(int64(index))
+		}
+		return !satisfied
+	})
+	return
+}
+
+// VectorCountImpl handles (vector-count pred vector...), returning the number of indexes at
+// which pred is true of the vectors' elements
+func VectorCountImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	count := 0
+	err = eachVectorPosition("vector-count", args, env, func(index int, satisfied bool) bool {
+		if satisfied {
+			count++
+		}
+		return true
+	})
+	if err != nil {
+		return
+	}
+	return IntegerWithValue(int64(count)), nil
+}
+
+// vectorSlice returns the slice a vector holds, so it can be changed in place
+func vectorSlice(name string, v *Data, env *SymbolTableFrame) (slice *[]*Data, err error) {
+	if !VectorP(v) {
+		err = ProcessTypeError(fmt.Sprintf("%s requires a vector but was given %s.", name, String(v)), env)
+		return
+	}
+	return (*[]*Data)(v.Value), nil
+}
+
+// VectorPushImpl handles (vector-push! vector element...), adding the elements to the end of
+// the vector, whose capacity grows as append grows slices
+func VectorPushImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	slice, err := vectorSlice("vector-push!", Car(args), env)
+	if err != nil {
+		return
+	}
+	*slice = append(*slice, ToArray(Cdr(args))...)
+	return Car(args), nil
+}
+
+// VectorPopImpl removes the last element of a vector and returns it
+func VectorPopImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	slice, err := vectorSlice("vector-pop!", Car(args), env)
+	if err != nil {
+		return
+	}
+	last := len(*slice) - 1
+	if last < 0 {
+		err = ProcessIndexError("vector-pop! requires a vector that isn't empty.", env)
+		return
+	}
+	result = (*slice)[last]
+	(*slice)[last] = nil
+	*slice = (*slice)[:last]
+	return
+}
+
+// VectorExtendImpl handles (vector-extend! vector elements), adding the elements of a vector
+// or list to the end of the vector
+func VectorExtendImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	slice, err := vectorSlice("vector-extend!", Car(args), env)
+	if err != nil {
+		return
+	}
+	var elements []*Data
+	switch extra := Cadr(args); {
+	case VectorP(extra):
+		elements = VectorValue(extra)
+	case ListP(extra):
+		elements = ToArray(extra)
+	default:
+		err = ProcessTypeError(fmt.Sprintf("vector-extend! requires a vector or list of elements but was given %s.", String(extra)), env)
+		return
+	}
+	*slice = append(*slice, elements...)
+	return Car(args), nil
+}
+
+// VectorCapacityImpl returns how many elements a vector can hold before pushing onto it
+// has to reallocate
+func VectorCapacityImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	slice, err := vectorSlice("vector-capacity", Car(args), env)
+	if err != nil {
+		return
+	}
+	return IntegerWithValue(int64(cap(*slice))), nil
+}
@@ -10,6 +10,7 @@ package golisp
 import (
 	"fmt"
 	"strings"
+	"sync"
 	"sync/atomic"
 )
 
@@ -19,6 +20,15 @@ type PrimitiveFunction struct {
 	NumberOfArgs string
 	Body         func(d *Data, env *SymbolTableFrame) (*Data, error)
 	IsRestricted bool
+	arity        []arityTerm
+	arityOnce    sync.Once
+}
+
+// arityTerm is one "|" separated term of NumberOfArgs: an acceptable argument count range,
+// where a max of -1 means there is no upper bound
+type arityTerm struct {
+	min int
+	max int
 }
 
 func MakePrimitiveFunction(name string, argCount string, function func(*Data, *SymbolTableFrame) (*Data, error)) {
@@ -45,43 +55,57 @@ func (self *PrimitiveFunction) String() string {
 	return fmt.Sprintf("<prim: %s, %v>", self.Name, self.Body)
 }
 
-func (self *PrimitiveFunction) checkArgumentCount(argCount int) bool {
-	if self.NumberOfArgs == "*" {
-		return true
+// parseArity converts an argument count spec such as "1|2", ">=2", "(1,3)" or "*" into terms
+func parseArity(spec string) (terms []arityTerm) {
+	if spec == "*" {
+		return []arityTerm{{0, -1}}
 	}
 
-	for _, term := range strings.Split(self.NumberOfArgs, "|") {
-		var intTerm int
-		n, _ := fmt.Sscanf(term, "%d", &intTerm)
-		if n == 1 && argCount == intTerm {
-			return true
-		}
-		n, _ = fmt.Sscanf(term, ">=%d", &intTerm)
-		if n == 1 && argCount >= intTerm {
-			return true
-		}
+	for _, term := range strings.Split(spec, "|") {
 		var lo int
 		var hi int
-		n, _ = fmt.Sscanf(term, "(%d,%d)", &lo, &hi)
-		if n == 2 && lo <= argCount && argCount <= hi {
+		if n, _ := fmt.Sscanf(term, "%d", &lo); n == 1 {
+			terms = append(terms, arityTerm{lo, lo})
+		} else if n, _ := fmt.Sscanf(term, ">=%d", &lo); n == 1 {
+			terms = append(terms, arityTerm{lo, -1})
+		} else if n, _ := fmt.Sscanf(term, "(%d,%d)", &lo, &hi); n == 2 {
+			terms = append(terms, arityTerm{lo, hi})
+		}
+	}
+	return
+}
+
+func (self *PrimitiveFunction) checkArgumentCount(argCount int) bool {
+	self.arityOnce.Do(func() { self.arity = parseArity(self.NumberOfArgs) })
+
+	for _, term := range self.arity {
+		if argCount >= term.min && (term.max == -1 || argCount <= term.max) {
 			return true
 		}
 	}
 	return false
 }
 
+// arityError returns an ArityError if the primitive can't be called with argCount arguments
+func (self *PrimitiveFunction) arityError(argCount int) error {
+	if !self.checkArgumentCount(argCount) {
+		return NewLispError(ArityError, fmt.Sprintf("Wrong number of args to %s. Expected %s but got %d.\n", self.Name, self.NumberOfArgs, argCount))
+	}
+	return nil
+}
+
 func (self *PrimitiveFunction) Apply(args *Data, env *SymbolTableFrame) (result *Data, err error) {
 	if self.IsRestricted && env.IsRestricted {
 		err = fmt.Errorf("The %s primitive is restricted from execution in this environment\n", self.Name)
 		return
 	}
 
-	if !self.checkArgumentCount(Length(args)) {
-		err = fmt.Errorf("Wrong number of args to %s. Expected %s but got %d.\n", self.Name, self.NumberOfArgs, Length(args))
+	argCount := Length(args)
+	if err = self.arityError(argCount); err != nil {
 		return
 	}
 
-	argArray := make([]*Data, 0)
+	argArray := make([]*Data, 0, argCount)
 	var argValue *Data
 	for a := args; NotNilP(a); a = Cdr(a) {
 		if self.Special {
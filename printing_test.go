@@ -37,6 +37,18 @@ func (s *PrintingSuite) TestString(c *C) {
 	c.Assert(String(sexpr), Equals, `"hello, world"`)
 }
 
+func (s *PrintingSuite) TestStringWithEscapes(c *C) {
+	sexpr := StringWithValue("say \"hi\"\n\tto C:\\ \x07")
+	c.Assert(String(sexpr), Equals, `"say \"hi\"\n\tto C:\\ \x07"`)
+}
+
+func (s *PrintingSuite) TestEscapedStringRoundTrips(c *C) {
+	original := StringWithValue("line 1\r\nline 2\x00\u2028")
+	parsed, err := Parse(String(original))
+	c.Assert(err, IsNil)
+	c.Assert(StringValue(parsed), Equals, StringValue(original))
+}
+
 func (s *PrintingSuite) TestSymbol(c *C) {
 	sexpr := SymbolWithName("function")
 	c.Assert(String(sexpr), Equals, "function")
@@ -106,3 +118,103 @@ func (s *PrintingSuite) TestBytearray(c *C) {
 	sexpr := ObjectWithTypeAndValue("[]byte", unsafe.Pointer(&dataBytes))
 	c.Assert(String(sexpr), Equals, "[1 2 3 4 5]")
 }
+
+func (s *PrintingSuite) TestVector(c *C) {
+	sexpr := VectorWithValue([]*Data{IntegerWithValue(1), StringWithValue("two"), Intern("three")})
+	c.Assert(String(sexpr), Equals, `#(1 "two" three)`)
+}
+
+func (s *PrintingSuite) TestCharacter(c *C) {
+	c.Assert(String(CharacterWithValue('a')), Equals, `#\a`)
+	c.Assert(String(CharacterWithValue(' ')), Equals, `#\space`)
+	c.Assert(String(CharacterWithValue('\x01')), Equals, `#\x1`)
+	c.Assert(PrintString(CharacterWithValue('a')), Equals, "a")
+}
+
+func (s *PrintingSuite) TestNestedFrame(c *C) {
+	f, err := ParseAndEval(`{b: {c: "x"} a: 1}`)
+	c.Assert(err, IsNil)
+	c.Assert(String(f), Equals, `{a: 1 b: {c: "x"}}`)
+}
+
+func (s *PrintingSuite) TestFrameQuotesEvaluatedValues(c *C) {
+	f, err := ParseAndEval("{a: 1 b: 'c d: '(1 2) e: f:}")
+	c.Assert(err, IsNil)
+	c.Assert(String(f), Equals, "{a: 1 b: 'c d: '(1 2) e: f:}")
+}
+
+func (s *PrintingSuite) TestWrittenDataReadsBack(c *C) {
+	sources := []string{
+		`"tab\there"`,
+		`#\newline`,
+		`#(1 #\x "three" #(4))`,
+		`[1 2 3]`,
+		`{a: "one" b: #(2) c: '(3 sym) d: {e: #\f}}`,
+	}
+	for _, src := range sources {
+		original, err := ParseAndEval(src)
+		c.Assert(err, IsNil)
+		reread, err := ParseAndEval(String(original))
+		c.Assert(err, IsNil)
+		c.Assert(IsEqual(reread, original), Equals, true, Commentf("%s", src))
+	}
+}
+
+func (s *PrintingSuite) TestCircularList(c *C) {
+	sexpr := InternalMakeList(IntegerWithValue(1), IntegerWithValue(2))
+	ConsValue(Cdr(sexpr)).Cdr = sexpr
+	c.Assert(String(sexpr), Equals, "#0=(1 2 . #0#)")
+}
+
+func (s *PrintingSuite) TestListLoopingBackToTheMiddle(c *C) {
+	sexpr := InternalMakeList(IntegerWithValue(1), IntegerWithValue(2), IntegerWithValue(3), IntegerWithValue(4))
+	ConsValue(Cdddr(sexpr)).Cdr = Cdr(sexpr)
+	c.Assert(String(sexpr), Equals, "(1 . #0=(2 3 4 . #0#))")
+}
+
+func (s *PrintingSuite) TestDottedListEndingInACyclicVector(c *C) {
+	v := VectorWithValue([]*Data{nil})
+	VectorValue(v)[0] = v
+	sexpr := Cons(IntegerWithValue(1), v)
+	c.Assert(String(sexpr), Equals, "(1 . #0=#(#0#))")
+}
+
+func (s *PrintingSuite) TestFlatStructureNeedsNoLabels(c *C) {
+	c.Assert(findSharedStructure(InternalMakeList(IntegerWithValue(1), StringWithValue("a")), true), IsNil)
+	c.Assert(findSharedStructure(VectorWithValue([]*Data{IntegerWithValue(1), Intern("a")}), true), IsNil)
+	c.Assert(findSharedStructure(IntegerWithValue(1), true), IsNil)
+}
+
+func (s *PrintingSuite) TestListContainingItself(c *C) {
+	sexpr := InternalMakeList(IntegerWithValue(1), IntegerWithValue(2))
+	ConsValue(Cdr(sexpr)).Car = sexpr
+	c.Assert(String(sexpr), Equals, "#0=(1 #0#)")
+}
+
+func (s *PrintingSuite) TestVectorContainingItself(c *C) {
+	sexpr := VectorWithValue([]*Data{IntegerWithValue(1), nil})
+	VectorValue(sexpr)[1] = sexpr
+	c.Assert(String(sexpr), Equals, "#0=#(1 #0#)")
+}
+
+func (s *PrintingSuite) TestFrameContainingItself(c *C) {
+	sexpr, _ := ParseAndEval("{a: 1}")
+	FrameValue(sexpr).Data["self:"] = sexpr
+	c.Assert(String(sexpr), Equals, "#0={a: 1 self: #0#}")
+}
+
+func (s *PrintingSuite) TestSharedStructureIsOnlyLabeledWhenAskedFor(c *C) {
+	shared := InternalMakeList(IntegerWithValue(1))
+	sexpr := InternalMakeList(shared, shared)
+	c.Assert(String(sexpr), Equals, "((1) (1))")
+	c.Assert(SharedString(sexpr), Equals, "(#0=(1) #0#)")
+}
+
+func (s *PrintingSuite) TestCircularListReadsBack(c *C) {
+	original := InternalMakeList(IntegerWithValue(1), IntegerWithValue(2))
+	ConsValue(Cdr(original)).Cdr = original
+	reread, err := Parse(String(original))
+	c.Assert(err, IsNil)
+	c.Assert(Cddr(reread), Equals, reread)
+	c.Assert(String(reread), Equals, String(original))
+}
@@ -8,16 +8,42 @@
 package golisp
 
 import (
+	"compress/gzip"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
+// ProfileFormat selects what the profiler writes
+type ProfileFormat int
+
+const (
+	// ProfileEvents writes a frame for every entry to and exit from a function
+	ProfileEvents ProfileFormat = iota
+	// ProfileFolded writes one line per call stack with the nanoseconds spent in it, the
+	// folded format read by flamegraph.pl and similar tools
+	ProfileFolded
+	// ProfilePprof writes a gzipped pprof protobuf profile, for go tool pprof
+	ProfilePprof
+)
+
 var profileOutput *os.File = nil
 var ProfileEnabled = false
 var ProfileGUID int64 = 0
+var profileFormat = ProfileEvents
+var profileStacks *stackProfile = nil
 
 func StartProfiling(fname string) {
+	StartProfilingWithFormat(fname, ProfileEvents)
+}
+
+// StartProfilingWithFormat starts profiling to fname, or the trace writer if fname is
+// empty, in the given format
+func StartProfilingWithFormat(fname string, format ProfileFormat) {
 	ProfileGUID = 0
 	if fname == "" {
 		profileOutput = nil
@@ -28,21 +54,39 @@ func StartProfiling(fname string) {
 			panic(fmt.Sprintf("Profiler: %s could not be opened.", fname))
 		}
 	}
+	profileFormat = format
+	if format == ProfileEvents {
+		profileStacks = nil
+	} else {
+		profileStacks = newStackProfile()
+	}
 	ProfileEnabled = true
 }
 
 func EndProfiling() {
 	ProfileEnabled = false
+	if profileStacks != nil {
+		var output io.Writer = TraceWriter()
+		if profileOutput != nil {
+			output = profileOutput
+		}
+		if profileFormat == ProfilePprof {
+			profileStacks.writePprof(output)
+		} else {
+			profileStacks.writeFolded(output)
+		}
+		profileStacks = nil
+	}
 	if profileOutput != nil {
 		profileOutput.Close()
 	}
 }
 
 func ProfileEnter(funcType string, name string, guid int64) {
-	if ProfileEnabled {
+	if ProfileEnabled && profileStacks == nil {
 		msg := fmt.Sprintf("{time: %d guid: %d mode: 'enter type: '%s name: '%s}\n", time.Now().UnixNano(), guid, funcType, name)
 		if profileOutput == nil {
-			fmt.Printf(msg)
+			fmt.Fprint(TraceWriter(), msg)
 		} else {
 			fmt.Fprintf(profileOutput, msg)
 		}
@@ -50,12 +94,112 @@ func ProfileEnter(funcType string, name string, guid int64) {
 }
 
 func ProfileExit(funcType string, name string, guid int64) {
-	if ProfileEnabled {
+	if ProfileEnabled && profileStacks == nil {
 		msg := fmt.Sprintf("{time: %d guid: %d mode: 'exit type: '%s name: '%s}\n", time.Now().UnixNano(), guid, funcType, name)
 		if profileOutput == nil {
-			fmt.Printf(msg)
+			fmt.Fprint(TraceWriter(), msg)
 		} else {
 			fmt.Fprintf(profileOutput, msg)
 		}
 	}
 }
+
+// profileStarted returns when a function call is starting, if its call stack is being
+// profiled
+func profileStarted() time.Time {
+	if ProfileEnabled && profileStacks != nil {
+		return time.Now()
+	}
+	return time.Time{}
+}
+
+// profileFinished records the time since started against the call stack of env, the
+// frame of a function call
+func profileFinished(env *SymbolTableFrame, started time.Time) {
+	if stacks := profileStacks; !started.IsZero() && stacks != nil {
+		stacks.record(callStackNames(env), time.Since(started))
+	}
+}
+
+// callStackNames returns the names of the function calls that led to env, outermost first
+func callStackNames(env *SymbolTableFrame) []string {
+	names := make([]string, 0)
+	for e := env; e != nil; e = e.Previous {
+		if e.function != nil {
+			names = append(names, e.function.Name)
+		}
+	}
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return names
+}
+
+// stackProfile totals the time spent in, and the number of calls to, each call stack of
+// user written functions.  Time in primitives counts towards the function calling them.
+type stackProfile struct {
+	inclusive map[string]int64
+	calls     map[string]int64
+	mutex     sync.Mutex
+}
+
+func newStackProfile() *stackProfile {
+	return &stackProfile{inclusive: make(map[string]int64), calls: make(map[string]int64)}
+}
+
+func (self *stackProfile) record(stack []string, elapsed time.Duration) {
+	key := strings.Join(stack, ";")
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.inclusive[key] += int64(elapsed)
+	self.calls[key]++
+}
+
+// selfTimes returns the sorted stacks along with the time spent in the innermost function
+// of each, excluding the calls it made.  Calls made concurrently can make a caller's
+// children take longer than it did, in which case its self time is zero.
+func (self *stackProfile) selfTimes() (stacks []string, times map[string]int64) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	times = make(map[string]int64, len(self.inclusive))
+	for stack, inclusive := range self.inclusive {
+		times[stack] += inclusive
+		if separator := strings.LastIndex(stack, ";"); separator >= 0 {
+			times[stack[:separator]] -= inclusive
+		}
+		stacks = append(stacks, stack)
+	}
+	for stack, time := range times {
+		if time < 0 {
+			times[stack] = 0
+		}
+	}
+	sort.Strings(stacks)
+	return
+}
+
+func (self *stackProfile) writeFolded(output io.Writer) {
+	stacks, times := self.selfTimes()
+	for _, stack := range stacks {
+		fmt.Fprintf(output, "%s %d\n", stack, times[stack])
+	}
+}
+
+func (self *stackProfile) writePprof(output io.Writer) error {
+	stacks, times := self.selfTimes()
+	profile := newPprofBuilder()
+	profile.sampleType("calls", "count")
+	profile.sampleType("time", "nanoseconds")
+	for _, stack := range stacks {
+		self.mutex.Lock()
+		calls := self.calls[stack]
+		self.mutex.Unlock()
+		profile.sample(strings.Split(stack, ";"), calls, times[stack])
+	}
+
+	compressed := gzip.NewWriter(output)
+	if _, err := compressed.Write(profile.bytes()); err != nil {
+		return err
+	}
+	return compressed.Close()
+}
@@ -0,0 +1,97 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests the profiler's call stack output.
+
+package golisp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type ProfilingSuite struct {
+}
+
+var _ = Suite(&ProfilingSuite{})
+
+func (s *ProfilingSuite) SetUpSuite(c *C) {
+	_, err := ParseAndEvalAll(`
+(define (profile-test-leaf n) (* n 2))
+(define (profile-test-middle n) (profile-test-leaf n) (profile-test-leaf n))
+(define (profile-test-top) (profile-test-middle 1) (profile-test-leaf 2))`)
+	c.Assert(err, IsNil)
+}
+
+func (s *ProfilingSuite) TestFoldedStacks(c *C) {
+	var trace bytes.Buffer
+	SetTraceWriter(&trace)
+	defer SetTraceWriter(nil)
+
+	_, err := ParseAndEval("(profile (profile-test-top) nil 'folded)")
+	c.Assert(err, IsNil)
+
+	stacks := make([]string, 0)
+	for _, line := range strings.Split(strings.TrimSpace(trace.String()), "\n") {
+		separator := strings.LastIndex(line, " ")
+		c.Assert(separator > 0, Equals, true)
+		_, err := strconv.ParseInt(line[separator+1:], 10, 64)
+		c.Assert(err, IsNil)
+		stacks = append(stacks, line[:separator])
+	}
+	c.Assert(stacks, DeepEquals, []string{
+		"profile-test-top",
+		"profile-test-top;profile-test-leaf",
+		"profile-test-top;profile-test-middle",
+		"profile-test-top;profile-test-middle;profile-test-leaf",
+	})
+}
+
+func (s *ProfilingSuite) TestSelfTimes(c *C) {
+	profile := newStackProfile()
+	profile.record([]string{"a"}, 100)
+	profile.record([]string{"a", "b"}, 30)
+	profile.record([]string{"a", "b"}, 20)
+	profile.record([]string{"a", "b", "c"}, 60)
+	profile.record([]string{"a", "d"}, 10)
+
+	var folded bytes.Buffer
+	profile.writeFolded(&folded)
+	c.Assert(folded.String(), Equals, "a 40\na;b 0\na;b;c 60\na;d 10\n")
+}
+
+func (s *ProfilingSuite) TestPprofProfile(c *C) {
+	filename := filepath.Join(c.MkDir(), "profile.pb.gz")
+	_, err := ParseAndEval(`(profile (profile-test-top) "` + filename + `" 'pprof)`)
+	c.Assert(err, IsNil)
+
+	compressed, err := ioutil.ReadFile(filename)
+	c.Assert(err, IsNil)
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	c.Assert(err, IsNil)
+	profile, err := ioutil.ReadAll(reader)
+	c.Assert(err, IsNil)
+	c.Assert(bytes.Contains(profile, []byte("profile-test-middle")), Equals, true)
+	c.Assert(bytes.Contains(profile, []byte("nanoseconds")), Equals, true)
+}
+
+func (s *ProfilingSuite) TestProtobufVarints(c *C) {
+	var message protobuf
+	message.uint64Field(1, 300)
+	message.packedField(2, []uint64{1, 150})
+	c.Assert(message.data, DeepEquals, []byte{0x08, 0xac, 0x02, 0x12, 0x03, 0x01, 0x96, 0x01})
+}
+
+func (s *ProfilingSuite) TestBadFormat(c *C) {
+	_, err := ParseAndEval("(profile (profile-test-top) nil 'flames)")
+	c.Assert(err, NotNil)
+	c.Assert(ProfileEnabled, Equals, false)
+}
@@ -0,0 +1,296 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements record types, as made by define-record-type.
+
+package golisp
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// RecordType describes a kind of record: its name and the names of its fields
+type RecordType struct {
+	Name   string
+	Fields []string
+}
+
+// Record is an instance of a RecordType, with a value for each of its fields in order
+type Record struct {
+	Type   *RecordType
+	Values []*Data
+}
+
+func NewRecordType(name string, fields ...string) *RecordType {
+	return &RecordType{Name: name, Fields: fields}
+}
+
+// FieldIndex returns the position of the named field, or -1 if the type doesn't have one
+func (self *RecordType) FieldIndex(field string) int {
+	for i, name := range self.Fields {
+		if name == field {
+			return i
+		}
+	}
+	return -1
+}
+
+// New makes a record of this type with the fields set from values in order, and any
+// left over set to nil
+func (self *RecordType) New(values ...*Data) *Record {
+	record := &Record{Type: self, Values: make([]*Data, len(self.Fields))}
+	copy(record.Values, values)
+	return record
+}
+
+// Get returns the value of the named field, and whether the record has one
+func (self *Record) Get(field string) (value *Data, found bool) {
+	index := self.Type.FieldIndex(field)
+	if index < 0 {
+		return nil, false
+	}
+	return self.Values[index], true
+}
+
+// Set sets the value of the named field, returning false if the record has no such field
+func (self *Record) Set(field string, value *Data) bool {
+	index := self.Type.FieldIndex(field)
+	if index < 0 {
+		return false
+	}
+	self.Values[index] = value
+	return true
+}
+
+func (self *Record) String() string {
+	return String(RecordWithValue(self))
+}
+
+// stringWithLabels prints the record with the labels String found for shared structure, since
+// a record's fields can refer back to it
+func (self *Record) stringWithLabels(labels *printLabels) string {
+	fields := make([]string, len(self.Values))
+	for i, value := range self.Values {
+		fields[i] = fmt.Sprintf(" %s: %s", self.Type.Fields[i], stringWithLabels(value, labels))
+	}
+	return fmt.Sprintf("#<%s%s>", self.Type.Name, strings.Join(fields, ""))
+}
+
+func (self *Record) equal(other *Record, inProgress map[[2]unsafe.Pointer]bool) bool {
+	if self.Type != other.Type {
+		return false
+	}
+
+	// if we get back to records we are already comparing, they match so far
+	key := [2]unsafe.Pointer{unsafe.Pointer(self), unsafe.Pointer(other)}
+	if inProgress[key] {
+		return true
+	}
+	inProgress[key] = true
+	defer delete(inProgress, key)
+
+	for i, value := range self.Values {
+		if !isDeepEqual(value, other.Values[i], inProgress) {
+			return false
+		}
+	}
+	return true
+}
+
+func (self *Record) hash(depth int) uint64 {
+	h := hashBytes('r', []byte(self.Type.Name))
+	if depth == 0 {
+		return h
+	}
+	for i := 0; i < len(self.Values) && i < maxHashElements; i++ {
+		h = combineHashes(h, hashHelper(self.Values[i], depth-1))
+	}
+	return h
+}
+
+func init() {
+	registerObjectEqualInProgress("Record",
+		func(a unsafe.Pointer, b unsafe.Pointer, inProgress map[[2]unsafe.Pointer]bool) bool {
+			return (*Record)(a).equal((*Record)(b), inProgress)
+		})
+	registerObjectHashWithDepth("Record",
+		func(o unsafe.Pointer, depth int) uint64 {
+			return (*Record)(o).hash(depth)
+		})
+}
+
+func RecordTypeWithValue(recordType *RecordType) *Data {
+	return ObjectWithTypeAndValue("RecordType", unsafe.Pointer(recordType))
+}
+
+func RecordTypeP(d *Data) bool {
+	return ObjectP(d) && ObjectType(d) == "RecordType"
+}
+
+func RecordTypeValue(d *Data) *RecordType {
+	if !RecordTypeP(d) {
+		return nil
+	}
+	return (*RecordType)(ObjectValue(d))
+}
+
+func RecordWithValue(record *Record) *Data {
+	return ObjectWithTypeAndValue("Record", unsafe.Pointer(record))
+}
+
+func RecordP(d *Data) bool {
+	return ObjectP(d) && ObjectType(d) == "Record"
+}
+
+func RecordValue(d *Data) *Record {
+	if !RecordP(d) {
+		return nil
+	}
+	return (*Record)(ObjectValue(d))
+}
+
+// recordOf returns d as a record of recordType, or an error on behalf of the function called
+// name if it isn't one
+func recordOf(name string, recordType *RecordType, d *Data, env *SymbolTableFrame) (record *Record, err error) {
+	if record = RecordValue(d); record == nil || record.Type != recordType {
+		err = ProcessTypeError(fmt.Sprintf("%s expects a %s record but received %s.", name, recordType.Name, String(d)), env)
+	}
+	return
+}
+
+func allSymbols(l *Data) bool {
+	for c := l; NotNilP(c); c = Cdr(c) {
+		if !SymbolP(Car(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// bindRecordPrimitive binds name in env to a primitive function
+func bindRecordPrimitive(env *SymbolTableFrame, name string, argCount string, body func(*Data, *SymbolTableFrame) (*Data, error)) (err error) {
+	f := &PrimitiveFunction{Name: name, NumberOfArgs: argCount, Body: body}
+	_, err = env.BindLocallyTo(Intern(name), PrimitiveWithNameAndFunc(name, f))
+	return
+}
+
+// DefineRecordTypeImpl handles (define-record-type name (constructor field...) predicate
+// (field accessor [modifier])...), binding name to the record type and the constructor,
+// predicate, accessors, and modifiers to primitives.  The constructor can also be just a name, taking every field in order, or
+// #f for none.
+func DefineRecordTypeImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	typeName := Car(args)
+	if !SymbolP(typeName) {
+		err = ProcessTypeError(fmt.Sprintf("define-record-type expects a type name but received %s.", String(typeName)), env)
+		return
+	}
+
+	fieldSpecs := Cdddr(args)
+	fields := make([]string, 0, Length(fieldSpecs))
+	for c := fieldSpecs; NotNilP(c); c = Cdr(c) {
+		spec := Car(c)
+		if SymbolP(spec) {
+			spec = InternalMakeList(spec)
+		}
+		if !PairP(spec) || Length(spec) > 3 || !allSymbols(spec) {
+			err = ProcessError(fmt.Sprintf("define-record-type expects (field accessor [modifier]) but received %s.", String(Car(c))), env)
+			return
+		}
+		fields = append(fields, StringValue(Car(spec)))
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(StringValue(typeName), "<"), ">")
+	recordType := NewRecordType(name, fields...)
+
+	if err = defineRecordConstructor(recordType, Cadr(args), env); err != nil {
+		return
+	}
+
+	predicate := Caddr(args)
+	if !SymbolP(predicate) {
+		err = ProcessTypeError(fmt.Sprintf("define-record-type expects a predicate name but received %s.", String(predicate)), env)
+		return
+	}
+	err = bindRecordPrimitive(env, StringValue(predicate), "1", func(args *Data, env *SymbolTableFrame) (*Data, error) {
+		record := RecordValue(Car(args))
+		return BooleanWithValue(record != nil && record.Type == recordType), nil
+	})
+	if err != nil {
+		return
+	}
+
+	for c, index := fieldSpecs, 0; NotNilP(c); c, index = Cdr(c), index+1 {
+		if PairP(Car(c)) {
+			if err = defineRecordFieldFunctions(recordType, index, Car(c), env); err != nil {
+				return
+			}
+		}
+	}
+
+	return env.BindLocallyTo(typeName, RecordTypeWithValue(recordType))
+}
+
+func defineRecordConstructor(recordType *RecordType, spec *Data, env *SymbolTableFrame) (err error) {
+	if BooleanP(spec) && !BooleanValue(spec) {
+		return
+	}
+	if SymbolP(spec) {
+		names := []*Data{spec}
+		for _, field := range recordType.Fields {
+			names = append(names, Intern(field))
+		}
+		spec = ArrayToList(names)
+	}
+	if !PairP(spec) || !SymbolP(Car(spec)) {
+		return ProcessError(fmt.Sprintf("define-record-type expects (constructor field...) but received %s.", String(spec)), env)
+	}
+
+	constructor := StringValue(Car(spec))
+	positions := make([]int, 0, Length(Cdr(spec)))
+	for c := Cdr(spec); NotNilP(c); c = Cdr(c) {
+		index := recordType.FieldIndex(StringValue(Car(c)))
+		if !SymbolP(Car(c)) || index < 0 {
+			return ProcessError(fmt.Sprintf("%s's constructor takes %s, which isn't one of its fields.", recordType.Name, String(Car(c))), env)
+		}
+		positions = append(positions, index)
+	}
+	return bindRecordPrimitive(env, constructor, fmt.Sprintf("%d", len(positions)), func(args *Data, env *SymbolTableFrame) (*Data, error) {
+		record := recordType.New()
+		for _, index := range positions {
+			record.Values[index] = Car(args)
+			args = Cdr(args)
+		}
+		return RecordWithValue(record), nil
+	})
+}
+
+func defineRecordFieldFunctions(recordType *RecordType, index int, spec *Data, env *SymbolTableFrame) (err error) {
+	if accessor := Cadr(spec); NotNilP(accessor) {
+		name := StringValue(accessor)
+		err = bindRecordPrimitive(env, name, "1", func(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+			record, err := recordOf(name, recordType, Car(args), env)
+			if err != nil {
+				return
+			}
+			return record.Values[index], nil
+		})
+		if err != nil {
+			return
+		}
+	}
+	if modifier := Caddr(spec); NotNilP(modifier) {
+		name := StringValue(modifier)
+		err = bindRecordPrimitive(env, name, "2", func(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+			record, err := recordOf(name, recordType, Car(args), env)
+			if err != nil {
+				return
+			}
+			record.Values[index] = Cadr(args)
+			return Cadr(args), nil
+		})
+	}
+	return
+}
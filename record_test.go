@@ -0,0 +1,48 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests using records from Go.
+
+package golisp
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type RecordSuite struct {
+}
+
+var _ = Suite(&RecordSuite{})
+
+func (s *RecordSuite) TestRecordsFromLisp(c *C) {
+	record, err := ParseAndEvalAll(`(define-record-type sensor (make-sensor id reading) sensor? (id sensor-id) (reading sensor-reading))
+(make-sensor 7 21.5)`)
+	c.Assert(err, IsNil)
+	c.Assert(RecordP(record), Equals, true)
+	c.Assert(RecordValue(record).Type.Name, Equals, "sensor")
+	c.Assert(RecordValue(record).Type.Fields, DeepEquals, []string{"id", "reading"})
+
+	id, found := RecordValue(record).Get("id")
+	c.Assert(found, Equals, true)
+	c.Assert(IntegerValue(id), Equals, int64(7))
+	_, found = RecordValue(record).Get("missing")
+	c.Assert(found, Equals, false)
+}
+
+func (s *RecordSuite) TestRecordsFromGo(c *C) {
+	recordType, err := ParseAndEvalAll(`(define-record-type sensor2 (make-sensor2 id) sensor2? (id sensor2-id set-sensor2-id!))
+sensor2`)
+	c.Assert(err, IsNil)
+	c.Assert(RecordTypeP(recordType), Equals, true)
+
+	record := RecordTypeValue(recordType).New(IntegerWithValue(3))
+	c.Assert(record.Set("id", IntegerWithValue(4)), Equals, true)
+	c.Assert(record.Set("missing", nil), Equals, false)
+	Global.BindTo(Intern("record-test-sensor"), RecordWithValue(record))
+
+	result, err := ParseAndEval("(sensor2-id record-test-sensor)")
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(4))
+}
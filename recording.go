@@ -0,0 +1,244 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements recording execution and stepping through recordings offline.
+
+package golisp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// BindingChange is a binding that was created or assigned, with its new value printed
+type BindingChange struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// RecordedStep is an eval event along with the binding changes made since the previous
+// step, e.g. by the set! or define whose return the step records
+type RecordedStep struct {
+	EvalEvent
+	Changes []BindingChange `json:"changes,omitempty"`
+}
+
+type Recording struct {
+	Steps []*RecordedStep
+}
+
+// Save writes the recording as a line of JSON per step
+func (self *Recording) Save(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	for _, step := range self.Steps {
+		if err := encoder.Encode(step); err != nil {
+			return ioError(err)
+		}
+	}
+	return nil
+}
+
+// LoadRecording reads a recording written by Save
+func LoadRecording(r io.Reader) (recording *Recording, err error) {
+	recording = &Recording{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		step := &RecordedStep{}
+		if err = json.Unmarshal(scanner.Bytes(), step); err != nil {
+			return nil, NewLispError(ParseError, fmt.Sprintf("Bad step %d in recording: %s", len(recording.Steps)+1, err))
+		}
+		recording.Steps = append(recording.Steps, step)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, ioError(err)
+	}
+	return
+}
+
+// Recorder captures eval events and binding changes.  While it is recording it installs its
+// own eval event handler, which passes events on to the handler that was there before, and
+// puts that handler back when it stops.
+type Recorder struct {
+	mutex    sync.Mutex
+	steps    []*RecordedStep
+	pending  []BindingChange
+	previous EvalEventHandler
+}
+
+// bindingChangeHook holds a func(name string, value *Data) while something is recording.
+var bindingChangeHook atomic.Value
+
+func noteBindingChange(symbol *Data, value *Data) {
+	if hook, _ := bindingChangeHook.Load().(func(string, *Data)); hook != nil {
+		hook(StringValue(symbol), value)
+	}
+}
+
+func (self *Recorder) Start() {
+	bindingChangeHook.Store(self.bindingChanged)
+	self.previous = currentEvalEventHandler()
+	SetEvalEventHandler(func(event *EvalEvent) {
+		self.record(event)
+		if self.previous != nil {
+			self.previous(event)
+		}
+	})
+}
+
+// Stop stops recording and returns what was recorded
+func (self *Recorder) Stop() *Recording {
+	SetEvalEventHandler(self.previous)
+	bindingChangeHook.Store((func(string, *Data))(nil))
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return &Recording{Steps: self.steps}
+}
+
+func (self *Recorder) bindingChanged(name string, value *Data) {
+	change := BindingChange{Name: name, Value: String(value)}
+	self.mutex.Lock()
+	self.pending = append(self.pending, change)
+	self.mutex.Unlock()
+}
+
+func (self *Recorder) record(event *EvalEvent) {
+	self.mutex.Lock()
+	self.steps = append(self.steps, &RecordedStep{EvalEvent: *event, Changes: self.pending})
+	self.pending = nil
+	self.mutex.Unlock()
+}
+
+// Replayer steps back and forth through a recording
+type Replayer struct {
+	Recording *Recording
+	Position  int
+}
+
+func (self *Replayer) Current() *RecordedStep {
+	if self.Position < 0 || self.Position >= len(self.Recording.Steps) {
+		return nil
+	}
+	return self.Recording.Steps[self.Position]
+}
+
+// Goto moves to step n, counting from 0, and reports whether there is such a step
+func (self *Replayer) Goto(n int) bool {
+	if n < 0 || n >= len(self.Recording.Steps) {
+		return false
+	}
+	self.Position = n
+	return true
+}
+
+// Find moves to the next step after the current one whose expression, function, or
+// binding changes mention text, and reports whether there is one
+func (self *Replayer) Find(text string) bool {
+	for i := self.Position + 1; i < len(self.Recording.Steps); i++ {
+		step := self.Recording.Steps[i]
+		found := strings.Contains(step.Expression, text) || strings.Contains(step.Function, text)
+		for _, change := range step.Changes {
+			found = found || change.Name == text
+		}
+		if found {
+			self.Position = i
+			return true
+		}
+	}
+	return false
+}
+
+func (self *Replayer) describeCurrent(w io.Writer) {
+	step := self.Current()
+	if step == nil {
+		fmt.Fprintf(w, "No steps recorded.\n")
+		return
+	}
+	fmt.Fprintf(w, "[%d/%d] %3d: ", self.Position+1, len(self.Recording.Steps), step.Depth)
+	switch step.Kind {
+	case EvalStarted:
+		fmt.Fprintf(w, "> %s\n", step.Expression)
+	case ApplyStarted:
+		fmt.Fprintf(w, "apply %s to %s\n", step.Function, step.Args)
+	case EvalReturned:
+		if step.Error != "" {
+			fmt.Fprintf(w, "< %s failed: %s\n", step.Expression, step.Error)
+		} else {
+			fmt.Fprintf(w, "< %s ==> %s\n", step.Expression, step.Result)
+		}
+	}
+	for _, change := range step.Changes {
+		fmt.Fprintf(w, "      %s := %s\n", change.Name, change.Value)
+	}
+}
+
+// Command carries out a replay command, writing what it shows to w, and reports whether
+// the command was to quit
+func (self *Replayer) Command(input string, w io.Writer) (quit bool) {
+	tokens := strings.Fields(input)
+	if len(tokens) == 0 {
+		tokens = []string{"n"}
+	}
+	switch tokens[0] {
+	case "n":
+		if !self.Goto(self.Position + 1) {
+			fmt.Fprintf(w, "At the last step.\n")
+			return
+		}
+	case "p":
+		if !self.Goto(self.Position - 1) {
+			fmt.Fprintf(w, "At the first step.\n")
+			return
+		}
+	case "g":
+		n, err := strconv.Atoi(strings.Join(tokens[1:], ""))
+		if err != nil || !self.Goto(n-1) {
+			fmt.Fprintf(w, "Bad step number: '%s'.\n", strings.Join(tokens[1:], " "))
+			return
+		}
+	case "f":
+		if !self.Find(strings.Join(tokens[1:], " ")) {
+			fmt.Fprintf(w, "Not found.\n")
+			return
+		}
+	case "w":
+	case "q":
+		return true
+	default:
+		fmt.Fprintf(w, "Replay commands\n")
+		fmt.Fprintf(w, "---------------\n")
+		fmt.Fprintf(w, "n        - next step (also an empty line)\n")
+		fmt.Fprintf(w, "p        - previous step\n")
+		fmt.Fprintf(w, "g step#  - go to a step\n")
+		fmt.Fprintf(w, "f text   - find the next step mentioning text\n")
+		fmt.Fprintf(w, "w        - show the current step\n")
+		fmt.Fprintf(w, "q        - quit replaying\n")
+		return
+	}
+	self.describeCurrent(w)
+	return
+}
+
+// ReplayRepl steps through recording interactively
+func ReplayRepl(recording *Recording) {
+	replayer := &Replayer{Recording: recording}
+	replayer.describeCurrent(OutputWriter())
+	prompt := "R> "
+	for {
+		input := ReadLine(&prompt)
+		if input == nil || replayer.Command(*input, OutputWriter()) {
+			return
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests recording and replaying execution.
+
+package golisp
+
+import (
+	"bytes"
+	. "gopkg.in/check.v1"
+	"sync/atomic"
+)
+
+type RecordingSuite struct {
+	recording *Recording
+}
+
+var _ = Suite(&RecordingSuite{})
+
+func (s *RecordingSuite) SetUpTest(c *C) {
+	recorder := &Recorder{}
+	recorder.Start()
+	_, err := ParseAndEvalAll(`(define recorded-x 1) (set! recorded-x (+ recorded-x 1))`)
+	s.recording = recorder.Stop()
+	c.Assert(err, IsNil)
+}
+
+func (s *RecordingSuite) changesTo(recording *Recording, name string) (values []string) {
+	for _, step := range recording.Steps {
+		for _, change := range step.Changes {
+			if change.Name == name {
+				values = append(values, change.Value)
+			}
+		}
+	}
+	return
+}
+
+func (s *RecordingSuite) TestRecordsBindingChanges(c *C) {
+	c.Assert(s.changesTo(s.recording, "recorded-x"), DeepEquals, []string{"1", "2"})
+}
+
+func (s *RecordingSuite) TestStopsRecording(c *C) {
+	steps := len(s.recording.Steps)
+	_, err := ParseAndEval(`(set! recorded-x 5)`)
+	c.Assert(err, IsNil)
+	c.Assert(len(s.recording.Steps), Equals, steps)
+}
+
+func (s *RecordingSuite) TestKeepsOtherEventHandler(c *C) {
+	var events int64
+	SetEvalEventHandler(func(event *EvalEvent) { atomic.AddInt64(&events, 1) })
+	defer SetEvalEventHandler(nil)
+
+	recorder := &Recorder{}
+	recorder.Start()
+	_, err := ParseAndEval(`(+ 1 2)`)
+	c.Assert(err, IsNil)
+	recording := recorder.Stop()
+	recorded := atomic.LoadInt64(&events)
+	c.Assert(recorded >= int64(len(recording.Steps)), Equals, true)
+	c.Assert(recorded > 0, Equals, true)
+
+	_, err = ParseAndEval(`(+ 1 2)`)
+	c.Assert(err, IsNil)
+	c.Assert(atomic.LoadInt64(&events) > recorded, Equals, true)
+}
+
+func (s *RecordingSuite) TestSaveAndLoad(c *C) {
+	buffer := new(bytes.Buffer)
+	c.Assert(s.recording.Save(buffer), IsNil)
+	loaded, err := LoadRecording(buffer)
+	c.Assert(err, IsNil)
+	c.Assert(len(loaded.Steps), Equals, len(s.recording.Steps))
+	c.Assert(loaded.Steps[0].Kind, Equals, s.recording.Steps[0].Kind)
+	c.Assert(loaded.Steps[0].Expression, Equals, s.recording.Steps[0].Expression)
+	c.Assert(s.changesTo(loaded, "recorded-x"), DeepEquals, []string{"1", "2"})
+}
+
+func (s *RecordingSuite) TestLoadingBadRecording(c *C) {
+	_, err := LoadRecording(bytes.NewBufferString(`{"kind": "eval"}` + "\nnot json\n"))
+	c.Assert(err, NotNil)
+	c.Assert(ErrorCategoryOf(err), Equals, ParseError)
+}
+
+func (s *RecordingSuite) TestReplaying(c *C) {
+	output := new(bytes.Buffer)
+	replayer := &Replayer{Recording: s.recording}
+	c.Assert(replayer.Command("p", output), Equals, false)
+	c.Assert(output.String(), Equals, "At the first step.\n")
+
+	output.Reset()
+	replayer.Command("f recorded-x", output)
+	c.Assert(output.String(), Matches, `(?s)\[\d+/\d+\].*recorded-x := 1.*`)
+
+	output.Reset()
+	replayer.Command("g 1", output)
+	c.Assert(replayer.Position, Equals, 0)
+	c.Assert(output.String(), Matches, `\[1/\d+\] .*> \(define recorded-x 1\)\n`)
+
+	output.Reset()
+	replayer.Command("g 0", output)
+	c.Assert(output.String(), Equals, "Bad step number: '0'.\n")
+	c.Assert(replayer.Command("q", output), Equals, true)
+}
@@ -10,13 +10,18 @@ package golisp
 import (
 	"container/list"
 	"fmt"
+	"os"
+	"path/filepath"
 )
 
+// InitFileName is the name of the files LoadInitFiles looks for
+const InitFileName = ".golisprc"
+
 func Repl() {
 	IsInteractive = true
-	fmt.Printf("Welcome to GoLisp 1.0\n")
-	fmt.Printf("Copyright 2015 SteelSeries\n")
-	fmt.Printf("Evaluate '(quit)' to exit.\n\n")
+	fmt.Fprintf(OutputWriter(), "Welcome to GoLisp 1.0\n")
+	fmt.Fprintf(OutputWriter(), "Copyright 2015 SteelSeries\n")
+	fmt.Fprintf(OutputWriter(), "Evaluate '(quit)' to exit.\n\n")
 	prompt := "> "
 	LoadHistoryFromFile(".golisp_history")
 	lastInput := ""
@@ -24,7 +29,7 @@ func Repl() {
 	for true {
 		defer func() {
 			if x := recover(); x != nil {
-				fmt.Printf("Don't Panic! %v\n", x)
+				fmt.Fprintf(OutputWriter(), "Don't Panic! %v\n", x)
 			}
 		}()
 		DebugCurrentFrame = nil
@@ -36,11 +41,11 @@ func Repl() {
 			QuitImpl(nil, nil)
 		} else {
 			input := *inputp
-			//			fmt.Printf("input: <%s>\n", inputp)
+			//			fmt.Fprintf(OutputWriter(), "input: <%s>\n", inputp)
 			if input != "" {
 				code, err := Parse(input)
 				if err != nil {
-					fmt.Printf("Error: %s\n", err)
+					fmt.Fprintf(OutputWriter(), "Error: %s\n", err)
 				} else {
 					if input != lastInput {
 						AddHistory(input)
@@ -48,15 +53,48 @@ func Repl() {
 					}
 					d, err := Eval(code, replEnv)
 					if err != nil {
-						fmt.Printf("Error in evaluation: %s\n", err)
-						if DebugOnError {
+						fmt.Fprintf(OutputWriter(), "Error in evaluation: %s\n", err)
+						if debugOnErrorEnabled() {
 							DebugRepl(DebugErrorEnv)
 						}
 					} else {
-						fmt.Printf("==> %s\n", String(d))
+						fmt.Fprintf(OutputWriter(), "==> %s\n", String(d))
 					}
 				}
 			}
 		}
 	}
 }
+
+// InitFiles returns the init files that exist: ~/.golisprc, then .golisprc in the current
+// directory, so that project settings can build on personal ones
+func InitFiles() []string {
+	home, _ := os.UserHomeDir()
+	dir, _ := os.Getwd()
+	return initFilesIn(home, dir)
+}
+
+func initFilesIn(home string, dir string) (files []string) {
+	seen := make(map[string]bool)
+	for _, directory := range []string{home, dir} {
+		if directory == "" {
+			continue
+		}
+		filename := filepath.Join(directory, InitFileName)
+		if !seen[filename] && fileExists(filename) {
+			seen[filename] = true
+			files = append(files, filename)
+		}
+	}
+	return
+}
+
+// LoadInitFiles loads each of InitFiles into the global environment, reporting rather than
+// stopping at a file that fails so a broken one doesn't lock the user out of the REPL
+func LoadInitFiles() {
+	for _, filename := range InitFiles() {
+		if _, err := ProcessFile(filename); err != nil {
+			fmt.Fprintf(ErrorWriter(), "Error loading %s: %s\n", filename, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests finding the REPL's init files.
+
+package golisp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type ReplSuite struct {
+	home    string
+	project string
+}
+
+var _ = Suite(&ReplSuite{})
+
+func (s *ReplSuite) SetUpTest(c *C) {
+	s.home = c.MkDir()
+	s.project = c.MkDir()
+}
+
+func (s *ReplSuite) writeInitFile(c *C, directory string, contents string) string {
+	filename := filepath.Join(directory, InitFileName)
+	c.Assert(ioutil.WriteFile(filename, []byte(contents), 0644), IsNil)
+	return filename
+}
+
+func (s *ReplSuite) TestNoInitFiles(c *C) {
+	c.Assert(initFilesIn(s.home, s.project), HasLen, 0)
+	c.Assert(initFilesIn("", ""), HasLen, 0)
+}
+
+func (s *ReplSuite) TestHomeBeforeProject(c *C) {
+	project := s.writeInitFile(c, s.project, "")
+	home := s.writeInitFile(c, s.home, "")
+	c.Assert(initFilesIn(s.home, s.project), DeepEquals, []string{home, project})
+}
+
+func (s *ReplSuite) TestHomeIsTheProject(c *C) {
+	home := s.writeInitFile(c, s.home, "")
+	c.Assert(initFilesIn(s.home, s.home), DeepEquals, []string{home})
+}
+
+func (s *ReplSuite) TestLoadingInitFiles(c *C) {
+	s.writeInitFile(c, s.home, "(define repl-test-rc-home 1)")
+	s.writeInitFile(c, s.project, "(define repl-test-rc-project (+ repl-test-rc-home 1))")
+	oldHome := os.Getenv("HOME")
+	oldDir, err := os.Getwd()
+	c.Assert(err, IsNil)
+	os.Setenv("HOME", s.home)
+	c.Assert(os.Chdir(s.project), IsNil)
+	defer func() {
+		os.Setenv("HOME", oldHome)
+		os.Chdir(oldDir)
+	}()
+
+	LoadInitFiles()
+	result, err := ParseAndEval("repl-test-rc-project")
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(2))
+}
@@ -0,0 +1,64 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements evaluation that turns panics into errors, for embedders.
+
+package golisp
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is a panic in a primitive or in the interpreter, recovered by SafeEval or
+// SafeApply.  Form is the expression that was being evaluated at the top level when it
+// happened and Stack is the Go stack of the panic.
+type PanicError struct {
+	Value interface{}
+	Form  *Data
+	Stack string
+}
+
+func (self *PanicError) Error() string {
+	if self.Form != nil {
+		return fmt.Sprintf("panic while evaluating %s: %v", String(self.Form), self.Value)
+	}
+	return fmt.Sprintf("panic: %v", self.Value)
+}
+
+// Unwrap returns the value of the panic if it was an error, e.g. a runtime.Error
+func (self *PanicError) Unwrap() error {
+	err, _ := self.Value.(error)
+	return err
+}
+
+// recoverPanic turns a panic into a PanicError in *err.  It has to be deferred directly.
+func recoverPanic(form *Data, err *error) {
+	if recovered := recover(); recovered != nil {
+		*err = &PanicError{Value: recovered, Form: form, Stack: string(debug.Stack())}
+	}
+}
+
+// SafeEval evaluates d in env like Eval, except that a panic anywhere in the evaluation is
+// returned as a *PanicError instead of taking down the host.  Panics in goroutines that the
+// evaluation starts, e.g. with fork, can't be caught here.
+func SafeEval(d *Data, env *SymbolTableFrame) (result *Data, err error) {
+	defer recoverPanic(d, &err)
+	return Eval(d, env)
+}
+
+// SafeApply applies function to the (already evaluated) args in env like ApplyWithoutEval,
+// returning any panic as a *PanicError
+func SafeApply(function *Data, args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	defer recoverPanic(Cons(function, args), &err)
+	return ApplyWithoutEval(function, args, env)
+}
+
+// SafeParseAndEvalAll parses and evaluates all the expressions in src in env, returning
+// any panic as a *PanicError
+func SafeParseAndEvalAll(src string, env *SymbolTableFrame) (result *Data, err error) {
+	defer recoverPanic(nil, &err)
+	return ParseAndEvalAllInEnvironment(src, env)
+}
@@ -0,0 +1,58 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests evaluation that turns panics into errors.
+
+package golisp
+
+import (
+	"errors"
+	. "gopkg.in/check.v1"
+	"runtime"
+)
+
+type SafeSuite struct {
+}
+
+var _ = Suite(&SafeSuite{})
+
+func (s *SafeSuite) SetUpSuite(c *C) {
+	MakePrimitiveFunction("safe-test-panic", "1", func(args *Data, env *SymbolTableFrame) (*Data, error) {
+		var frame *FrameMap
+		return frame.Get("boom:"), nil
+	})
+}
+
+func (s *SafeSuite) TestSafeEval(c *C) {
+	code, _ := Parse(`(map (lambda (x) (safe-test-panic x)) '(1 2))`)
+	_, err := SafeEval(code, Global)
+	c.Assert(err, NotNil)
+	var panicError *PanicError
+	c.Assert(errors.As(err, &panicError), Equals, true)
+	c.Assert(panicError.Form, Equals, code)
+	c.Assert(panicError.Stack, Matches, "(?s).*safe_test.go.*")
+	var runtimeError runtime.Error
+	c.Assert(errors.As(err, &runtimeError), Equals, true)
+	c.Assert(err.Error(), Matches, "panic while evaluating \\(map .*")
+}
+
+func (s *SafeSuite) TestSafeApply(c *C) {
+	f := Global.ValueOf(Intern("safe-test-panic"))
+	_, err := SafeApply(f, InternalMakeList(IntegerWithValue(1)), Global)
+	c.Assert(err, FitsTypeOf, &PanicError{})
+
+	result, err := SafeApply(Global.ValueOf(Intern("+")), InternalMakeList(IntegerWithValue(1), IntegerWithValue(2)), Global)
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(3))
+}
+
+func (s *SafeSuite) TestSafeParseAndEvalAll(c *C) {
+	_, err := SafeParseAndEvalAll(`(define safe-test-x 1) (safe-test-panic safe-test-x)`, Global)
+	c.Assert(err, FitsTypeOf, &PanicError{})
+
+	result, err := SafeParseAndEvalAll(`(+ safe-test-x 1)`, Global)
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(2))
+}
@@ -0,0 +1,42 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements tracking where lists were read from.
+
+package golisp
+
+import (
+	"fmt"
+)
+
+// SourcePosition is where a list was read from.  File is empty for lists that weren't
+// read from a file, e.g. ones typed into the repl.
+type SourcePosition struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (self *SourcePosition) String() string {
+	if self.File == "" {
+		return fmt.Sprintf("line %d", self.Line)
+	}
+	return fmt.Sprintf("%s:%d", self.File, self.Line)
+}
+
+// SourcePositionOf returns where the list d was read from, or nil if it wasn't read, e.g.
+// because it was built by a macro or a primitive
+func SourcePositionOf(d *Data) *SourcePosition {
+	if TypeOf(d) != ConsCellType || d.Value == nil {
+		return nil
+	}
+	return (*ConsCell)(d.Value).Source
+}
+
+func setSourcePosition(d *Data, position *SourcePosition) {
+	if TypeOf(d) == ConsCellType && d.Value != nil {
+		(*ConsCell)(d.Value).Source = position
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements tracing spans.
+
+package golisp
+
+import (
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Span is a named, timed piece of work, created by with-span around its body.  Spans nested
+// inside one another, including through function calls, share a TraceID and point to their
+// parent's SpanID.
+type Span struct {
+	Name       string
+	TraceID    string
+	SpanID     string
+	ParentID   string
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]interface{}
+	Error      string
+	mutex      sync.Mutex
+}
+
+// SpanExporter receives each span as it ends, e.g. to pass it on to the host's tracer
+type SpanExporter interface {
+	ExportSpan(span *Span)
+}
+
+// SpanExporterFunc lets an ordinary function be used as a SpanExporter
+type SpanExporterFunc func(span *Span)
+
+func (self SpanExporterFunc) ExportSpan(span *Span) {
+	self(span)
+}
+
+type spanExporterHolder struct {
+	exporter SpanExporter
+}
+
+// spanExporter holds a spanExporterHolder; with-span only creates spans while there is
+// an exporter to send them to
+var spanExporter atomic.Value
+
+// SetSpanExporter sends spans to exporter, or stops creating them if exporter is nil
+func SetSpanExporter(exporter SpanExporter) {
+	spanExporter.Store(spanExporterHolder{exporter})
+}
+
+func currentSpanExporter() SpanExporter {
+	holder, _ := spanExporter.Load().(spanExporterHolder)
+	return holder.exporter
+}
+
+// WithSpanContext returns an environment below env in which spans are children of the
+// host's span spanID in trace traceID, so scripts show up in the host's traces
+func WithSpanContext(env *SymbolTableFrame, traceID string, spanID string) *SymbolTableFrame {
+	spanEnv := NewSymbolTableFrameBelow(env, "span-context")
+	spanEnv.Previous = env
+	spanEnv.span = &Span{TraceID: traceID, SpanID: spanID}
+	return spanEnv
+}
+
+// currentSpan returns the innermost span that env is being evaluated in, if any
+func currentSpan(env *SymbolTableFrame) *Span {
+	for e := env; e != nil; e = e.Previous {
+		if e.span != nil {
+			return e.span
+		}
+	}
+	return nil
+}
+
+func newSpanID(bytes int) string {
+	id := make([]byte, bytes)
+	randomBytes(id)
+	return hex.EncodeToString(id)
+}
+
+func newSpan(name string, parent *Span) *Span {
+	span := &Span{Name: name, SpanID: newSpanID(8), Start: CurrentTime(), Attributes: make(map[string]interface{})}
+	if parent != nil {
+		span.TraceID = parent.TraceID
+		span.ParentID = parent.SpanID
+	} else {
+		span.TraceID = newSpanID(16)
+	}
+	return span
+}
+
+// SetAttribute records an attribute of the span, converting numbers, strings, and booleans
+// to their Go equivalents and anything else to its printed form
+func (self *Span) SetAttribute(key string, value *Data) {
+	var v interface{}
+	switch TypeOf(value) {
+	case IntegerType:
+		v = IntegerValue(value)
+	case FloatType:
+		v = float64(FloatValue(value))
+	case StringType:
+		v = StringValue(value)
+	case BooleanType:
+		v = BooleanValue(value)
+	default:
+		v = String(value)
+	}
+	self.mutex.Lock()
+	self.Attributes[key] = v
+	self.mutex.Unlock()
+}
@@ -0,0 +1,77 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests tracing spans.
+
+package golisp
+
+import (
+	. "gopkg.in/check.v1"
+	"sync"
+)
+
+type SpansSuite struct {
+	mutex sync.Mutex
+	spans []*Span
+}
+
+var _ = Suite(&SpansSuite{})
+
+func (s *SpansSuite) SetUpTest(c *C) {
+	s.spans = nil
+	SetSpanExporter(SpanExporterFunc(func(span *Span) {
+		s.mutex.Lock()
+		s.spans = append(s.spans, span)
+		s.mutex.Unlock()
+	}))
+}
+
+func (s *SpansSuite) TearDownTest(c *C) {
+	SetSpanExporter(nil)
+}
+
+func (s *SpansSuite) TestNestedSpans(c *C) {
+	_, err := ParseAndEval(`(define (span-test-inner) (with-span "inner" {device: "mouse"} (span-attribute! 'step 2) 42))`)
+	c.Assert(err, IsNil)
+	result, err := ParseAndEval(`(with-span "outer" {count: 3 ok: #t} (span-test-inner))`)
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(42))
+
+	c.Assert(s.spans, HasLen, 2)
+	inner, outer := s.spans[0], s.spans[1]
+	c.Assert(inner.Name, Equals, "inner")
+	c.Assert(outer.Name, Equals, "outer")
+	c.Assert(inner.TraceID, Equals, outer.TraceID)
+	c.Assert(inner.ParentID, Equals, outer.SpanID)
+	c.Assert(outer.ParentID, Equals, "")
+	c.Assert(inner.Attributes, DeepEquals, map[string]interface{}{"device": "mouse", "step": int64(2)})
+	c.Assert(outer.Attributes, DeepEquals, map[string]interface{}{"count": int64(3), "ok": true})
+	c.Assert(outer.End.Before(outer.Start), Equals, false)
+}
+
+func (s *SpansSuite) TestFailingSpan(c *C) {
+	_, err := ParseAndEval(`(with-span 'failing nil (error "boom"))`)
+	c.Assert(err, NotNil)
+	c.Assert(s.spans, HasLen, 1)
+	c.Assert(s.spans[0].Name, Equals, "failing")
+	c.Assert(s.spans[0].Error, Matches, "(?s).*boom.*")
+}
+
+func (s *SpansSuite) TestHostSpanContext(c *C) {
+	env := WithSpanContext(Global, "trace-from-host", "span-from-host")
+	_, err := ParseAndEvalInEnvironment(`(with-span "scripted" nil 1)`, env)
+	c.Assert(err, IsNil)
+	c.Assert(s.spans, HasLen, 1)
+	c.Assert(s.spans[0].TraceID, Equals, "trace-from-host")
+	c.Assert(s.spans[0].ParentID, Equals, "span-from-host")
+}
+
+func (s *SpansSuite) TestWithoutExporter(c *C) {
+	SetSpanExporter(nil)
+	result, err := ParseAndEval(`(with-span "untraced" nil (span-attribute! 'a 1) 5)`)
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(5))
+	c.Assert(s.spans, HasLen, 0)
+}
@@ -0,0 +1,58 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements symbol macros.
+
+package golisp
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// SymbolMacro is bound to a symbol in place of a value.  Evaluating the symbol evaluates
+// Expansion in its place, so (define-symbol-macro profile (get-slot device current-profile:))
+// lets profile be used like a variable.  Like any binding, it's shadowed by local bindings
+// of the same name.
+type SymbolMacro struct {
+	Name      string
+	Expansion *Data
+}
+
+func SymbolMacroWithValue(m *SymbolMacro) *Data {
+	return ObjectWithTypeAndValue("SymbolMacro", unsafe.Pointer(m))
+}
+
+func SymbolMacroP(d *Data) bool {
+	return ObjectP(d) && ObjectType(d) == "SymbolMacro"
+}
+
+func SymbolMacroValue(d *Data) *SymbolMacro {
+	if !SymbolMacroP(d) {
+		return nil
+	}
+	return (*SymbolMacro)(ObjectValue(d))
+}
+
+// DefineSymbolMacroImpl handles (define-symbol-macro name expansion)
+func DefineSymbolMacroImpl(args *Data, env *SymbolTableFrame) (result *Data, err error) {
+	name := Car(args)
+	if !SymbolP(name) || NakedP(name) {
+		err = ProcessTypeError(fmt.Sprintf("define-symbol-macro expected a symbol to name the macro, received %s", String(name)), env)
+		return
+	}
+	result = SymbolMacroWithValue(&SymbolMacro{Name: StringValue(name), Expansion: Cadr(args)})
+	_, err = env.BindLocallyTo(name, result)
+	return
+}
+
+// expandSymbolMacro evaluates the expansion of the symbol macro m in env
+func expandSymbolMacro(m *SymbolMacro, env *SymbolTableFrame) (result *Data, err error) {
+	result, err = Eval(m.Expansion, env)
+	if err != nil {
+		err = addErrorContext(err, fmt.Sprintf("\nExpanding symbol macro %s. ", m.Name))
+	}
+	return
+}
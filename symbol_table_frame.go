@@ -11,8 +11,11 @@ import (
 	"container/list"
 	"errors"
 	"fmt"
+	"io"
+	"sort"
 	"sync"
 	"sync/atomic"
+	"unsafe"
 )
 
 const (
@@ -29,6 +32,12 @@ type SymbolTableFrame struct {
 	Mutex        sync.RWMutex
 	CurrentCode  *list.List
 	IsRestricted bool
+	callDepth    int32
+	output       io.Writer
+	function     *Function
+	currentForm  unsafe.Pointer
+	span         *Span
+	hostValues   map[string]interface{}
 }
 
 type symbolsTable struct {
@@ -62,12 +71,109 @@ func Intern(name string) (sym *Data) {
 		internedSymbols.Mutex.RUnlock()
 		internedSymbols.Mutex.Lock()
 		lock = WRITE_LOCK
-		sym = SymbolWithName(name)
-		internedSymbols.Symbols[name] = sym
+		// another goroutine may have interned it while the lock was released
+		sym = internedSymbols.Symbols[name]
+		if sym == nil {
+			sym = SymbolWithName(name)
+			internedSymbols.Symbols[name] = sym
+		}
 	}
 	return
 }
 
+// StackOverflowError is returned when evaluation nests deeper than Config.MaxCallDepth
+type StackOverflowError struct {
+	Name  string
+	Depth int32
+}
+
+func (self *StackOverflowError) Error() string {
+	return fmt.Sprintf("Stack overflow in %s: calls nested deeper than %d.", self.Name, self.Depth)
+}
+
+func SetMaxCallDepth(depth int32) {
+	atomic.StoreInt32(&Config.MaxCallDepth, depth)
+	atomic.StoreInt32(&MaxCallDepth, defaultMaxCallDepth)
+}
+
+// callFrom records that self is being entered from caller, failing if that nests too deeply
+func (self *SymbolTableFrame) callFrom(caller *SymbolTableFrame) error {
+	self.Previous = caller
+	if caller != nil {
+		self.callDepth = caller.callDepth + 1
+	}
+	if limit := maxCallDepth(); limit > 0 && self.callDepth > limit {
+		return &StackOverflowError{Name: self.Name, Depth: limit}
+	}
+	return nil
+}
+
+// startEvaluating records that the list d is being evaluated in this frame and returns the
+// form it replaces, which finishEvaluating puts back.  Frames shared by goroutines can be
+// left showing another goroutine's form.
+func (self *SymbolTableFrame) startEvaluating(d *Data) *Data {
+	return (*Data)(atomic.SwapPointer(&self.currentForm, unsafe.Pointer(d)))
+}
+
+func (self *SymbolTableFrame) finishEvaluating(previous *Data) {
+	atomic.StorePointer(&self.currentForm, unsafe.Pointer(previous))
+}
+
+// CurrentForm returns the innermost list being evaluated in this frame, e.g. the call to
+// the function whose frame this frame is the Previous of
+func (self *SymbolTableFrame) CurrentForm() *Data {
+	return (*Data)(atomic.LoadPointer(&self.currentForm))
+}
+
+// StackFrame describes a call to a user written function: the function's name, the current
+// values of its parameters, and the form it was called from along with where that was read
+type StackFrame struct {
+	Function string
+	Args     *Data
+	Form     *Data
+	Source   *SourcePosition
+}
+
+// CallStack describes the function calls that led to env, innermost first
+func CallStack(env *SymbolTableFrame) (frames []StackFrame) {
+	frames = make([]StackFrame, 0)
+	for e := env; e != nil; e = e.Previous {
+		if e.function == nil {
+			continue
+		}
+		frame := StackFrame{Function: e.function.Name, Args: e.parameterValues()}
+		if e.Previous != nil {
+			frame.Form = e.Previous.CurrentForm()
+			frame.Source = SourcePositionOf(frame.Form)
+		}
+		frames = append(frames, frame)
+	}
+	return
+}
+
+// parameterValues returns the values of the parameters of the function this frame is a call
+// of, with any rest parameter's values spliced in as they were passed
+func (self *SymbolTableFrame) parameterValues() *Data {
+	values := make([]*Data, 0)
+	p := self.function.Params
+	for ; PairP(p) && NotNilP(p); p = Cdr(p) {
+		values = append(values, self.localValueOf(Car(p)))
+	}
+	if SymbolP(p) {
+		for cell := self.localValueOf(p); NotNilP(cell); cell = Cdr(cell) {
+			values = append(values, Car(cell))
+		}
+	}
+	return ArrayToList(values)
+}
+
+func (self *SymbolTableFrame) localValueOf(symbol *Data) *Data {
+	if binding, found := self.BindingNamed(StringValue(symbol)); found {
+		return binding.Value()
+	}
+	return nil
+}
+
 func (self *SymbolTableFrame) Depth() int {
 	if self.Previous == nil {
 		return 1
@@ -84,58 +190,133 @@ func (self *SymbolTableFrame) CurrentCodeString() string {
 	}
 }
 
-func (self *SymbolTableFrame) InternalDump(frameNumber int) {
-	fmt.Printf("Frame %d: %s\n", frameNumber, self.CurrentCodeString())
+// DumpLimits keeps environment dumps readable: at most Depth frames are shown, and values
+// printing longer than Width characters are elided.  Zero means no limit.
+type DumpLimits struct {
+	Depth int
+	Width int
+}
+
+// elide returns the printed form of value, cut short if it's longer than the width limit
+func (self DumpLimits) elide(value *Data) string {
+	printed := String(value)
+	if self.Width <= 0 || len(printed) <= self.Width {
+		return printed
+	}
+	return fmt.Sprintf("%s... (%d more characters)", printed[:self.Width], len(printed)-self.Width)
+}
+
+// dumpBindings prints the bindings, other than of primitives, sorted by name
+func (self *SymbolTableFrame) dumpBindings(limits DumpLimits) {
 	self.Mutex.RLock()
 	defer self.Mutex.RUnlock()
-	for _, b := range self.Bindings {
-		if b.Val == nil || TypeOf(b.Val) != PrimitiveType {
-			b.Dump()
+	names := make([]string, 0, len(self.Bindings))
+	for name, b := range self.Bindings {
+		if v := b.Value(); v == nil || TypeOf(v) != PrimitiveType {
+			names = append(names, name)
 		}
 	}
-	fmt.Printf("\n")
-	if self.Previous != nil {
-		self.Previous.InternalDump(frameNumber + 1)
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(OutputWriter(), "   %s => %s\n", name, limits.elide(self.Bindings[name].Value()))
 	}
 }
 
+// dumpFrames calls dump on each frame from self back through its callers, stopping after
+// limits.Depth frames
+func (self *SymbolTableFrame) dumpFrames(frameNumber int, limits DumpLimits, dump func(frame *SymbolTableFrame, frameNumber int)) {
+	for frame := self; frame != nil; frame, frameNumber = frame.Previous, frameNumber+1 {
+		if limits.Depth > 0 && frameNumber >= limits.Depth {
+			fmt.Fprintf(OutputWriter(), "... %d more frames\n", frame.Depth())
+			return
+		}
+		dump(frame, frameNumber)
+	}
+}
+
+func (self *SymbolTableFrame) InternalDump(frameNumber int) {
+	self.internalDump(frameNumber, Config.DumpLimits)
+}
+
+func (self *SymbolTableFrame) internalDump(frameNumber int, limits DumpLimits) {
+	self.dumpFrames(frameNumber, limits, func(frame *SymbolTableFrame, frameNumber int) {
+		fmt.Fprintf(OutputWriter(), "Frame %d: %s\n", frameNumber, frame.CurrentCodeString())
+		frame.dumpBindings(limits)
+		fmt.Fprintf(OutputWriter(), "\n")
+	})
+}
+
+// Dump prints the environment stack and its bindings, within Config.DumpLimits
 func (self *SymbolTableFrame) Dump() {
-	println()
-	self.InternalDump(0)
+	self.DumpWithLimits(Config.DumpLimits)
+}
+
+func (self *SymbolTableFrame) DumpWithLimits(limits DumpLimits) {
+	fmt.Fprintln(OutputWriter())
+	self.internalDump(0, limits)
 }
 
 func (self *SymbolTableFrame) DumpSingleFrame(frameNumber int) {
-	if frameNumber == 0 {
-		fmt.Printf("%s\n", self.CurrentCodeString())
-		self.Mutex.RLock()
-		defer self.Mutex.RUnlock()
-		for _, b := range self.Bindings {
-			if b.Val == nil || TypeOf(b.Val) != PrimitiveType {
-				b.Dump()
-			}
-		}
-		fmt.Printf("\n")
-	} else if self.Previous != nil {
-		self.Previous.DumpSingleFrame(frameNumber - 1)
+	if frame := self.frameNumbered(frameNumber); frame != nil {
+		fmt.Fprintf(OutputWriter(), "%s\n", frame.CurrentCodeString())
+		frame.dumpBindings(Config.DumpLimits)
+		fmt.Fprintf(OutputWriter(), "\n")
 	} else {
-		fmt.Printf("Invalid frame selected.\n")
+		fmt.Fprintf(OutputWriter(), "Invalid frame selected.\n")
 	}
 }
 
-func (self *SymbolTableFrame) InternalDumpHeaders(frameNumber int) {
-	fmt.Printf("Frame %d: %s\n", frameNumber, self.CurrentCodeString())
-	if self.Previous != nil {
-		self.Previous.InternalDumpHeaders(frameNumber + 1)
+// DumpBinding prints the whole value of name as seen from the numbered frame, for
+// expanding a value that a dump elided
+func (self *SymbolTableFrame) DumpBinding(name string, frameNumber int) {
+	frame := self.frameNumbered(frameNumber)
+	if frame == nil {
+		fmt.Fprintf(OutputWriter(), "Invalid frame selected.\n")
+		return
 	}
+	binding, found := frame.FindBindingFor(Intern(name))
+	if !found {
+		fmt.Fprintf(OutputWriter(), "%s is not bound.\n", name)
+		return
+	}
+	fmt.Fprintf(OutputWriter(), "   %s => %s\n", name, String(binding.Value()))
 }
 
+// frameNumbered returns the frame frameNumber calls back from self, or nil if there isn't one
+func (self *SymbolTableFrame) frameNumbered(frameNumber int) *SymbolTableFrame {
+	frame := self
+	for ; frame != nil && frameNumber > 0; frameNumber-- {
+		frame = frame.Previous
+	}
+	if frameNumber < 0 {
+		return nil
+	}
+	return frame
+}
+
+func (self *SymbolTableFrame) InternalDumpHeaders(frameNumber int) {
+	self.internalDumpHeaders(frameNumber, Config.DumpLimits)
+}
+
+func (self *SymbolTableFrame) internalDumpHeaders(frameNumber int, limits DumpLimits) {
+	self.dumpFrames(frameNumber, limits, func(frame *SymbolTableFrame, frameNumber int) {
+		fmt.Fprintf(OutputWriter(), "Frame %d: %s\n", frameNumber, frame.CurrentCodeString())
+	})
+}
+
+// DumpHeaders prints the code being evaluated in each frame of the environment stack,
+// within Config.DumpLimits
 func (self *SymbolTableFrame) DumpHeaders() {
-	println()
-	self.InternalDumpHeaders(0)
+	self.DumpHeadersWithLimits(Config.DumpLimits)
+}
+
+func (self *SymbolTableFrame) DumpHeadersWithLimits(limits DumpLimits) {
+	fmt.Fprintln(OutputWriter())
+	self.internalDumpHeaders(0, limits)
 }
 
 func (self *SymbolTableFrame) DumpHeader() {
-	fmt.Printf("%s\n", self.CurrentCodeString())
+	fmt.Fprintf(OutputWriter(), "%s\n", self.CurrentCodeString())
 }
 
 func NewSymbolTableFrameBelow(p *SymbolTableFrame, name string) *SymbolTableFrame {
@@ -215,41 +396,61 @@ func (self *SymbolTableFrame) FindBindingFor(symbol *Data) (binding *Binding, fo
 	}
 }
 
+// bindLocally binds symbol in this frame, checking for and creating the binding under
+// the frame's lock so concurrent definitions of the same name can't lose each other.
+func (self *SymbolTableFrame) bindLocally(symbol *Data, value *Data, protected bool) (*Data, error) {
+	name := StringValue(symbol)
+	self.Mutex.Lock()
+	binding, found := self.Bindings[name]
+	if !found {
+		if protected {
+			binding = ProtectedBindingWithSymbolAndValue(symbol, value)
+		} else {
+			binding = BindingWithSymbolAndValue(symbol, value)
+		}
+		self.Bindings[name] = binding
+	}
+	self.Mutex.Unlock()
+
+	if !found {
+		noteBindingChange(symbol, value)
+		return value, nil
+	}
+	if protected {
+		binding.protect(value)
+		return value, nil
+	}
+	return value, binding.Assign(value)
+}
+
 func (self *SymbolTableFrame) BindTo(symbol *Data, value *Data) (*Data, error) {
 	binding, found := self.FindBindingFor(symbol)
 	if found {
-		if binding.Protected {
-			return nil, fmt.Errorf("%s is a protected binding", StringValue(symbol))
+		if err := binding.Assign(value); err != nil {
+			return nil, err
 		}
-		binding.Val = value
-	} else {
-		binding = BindingWithSymbolAndValue(symbol, value)
-		self.SetBindingAt(StringValue(symbol), binding)
+		return value, nil
 	}
-	return binding.Val, nil
+	return self.bindLocally(symbol, value, false)
 }
 
 func (self *SymbolTableFrame) BindToProtected(symbol *Data, value *Data) *Data {
 	binding, found := self.FindBindingFor(symbol)
 	if found {
-		binding.Val = value
-		binding.Protected = true
-	} else {
-		binding = ProtectedBindingWithSymbolAndValue(symbol, value)
-		self.SetBindingAt(StringValue(symbol), binding)
+		binding.protect(value)
+		return value
 	}
-	return binding.Val
+	value, _ = self.bindLocally(symbol, value, true)
+	return value
 }
 
 func (self *SymbolTableFrame) SetTo(symbol *Data, value *Data) (result *Data, err error) {
 	localBinding, found := self.findBindingInLocalFrameFor(symbol)
 	if found {
-		if localBinding.Protected {
-			return nil, fmt.Errorf("%s is a protected binding", StringValue(symbol))
-		} else {
-			localBinding.Val = value
-			return value, nil
+		if err = localBinding.Assign(value); err != nil {
+			return nil, err
 		}
+		return value, nil
 	}
 
 	naked := StringValue(NakedSymbolFrom(symbol))
@@ -260,12 +461,10 @@ func (self *SymbolTableFrame) SetTo(symbol *Data, value *Data) (result *Data, er
 
 	binding, found := self.FindBindingFor(symbol)
 	if found {
-		if binding.Protected {
-			return nil, fmt.Errorf("%s is a protected binding", StringValue(symbol))
-		} else {
-			binding.Val = value
-			return value, nil
+		if err = binding.Assign(value); err != nil {
+			return nil, err
 		}
+		return value, nil
 	}
 
 	return nil, errors.New(fmt.Sprintf("%s is undefined", StringValue(symbol)))
@@ -276,26 +475,21 @@ func (self *SymbolTableFrame) findBindingInLocalFrameFor(symbol *Data) (b *Bindi
 }
 
 func (self *SymbolTableFrame) BindLocallyTo(symbol *Data, value *Data) (*Data, error) {
-	binding, found := self.findBindingInLocalFrameFor(symbol)
-	if found {
-		if binding.Protected {
-			return nil, fmt.Errorf("%s is a protected binding", StringValue(symbol))
-		}
-		binding.Val = value
-	} else {
-		binding = BindingWithSymbolAndValue(symbol, value)
-		self.SetBindingAt(StringValue(symbol), binding)
+	value, err := self.bindLocally(symbol, value, false)
+	if err != nil {
+		return nil, err
 	}
-	return binding.Val, nil
+	return value, nil
 }
 
 func (self *SymbolTableFrame) ValueOfWithFunctionSlotCheck(symbol *Data, needFunction bool) *Data {
 	localBinding, found := self.findBindingInLocalFrameFor(symbol)
 	if found {
-		if FunctionP(localBinding.Val) {
-			atomic.StoreInt32(&FunctionValue(localBinding.Val).SlotFunction, 1)
+		value := localBinding.Value()
+		if FunctionP(value) {
+			atomic.StoreInt32(&FunctionValue(value).SlotFunction, 1)
 		}
-		return localBinding.Val
+		return value
 	}
 
 	if self.HasFrame() {
@@ -315,10 +509,11 @@ func (self *SymbolTableFrame) ValueOfWithFunctionSlotCheck(symbol *Data, needFun
 
 	binding, found := self.FindBindingFor(symbol)
 	if found {
-		if FunctionP(binding.Val) {
-			atomic.StoreInt32(&FunctionValue(binding.Val).SlotFunction, 0)
+		value := binding.Value()
+		if FunctionP(value) {
+			atomic.StoreInt32(&FunctionValue(value).SlotFunction, 0)
 		}
-		return binding.Val
+		return value
 	} else {
 		return EmptyCons()
 	}
@@ -327,3 +522,89 @@ func (self *SymbolTableFrame) ValueOfWithFunctionSlotCheck(symbol *Data, needFun
 func (self *SymbolTableFrame) ValueOf(symbol *Data) *Data {
 	return self.ValueOfWithFunctionSlotCheck(symbol, false)
 }
+
+// SetHostValue attaches an opaque value from the host, such as the user a session is for or
+// a device handle, to this frame under key.  Code evaluated in or called from this frame
+// sees it via GetHostValue or (host-value 'key).  Setting a nil value removes it.
+func (self *SymbolTableFrame) SetHostValue(key string, value interface{}) {
+	self.Mutex.Lock()
+	defer self.Mutex.Unlock()
+	if value == nil {
+		delete(self.hostValues, key)
+		return
+	}
+	if self.hostValues == nil {
+		self.hostValues = make(map[string]interface{})
+	}
+	self.hostValues[key] = value
+}
+
+func (self *SymbolTableFrame) localHostValue(key string) (value interface{}, found bool) {
+	self.Mutex.RLock()
+	defer self.Mutex.RUnlock()
+	value, found = self.hostValues[key]
+	return
+}
+
+// GetHostValue returns the host value attached under key to the innermost frame in the
+// code that led to this one, whether it was set on this frame, an enclosing scope, or a
+// caller
+func (self *SymbolTableFrame) GetHostValue(key string) (value interface{}, found bool) {
+	for e := self; e != nil; {
+		if value, found = e.localHostValue(key); found {
+			return
+		}
+		if e.Previous != nil {
+			e = e.Previous
+		} else {
+			e = e.Parent
+		}
+	}
+	return nil, false
+}
+
+// Snapshot returns the values of this frame's own bindings by name, to compare with a later
+// snapshot using DiffSnapshots
+func (self *SymbolTableFrame) Snapshot() map[string]*Data {
+	self.Mutex.RLock()
+	defer self.Mutex.RUnlock()
+	snapshot := make(map[string]*Data, len(self.Bindings))
+	for name, binding := range self.Bindings {
+		snapshot[name] = binding.Value()
+	}
+	return snapshot
+}
+
+// EnvironmentDiff lists the names, sorted, that were bound, rebound to a value that isn't
+// equal?, or unbound between two snapshots
+type EnvironmentDiff struct {
+	Added   []string
+	Changed []string
+	Removed []string
+}
+
+func (self EnvironmentDiff) Empty() bool {
+	return len(self.Added) == 0 && len(self.Changed) == 0 && len(self.Removed) == 0
+}
+
+// DiffSnapshots compares two snapshots.  A name still bound to the same object is unchanged,
+// even if the object, such as NaN, isn't equal to itself.
+func DiffSnapshots(before map[string]*Data, after map[string]*Data) (diff EnvironmentDiff) {
+	diff = EnvironmentDiff{Added: make([]string, 0), Changed: make([]string, 0), Removed: make([]string, 0)}
+	for name, value := range after {
+		if previous, found := before[name]; !found {
+			diff.Added = append(diff.Added, name)
+		} else if previous != value && !IsEqual(previous, value) {
+			diff.Changed = append(diff.Changed, name)
+		}
+	}
+	for name := range before {
+		if _, found := after[name]; !found {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Changed)
+	sort.Strings(diff.Removed)
+	return
+}
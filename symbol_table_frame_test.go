@@ -8,7 +8,11 @@
 package golisp
 
 import (
+	"bytes"
+	"fmt"
 	. "gopkg.in/check.v1"
+	"strings"
+	"sync"
 )
 
 type SymbolTableFrameSuite struct {
@@ -52,3 +56,151 @@ func (s *SymbolTableFrameSuite) TestSymbolValue(c *C) {
 	c.Assert(int(TypeOf(val)), Equals, IntegerType)
 	c.Assert(IntegerValue(val), Equals, int64(42))
 }
+
+func (s *SymbolTableFrameSuite) TestProtectedBindingsCannotBeAssigned(c *C) {
+	sym := Intern("protected-test")
+	s.frame.BindToProtected(sym, IntegerWithValue(1))
+	_, err := s.frame.BindTo(sym, IntegerWithValue(2))
+	c.Assert(err, NotNil)
+	_, err = s.frame.SetTo(sym, IntegerWithValue(2))
+	c.Assert(err, NotNil)
+	c.Assert(IntegerValue(s.frame.ValueOf(sym)), Equals, int64(1))
+}
+
+func (s *SymbolTableFrameSuite) TestConcurrentBindingAndLookup(c *C) {
+	const workers = 8
+	const iterations = 200
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				name := Intern(fmt.Sprintf("concurrent-%d", i%10))
+				if _, err := s.frame.BindTo(name, IntegerWithValue(int64(i))); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := s.frame.BindLocallyTo(Intern(fmt.Sprintf("worker-%d", w)), IntegerWithValue(int64(i))); err != nil {
+					errs <- err
+					return
+				}
+				s.frame.ValueOf(name)
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		c.Assert(err, IsNil)
+	}
+	for w := 0; w < workers; w++ {
+		c.Assert(IntegerValue(s.frame.ValueOf(Intern(fmt.Sprintf("worker-%d", w)))), Equals, int64(iterations-1))
+	}
+	c.Assert(len(s.frame.Bindings), Equals, workers+10)
+}
+
+func (s *SymbolTableFrameSuite) TestConcurrentInterningGivesOneSymbol(c *C) {
+	const workers = 8
+	symbols := make([]*Data, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			symbols[w] = Intern("freshly-interned-concurrently")
+		}(w)
+	}
+	wg.Wait()
+
+	for _, sym := range symbols {
+		c.Assert(sym == symbols[0], Equals, true)
+	}
+}
+
+func (s *SymbolTableFrameSuite) TestDiffSnapshots(c *C) {
+	s.frame.BindLocallyTo(Intern("kept"), IntegerWithValue(1))
+	s.frame.BindLocallyTo(Intern("changed"), InternalMakeList(IntegerWithValue(1)))
+	s.frame.BindLocallyTo(Intern("same"), InternalMakeList(IntegerWithValue(1)))
+	before := s.frame.Snapshot()
+
+	s.frame.BindLocallyTo(Intern("changed"), InternalMakeList(IntegerWithValue(2)))
+	s.frame.BindLocallyTo(Intern("same"), InternalMakeList(IntegerWithValue(1)))
+	s.frame.BindLocallyTo(Intern("added"), IntegerWithValue(3))
+	diff := DiffSnapshots(before, s.frame.Snapshot())
+	c.Assert(diff.Added, DeepEquals, []string{"added"})
+	c.Assert(diff.Changed, DeepEquals, []string{"changed"})
+	c.Assert(diff.Removed, DeepEquals, []string{})
+	c.Assert(diff.Empty(), Equals, false)
+
+	diff = DiffSnapshots(s.frame.Snapshot(), before)
+	c.Assert(diff.Removed, DeepEquals, []string{"added"})
+	c.Assert(DiffSnapshots(before, before).Empty(), Equals, true)
+}
+
+func (s *SymbolTableFrameSuite) TestHostValues(c *C) {
+	session := NewSymbolTableFrameBelow(Global, "host-value-test")
+	session.SetHostValue("user", "alice")
+	session.SetHostValue("device", IntegerWithValue(7))
+
+	value, found := session.GetHostValue("user")
+	c.Assert(found, Equals, true)
+	c.Assert(value, Equals, "alice")
+
+	_, err := ParseAndEvalInEnvironment("(define (host-value-user) (host-value 'user))", Global)
+	c.Assert(err, IsNil)
+	result, err := ParseAndEvalInEnvironment("(host-value-user)", session)
+	c.Assert(err, IsNil)
+	c.Assert(HostValueOf(result), Equals, "alice")
+
+	result, err = ParseAndEvalInEnvironment(`(let ((x 1)) (host-value "device"))`, session)
+	c.Assert(err, IsNil)
+	c.Assert(IntegerValue(result), Equals, int64(7))
+
+	result, err = ParseAndEvalInEnvironment("(host-value 'user)", Global)
+	c.Assert(err, IsNil)
+	c.Assert(NilP(result), Equals, true)
+
+	session.SetHostValue("user", nil)
+	_, found = session.GetHostValue("user")
+	c.Assert(found, Equals, false)
+}
+
+func (s *SymbolTableFrameSuite) dumped(dump func()) string {
+	var output bytes.Buffer
+	SetOutputWriter(&output)
+	defer SetOutputWriter(nil)
+	dump()
+	return output.String()
+}
+
+func (s *SymbolTableFrameSuite) TestDumpLimits(c *C) {
+	s.frame.BindLocallyTo(Intern("small"), IntegerWithValue(1))
+	s.frame.BindLocallyTo(Intern("big"), StringWithValue(strings.Repeat("x", 50)))
+	inner := NewSymbolTableFrameBelow(s.frame, "inner")
+	inner.callFrom(s.frame)
+	innermost := NewSymbolTableFrameBelow(inner, "innermost")
+	innermost.callFrom(inner)
+
+	all := s.dumped(func() { innermost.DumpHeadersWithLimits(DumpLimits{}) })
+	c.Assert(all, Equals, "\nFrame 0: Unknown code\nFrame 1: Unknown code\nFrame 2: Unknown code\n")
+	limited := s.dumped(func() { innermost.DumpHeadersWithLimits(DumpLimits{Depth: 1}) })
+	c.Assert(limited, Equals, "\nFrame 0: Unknown code\n... 2 more frames\n")
+
+	elided := s.dumped(func() { s.frame.DumpWithLimits(DumpLimits{Width: 10}) })
+	c.Assert(elided, Equals, "\nFrame 0: Unknown code\n   big => \"xxxxxxxxx... (42 more characters)\n   small => 1\n\n")
+}
+
+func (s *SymbolTableFrameSuite) TestDumpingOneBinding(c *C) {
+	s.frame.BindLocallyTo(Intern("big"), StringWithValue(strings.Repeat("x", 300)))
+	inner := NewSymbolTableFrameBelow(s.frame, "inner")
+	inner.callFrom(s.frame)
+
+	c.Assert(s.dumped(func() { inner.DumpBinding("big", 0) }), Equals, "   big => \""+strings.Repeat("x", 300)+"\"\n")
+	c.Assert(s.dumped(func() { inner.DumpBinding("big", 1) }), Equals, "   big => \""+strings.Repeat("x", 300)+"\"\n")
+	c.Assert(s.dumped(func() { inner.DumpBinding("missing", 0) }), Equals, "missing is not bound.\n")
+	c.Assert(s.dumped(func() { inner.DumpBinding("big", 2) }), Equals, "Invalid frame selected.\n")
+}
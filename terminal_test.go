@@ -0,0 +1,68 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests the terminal output primitives.
+
+package golisp
+
+import (
+	"os"
+
+	. "gopkg.in/check.v1"
+)
+
+type TerminalSuite struct {
+	noColor    string
+	hadNoColor bool
+}
+
+var _ = Suite(&TerminalSuite{})
+
+func (s *TerminalSuite) SetUpTest(c *C) {
+	s.noColor, s.hadNoColor = os.LookupEnv("NO_COLOR")
+	os.Unsetenv("NO_COLOR")
+}
+
+func (s *TerminalSuite) TearDownTest(c *C) {
+	if s.hadNoColor {
+		os.Setenv("NO_COLOR", s.noColor)
+	}
+}
+
+func (s *TerminalSuite) TestVisibleWidth(c *C) {
+	c.Assert(visibleWidth("abc"), Equals, 3)
+	c.Assert(visibleWidth("\x1b[1;31mred\x1b[0m"), Equals, 3)
+	c.Assert(visibleWidth("héllo"), Equals, 5)
+}
+
+func (s *TerminalSuite) TestColorize(c *C) {
+	result, err := ParseAndEval(`(colorize "hi" 'red)`)
+	c.Assert(err, IsNil)
+	c.Assert(StringValue(result), Equals, "\x1b[31mhi\x1b[0m")
+
+	result, err = ParseAndEval(`(colorize "hi" "bright-green" {background: 208 bold: #t underline: #t})`)
+	c.Assert(err, IsNil)
+	c.Assert(StringValue(result), Equals, "\x1b[92;48;5;208;1;4mhi\x1b[0m")
+
+	result, err = ParseAndEval(`(colorize 42 '())`)
+	c.Assert(err, IsNil)
+	c.Assert(StringValue(result), Equals, "42")
+
+	_, err = ParseAndEval(`(colorize "hi" 'mauve)`)
+	c.Assert(err, NotNil)
+}
+
+func (s *TerminalSuite) TestNoColor(c *C) {
+	os.Setenv("NO_COLOR", "1")
+	result, err := ParseAndEval(`(colorize "hi" 'red {bold: #t})`)
+	c.Assert(err, IsNil)
+	c.Assert(StringValue(result), Equals, "hi")
+}
+
+func (s *TerminalSuite) TestColoredTable(c *C) {
+	result, err := ParseAndEval(`(format-table (list (list (colorize "ok" 'green) 1) (list "failed" 2)))`)
+	c.Assert(err, IsNil)
+	c.Assert(StringValue(result), Equals, "\x1b[32mok\x1b[0m      1\nfailed  2\n")
+}
@@ -0,0 +1,50 @@
+;;; -*- mode: Scheme -*-
+
+(context "multi-dimensional arrays"
+
+         ((define grid (make-array '(2 3) 0)))
+
+         (it "makes arrays of the given dimensions"
+             (assert-true (array? grid))
+             (assert-false (array? #(1 2 3)))
+             (assert-eq (array-dimensions grid) '(2 3))
+             (assert-eq (array-rank grid) 2)
+             (assert-eq (array-dimensions (make-array 4)) '(4))
+             (assert-eq (array-ref (make-array '(2 2)) 1 1) nil)
+             (assert-error (make-array '(2 -1)))
+             (assert-error (make-array "3"))
+             (assert-error (make-array '(4294967296 4294967296)))
+             (assert-error (make-array '(65536 65536 65536))))
+
+         (it "stores elements in row-major order"
+             (array-set! grid 0 1 'a)
+             (assert-eq (array-set! grid 1 2 'b) 'b)
+             (assert-eq (array-ref grid 0 1) 'a)
+             (assert-eq (array-ref grid 1 2) 'b)
+             (assert-eq (array->vector grid) #(0 a 0 0 0 b)))
+
+         (it "handles more than two dimensions"
+             (define cube (make-array '(2 3 4) 0))
+             (array-set! cube 1 2 3 'last)
+             (array-set! cube 0 1 0 'middle)
+             (assert-eq (vector-ref (array->vector cube) 23) 'last)
+             (assert-eq (vector-ref (array->vector cube) 4) 'middle))
+
+         (it "copies into the vector"
+             (define v (array->vector grid))
+             (vector-set! v 0 'changed)
+             (assert-eq (array-ref grid 0 0) 0))
+
+         (it "checks indices"
+             (assert-error (array-ref grid 2 0))
+             (assert-error (array-ref grid 0 3))
+             (assert-error (array-ref grid 0))
+             (assert-error (array-ref grid 0 0 0))
+             (assert-error (array-ref grid 'a 0))
+             (assert-error (array-set! grid 0 -1 1))
+             (assert-error (array-ref #(1 2) 0)))
+
+         (it "compares by contents"
+             (assert-true (equal? (make-array '(2 2) 1) (make-array '(2 2) 1)))
+             (assert-false (equal? (make-array '(2 2) 1) (make-array '(4) 1)))
+             (assert-eq (format #f "~A" (make-array '(2 3 4))) "<array 2x3x4>")))
@@ -0,0 +1,63 @@
+;;; -*- mode: Scheme -*-
+
+(context "bignum"
+
+         ()
+
+         (it "promotes on overflow"
+             (assert-eq (+ 9223372036854775807 1) 9223372036854775808)
+             (assert-eq (- -9223372036854775808 1) -9223372036854775809)
+             (assert-eq (* 4294967296 4294967296) 18446744073709551616)
+             (assert-eq (* -1 -9223372036854775808) 9223372036854775808)
+             (assert-eq (succ 9223372036854775807) 9223372036854775808)
+             (assert-true (bignum? (+ 9223372036854775807 1)))
+             (assert-false (bignum? 9223372036854775807)))
+
+         (it "raises to large powers"
+             (assert-eq (expt 2 100) 1267650600228229401496703205376)
+             (assert-eq (pow 3 40) 12157665459056928801)
+             (assert-eq (expt 2 10) 1024)
+             (assert-eq (expt (expt 2 64) 0) 1))
+
+         (it "demotes results that fit"
+             (assert-eq (- (expt 2 64) (expt 2 64) -5) 5)
+             (assert-false (bignum? (quotient (expt 2 70) (expt 2 68))))
+             (assert-eq (quotient (expt 2 70) (expt 2 68)) 4)
+             (assert-error (quotient (expt 2 70) 0)))
+
+         (it "reads and prints large literals"
+             (assert-eq (str 123456789012345678901234567890) "123456789012345678901234567890")
+             (assert-eq #xFFFFFFFFFFFFFFFF 18446744073709551615)
+             (assert-eq (number->string (expt 2 70) 16) "400000000000000000")
+             (assert-eq (string->number "123456789012345678901234567890") 123456789012345678901234567890))
+
+         (it "compares"
+             (assert-true (< 1 (expt 2 70) (expt 2 71)))
+             (assert-true (= (expt 2 70) (* (expt 2 35) (expt 2 35))))
+             (assert-true (equal? (expt 2 70) (expt 2 70)))
+             (assert-false (equal? (expt 2 70) (expt 2 71)))
+             (assert-eq (max 1 (expt 2 70)) (expt 2 70))
+             (assert-eq (min (expt 2 70) -3) -3))
+
+         (it "works with other integer functions"
+             (assert-eq (abs (- 0 (expt 2 70))) (expt 2 70))
+             (assert-true (even? (expt 2 70)))
+             (assert-true (odd? (+ (expt 2 70) 1)))
+             (assert-eq (integer (expt 2 70)) (expt 2 70))
+             (assert-true (number? (expt 2 70))))
+
+         (it "promotes in gcd, lcm, negation, and abs"
+             (assert-eq (lcm 9223372036854775807 2) 18446744073709551614)
+             (assert-eq (gcd (expt 2 100) (expt 6 50)) (expt 2 50))
+             (assert-eq (lcm (expt 2 70) 3) (* 3 (expt 2 70)))
+             (assert-eq (gcd -12 18) 6)
+             (assert-eq (- -9223372036854775808) 9223372036854775808)
+             (assert-eq (- (expt 2 70)) (- 0 (expt 2 70)))
+             (assert-eq (- 5) -5)
+             (assert-eq (abs -16777217) 16777217)
+             (assert-eq (abs -9223372036854775808) 9223372036854775808)
+             (assert-error (gcd 1.5 2)))
+
+         (it "is eqv? by value"
+             (assert-true (eqv? (expt 2 100) (expt 2 100)))
+             (assert-false (eqv? (expt 2 100) (expt 2 101)))))
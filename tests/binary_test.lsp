@@ -71,4 +71,60 @@
              (assert-error (right-shift '(a b) 2))
              (assert-error (right-shift 2 'a))
              (assert-error (right-shift 2 '(a b))))
+         (it "can xor"
+             (assert-eq (binary-xor 0x0a 0x0f)
+                        0x05)
+             (assert-eq (binary-xor 0xff 0xff)
+                        0x00)
+             (assert-error (binary-xor 'a 2)))
+
+         (it "can negate a given width"
+             (assert-eq (binary-not 0x0a 8)
+                        0xf5)
+             (assert-eq (binary-not 0 64)
+                        0xffffffffffffffff)
+             (assert-error (binary-not 1 65))
+             (assert-error (binary-not 1 0)))
+
+         (it "handles unsigned 64 bit values"
+             (assert-eq 0xffffffffffffffff
+                        18446744073709551615)
+             (assert-true (> 0xffffffffffffffff 0))
+             (assert-eq (number->string 0xfedcba9876543210 16)
+                        "fedcba9876543210")
+             (assert-eq (binary-and 0xfedcba9876543210 0xffff000000000000)
+                        0xfedc000000000000)
+             (assert-eq (binary-or 0x8000000000000000 1)
+                        0x8000000000000001)
+             (assert-eq (binary-xor 0xffffffffffffffff 0x0f)
+                        0xfffffffffffffff0)
+             (assert-eq (right-shift 0x8000000000000000 60)
+                        8)
+             (assert-eq (left-shift 1 63)
+                        0x8000000000000000)
+             (assert-eq (left-shift 0xff 60)
+                        0xf000000000000000)
+             (assert-error (binary-and 0x10000000000000000 1)))
+
+         (it "keeps negative values signed"
+             (assert-eq (binary-or -8 1)
+                        -7)
+             (assert-eq (binary-and -1 0xff)
+                        0xff))
+
+         (it "converts between signed and unsigned"
+             (assert-true (uint64? 0xffffffffffffffff))
+             (assert-true (uint64? 5))
+             (assert-false (uint64? -1))
+             (assert-false (uint64? 0x10000000000000000))
+             (assert-eq (int64->uint64 -1)
+                        0xffffffffffffffff)
+             (assert-eq (int64->uint64 5)
+                        5)
+             (assert-eq (uint64->int64 0xffffffffffffffff)
+                        -1)
+             (assert-eq (uint64->int64 (int64->uint64 -12345))
+                        -12345)
+             (assert-error (uint64->int64 -1))
+             (assert-error (int64->uint64 0xffffffffffffffff)))
 )
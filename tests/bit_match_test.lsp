@@ -0,0 +1,68 @@
+;;; -*- mode: Scheme -*-
+
+(context "bit-match"
+
+         ()
+
+         (it fields
+             (assert-eq (bit-match [1 2 3 4] ((a 8) (b 16) (c 8)) (list a b c))
+                        '(1 0x0203 4))
+             (assert-eq (bit-match [0xab] ((high 4) (low 4)) (list high low))
+                        '(0x0a 0x0b))
+             (assert-eq (bit-match [0xb5] ((flag 1) (kind 3) (_ 4)) (list flag kind))
+                        '(1 3))
+             (assert-eq (bit-match #u8(1 2) ((a 8) (b 8)) (list a b))
+                        '(1 2)))
+
+         (it sizes-from-earlier-fields
+             (assert-eq (bit-match [0x7e 0 2 0xca 0xfe 9]
+                                   ((0x7e 8) (len 16) (payload (* len 8) binary) (checksum 8))
+                                   (list payload checksum))
+                        '([0xca 0xfe] 9)))
+
+         (it rest-of-the-bytes
+             (assert-eq (bit-match [3 1 2 3 4] ((kind 8) (body binary)) (list kind body))
+                        '(3 [1 2 3 4]))
+             (assert-eq (bit-match [3] ((kind 8) (body binary)) body)
+                        []))
+
+         (it endianness-and-sign
+             (assert-eq (bit-match [0x34 0x12] ((n 16 little)) n)
+                        0x1234)
+             (assert-eq (bit-match [0xff 0xfe] ((n 16 signed)) n)
+                        -2)
+             (assert-eq (bit-match [0xfe 0xff] ((n 16 little signed)) n)
+                        -2)
+             (assert-eq (bit-match [0xf0] ((n 4 signed) (_ 4)) n)
+                        -1))
+
+         (it sixty-four-bit-fields
+             (assert-eq (bit-match [0xff 0xff 0xff 0xff 0xff 0xff 0xff 0xfe] ((serial 64)) serial)
+                        0xfffffffffffffffe)
+             (assert-eq (bit-match [0xff 0xff 0xff 0xff 0xff 0xff 0xff 0xfe] ((n 64 signed)) n)
+                        -2))
+
+         (it mismatches
+             (assert-false (bit-match [1 2] ((a 8)) a))
+             (assert-false (bit-match [1] ((a 16)) a))
+             (assert-false (bit-match [0x7f 1] ((0x7e 8) (a 8)) a))
+             (assert-eq (bit-match [0xff 0xff 0xff 0xff 0xff 0xff 0xff 0xff 1] ((0xffffffffffffffff 64) (a 8)) a) 1)
+             (assert-false (bit-match [0 5 1] ((len 8) (payload (* len 8) binary)) payload)))
+
+         (it huge-sizes-do-not-match
+             (assert-false (bit-match [1 2 3] ((a 8) (b 9223372036854775800 binary)) b))
+             (assert-false (bit-match [1 2 3] ((a 8) (b 9223372036854775807)) b)))
+
+         (it body-is-only-evaluated-on-a-match
+             (define evaluated #f)
+             (bit-match [1 2] ((a 8)) (set! evaluated #t))
+             (assert-false evaluated))
+
+         (it errors
+             (assert-error (bit-match '(1 2) ((a 16)) a))
+             (assert-error (bit-match [1] ((a 8 sideways)) a))
+             (assert-error (bit-match [1 2] ((a binary) (b 8)) a))
+             (assert-error (bit-match [1] ((a 4 little) (b 4)) a))
+             (assert-error (bit-match [1] ((a 4 binary) (b 4)) a))
+             (assert-error (bit-match [1 2 3 4 5 6 7 8 9] ((a 72)) a))
+             (assert-error (bit-match [1] (("a" 8)) 1))))
@@ -0,0 +1,74 @@
+;;; -*- mode: Scheme -*-
+
+(context "bytevectors"
+
+         ()
+
+         (it "are a type of their own"
+             (assert-true (bytevector? #u8(1 2 3)))
+             (assert-true (u8vector? (bytevector 1 2)))
+             (assert-false (bytevector? [1 2 3]))
+             (assert-false (bytearray? #u8(1 2 3)))
+             (assert-false (bytevector? #(1 2 3)))
+             (assert-false (equal? (bytevector 1 2 3) [1 2 3]))
+             (assert-eq (bytevector 1 2 3) #u8(1 2 3)))
+
+         (it "print as literals"
+             (assert-eq (str #u8(1 2 255)) "#u8(1 2 255)")
+             (assert-eq (str (bytevector)) "#u8()"))
+
+         (it "can be hash table keys"
+             (let ((h (make-hash-table)))
+               (hash-set! h (bytevector 1 2) 'found)
+               (assert-eq (hash-ref h #u8(1 2) #f) 'found)
+               (assert-false (hash-ref h [1 2] #f))))
+
+         (it "can be made"
+             (assert-eq (make-bytevector 3 7) #u8(7 7 7))
+             (assert-eq (make-bytevector 2) #u8(0 0))
+             (assert-eq (make-u8vector 1 255) #u8(255))
+             (assert-error (make-bytevector 2 256))
+             (assert-error (make-bytevector 9223372036854775807))
+             (assert-error (bytevector 1 -1)))
+
+         (it "can be accessed"
+             (let ((bv (bytevector 1 2 3)))
+               (assert-eq (bytevector-length bv) 3)
+               (assert-eq (bytevector-u8-ref bv 1) 2)
+               (bytevector-u8-set! bv 1 42)
+               (assert-eq (u8vector-ref bv 1) 42)
+               (assert-error (bytevector-u8-ref bv 3))
+               (assert-error (bytevector-u8-set! bv 0 300))))
+
+         (it "can be copied"
+             (let ((bv (bytevector 1 2 3 4 5)))
+               (assert-eq (bytevector-copy bv) #u8(1 2 3 4 5))
+               (assert-eq (bytevector-copy bv 2) #u8(3 4 5))
+               (assert-eq (bytevector-copy bv 1 3) #u8(2 3))
+               (assert-error (bytevector-copy bv 3 2))
+               (bytevector-copy! bv 1 #u8(9 8 7) 1)
+               (assert-eq bv #u8(1 8 7 4 5))
+               (assert-error (bytevector-copy! bv 4 #u8(9 8 7)))))
+
+         (it "can be appended"
+             (assert-eq (bytevector-append #u8(1) #u8() #u8(2 3)) #u8(1 2 3))
+             (assert-eq (bytevector-append) #u8()))
+
+         (it "convert to and from lists and vectors"
+             (assert-eq (bytevector->list #u8(1 2 3)) '(1 2 3))
+             (assert-eq (bytevector->list #u8(1 2 3) 1) '(2 3))
+             (assert-eq (list->bytevector '(1 2 3)) #u8(1 2 3))
+             (assert-eq (bytevector->vector #u8(1 2 3) 0 2) #(1 2))
+             (assert-eq (vector->bytevector #(1 2 3)) #u8(1 2 3))
+             (assert-error (list->bytevector '(1 a))))
+
+         (it "convert to and from bytearrays"
+             (assert-eq (bytevector->bytearray #u8(1 2 3)) [1 2 3])
+             (assert-eq (bytearray->bytevector [1 2 3]) #u8(1 2 3))
+             (assert-eq (length #u8(1 2 3)) 3)
+             (assert-error (bytevector->bytearray [1 2 3]))
+             (assert-error (bytearray->bytevector #u8(1 2 3))))
+
+         (it "convert to and from utf8"
+             (assert-eq (string->utf8 "hé") #u8(104 195 169))
+             (assert-eq (utf8->string #u8(104 195 169)) "hé")))
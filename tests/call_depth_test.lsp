@@ -0,0 +1,44 @@
+;;; -*- mode: Scheme -*-
+
+(define (deep n)
+  (if (== n 0)
+      0
+      (+ 1 (deep (- n 1)))))
+
+(define (deep-let n)
+  (let ((m (- n 1)))
+    (if (< m 0)
+        0
+        (+ 1 (deep-let m)))))
+
+(context "call depth"
+
+         ()
+
+         (it "allows recursion within the limit"
+             (assert-eq (deep 1000) 1000)
+             (assert-eq (deep-let 1000) 1000))
+
+         (it "raises a catchable error beyond the limit"
+             (let ((limit (max-call-depth)))
+               (max-call-depth 100)
+               (assert-error (deep 200))
+               (assert-eq (on-error (deep 200) (lambda (e) (string-prefix? "Stack overflow in deep" e))) #t)
+               (assert-eq (deep 50) 50)
+               (max-call-depth limit)))
+
+         (it "counts let scopes"
+             (let ((limit (max-call-depth)))
+               (max-call-depth 100)
+               (assert-error (deep-let 60))
+               (max-call-depth limit)))
+
+         (it "can be disabled"
+             (let ((limit (max-call-depth)))
+               (max-call-depth 0)
+               (assert-eq (deep 20000) 20000)
+               (max-call-depth limit)))
+
+         (it "requires a non-negative integer"
+             (assert-error (max-call-depth -1))
+             (assert-error (max-call-depth "a"))))
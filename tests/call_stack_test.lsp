@@ -0,0 +1,33 @@
+;;; -*- mode: Scheme -*-
+
+(define (stack-inner x)
+  (current-stack))
+
+(define (stack-outer a . rest)
+  (stack-inner (+ a 1)))
+
+(define (stack-frame-inner)
+  (stack-frame-ref 0))
+
+(context "call stack"
+
+         ()
+
+         (it "describes the calls that led to current-stack"
+             (let* ((stack (stack-outer 1 2 3))
+                    (inner (vector-ref stack 0))
+                    (outer (vector-ref stack 1)))
+               (assert-eq (name: inner) "stack-inner")
+               (assert-eq (args: inner) '(2))
+               (assert-eq (form: inner) '(stack-inner (+ a 1)))
+               (assert-eq (line: inner) 7)
+               (assert-true (string? (file: inner)))
+               (assert-eq (name: outer) "stack-outer")
+               (assert-eq (args: outer) '(1 2 3))
+               (assert-eq (form: outer) '(stack-outer 1 2 3))))
+
+         (it "returns a single frame"
+             (assert-eq (name: (stack-frame-inner)) "stack-frame-inner")
+             (assert-error (stack-frame-ref 100000))
+             (assert-error (stack-frame-ref -1))
+             (assert-error (stack-frame-ref 'a))))
@@ -76,5 +76,15 @@
                    (assert-eq (multi-test-func 8)
                               "some")
                    (assert-eq (multi-test-func 9)
-                              "many")))
+                              "many"))
 
+         (it "passes the key to the receiver in a => clause"
+             (assert-eq (case 5
+                          ((1 2 3) => (lambda (x) (* x 2)))
+                          ((4 5 6) => (lambda (x) (* x 10)))
+                          (else 0))
+                        50)
+             (assert-eq (case 'z
+                          ((a b) 1)
+                          (else => list))
+                        '(z))))
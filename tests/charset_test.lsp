@@ -0,0 +1,58 @@
+;;; -*- mode: Scheme -*-
+
+(context "char-set"
+
+         ()
+
+         (it "can be made from characters and strings"
+             (let ((vowels (char-set #\a "eiou")))
+               (assert-true (char-set? vowels))
+               (assert-true (char-set-contains? vowels #\a))
+               (assert-true (char-set-contains? vowels #\o))
+               (assert-false (char-set-contains? vowels #\b)))
+             (assert-true (char-set-contains? (string->char-set "xyz") #\y))
+             (assert-false (char-set? "abc"))
+             (assert-error (char-set 1)))
+
+         (it "has predefined sets"
+             (assert-true (char-set-contains? char-set:digit #\7))
+             (assert-false (char-set-contains? char-set:digit #\a))
+             (assert-true (char-set-contains? char-set:whitespace #\space))
+             (assert-true (char-set-contains? char-set:letter+digit #\Q))
+             (assert-true (char-set-contains? char-set:hex-digit #\f))
+             (assert-false (char-set-contains? char-set:hex-digit #\g))
+             (assert-eq (str char-set:letter) "<char-set:letter>"))
+
+         (it "can be combined"
+             (let ((alnum (char-set-union char-set:letter char-set:digit))
+                   (not-digit (char-set-complement char-set:digit))
+                   (consonant (char-set-difference char-set:lower-case (char-set "aeiou")))
+                   (hex-letter (char-set-intersection char-set:letter char-set:hex-digit)))
+               (assert-true (char-set-contains? alnum #\z))
+               (assert-true (char-set-contains? alnum #\3))
+               (assert-false (char-set-contains? alnum #\-))
+               (assert-false (char-set-contains? not-digit #\3))
+               (assert-true (char-set-contains? consonant #\b))
+               (assert-false (char-set-contains? consonant #\e))
+               (assert-true (char-set-contains? hex-letter #\c))
+               (assert-false (char-set-contains? hex-letter #\9)))
+             (assert-error (char-set-union char-set:letter "a")))
+
+         (it "can be used to scan strings"
+             (assert-eq (string-index "port10" char-set:digit) 4)
+             (assert-eq (string-index "port10" #\t) 3)
+             (assert-eq (string-index "a b c" char-set:whitespace 2) 3)
+             (assert-eq (string-index "abc" char-set:digit) #f)
+             (assert-eq (string-index "abc" (lambda (c) (eqv? c #\c))) 2)
+             (assert-eq (string-index-right "a1b2c" char-set:digit) 3)
+             (assert-eq (string-index-right "abc" char-set:digit) #f)
+             (assert-eq (string-skip "   key" char-set:whitespace) 3)
+             (assert-eq (string-skip "   " char-set:whitespace) #f)
+             (assert-error (string-index "abc" 1))
+             (assert-error (string-index "abc" #\a 10)))
+
+         (it "can be used to trim strings"
+             (assert-eq (string-trim "--==key==--" (char-set "-=")) "key")
+             (assert-eq (string-trim-left "0042" (char-set #\0)) "42")
+             (assert-eq (string-trim-right "key;;  " (char-set-union char-set:whitespace (char-set #\;))) "key")
+             (assert-eq (string-trim "xxkeyxx" "x") "key")))
@@ -0,0 +1,67 @@
+;;; -*- mode: Scheme -*-
+
+(define (make-job n hosts options)
+  (lambda (attempt)
+    (list n hosts (get-slot options retries:) attempt)))
+
+(define (make-adder k)
+  (named-lambda (add x . more)
+    (apply + k x more)))
+
+(define global-offset 100)
+
+(context "serialize-closure"
+
+         ()
+
+         (it captures-local-bindings
+             (assert-eq (serialize-closure (make-adder 5))
+                        "(let ((k 5)) (named-lambda (add x . more) (apply + k x more)))")
+             (assert-eq (serialize-closure (lambda (x) (+ x global-offset)))
+                        "(let () (lambda (x) (+ x global-offset)))"))
+
+         (it writes-data-that-has-to-be-rebuilt
+             (assert-eq (serialize-closure (let ((options {tag: 'x}) (items (list {a: 1} 'b)) (v (vector {}))) (lambda () (list options items v))))
+                        "(let ((items (list (make-frame a: 1) 'b)) (options (make-frame tag: 'x)) (v (vector (make-frame)))) (lambda () (list options items v)))"))
+
+         (it round-trips
+             (define job (deserialize-closure (serialize-closure (make-job 3 '("a" b) {retries: 2}))))
+             (assert-eq (job 7) '(3 ("a" b) 2 7))
+             (assert-eq ((deserialize-closure (serialize-closure (make-adder 5))) 1 2 3) 11))
+
+         (it captures-other-closures
+             (define doubler (let ((factor 2)) (lambda (x) (* x factor))))
+             (define job (let ((f doubler)) (lambda (y) (f y))))
+             (assert-eq ((deserialize-closure (serialize-closure job)) 21) 42))
+
+         (it looks-up-globals-when-called
+             (define f (deserialize-closure (serialize-closure (lambda (x) (+ x global-offset)))))
+             (assert-eq (f 1) 101)
+             (set! global-offset 200)
+             (assert-eq (f 1) 201))
+
+         (it rejects-what-it-cannot-write
+             (assert-error (serialize-closure car))
+             (assert-error (serialize-closure (let ((e (the-environment))) (lambda () e))))
+             (assert-error (letrec ((loop (lambda (n) (if (= n 0) 0 (loop (- n 1)))))) (serialize-closure loop)))))
+
+(context "deserialize-closure"
+
+         ()
+
+         (it does-not-evaluate-values
+             (assert-error (deserialize-closure "(let ((x (launch-missiles))) (lambda () x))"))
+             (assert-error (deserialize-closure "(let ((x y)) (lambda () x))"))
+             (assert-eq ((deserialize-closure "(let ((x (cons 1 (list 2 'b)))) (lambda () x))")) '(1 2 b)))
+
+         (it only-reads-closures
+             (assert-error (deserialize-closure "(+ 1 2)"))
+             (assert-error (deserialize-closure "(let () (+ 1 2))"))
+             (assert-error (deserialize-closure "(let () (lambda (1) 1))"))
+             (assert-error (deserialize-closure "(let (("))
+             (assert-error (deserialize-closure 'x)))
+
+         (it uses-the-given-environment
+             (define env (make-top-level-environment '(offset) '(7)))
+             (assert-eq ((deserialize-closure "(let () (lambda (x) (+ x offset)))" env) 1) 8)
+             (assert-error (deserialize-closure "(let () (lambda () 1))" 5))))
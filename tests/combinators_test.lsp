@@ -0,0 +1,59 @@
+;;; -*- mode: Scheme -*-
+
+(define config-lexer (make-lexer `(("[0-9]+" number ,string->number)
+                                   ("[a-z]+" word)
+                                   ("[=;()]" punct)
+                                   ("[ \n]+" #f))))
+
+(define setting (p-map (p-seq (p-token 'word) (p-token "=") (p-alt (p-token 'number) (p-token 'word)))
+                       (lambda (parts) (cons (car parts) (caddr parts)))))
+
+(define config (p-many (p-map (p-seq setting (p-token ";")) car)))
+
+(define item (p-lazy (lambda () (p-alt (p-token 'number) nested))))
+(define nested (p-map (p-seq (p-token "(") (p-many item) (p-token ")")) cadr))
+
+(define left-recursive (p-lazy (lambda () (p-alt (p-seq left-recursive (p-token "+") (p-token 'number))
+                                                  (p-token 'number)))))
+
+(define (parse-config text)
+  (p-parse config (lex config-lexer text)))
+
+(context "parser combinators"
+
+         ()
+
+         (it "parses sequences and alternatives"
+             (assert-true (parser? setting))
+             (assert-false (parser? config-lexer))
+             (assert-eq (parse-config "port = 10; mode = fast;")
+                        '(("port" . 10) ("mode" . "fast")))
+             (assert-eq (parse-config "") '()))
+
+         (it "parses optional parts"
+             (let ((assigned (p-seq (p-optional (p-token "=") "none") (p-token 'number))))
+               (assert-eq (p-parse assigned (lex config-lexer "= 7")) '("=" 7))
+               (assert-eq (p-parse assigned (lex config-lexer "7")) '("none" 7))
+               (assert-eq (p-parse (p-token 'x) '(x)) 'x)))
+
+         (it "requires at least one match with p-many1"
+             (assert-eq (p-parse (p-many1 (p-token 'a)) '(a a)) '(a a))
+             (assert-error (p-parse (p-many1 (p-token 'a)) '())))
+
+         (it "parses recursive grammars"
+             (assert-eq (p-parse item (lex config-lexer "(1 (2 3) ())"))
+                        '(1 (2 3) ())))
+
+         (it "parses vectors of tokens"
+             (assert-eq (p-parse (p-seq (p-token 'a) (p-token 'b)) #(a b)) '(a b)))
+
+         (it "reports where parsing failed"
+             (assert-error (parse-config "port = ;"))
+             (assert-error (parse-config "port = 10"))
+             (assert-error (p-parse (p-token 'a) '(a b)))
+             (assert-error (p-seq (p-token 'a) 'b))
+             (assert-error (p-token 1)))
+
+         (it "fails on left recursion and runaway nesting"
+             (assert-error (p-parse left-recursive (lex config-lexer "1")))
+             (assert-error (p-parse item (vector->list (make-vector 20001 "(")))))))
@@ -31,4 +31,19 @@
              (assert-eq (cond (#f 1 2 3)
                               (#f 4 5 6)
                               (else 7 8 9))
-                        9)))
+                        9))
+
+         (it "passes the test value to the receiver in a => clause"
+             (assert-eq (cond ((assq 'b '((a 1) (b 2))) => cadr)
+                              (else #f))
+                        2)
+             (assert-eq (cond (#f => car)
+                              ((+ 1 2) => (lambda (x) (* x 10))))
+                        30))
+
+         (it "errors when the receiver isn't a function"
+             (assert-error (cond (1 => 5))))
+
+         (it "results in the test value for a clause with no body"
+             (assert-eq (cond (#f) ((+ 1 1)) (else 5))
+                        2)))
@@ -0,0 +1,34 @@
+;;; -*- mode: Scheme -*-
+
+(context "config formats"
+
+         ()
+
+         (it "reads TOML into frames"
+             (let ((config (read-toml "name = \"K1\"\n[usb]\nports = [1, 2]\nspeed = 1.5\n")))
+               (assert-eq (name: config) "K1")
+               (assert-eq (ports: (usb: config)) '(1 2))
+               (assert-eq (speed: (usb: config)) 1.5)))
+
+         (it "reads INI into frames"
+             (let ((config (read-ini "top = 1\n[device]\nname = K1\n")))
+               (assert-eq (top: config) "1")
+               (assert-eq (name: (device: config)) "K1")))
+
+         (it "reads YAML into frames"
+             (let ((config (yaml->lisp "name: K1\nports:\n  - 1\n  - 2\nusb: {speed: 1.5}\n")))
+               (assert-eq (name: config) "K1")
+               (assert-eq (ports: config) '(1 2))
+               (assert-eq (speed: (usb: config)) 1.5)))
+
+         (it "writes YAML"
+             (assert-eq (lisp->yaml {name: "K1" ports: '(1 2)})
+                        "name: K1\nports:\n  - 1\n  - 2\n")
+             (assert-eq (ports: (yaml->lisp (lisp->yaml {ports: '(1 2)})))
+                        '(1 2)))
+
+         (it "rejects bad input"
+             (assert-error (read-toml "a = "))
+             (assert-error (read-ini "[open"))
+             (assert-error (read-toml 42))
+             (assert-error (yaml->lisp "a: [1"))))
@@ -0,0 +1,47 @@
+;;; -*- mode: Scheme -*-
+
+(context "decimal"
+
+         ()
+
+         (it "converts numbers and strings"
+             (assert-true (decimal? (decimal 1)))
+             (assert-false (decimal? 1.5))
+             (assert-eq (decimal->string (decimal "12.50")) "12.50")
+             (assert-eq (decimal->string (decimal 0.1)) "0.1")
+             (assert-eq (decimal->string (decimal 7)) "7")
+             (assert-eq (str (decimal "3.14")) "3.14")
+             (assert-error (decimal "abc"))
+             (assert-error (decimal 'a)))
+
+         (it "rounds to a scale"
+             (assert-eq (decimal->string (decimal "2.345" 2)) "2.34")
+             (assert-eq (decimal->string (decimal "2.345" 2 'half-up)) "2.35")
+             (assert-eq (decimal->string (decimal-round "2.341" 2 ceiling:)) "2.35")
+             (assert-eq (decimal->string (decimal-round 2 2)) "2.00")
+             (assert-eq (decimal-scale (decimal "1.500")) 3)
+             (assert-error (decimal-round 1 -1))
+             (assert-error (decimal-round "1.5" 100000000))
+             (assert-error (decimal/ 1 3 100000000))
+             (assert-error (decimal-round 1 2 'sideways)))
+
+         (it "does exact arithmetic"
+             (assert-eq (decimal->string (decimal+ "0.1" "0.2")) "0.3")
+             (assert-eq (decimal->string (decimal+)) "0")
+             (assert-eq (decimal->string (decimal- "1.00" "0.01")) "0.99")
+             (assert-eq (decimal->string (decimal- "1.5")) "-1.5")
+             (assert-eq (decimal->string (decimal* "19.99" 3)) "59.97")
+             (assert-eq (decimal->string (decimal/ 10 3 2)) "3.33")
+             (assert-eq (decimal->string (decimal/ 2 3 2 'down)) "0.66")
+             (assert-error (decimal/ 1 0 2)))
+
+         (it "compares"
+             (assert-eq (decimal-compare "1.0" "1.00") 0)
+             (assert-eq (decimal-compare 1 "1.01") -1)
+             (assert-eq (decimal-compare "2" 1) 1)
+             (assert-true (equal? (decimal "1.0") (decimal "1.00")))
+             (assert-false (equal? (decimal "1.0") (decimal "1.01")))
+             (assert-eq (equal-hash (decimal "1.0")) (equal-hash (decimal "1.00"))))
+
+         (it "converts to float"
+             (assert-eq (decimal->float (decimal "1.5")) 1.5)))
@@ -14,6 +14,25 @@
              (assert-eq (environment-parent new-env)
                         (system-global-environment)))
 
+         (it "lets you create an anonymous environment"
+             (define anon-env (make-top-level-environment))
+             (assert-true (environment? anon-env))
+             (assert-eq (environment-parent anon-env)
+                        (system-global-environment)))
+
+         (it "lets you evaluate code in an environment"
+             (define sandbox (make-top-level-environment '(x) '(10)))
+             (assert-eq (eval 'x sandbox) 10)
+             (eval '(define sandboxed-y (* x 2)) sandbox)
+             (assert-eq (eval 'sandboxed-y sandbox) 20)
+             (assert-false (environment-bound? (system-global-environment) 'sandboxed-y))
+             (assert-error (eval 'x 5)))
+
+         (it "lets you capture the current environment"
+             (define workspace (make-top-level-environment))
+             (define captured (eval '(the-environment) workspace))
+             (assert-eq captured workspace))
+
          (it "lets you create and query bindings"
              (environment-define (system-global-environment) 'test-name 42)
              (assert-true (environment-bound? (system-global-environment) 'test-name))
@@ -60,4 +79,18 @@
              (assert-error (make-top-level-environment '(a b) 5)) ;not a list of binding values
              (assert-error (make-top-level-environment '(a b) '(1 2 3))) ;different length names & values
              (assert-error (make-top-level-environment '(3 4) '(1 2))) ;not symbol binding names
-             (assert-error (procedure-environment +)))) ;not a user defined function
+             (assert-error (procedure-environment +))) ;not a user defined function
+
+         (it "lets you diff environments"
+             (define diffed (make-top-level-environment '(a b c) '(1 2 3)))
+             (define before (environment-bindings diffed))
+             (eval '(begin (define d 4) (set! b 20) (set! c 3)) diffed)
+             (let ((diff (environment-diff before diffed)))
+               (assert-eq (added: diff) '(d))
+               (assert-eq (changed: diff) '(b))
+               (assert-eq (removed: diff) '()))
+             (assert-eq (removed: (environment-diff diffed (make-top-level-environment '(a) '(1))))
+                        '(b c d))
+             (assert-eq (changed: (environment-diff (system-global-environment) (system-global-environment))) '())
+             (assert-error (environment-diff diffed 5))
+             (assert-error (environment-diff '(5) diffed))))
@@ -12,3 +12,91 @@
              (assert-false (eq? 42 "42"))
              (assert-false (eq? (alist '((a.1))) (alist '((a.1) (b.2)))))
              (assert-false (eq? '(1 2) '(1 2 3)))))
+
+(context "eqv"
+
+         ()
+
+         (it "compares atoms by value"
+             (assert-true (eqv? 1 1))
+             (assert-true (eqv? 'a 'a))
+             (assert-true (eqv? #\a #\a))
+             (assert-true (eqv? '() '()))
+             (assert-false (eqv? 1 1.0))
+             (assert-false (eqv? #\a #\b)))
+
+         (it "compares structure by identity"
+             (define l '(1 2))
+             (define v #(1 2))
+             (assert-true (eqv? l l))
+             (assert-true (eqv? v v))
+             (assert-false (eqv? (list 1 2) (list 1 2)))
+             (assert-false (eqv? (vector 1 2) (vector 1 2)))
+             (assert-false (eqv? "abc" (str "ab" "c")))))
+
+(context "equal"
+
+         ()
+
+         (it "compares numbers by value"
+             (assert-true (equal? 1 1.0))
+             (assert-true (equal? '(1 2.0) '(1.0 2)))
+             (assert-false (equal? 1 2)))
+
+         (it "compares large integers and floats exactly"
+             (assert-false (equal? 9007199254740993 9007199254740992.0))
+             (assert-true (equal? 9007199254740992 9007199254740992.0))
+             (assert-true (equal? 36893488147419103232 36893488147419103232.0))
+             (assert-false (= 9007199254740993 9007199254740992.0))
+             (assert-true (> 9007199254740993 9007199254740992.0)))
+
+         (it "compares structure recursively"
+             (assert-true (equal? (list 1 (list 2 "three")) '(1 (2 "three"))))
+             (assert-true (equal? (vector 1 #(2)) #(1 #(2))))
+             (assert-true (equal? {a: 1 b: '(2)} {b: '(2) a: 1.0}))
+             (assert-true (equal? [1 2 3] (list->bytearray '(1 2 3))))
+             (assert-true (equal? "abc" (str "ab" "c")))
+             (assert-false (equal? '(1 2) '(1 2 3)))
+             (assert-false (equal? #(1 2) '(1 2)))
+             (assert-false (equal? {a: 1} {a: 1 b: 2})))
+
+         (it "handles circular structure"
+             (define a (list 1 2))
+             (define b (list 1 2))
+             (set-cdr! (cdr a) a)
+             (set-cdr! (cdr b) b)
+             (assert-true (equal? a b))))
+
+(context "equal-hash"
+
+         ()
+
+         (it "hashes equal data the same"
+             (assert-eq (equal-hash '(1 "two" #(3))) (equal-hash (list 1 "two" (vector 3))))
+             (assert-eq (equal-hash 2) (equal-hash 2.0))
+             (assert-eq (equal-hash 9007199254740992) (equal-hash 9007199254740992.0))
+             (assert-eq (equal-hash 36893488147419103232) (equal-hash 36893488147419103232.0))
+             (assert-eq (equal-hash -9223372036854775808) (equal-hash -9223372036854775808.0))
+             (assert-eq (equal-hash {a: 1 b: 2}) (equal-hash {b: 2 a: 1}))
+             (assert-false (eq? (equal-hash "abc") (equal-hash "abd"))))
+
+         (it "terminates on circular data"
+             (define a (list 1 2))
+             (set-cdr! (cdr a) a)
+             (assert-true (integer? (equal-hash a)))))
+
+(define point-proto {equal?: (lambda (other) (and (frame? other) (== x (get-slot other x:))))
+                     hash: (lambda () (equal-hash x))})
+
+(context "custom equality"
+
+         ()
+
+         (it "uses equal?: and hash: slots on frames"
+             (define p1 {proto*: point-proto x: 1 label: "first"})
+             (define p2 {proto*: point-proto x: 1 label: "second"})
+             (define p3 {proto*: point-proto x: 2 label: "first"})
+             (assert-true (equal? p1 p2))
+             (assert-false (equal? p1 p3))
+             (assert-eq (equal-hash p1) (equal-hash p2))
+             (assert-eq (equal-hash p1) (equal-hash 1))))
@@ -0,0 +1,62 @@
+;;; -*- mode: Scheme -*-
+
+(define (error-category-of thunk)
+  (on-error (thunk)
+            (lambda (message e) (error-category e))
+            (lambda () 'none)))
+
+(context "error categories"
+
+         ()
+
+         (it "categorizes type errors"
+             (assert-eq (error-category-of (lambda () (string-length 1))) 'type-error))
+
+         (it "categorizes arity errors"
+             (assert-eq (error-category-of (lambda () (car 1 2))) 'arity-error)
+             (assert-eq (error-category-of (lambda () ((lambda (x) x)))) 'arity-error))
+
+         (it "categorizes index errors"
+             (assert-eq (error-category-of (lambda () (vector-ref (vector 1 2) 5))) 'index-error))
+
+         (it "categorizes io errors"
+             (assert-eq (error-category-of (lambda () (load "no/such/file.lsp"))) 'io-error))
+
+         (it "categorizes user errors"
+             (assert-eq (error-category-of (lambda () (error "oops"))) 'user-error))
+
+         (it "keeps the category through nested calls"
+             (define (inner) (string-length 1))
+             (define (outer) (+ 1 (inner)))
+             (assert-eq (error-category-of outer) 'type-error))
+
+         (it "uses error for everything else"
+             (assert-eq (error-category-of (lambda () (heap-pop! (make-heap <)))) 'error)))
+
+(context "error objects"
+
+         ()
+
+         (it "are passed to handlers that accept them"
+             (on-error (error "oops")
+                       (lambda (message e)
+                         (assert-true (error-object? e))
+                         (assert-true (user-error? e))
+                         (assert-false (type-error? e))
+                         (assert-eq (error-message e) message))))
+
+         (it "are not passed to one argument handlers"
+             (assert-true (on-error (error "oops") (lambda (message) (string? message)))))
+
+         (it "have predicates for each category"
+             (on-error (car 1 2)
+                       (lambda (message e)
+                         (assert-true (arity-error? e))
+                         (assert-false (index-error? e))
+                         (assert-false (io-error? e))
+                         (assert-false (parse-error? e)))))
+
+         (it "require an error object"
+             (assert-false (error-object? "oops"))
+             (assert-false (type-error? "oops"))
+             (assert-error (error-message "oops"))))
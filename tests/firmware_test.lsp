@@ -0,0 +1,66 @@
+;;; -*- mode: Scheme -*-
+
+(define sample-hex ":020000040800F2
+:0400000001020304F2
+:0400040005060708DE
+:02001000AABB89
+:0400000508000004EB
+:00000001FF
+")
+
+(context "firmware"
+
+         ()
+
+         (it parsing-intel-hex
+             (define image (parse-intel-hex sample-hex))
+             (assert-true (firmware? image))
+             (assert-eq (firmware-size image) 10)
+             (assert-eq (firmware-ranges image) '((0x08000000 0x08000008) (0x08000010 0x08000012)))
+             (assert-eq (firmware-start-address image) 0x08000004)
+             (assert-error (parse-intel-hex ":0400000001020304F3\n:00000001FF"))
+             (assert-error (parse-intel-hex 42)))
+
+         (it records
+             (define records (intel-hex-records sample-hex))
+             (assert-eq (length records) 6)
+             (assert-eq (map (lambda (r) (get-slot r type:)) records) '(4 0 0 0 5 1))
+             (assert-eq (get-slot (cadr records) data:) [1 2 3 4])
+             (assert-eq (get-slot (caddr records) address:) 4)
+             (assert-eq (get-slot (caddr records) line:) 3))
+
+         (it bytes
+             (define image (parse-intel-hex sample-hex))
+             (assert-eq (firmware-bytes image 0x08000006 0x08000012)
+                        [7 8 0xff 0xff 0xff 0xff 0xff 0xff 0xff 0xff 0xaa 0xbb])
+             (assert-eq (firmware-bytes image 0x0800000e 0x08000011 0)
+                        [0 0 0xaa])
+             (assert-eq (firmware->binary (binary->firmware [1 2 3] 0x100)) [1 2 3])
+             (assert-eq (length (bytearray->list (firmware->binary image))) 18)
+             (assert-error (firmware-bytes image 10 5))
+             (assert-error (firmware-bytes image 0 4 256)))
+
+         (it raw-binaries
+             (define image (binary->firmware [1 2 3 4 5 6 7] 6))
+             (assert-eq (firmware-ranges image) '((6 13)))
+             (assert-false (firmware-start-address image))
+             (assert-eq (firmware-ranges (binary->firmware [1])) '((0 1)))
+             (assert-error (binary->firmware '(1 2)))
+             (assert-error (binary->firmware [1] -1)))
+
+         (it chunks
+             (assert-eq (firmware-chunks (binary->firmware [1 2 3 4 5 6 7] 6) 4)
+                        '((6 [1 2]) (8 [3 4 5 6]) (12 [7])))
+             (assert-eq (map car (firmware-chunks (parse-intel-hex sample-hex) 4))
+                        '(0x08000000 0x08000004 0x08000010))
+             (assert-error (firmware-chunks (binary->firmware [1]) 0)))
+
+         (it crcs
+             (assert-eq (crc32 [1 2 3 4 5 6 7 8]) 0x3fca88c5)
+             (assert-eq (crc16 (string->utf8 "123456789")) 0x29b1)
+             (assert-eq (crc32 (parse-intel-hex sample-hex)) 0x4fcf7632)
+             (assert-error (crc32 "abc"))
+             (define sparse (parse-intel-hex ":020000040800F2\n:0100000001FE\n:020000041FFFDC\n:0100000003FC\n:00000001FF\n"))
+             (assert-eq (firmware-size sparse) 2)
+             (assert-error (crc32 sparse))
+             (assert-error (firmware->binary sparse))))
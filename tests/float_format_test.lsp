@@ -0,0 +1,38 @@
+;;; -*- mode: Scheme -*-
+
+(context "float formatting"
+
+         ()
+
+         (it "prints the shortest float by default"
+             (assert-false (float-precision))
+             (assert-eq (float-notation) 'general)
+             (assert-false (float-trim-zeros))
+             (assert-eq (number->string 2.5) "2.5")
+             (assert-eq (number->string 2.0) "2.0"))
+
+         (it "formats floats with number->string options"
+             (assert-eq (number->string 3.14159 {precision: 2 notation: 'fixed}) "3.14")
+             (assert-eq (number->string 2.5 {precision: 3 notation: 'fixed}) "2.500")
+             (assert-eq (number->string 2.5 {precision: 3 notation: 'fixed trim-zeros: #t}) "2.5")
+             (assert-eq (number->string 1234.5 {precision: 1 notation: 'scientific}) "1.2e+03")
+             (assert-eq (number->string 2.5 10) "2.5"))
+
+         (it "controls how floats print"
+             (float-precision 2)
+             (float-notation 'fixed)
+             (assert-eq (str 1.5) "1.50")
+             (float-trim-zeros #t)
+             (assert-eq (str 1.5) "1.5")
+             (assert-eq (float-precision) 2)
+             (float-precision #f)
+             (float-notation 'general)
+             (float-trim-zeros #f)
+             (assert-eq (str 1.5) "1.5"))
+
+         (it "rejects bad settings"
+             (assert-error (float-precision -1))
+             (assert-error (float-precision 'a))
+             (assert-error (float-notation 'engineering))
+             (assert-error (number->string 2.5 16))
+             (assert-error (number->string 2.5 {precision: -2}))))
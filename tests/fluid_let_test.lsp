@@ -0,0 +1,38 @@
+;;; -*- mode: Scheme -*-
+
+(define fluid-x 1)
+(define (get-fluid-x) fluid-x)
+
+(context "fluid-let"
+
+         ()
+
+         (it "rebinds variables for the extent of its body"
+             (assert-eq (fluid-let ((fluid-x 2))
+                          (get-fluid-x))
+                        2)
+             (assert-eq fluid-x 1))
+
+         (it "evaluates the new values before rebinding"
+             (assert-eq (fluid-let ((fluid-x (+ fluid-x 10)))
+                          fluid-x)
+                        11))
+
+         (it "restores the old values when the body fails"
+             (assert-error (fluid-let ((fluid-x 3))
+                             (error "boom")))
+             (assert-eq fluid-x 1))
+
+         (it "restores the old values after the body assigns them"
+             (fluid-let ((fluid-x 4))
+               (set! fluid-x 5))
+             (assert-eq fluid-x 1))
+
+         (it "rebinds local variables"
+             (assert-eq (let ((y 1))
+                          (let ((f (lambda () y)))
+                            (list (fluid-let ((y 2)) (f)) (f))))
+                        '(2 1)))
+
+         (it "requires the variables to be bound"
+             (assert-error (fluid-let ((fluid-unbound-variable 1)) 1))))
@@ -0,0 +1,34 @@
+;;; -*- mode: Scheme -*-
+
+(context "query strings"
+
+         ()
+
+         (it "encodes frames"
+             (assert-eq (frame->query-string {name: "K1 Pro" id: 42 tags: '("a" "b&c")})
+                        "id=42&name=K1+Pro&tags=a&tags=b%26c")
+             (assert-eq (frame->query-string {}) ""))
+
+         (it "parses query strings"
+             (let ((query (parse-query-string "?name=K1+Pro&tags=a&tags=b%26c")))
+               (assert-eq (name: query) "K1 Pro")
+               (assert-eq (tags: query) '("a" "b&c"))))
+
+         (it "round trips"
+             (assert-eq (id: (parse-query-string (frame->query-string {id: "7"}))) "7"))
+
+         (it "rejects bad input"
+             (assert-error (frame->query-string "a=1"))
+             (assert-error (parse-query-string "a=%zz"))))
+
+(context "multipart forms"
+
+         ()
+
+         (it "makes a content type and body"
+             (let ((form (make-multipart-form {name: "K1" firmware: {filename: "k1.bin" content: [1 2 3]}})))
+               (assert-true (string-prefix? "multipart/form-data; boundary=" (content-type: form)))
+               (assert-true (bytearray? (body: form)))))
+
+         (it "rejects bad file content"
+             (assert-error (make-multipart-form {firmware: {content: 42}}))))
@@ -0,0 +1,77 @@
+;;; -*- mode: Scheme -*-
+
+(context "format"
+
+         ()
+
+         (it aesthetic-and-standard
+             (assert-eq (format #f "~a ~s" "x" "x") "x \"x\"")
+             (assert-eq (format #f "~5a|~5@a|" 1 2) "1    |    2|")
+             (assert-eq (format #f "~5,,,'.a|" 1) "1....|")
+             (assert-eq (format #f "~,,2a|" 1) "1  |"))
+
+         (it integers
+             (assert-eq (format #f "~d" 42) "42")
+             (assert-eq (format #f "~5d|~5,'0d" 42 -7) "   42|000-7")
+             (assert-eq (format #f "~@d ~@d" 5 -5) "+5 -5")
+             (assert-eq (format #f "~:d" 1234567) "1,234,567")
+             (assert-eq (format #f "~,,'.,4:d" 1234567) "123.4567")
+             (assert-eq (format #f "~d" (expt 2 70)) "1180591620717411303424")
+             (assert-eq (format #f "~x ~b ~o" 255 5 8) "FF 101 10")
+             (assert-eq (format #f "~4,'0x" 10) "000A")
+             (assert-eq (format #f "~d" "x") "x"))
+
+         (it floats
+             (assert-eq (format #f "~,2f" 3.14159) "3.14")
+             (assert-eq (format #f "~8,3f|" 2.5) "   2.500|")
+             (assert-eq (format #f "~f" 1.5) "1.5")
+             (assert-eq (format #f "~f" 100) "100.0")
+             (assert-eq (format #f "~,0f" 3.7) "4.")
+             (assert-eq (format #f "~,1@f" 2) "+2.0")
+             (assert-eq (format #f "~,1,2f" 0.5) "50.0")
+             (assert-eq (format #f "~4f" 3.14159) "3.14")
+             (assert-eq (format #f "~3,,,'*f" 12345.5) "***"))
+
+         (it parameters-from-arguments
+             (assert-eq (format #f "~v,2f|" 6 1.5) "  1.50|")
+             (assert-eq (format #f "~#a|~a~a" 1 2 3) "1  |23"))
+
+         (it newlines-and-tildes
+             (assert-eq (format #f "a~%b~2%c") "a\nb\n\nc")
+             (assert-eq (format #f "~~ ~3~") "~ ~~~")
+             (assert-eq (format #f "x~
+                                   y") "xy")
+             (assert-eq (format #f "x~@
+                                   y") "x\ny"))
+
+         (it iteration
+             (assert-eq (format #f "~{~a~^, ~}" '(1 2 3)) "1, 2, 3")
+             (assert-eq (format #f "~{~a~^, ~}" '()) "")
+             (assert-eq (format #f "~{~a=~a~^ ~}" '(a 1 b 2)) "a=1 b=2")
+             (assert-eq (format #f "~:{~a=~a~%~}" '((a 1) (b 2))) "a=1\nb=2\n")
+             (assert-eq (format #f "~@{~a~^-~}" 1 2 3) "1-2-3")
+             (assert-eq (format #f "~2{~a~}" '(1 2 3)) "12")
+             (assert-eq (format #f "~{x~^~a~:}" '()) "x")
+             (assert-eq (format #f "~{~{~a~}/~}" '((1 2) (3))) "12/3/"))
+
+         (it escaping-the-control-string
+             (assert-eq (format #f "~a~^ and ~a" 1) "1")
+             (assert-eq (format #f "~a~^ and ~a" 1 2) "1 and 2"))
+
+         (it output
+             (assert-eq (with-output-to-string (format #t "~{~a~}~%" '(1 2))) "12\n")
+             (assert-nil (format #t "")))
+
+         (it errors
+             (assert-error (format #f "~a ~a" 1))
+             (assert-error (format #f "~a" 1 2))
+             (assert-error (format #f "~{~a" '(1)))
+             (assert-error (format #f "~a~}" 1))
+             (assert-error (format #f "~{~}" '(1)))
+             (assert-error (format #f "~{~a~}" 1))
+             (assert-error (format #f "~q" 1))
+             (assert-error (format #f "~100000000d" 1))
+             (assert-error (format #f "~10,100000000f" 1.5))
+             (assert-error (format #f "~v%" 100000000))
+             (assert-error (format #f "~"))
+             (assert-error (format "x" "~a" 1))))
@@ -71,8 +71,8 @@
                (assert-error (apply-slot f foo: 2 3))) ;doesn't end in a list
 
              (assert-error (send '(1 2) foo:)) ;1st arg must be a frame
-             (assert-error (send {a: 1} 'a)) ;selector must be a naked symbol
-             (assert-error (send {a: 1} 1)) ;selector must be a naked symbol
+             (assert-error (send {a: 1} 'a)) ;slot value must be a function
+             (assert-error (send {a: 1} 1)) ;selector must be a symbol
              (assert-error (send {a: 1} b:)) ;selector must be a key in the frame
              (assert-error (send {a: 1} a:))) ;slot value must be a function
 
@@ -96,7 +96,19 @@
                     (incrementor {parent*: adder
                                            a: 1}))
                (assert-eq (send incrementor add: 3)
-                          4)))
+                          4)
+               (assert-eq (send incrementor 'add 3)
+                          4))
+             (let* ((counter {bump: (lambda ()
+                                      (set-slot! self count: (+ 1 count))
+                                      self)})
+                    (c {parent*: counter
+                                 count: 0}))
+               (assert-eq (send c 'bump)
+                          c)
+               (assert-eq (get-slot c count:)
+                          1)
+               (assert-false (has-slot? counter count:))))
 
          (it new-slots
              (let ((f {a: 1}))
@@ -309,3 +321,67 @@
              (assert-error ("x:" f))
              (assert-error ("x:!" f 1))
              (assert-error ("foo>:" f))))
+
+(context "frame utilities"
+
+         ()
+
+         (it "gets values along a path"
+             (define config {device: {port: {speed: 9600}} name: "kbd"})
+             (assert-eq (frame-get-in config '(device: port: speed:)) 9600)
+             (assert-eq (frame-get-in config '(name:)) "kbd")
+             (assert-nil (frame-get-in config '(device: missing: speed:)))
+             (assert-eq (frame-get-in config '(name: length:) 0) 0)
+             (assert-error (frame-get-in config '()))
+             (assert-error (frame-get-in config '(device port))))
+
+         (it "sets values along a path"
+             (define config {device: {port: {speed: 9600}}})
+             (frame-set-in! config '(device: port: speed:) 19200)
+             (assert-eq (frame-get-in config '(device: port: speed:)) 19200)
+             (frame-set-in! config '(display: brightness:) 50)
+             (assert-eq (frame-get-in config '(display: brightness:)) 50)
+             (assert-error (frame-set-in! config '(display: brightness: level:) 1)))
+
+         (it "merges frames"
+             (define a {x: 1 nested: {p: 1 q: 2}})
+             (define b {y: 2 nested: {q: 3}})
+             (assert-eq (frame-merge a b) {x: 1 y: 2 nested: {q: 3}})
+             (assert-eq (frame-deep-merge a b) {x: 1 y: 2 nested: {p: 1 q: 3}})
+             (assert-eq a {x: 1 nested: {p: 1 q: 2}})
+             (assert-eq (frame-merge) {})
+             (assert-error (frame-merge a 1)))
+
+         (it "maps over slots"
+             (assert-eq (frame-map (lambda (k v) (* v 2)) {a: 1 b: 2}) {a: 2 b: 4})
+             (assert-eq (frame-map (lambda (k v) k) {a: 1}) {a: a:})))
+
+(context "slot change handlers"
+
+         ()
+
+         (it "are called when the slot is set"
+             (define changes '())
+             (define f {level: 1 other: 0})
+             (define id (on-slot-change! f 'level (lambda (frame slot old new)
+                                                   (set! changes (cons (list slot old new) changes)))))
+             (set-slot! f level: 2)
+             (level:! f 3)
+             (set-slot! f other: 9)
+             (assert-eq changes '((level: 2 3) (level: 1 2)))
+             (assert-true (remove-slot-change! f id))
+             (set-slot! f level: 4)
+             (assert-eq (length changes) 2)
+             (assert-false (remove-slot-change! f id)))
+
+         (it "accept naked slot names"
+             (define seen 0)
+             (define f {a: 1})
+             (on-slot-change! f a: (lambda (frame slot old new) (set! seen new)))
+             (set-slot! f a: 5)
+             (assert-eq seen 5))
+
+         (it "report errors"
+             (assert-error (on-slot-change! 1 'a (lambda (f s o n) ())))
+             (assert-error (on-slot-change! {a: 1} 1 (lambda (f s o n) ())))
+             (assert-error (on-slot-change! {a: 1} 'a 1))))
@@ -0,0 +1,57 @@
+;;; -*- mode: Scheme -*-
+
+(context "hash tables"
+
+         ((define table (make-hash-table))
+          (hash-set! table 1 'one)
+          (hash-set! table "two" 2)
+          (hash-set! table '(3 4) 'list))
+
+         (it "makes hash tables"
+             (assert-true (hash-table? table))
+             (assert-false (hash-table? '((1 . 2))))
+             (assert-eq (hash-count (make-hash-table)) 0)
+             (assert-eq (hash-ref (make-hash-table '((a . 1) (b . 2))) 'b) 2)
+             (assert-error (make-hash-table '(1 2))))
+
+         (it "looks keys up with equal?"
+             (assert-eq (hash-ref table 1) 'one)
+             (assert-eq (hash-ref table 1.0) 'one)
+             (assert-eq (hash-ref table (str "t" "wo")) 2)
+             (assert-eq (hash-ref table (list 3 4)) 'list)
+             (assert-nil (hash-ref table 'missing))
+             (assert-eq (hash-ref table 'missing 'default) 'default)
+             (assert-true (hash-has-key? table "two"))
+             (assert-false (hash-has-key? table "three")))
+
+         (it "replaces values"
+             (assert-eq (hash-set! table 1 'uno) 'uno)
+             (assert-eq (hash-ref table 1) 'uno)
+             (assert-eq (hash-count table) 3))
+
+         (it "removes entries"
+             (assert-true (hash-remove! table "two"))
+             (assert-false (hash-remove! table "two"))
+             (assert-eq (hash-count table) 2)
+             (assert-false (hash-has-key? table "two"))
+             (hash-clear! table)
+             (assert-eq (hash-count table) 0))
+
+         (it "lists keys, values and entries"
+             (define numbers (make-hash-table))
+             (for-each (lambda (n) (hash-set! numbers n (* n n))) '(1 2 3))
+             (assert-eq (sort (hash-keys numbers) <) '(1 2 3))
+             (assert-eq (sort (hash-values numbers) <) '(1 4 9))
+             (assert-eq (sort (hash-map numbers +) <) '(2 6 12))
+             (assert-eq (sort (hash->alist numbers) (lambda (a b) (< (car a) (car b))))
+                        '((1 . 1) (2 . 4) (3 . 9))))
+
+         (it "scales to many entries"
+             (define big (make-hash-table))
+             (for-each (lambda (n) (hash-set! big (list n) n)) (interval 1 5000))
+             (assert-eq (hash-count big) 5000)
+             (assert-eq (hash-ref big (list 4321)) 4321))
+
+         (it "rejects things that aren't hash tables"
+             (assert-error (hash-ref '((1 . 2)) 1))
+             (assert-error (hash-map table 1))))
@@ -0,0 +1,80 @@
+;;; -*- mode: Scheme -*-
+
+(context "heap"
+
+         ()
+
+         (it "pops in priority order"
+             (define h (make-heap <))
+             (heap-push! h 5)
+             (heap-push! h 1)
+             (heap-push! h 3)
+             (assert-eq (heap-size h) 3)
+             (assert-eq (heap-peek h) 1)
+             (assert-eq (heap-pop! h) 1)
+             (assert-eq (heap-pop! h) 3)
+             (assert-eq (heap-pop! h) 5)
+             (assert-true (heap-empty? h)))
+
+         (it "can be built from a list"
+             (define h (make-heap > '(2 9 4 7)))
+             (assert-true (heap? h))
+             (assert-eq (heap->list h) '(9 7 4 2))
+             (assert-eq (heap-size h) 4)
+             (assert-eq (heap-pop! h) 9))
+
+         (it "uses lisp comparators"
+             (define h (make-heap (lambda (a b) (< (cdr a) (cdr b)))))
+             (heap-push! h '(low . 10))
+             (heap-push! h '(high . 1))
+             (assert-eq (car (heap-pop! h)) 'high))
+
+         (it "lets comparators look at the heap"
+             (define sizes '())
+             (define h (make-heap (lambda (a b)
+                                    (set! sizes (cons (heap-size h) sizes))
+                                    (< a b))))
+             (heap-push! h 3)
+             (heap-push! h 1)
+             (heap-push! h 2)
+             (assert-eq (heap-peek h) 1)
+             (assert-false (nil? sizes))
+             (assert-eq (heap-pop! h) 1)
+             (assert-eq (heap->list h) '(2 3)))
+
+         (it "sorts many items"
+             (define h (make-heap <))
+             (for-each (lambda (n) (heap-push! h (modulo (* n 7919) 1000))) (interval 1 1000))
+             (define popped (map (lambda (n) (heap-pop! h)) (interval 1 1000)))
+             (assert-eq popped (sort popped <))
+             (assert-true (heap-empty? h)))
+
+         (it "stays in order when comparators change the heap"
+             (define push-zero #f)
+             (define h (make-heap (lambda (a b)
+                                    (when push-zero
+                                      (set! push-zero #f)
+                                      (heap-push! h 0))
+                                    (< a b))
+                                  '(5 3 8)))
+             (set! push-zero #t)
+             (heap-push! h 4)
+             (heap-push! h 1)
+             (assert-eq (map (lambda (n) (heap-pop! h)) (interval 1 6)) '(0 1 3 4 5 8)))
+
+         (it "can hold the empty list"
+             (define h (make-heap (lambda (a b) (< (length a) (length b)))))
+             (heap-push! h '(1))
+             (heap-push! h '())
+             (assert-eq (heap-peek h) '())
+             (assert-eq (heap-pop! h) '())
+             (assert-eq (heap-pop! h) '(1)))
+
+         (it "reports errors"
+             (assert-error (make-heap 1))
+             (assert-error (heap-pop! (make-heap <)))
+             (assert-error (heap-peek (make-heap <)))
+             (assert-error (heap-push! '(1 2) 3))
+             (define h (make-heap < '(1)))
+             (assert-error (heap-push! h "a"))
+             (assert-eq (heap->list h) '(1))))
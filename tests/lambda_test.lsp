@@ -45,6 +45,17 @@
                    (assert-error (named-lambda x (+ 1 2)))
                    (assert-error (named-lambda ("h" x) (+ 1 2))))
 
+         (it shorthand
+                   (assert-eq (map #[(+ % 1)] '(1 2 3))
+                              '(2 3 4))
+                   (assert-eq (#[(list %1 %2 %&)] 1 2 3 4)
+                              '(1 2 (3 4)))
+                   (assert-eq (#[(- %2 %1)] 1 5)
+                              4)
+                   (assert-eq (#[(vector-ref #(%) 0)])
+                              '%)
+                   (assert-error (#[(+ %2 1)] 1)))
+
          (it function
                    (assert-eq (bar 0)
                               0)
@@ -0,0 +1,82 @@
+;;; -*- mode: Scheme -*-
+
+(define (integers-from n)
+  (lazy-seq (cons n (integers-from (+ n 1)))))
+
+(define calls 0)
+
+(define (count-square x)
+  (set! calls (+ calls 1))
+  (* x x))
+
+(context "lazy-seq"
+
+         ()
+
+         (it "is not realized until used"
+             (let ((s (lazy-seq (list 1 2 3))))
+               (assert-true (lazy-seq? s))
+               (assert-false (realized? s))
+               (assert-eq (doall s) '(1 2 3))
+               (assert-true (realized? s))))
+
+         (it "can be infinite"
+             (assert-eq (take 5 (integers-from 1)) '(1 2 3 4 5)))
+
+         (it "can be empty"
+             (assert-eq (doall (lazy-seq nil)) '())
+             (assert-eq (take 3 (lazy-seq '())) '()))
+
+         (it "can be chained"
+             (assert-eq (doall (lazy-seq (lazy-seq '(1 2)))) '(1 2)))
+
+         (it "can't produce a non-list"
+             (assert-error (doall (lazy-seq 42))))
+
+         (it "isn't a list"
+             (assert-false (lazy-seq? '(1 2)))
+             (assert-error (realized? '(1 2)))))
+
+(context "lazy-map"
+
+         ()
+
+         (it "maps lazily"
+             (assert-eq (take 3 (lazy-map (lambda (x) (* x 10)) (integers-from 1))) '(10 20 30))
+             (assert-eq (doall (lazy-map (lambda (x) (+ x 1)) '(1 2 3))) '(2 3 4)))
+
+         (it "computes each element only once"
+             (set! calls 0)
+             (let ((squares (lazy-map count-square '(1 2 3 4))))
+               (assert-eq calls 0)
+               (assert-eq (take 2 squares) '(1 4))
+               (assert-eq calls 2)
+               (assert-eq (doall squares) '(1 4 9 16))
+               (assert-eq calls 4)
+               (assert-eq (doall squares) '(1 4 9 16))
+               (assert-eq calls 4)))
+
+         (it "requires a function and a sequence"
+             (assert-error (lazy-map 1 '(1 2)))
+             (assert-error (lazy-map car 5))))
+
+(context "lazy-filter"
+
+         ()
+
+         (it "filters lazily"
+             (assert-eq (take 4 (lazy-filter even? (integers-from 1))) '(2 4 6 8))
+             (assert-eq (doall (lazy-filter odd? '(1 2 3 4 5))) '(1 3 5)))
+
+         (it "composes with lazy-map"
+             (assert-eq (take 3 (lazy-map (lambda (x) (* x x)) (lazy-filter odd? (integers-from 1)))) '(1 9 25))))
+
+(context "doall"
+
+         ()
+
+         (it "returns lists unchanged"
+             (assert-eq (doall '(1 2 3)) '(1 2 3)))
+
+         (it "requires a sequence"
+             (assert-error (doall 5))))
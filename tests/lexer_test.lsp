@@ -0,0 +1,43 @@
+;;; -*- mode: Scheme -*-
+
+(define log-lexer (make-lexer `(("[0-9]+" number ,string->number)
+                                ("[a-zA-Z_][a-zA-Z0-9_]*" word)
+                                ("=" equals)
+                                ("[ \t]+" #f)
+                                ("\n" newline))))
+
+(define (token-slots slot tokens)
+  (map (lambda (token) (get-slot token slot)) tokens))
+
+(context "lexer"
+
+         ()
+
+         (it "splits text into tokens"
+             (assert-true (lexer? log-lexer))
+             (assert-false (lexer? "x"))
+             (assert-eq (token-slots 'type: (lex log-lexer "port = 10"))
+                        '(word equals number))
+             (assert-eq (token-slots 'text: (lex log-lexer "port = 10"))
+                        '("port" "=" "10"))
+             (assert-eq (lex log-lexer "") '()))
+
+         (it "converts token values"
+             (assert-eq (token-slots 'value: (lex log-lexer "port = 10"))
+                        '("port" "=" 10)))
+
+         (it "tracks where tokens are"
+             (let ((tokens (lex log-lexer "a = 1\n  b = 22")))
+               (assert-eq (token-slots 'line: tokens) '(1 1 1 1 2 2 2))
+               (assert-eq (token-slots 'column: tokens) '(1 3 5 6 3 5 7))
+               (assert-eq (token-slots 'offset: tokens) '(0 2 4 5 8 10 12))))
+
+         (it "prefers the longest match"
+             (let ((lexer (make-lexer '(("if" keyword) ("[a-z]+" word) (" " #f)))))
+               (assert-eq (token-slots 'type: (lex lexer "if iffy")) '(keyword word))))
+
+         (it "reports text it can't lex"
+             (assert-error (lex log-lexer "port = #10"))
+             (assert-error (make-lexer '(("[0-9" number))))
+             (assert-error (make-lexer '(("[0-9]" "number"))))
+             (assert-error (lex "not a lexer" "x"))))
@@ -3,6 +3,22 @@
 (defmacro (add x y)
   `(+ ,x ,@y))
 
+(defmacro (add-optional x &optional (y 10) z)
+  `(list ,x ,y ',z))
+
+(defmacro (add-rest x &rest ys)
+  `(+ ,x ,@ys))
+
+(defmacro (swap-in-place! a b)
+  (with-gensyms (tmp)
+    `(let ((,tmp ,a))
+       (set! ,a ,b)
+       (set! ,b ,tmp))))
+
+(defmacro (square-once x)
+  (once-only (x)
+    `(* ,x ,x)))
+
 
 (context "macro"
 
@@ -32,6 +48,42 @@
              (assert-eq `(a ,@(list 1 2 3) `(list ,@(list a b c)))
                         '(a 1 2 3 `(list ,@(list a b c)))))
 
+         (it nested-unquote
+             (let ((x 5)
+                   (l '(1 2)))
+               (assert-eq `(a `(b ,(c ,x)))
+                          '(a `(b ,(c 5))))
+               (assert-eq `(a `(b ,,x ,@,l))
+                          '(a `(b ,5 ,@(1 2))))))
+
+         (it dotted-tail
+             (let ((x 5)
+                   (l '(1 2)))
+               (assert-eq `(a . ,x)
+                          '(a . 5))
+               (assert-eq `(a ,@l . ,x)
+                          '(a 1 2 . 5))
+               (assert-eq `(a ,@x)
+                          '(a . 5))
+               (assert-error `(a ,@x b))))
+
+         (it vector
+             (let ((x 5)
+                   (l '(1 2)))
+               (assert-true (vector? `#(a ,x)))
+               (assert-eq `#(a ,x ,@l b)
+                          #(a 5 1 2 b))
+               (assert-eq `(v #(a ,@l))
+                          '(v #(a 1 2)))))
+
+         (it frame
+             (let ((x 5)
+                   (slots '(b: 2 c: 3)))
+               (assert-eq (eval `{a: ,x})
+                          {a: 5})
+               (assert-eq (eval `{a: ,x ,@slots})
+                          {a: 5 b: 2 c: 3})))
+
          (it combined-and-eval
              (let* ((x 1)
                     (y '(2 3)))
@@ -50,7 +102,38 @@
              (assert-eq  `(a `(b ,(+ 1 2) ,(foo ,(+ 1 3) d) e) f) 
                          '(a `(b ,(+ 1 2) ,(foo 4 d) e) f)))
 
+         (it defmacro-optional
+             (assert-eq (add-optional 1) '(1 10 ()))
+             (assert-eq (add-optional 1 2) '(1 2 ()))
+             (assert-eq (add-optional 1 2 3) '(1 2 3))
+             (assert-error (add-optional))
+             (assert-error (add-optional 1 2 3 4)))
+
+         (it defmacro-rest
+             (assert-eq (add-rest 1) 1)
+             (assert-eq (add-rest 1 2 3) 6)
+             (assert-eq (expand add-rest 1 2 3) '(+ 1 2 3)))
+
+         (it with-gensyms
+             (let ((tmp 1)
+                   (other 2))
+               (swap-in-place! tmp other)
+               (assert-eq tmp 2)
+               (assert-eq other 1))
+             (assert-false (eq? (car (cadr (cadr (expand swap-in-place! a b)))) 'tmp)))
+
+         (it once-only
+             (let ((count 0))
+               (assert-eq (square-once (begin (set! count (+ count 1)) 3)) 9)
+               (assert-eq count 1)))
+
          (it defmacro-errors
              (assert-error (defmacro "x" 1))
-             (assert-error (defmacro ("x") 1)))
+             (assert-error (defmacro ("x") 1))
+             (assert-error (defmacro (bad &rest) 1))
+             (assert-error (defmacro (bad &rest a b) 1))
+             (assert-error (defmacro (bad &rest a &optional b) 1))
+             (assert-error (defmacro (bad &optional (a)) 1))
+             (assert-error (with-gensyms (1) 1))
+             (assert-error (once-only x 1)))
 )
@@ -73,6 +73,131 @@
              (assert-eq #t (or (> 4 2) (+ 4 1)))
              (assert-eq 5 (or (< 4 2) (+ 4 1))))
 
+         (it "chains comparisons over any number of arguments"
+             (assert-true (< 1 2 3))
+             (assert-false (< 1 3 2))
+             (assert-true (<= 1 1 2.5))
+             (assert-true (> 3 2.0 1))
+             (assert-false (>= 3 4 1))
+             (assert-true (= 2 2 2.0))
+             (assert-false (= 2 2 3))
+             (assert-error (< 5))
+             (assert-false (< 1 nan))
+             (assert-false (= nan nan))
+             (assert-error (< 1 'a))
+             (assert-error (= 1 2 "3")))
+
+         (it "compares large integers exactly"
+             (assert-true (< 9007199254740992 9007199254740993))
+             (assert-false (= 9007199254740992 9007199254740993)))
+
+         (it "modulo has the sign of the divisor and remainder that of the dividend"
+             (assert-eq (modulo 13 4) 1)
+             (assert-eq (modulo -13 4) 3)
+             (assert-eq (modulo 13 -4) -3)
+             (assert-eq (modulo -13 -4) -1)
+             (assert-eq (modulo 12 -4) 0)
+             (assert-eq (remainder 13 4) 1)
+             (assert-eq (remainder -13 4) -1)
+             (assert-eq (remainder 13 -4) 1)
+             (assert-eq (remainder -13 -4) -1)
+             (assert-eq (% -13 4) -1)
+             (assert-error (modulo 1 0))
+             (assert-error (remainder 1 0)))
+
+         (it quotient-truncates
+             (assert-eq (quotient 13 4) 3)
+             (assert-eq (quotient -13 4) -3)
+             (assert-eq (quotient 13 -4) -3)
+             (assert-eq (quotient -13 -4) 3)
+             (assert-eq (quotient 13.0 4) 3.0)
+             (assert-eq (quotient (expt 2 70) -3) -393530540239137101141)
+             (assert-error (quotient 1 0))
+             (assert-error (quotient 1 2 3)))
+
+         (it truncate-division
+             (assert-eq (truncate/ 13 4) '(3 1))
+             (assert-eq (truncate/ -13 4) '(-3 -1))
+             (assert-eq (truncate/ 13 -4) '(-3 1))
+             (assert-eq (truncate/ -13 -4) '(3 -1))
+             (assert-eq (truncate-quotient -13 4) -3)
+             (assert-eq (truncate-remainder -13 4) -1)
+             (assert-eq (truncate/ -7.5 2) '(-3.0 -1.5)))
+
+         (it floor-division
+             (assert-eq (floor/ 13 4) '(3 1))
+             (assert-eq (floor/ -13 4) '(-4 3))
+             (assert-eq (floor/ 13 -4) '(-4 -3))
+             (assert-eq (floor/ -13 -4) '(3 -1))
+             (assert-eq (floor-quotient -13 4) -4)
+             (assert-eq (floor-remainder -13 4) 3)
+             (assert-eq (floor/ -7.5 2) '(-4.0 0.5))
+             (assert-eq (floor/ (- 0 (expt 2 70)) 3) '(-393530540239137101142 2)))
+
+         (it euclidean-division
+             (assert-eq (euclidean/ 13 4) '(3 1))
+             (assert-eq (euclidean/ -13 4) '(-4 3))
+             (assert-eq (euclidean/ 13 -4) '(-3 1))
+             (assert-eq (euclidean/ -13 -4) '(4 3))
+             (assert-eq (euclidean-quotient -13 -4) 4)
+             (assert-eq (euclidean-remainder -13 -4) 3)
+             (assert-eq (euclidean/ (- 0 (expt 2 70)) -3) '(393530540239137101142 2)))
+
+         (it division-identity
+             (for-each (lambda (n)
+                         (for-each (lambda (d)
+                                     (for-each (lambda (qr)
+                                                 (assert-eq (+ (* (car qr) d) (cadr qr)) n))
+                                               (list (truncate/ n d) (floor/ n d) (euclidean/ n d))))
+                                   '(1 -1 3 -3 7 -7)))
+                       '(0 1 -1 5 -5 20 -20 21 -21)))
+
+         (it division-overflow
+             (assert-eq (quotient -9223372036854775808 -1) 9223372036854775808)
+             (assert-eq (/ -9223372036854775808 -1) 9223372036854775808)
+             (assert-eq (floor/ -9223372036854775808 -1) '(9223372036854775808 0)))
+
+         (it division-errors
+             (assert-error (truncate/ 1 0))
+             (assert-error (floor/ 1 0.0))
+             (assert-error (euclidean/ 'a 2))
+             (assert-error (floor-remainder 2 "b")))
+
+         (it "gcd and lcm"
+             (assert-eq (gcd 32 -36) 4)
+             (assert-eq (gcd 12 18 27) 3)
+             (assert-eq (gcd 5) 5)
+             (assert-eq (gcd) 0)
+             (assert-eq (lcm 32 -36) 288)
+             (assert-eq (lcm 4 6 10) 60)
+             (assert-eq (lcm 3 0) 0)
+             (assert-eq (lcm) 1)
+             (assert-error (gcd 1.5 3)))
+
+         (it "min and max take numbers as well as a list"
+             (assert-eq (min 3 1 2) 1)
+             (assert-eq (max 3 1 2) 3)
+             (assert-eq (max 1 2.5) 2.5)
+             (assert-eq (min 7) 7)
+             (assert-error (min 1 'a)))
+
+         (it "and/or result in the deciding value"
+             (assert-eq (and 1 2 3) 3)
+             (assert-eq (and 1 '() 3) '())
+             (assert-eq (or #f '(a) 3) '(a))
+             (assert-eq (or #f nil) nil)
+             (assert-eq (and) #t)
+             (assert-eq (or) #f))
+
+         (it "and/or don't evaluate past the deciding value"
+             (define evaluated '())
+             (define (note x) (set! evaluated (cons x evaluated)) x)
+             (and (note 1) (note #f) (note 3))
+             (assert-eq evaluated '(#f 1))
+             (set! evaluated '())
+             (or (note #f) (note 2) (note 3))
+             (assert-eq evaluated '(2 #f)))
+
          (it int-min
              (assert-eq (min '(1 2))
                         1)
@@ -103,7 +228,8 @@
              (assert-eq (floor -3.4)
                         -4.0)
              (assert-eq (floor 3)
-                        3.0))
+                        3)
+             (assert-true (exact? (floor 3))))
 
          (it ceiling
              (assert-eq (ceiling 3.4)
@@ -111,11 +237,63 @@
              (assert-eq (ceiling -3.4)
                         -3.0)
              (assert-eq (ceiling 3)
-                        3.0))
+                        3)
+             (assert-true (exact? (ceiling 3))))
+
+         (it round
+             (assert-eq (round 3.4) 3.0)
+             (assert-eq (round 3.6) 4.0)
+             (assert-eq (round 2.5) 2.0)
+             (assert-eq (round 3.5) 4.0)
+             (assert-eq (round -2.5) -2.0)
+             (assert-eq (round 7) 7)
+             (assert-true (inexact? (round 3.4))))
+
+         (it truncate
+             (assert-eq (truncate 3.7) 3.0)
+             (assert-eq (truncate -3.7) -3.0)
+             (assert-eq (truncate -3) -3))
+
+         (it exactness
+             (assert-true (exact? 3))
+             (assert-true (exact? 100000000000000000000))
+             (assert-false (exact? 3.0))
+             (assert-true (inexact? 3.0))
+             (assert-false (inexact? 3))
+             (assert-true (integer? 100000000000000000000))
+             (assert-error (exact? 'a)))
+
+         (it exactness-conversions
+             (assert-eq (exact->inexact 3) 3.0)
+             (assert-true (inexact? (exact->inexact 3)))
+             (assert-eq (inexact 2) 2.0)
+             (assert-eq (inexact->exact 4.0) 4)
+             (assert-true (exact? (inexact->exact 4.0)))
+             (assert-eq (exact -12.0) -12)
+             (assert-eq (inexact->exact (exact->inexact (expt 2 70))) (expt 2 70))
+             (assert-true (bignum? (inexact->exact 1e20)))
+             (assert-eq (inexact->exact 7) 7)
+             (assert-error (inexact->exact 2.5))
+             (assert-error (inexact->exact (/ 1.0 0))))
+
+         (it contagion
+             (assert-true (inexact? (+ 1 2.0)))
+             (assert-true (exact? (+ 1 2)))
+             (assert-eq (% 7.5 2) 1.5)
+             (assert-eq (remainder -7 2.0) -1.0)
+             (assert-eq (modulo -7 2.0) 1.0)
+             (assert-eq (modulo 7.5 -2) -0.5))
+
+         (it scientific-notation
+             (assert-eq 1e3 1000.0)
+             (assert-eq 1.5e-2 0.015)
+             (assert-eq -2.5E2 -250.0)
+             (assert-eq 1e+2 100.0)
+             (assert-true (inexact? 1e3)))
 
          (it general-math-errors
              (assert-error (/ 3 0))
-             (assert-error (% 3.5 6))
+             (assert-error (% 3.5 0))
              (assert-error (min '(1 d)))
              (assert-error (max 5.4 i))
              (assert-error (floor 'd))
@@ -0,0 +1,27 @@
+;;; -*- mode: Scheme -*-
+
+(context "memory-stats"
+
+         ()
+
+         (it "reports interpreter counts"
+             (let ((stats (memory-stats)))
+               (assert-true (frame? stats))
+               (assert-true (> (symbols: stats) 0))
+               (assert-true (> (global-bindings: stats) 0))
+               (assert-true (> (goroutines: stats) 0))))
+
+         (it "reports heap numbers"
+             (let ((stats (memory-stats)))
+               (assert-true (> (heap-alloc: stats) 0))
+               (assert-true (>= (total-alloc: stats) (heap-alloc: stats)))
+               (assert-true (integer? (gc-pause-total-ms: stats))))))
+
+(context "gc"
+
+         ()
+
+         (it "forces a collection"
+             (let ((before (gc-count: (memory-stats))))
+               (assert-nil (gc))
+               (assert-true (> (gc-count: (memory-stats)) before)))))
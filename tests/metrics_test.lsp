@@ -0,0 +1,37 @@
+;;; -*- mode: Scheme -*-
+
+(context "metrics"
+
+         ()
+
+         (it "counts"
+             (assert-eq (metric-inc! "test_requests") 1)
+             (assert-eq (metric-inc! "test_requests" 4) 5)
+             (assert-eq (metric-value "test_requests") 5)
+             (assert-error (metric-inc! "test_requests" -1))
+             (assert-true (memq "test_requests" (metric-names))))
+
+         (it "sets gauges"
+             (metric-set! 'test_temperature 20)
+             (metric-inc! 'test_temperature -2.5)
+             (assert-eq (metric-value 'test_temperature) 17.5))
+
+         (it "observes values into histogram buckets"
+             (define-metric "test_latency" 'histogram {help: "How long things take." buckets: '(1 5 10)})
+             (metric-observe! "test_latency" 0.5)
+             (metric-observe! "test_latency" 4)
+             (metric-observe! "test_latency" 20)
+             (define latency (metric-value "test_latency"))
+             (assert-eq (count: latency) 3)
+             (assert-eq (sum: latency) 24.5)
+             (assert-eq (buckets: latency) '((1 . 1) (5 . 2) (10 . 2))))
+
+         (it "keeps each metric to one kind"
+             (metric-inc! "test_kind")
+             (assert-error (metric-set! "test_kind" 1))
+             (assert-error (metric-observe! "test_kind" 1))
+             (assert-error (define-metric "test_kind" 'gauge))
+             (assert-error (define-metric "test_other_kind" 'meter)))
+
+         (it "is nil for unknown metrics"
+             (assert-nil (metric-value "test_no_such_metric"))))
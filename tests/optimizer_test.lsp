@@ -0,0 +1,70 @@
+;;; -*- mode: Scheme -*-
+
+(context "constant folding"
+
+         ()
+
+         (it "folds arithmetic on literals"
+             (assert-eq (optimize-expression '(+ 1 (* 2 3))) 7)
+             (assert-eq (optimize-expression '(< (- 10 4) 5)) #f))
+
+         (it "folds the literal parts of larger expressions"
+             (assert-eq (optimize-expression '(+ x (* 2 3))) '(+ x 6))
+             (assert-eq (optimize-expression '(list (* 2 3) "a")) '(list 6 "a")))
+
+         (it "leaves errors to happen at run time"
+             (assert-eq (optimize-expression '(/ 1 0)) '(/ 1 0)))
+
+         (it "doesn't fold impure or unknown functions"
+             (assert-eq (optimize-expression '(random-byte)) '(random-byte))
+             (assert-eq (optimize-expression '(not-defined-yet (+ 1 2))) '(not-defined-yet (+ 1 2))))
+
+         (it "doesn't touch quoted data"
+             (assert-eq (optimize-expression ''(+ 1 2)) ''(+ 1 2)))
+
+         (it "respects local rebinding"
+             (assert-eq (optimize-expression '(lambda (+) (+ 1 2))) '(lambda (+) (+ 1 2)))
+             (assert-eq (optimize-expression '(let ((x (+ 1 2))) (* x 2))) '(let ((x 3)) (* x 2)))))
+
+(context "conditionals"
+
+         ()
+
+         (it "reduces ifs with literal tests"
+             (assert-eq (optimize-expression '(if (> 2 1) (foo) (bar))) '(foo))
+             (assert-eq (optimize-expression '(if (> 1 2) (foo) (bar))) '(bar))
+             (assert-eq (optimize-expression '(if (> 1 2) (foo))) '(if #f (foo))))
+
+         (it "optimizes cond clauses"
+             (assert-eq (optimize-expression '(cond ((== x 1) (+ 1 1)) (else (* 2 2))))
+                        '(cond ((== x 1) 2) (else 4)))))
+
+(context "inlining"
+
+         ()
+
+         (it "inlines trivial lambdas applied to literals"
+             (assert-eq (optimize-expression '((lambda (x y) (* x y)) 6 7)) 42)
+             (assert-eq (optimize-expression '((lambda (x) (f x 'x)) 1)) '(f 1 'x)))
+
+         (it "leaves lambdas that rebind or assign alone"
+             (assert-eq (optimize-expression '((lambda (x) (set! x 2)) 1)) '((lambda (x) (set! x 2)) 1))))
+
+(context "optimize"
+
+         ()
+
+         (it "optimizes definitions when enabled"
+             (assert-false (optimize))
+             (define (unoptimized x) (+ x (* 60 60)))
+             (assert-true (optimize #t))
+             (define (optimized x) (+ x (* 60 60)))
+             (define seconds-per-day (* 24 60 60))
+             (optimize #f)
+             (assert-eq (definition-of unoptimized) '(define (unoptimized x) (+ x (* 60 60))))
+             (assert-eq (definition-of optimized) '(define (optimized x) (+ x 3600)))
+             (assert-eq (optimized 1) 3601)
+             (assert-eq seconds-per-day 86400))
+
+         (it "requires a boolean"
+             (assert-error (optimize 1))))
@@ -0,0 +1,43 @@
+;;; -*- mode: Scheme -*-
+
+(define (greet name)
+  (display "Hello, ")
+  (display name)
+  (newline))
+
+(context "with-output-to-string"
+
+         ()
+
+         (it "captures printed output"
+             (assert-eq (with-output-to-string (display "a") (write "b") (newline)) "a\"b\"\n"))
+
+         (it "captures output from called functions"
+             (assert-eq (with-output-to-string (greet "world")) "Hello, world\n"))
+
+         (it "captures write-line and format"
+             (assert-eq (with-output-to-string (write-line "x") (format #t "~A-~A" 1 2)) "x\n1-2"))
+
+         (it "is empty when nothing is printed"
+             (assert-eq (with-output-to-string (+ 1 2)) ""))
+
+         (it "nests"
+             (assert-eq (with-output-to-string
+                         (display "outer ")
+                         (display (with-output-to-string (display "inner"))))
+                        "outer inner"))
+
+         (it "only applies for its dynamic extent"
+             (let ((printer (lambda () (display "late"))))
+               (assert-eq (with-output-to-string (display "early")) "early")
+               (assert-eq (with-output-to-string (printer)) "late")))
+
+         (it "passes errors on"
+             (assert-error (with-output-to-string (display "a") (car 1 2)))))
+
+(context "with-output-to-port"
+
+         ()
+
+         (it "requires a port"
+             (assert-error (with-output-to-port "not a port" (display "a")))))
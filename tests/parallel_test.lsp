@@ -0,0 +1,43 @@
+;;; -*- mode: Scheme -*-
+
+(context "pmap"
+
+         ()
+
+         (it "maps like map"
+             (assert-eq (pmap (lambda (x) (* x x)) '(1 2 3 4 5)) '(1 4 9 16 25))
+             (assert-eq (pmap + '(1 2 3) '(10 20 30 40)) '(11 22 33))
+             (assert-eq (pmap car '()) '()))
+
+         (it "keeps the results in order"
+             (define numbers (interval 1 1000))
+             (assert-eq (pmap (lambda (x) (+ x 1)) numbers) (map (lambda (x) (+ x 1)) numbers)))
+
+         (it "runs closures concurrently"
+             (define offset 100)
+             (define (shifted x)
+               (let ((y (+ x offset)))
+                 y))
+             (assert-eq (pmap shifted (interval 1 200)) (map shifted (interval 1 200))))
+
+         (it "reports the earliest failure"
+             (assert-error (pmap (lambda (x) (if (= x 50) (error "fifty") x)) (interval 1 100)))
+             (assert-error (pmap 1 '(1 2)))
+             (assert-error (pmap car [1 2]))))
+
+(context "pvector-map"
+
+         ()
+
+         (it "maps over vectors into a vector"
+             (assert-eq (pvector-map (lambda (x) (* 2 x)) #(1 2 3)) #(2 4 6))
+             (assert-eq (pvector-map + #(1 2) #(3 4 5)) #(4 6))
+             (assert-eq (pvector-map car #()) #()))
+
+         (it "keeps the results in order"
+             (define v (list->vector (interval 1 1000)))
+             (assert-eq (vector->list (pvector-map (lambda (x) (- x))  v))
+                        (map (lambda (x) (- x)) (interval 1 1000))))
+
+         (it "rejects things that aren't vectors"
+             (assert-error (pvector-map car '(1 2)))))
@@ -0,0 +1,26 @@
+;;; -*- mode: Scheme -*-
+
+(context "plot"
+
+         ()
+
+         (it line-chart
+             (define svg (plot '(1 2 3 2)))
+             (assert-true (string-prefix? "<svg " svg))
+             (assert-true (substring? "<polyline" svg))
+             (assert-true (substring? "width=\"640\"" svg)))
+
+         (it options
+             (define svg (plot (vector '(1 2) #(3.5 1)) {type: 'bar title: "Latency" names: '("a" "b") width: 320 height: 200}))
+             (assert-false (substring? "<polyline" svg))
+             (assert-true (substring? ">Latency</text>" svg))
+             (assert-true (substring? ">b</text>" svg))
+             (assert-true (substring? "width=\"320\"" svg)))
+
+         (it errors
+             (assert-error (plot '()))
+             (assert-error (plot '(1 a 3)))
+             (assert-error (plot '(1 2) 5))
+             (assert-error (plot '(1 2) {type: 'pie}))
+             (assert-error (plot '(1 2) {width: 10}))
+             (assert-error (plot '(1 2) {file: "chart.gif"}))))
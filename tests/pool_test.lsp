@@ -0,0 +1,60 @@
+;;; -*- mode: Scheme -*-
+
+(context "worker pools"
+
+         ()
+
+         (it "runs submitted tasks and waits for them when drained"
+             (define count (atomic))
+             (define pool (make-pool 4))
+             (assert-true (pool? pool))
+             (for-each (lambda (n) (pool-submit! pool (lambda (x) (atomic-add! count x)) n))
+                       (interval 1 100))
+             (assert-eq (pool-drain! pool) 100)
+             (assert-eq (atomic-load count) 5050)
+             (pool-shutdown! pool))
+
+         (it "applies backpressure when the queue is full"
+             (define pool (make-pool 1 1))
+             (define started (millis))
+             (pool-submit! pool (lambda () (sleep 30)))
+             (pool-submit! pool (lambda () (sleep 30)))
+             (pool-submit! pool (lambda () (sleep 30)))
+             (assert-true (>= (- (millis) started) 25))
+             (pool-shutdown! pool)
+             (assert-eq (pool-runs pool) 3))
+
+         (it "records failures without stopping"
+             (define pool (make-pool 2))
+             (pool-submit! pool (lambda () (error "boom")))
+             (pool-submit! pool (lambda () 1))
+             (pool-drain! pool)
+             (assert-eq (pool-runs pool) 2)
+             (assert-eq (pool-failures pool) 1)
+             (assert-true (string? (pool-last-error pool)))
+             (pool-shutdown! pool))
+
+         (it "refuses tasks while draining"
+             (define pool (make-pool 1))
+             (define refused (atomic 0))
+             (pool-submit! pool (lambda ()
+                                  (sleep 20)
+                                  (on-error (pool-submit! pool (lambda () 1))
+                                            (lambda (e) (atomic-add! refused 1)))))
+             (pool-drain! pool)
+             (assert-eq (atomic-load refused) 1)
+             (pool-shutdown! pool))
+
+         (it "refuses tasks after shutdown"
+             (define pool (make-pool 1))
+             (assert-eq (pool-shutdown! pool) 0)
+             (assert-error (pool-submit! pool (lambda () 1))))
+
+         (it "checks its arguments"
+             (assert-error (make-pool 0))
+             (assert-error (make-pool 'a))
+             (assert-error (pool-submit! 5 (lambda () 1)))
+             (define pool (make-pool 1))
+             (assert-error (pool-submit! pool 5))
+             (assert-error (pool-submit! pool (lambda (x) x)))
+             (pool-shutdown! pool)))
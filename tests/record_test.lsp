@@ -0,0 +1,76 @@
+;;; -*- mode: Scheme -*-
+
+(define-record-type <point>
+  (make-point x y)
+  point?
+  (x point-x set-point-x!)
+  (y point-y))
+
+(define-record-type labelled
+  (make-labelled text)
+  labelled?
+  (text labelled-text)
+  (colour labelled-colour set-labelled-colour!))
+
+(define-record-type <node>
+  (make-node v)
+  node?
+  (v node-v)
+  (next node-next set-node-next!))
+
+(context "define-record-type"
+
+         ((define p (make-point 1 2)))
+
+         (it "makes records"
+             (assert-true (point? p))
+             (assert-false (point? '(1 2)))
+             (assert-false (point? (make-labelled "a")))
+             (assert-eq (point-x p) 1)
+             (assert-eq (point-y p) 2)
+             (assert-eq (format #f "~A" p) "#<point x: 1 y: 2>"))
+
+         (it "modifies fields"
+             (assert-eq (set-point-x! p 10) 10)
+             (assert-eq (point-x p) 10)
+             (assert-error (set-point-y! p 3)))
+
+         (it "leaves fields the constructor doesn't take unset"
+             (define l (make-labelled "hi"))
+             (assert-nil (labelled-colour l))
+             (set-labelled-colour! l 'red)
+             (assert-eq (labelled-colour l) 'red))
+
+         (it "checks the record type"
+             (assert-error (point-x (make-labelled "a")))
+             (assert-error (point-x 1))
+             (assert-error (make-point 1)))
+
+         (it "compares records by type and contents"
+             (assert-true (equal? (make-point 1 2) (make-point 1 2)))
+             (assert-false (equal? (make-point 1 2) (make-point 2 1))))
+
+         (it "binds the record type"
+             (assert-eq (format #f "~A" <point>) "<record-type: point>"))
+
+         (it "accepts a bare constructor name"
+             (define-record-type pair-of make-pair-of pair-of? (a pair-of-a) (b pair-of-b))
+             (assert-eq (pair-of-b (make-pair-of 1 2)) 2))
+
+         (it "can refer to themselves"
+             (define n (make-node 1))
+             (set-node-next! n n)
+             (define m (make-node 1))
+             (set-node-next! m m)
+             (assert-eq (format #f "~A" n) "#0=#<node v: 1 next: #0#>")
+             (assert-true (equal? n m))
+             (set-node-next! m (make-node 2))
+             (assert-false (equal? n m))
+             (define h (make-hash-table))
+             (hash-set! h n 'found)
+             (assert-eq (hash-ref h n #f) 'found))
+
+         (it "rejects malformed definitions"
+             (assert-error (define-record-type 1 (make-bad) bad?))
+             (assert-error (define-record-type bad (make-bad z) bad? (x bad-x)))
+             (assert-error (define-record-type bad (make-bad) bad? (x 1)))))
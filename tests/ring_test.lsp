@@ -0,0 +1,45 @@
+;;; -*- mode: Scheme -*-
+
+(context "ring"
+
+         ()
+
+         (it "gets items oldest first"
+             (define r (make-ring 3))
+             (assert-true (ring? r))
+             (assert-true (ring-empty? r))
+             (ring-put! r 1)
+             (ring-put! r 2)
+             (assert-eq (ring-count r) 2)
+             (assert-eq (ring-peek r) 1)
+             (assert-eq (ring-get r) 1)
+             (assert-eq (ring-get r) 2)
+             (assert-true (ring-empty? r)))
+
+         (it "overwrites the oldest item when full"
+             (define r (make-ring 3))
+             (ring-put! r 1)
+             (ring-put! r 2)
+             (ring-put! r 3)
+             (assert-true (ring-full? r))
+             (ring-put! r 4)
+             (ring-put! r 5)
+             (assert-eq (ring-count r) 3)
+             (assert-eq (ring-capacity r) 3)
+             (assert-eq (ring->vector r) #(3 4 5))
+             (assert-eq (ring->list r) '(3 4 5))
+             (assert-eq (ring-get r) 3))
+
+         (it "can be cleared"
+             (define r (make-ring 2))
+             (ring-put! r 1)
+             (ring-clear! r)
+             (assert-true (ring-empty? r))
+             (assert-eq (ring->vector r) #()))
+
+         (it "reports errors"
+             (assert-error (make-ring 0))
+             (assert-error (make-ring 100000000000000))
+             (assert-error (make-ring 'a))
+             (assert-error (ring-get (make-ring 1)))
+             (assert-error (ring-put! '(1) 2))))
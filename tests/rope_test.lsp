@@ -0,0 +1,53 @@
+;;; -*- mode: Scheme -*-
+
+(context "rope"
+
+         ()
+
+         (it construction
+             (assert-true (rope? (rope "abc" "def")))
+             (assert-false (rope? "abc"))
+             (assert-eq (rope->string (rope "abc" "def")) "abcdef")
+             (assert-eq (rope->string (rope)) "")
+             (assert-eq (rope->string (rope-append (rope "ab") "cd" (rope "ef"))) "abcdef")
+             (assert-error (rope "abc" 5)))
+
+         (it as-a-string
+             (assert-true (string? (rope "abc")))
+             (assert-eq (string-length (rope "abc" "def")) 6)
+             (assert-eq (string-upcase (rope "abc" "def")) "ABCDEF")
+             (assert-eq (substring (rope "abc" "def") 2 4) "cd")
+             (assert-eq (str (rope "abc" "def") "!") "abcdef!")
+             (assert-eq (rope "abc" "def") "abcdef")
+             (assert-true (equal? "abcdef" (rope "abc" "def")))
+             (assert-eq (equal-hash (rope "abc" "def")) (equal-hash "abcdef")))
+
+         (it printing
+             (assert-eq (with-output-to-string (display (rope "a\"b" "c"))) "a\"bc")
+             (assert-eq (with-output-to-string (write (rope "a\"b" "c"))) "\"a\\\"bc\""))
+
+         (it subrope
+             (define r (rope "hello " "there " "world"))
+             (assert-eq (subrope r 4 13) "o there w")
+             (assert-eq (subrope r 6) "there world")
+             (assert-true (rope? (subrope r 0 5)))
+             (assert-eq (subrope "plain string" 6) "string")
+             (assert-error (subrope r 5 100))
+             (assert-error (subrope r 5 2)))
+
+         (it mutation
+             (define r (rope "abc" "def"))
+             (define r2 (rope-append r "ghi"))
+             (string-upcase! r)
+             (assert-eq r "ABCDEF")
+             (assert-false (rope? r))
+             (assert-eq r2 "abcdefghi"))
+
+         (it long-ropes
+             (define r (rope))
+             (do ((i 0 (+ i 1)))
+                 ((= i 2000))
+               (set! r (rope-append r (number->string (modulo i 10)))))
+             (assert-eq (string-length r) 2000)
+             (assert-eq (subrope r 1995 2000) "56789")
+             (assert-eq (substring r 0 12) "012345678901")))
@@ -0,0 +1,91 @@
+;;; -*- mode: Scheme -*-
+
+(context "scheduler"
+
+         ()
+
+         (it "runs jobs repeatedly until cancelled"
+             (define count (atomic))
+             (define job (schedule every: 5 (lambda (job) (atomic-add! count 1))))
+             (assert-true (job? job))
+             (sleep 60)
+             (cancel-job! job)
+             (sleep 20)
+             (define final (atomic-load count))
+             (assert-true (> final 1))
+             (assert-eq (job-runs job) final)
+             (sleep 30)
+             (assert-eq (atomic-load count) final))
+
+         (it "passes extra arguments and accepts duration strings and jitter"
+             (define count (atomic))
+             (define job (schedule every: "5ms" jitter: 2 (lambda (job counter) (atomic-add! counter 1)) count))
+             (sleep 60)
+             (cancel-job! job)
+             (assert-true (> (atomic-load count) 0)))
+
+         (it "can be paused and resumed"
+             (define job (schedule every: 5 (lambda (job) ())))
+             (pause-job! job)
+             (assert-true (job-paused? job))
+             (sleep 20)
+             (define paused-runs (job-runs job))
+             (sleep 40)
+             (assert-eq (job-runs job) paused-runs)
+             (resume-job! job)
+             (assert-false (job-paused? job))
+             (sleep 40)
+             (cancel-job! job)
+             (assert-true (> (job-runs job) paused-runs)))
+
+         (it "keeps running after errors"
+             (define job (schedule every: 5 (lambda (job) (car 1 2 3))))
+             (sleep 60)
+             (cancel-job! job)
+             (assert-true (> (job-failures job) 1))
+             (assert-eq (job-failures job) (job-runs job))
+             (assert-true (string? (job-last-error job))))
+
+         (it "tracks running jobs"
+             (define job (schedule every: 1000 (lambda (job) ())))
+             (assert-true (memq job (scheduled-jobs)))
+             (cancel-job! job)
+             (assert-false (memq job (scheduled-jobs))))
+
+         (it "lists jobs in the order they were scheduled"
+             (define jobs (map (lambda (i) (schedule every: 1000 (lambda (job) ()))) '(1 2 3 4 5)))
+             (assert-eq (filter (lambda (job) (if (memq job jobs) #t #f)) (scheduled-jobs)) jobs)
+             (for-each cancel-job! jobs))
+
+         (it "reports errors"
+             (assert-error (schedule every: 0 (lambda (job) ())))
+             (assert-error (schedule every: "soon" (lambda (job) ())))
+             (assert-error (schedule every: 10 (lambda () ())))
+             (assert-error (pause-job! 1))))
+
+(context "schedule-cron"
+
+         ()
+
+         (it "returns a job"
+             (define job (schedule-cron "*/5 * * * *" (lambda () ())))
+             (assert-true (job? job))
+             (assert-eq (job-runs job) 0)
+             (cancel-job! job)
+             (define job (schedule-cron "@hourly" (lambda (job x) x) 1))
+             (assert-true (job? job))
+             (cancel-job! job))
+
+         (it "checks its arguments"
+             (assert-error (schedule-cron "*/5 * * *" (lambda () ())))
+             (assert-error (schedule-cron "61 * * * *" (lambda () ())))
+             (assert-error (schedule-cron 5 (lambda () ())))
+             (assert-error (schedule-cron "* * * * *" 5))
+             (assert-error (schedule-cron "* * * * *" (lambda (a b c) ()) 1)))
+
+         (it "finds the next matching time"
+             (assert-eq (format-time (cron-next "*/5 * * * *" (parse-time "2015-03-01T12:31:00Z"))) "2015-03-01T12:35:00Z")
+             (assert-eq (format-time (cron-next "0 9 * * mon" (parse-time "2015-03-01T12:00:00Z"))) "2015-03-02T09:00:00Z")
+             (assert-eq (format-time (cron-next "0 9 * * *" (in-timezone (parse-time "2015-03-01T12:00:00Z") "Europe/Copenhagen"))) "2015-03-02T09:00:00+01:00")
+             (assert-nil (cron-next "0 0 30 2 *" (parse-time "2015-03-01T12:00:00Z")))
+             (assert-true (time? (cron-next "* * * * *")))))
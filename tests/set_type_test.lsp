@@ -0,0 +1,57 @@
+;;; -*- mode: Scheme -*-
+
+(context "sets"
+
+         ((define s (set 1 2 3 2 1)))
+
+         (it "makes sets of distinct members"
+             (assert-true (set? s))
+             (assert-false (set? '(1 2 3)))
+             (assert-eq (set-count s) 3)
+             (assert-eq (set-count (set)) 0)
+             (assert-eq (sort (set->list s) <) '(1 2 3))
+             (assert-eq (set-count (list->set '((a) (a) (b)))) 2)
+             (assert-error (list->set 1)))
+
+         (it "tests membership with equal?"
+             (assert-true (set-contains? s 2))
+             (assert-true (set-contains? s 2.0))
+             (assert-false (set-contains? s 4))
+             (assert-true (set-contains? (set "a" '(1 2)) (list 1 2))))
+
+         (it "adds and removes members"
+             (assert-eq (set-add! s 4 5 4) s)
+             (assert-eq (set-count s) 5)
+             (set-remove! s 1 5 6)
+             (assert-eq (sort (set->list s) <) '(2 3 4)))
+
+         (it "combines sets"
+             (define a (set 1 2 3 4))
+             (define b (set 3 4 5))
+             (assert-eq (sort (set->list (set-union a b (set 9))) <) '(1 2 3 4 5 9))
+             (assert-eq (sort (set->list (set-intersection a b)) <) '(3 4))
+             (assert-eq (sort (set->list (set-difference a b)) <) '(1 2))
+             (assert-eq (set-count (set-union)) 0)
+             (assert-eq (set-count a) 4)
+             (assert-error (set-union a '(1 2))))
+
+         (it "compares sets"
+             (assert-true (set-subset? (set 1 2) (set 2 1 3)))
+             (assert-false (set-subset? (set 1 4) (set 2 1 3)))
+             (assert-true (equal? (set 1 2 3) (set 3 2 1)))
+             (assert-false (equal? (set 1 2) (set 1 2 3)))
+             (assert-eq (equal-hash (set 1 2 3)) (equal-hash (set 3 1 2)))
+             (assert-true (set-contains? (set (set 1 2)) (set 2 1))))
+
+         (it "scales to many members"
+             (define big (list->set (interval 1 5000)))
+             (assert-eq (set-count (set-intersection big (list->set (interval 4990 6000)))) 11))
+
+         (it "can contain itself"
+             (define s (set))
+             (set-add! s s)
+             (set-add! s 1)
+             (assert-eq (set-count s) 2)
+             (assert-true (set-contains? s 1))
+             (assert-true (integer? (equal-hash s)))
+             (assert-true (set-contains? (set s) s))))
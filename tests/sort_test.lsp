@@ -0,0 +1,43 @@
+;;; -*- mode: Scheme -*-
+
+(context "sort"
+
+         ()
+
+         (it "sorts lists"
+             (assert-eq (sort '(3 1 2) <) '(1 2 3))
+             (assert-eq (sort '(3 1.5 2) >) '(3 2 1.5))
+             (assert-eq (sort '("b" "c" "a") string<?) '("a" "b" "c"))
+             (assert-eq (sort '() <) '()))
+
+         (it "compares numbers exactly"
+             (assert-eq (sort '(9007199254740993 9007199254740992) <) '(9007199254740992 9007199254740993))
+             (assert-eq (sort '(36893488147419103233 36893488147419103232) <) '(36893488147419103232 36893488147419103233))
+             (assert-eq (sort '(9007199254740993 9007199254740992.0 1) >) '(9007199254740993 9007199254740992.0 1)))
+
+         (it "sorts vectors and strings"
+             (assert-eq (sort #(3 1 2) <) #(1 2 3))
+             (assert-eq (sort "cab" (lambda (a b) (< (char->integer a) (char->integer b)))) "abc")
+             (assert-eq (sort "cab" char<?) "abc")
+             (assert-eq (sort "cab" char>?) "cba")
+             (assert-eq (sort '(#\b #\a) char<?) '(#\a #\b)))
+
+         (it "sorts with lisp comparators"
+             (assert-eq (sort '(3 1 2) (lambda (a b) (> a b))) '(3 2 1))
+             (assert-eq (sort '(b c a) (lambda (a b) (string<? (str a) (str b)))) '(a b c)))
+
+         (it "sorts by key"
+             (assert-eq (sort '((b . 2) (a . 3) (c . 1)) < key: cdr) '((c . 1) (b . 2) (a . 3)))
+             (assert-eq (sort '("ccc" "a" "bb") < key: string-length) '("a" "bb" "ccc")))
+
+         (it "is stable"
+             (assert-eq (sort '((1 . a) (0 . b) (1 . c) (0 . d)) < key: car)
+                        '((0 . b) (0 . d) (1 . a) (1 . c)))
+             (assert-eq (sort '((1 . a) (0 . b) (1 . c) (0 . d)) (lambda (x y) (< (car x) (car y))))
+                        '((0 . b) (0 . d) (1 . a) (1 . c))))
+
+         (it "reports errors"
+             (assert-error (sort 1 <))
+             (assert-error (sort '(1 2) 1))
+             (assert-error (sort '(1 "a") <))
+             (assert-error (sort '(1 2) < foo: car))))
@@ -0,0 +1,52 @@
+;;; -*- mode: Scheme -*-
+
+(context "string builder"
+
+         ()
+
+         (it builds-strings
+             (define sb (make-string-builder))
+             (assert-true (string-builder? sb))
+             (assert-false (string-builder? "abc"))
+             (assert-eq (string-builder->string sb) "")
+             (string-builder-append! sb "line " 1 #\: #\space 'ok)
+             (string-builder-append! sb (rope "!" "!"))
+             (assert-eq (string-builder->string sb) "line 1: ok!!")
+             (assert-eq (string-builder-length sb) 12))
+
+         (it chains
+             (assert-eq (string-builder->string (string-builder-append! (string-builder-append! (make-string-builder 16) "a") "b")) "ab"))
+
+         (it "copes with huge capacities"
+             (define sb (make-string-builder 100000000000000))
+             (string-builder-append! sb "big")
+             (assert-eq (string-builder->string sb) "big"))
+
+         (it builds-large-strings
+             (define sb (make-string-builder))
+             (do ((i 0 (+ i 1)))
+                 ((= i 1000))
+               (string-builder-append! sb "0123456789"))
+             (assert-eq (string-builder-length sb) 10000)
+             (assert-eq (string-length (string-builder->string sb)) 10000))
+
+         (it resets
+             (define sb (make-string-builder))
+             (string-builder-append! sb "abc")
+             (string-builder-reset! sb)
+             (assert-eq (string-builder-length sb) 0)
+             (string-builder-append! sb "x")
+             (assert-eq (string-builder->string sb) "x"))
+
+         (it prints
+             (define sb (make-string-builder))
+             (string-builder-append! sb "abc")
+             (assert-eq (str sb) "<string-builder: 3 bytes>"))
+
+         (it checks-its-arguments
+             (assert-error (make-string-builder -1))
+             (assert-error (make-string-builder "a"))
+             (assert-error (string-builder-append! "a" "b"))
+             (assert-error (string-builder->string "a"))
+             (assert-error (string-builder-length 1))
+             (assert-error (string-builder-reset! '()))))
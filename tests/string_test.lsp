@@ -59,8 +59,41 @@
                         '("1" "2"))
              (assert-eq (string-split "one,two" ",")
                         '("one" "two"))
+             (assert-eq (string-split "a,b,c,d" "," {limit: 2})
+                        '("a" "b,c,d"))
+             (assert-eq (string-split "a1b22c333d" "[0-9]+" {regexp: #t})
+                        '("a" "b" "c" "d"))
+             (assert-eq (string-split "a1b22c333d" "[0-9]+" {regexp: #t limit: 3})
+                        '("a" "b" "c333d"))
+             (assert-eq (string-split "k=v" #\=)
+                        '("k" "v"))
+             (assert-eq (string-split "a b;c" (char-set " ;"))
+                        '("a" "b" "c"))
+             (assert-eq (string-split "  a   b " (char-set #\space) {omit-empty: #t})
+                        '("a" "b"))
+             (assert-eq (string-split "x;y;z" #\; {limit: 2})
+                        '("x" "y;z"))
+             (assert-eq (string-split "a;;b" #\;)
+                        '("a" "" "b"))
+             (assert-eq (string-split "a,,b" ",")
+                        '("a" "" "b"))
              (assert-error (string-split 3 ""))
-             (assert-error (string-split "" 3)))
+             (assert-error (string-split "" 3))
+             (assert-error (string-split "a" "(" {regexp: #t}))
+             (assert-error (string-split "a" "," {limit: 0}))
+             (assert-error (string-split "a" "," 2)))
+
+         (it string-join
+             (assert-eq (string-join '("a" "b" "c") ", ")
+                        "a, b, c")
+             (assert-eq (string-join '("a" "b"))
+                        "ab")
+             (assert-eq (string-join #("x" "y") "-")
+                        "x-y")
+             (assert-eq (string-join (string-split "a b c" " ") "+")
+                        "a+b+c")
+             (assert-error (string-join '("a" 1) ","))
+             (assert-error (string-join "ab" ",")))
 
          (it string-trim
              (assert-eq (string-trim "  hello ")
@@ -69,6 +102,8 @@
                         " yo ")
              (assert-eq (string-trim "++ yo --" "+- ")
                         "yo")
+             (assert-eq (string-trim "**yo**" #\*)
+                        "yo")
              (assert-error (string-trim 3 ""))
              (assert-error (string-trim "" 3)))
 
@@ -143,6 +178,15 @@
                           "Hello"))
              (assert-error (string-capitalize! 6)))
 
+         (it string-titlecase
+             (assert-eq (string-titlecase "hello wORLD")
+                        "Hello World")
+             (assert-eq (string-titlecase "it's port-2b")
+                        "It's Port-2b")
+             (assert-eq (string-titlecase "")
+                        "")
+             (assert-error (string-titlecase 5)))
+
 
          (it string-length
              (assert-eq (string-length "")
@@ -227,4 +271,18 @@
              (assert-false (string>=? "a" "b"))
              (assert-true (string>=? "a" "a"))
              (assert-true (string>=? "a" "A"))
-             (assert-true (string-ci>=? "a" "A"))))
+             (assert-true (string-ci>=? "a" "A")))
+
+         (it "can compare strings in natural order"
+             (assert-true (string-natural<? "port2" "port10"))
+             (assert-false (string-natural<? "port10" "port2"))
+             (assert-true (string-natural>? "port10" "port2"))
+             (assert-false (string-natural<? "port2" "port2"))
+             (assert-true (string-natural<? "port2" "port02"))
+             (assert-true (string-natural<? "a1b2" "a1b10"))
+             (assert-true (string-natural<? "port" "port1"))
+             (assert-false (string-natural<? "port2" "Port10"))
+             (assert-true (string-natural-ci<? "port2" "Port10"))
+             (assert-eq (sort '("port10" "port9" "port1") string-natural<?)
+                        '("port1" "port9" "port10"))
+             (assert-error (string-natural<? "a" 1))))
@@ -0,0 +1,37 @@
+;;; -*- mode: Scheme -*-
+
+(define device {current-profile: "default" brightness: 50})
+
+(define-symbol-macro device.current-profile (get-slot device current-profile:))
+
+(define-symbol-macro device.brightness (get-slot device brightness:))
+
+(context "symbol macro"
+
+         ()
+
+         (it "expands where the symbol is evaluated"
+             (assert-eq device.current-profile "default")
+             (set-slot! device current-profile: "gaming")
+             (assert-eq device.current-profile "gaming")
+             (assert-eq (+ device.brightness 10) 60))
+
+         (it "expands in the environment it's used in"
+             (let ((device {current-profile: "local" brightness: 0}))
+               (assert-eq device.current-profile "local")))
+
+         (it "is shadowed by local bindings"
+             (let ((device.brightness 1))
+               (assert-eq device.brightness 1))
+             (assert-eq device.brightness 50))
+
+         (it "can stand for a function"
+             (define-symbol-macro first-of car)
+             (assert-eq (first-of '(1 2)) 1))
+
+         (it "shows its expansion"
+             (assert-eq (expand device.brightness) '(get-slot device brightness:)))
+
+         (it "needs a symbol for a name"
+             (assert-error (define-symbol-macro "name" 1))
+             (assert-error (define-symbol-macro name: 1))))
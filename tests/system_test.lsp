@@ -17,4 +17,14 @@
          (it eval
              (assert-eq (+ 1 2) 3)
              (assert-error (5 1 2))
-             (assert-error ('list 1 2))))
+             (assert-error ('list 1 2)))
+
+         (it expand-vars
+             (assert-eq (expand-vars "no variables") "no variables")
+             (assert-eq (expand-vars "${NAME}/$NAME" {NAME: "x"}) "x/x")
+             (assert-eq (expand-vars "${GOLISP_SYSTEM_TEST_UNSET:-fallback}") "fallback")
+             (assert-eq (expand-vars "[$GOLISP_SYSTEM_TEST_UNSET]") "[]")
+             (assert-eq (expand-vars "$$HOME") "$HOME")
+             (assert-eq (expand-vars "n=$n" {n: 3}) "n=3")
+             (assert-error (expand-vars 5))
+             (assert-error (expand-vars "x" '(1)))))
@@ -0,0 +1,57 @@
+;;; -*- mode: Scheme -*-
+
+(define esc "\x1b[")
+
+(context "cursor"
+
+         ()
+
+         (it moves
+             (assert-eq (with-output-to-string (cursor-to 3 10)) (str esc "3;10H"))
+             (assert-eq (with-output-to-string (cursor-up)) (str esc "1A"))
+             (assert-eq (with-output-to-string (cursor-left 4)) (str esc "4D"))
+             (assert-eq (with-output-to-string (cursor-down 0)) "")
+             (assert-error (cursor-to 0 1))
+             (assert-error (cursor-right -1)))
+
+         (it clears
+             (assert-eq (with-output-to-string (clear-line)) (str esc "2K\r"))
+             (assert-eq (with-output-to-string (cursor-hide) (cursor-show)) (str esc "?25l" esc "?25h")))
+
+         (it knows-captured-output-is-not-a-terminal
+             (assert-eq (with-output-to-string (display (terminal?))) "#f")))
+
+(context "progress"
+
+         ()
+
+         (it renders-bars
+             (assert-eq (progress-bar 0 10 {width: 10}) "[>         ]   0%")
+             (assert-eq (progress-bar 5 10 {width: 10 label: "flashing"}) "[=====>    ]  50% flashing")
+             (assert-eq (progress-bar 12 10 {width: 4}) "[====] 100%")
+             (assert-error (progress-bar 1 0))
+             (assert-error (progress-bar 1 10 {width: 0}))
+             (assert-error (progress-bar 1 10 {width: 100000000000})))
+
+         (it draws-over-the-line
+             (assert-eq (with-output-to-string (show-progress 1 2 {width: 2}))
+                        (str "\r" esc "2K[=>]  50%"))
+             (assert-eq (with-output-to-string (show-progress 2 2 {width: 2}))
+                        (str "\r" esc "2K[==] 100%\n"))))
+
+(context "tables"
+
+         ()
+
+         (it aligns-columns
+             (assert-eq (format-table '((a 1) (bbb 22)))
+                        "a    1\nbbb  22\n")
+             (assert-eq (format-table (vector #("x" 100) '("yy" 5)) {header: '("name" "count") align: '(left right)})
+                        "name  count\n----  -----\nx       100\nyy        5\n"))
+
+         (it prints
+             (assert-eq (with-output-to-string (print-table '((1 2)))) "1  2\n"))
+
+         (it rejects-bad-rows
+             (assert-error (format-table 5))
+             (assert-error (format-table '(5)))))
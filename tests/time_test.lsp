@@ -0,0 +1,103 @@
+;;; -*- mode: Scheme -*-
+
+(context "durations"
+
+         ()
+
+         (it "can be made from units"
+             (assert-true (duration? (minutes 5)))
+             (assert-false (duration? 300000))
+             (assert-eq (duration->milliseconds (minutes 5)) 300000)
+             (assert-eq (duration->milliseconds (seconds 1.5)) 1500)
+             (assert-eq (duration->milliseconds (hours 1)) 3600000)
+             (assert-eq (duration->milliseconds (days 1)) 86400000)
+             (assert-eq (duration->milliseconds (milliseconds 20)) 20)
+             (assert-eq (duration->seconds (milliseconds 500)) 0.5)
+             (assert-error (minutes "5")))
+
+         (it "can be parsed"
+             (assert-eq (duration "1h30m") (minutes 90))
+             (assert-eq (duration 250) (milliseconds 250))
+             (assert-eq (str (duration "90s")) "1m30s")
+             (assert-error (duration "soon"))
+             (assert-error (duration 'a)))
+
+         (it "supports arithmetic"
+             (assert-eq (duration+ (hours 1) (minutes 30)) (duration "1h30m"))
+             (assert-eq (duration+) (milliseconds 0))
+             (assert-eq (duration- (hours 1) (minutes 15) "5m") (minutes 40))
+             (assert-eq (duration- (seconds 1)) (seconds -1)))
+
+         (it "can be used when sleeping and scheduling"
+             (assert-nil (sleep (milliseconds 1)))
+             (define job (schedule every: (milliseconds 5) (lambda (job) ())))
+             (assert-true (job? job))
+             (cancel-job! job)
+             (assert-error (schedule every: (seconds -1) (lambda (job) ())))))
+
+(context "times"
+
+         ()
+
+         (it "can be parsed and formatted"
+             (define t (parse-time "2015-03-01T12:30:00Z"))
+             (assert-true (time? t))
+             (assert-false (time? "2015-03-01T12:30:00Z"))
+             (assert-eq (format-time t) "2015-03-01T12:30:00Z")
+             (assert-eq (format-time t 'date) "2015-03-01")
+             (assert-eq (format-time t date-time:) "2015-03-01 12:30:00")
+             (assert-eq (format-time t "Jan 2, 2006 at 3:04pm") "Mar 1, 2015 at 12:30pm")
+             (assert-eq (format-time (parse-time "2015-03-01 08:15:00" 'date-time) 'kitchen) "8:15AM")
+             (assert-eq (str t) "2015-03-01T12:30:00Z")
+             (assert-error (parse-time "yesterday"))
+             (assert-error (parse-time "2015-03-01" 'no-such-layout))
+             (assert-error (format-time "2015-03-01")))
+
+         (it "supports arithmetic with durations"
+             (define t (parse-time "2015-03-01T12:30:00Z"))
+             (assert-eq (format-time (time+ t (hours 2) (minutes 15))) "2015-03-01T14:45:00Z")
+             (assert-eq (format-time (time- t (days 1))) "2015-02-28T12:30:00Z")
+             (assert-eq (time- (time+ t (seconds 10)) t) (seconds 10))
+             (assert-error (time+ t 'a)))
+
+         (it "compares"
+             (define t (parse-time "2015-03-01T12:30:00Z"))
+             (assert-true (time-before? t (time+ t (seconds 1))))
+             (assert-false (time-after? t (time+ t (seconds 1))))
+             (assert-true (equal? t (parse-time "2015-03-01T13:30:00+01:00"))))
+
+         (it "converts to and from milliseconds"
+             (define t (parse-time "2015-03-01T12:30:00Z"))
+             (assert-eq (time->milliseconds t) 1425213000000)
+             (assert-true (equal? (milliseconds->time 1425213000000) t))
+             (assert-true (time? (now)))))
+
+(context "timezones"
+
+         ()
+
+         (it "converts between timezones"
+             (define t (parse-time "2015-07-01T12:00:00Z"))
+             (define cph (in-timezone t "Europe/Copenhagen"))
+             (assert-eq (format-time cph) "2015-07-01T14:00:00+02:00")
+             (assert-eq (time-zone cph) "Europe/Copenhagen")
+             (assert-eq (time-zone-offset cph) (hours 2))
+             (assert-eq (time-zone-offset (in-timezone (parse-time "2015-01-01T12:00:00Z") "Europe/Copenhagen")) (hours 1))
+             (assert-true (equal? cph t))
+             (assert-true (string? (local-timezone)))
+             (assert-eq (time-zone-offset (in-timezone t (local-timezone))) (time-zone-offset (in-timezone t "Local")))
+             (assert-error (in-timezone t "Nowhere/Special"))
+             (assert-error (in-timezone t 42)))
+
+         (it "parses and builds times in a timezone"
+             (assert-eq (format-time (parse-time "2015-07-01 12:00:00" 'date-time "America/New_York")) "2015-07-01T12:00:00-04:00")
+             (assert-eq (format-time (make-time 2015 3 1 9 30 0 "UTC")) "2015-03-01T09:30:00Z")
+             (assert-eq (format-time (make-time 2015 7 1 9 30 0 "Europe/Copenhagen")) "2015-07-01T09:30:00+02:00")
+             (assert-error (make-time 2015 3 1 9 "30" 0)))
+
+         (it "adds calendar days across DST changes"
+             (define before (make-time 2015 3 28 12 0 0 "Europe/Copenhagen"))
+             (assert-eq (format-time (time-add-date before 0 0 1)) "2015-03-29T12:00:00+02:00")
+             (assert-eq (time- (time-add-date before 0 0 1) before) (hours 23))
+             (assert-eq (format-time (time+ before (days 1))) "2015-03-29T13:00:00+02:00")
+             (assert-eq (format-time (time-add-date before 1 1 0)) "2016-04-28T12:00:00+02:00")))
@@ -0,0 +1,102 @@
+;;; -*- mode: Scheme -*-
+
+(context "vectors"
+
+         ()
+
+         (it "reads and writes vector literals"
+             (assert-true (vector? #(1 2 3)))
+             (assert-false (vector? '(1 2 3)))
+             (assert-eq (str #(1 "two" #\3)) "#(1 \"two\" #\\3)"))
+
+         (it "creates vectors"
+             (assert-eq (vector 1 2 3) #(1 2 3))
+             (assert-eq (make-vector 2 'a) #(a a))
+             (assert-eq (vector-length (make-vector 3)) 3)
+             (assert-error (make-vector -1))
+             (assert-error (make-vector 100000000000000)))
+
+         (it "accesses elements"
+             (define v (vector 1 2 3))
+             (assert-eq (vector-ref v 1) 2)
+             (vector-set! v 1 'b)
+             (assert-eq v #(1 b 3))
+             (assert-error (vector-ref v 3))
+             (assert-error (vector-ref '(1 2) 0)))
+
+         (it "converts to and from lists"
+             (assert-eq (vector->list #(1 2 3)) '(1 2 3))
+             (assert-eq (list->vector '(1 2 3)) #(1 2 3))
+             (assert-eq (list->vector (acons 'a 1)) #((a . 1)))
+             (assert-error (list->vector '(1 2 . 3)))
+             (assert-eq (vector->list #()) '()))
+
+         (it "appends"
+             (let ((v #(1 2)))
+               (assert-eq (vector-append v #() #(3) #(4 5)) #(1 2 3 4 5))
+               (assert-eq v #(1 2)))
+             (assert-eq (vector-append) #())
+             (assert-eq (vector-concatenate (list #(1) #(2 3))) #(1 2 3))
+             (assert-eq (vector-concatenate '()) #())
+             (assert-error (vector-append #(1) '(2)))
+             (assert-error (vector-concatenate #(1))))
+
+         (it "finds the index of an element"
+             (assert-eq (vector-index even? #(1 3 4 6)) 2)
+             (assert-eq (vector-index even? #(1 3 5)) #f)
+             (assert-eq (vector-index not #(1 #f 3)) 1)
+             (assert-eq (vector-index < #(3 2 1) #(1 2 3 4)) 2)
+             (assert-eq (vector-index < #(3 2 1 0) #(0 0 0)) #f)
+             (assert-error (vector-index even? '(1 2))))
+
+         (it "counts elements"
+             (assert-eq (vector-count even? #(1 2 3 4)) 2)
+             (assert-eq (vector-count even? #()) 0)
+             (assert-eq (vector-count < #(1 5 2) #(2 3 4 0)) 2)
+             (assert-error (vector-count 1 #(1))))
+
+         (it "grows and shrinks in place"
+             (let ((v (make-vector 0)))
+               (vector-push! v 1)
+               (vector-push! v 2 3)
+               (assert-eq v #(1 2 3))
+               (assert-true (>= (vector-capacity v) 3))
+               (assert-eq (vector-pop! v) 3)
+               (assert-eq v #(1 2))
+               (vector-extend! v #(4 5))
+               (vector-extend! v '(6))
+               (assert-eq v #(1 2 4 5 6))
+               (assert-eq (vector-length v) 5)))
+
+         (it "errors appropriately when growing and shrinking"
+             (assert-error (vector-pop! (make-vector 0)))
+             (assert-error (vector-push! '(1) 2))
+             (assert-error (vector-extend! #(1) 2))
+             (assert-error (vector-capacity '()))))
+
+(context "characters"
+
+         ()
+
+         (it "reads and writes character literals"
+             (assert-true (char? #\a))
+             (assert-false (char? "a"))
+             (assert-eq (format #f "~s" #\space) "#\\space")
+             (assert-eq (format #f "~s" #\a) "#\\a")
+             (assert-eq (str #\a) "a"))
+
+         (it "converts to and from integers"
+             (assert-eq (char->integer #\A) 65)
+             (assert-eq (integer->char 97) #\a)
+             (assert-eq (integer->char 10) #\newline)
+             (assert-error (char->integer 65)))
+
+         (it "compares characters"
+             (assert-true (char<? #\a #\b))
+             (assert-false (char<? #\b #\a))
+             (assert-true (char>? #\b #\a))
+             (assert-true (char<=? #\a #\a))
+             (assert-true (char>=? #\b #\a))
+             (assert-true (char=? #\a #\a))
+             (assert-false (char=? #\a #\A))
+             (assert-error (char<? #\a 1))))
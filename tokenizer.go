@@ -12,6 +12,7 @@ import (
 	"github.com/SteelSeries/bufrr"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 	"unicode"
 )
@@ -37,25 +38,40 @@ const (
 	PERIOD
 	TRUE
 	FALSE
+	CHARACTER
+	HASHLPAREN
+	HASHU8LPAREN
+	HASHLBRACKET
+	LABELDEF
+	LABELREF
 	COMMENT
 	EOF
 )
 
 type Tokenizer struct {
-	LookaheadToken int
-	LookaheadLit   string
-	Source         *bufrr.Reader
-	CurrentCh      rune
-	NextCh         rune
-	Eof            bool
-	AlmostEof      bool
+	LookaheadToken  int
+	LookaheadLit    string
+	Source          *bufrr.Reader
+	CurrentCh       rune
+	NextCh          rune
+	Eof             bool
+	AlmostEof       bool
+	Labels          map[string]*Data
+	File            string
+	line            int
+	column          int
+	tokenLine       int
+	tokenColumn     int
+	lookaheadLine   int
+	lookaheadColumn int
+	inShorthand     bool
 }
 
 var mostRecentFileTokenizer *Tokenizer
 var mostRecentlyUsedFile *os.File
 
 func NewTokenizer(scanner *bufrr.Reader) *Tokenizer {
-	t := &Tokenizer{Source: scanner}
+	t := &Tokenizer{Source: scanner, line: 1}
 	t.Advance()
 	t.ConsumeToken()
 	return t
@@ -76,8 +92,22 @@ func NewTokenizerFromFile(src *os.File) *Tokenizer {
 	}
 }
 
+// NewTokenizerFromFileContents tokenizes src, which was read from filename, so that the
+// lists it contains know where they came from
+func NewTokenizerFromFileContents(src string, filename string) *Tokenizer {
+	t := NewTokenizerFromString(src)
+	t.File = filename
+	return t
+}
+
 func (self *Tokenizer) Advance() {
 	var err error
+	if self.CurrentCh == '\n' {
+		self.line += 1
+		self.column = 1
+	} else {
+		self.column += 1
+	}
 	self.CurrentCh, _, err = self.Source.ReadRune()
 	if err == io.EOF || self.CurrentCh == -1 {
 		self.Eof = true
@@ -163,10 +193,26 @@ func (self *Tokenizer) readNumber() (token int, lit string) {
 	buffer := make([]rune, 0, 1)
 	isFloat := false
 	sawDecimal := false
+	sawExponent := false
+	sawDigit := false
 	firstChar := true
 	for !self.isEof() {
 		ch := rune(self.CurrentCh)
-		if ch == '.' && !sawDecimal {
+		if (ch == 'e' || ch == 'E') && sawDigit && !sawExponent && (unicode.IsDigit(self.NextCh) || self.NextCh == '+' || self.NextCh == '-') {
+			// scientific notation, e.g. 1.5e-3
+			isFloat = true
+			sawDecimal = true
+			sawExponent = true
+			buffer = append(buffer, self.CurrentCh)
+			self.Advance()
+			if self.CurrentCh == '+' || self.CurrentCh == '-' {
+				buffer = append(buffer, self.CurrentCh)
+				self.Advance()
+			}
+			if self.isEof() || !unicode.IsDigit(self.CurrentCh) {
+				return ILLEGAL, string(buffer)
+			}
+		} else if ch == '.' && !sawDecimal {
 			isFloat = true
 			sawDecimal = true
 			buffer = append(buffer, self.CurrentCh)
@@ -175,6 +221,7 @@ func (self *Tokenizer) readNumber() (token int, lit string) {
 			buffer = append(buffer, self.CurrentCh)
 			self.Advance()
 		} else if unicode.IsNumber(ch) {
+			sawDigit = true
 			buffer = append(buffer, self.CurrentCh)
 			self.Advance()
 		} else {
@@ -192,15 +239,48 @@ func (self *Tokenizer) readNumber() (token int, lit string) {
 	return
 }
 
+func (self *Tokenizer) readHexEscape(digits int) (ch rune, ok bool) {
+	buffer := make([]rune, 0, digits)
+	for i := 0; i < digits; i++ {
+		self.Advance()
+		if self.isEof() || !isHexChar(self.CurrentCh) {
+			return 0, false
+		}
+		buffer = append(buffer, self.CurrentCh)
+	}
+	n, err := strconv.ParseInt(string(buffer), 16, 32)
+	if err != nil {
+		return 0, false
+	}
+	return rune(n), true
+}
+
 func (self *Tokenizer) readString() (token int, lit string) {
 	buffer := make([]rune, 0, 10)
 	self.Advance()
 	for !self.isEof() && rune(self.CurrentCh) != '"' {
 		if rune(self.CurrentCh) == '\\' {
 			self.Advance()
-			if rune(self.CurrentCh) == 'n' {
+			switch rune(self.CurrentCh) {
+			case 'n':
 				buffer = append(buffer, '\n')
-			} else {
+			case 't':
+				buffer = append(buffer, '\t')
+			case 'r':
+				buffer = append(buffer, '\r')
+			case 'x':
+				ch, ok := self.readHexEscape(2)
+				if !ok {
+					return ILLEGAL, "\\x"
+				}
+				buffer = append(buffer, ch)
+			case 'u':
+				ch, ok := self.readHexEscape(4)
+				if !ok {
+					return ILLEGAL, "\\u"
+				}
+				buffer = append(buffer, ch)
+			default:
 				buffer = append(buffer, rune(self.CurrentCh))
 			}
 			self.Advance()
@@ -216,6 +296,41 @@ func (self *Tokenizer) readString() (token int, lit string) {
 	return STRING, string(buffer)
 }
 
+func (self *Tokenizer) readCharacter() (token int, lit string) {
+	self.Advance()
+	if self.isEof() {
+		return ILLEGAL, "#\\"
+	}
+	first := self.CurrentCh
+	buffer := []rune{first}
+	self.Advance()
+	if unicode.IsLetter(first) {
+		for !self.isEof() && self.isSymbolCharacter(self.CurrentCh) {
+			buffer = append(buffer, self.CurrentCh)
+			self.Advance()
+		}
+	}
+	return CHARACTER, string(buffer)
+}
+
+func (self *Tokenizer) readLabel() (token int, lit string) {
+	buffer := make([]rune, 0, 1)
+	for !self.isEof() && unicode.IsDigit(self.CurrentCh) {
+		buffer = append(buffer, self.CurrentCh)
+		self.Advance()
+	}
+	switch self.CurrentCh {
+	case '=':
+		self.Advance()
+		return LABELDEF, string(buffer)
+	case '#':
+		self.Advance()
+		return LABELREF, string(buffer)
+	default:
+		return ILLEGAL, fmt.Sprintf("#%s%c", string(buffer), self.CurrentCh)
+	}
+}
+
 func (self *Tokenizer) isEof() bool {
 	return self.Eof
 }
@@ -234,6 +349,7 @@ func (self *Tokenizer) readNextToken() (token int, lit string) {
 			return EOF, ""
 		}
 	}
+	self.tokenLine, self.tokenColumn = self.line, self.column
 
 	if self.CurrentCh == '0' && self.NextCh == 'x' {
 		self.Advance()
@@ -295,6 +411,24 @@ func (self *Tokenizer) readNextToken() (token int, lit string) {
 		} else if self.CurrentCh == 'b' {
 			self.Advance()
 			return self.readBinaryNumber()
+		} else if self.CurrentCh == '\\' {
+			return self.readCharacter()
+		} else if self.CurrentCh == '(' {
+			self.Advance()
+			return HASHLPAREN, "#("
+		} else if self.CurrentCh == '[' {
+			self.Advance()
+			return HASHLBRACKET, "#["
+		} else if self.CurrentCh == 'u' && self.NextCh == '8' {
+			self.Advance()
+			self.Advance()
+			if self.CurrentCh != '(' {
+				return ILLEGAL, fmt.Sprintf("#u8%c", self.CurrentCh)
+			}
+			self.Advance()
+			return HASHU8LPAREN, "#u8("
+		} else if unicode.IsDigit(self.CurrentCh) {
+			return self.readLabel()
 		} else {
 			return ILLEGAL, fmt.Sprintf("#%c", self.NextCh)
 		}
@@ -315,8 +449,14 @@ func (self *Tokenizer) readNextToken() (token int, lit string) {
 	}
 }
 
+// LookaheadPosition returns where the lookahead token starts
+func (self *Tokenizer) LookaheadPosition() *SourcePosition {
+	return &SourcePosition{File: self.File, Line: self.lookaheadLine, Column: self.lookaheadColumn}
+}
+
 func (self *Tokenizer) ConsumeToken() {
 	self.LookaheadToken, self.LookaheadLit = self.readNextToken()
+	self.lookaheadLine, self.lookaheadColumn = self.tokenLine, self.tokenColumn
 	if self.LookaheadToken == COMMENT { // skip comments
 		self.ConsumeToken()
 	}
@@ -163,6 +163,33 @@ func (s *TokenizerSuite) TestStringWithEscapedChar(c *C) {
 	c.Assert(lit, Equals, `hi"`)
 }
 
+func (s *TokenizerSuite) TestStringWithControlEscapes(c *C) {
+	t := NewTokenizerFromString(`"a\tb\nc\rd\\e" a`)
+	tok, lit := t.NextToken()
+	c.Assert(tok, Equals, STRING)
+	c.Assert(lit, Equals, "a\tb\nc\rd\\e")
+}
+
+func (s *TokenizerSuite) TestStringWithHexEscape(c *C) {
+	t := NewTokenizerFromString(`"\x41\x1b[0m" a`)
+	tok, lit := t.NextToken()
+	c.Assert(tok, Equals, STRING)
+	c.Assert(lit, Equals, "A\x1b[0m")
+}
+
+func (s *TokenizerSuite) TestStringWithUnicodeEscape(c *C) {
+	t := NewTokenizerFromString(`"\u00e9t\u00e9" a`)
+	tok, lit := t.NextToken()
+	c.Assert(tok, Equals, STRING)
+	c.Assert(lit, Equals, "été")
+}
+
+func (s *TokenizerSuite) TestStringWithBadHexEscape(c *C) {
+	t := NewTokenizerFromString(`"\x4g" a`)
+	tok, _ := t.NextToken()
+	c.Assert(tok, Equals, ILLEGAL)
+}
+
 func (s *TokenizerSuite) TestQuote(c *C) {
 	t := NewTokenizerFromString(`'a`)
 	tok, lit := t.NextToken()
@@ -337,3 +364,34 @@ func (s *TokenizerSuite) TestTrue(c *C) {
 	c.Assert(tok, Equals, TRUE)
 	c.Assert(lit, Equals, `#t`)
 }
+
+func (s *TokenizerSuite) TestCharacter(c *C) {
+	t := NewTokenizerFromString(`#\a b`)
+	tok, lit := t.NextToken()
+	c.Assert(tok, Equals, CHARACTER)
+	c.Assert(lit, Equals, `a`)
+}
+
+func (s *TokenizerSuite) TestNamedCharacter(c *C) {
+	t := NewTokenizerFromString(`#\newline)`)
+	tok, lit := t.NextToken()
+	c.Assert(tok, Equals, CHARACTER)
+	c.Assert(lit, Equals, `newline`)
+	t.ConsumeToken()
+	tok, _ = t.NextToken()
+	c.Assert(tok, Equals, RPAREN)
+}
+
+func (s *TokenizerSuite) TestVectorStart(c *C) {
+	t := NewTokenizerFromString(`#(1 2)`)
+	tok, lit := t.NextToken()
+	c.Assert(tok, Equals, HASHLPAREN)
+	c.Assert(lit, Equals, `#(`)
+}
+
+func (s *TokenizerSuite) TestBytevectorStart(c *C) {
+	t := NewTokenizerFromString(`#u8(1 2)`)
+	tok, lit := t.NextToken()
+	c.Assert(tok, Equals, HASHU8LPAREN)
+	c.Assert(lit, Equals, `#u8(`)
+}
@@ -0,0 +1,661 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements reading TOML documents into frames.
+
+package golisp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// TomlToLisp reads a TOML document into a frame, with the same mapping as json->lisp: tables
+// (including inline ones) are frames, arrays are lists, and arrays of tables are lists of
+// frames.  Integers become integers, and ones that don't fit in 64 bits are errors, as TOML
+// requires.  Floats become floats if that loses nothing, or decimals otherwise.  Offset and local date-times and local dates become times (local ones
+// in UTC), and local times stay strings.
+func TomlToLisp(text string) (result *Data, err error) {
+	parser := &tomlParser{text: text, line: 1}
+	root, err := parser.parseDocument()
+	if err != nil {
+		return
+	}
+	return root.toLisp(), nil
+}
+
+// tomlTable is a table being built.  Tables made implicitly, as the parents of a [a.b]
+// header, can still be defined by a header of their own later; ones made by dotted keys or
+// written inline can't.
+type tomlTable struct {
+	values   map[string]interface{}
+	explicit bool
+	dotted   bool
+	inline   bool
+}
+
+// tomlTableArray is an array of tables, made by [[a.b]] headers
+type tomlTableArray struct {
+	tables []*tomlTable
+}
+
+func newTomlTable() *tomlTable {
+	return &tomlTable{values: make(map[string]interface{})}
+}
+
+func (self *tomlTable) toLisp() *Data {
+	m := FrameMap{Data: make(FrameMapData, len(self.values))}
+	for key, value := range self.values {
+		m.Data[key+":"] = tomlValueToLisp(value)
+	}
+	return FrameWithValue(&m)
+}
+
+func tomlValueToLisp(value interface{}) *Data {
+	switch v := value.(type) {
+	case *tomlTable:
+		return v.toLisp()
+	case *tomlTableArray:
+		items := make([]*Data, 0, len(v.tables))
+		for _, table := range v.tables {
+			items = append(items, table.toLisp())
+		}
+		return ArrayToList(items)
+	case []interface{}:
+		items := make([]*Data, 0, len(v))
+		for _, item := range v {
+			items = append(items, tomlValueToLisp(item))
+		}
+		return ArrayToList(items)
+	case string:
+		return StringWithValue(v)
+	case int64:
+		return IntegerWithValue(v)
+	case float64:
+		return tomlFloatToLisp(v)
+	case bool:
+		return BooleanWithValue(v)
+	case time.Time:
+		return TimeWithValue(v)
+	}
+	return nil
+}
+
+// tomlFloatToLisp makes a float of f if it fits exactly in a lisp float, or else a decimal so
+// that settings such as 0.1 or 16777217.0 keep every digit they were written with
+func tomlFloatToLisp(f float64) *Data {
+	if float64(float32(f)) == f || math.IsNaN(f) || math.IsInf(f, 0) {
+		return FloatWithValue(float32(f))
+	}
+	d, err := ParseDecimal(strconv.FormatFloat(f, 'g', -1, 64))
+	if err != nil {
+		return FloatWithValue(float32(f))
+	}
+	return DecimalWithValue(d)
+}
+
+type tomlParser struct {
+	text string
+	pos  int
+	line int
+}
+
+func (self *tomlParser) errorf(format string, args ...interface{}) error {
+	return NewLispError(ParseError, fmt.Sprintf("Bad TOML at line %d: %s", self.line, fmt.Sprintf(format, args...)))
+}
+
+func (self *tomlParser) atEnd() bool {
+	return self.pos >= len(self.text)
+}
+
+func (self *tomlParser) peek() byte {
+	if self.atEnd() {
+		return 0
+	}
+	return self.text[self.pos]
+}
+
+func (self *tomlParser) lookingAt(prefix string) bool {
+	return strings.HasPrefix(self.text[self.pos:], prefix)
+}
+
+func (self *tomlParser) advance(n int) {
+	self.line += strings.Count(self.text[self.pos:self.pos+n], "\n")
+	self.pos += n
+}
+
+func (self *tomlParser) skipSpaces() {
+	for self.peek() == ' ' || self.peek() == '\t' {
+		self.pos++
+	}
+}
+
+func (self *tomlParser) skipComment() {
+	if self.peek() == '#' {
+		for !self.atEnd() && self.peek() != '\n' {
+			self.pos++
+		}
+	}
+}
+
+// skipBlank skips whitespace, comments, and newlines, as allowed between array elements
+func (self *tomlParser) skipBlank() {
+	for {
+		self.skipSpaces()
+		self.skipComment()
+		if self.lookingAt("\r\n") {
+			self.advance(2)
+		} else if self.peek() == '\n' {
+			self.advance(1)
+		} else {
+			return
+		}
+	}
+}
+
+// endOfLine requires the rest of the line to be blank or a comment
+func (self *tomlParser) endOfLine() error {
+	self.skipSpaces()
+	self.skipComment()
+	if self.lookingAt("\r\n") {
+		self.advance(2)
+	} else if self.peek() == '\n' {
+		self.advance(1)
+	} else if !self.atEnd() {
+		return self.errorf("unexpected %q after value", self.peek())
+	}
+	return nil
+}
+
+func (self *tomlParser) parseDocument() (root *tomlTable, err error) {
+	root = newTomlTable()
+	current := root
+	for {
+		self.skipBlank()
+		if self.atEnd() {
+			return
+		}
+		if self.lookingAt("[[") {
+			self.advance(2)
+			current, err = self.parseTableArrayHeader(root)
+		} else if self.peek() == '[' {
+			self.advance(1)
+			current, err = self.parseTableHeader(root)
+		} else {
+			err = self.parseKeyValue(current)
+		}
+		if err == nil {
+			err = self.endOfLine()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// descend returns the table called key in table, creating it if need be; arrays of tables
+// lead to their last table
+func (self *tomlParser) descend(table *tomlTable, key string, dotted bool) (*tomlTable, error) {
+	switch existing := table.values[key].(type) {
+	case nil:
+		child := newTomlTable()
+		child.dotted = dotted
+		table.values[key] = child
+		return child, nil
+	case *tomlTable:
+		if existing.inline || (dotted && existing.explicit) {
+			return nil, self.errorf("can't add keys to table %s", key)
+		}
+		return existing, nil
+	case *tomlTableArray:
+		if dotted {
+			return nil, self.errorf("can't add keys to array of tables %s", key)
+		}
+		return existing.tables[len(existing.tables)-1], nil
+	default:
+		return nil, self.errorf("%s is already a value, not a table", key)
+	}
+}
+
+func (self *tomlParser) parseHeaderKeys(closing string) (keys []string, err error) {
+	self.skipSpaces()
+	keys, err = self.parseKey()
+	if err != nil {
+		return
+	}
+	self.skipSpaces()
+	if !self.lookingAt(closing) {
+		return nil, self.errorf("expected %s to end the table header", closing)
+	}
+	self.advance(len(closing))
+	return
+}
+
+func (self *tomlParser) parseTableHeader(root *tomlTable) (table *tomlTable, err error) {
+	keys, err := self.parseHeaderKeys("]")
+	if err != nil {
+		return
+	}
+	parent := root
+	for _, key := range keys[:len(keys)-1] {
+		if parent, err = self.descend(parent, key, false); err != nil {
+			return
+		}
+	}
+	last := keys[len(keys)-1]
+	switch existing := parent.values[last].(type) {
+	case nil:
+		table = newTomlTable()
+		parent.values[last] = table
+	case *tomlTable:
+		if existing.explicit || existing.dotted || existing.inline {
+			return nil, self.errorf("table %s is defined more than once", strings.Join(keys, "."))
+		}
+		table = existing
+	default:
+		return nil, self.errorf("%s is already a value, not a table", strings.Join(keys, "."))
+	}
+	table.explicit = true
+	return
+}
+
+func (self *tomlParser) parseTableArrayHeader(root *tomlTable) (table *tomlTable, err error) {
+	keys, err := self.parseHeaderKeys("]]")
+	if err != nil {
+		return
+	}
+	parent := root
+	for _, key := range keys[:len(keys)-1] {
+		if parent, err = self.descend(parent, key, false); err != nil {
+			return
+		}
+	}
+	last := keys[len(keys)-1]
+	table = newTomlTable()
+	table.explicit = true
+	switch existing := parent.values[last].(type) {
+	case nil:
+		parent.values[last] = &tomlTableArray{tables: []*tomlTable{table}}
+	case *tomlTableArray:
+		existing.tables = append(existing.tables, table)
+	default:
+		return nil, self.errorf("%s is already a value, not an array of tables", strings.Join(keys, "."))
+	}
+	return
+}
+
+func (self *tomlParser) parseKeyValue(table *tomlTable) (err error) {
+	keys, err := self.parseKey()
+	if err != nil {
+		return
+	}
+	self.skipSpaces()
+	if self.peek() != '=' {
+		return self.errorf("expected = after key %s", strings.Join(keys, "."))
+	}
+	self.advance(1)
+	self.skipSpaces()
+	value, err := self.parseValue()
+	if err != nil {
+		return
+	}
+	for _, key := range keys[:len(keys)-1] {
+		if table, err = self.descend(table, key, true); err != nil {
+			return
+		}
+	}
+	last := keys[len(keys)-1]
+	if _, found := table.values[last]; found {
+		return self.errorf("key %s is defined more than once", strings.Join(keys, "."))
+	}
+	table.values[last] = value
+	return
+}
+
+// parseKey parses a possibly dotted key made of bare and quoted parts
+func (self *tomlParser) parseKey() (keys []string, err error) {
+	for {
+		self.skipSpaces()
+		var key string
+		switch self.peek() {
+		case '"':
+			key, err = self.parseBasicString()
+		case '\'':
+			key, err = self.parseLiteralString()
+		default:
+			start := self.pos
+			for !self.atEnd() && isTomlBareKeyChar(self.peek()) {
+				self.pos++
+			}
+			if start == self.pos {
+				return nil, self.errorf("expected a key")
+			}
+			key = self.text[start:self.pos]
+		}
+		if err != nil {
+			return
+		}
+		keys = append(keys, key)
+		self.skipSpaces()
+		if self.peek() != '.' {
+			return
+		}
+		self.advance(1)
+	}
+}
+
+func isTomlBareKeyChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '-'
+}
+
+func (self *tomlParser) parseValue() (value interface{}, err error) {
+	switch {
+	case self.lookingAt(`"""`):
+		return self.parseMultilineBasicString()
+	case self.peek() == '"':
+		return self.parseBasicString()
+	case self.lookingAt("'''"):
+		return self.parseMultilineLiteralString()
+	case self.peek() == '\'':
+		return self.parseLiteralString()
+	case self.peek() == '[':
+		return self.parseArray()
+	case self.peek() == '{':
+		return self.parseInlineTable()
+	case self.lookingAt("true"):
+		self.advance(4)
+		return true, nil
+	case self.lookingAt("false"):
+		self.advance(5)
+		return false, nil
+	default:
+		return self.parseScalar()
+	}
+}
+
+func (self *tomlParser) parseLiteralString() (string, error) {
+	end := strings.IndexAny(self.text[self.pos+1:], "'\n")
+	if end == -1 || self.text[self.pos+1+end] != '\'' {
+		return "", self.errorf("unterminated string")
+	}
+	s := self.text[self.pos+1 : self.pos+1+end]
+	self.advance(end + 2)
+	return s, nil
+}
+
+func (self *tomlParser) parseMultilineLiteralString() (string, error) {
+	self.advance(3)
+	end := strings.Index(self.text[self.pos:], "'''")
+	if end == -1 {
+		return "", self.errorf("unterminated string")
+	}
+	s := self.text[self.pos : self.pos+end]
+	self.advance(end + 3)
+	return trimFirstNewline(s), nil
+}
+
+func trimFirstNewline(s string) string {
+	if strings.HasPrefix(s, "\r\n") {
+		return s[2:]
+	}
+	return strings.TrimPrefix(s, "\n")
+}
+
+func (self *tomlParser) parseBasicString() (string, error) {
+	self.advance(1)
+	var b strings.Builder
+	for {
+		if self.atEnd() || self.peek() == '\n' {
+			return "", self.errorf("unterminated string")
+		}
+		c := self.peek()
+		if c == '"' {
+			self.advance(1)
+			return b.String(), nil
+		}
+		if c == '\\' {
+			if err := self.parseEscape(&b); err != nil {
+				return "", err
+			}
+			continue
+		}
+		b.WriteByte(c)
+		self.pos++
+	}
+}
+
+func (self *tomlParser) parseMultilineBasicString() (string, error) {
+	self.advance(3)
+	if self.lookingAt("\r\n") {
+		self.advance(2)
+	} else if self.peek() == '\n' {
+		self.advance(1)
+	}
+	var b strings.Builder
+	for {
+		if self.atEnd() {
+			return "", self.errorf("unterminated string")
+		}
+		if self.lookingAt(`"""`) {
+			self.advance(3)
+			// up to two more quotes right before the closing ones belong to the string
+			for i := 0; i < 2 && self.peek() == '"'; i++ {
+				b.WriteByte('"')
+				self.advance(1)
+			}
+			return b.String(), nil
+		}
+		c := self.peek()
+		if c == '\\' {
+			// a backslash at the end of a line trims the newline and leading whitespace after it
+			rest := strings.TrimLeft(self.text[self.pos+1:], " \t")
+			if strings.HasPrefix(rest, "\n") || strings.HasPrefix(rest, "\r\n") {
+				self.advance(1)
+				for strings.ContainsRune(" \t\r\n", rune(self.peek())) && !self.atEnd() {
+					self.advance(1)
+				}
+				continue
+			}
+			if err := self.parseEscape(&b); err != nil {
+				return "", err
+			}
+			continue
+		}
+		b.WriteByte(c)
+		self.advance(1)
+	}
+}
+
+func (self *tomlParser) parseEscape(b *strings.Builder) error {
+	if self.pos+1 >= len(self.text) {
+		return self.errorf("unterminated string")
+	}
+	escape := self.text[self.pos+1]
+	self.advance(2)
+	switch escape {
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case 'e':
+		b.WriteByte('\x1b')
+	case '"':
+		b.WriteByte('"')
+	case '\\':
+		b.WriteByte('\\')
+	case 'u', 'U':
+		digits := 4
+		if escape == 'U' {
+			digits = 8
+		}
+		if self.pos+digits > len(self.text) {
+			return self.errorf("short unicode escape")
+		}
+		code, err := strconv.ParseUint(self.text[self.pos:self.pos+digits], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return self.errorf("bad unicode escape \\%c%s", escape, self.text[self.pos:self.pos+digits])
+		}
+		b.WriteRune(rune(code))
+		self.advance(digits)
+	default:
+		return self.errorf("bad escape \\%c", escape)
+	}
+	return nil
+}
+
+func (self *tomlParser) parseArray() (value interface{}, err error) {
+	self.advance(1)
+	items := make([]interface{}, 0)
+	for {
+		self.skipBlank()
+		if self.peek() == ']' {
+			self.advance(1)
+			return items, nil
+		}
+		var item interface{}
+		if item, err = self.parseValue(); err != nil {
+			return
+		}
+		items = append(items, item)
+		self.skipBlank()
+		switch self.peek() {
+		case ',':
+			self.advance(1)
+		case ']':
+		default:
+			return nil, self.errorf("expected , or ] in array")
+		}
+	}
+}
+
+func (self *tomlParser) parseInlineTable() (value interface{}, err error) {
+	self.advance(1)
+	table := newTomlTable()
+	self.skipSpaces()
+	if self.peek() == '}' {
+		self.advance(1)
+		table.inline = true
+		return table, nil
+	}
+	for {
+		self.skipSpaces()
+		if err = self.parseKeyValue(table); err != nil {
+			return
+		}
+		self.skipSpaces()
+		switch self.peek() {
+		case ',':
+			self.advance(1)
+		case '}':
+			self.advance(1)
+			table.inline = true
+			return table, nil
+		default:
+			return nil, self.errorf("expected , or } in inline table")
+		}
+	}
+}
+
+var (
+	tomlDatePattern     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	tomlTimePattern     = regexp.MustCompile(`^\d{2}:\d{2}(:\d{2}(\.\d+)?)?$`)
+	tomlDateTimePattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}[Tt ]\d{2}:\d{2}`)
+)
+
+// parseScalar parses numbers, dates, and times, which run until whitespace or the end of
+// an array or inline table, except for the space allowed between a date and a time
+func (self *tomlParser) parseScalar() (value interface{}, err error) {
+	start := self.pos
+	for !self.atEnd() && !strings.ContainsRune(" \t\r\n,]}#", rune(self.peek())) {
+		self.pos++
+	}
+	if tomlDatePattern.MatchString(self.text[start:self.pos]) && self.pos+3 < len(self.text) && self.text[self.pos] == ' ' && isDigit(self.text[self.pos+1]) {
+		self.pos++
+		for !self.atEnd() && !strings.ContainsRune(" \t\r\n,]}#", rune(self.peek())) {
+			self.pos++
+		}
+	}
+	token := self.text[start:self.pos]
+	if token == "" {
+		return nil, self.errorf("expected a value")
+	}
+
+	switch {
+	case tomlDateTimePattern.MatchString(token):
+		return self.parseDateTime(token)
+	case tomlDatePattern.MatchString(token):
+		return self.parseTime(token, "2006-01-02")
+	case tomlTimePattern.MatchString(token):
+		return token, nil
+	}
+
+	switch strings.TrimLeft(token, "+-") {
+	case "inf":
+		if token[0] == '-' {
+			return math.Inf(-1), nil
+		}
+		return math.Inf(1), nil
+	case "nan":
+		return math.NaN(), nil
+	}
+
+	digits := strings.Replace(token, "_", "", -1)
+	if strings.Contains(token, "__") || strings.HasPrefix(token, "_") || strings.HasSuffix(token, "_") {
+		return nil, self.errorf("bad number %s", token)
+	}
+	if len(digits) > 2 && digits[0] == '0' {
+		base := map[byte]int{'x': 16, 'o': 8, 'b': 2}[digits[1]]
+		if base != 0 {
+			n, err := strconv.ParseInt(digits[2:], base, 64)
+			if errors.Is(err, strconv.ErrRange) {
+				return nil, self.errorf("integer %s doesn't fit in 64 bits", token)
+			}
+			if err != nil {
+				return nil, self.errorf("bad number %s", token)
+			}
+			return n, nil
+		}
+	}
+	// TOML integers are 64 bit, and one that isn't has to be an error rather than rounded
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err == nil {
+		return n, nil
+	}
+	if errors.Is(err, strconv.ErrRange) {
+		return nil, self.errorf("integer %s doesn't fit in 64 bits", token)
+	}
+	if f, err := strconv.ParseFloat(digits, 64); err == nil && !strings.ContainsAny(digits, "xX") {
+		return f, nil
+	}
+	return nil, self.errorf("bad value %s", token)
+}
+
+func (self *tomlParser) parseDateTime(token string) (value interface{}, err error) {
+	normalized := strings.ToUpper(token[:10]) + "T" + strings.ToUpper(token[11:])
+	if t, err := time.Parse(time.RFC3339Nano, normalized); err == nil {
+		return t, nil
+	}
+	return self.parseTime(normalized, "2006-01-02T15:04:05.999999999")
+}
+
+func (self *tomlParser) parseTime(token string, layout string) (value interface{}, err error) {
+	t, err := time.ParseInLocation(layout, token, time.UTC)
+	if err != nil {
+		return nil, self.errorf("bad date %s", token)
+	}
+	return t, nil
+}
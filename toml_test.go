@@ -0,0 +1,159 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests reading TOML and INI documents.
+
+package golisp
+
+import (
+	. "gopkg.in/check.v1"
+	"time"
+)
+
+type TomlSuite struct {
+}
+
+var _ = Suite(&TomlSuite{})
+
+func tomlSlot(c *C, d *Data, path ...string) *Data {
+	for _, key := range path {
+		c.Assert(FrameP(d), Equals, true, Commentf("looking for %s in %s", key, String(d)))
+		d = FrameValue(d).Get(key + ":")
+	}
+	return d
+}
+
+func (s *TomlSuite) TestValues(c *C) {
+	doc, err := TomlToLisp(`
+# device settings
+name = "keyboard \"K1\"\u00e9"
+path = 'C:\devices'
+count = 1_000
+mask = 0xff
+mode = 0o17
+bits = 0b101
+ratio = 0.5
+big = 6.02e23
+tenth = 0.1
+neg = -3
+on = true
+off = false
+ports = [ 1, 2,
+          3, # trailing comma
+        ]
+mixed = [["a", 'b'], []]
+created = 1979-05-27T07:32:00-08:00
+local = 1979-05-27 07:32:00
+day = 1979-05-27
+alarm = 07:32:00
+notes = """
+first \
+    second"""
+raw = '''
+C:\raw'''
+`)
+	c.Assert(err, IsNil)
+	c.Assert(StringValue(tomlSlot(c, doc, "name")), Equals, "keyboard \"K1\"é")
+	c.Assert(StringValue(tomlSlot(c, doc, "path")), Equals, `C:\devices`)
+	c.Assert(IntegerValue(tomlSlot(c, doc, "count")), Equals, int64(1000))
+	c.Assert(IntegerValue(tomlSlot(c, doc, "mask")), Equals, int64(255))
+	c.Assert(IntegerValue(tomlSlot(c, doc, "mode")), Equals, int64(15))
+	c.Assert(IntegerValue(tomlSlot(c, doc, "bits")), Equals, int64(5))
+	c.Assert(FloatValue(tomlSlot(c, doc, "ratio")), Equals, float32(0.5))
+	c.Assert(String(tomlSlot(c, doc, "big")), Equals, "602000000000000000000000")
+	c.Assert(String(tomlSlot(c, doc, "tenth")), Equals, "0.1")
+	c.Assert(IntegerValue(tomlSlot(c, doc, "neg")), Equals, int64(-3))
+	c.Assert(BooleanValue(tomlSlot(c, doc, "on")), Equals, true)
+	c.Assert(BooleanValue(tomlSlot(c, doc, "off")), Equals, false)
+	c.Assert(String(tomlSlot(c, doc, "ports")), Equals, "(1 2 3)")
+	c.Assert(String(tomlSlot(c, doc, "mixed")), Equals, `(("a" "b") ())`)
+	c.Assert(TimeValue(tomlSlot(c, doc, "created")).Equal(time.Date(1979, 5, 27, 15, 32, 0, 0, time.UTC)), Equals, true)
+	c.Assert(TimeValue(tomlSlot(c, doc, "local")), Equals, time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC))
+	c.Assert(TimeValue(tomlSlot(c, doc, "day")), Equals, time.Date(1979, 5, 27, 0, 0, 0, 0, time.UTC))
+	c.Assert(StringValue(tomlSlot(c, doc, "alarm")), Equals, "07:32:00")
+	c.Assert(StringValue(tomlSlot(c, doc, "notes")), Equals, "first second")
+	c.Assert(StringValue(tomlSlot(c, doc, "raw")), Equals, `C:\raw`)
+}
+
+func (s *TomlSuite) TestTables(c *C) {
+	doc, err := TomlToLisp(`
+title = "devices"
+owner.name = "ops"
+
+[servers.alpha]
+ip = "10.0.0.1"
+"quoted key" = 1
+limits = { cpu = 2, mem.max = 512 }
+
+[servers]
+region = "eu"
+
+[[products]]
+name = "mouse"
+
+[[products]]
+name = "keyboard"
+[products.size]
+keys = 104
+`)
+	c.Assert(err, IsNil)
+	c.Assert(StringValue(tomlSlot(c, doc, "owner", "name")), Equals, "ops")
+	c.Assert(StringValue(tomlSlot(c, doc, "servers", "alpha", "ip")), Equals, "10.0.0.1")
+	c.Assert(IntegerValue(tomlSlot(c, doc, "servers", "alpha", "quoted key")), Equals, int64(1))
+	c.Assert(IntegerValue(tomlSlot(c, doc, "servers", "alpha", "limits", "mem", "max")), Equals, int64(512))
+	c.Assert(StringValue(tomlSlot(c, doc, "servers", "region")), Equals, "eu")
+	products := tomlSlot(c, doc, "products")
+	c.Assert(Length(products), Equals, 2)
+	c.Assert(StringValue(tomlSlot(c, First(products), "name")), Equals, "mouse")
+	c.Assert(IntegerValue(tomlSlot(c, Second(products), "size", "keys")), Equals, int64(104))
+}
+
+func (s *TomlSuite) TestErrors(c *C) {
+	for _, text := range []string{
+		"a = 1\na = 2",
+		"[t]\n[t]",
+		"a = ",
+		"a = \"open",
+		"a = 1 2",
+		"a = {b = 1}\n[a]",
+		"a = [1 2]",
+		"= 1",
+		"a = 1__0",
+		"a = \"\\q\"",
+		"a = 18446744073709551616",
+		"a = -9223372036854775809",
+		"a = 0x1FFFFFFFFFFFFFFFF",
+	} {
+		_, err := TomlToLisp(text)
+		c.Assert(err, NotNil, Commentf("%q", text))
+		c.Assert(ErrorCategoryOf(err), Equals, ParseError)
+	}
+	_, err := TomlToLisp("a = 1\nb = [1,\n2,\n3 4]")
+	c.Assert(err, ErrorMatches, "Bad TOML at line 4: .*")
+}
+
+func (s *TomlSuite) TestIni(c *C) {
+	doc, err := IniToLisp(`
+; global settings
+debug = true
+
+[device]
+name = "K1 keyboard"
+port: 3
+# comment
+[device]
+model = 'pro'
+`)
+	c.Assert(err, IsNil)
+	c.Assert(StringValue(tomlSlot(c, doc, "debug")), Equals, "true")
+	c.Assert(StringValue(tomlSlot(c, doc, "device", "name")), Equals, "K1 keyboard")
+	c.Assert(StringValue(tomlSlot(c, doc, "device", "port")), Equals, "3")
+	c.Assert(StringValue(tomlSlot(c, doc, "device", "model")), Equals, "pro")
+
+	_, err = IniToLisp("[open")
+	c.Assert(err, ErrorMatches, "Bad INI at line 1: .*")
+	_, err = IniToLisp("[s]\njust words")
+	c.Assert(err, ErrorMatches, "Bad INI at line 2: .*")
+}
@@ -0,0 +1,903 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file implements YAML<->Lisp conversions, with the same mapping as json->lisp.
+
+package golisp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// YamlToLisp reads the first document in text.  Mappings become frames, sequences become
+// lists, and scalars are resolved as in YAML 1.2's core schema: null, booleans, integers
+// (bignums if they don't fit in 64 bits), and floats, with anything else (and anything
+// quoted) a string.  Block and flow styles,
+// block scalars, anchors and aliases, and << merge keys are supported; complex keys and
+// multiple documents are not.
+func YamlToLisp(text string) (result *Data, err error) {
+	parser := newYamlParser(text)
+	value, err := parser.parseDocument()
+	if err != nil {
+		return
+	}
+	if err = checkYamlExpansion(value); err != nil {
+		return
+	}
+	return JsonToLispWithFrames(value), nil
+}
+
+// An alias shares its anchor's value, so parsing stays cheap however many there are, but
+// each use is copied when converting to Lisp.  Nested aliases multiply, so a short document
+// can expand to billions of nodes.  Documents that expand to more than
+// yamlMaxExpandedNodes, and to more than yamlMaxExpansionRatio times the nodes they
+// actually contain, are refused.
+const (
+	yamlMaxExpandedNodes  = 1000000
+	yamlMaxExpansionRatio = 10
+)
+
+type yamlNodeKey struct {
+	pointer uintptr
+	length  int
+}
+
+// yamlNodeCounter counts the nodes of a parsed document, both as written and with every
+// alias expanded, visiting each shared collection only once
+type yamlNodeCounter struct {
+	expanded map[yamlNodeKey]int
+	distinct int
+}
+
+func (self *yamlNodeCounter) count(value interface{}) int {
+	var key yamlNodeKey
+	var children []interface{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		key = yamlNodeKey{reflect.ValueOf(v).Pointer(), len(v)}
+		for _, child := range v {
+			children = append(children, child)
+		}
+	case []interface{}:
+		key = yamlNodeKey{reflect.ValueOf(v).Pointer(), len(v)}
+		children = v
+	default:
+		self.distinct++
+		return 1
+	}
+	if n, seen := self.expanded[key]; seen && key.length > 0 {
+		return n
+	}
+
+	self.distinct++
+	n := 1
+	for _, child := range children {
+		// stop counting once over the limit, so the count can't overflow
+		if n += self.count(child); n > yamlMaxExpandedNodes*yamlMaxExpansionRatio {
+			break
+		}
+	}
+	self.expanded[key] = n
+	return n
+}
+
+func checkYamlExpansion(value interface{}) error {
+	counter := &yamlNodeCounter{expanded: make(map[yamlNodeKey]int)}
+	expanded := counter.count(value)
+	if expanded > yamlMaxExpandedNodes && expanded > counter.distinct*yamlMaxExpansionRatio {
+		return NewLispError(ParseError, fmt.Sprintf("Bad YAML: aliases expand to more than %d nodes", yamlMaxExpandedNodes))
+	}
+	return nil
+}
+
+// LispToYaml writes d as a block style YAML document, converting it just as lisp->json does
+func LispToYaml(d *Data) string {
+	var b strings.Builder
+	writeYamlValue(&b, LispWithFramesToJson(d), 0, false)
+	return b.String()
+}
+
+type yamlParser struct {
+	lines   []string
+	index   int
+	anchors map[string]interface{}
+}
+
+func newYamlParser(text string) *yamlParser {
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	return &yamlParser{lines: strings.Split(text, "\n"), anchors: make(map[string]interface{})}
+}
+
+func (self *yamlParser) errorf(format string, args ...interface{}) error {
+	line := self.index + 1
+	if line > len(self.lines) {
+		line = len(self.lines)
+	}
+	return NewLispError(ParseError, fmt.Sprintf("Bad YAML at line %d: %s", line, fmt.Sprintf(format, args...)))
+}
+
+// stripYamlComment removes a comment, which starts with a # at the start of the line or
+// after whitespace, outside of quotes
+func stripYamlComment(line string) string {
+	inSingle, inDouble := false, false
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\' && inDouble:
+			i++
+		case c == '"' && !inSingle:
+			inDouble = !inDouble
+		case c == '\'' && !inDouble:
+			inSingle = !inSingle
+		case c == '#' && !inSingle && !inDouble && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return strings.TrimRight(line[:i], " \t")
+		}
+	}
+	return strings.TrimRight(line, " \t")
+}
+
+func yamlIndent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// current skips blank and comment lines, returning the indentation and content of the next
+// line with something on it, or ok false at the end of the document
+func (self *yamlParser) current() (indent int, content string, ok bool) {
+	for ; self.index < len(self.lines); self.index++ {
+		line := self.lines[self.index]
+		if line == "---" || line == "..." || strings.HasPrefix(line, "--- ") {
+			return 0, "", false
+		}
+		content = stripYamlComment(line)
+		if strings.TrimSpace(content) != "" {
+			return yamlIndent(content), strings.TrimSpace(content), true
+		}
+	}
+	return 0, "", false
+}
+
+func (self *yamlParser) parseDocument() (value interface{}, err error) {
+	for ; self.index < len(self.lines); self.index++ {
+		line := stripYamlComment(self.lines[self.index])
+		if strings.HasPrefix(line, "%") || line == "" {
+			continue
+		}
+		if line == "---" {
+			self.index++
+		} else if strings.HasPrefix(line, "--- ") {
+			self.lines[self.index] = strings.TrimPrefix(line, "--- ")
+		}
+		break
+	}
+	indent, _, ok := self.current()
+	if !ok {
+		return nil, nil
+	}
+	value, err = self.parseNode(indent)
+	if err != nil {
+		return
+	}
+	if indent, content, ok := self.current(); ok {
+		return nil, self.errorf("unexpected %q at indentation %d", content, indent)
+	}
+	return
+}
+
+// parseNode parses the block node starting on the current line, which is at indent
+func (self *yamlParser) parseNode(indent int) (value interface{}, err error) {
+	_, content, _ := self.current()
+	if content == "-" || strings.HasPrefix(content, "- ") {
+		return self.parseSequence(indent)
+	}
+	if _, _, isEntry := splitYamlMappingEntry(content); isEntry {
+		return self.parseMapping(indent)
+	}
+	self.index++
+	return self.parseInlineValue(content, indent)
+}
+
+// replaceCurrent rewrites the current line as if what follows a "- " on it started its own
+// line, so that compact nested collections parse like block ones
+func (self *yamlParser) replaceCurrent(indent int, content string) {
+	self.lines[self.index] = strings.Repeat(" ", indent) + content
+}
+
+func (self *yamlParser) parseSequence(indent int) (value interface{}, err error) {
+	items := make([]interface{}, 0)
+	for {
+		lineIndent, content, ok := self.current()
+		if !ok || lineIndent != indent || !(content == "-" || strings.HasPrefix(content, "- ")) {
+			return items, nil
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(content, "-"), " ")
+		var item interface{}
+		if item, err = self.parseEntryValue(rest, indent, indent+len(content)-len(rest), true); err != nil {
+			return
+		}
+		items = append(items, item)
+	}
+}
+
+func (self *yamlParser) parseMapping(indent int) (value interface{}, err error) {
+	values := make(map[string]interface{})
+	merged := make(map[string]interface{})
+	for {
+		lineIndent, content, ok := self.current()
+		if !ok || lineIndent != indent {
+			break
+		}
+		key, rest, isEntry := splitYamlMappingEntry(content)
+		if !isEntry {
+			return nil, self.errorf("expected a mapping entry but found %q", content)
+		}
+		if key, err = yamlKey(key); err != nil {
+			return nil, self.errorf("%s", err)
+		}
+		var entry interface{}
+		if entry, err = self.parseEntryValue(rest, indent, -1, false); err != nil {
+			return
+		}
+		if key == "<<" {
+			if err = mergeYamlMappings(merged, entry); err != nil {
+				return nil, self.errorf("%s", err)
+			}
+			continue
+		}
+		if _, found := values[key]; found {
+			return nil, self.errorf("duplicate key %s", key)
+		}
+		values[key] = entry
+	}
+	for key, entry := range merged {
+		if _, found := values[key]; !found {
+			values[key] = entry
+		}
+	}
+	return values, nil
+}
+
+func mergeYamlMappings(merged map[string]interface{}, entry interface{}) error {
+	switch v := entry.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if _, found := merged[key]; !found {
+				merged[key] = value
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := mergeYamlMappings(merged, item); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("<< requires a mapping or a list of mappings")
+	}
+	return nil
+}
+
+// parseEntryValue parses what comes after "key:" or "- " on the current line, at indent.
+// In a sequence, inlineIndent is where rest starts, for compact nested collections.
+func (self *yamlParser) parseEntryValue(rest string, indent int, inlineIndent int, inSequence bool) (value interface{}, err error) {
+	anchor, tag, rest := yamlProperties(rest)
+	defer func() {
+		if err == nil && anchor != "" {
+			self.anchors[anchor] = value
+		}
+	}()
+
+	if rest != "" && inSequence && (rest == "-" || strings.HasPrefix(rest, "- ") || isYamlMappingEntry(rest)) {
+		self.replaceCurrent(inlineIndent, rest)
+		return self.parseNode(inlineIndent)
+	}
+	if strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">") {
+		return self.parseBlockScalar(rest, indent)
+	}
+	self.index++
+	if rest != "" {
+		// a plain scalar can continue on more indented lines
+		if !strings.ContainsAny(rest[:1], "\"'[{*") {
+			for {
+				nextIndent, next, ok := self.current()
+				if !ok || nextIndent <= indent {
+					break
+				}
+				if isYamlMappingEntry(next) {
+					return nil, self.errorf("a mapping can't follow the value %q", rest)
+				}
+				rest += " " + next
+				self.index++
+			}
+		}
+		if tag == "!!str" {
+			return yamlUnquote(rest)
+		}
+		return self.parseInlineValue(rest, indent)
+	}
+
+	nextIndent, next, ok := self.current()
+	switch {
+	case ok && nextIndent > indent:
+		return self.parseNode(nextIndent)
+	case ok && nextIndent == indent && !inSequence && (next == "-" || strings.HasPrefix(next, "- ")):
+		return self.parseSequence(indent)
+	}
+	return nil, nil
+}
+
+// yamlProperties splits the &anchor and !tag off the start of a node
+func yamlProperties(rest string) (anchor string, tag string, remaining string) {
+	for {
+		rest = strings.TrimLeft(rest, " ")
+		if !strings.HasPrefix(rest, "&") && !strings.HasPrefix(rest, "!") {
+			return anchor, tag, rest
+		}
+		end := strings.IndexAny(rest, " \t")
+		if end == -1 {
+			end = len(rest)
+		}
+		if rest[0] == '&' {
+			anchor = rest[1:end]
+		} else {
+			tag = rest[:end]
+		}
+		rest = rest[end:]
+	}
+}
+
+// splitYamlMappingEntry splits "key: value" at the first colon that is followed by a space
+// or ends the line and isn't in quotes or a flow collection
+func splitYamlMappingEntry(content string) (key string, rest string, ok bool) {
+	if strings.HasPrefix(content, "[") || strings.HasPrefix(content, "{") {
+		return "", "", false
+	}
+	inSingle, inDouble := false, false
+	for i := 0; i < len(content); i++ {
+		switch c := content[i]; {
+		case c == '\\' && inDouble:
+			i++
+		case c == '"' && !inSingle:
+			inDouble = !inDouble
+		case c == '\'' && !inDouble:
+			inSingle = !inSingle
+		case c == ':' && !inSingle && !inDouble && (i+1 == len(content) || content[i+1] == ' '):
+			return strings.TrimSpace(content[:i]), strings.TrimSpace(content[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+func isYamlMappingEntry(content string) bool {
+	_, _, ok := splitYamlMappingEntry(content)
+	return ok
+}
+
+func yamlKey(key string) (string, error) {
+	if strings.HasPrefix(key, "\"") || strings.HasPrefix(key, "'") {
+		unquoted, err := yamlUnquote(key)
+		if err != nil {
+			return "", err
+		}
+		return unquoted.(string), nil
+	}
+	return key, nil
+}
+
+func (self *yamlParser) parseBlockScalar(header string, indent int) (value interface{}, err error) {
+	folded := header[0] == '>'
+	chomping := byte(0)
+	explicitIndent := 0
+	for _, c := range header[1:] {
+		switch {
+		case c == '-' || c == '+':
+			chomping = byte(c)
+		case c >= '1' && c <= '9':
+			explicitIndent = int(c - '0')
+		default:
+			return nil, self.errorf("bad block scalar header %s", header)
+		}
+	}
+	self.index++
+
+	contentIndent := indent + explicitIndent
+	lines := make([]string, 0)
+	for ; self.index < len(self.lines); self.index++ {
+		line := self.lines[self.index]
+		if strings.TrimSpace(line) == "" {
+			lines = append(lines, "")
+			continue
+		}
+		lineIndent := yamlIndent(line)
+		if explicitIndent == 0 && contentIndent == indent {
+			if lineIndent <= indent {
+				break
+			}
+			contentIndent = lineIndent
+		}
+		if lineIndent < contentIndent {
+			break
+		}
+		lines = append(lines, line[contentIndent:])
+	}
+
+	// trailing blank lines are subject to chomping, and don't belong to what follows
+	trailing := 0
+	for trailing < len(lines) && lines[len(lines)-1-trailing] == "" {
+		trailing++
+	}
+	lines = lines[:len(lines)-trailing]
+
+	var text string
+	if folded {
+		text = foldYamlLines(lines)
+	} else {
+		text = strings.Join(lines, "\n")
+	}
+	switch {
+	case len(lines) == 0:
+	case chomping == '-':
+	case chomping == '+':
+		text += strings.Repeat("\n", trailing+1)
+	default:
+		text += "\n"
+	}
+	return text, nil
+}
+
+// foldYamlLines joins lines with spaces, except that blank lines become newlines and more
+// indented lines are kept as they are
+func foldYamlLines(lines []string) string {
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			previous := lines[i-1]
+			switch {
+			case line == "" && previous != "":
+				// the line break before a run of blank lines is folded away
+			case previous == "":
+				b.WriteByte('\n')
+			case strings.HasPrefix(line, " ") || strings.HasPrefix(previous, " "):
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// parseInlineValue parses a scalar, an alias, or a flow collection that (once joined with
+// any lines it continues onto) takes up the rest of an entry
+func (self *yamlParser) parseInlineValue(text string, indent int) (value interface{}, err error) {
+	if strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{") {
+		for !yamlFlowBalanced(text) {
+			_, next, ok := self.current()
+			if !ok {
+				return nil, self.errorf("unterminated flow collection")
+			}
+			text += " " + next
+			self.index++
+		}
+		flow := &yamlFlowParser{text: text, anchors: self.anchors}
+		if value, err = flow.parseValue(); err != nil {
+			return nil, self.errorf("%s", err)
+		}
+		flow.skipSpaces()
+		if flow.pos != len(flow.text) {
+			return nil, self.errorf("unexpected %q after flow collection", flow.text[flow.pos:])
+		}
+		return
+	}
+	value, err = resolveYamlScalar(text, self.anchors)
+	if err != nil {
+		err = self.errorf("%s", err)
+	}
+	return
+}
+
+func yamlFlowBalanced(text string) bool {
+	depth := 0
+	inSingle, inDouble := false, false
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '\\' && inDouble:
+			i++
+		case c == '"' && !inSingle:
+			inDouble = !inDouble
+		case c == '\'' && !inDouble:
+			inSingle = !inSingle
+		case (c == '[' || c == '{') && !inSingle && !inDouble:
+			depth++
+		case (c == ']' || c == '}') && !inSingle && !inDouble:
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+var (
+	yamlIntPattern   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlFloatPattern = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+)
+
+// resolveYamlScalar turns the text of a scalar into a value, following an alias if it is one
+func resolveYamlScalar(text string, anchors map[string]interface{}) (value interface{}, err error) {
+	anchor, tag, text := yamlProperties(text)
+	defer func() {
+		if err == nil && anchor != "" && anchors != nil {
+			anchors[anchor] = value
+		}
+	}()
+
+	if strings.HasPrefix(text, "*") {
+		value, found := anchors[text[1:]]
+		if !found {
+			return nil, fmt.Errorf("unknown alias %s", text)
+		}
+		return value, nil
+	}
+	if strings.HasPrefix(text, "\"") || strings.HasPrefix(text, "'") || tag == "!!str" {
+		return yamlUnquote(text)
+	}
+
+	switch text {
+	case "", "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+		return math.Inf(1), nil
+	case "-.inf", "-.Inf", "-.INF":
+		return math.Inf(-1), nil
+	case ".nan", ".NaN", ".NAN":
+		return math.NaN(), nil
+	}
+	if yamlIntPattern.MatchString(text) {
+		return yamlInteger(text, 10)
+	}
+	if strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0o") {
+		base := 16
+		if text[1] == 'o' {
+			base = 8
+		}
+		if n, err := yamlInteger(text[2:], base); err == nil {
+			return n, nil
+		}
+	}
+	if yamlFloatPattern.MatchString(text) {
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f, nil
+		}
+	}
+	return text, nil
+}
+
+// yamlInteger reads digits in base as an int64, or as a big.Int if they don't fit in one
+func yamlInteger(digits string, base int) (interface{}, error) {
+	if n, err := strconv.ParseInt(digits, base, 64); err == nil {
+		return n, nil
+	}
+	n, ok := new(big.Int).SetString(digits, base)
+	if !ok {
+		return nil, fmt.Errorf("bad integer %s", digits)
+	}
+	return n, nil
+}
+
+// yamlUnquote returns the contents of a quoted scalar, or text itself if it isn't quoted
+func yamlUnquote(text string) (interface{}, error) {
+	switch {
+	case len(text) >= 2 && text[0] == '\'' && text[len(text)-1] == '\'':
+		return strings.Replace(text[1:len(text)-1], "''", "'", -1), nil
+	case len(text) >= 2 && text[0] == '"' && text[len(text)-1] == '"':
+		var s string
+		if err := json.Unmarshal([]byte(yamlEscapesToJson(text)), &s); err != nil {
+			return nil, fmt.Errorf("bad double quoted string %s", text)
+		}
+		return s, nil
+	case strings.HasPrefix(text, "'") || strings.HasPrefix(text, "\""):
+		return nil, fmt.Errorf("unterminated string %s", text)
+	}
+	return text, nil
+}
+
+// yamlEscapesToJson rewrites the escapes YAML allows in double quoted strings that JSON
+// doesn't into ones it does
+func yamlEscapesToJson(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' || i+1 == len(text) {
+			b.WriteByte(text[i])
+			continue
+		}
+		i++
+		switch text[i] {
+		case '0':
+			b.WriteString(`\u0000`)
+		case 'a':
+			b.WriteString(`\u0007`)
+		case 'e':
+			b.WriteString(`\u001b`)
+		case 'v':
+			b.WriteString(`\u000b`)
+		case ' ', '\'':
+			b.WriteByte(text[i])
+		case 'x':
+			if i+2 < len(text) {
+				b.WriteString(`\u00` + text[i+1:i+3])
+				i += 2
+			}
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(text[i])
+		}
+	}
+	return b.String()
+}
+
+// yamlFlowParser parses [sequences] and {mappings} written on one (joined) line
+type yamlFlowParser struct {
+	text    string
+	pos     int
+	anchors map[string]interface{}
+}
+
+func (self *yamlFlowParser) skipSpaces() {
+	for self.pos < len(self.text) && (self.text[self.pos] == ' ' || self.text[self.pos] == '\t') {
+		self.pos++
+	}
+}
+
+func (self *yamlFlowParser) peek() byte {
+	if self.pos >= len(self.text) {
+		return 0
+	}
+	return self.text[self.pos]
+}
+
+func (self *yamlFlowParser) parseValue() (value interface{}, err error) {
+	self.skipSpaces()
+	anchor := ""
+	if self.peek() == '&' {
+		end := self.pos
+		for end < len(self.text) && self.text[end] != ' ' {
+			end++
+		}
+		anchor = self.text[self.pos+1 : end]
+		self.pos = end
+		self.skipSpaces()
+	}
+	switch self.peek() {
+	case '[':
+		value, err = self.parseSequence()
+	case '{':
+		value, err = self.parseMapping()
+	default:
+		value, err = resolveYamlScalar(self.scalarText(false), self.anchors)
+	}
+	if err == nil && anchor != "" {
+		self.anchors[anchor] = value
+	}
+	return
+}
+
+// scalarText returns the text of a scalar in a flow collection, which ends at a , ] or },
+// or at a ": " for keys
+func (self *yamlFlowParser) scalarText(key bool) string {
+	self.skipSpaces()
+	start := self.pos
+	if c := self.peek(); c == '"' || c == '\'' {
+		for self.pos++; self.pos < len(self.text); self.pos++ {
+			if c == '"' && self.text[self.pos] == '\\' {
+				self.pos++
+			} else if self.text[self.pos] == c {
+				if c == '\'' && self.pos+1 < len(self.text) && self.text[self.pos+1] == '\'' {
+					self.pos++
+					continue
+				}
+				self.pos++
+				break
+			}
+		}
+		return self.text[start:self.pos]
+	}
+	for self.pos < len(self.text) && !strings.ContainsRune(",]}", rune(self.text[self.pos])) {
+		if key && self.text[self.pos] == ':' && (self.pos+1 == len(self.text) || strings.ContainsRune(" ,]}", rune(self.text[self.pos+1]))) {
+			break
+		}
+		self.pos++
+	}
+	return strings.TrimSpace(self.text[start:self.pos])
+}
+
+func (self *yamlFlowParser) parseSequence() (value interface{}, err error) {
+	self.pos++
+	items := make([]interface{}, 0)
+	for {
+		self.skipSpaces()
+		if self.peek() == ']' {
+			self.pos++
+			return items, nil
+		}
+		var item interface{}
+		if item, err = self.parseValue(); err != nil {
+			return
+		}
+		items = append(items, item)
+		self.skipSpaces()
+		switch self.peek() {
+		case ',':
+			self.pos++
+		case ']':
+		default:
+			return nil, fmt.Errorf("expected , or ] in flow sequence")
+		}
+	}
+}
+
+func (self *yamlFlowParser) parseMapping() (value interface{}, err error) {
+	self.pos++
+	values := make(map[string]interface{})
+	for {
+		self.skipSpaces()
+		if self.peek() == '}' {
+			self.pos++
+			return values, nil
+		}
+		key, err := yamlKey(self.scalarText(true))
+		if err != nil {
+			return nil, err
+		}
+		self.skipSpaces()
+		var entry interface{}
+		if self.peek() == ':' {
+			self.pos++
+			if entry, err = self.parseValue(); err != nil {
+				return nil, err
+			}
+		}
+		values[key] = entry
+		self.skipSpaces()
+		switch self.peek() {
+		case ',':
+			self.pos++
+		case '}':
+		default:
+			return nil, fmt.Errorf("expected , or } in flow mapping")
+		}
+	}
+}
+
+// writeYamlValue writes value at indent.  inline is true when value follows a "- " or
+// "key: " on the current line.
+func writeYamlValue(b *strings.Builder, value interface{}, indent int, inline bool) {
+	prefix := strings.Repeat("  ", indent)
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			b.WriteString("{}\n")
+			return
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for i, key := range keys {
+			if i > 0 || !inline {
+				b.WriteString(prefix)
+			}
+			b.WriteString(yamlScalar(key))
+			b.WriteString(":")
+			writeYamlEntry(b, v[key], indent)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			b.WriteString("[]\n")
+			return
+		}
+		for i, item := range v {
+			if i > 0 || !inline {
+				b.WriteString(prefix)
+			}
+			b.WriteString("-")
+			if isYamlCollection(item) {
+				b.WriteString(" ")
+				writeYamlValue(b, item, indent+1, true)
+			} else {
+				b.WriteString(" " + yamlScalar(item) + "\n")
+			}
+		}
+	default:
+		b.WriteString(yamlScalar(v) + "\n")
+	}
+}
+
+func writeYamlEntry(b *strings.Builder, value interface{}, indent int) {
+	if isYamlCollection(value) {
+		b.WriteString("\n")
+		writeYamlValue(b, value, indent+1, false)
+	} else {
+		b.WriteString(" " + yamlScalar(value) + "\n")
+	}
+}
+
+// isYamlCollection reports whether value is written in block style, on lines of its own
+func isYamlCollection(value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return len(v) > 0
+	case []interface{}:
+		return len(v) > 0
+	}
+	return false
+}
+
+// yamlScalar writes a scalar, quoting strings that would otherwise read back as something
+// else
+func yamlScalar(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "{}"
+	case []interface{}:
+		return "[]"
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float32:
+		return yamlFloat(float64(v), 32)
+	case float64:
+		return yamlFloat(v, 64)
+	case string:
+		if yamlNeedsQuotes(v) {
+			quoted, _ := json.Marshal(v)
+			return string(quoted)
+		}
+		return v
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func yamlFloat(f float64, bits int) string {
+	switch {
+	case math.IsInf(f, 1):
+		return ".inf"
+	case math.IsInf(f, -1):
+		return "-.inf"
+	case math.IsNaN(f):
+		return ".nan"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, bits)
+	if !strings.ContainsAny(s, ".eEn") {
+		s += ".0"
+	}
+	return s
+}
+
+func yamlNeedsQuotes(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s {
+		return true
+	}
+	if resolved, _ := resolveYamlScalar(s, nil); resolved != s {
+		return true
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`~") {
+		return true
+	}
+	return strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") || strings.ContainsAny(s, "\n\t\\")
+}
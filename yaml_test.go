@@ -0,0 +1,192 @@
+// Copyright 2015 SteelSeries ApS.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This package implements a basic LISP interpretor for embedding in a go program for scripting.
+// This file tests YAML<->Lisp conversions.
+
+package golisp
+
+import (
+	"fmt"
+	. "gopkg.in/check.v1"
+	"strings"
+)
+
+type YamlSuite struct {
+}
+
+var _ = Suite(&YamlSuite{})
+
+func (s *YamlSuite) TestBlockCollections(c *C) {
+	doc, err := YamlToLisp(`
+# CI config
+name: build
+on: true
+retries: 3
+timeout: 1.5
+empty:
+tilde: ~
+quoted: "yes: \"really\"\t"
+single: 'it''s'
+version: "3"
+url: http://example.com/a#b  # a comment
+steps:
+  - checkout
+  - run: make test
+    shell: bash
+  -
+    - nested
+    - list
+env:
+  GOPATH: /go
+  flags: [-v, "-race", {short: true}]
+tags: [a, b,
+       c]
+`)
+	c.Assert(err, IsNil)
+	c.Assert(StringValue(tomlSlot(c, doc, "name")), Equals, "build")
+	c.Assert(BooleanValue(tomlSlot(c, doc, "on")), Equals, true)
+	c.Assert(IntegerValue(tomlSlot(c, doc, "retries")), Equals, int64(3))
+	c.Assert(FloatValue(tomlSlot(c, doc, "timeout")), Equals, float32(1.5))
+	c.Assert(tomlSlot(c, doc, "empty"), IsNil)
+	c.Assert(tomlSlot(c, doc, "tilde"), IsNil)
+	c.Assert(StringValue(tomlSlot(c, doc, "quoted")), Equals, "yes: \"really\"\t")
+	c.Assert(StringValue(tomlSlot(c, doc, "single")), Equals, "it's")
+	c.Assert(StringValue(tomlSlot(c, doc, "version")), Equals, "3")
+	c.Assert(StringValue(tomlSlot(c, doc, "url")), Equals, "http://example.com/a#b")
+	steps := tomlSlot(c, doc, "steps")
+	c.Assert(Length(steps), Equals, 3)
+	c.Assert(StringValue(First(steps)), Equals, "checkout")
+	c.Assert(StringValue(tomlSlot(c, Second(steps), "run")), Equals, "make test")
+	c.Assert(StringValue(tomlSlot(c, Second(steps), "shell")), Equals, "bash")
+	c.Assert(String(Third(steps)), Equals, `("nested" "list")`)
+	c.Assert(StringValue(tomlSlot(c, doc, "env", "GOPATH")), Equals, "/go")
+	flags := tomlSlot(c, doc, "env", "flags")
+	c.Assert(StringValue(First(flags)), Equals, "-v")
+	c.Assert(BooleanValue(tomlSlot(c, Third(flags), "short")), Equals, true)
+	c.Assert(String(tomlSlot(c, doc, "tags")), Equals, `("a" "b" "c")`)
+}
+
+func (s *YamlSuite) TestBlockScalars(c *C) {
+	doc, err := YamlToLisp(`---
+script: |
+  make
+    indented
+
+  test
+folded: >-
+  one
+  two
+
+  three
+kept: |+
+  x
+
+plain: a long
+  line
+after: 1
+`)
+	c.Assert(err, IsNil)
+	c.Assert(StringValue(tomlSlot(c, doc, "script")), Equals, "make\n  indented\n\ntest\n")
+	c.Assert(StringValue(tomlSlot(c, doc, "folded")), Equals, "one two\nthree")
+	c.Assert(StringValue(tomlSlot(c, doc, "kept")), Equals, "x\n\n")
+	c.Assert(StringValue(tomlSlot(c, doc, "plain")), Equals, "a long line")
+	c.Assert(IntegerValue(tomlSlot(c, doc, "after")), Equals, int64(1))
+}
+
+func (s *YamlSuite) TestAnchorsAndMerges(c *C) {
+	doc, err := YamlToLisp(`
+defaults: &defaults
+  image: golang
+  retries: 2
+build:
+  <<: *defaults
+  retries: 5
+ports: &ports [80, 443]
+more: *ports
+`)
+	c.Assert(err, IsNil)
+	c.Assert(StringValue(tomlSlot(c, doc, "build", "image")), Equals, "golang")
+	c.Assert(IntegerValue(tomlSlot(c, doc, "build", "retries")), Equals, int64(5))
+	c.Assert(String(tomlSlot(c, doc, "more")), Equals, "(80 443)")
+}
+
+func (s *YamlSuite) TestAliasExpansionIsLimited(c *C) {
+	var text strings.Builder
+	text.WriteString("l0: &l0 [x, x, x, x, x, x, x, x, x]\n")
+	for level := 1; level <= 9; level++ {
+		fmt.Fprintf(&text, "l%d: &l%d [", level, level)
+		for i := 0; i < 9; i++ {
+			if i > 0 {
+				text.WriteString(", ")
+			}
+			fmt.Fprintf(&text, "*l%d", level-1)
+		}
+		text.WriteString("]\n")
+	}
+	_, err := YamlToLisp(text.String())
+	c.Assert(err, ErrorMatches, ".*aliases expand.*")
+
+	doc, err := YamlToLisp("a: &a [1, 2]\nb: [*a, *a, *a]\n")
+	c.Assert(err, IsNil)
+	c.Assert(String(tomlSlot(c, doc, "b")), Equals, "((1 2) (1 2) (1 2))")
+}
+
+func (s *YamlSuite) TestScalarDocuments(c *C) {
+	doc, err := YamlToLisp("- 1\n- two\n")
+	c.Assert(err, IsNil)
+	c.Assert(String(doc), Equals, `(1 "two")`)
+	doc, err = YamlToLisp("")
+	c.Assert(err, IsNil)
+	c.Assert(doc, IsNil)
+}
+
+func (s *YamlSuite) TestLargeIntegers(c *C) {
+	doc, err := YamlToLisp("- 18446744073709551616\n- -9223372036854775809\n- 0xFFFFFFFFFFFFFFFF\n- 1e30\n")
+	c.Assert(err, IsNil)
+	c.Assert(String(doc), Equals, "(18446744073709551616 -9223372036854775809 18446744073709551615 1.0e+30)")
+	c.Assert(BigIntegerP(First(doc)), Equals, true)
+	c.Assert(LispToYaml(First(doc)), Equals, "18446744073709551616\n")
+}
+
+func (s *YamlSuite) TestErrors(c *C) {
+	for _, text := range []string{
+		"a: 1\na: 2",
+		"a: [1, 2",
+		"a: *missing",
+		"a: 'open",
+		"a: 1\n  b: 2",
+	} {
+		_, err := YamlToLisp(text)
+		c.Assert(err, NotNil, Commentf("%q", text))
+		c.Assert(ErrorCategoryOf(err), Equals, ParseError)
+	}
+}
+
+func (s *YamlSuite) TestRoundTrip(c *C) {
+	original, err := ParseAndEval(`{name: "build" count: 3 ratio: 0.5 on: #t
+                                      steps: (list "make" {run: "test: all" env: {}})
+                                      odd: (list "true" "12" "" "- x" "a #b" "multi\nline")}`)
+	c.Assert(err, IsNil)
+	text := LispToYaml(original)
+	c.Assert(text, Equals, `count: 3
+name: build
+odd:
+  - "true"
+  - "12"
+  - ""
+  - "- x"
+  - "a #b"
+  - "multi\nline"
+on: true
+ratio: 0.5
+steps:
+  - make
+  - env: {}
+    run: "test: all"
+`)
+	back, err := YamlToLisp(text)
+	c.Assert(err, IsNil)
+	c.Assert(LispWithFramesToJsonString(back), Equals, LispWithFramesToJsonString(original))
+}